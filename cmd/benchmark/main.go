@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
@@ -9,6 +10,15 @@ import (
 )
 
 func main() {
+	defaults := benchmarking.DefaultRegressionConfig()
+	baselinePath := flag.String("baseline", "", "compare results against the baseline report at this path and exit non-zero on regression")
+	saveBaselinePath := flag.String("save-baseline", "", "save results as the baseline report at this path")
+	maxThroughputDrop := flag.Float64("max-throughput-drop", defaults.MaxThroughputDrop, "allowed throughput drop in percent (0 disables)")
+	maxMemoryGrowth := flag.Float64("max-memory-growth", defaults.MaxMemoryPerOpGrowth, "allowed memory per operation growth in percent (0 disables)")
+	maxDurationGrowth := flag.Float64("max-duration-growth", defaults.MaxDurationGrowth, "allowed duration growth in percent (0 disables)")
+	failOnMissing := flag.Bool("fail-on-missing", defaults.FailOnMissing, "fail when a baseline benchmark did not run")
+	flag.Parse()
+
 	fmt.Println("🚀 ADRENOCHAIN BENCHMARKING SUITE")
 	fmt.Println("==================================")
 
@@ -27,5 +37,32 @@ func main() {
 		os.Exit(1)
 	}
 
+	if *saveBaselinePath != "" {
+		if err := orchestrator.SaveBaseline(*saveBaselinePath); err != nil {
+			log.Printf("Failed to save baseline: %v", err)
+			os.Exit(1)
+		}
+		fmt.Printf("📄 Baseline saved to: %s\n", *saveBaselinePath)
+	}
+
+	// Gate on the baseline
+	if *baselinePath != "" {
+		config := &benchmarking.RegressionConfig{
+			MaxThroughputDrop:    *maxThroughputDrop,
+			MaxMemoryPerOpGrowth: *maxMemoryGrowth,
+			MaxDurationGrowth:    *maxDurationGrowth,
+			FailOnMissing:        *failOnMissing,
+		}
+		result, err := orchestrator.CheckRegression(*baselinePath, config)
+		if err != nil {
+			log.Printf("Regression check failed: %v", err)
+			os.Exit(1)
+		}
+		fmt.Print("\n" + result.String())
+		if !result.Passed() {
+			os.Exit(1)
+		}
+	}
+
 	fmt.Println("\n✅ All benchmarks completed successfully!")
 }
@@ -0,0 +1,253 @@
+//go:build go1.20
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/palaseus/adrenochain/pkg/chain"
+	"github.com/palaseus/adrenochain/pkg/consensus"
+	"github.com/palaseus/adrenochain/pkg/mempool"
+	"github.com/palaseus/adrenochain/pkg/miner"
+	netpkg "github.com/palaseus/adrenochain/pkg/net"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/spf13/viper"
+)
+
+// supportedNetworks lists the accepted values of the --network flag.
+var supportedNetworks = []string{"mainnet", "testnet", "devnet"}
+
+// nodeConfig gathers the configuration of every node component, built from
+// the command line flags and the viper configuration.
+type nodeConfig struct {
+	Network         string
+	StorageType     storage.StorageType
+	DataDir         string
+	LevelDB         *storage.LevelDBStorageConfig
+	PersistAddrBook bool
+	PersistMempool  bool
+	// ReadOnly syncs and serves blocks but never mines, relays or accepts
+	// transactions.
+	ReadOnly bool
+
+	Chain     *chain.ChainConfig
+	Consensus *consensus.ConsensusConfig
+	Mempool   *mempool.MempoolConfig
+	Miner     *miner.MinerConfig
+	Net       *netpkg.NetworkConfig
+}
+
+// buildNodeConfig builds the node configuration from the command line flags
+// and the loaded viper configuration.
+func buildNodeConfig() *nodeConfig {
+	cfg := &nodeConfig{
+		Network:         network,
+		StorageType:     storage.StorageTypeFile,
+		DataDir:         viper.GetString("storage.data_dir"),
+		LevelDB:         storage.DefaultLevelDBStorageConfig(),
+		PersistAddrBook: viper.GetBool("network.persist_addrbook"),
+		PersistMempool:  viper.GetBool("mempool.persist"),
+		ReadOnly:        readOnly || viper.GetBool("read_only"),
+		Chain:           chain.DefaultChainConfig(),
+		Consensus:       consensus.NetworkConsensusConfig(network),
+		Mempool:         mempool.DefaultMempoolConfig(),
+		Miner:           miner.DefaultMinerConfig(),
+		Net:             netpkg.DefaultNetworkConfig(),
+	}
+
+	if dbType := viper.GetString("storage.db_type"); dbType != "" {
+		cfg.StorageType = storage.StorageType(dbType)
+	}
+	if cfg.DataDir == "" {
+		cfg.DataDir = "./data"
+	}
+	cfg.LevelDB.CompactionInterval = viper.GetDuration("storage.compaction_interval")
+	cfg.LevelDB.CompactAfterDeletions = viper.GetInt("storage.compact_after_deletions")
+
+	if viper.IsSet("blockchain.genesis_difficulty") {
+		cfg.Consensus.GenesisDifficulty = viper.GetUint64("blockchain.genesis_difficulty")
+	}
+	if viper.IsSet("blockchain.min_difficulty") {
+		cfg.Consensus.MinDifficulty = viper.GetUint64("blockchain.min_difficulty")
+	}
+	cfg.Consensus.SignalWindow = viper.GetUint64("blockchain.signal_window")
+	cfg.Consensus.SignalThreshold = viper.GetUint64("blockchain.signal_threshold")
+	cfg.Consensus.TestnetMinDifficultyAfter = viper.GetDuration("blockchain.testnet_min_difficulty_after")
+
+	cfg.Chain.Network = network
+	if viper.IsSet("blockchain.max_block_size") {
+		cfg.Chain.MaxBlockSize = viper.GetUint64("blockchain.max_block_size")
+		cfg.Miner.MaxBlockSize = cfg.Chain.MaxBlockSize
+	}
+	if viper.IsSet("blockchain.max_block_sigops") {
+		cfg.Chain.MaxBlockSigOps = viper.GetUint64("blockchain.max_block_sigops")
+		cfg.Miner.MaxBlockSigOps = cfg.Chain.MaxBlockSigOps
+	}
+	if viper.IsSet("blockchain.max_transactions_per_block") {
+		cfg.Chain.MaxTransactionsPerBlock = viper.GetUint64("blockchain.max_transactions_per_block")
+		cfg.Miner.MaxTransactionsPerBlock = cfg.Chain.MaxTransactionsPerBlock
+	}
+	cfg.Chain.InitialBlockSubsidy = viper.GetUint64("blockchain.initial_block_subsidy")
+	if viper.IsSet("blockchain.subsidy_halving_interval") {
+		cfg.Chain.SubsidyHalvingInterval = viper.GetUint64("blockchain.subsidy_halving_interval")
+	}
+	if viper.IsSet("blockchain.coinbase_maturity") {
+		cfg.Chain.CoinbaseMaturity = viper.GetUint64("blockchain.coinbase_maturity")
+	}
+	cfg.Chain.MaxTipAge = viper.GetDuration("blockchain.max_tip_age")
+	cfg.Chain.MinimumChainWork = viper.GetUint64("blockchain.minimum_chain_work")
+	if viper.IsSet("blockchain.safe_mode_on_write_failure") {
+		cfg.Chain.SafeModeOnWriteFailure = viper.GetBool("blockchain.safe_mode_on_write_failure")
+	}
+	if viper.IsSet("blockchain.warn_on_invalid_chain") {
+		cfg.Chain.WarnOnInvalidChain = viper.GetBool("blockchain.warn_on_invalid_chain")
+	}
+	cfg.Chain.AutoRecover = viper.GetBool("blockchain.auto_recover")
+	if viper.IsSet("blockchain.address_index") {
+		cfg.Chain.AddressIndex = viper.GetBool("blockchain.address_index")
+	}
+	cfg.Chain.TxIndexRetention = viper.GetUint64("blockchain.tx_index_retention")
+	cfg.Chain.BlockUndo = viper.GetBool("blockchain.block_undo")
+	cfg.Chain.MaxUTXOCacheEntries = viper.GetInt("blockchain.max_utxo_cache_entries")
+	if viper.IsSet("blockchain.header_cache_size") {
+		cfg.Chain.ValidatedHeaderCacheSize = viper.GetInt("blockchain.header_cache_size")
+	}
+	cfg.Chain.AuditInterval = viper.GetDuration("blockchain.utxo_audit_interval")
+	cfg.Chain.StateFlushBlocks = viper.GetUint64("blockchain.state_flush_blocks")
+	cfg.Chain.StateFlushInterval = viper.GetDuration("blockchain.state_flush_interval")
+	cfg.Chain.PruneDepth = viper.GetUint64("blockchain.prune_depth")
+	cfg.Chain.PruneInterval = viper.GetDuration("blockchain.prune_interval")
+	if viper.IsSet("blockchain.deep_reorg_depth") {
+		cfg.Chain.DeepReorgDepth = viper.GetUint64("blockchain.deep_reorg_depth")
+	}
+	if viper.IsSet("blockchain.enforce_sequence_locks") {
+		cfg.Chain.EnforceSequenceLocks = viper.GetBool("blockchain.enforce_sequence_locks")
+		cfg.Mempool.EnforceSequenceLocks = cfg.Chain.EnforceSequenceLocks
+	}
+	if viper.IsSet("blockchain.enforce_difficulty") {
+		cfg.Chain.EnforceDifficulty = viper.GetBool("blockchain.enforce_difficulty")
+	}
+	if viper.IsSet("blockchain.validation_timings") {
+		cfg.Chain.ValidationTimings = viper.GetBool("blockchain.validation_timings")
+	}
+
+	if viper.IsSet("mempool.max_size") {
+		cfg.Mempool.MaxSize = viper.GetUint64("mempool.max_size")
+	}
+	cfg.Mempool.FreeTxMinPriority = viper.GetUint64("mempool.free_tx_min_priority")
+	cfg.Mempool.WeightAccounting = viper.GetBool("mempool.weight_accounting")
+	if viper.IsSet("mempool.max_tx_script_sig_size") {
+		cfg.Mempool.ScriptLimits.MaxScriptSigSize = viper.GetUint64("mempool.max_tx_script_sig_size")
+	}
+	if viper.IsSet("mempool.max_tx_script_pubkey_size") {
+		cfg.Mempool.ScriptLimits.MaxScriptPubKeySize = viper.GetUint64("mempool.max_tx_script_pubkey_size")
+	}
+	if viper.IsSet("mempool.reorg_retention") {
+		cfg.Mempool.ReorgRetention = viper.GetDuration("mempool.reorg_retention")
+	}
+	if viper.IsSet("mempool.rebroadcast_interval") {
+		cfg.Mempool.RebroadcastInterval = viper.GetDuration("mempool.rebroadcast_interval")
+	}
+	if viper.IsSet("mempool.max_rebroadcasts") {
+		cfg.Mempool.MaxRebroadcasts = viper.GetInt("mempool.max_rebroadcasts")
+	}
+	cfg.Mempool.DiffusionDelayRange = mempool.DelayRange{
+		Min: viper.GetDuration("mempool.diffusion_delay_min"),
+		Max: viper.GetDuration("mempool.diffusion_delay_max"),
+	}
+
+	cfg.Miner.MiningEnabled = mining
+	// Mine at the pace of the network's target block time
+	cfg.Miner.BlockTime = cfg.Consensus.TargetBlockTime
+	cfg.Miner.FreeTxSpace = viper.GetUint64("mining.free_tx_space")
+	cfg.Miner.NonceStart = viper.GetUint64("mining.nonce_start")
+	cfg.Miner.NonceStride = viper.GetUint64("mining.nonce_stride")
+	if viper.IsSet("mining.max_package_size") {
+		cfg.Miner.MaxPackageSize = viper.GetUint64("mining.max_package_size")
+	}
+	cfg.Miner.CoinbaseAddress = viper.GetString("mining.coinbase_address")
+
+	if cfg.ReadOnly {
+		cfg.Miner.ReadOnly = true
+		cfg.Mempool.RebroadcastInterval = 0
+	}
+
+	if viper.IsSet("network.block_queue_size") {
+		cfg.Net.BlockQueueSize = viper.GetInt("network.block_queue_size")
+	}
+	if viper.IsSet("network.stall_timeout") {
+		cfg.Net.StallTimeout = viper.GetDuration("network.stall_timeout")
+	}
+	if viper.IsSet("network.max_clock_offset") {
+		cfg.Net.MaxClockOffset = viper.GetDuration("network.max_clock_offset")
+	}
+	if viper.IsSet("network.max_announcements_per_peer") {
+		cfg.Net.MaxAnnouncementsPerPeer = viper.GetInt("network.max_announcements_per_peer")
+	}
+	if viper.IsSet("network.announcement_window") {
+		cfg.Net.AnnouncementWindow = viper.GetDuration("network.announcement_window")
+	}
+	cfg.Net.ListenPort = port
+	cfg.Net.EnableMDNS = true
+	cfg.Net.MaxPeers = 50
+	cfg.Net.Whitelist = viper.GetStringSlice("network.whitelist")
+	cfg.Net.PersistentPeers = viper.GetStringSlice("network.persistent_peers")
+	cfg.Net.Features = viper.GetStringSlice("network.features")
+	cfg.Net.InventoryRelay = viper.GetBool("network.inventory_relay")
+	cfg.Net.MaxUploadRatePerPeer = viper.GetInt("network.max_upload_rate_per_peer")
+	cfg.Net.MaxDownloadPeers = viper.GetInt("network.max_download_peers")
+	cfg.Net.MaxBlockMessageSize = viper.GetInt("network.max_block_message_size")
+	cfg.Net.MaxTxMessageSize = viper.GetInt("network.max_tx_message_size")
+	if cfg.Net.MaxTxMessageSize == 0 {
+		cfg.Net.MaxTxMessageSize = netpkg.TxMessageSize(cfg.Mempool.RelayPolicy())
+	}
+	cfg.Net.PeerExchange = viper.GetBool("network.peer_exchange")
+	cfg.Net.PeerExchangeInterval = viper.GetDuration("network.peer_exchange_interval")
+	if viper.IsSet("network.required_security") {
+		cfg.Net.RequiredSecurity = viper.GetString("network.required_security")
+	}
+
+	return cfg
+}
+
+// Validate checks the node-level settings and every component configuration,
+// returning all problems found so they can be fixed in one pass.
+func (c *nodeConfig) Validate() error {
+	var errs []error
+
+	if !containsString(supportedNetworks, c.Network) {
+		errs = append(errs, fmt.Errorf("node: unknown network %q (expected one of %v)", c.Network, supportedNetworks))
+	}
+	switch c.StorageType {
+	case storage.StorageTypeFile, storage.StorageTypeLevelDB:
+	default:
+		errs = append(errs, fmt.Errorf("storage: unsupported db type %q", c.StorageType))
+	}
+	if minSize := netpkg.TxMessageSize(c.Mempool.RelayPolicy()); c.Net.MaxTxMessageSize > 0 && c.Net.MaxTxMessageSize < minSize {
+		errs = append(errs, fmt.Errorf("node: max transaction message size %d is below the %d bytes the relay policy needs", c.Net.MaxTxMessageSize, minSize))
+	}
+	if c.Chain.MaxBlockSize != c.Miner.MaxBlockSize {
+		errs = append(errs, fmt.Errorf("node: miner max block size %d differs from chain max block size %d", c.Miner.MaxBlockSize, c.Chain.MaxBlockSize))
+	}
+
+	errs = append(errs,
+		c.LevelDB.Validate(),
+		c.Chain.Validate(),
+		c.Consensus.Validate(),
+		c.Mempool.Validate(),
+		c.Miner.Validate(),
+		c.Net.Validate(),
+	)
+	return errors.Join(errs...)
+}
+
+// containsString reports whether values contains s.
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+//go:build go1.20
+
+package main
+
+import (
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/consensus"
+	netpkg "github.com/palaseus/adrenochain/pkg/net"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildNodeConfigDefaultsAreValid(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	network, port, mining = "testnet", 0, false
+
+	cfg := buildNodeConfig()
+	assert.Equal(t, "./data", cfg.DataDir)
+	assert.Equal(t, netpkg.TxMessageSize(cfg.Mempool.RelayPolicy()), cfg.Net.MaxTxMessageSize)
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestBuildNodeConfigNetworkPresets(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	originalNetwork := network
+	defer func() { network = originalNetwork }()
+
+	network = "devnet"
+	cfg := buildNodeConfig()
+	assert.Equal(t, consensus.DevnetConsensusConfig().GenesisDifficulty, cfg.Consensus.GenesisDifficulty)
+	assert.Equal(t, consensus.DevnetConsensusConfig().TargetBlockTime, cfg.Consensus.TargetBlockTime)
+	assert.Equal(t, cfg.Consensus.TargetBlockTime, cfg.Miner.BlockTime, "the miner follows the target block time")
+	assert.NoError(t, cfg.Validate())
+
+	network = "mainnet"
+	cfg = buildNodeConfig()
+	assert.Equal(t, consensus.DefaultConsensusConfig().GenesisDifficulty, cfg.Consensus.GenesisDifficulty, "mainnet keeps the default genesis")
+
+	// The configuration file overrides the preset
+	viper.Set("blockchain.genesis_difficulty", 4)
+	cfg = buildNodeConfig()
+	assert.Equal(t, uint64(4), cfg.Consensus.GenesisDifficulty)
+}
+
+func TestNodeConfigValidate(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	originalNetwork, originalPort := network, port
+	defer func() { network, port = originalNetwork, originalPort }()
+
+	network = "moonnet"
+	port = -1
+	viper.Set("storage.db_type", "sqlite")
+	viper.Set("blockchain.min_difficulty", 0)
+	viper.Set("network.required_security", "/plaintext/2.0.0")
+	viper.Set("network.max_tx_message_size", 128*1024)
+
+	cfg := buildNodeConfig()
+	cfg.Miner.MaxBlockSize = cfg.Chain.MaxBlockSize / 2
+	err := cfg.Validate()
+	require.Error(t, err)
+
+	msg := err.Error()
+	assert.Contains(t, msg, `unknown network "moonnet"`)
+	assert.Contains(t, msg, `unsupported db type "sqlite"`)
+	assert.Contains(t, msg, "miner max block size 500000 differs from chain max block size 1000000")
+	assert.Contains(t, msg, "consensus: min difficulty must be positive")
+	assert.Contains(t, msg, "network: listen port -1 is outside 0-65535")
+	assert.Contains(t, msg, "unsupported security protocol")
+	assert.Contains(t, msg, "max transaction message size 131072 is below the 702560 bytes the relay policy needs")
+}
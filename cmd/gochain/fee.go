@@ -0,0 +1,68 @@
+//go:build go1.20
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// nodeFeeEstimatorTimeout bounds each fee estimate request to the node.
+const nodeFeeEstimatorTimeout = 10 * time.Second
+
+// nodeFeeEstimator prices fee levels for wallet commands, which have no
+// mempool of their own, with the fee estimate endpoint of a running node's
+// API. The wallet's FeeEstimator interface has no room for errors, so the
+// last failed request is kept in err to be reported.
+type nodeFeeEstimator struct {
+	url    string
+	client *http.Client
+	err    error
+}
+
+// newNodeFeeEstimator creates a fee estimator asking the node whose API is
+// served at nodeURL.
+func newNodeFeeEstimator(nodeURL string) *nodeFeeEstimator {
+	if !strings.Contains(nodeURL, "://") {
+		nodeURL = "http://" + nodeURL
+	}
+	return &nodeFeeEstimator{
+		url:    strings.TrimSuffix(nodeURL, "/"),
+		client: &http.Client{Timeout: nodeFeeEstimatorTimeout},
+	}
+}
+
+// EstimateFeeRate implements wallet.FeeEstimator. A failed request counts as
+// having no estimate.
+func (e *nodeFeeEstimator) EstimateFeeRate(blocks uint64) (uint64, bool) {
+	feeRate, ok, err := e.estimate(blocks)
+	if err != nil {
+		e.err = err
+		return 0, false
+	}
+	return feeRate, ok
+}
+
+// estimate asks the node for the fee rate to confirm within blocks.
+func (e *nodeFeeEstimator) estimate(blocks uint64) (uint64, bool, error) {
+	resp, err := e.client.Get(fmt.Sprintf("%s/api/v1/fee/estimate?blocks=%d", e.url, blocks))
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get fee estimate from node %s: %w", e.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, false, fmt.Errorf("failed to get fee estimate from node %s: %s", e.url, resp.Status)
+	}
+
+	var estimate struct {
+		FeeRate  uint64 `json:"fee_rate"`
+		Fallback bool   `json:"fallback"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&estimate); err != nil {
+		return 0, false, fmt.Errorf("failed to decode fee estimate from node %s: %w", e.url, err)
+	}
+	return estimate.FeeRate, !estimate.Fallback, nil
+}
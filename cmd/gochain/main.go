@@ -5,9 +5,12 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
@@ -16,7 +19,6 @@ import (
 	"github.com/palaseus/adrenochain/pkg/api"
 	"github.com/palaseus/adrenochain/pkg/block"
 	"github.com/palaseus/adrenochain/pkg/chain"
-	"github.com/palaseus/adrenochain/pkg/consensus"
 	"github.com/palaseus/adrenochain/pkg/logger"
 	"github.com/palaseus/adrenochain/pkg/mempool"
 	"github.com/palaseus/adrenochain/pkg/miner"
@@ -27,6 +29,7 @@ import (
 	"github.com/palaseus/adrenochain/pkg/utxo"
 	"github.com/palaseus/adrenochain/pkg/wallet"
 	"github.com/libp2p/go-libp2p/core/peer"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"google.golang.org/protobuf/proto"
@@ -36,6 +39,7 @@ var (
 	configFile string
 	port       int
 	mining     bool
+	readOnly   bool
 	network    string
 	walletFile string // New global flag
 	passphrase string // New global flag
@@ -54,6 +58,7 @@ and wallet functionality.`,
 	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "config file (default is ./config.yaml)")
 	rootCmd.PersistentFlags().IntVar(&port, "port", 0, "network port (0 for random)")
 	rootCmd.PersistentFlags().BoolVar(&mining, "mining", false, "enable mining")
+	rootCmd.PersistentFlags().BoolVar(&readOnly, "read-only", false, "sync and serve data without mining or relaying transactions")
 	rootCmd.PersistentFlags().StringVar(&network, "network", "mainnet", "network type (mainnet, testnet, devnet)")
 	rootCmd.PersistentFlags().StringVar(&walletFile, "wallet-file", "wallet.dat", "path to wallet file")   // New flag
 	rootCmd.PersistentFlags().StringVar(&passphrase, "passphrase", "", "passphrase for wallet encryption") // New flag
@@ -82,73 +87,116 @@ func runNode(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Port: %d\n", port)
 	fmt.Printf("Mining: %t\n", mining)
 
-	// Create blockchain components
-	storageFactory := storage.NewStorageFactory()
-
-	// Determine storage type from config or use default
-	storageType := storage.StorageTypeFile // Default to file storage
-	configDBType := viper.GetString("storage.db_type")
-
-	if configDBType == "leveldb" {
-		storageType = storage.StorageTypeLevelDB
-	}
-
-	dataDir := viper.GetString("storage.data_dir")
-	if dataDir == "" {
-		dataDir = "./data"
+	// Build and validate the configuration before creating any component
+	cfg := buildNodeConfig()
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
 	}
 
-	nodeStorage, err := storageFactory.CreateStorage(storageType, dataDir)
+	// Create blockchain components
+	storageFactory := storage.NewStorageFactory().WithLevelDBConfig(cfg.LevelDB)
+	nodeStorage, err := storageFactory.CreateStorage(cfg.StorageType, cfg.DataDir)
 	if err != nil {
 		return fmt.Errorf("failed to create storage: %w", err)
 	}
 	defer nodeStorage.Close()
 
-	chainConfig := chain.DefaultChainConfig()
-	consensusConfig := consensus.DefaultConsensusConfig()
-	chain, err := chain.NewChain(chainConfig, consensusConfig, nodeStorage)
+	chain, err := chain.NewChain(cfg.Chain, cfg.Consensus, nodeStorage)
 	if err != nil {
 		return fmt.Errorf("failed to create chain: %w", err)
 	}
 
-	mempoolConfig := mempool.DefaultMempoolConfig()
-	mempool := mempool.NewMempool(mempoolConfig)
+	mempool := mempool.NewMempool(cfg.Mempool)
+	mempool.SetUTXOSet(chain.UTXOSet)
+	mempool.SetBlockTimeSource(chain.BlockTime)
+	mempool.SetChainHeight(chain.GetHeight())
+	trackChainInMempool(chain, mempool)
 
-	minerConfig := miner.DefaultMinerConfig()
-	minerConfig.MiningEnabled = mining
-	minerConfig.CoinbaseAddress = "miner_reward"
-	miner := miner.NewMiner(chain, mempool, minerConfig, consensusConfig)
+	miner := miner.NewMiner(chain, mempool, cfg.Miner, cfg.Consensus)
 
-	networkConfig := netpkg.DefaultNetworkConfig()
-	networkConfig.ListenPort = port
-	networkConfig.EnableMDNS = true
-	networkConfig.MaxPeers = 50
+	networkConfig := cfg.Net
+	networkConfig.NetworkMagic = chain.NetworkMagic()
+	fmt.Printf("Network magic: %08x\n", networkConfig.NetworkMagic)
+	if cfg.PersistAddrBook {
+		networkConfig.AddrBookStore = nodeStorage
+	}
 
 	net, err := netpkg.NewNetwork(networkConfig, chain, mempool)
 	if err != nil {
 		return fmt.Errorf("failed to create network: %w", err)
 	}
+	chain.GetConsensus().SetTimeSource(net.AdjustedTime)
 
 	// Set up logging
 	logger := setupLogger()
 
+	mempoolFile := filepath.Join(cfg.DataDir, "mempool.json")
+	if cfg.PersistMempool {
+		restoreMempool(mempool, mempoolFile, logger)
+	}
+
+	// Set up the gRPC API; it is started together with the HTTP API below but
+	// created early so new blocks can be pushed to its subscribers
+	var grpcServer *api.GRPCServer
+	if viper.GetBool("api.grpc_enabled") {
+		grpcServer = api.NewGRPCServer(&api.GRPCServerConfig{
+			ListenAddr: viper.GetString("api.grpc_listen_addr"),
+			Chain:      chain,
+			Mempool:    mempool,
+			UTXOSet:    chain.UTXOSet,
+
+			AuthToken: viper.GetString("api.grpc_auth_token"),
+			ReadOnly:  cfg.ReadOnly,
+		})
+	}
+
+	// announceMinedBlock publishes a locally mined block to peers, recording
+	// when it was mined so that peer acknowledgements measure propagation
+	announceMinedBlock := func(minedBlock *block.Block) {
+		blockData, err := json.Marshal(minedBlock)
+		if err != nil {
+			logger.Error("Failed to encode mined block: %v", err)
+			return
+		}
+		net.MarkBlockMined(minedBlock.CalculateHash())
+		if networkConfig.InventoryRelay {
+			err = net.AnnounceInventory(proto_net.InvType_INV_BLOCK, minedBlock.CalculateHash(), blockData)
+		} else {
+			err = net.PublishBlock(blockData)
+		}
+		if err != nil {
+			logger.Error("Failed to publish mined block: %v", err)
+		}
+	}
+
 	// Set up monitoring service
 	var monitoringService *monitoring.Service
 	if viper.GetBool("monitoring.enabled") {
 		monitoringConfig := createMonitoringConfig()
 		monitoringService = monitoring.NewService(monitoringConfig, chain, mempool, net)
+		monitoringService.SetMiner(miner)
 
 		// Start monitoring service
 		if err := monitoringService.Start(); err != nil {
-			logger.Error("Failed to start monitoring service: %v", err)
-		} else {
-			logger.Info("Monitoring service started")
+			return fmt.Errorf("failed to start monitoring service: %w", err)
+		}
+		logger.Info("Monitoring service started")
+		live := make(map[string]bool)
+		for _, endpoint := range monitoringService.Endpoints() {
+			live[endpoint.Name] = endpoint.Live
+			if !endpoint.Live {
+				logger.Warn("Monitoring %s endpoint on %s is not available: %s", endpoint.Name, endpoint.Address, endpoint.Error)
+			}
+		}
+		if live["metrics"] {
 			logger.Info("Metrics endpoint: %s", monitoringService.GetMetricsEndpoint())
-			logger.Info("Health endpoint: %s", monitoringService.GetHealthEndpoint())
 			if prometheusEndpoint := monitoringService.GetPrometheusEndpoint(); prometheusEndpoint != "" {
 				logger.Info("Prometheus endpoint: %s", prometheusEndpoint)
 			}
 		}
+		if live["health"] {
+			logger.Info("Health endpoint: %s", monitoringService.GetHealthEndpoint())
+		}
 
 		// Set up mining callback for monitoring
 		miner.SetOnBlockMined(func(minedBlock *block.Block) {
@@ -174,6 +222,18 @@ func runNode(cmd *cobra.Command, args []string) error {
 			// Log the successful mining
 			logger.Info("Block successfully mined and added to chain: Height=%d, Hash=%x, Transactions=%d",
 				minedBlock.Header.Height, minedBlock.CalculateHash(), txnCount)
+
+			announceMinedBlock(minedBlock)
+			if grpcServer != nil {
+				grpcServer.PublishBlock(minedBlock)
+			}
+		})
+	} else {
+		miner.SetOnBlockMined(func(minedBlock *block.Block) {
+			announceMinedBlock(minedBlock)
+			if grpcServer != nil {
+				grpcServer.PublishBlock(minedBlock)
+			}
 		})
 	}
 
@@ -189,7 +249,209 @@ func runNode(cmd *cobra.Command, args []string) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Enhanced block processing with better monitoring integration
+	// Buffered chain state updates are written while no blocks arrive
+	chain.StartStateFlush(ctx)
+
+	// Old block bodies are deleted once they can no longer be reorganized
+	chain.StartPruning(ctx)
+
+	// Periodically verify the UTXO set as a safety net against bookkeeping
+	// bugs; the chain logs inconsistencies itself
+	chain.StartUTXOAudit(ctx, func(err error) {
+		if monitoringService != nil {
+			monitoringService.GetMetrics().IncrementUTXOAuditFailures()
+		}
+	})
+
+	// Transactions submitted through the API are sent to peers after a
+	// random diffusion delay, if configured, and resent until they confirm,
+	// in case they were dropped on the way
+	publishTx := func(tx *block.Transaction) error {
+		txData, err := json.Marshal(tx)
+		if err != nil {
+			return fmt.Errorf("failed to encode transaction: %w", err)
+		}
+		if networkConfig.InventoryRelay {
+			return net.AnnounceInventory(proto_net.InvType_INV_TX, tx.Hash, txData)
+		}
+		return net.PublishTransaction(txData)
+	}
+	mempool.StartDiffusion(ctx, publishTx)
+	mempool.StartRebroadcast(ctx, publishTx)
+
+	// Peers are told why their blocks and transactions were rejected
+	sendReject := func(to peer.ID, messageType string, hash []byte, reason error) {
+		go func() {
+			if err := net.SendReject(to, messageType, hash, reason); err != nil {
+				logger.Debug("Failed to send %s reject to %s: %v", messageType, to, err)
+			}
+		}()
+	}
+
+	// Blocks and transactions announced by hash are only fetched when
+	// missing here; accepted items are announced on to our own peers
+	net.SetInventoryLookup(func(invType proto_net.InvType, hash []byte) bool {
+		if invType == proto_net.InvType_INV_BLOCK {
+			return chain.GetBlock(hash) != nil
+		}
+		return mempool.GetTransaction(hash) != nil
+	})
+	net.SetInventoryHandler(func(from peer.ID, invType proto_net.InvType, data []byte) error {
+		switch invType {
+		case proto_net.InvType_INV_BLOCK:
+			var block block.Block
+			if err := json.Unmarshal(data, &block); err != nil {
+				return fmt.Errorf("%w: %v", netpkg.ErrMalformed, err)
+			}
+			logger.Info("Fetched announced block: %s", block.String())
+			if err := chain.AddBlock(&block); err != nil {
+				sendReject(from, netpkg.RejectTypeBlock, block.CalculateHash(), err)
+				return err
+			}
+			if grpcServer != nil {
+				grpcServer.PublishBlock(&block)
+			}
+			return net.AnnounceInventory(invType, block.CalculateHash(), data)
+		case proto_net.InvType_INV_TX:
+			if cfg.ReadOnly {
+				return api.ErrReadOnly
+			}
+			var tx block.Transaction
+			if err := json.Unmarshal(data, &tx); err != nil {
+				return fmt.Errorf("%w: %v", netpkg.ErrMalformed, err)
+			}
+			if err := net.AcceptTransaction(from, &tx); err != nil {
+				sendReject(from, netpkg.RejectTypeTx, tx.Hash, err)
+				return err
+			}
+			return net.AnnounceInventory(invType, tx.Hash, data)
+		default:
+			return fmt.Errorf("unknown inventory type %s", invType)
+		}
+	})
+
+	// Received blocks are handed to a single processing goroutine through a
+	// bounded queue; blocks arriving while it is full are dropped
+	blockProcessor := netpkg.NewMessageProcessor(networkConfig.BlockQueueSize, func(msg *pubsub.Message) {
+		payload, err := net.OpenMessage(msg.Data)
+		if err != nil {
+			logger.Error("Dropping block message: %v", err)
+			if monitoringService != nil {
+				monitoringService.GetMetrics().IncrementValidationErrors()
+			}
+			return
+		}
+
+		var networkMsg proto_net.Message
+		if err := proto.Unmarshal(payload, &networkMsg); err != nil {
+			logger.Error("Failed to unmarshal network message for block: %v", err)
+			if monitoringService != nil {
+				monitoringService.GetMetrics().IncrementValidationErrors()
+			}
+			return
+		}
+
+		// Verify message signature
+		pubKey, err := peer.ID(networkMsg.FromPeerId).ExtractPublicKey()
+		if err != nil {
+			logger.Error("Error extracting public key for block message: %v", err)
+			if monitoringService != nil {
+				monitoringService.GetMetrics().IncrementValidationErrors()
+			}
+			return
+		}
+		tempMsg := proto.Clone(&networkMsg).(*proto_net.Message)
+		tempMsg.Signature = nil // Clear the signature for verification
+		dataToVerify, err := proto.Marshal(tempMsg)
+		if err != nil {
+			logger.Error("Error marshaling block message for verification: %v", err)
+			if monitoringService != nil {
+				monitoringService.GetMetrics().IncrementValidationErrors()
+			}
+			return
+		}
+		verified, err := pubKey.Verify(dataToVerify, networkMsg.Signature)
+		if err != nil {
+			logger.Error("Error verifying block message signature: %v", err)
+			if monitoringService != nil {
+				monitoringService.GetMetrics().IncrementValidationErrors()
+			}
+			return
+		}
+		if !verified {
+			logger.Error("Invalid block message signature from %s", peer.ID(networkMsg.FromPeerId).String())
+			if monitoringService != nil {
+				monitoringService.GetMetrics().IncrementValidationErrors()
+			}
+			return
+		}
+
+		// Handle block message content
+		switch content := networkMsg.Content.(type) {
+		case *proto_net.Message_BlockMessage:
+			var block block.Block
+			if err := json.Unmarshal(content.BlockMessage.BlockData, &block); err != nil {
+				logger.Error("Failed to unmarshal block from payload: %v", err)
+				if monitoringService != nil {
+					monitoringService.GetMetrics().IncrementValidationErrors()
+				}
+				sendReject(peer.ID(networkMsg.FromPeerId), netpkg.RejectTypeBlock, nil, fmt.Errorf("%w: %v", netpkg.ErrMalformed, err))
+				return
+			}
+
+			// Record block processing start time for metrics
+			startTime := time.Now()
+
+			logger.Info("Received block from network: %s", block.String())
+			if err := chain.AddBlock(&block); err != nil {
+				logger.Error("Failed to add received block: %v", err)
+				if monitoringService != nil {
+					monitoringService.GetMetrics().IncrementRejectedBlocks()
+					monitoringService.GetMetrics().IncrementErrors()
+				}
+				sendReject(peer.ID(networkMsg.FromPeerId), netpkg.RejectTypeBlock, block.CalculateHash(), err)
+			} else {
+				if grpcServer != nil {
+					grpcServer.PublishBlock(&block)
+				}
+				go func(origin peer.ID, hash []byte) {
+					if err := net.AckBlock(origin, hash); err != nil {
+						logger.Debug("Failed to acknowledge block %x: %v", hash, err)
+					}
+				}(peer.ID(networkMsg.FromPeerId), block.CalculateHash())
+				if monitoringService != nil {
+					monitoringService.GetMetrics().UpdateTotalBlocks(int64(chain.GetHeight() + 1))
+					monitoringService.GetMetrics().UpdateBlockHeight(int64(chain.GetHeight()))
+					monitoringService.GetMetrics().UpdateLastBlockTime(block.Header.Timestamp)
+
+					// Update block processing time
+					processingTime := time.Since(startTime)
+					monitoringService.GetMetrics().UpdateBlockProcessingTime(processingTime)
+
+					// Update transaction metrics
+					txnCount := len(block.Transactions)
+					if txnCount > 0 {
+						monitoringService.GetMetrics().UpdateTotalTxns(int64(txnCount))
+						monitoringService.GetMetrics().UpdateAvgTxnPerBlock(float64(txnCount))
+					}
+
+					// Update block size metrics (rough estimate)
+					blockSize := int64(len(block.Transactions) * 256) // Rough estimate
+					monitoringService.GetMetrics().UpdateAvgBlockSize(blockSize)
+				}
+			}
+		default:
+			logger.Error("Received unknown message type for block subscription: %T", content)
+			if monitoringService != nil {
+				monitoringService.GetMetrics().IncrementValidationErrors()
+			}
+		}
+	})
+	if monitoringService != nil {
+		blockProcessor.SetOnDrop(monitoringService.GetMetrics().IncrementDroppedBlocks)
+	}
+	blockProcessor.Start(ctx)
+
 	go func() {
 		for {
 			select {
@@ -207,227 +469,157 @@ func runNode(cmd *cobra.Command, args []string) error {
 					}
 					continue
 				}
-
-				var networkMsg proto_net.Message
-				if err := proto.Unmarshal(msg.Data, &networkMsg); err != nil {
-					logger.Error("Failed to unmarshal network message for block: %v", err)
-					if monitoringService != nil {
-						monitoringService.GetMetrics().IncrementValidationErrors()
-					}
-					continue
+				if msg.ReceivedFrom == net.GetHost().ID() {
+					continue // Our own mined block, already on the chain
 				}
-
-				// Verify message signature
-				pubKey, err := peer.ID(networkMsg.FromPeerId).ExtractPublicKey()
-				if err != nil {
-					logger.Error("Error extracting public key for block message: %v", err)
-					if monitoringService != nil {
-						monitoringService.GetMetrics().IncrementValidationErrors()
-					}
+				if !net.AllowAnnouncement("blocks", msg.ReceivedFrom) {
 					continue
 				}
-				tempMsg := proto.Clone(&networkMsg).(*proto_net.Message)
-				tempMsg.Signature = nil // Clear the signature for verification
-				dataToVerify, err := proto.Marshal(tempMsg)
-				if err != nil {
-					logger.Error("Error marshaling block message for verification: %v", err)
-					if monitoringService != nil {
-						monitoringService.GetMetrics().IncrementValidationErrors()
-					}
-					continue
+				if !blockProcessor.Submit(msg) {
+					logger.Debug("Block processing queue full, dropping block from %s", msg.ReceivedFrom)
 				}
-				verified, err := pubKey.Verify(dataToVerify, networkMsg.Signature)
-				if err != nil {
-					logger.Error("Error verifying block message signature: %v", err)
-					if monitoringService != nil {
-						monitoringService.GetMetrics().IncrementValidationErrors()
+			}
+		}
+	}()
+
+	// Read-only nodes stay out of transaction relay entirely
+	if !cfg.ReadOnly {
+		txSub, err := net.SubscribeToTransactions()
+		if err != nil {
+			logger.Error("Failed to subscribe to transactions: %v", err)
+			return fmt.Errorf("failed to subscribe to transactions: %w", err)
+		}
+		defer txSub.Cancel() // Ensure subscription is cancelled on shutdown
+
+		// Enhanced transaction processing with better monitoring integration
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+					msg, err := txSub.Next(net.GetContext())
+					if err != nil {
+						if err == context.Canceled {
+							return
+						}
+						logger.Error("Error receiving transaction: %v", err)
+						if monitoringService != nil {
+							monitoringService.GetMetrics().IncrementNetworkErrors()
+						}
+						continue
 					}
-					continue
-				}
-				if !verified {
-					logger.Error("Invalid block message signature from %s", peer.ID(networkMsg.FromPeerId).String())
-					if monitoringService != nil {
-						monitoringService.GetMetrics().IncrementValidationErrors()
+					if !net.AllowAnnouncement("transactions", msg.ReceivedFrom) {
+						continue
 					}
-					continue
-				}
 
-				// Handle block message content
-				switch content := networkMsg.Content.(type) {
-				case *proto_net.Message_BlockMessage:
-					var block block.Block
-					if err := json.Unmarshal(content.BlockMessage.BlockData, &block); err != nil {
-						logger.Error("Failed to unmarshal block from payload: %v", err)
+					payload, err := net.OpenMessage(msg.Data)
+					if err != nil {
+						logger.Error("Dropping transaction message: %v", err)
 						if monitoringService != nil {
 							monitoringService.GetMetrics().IncrementValidationErrors()
 						}
 						continue
 					}
 
-					// Record block processing start time for metrics
-					startTime := time.Now()
-
-					logger.Info("Received block from network: %s", block.String())
-					if err := chain.AddBlock(&block); err != nil {
-						logger.Error("Failed to add received block: %v", err)
+					var networkMsg proto_net.Message
+					if err := proto.Unmarshal(payload, &networkMsg); err != nil {
+						logger.Error("Failed to unmarshal network message for transaction: %v", err)
 						if monitoringService != nil {
-							monitoringService.GetMetrics().IncrementRejectedBlocks()
-							monitoringService.GetMetrics().IncrementErrors()
-						}
-					} else {
-						if monitoringService != nil {
-							monitoringService.GetMetrics().UpdateTotalBlocks(int64(chain.GetHeight() + 1))
-							monitoringService.GetMetrics().UpdateBlockHeight(int64(chain.GetHeight()))
-							monitoringService.GetMetrics().UpdateLastBlockTime(block.Header.Timestamp)
-
-							// Update block processing time
-							processingTime := time.Since(startTime)
-							monitoringService.GetMetrics().UpdateBlockProcessingTime(processingTime)
-
-							// Update transaction metrics
-							txnCount := len(block.Transactions)
-							if txnCount > 0 {
-								monitoringService.GetMetrics().UpdateTotalTxns(int64(txnCount))
-								monitoringService.GetMetrics().UpdateAvgTxnPerBlock(float64(txnCount))
-							}
-
-							// Update block size metrics (rough estimate)
-							blockSize := int64(len(block.Transactions) * 256) // Rough estimate
-							monitoringService.GetMetrics().UpdateAvgBlockSize(blockSize)
+							monitoringService.GetMetrics().IncrementValidationErrors()
 						}
+						continue
 					}
-				default:
-					logger.Error("Received unknown message type for block subscription: %T", content)
-					if monitoringService != nil {
-						monitoringService.GetMetrics().IncrementValidationErrors()
-					}
-					continue
-				}
-			}
-		}
-	}()
-
-	txSub, err := net.SubscribeToTransactions()
-	if err != nil {
-		logger.Error("Failed to subscribe to transactions: %v", err)
-		return fmt.Errorf("failed to subscribe to transactions: %w", err)
-	}
-	defer txSub.Cancel() // Ensure subscription is cancelled on shutdown
-
-	// Enhanced transaction processing with better monitoring integration
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				msg, err := txSub.Next(net.GetContext())
-				if err != nil {
-					if err == context.Canceled {
-						return
-					}
-					logger.Error("Error receiving transaction: %v", err)
-					if monitoringService != nil {
-						monitoringService.GetMetrics().IncrementNetworkErrors()
-					}
-					continue
-				}
-
-				var networkMsg proto_net.Message
-				if err := proto.Unmarshal(msg.Data, &networkMsg); err != nil {
-					logger.Error("Failed to unmarshal network message for transaction: %v", err)
-					if monitoringService != nil {
-						monitoringService.GetMetrics().IncrementValidationErrors()
-					}
-					continue
-				}
 
-				// Verify message signature
-				pubKey, err := peer.ID(networkMsg.FromPeerId).ExtractPublicKey()
-				if err != nil {
-					logger.Error("Error extracting public key for transaction message: %v", err)
-					if monitoringService != nil {
-						monitoringService.GetMetrics().IncrementValidationErrors()
-					}
-					continue
-				}
-				tempMsg := proto.Clone(&networkMsg).(*proto_net.Message)
-				tempMsg.Signature = nil // Clear the signature for verification
-				dataToVerify, err := proto.Marshal(tempMsg)
-				if err != nil {
-					logger.Error("Error marshaling transaction message for verification: %v", err)
-					if monitoringService != nil {
-						monitoringService.GetMetrics().IncrementValidationErrors()
-					}
-					continue
-				}
-				verified, err := pubKey.Verify(dataToVerify, networkMsg.Signature)
-				if err != nil {
-					logger.Error("Error verifying transaction message signature: %v", err)
-					if monitoringService != nil {
-						monitoringService.GetMetrics().IncrementValidationErrors()
+					// Verify message signature
+					pubKey, err := peer.ID(networkMsg.FromPeerId).ExtractPublicKey()
+					if err != nil {
+						logger.Error("Error extracting public key for transaction message: %v", err)
+						if monitoringService != nil {
+							monitoringService.GetMetrics().IncrementValidationErrors()
+						}
+						continue
 					}
-					continue
-				}
-				if !verified {
-					senderPeerID, err := peer.IDFromBytes(networkMsg.FromPeerId)
+					tempMsg := proto.Clone(&networkMsg).(*proto_net.Message)
+					tempMsg.Signature = nil // Clear the signature for verification
+					dataToVerify, err := proto.Marshal(tempMsg)
 					if err != nil {
-						logger.Error("Failed to get peer ID from bytes: %v", err)
+						logger.Error("Error marshaling transaction message for verification: %v", err)
 						if monitoringService != nil {
 							monitoringService.GetMetrics().IncrementValidationErrors()
 						}
 						continue
 					}
-					logger.Error("Invalid transaction message signature from %s", senderPeerID.String())
-					if monitoringService != nil {
-						monitoringService.GetMetrics().IncrementValidationErrors()
+					verified, err := pubKey.Verify(dataToVerify, networkMsg.Signature)
+					if err != nil {
+						logger.Error("Error verifying transaction message signature: %v", err)
+						if monitoringService != nil {
+							monitoringService.GetMetrics().IncrementValidationErrors()
+						}
+						continue
 					}
-					continue
-				}
-
-				// Handle transaction message content
-				switch content := networkMsg.Content.(type) {
-				case *proto_net.Message_TransactionMessage:
-					var tx block.Transaction
-					if err := json.Unmarshal(content.TransactionMessage.TransactionData, &tx); err != nil {
-						logger.Error("Failed to unmarshal transaction from payload: %v", err)
+					if !verified {
+						senderPeerID, err := peer.IDFromBytes(networkMsg.FromPeerId)
+						if err != nil {
+							logger.Error("Failed to get peer ID from bytes: %v", err)
+							if monitoringService != nil {
+								monitoringService.GetMetrics().IncrementValidationErrors()
+							}
+							continue
+						}
+						logger.Error("Invalid transaction message signature from %s", senderPeerID.String())
 						if monitoringService != nil {
 							monitoringService.GetMetrics().IncrementValidationErrors()
 						}
 						continue
 					}
 
-					// Record transaction processing start time for metrics
-					startTime := time.Now()
+					// Handle transaction message content
+					switch content := networkMsg.Content.(type) {
+					case *proto_net.Message_TransactionMessage:
+						var tx block.Transaction
+						if err := json.Unmarshal(content.TransactionMessage.TransactionData, &tx); err != nil {
+							logger.Error("Failed to unmarshal transaction from payload: %v", err)
+							if monitoringService != nil {
+								monitoringService.GetMetrics().IncrementValidationErrors()
+							}
+							sendReject(peer.ID(networkMsg.FromPeerId), netpkg.RejectTypeTx, nil, fmt.Errorf("%w: %v", netpkg.ErrMalformed, err))
+							continue
+						}
+
+						// Record transaction processing start time for metrics
+						startTime := time.Now()
 
-					logger.Info("Received transaction from network: %s", tx.String())
-					if err := mempool.AddTransaction(&tx); err != nil {
-						logger.Error("Failed to add received transaction: %v", err)
-						if monitoringService != nil {
-							monitoringService.GetMetrics().IncrementRejectedTxns()
-							monitoringService.GetMetrics().IncrementErrors()
+						logger.Info("Received transaction from network: %s", tx.String())
+						if err := net.AcceptTransaction(msg.ReceivedFrom, &tx); err != nil {
+							logger.Error("Failed to add received transaction: %v", err)
+							if monitoringService != nil {
+								monitoringService.GetMetrics().IncrementRejectedTxns()
+								monitoringService.GetMetrics().IncrementErrors()
+							}
+							sendReject(peer.ID(networkMsg.FromPeerId), netpkg.RejectTypeTx, tx.Hash, err)
+						} else {
+							if monitoringService != nil {
+								// Update transaction metrics
+								monitoringService.GetMetrics().UpdateTotalTxns(int64(mempool.GetTransactionCount()))
+								monitoringService.GetMetrics().UpdatePendingTxns(int64(mempool.GetTransactionCount()))
+
+								// Update transaction processing time
+								processingTime := time.Since(startTime)
+								monitoringService.GetMetrics().UpdateTxnProcessingTime(processingTime)
+							}
 						}
-					} else {
+					default:
+						logger.Error("Received unknown message type for transaction subscription: %T", content)
 						if monitoringService != nil {
-							// Update transaction metrics
-							monitoringService.GetMetrics().UpdateTotalTxns(int64(mempool.GetTransactionCount()))
-							monitoringService.GetMetrics().UpdatePendingTxns(int64(mempool.GetTransactionCount()))
-
-							// Update transaction processing time
-							processingTime := time.Since(startTime)
-							monitoringService.GetMetrics().UpdateTxnProcessingTime(processingTime)
+							monitoringService.GetMetrics().IncrementValidationErrors()
 						}
+						continue
 					}
-				default:
-					logger.Error("Received unknown message type for transaction subscription: %T", content)
-					if monitoringService != nil {
-						monitoringService.GetMetrics().IncrementValidationErrors()
-					}
-					continue
 				}
 			}
-		}
-	}()
+		}()
+	}
 
 	// Start mining if enabled
 	if mining {
@@ -435,6 +627,9 @@ func runNode(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("failed to start mining: %w", err)
 		}
 		logger.Info("Mining started")
+		if cfg.Miner.CoinbaseAddress == "" {
+			logger.Warn("No mining.coinbase_address is configured, block rewards are burned")
+		}
 
 		// Update mining metrics
 		if monitoringService != nil {
@@ -466,16 +661,48 @@ func runNode(cmd *cobra.Command, args []string) error {
 			}
 		}
 
-		// Create a dummy wallet for API (in a real implementation, this would be the actual wallet)
-		dummyWallet := &wallet.Wallet{}
-
 		apiConfig := &api.ServerConfig{
-			Port:   apiPort,
-			Chain:  chain,
-			Wallet: dummyWallet,
+			Port:               apiPort,
+			Chain:              chain,
+			Miner:              miner,
+			UTXOSet:            chain.UTXOSet,
+			ResponseCacheSize:  viper.GetInt("api.response_cache_size"),
+			CacheConfirmations: viper.GetUint64("api.cache_confirmations"),
+
+			MaxConcurrentConnections: viper.GetInt("api.max_concurrent_connections"),
+			MaxBlockPageSize:         viper.GetInt("api.max_block_page_size"),
+			MaxRPCBatchSize:          viper.GetInt("api.max_rpc_batch_size"),
+			HeavyRateLimit:           viper.GetInt("api.heavy_rate_limit"),
+			HeavyRateBurst:           viper.GetInt("api.heavy_rate_burst"),
+			RateLimitKeyHeader:       viper.GetString("api.rate_limit_key_header"),
+			ReadOnly:                 cfg.ReadOnly,
+			AdminToken:               viper.GetString("api.admin_token"),
+		}
+
+		// Serve the node's wallet, loaded over the node's UTXO set
+		if !viper.IsSet("api.wallet_enabled") || viper.GetBool("api.wallet_enabled") {
+			walletStorage, err := storage.NewStorage(storage.DefaultStorageConfig().WithDataDir("./wallet_data"))
+			if err != nil {
+				return fmt.Errorf("failed to create wallet storage: %w", err)
+			}
+			defer walletStorage.Close()
+
+			walletConfig := newWalletConfig(cfg)
+			walletConfig.FeeEstimator = mempool
+			nodeWallet, err := loadNodeWallet(walletConfig, chain.UTXOSet, walletStorage, logger)
+			if err != nil {
+				return err
+			}
+			trackChainInWallet(chain, nodeWallet)
+
+			apiConfig.Wallet = nodeWallet
+			apiConfig.WalletEndpoints = true
 		}
 
-		apiServer = api.NewServer(apiConfig)
+		apiServer, err = api.NewServer(apiConfig)
+		if err != nil {
+			return fmt.Errorf("failed to create API server: %w", err)
+		}
 
 		// Start API server in background
 		go func() {
@@ -487,6 +714,16 @@ func runNode(cmd *cobra.Command, args []string) error {
 		logger.Info("API server started on port %d", apiPort)
 	}
 
+	if grpcServer != nil {
+		go func() {
+			if err := grpcServer.Start(); err != nil {
+				logger.Error("gRPC server error: %v", err)
+			}
+		}()
+
+		logger.Info("gRPC server started on %s", grpcServer.ListenAddr())
+	}
+
 	// Start periodic status updates with enhanced monitoring
 	go func() {
 		ticker := time.NewTicker(30 * time.Second)
@@ -549,6 +786,16 @@ func runNode(cmd *cobra.Command, args []string) error {
 	miner.Close()
 	net.Close()
 
+	if err := chain.FlushState(); err != nil {
+		logger.Error("Failed to flush chain state: %v", err)
+	}
+
+	if cfg.PersistMempool {
+		if err := mempool.Save(mempoolFile); err != nil {
+			logger.Error("Failed to save mempool: %v", err)
+		}
+	}
+
 	// Stop monitoring service if it was started
 	if monitoringService != nil {
 		logger.Info("Stopping monitoring service...")
@@ -559,6 +806,11 @@ func runNode(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if grpcServer != nil {
+		grpcServer.Stop()
+		logger.Info("gRPC server stopped")
+	}
+
 	// Close API server if it was started
 	if apiServer != nil {
 		// Note: The API server doesn't have a Close method yet, but we could add one if needed
@@ -603,9 +855,10 @@ func createWalletCmd() *cobra.Command {
 			}
 			defer walletStorage.Close()
 
-			walletConfig := wallet.DefaultWalletConfig()
-			walletConfig.WalletFile = walletFile
-			walletConfig.Passphrase = passphrase
+			if err := loadConfig(); err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			walletConfig := newWalletConfig(buildNodeConfig())
 
 			us := utxo.NewUTXOSet() // Still a dummy UTXOSet for CLI commands
 			wallet, err := wallet.NewWallet(walletConfig, us, walletStorage)
@@ -631,11 +884,23 @@ func createWalletCmd() *cobra.Command {
 func createTransactionCmd() *cobra.Command {
 	var from, to string
 	var amount, fee uint64
+	var allowHighFee bool
+	var priority, nodeAddr string
 
 	cmd := &cobra.Command{
 		Use:   "send",
 		Short: "Send a transaction",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			// With --priority, pay the fee rate of the level instead of a fixed fee
+			useLevel := cmd.Flags().Changed("priority")
+			if useLevel && cmd.Flags().Changed("fee") {
+				return fmt.Errorf("--fee and --priority are mutually exclusive")
+			}
+			level, err := wallet.ParseFeeLevel(priority)
+			if err != nil {
+				return err
+			}
+
 			// Create storage for wallet
 			walletStorageConfig := storage.DefaultStorageConfig().WithDataDir("./wallet_data")
 			walletStorage, err := storage.NewStorage(walletStorageConfig)
@@ -644,18 +909,40 @@ func createTransactionCmd() *cobra.Command {
 			}
 			defer walletStorage.Close()
 
-			walletConfig := wallet.DefaultWalletConfig()
-			walletConfig.WalletFile = walletFile
-			walletConfig.Passphrase = passphrase
+			// Created transactions follow the relay policy of the configured mempool
+			if err := loadConfig(); err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			walletConfig := newWalletConfig(buildNodeConfig())
+			walletConfig.AllowHighFee = allowHighFee
+
+			// Fee levels are priced from the mempool of a running node
+			var estimator *nodeFeeEstimator
+			if useLevel {
+				estimator = newNodeFeeEstimator(nodeAddr)
+				walletConfig.FeeEstimator = estimator
+			}
 
-			us := utxo.NewUTXOSet() // Still a dummy UTXOSet for CLI commands
+			us := newCLIUTXOSet()
 			wallet, err := wallet.NewWallet(walletConfig, us, walletStorage)
 			if err != nil {
 				return fmt.Errorf("failed to load wallet: %w", err)
 			}
+			// Spend from the saved wallet's accounts
+			if err := wallet.Load(); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to load wallet: %w", err)
+			}
 
-			tx, err := wallet.CreateTransaction(from, to, amount, fee)
+			var tx *block.Transaction
+			if useLevel {
+				tx, err = wallet.CreateTransactionWithFeeLevel(from, to, amount, level)
+			} else {
+				tx, err = wallet.CreateTransaction(from, to, amount, fee)
+			}
 			if err != nil {
+				if estimator != nil && estimator.err != nil {
+					return fmt.Errorf("failed to price fee level %q: %w", level, estimator.err)
+				}
 				return fmt.Errorf("failed to create transaction: %w", err)
 			}
 
@@ -669,7 +956,7 @@ func createTransactionCmd() *cobra.Command {
 			fmt.Printf("From: %s\n", from)
 			fmt.Printf("To: %s\n", to)
 			fmt.Printf("Amount: %d\n", amount)
-			fmt.Printf("Fee: %d\n", fee)
+			fmt.Printf("Fee: %d\n", tx.Fee)
 
 			return nil
 		},
@@ -679,6 +966,9 @@ func createTransactionCmd() *cobra.Command {
 	cmd.Flags().StringVar(&to, "to", "", "recipient address")
 	cmd.Flags().Uint64Var(&amount, "amount", 0, "amount to send")
 	cmd.Flags().Uint64Var(&fee, "fee", 0, "transaction fee")
+	cmd.Flags().StringVar(&priority, "priority", string(wallet.FeeNormal), "fee level to pay instead of --fee: economy, normal or priority, priced by the node at --node")
+	cmd.Flags().StringVar(&nodeAddr, "node", defaultNodeAddr, "API address of the running node pricing --priority")
+	cmd.Flags().BoolVar(&allowHighFee, "allow-high-fee", false, "allow a fee above the wallet's sanity limits")
 
 	cmd.MarkFlagRequired("from")
 	cmd.MarkFlagRequired("to")
@@ -702,9 +992,10 @@ func getBalanceCmd() *cobra.Command {
 			}
 			defer walletStorage.Close()
 
-			walletConfig := wallet.DefaultWalletConfig()
-			walletConfig.WalletFile = walletFile
-			walletConfig.Passphrase = passphrase
+			if err := loadConfig(); err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			walletConfig := newWalletConfig(buildNodeConfig())
 
 			us := utxo.NewUTXOSet() // Still a dummy UTXOSet for CLI commands
 			wallet, err := wallet.NewWallet(walletConfig, us, walletStorage)
@@ -726,139 +1017,200 @@ func getBalanceCmd() *cobra.Command {
 }
 
 func getBlockchainInfoCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "info",
-		Short: "Get blockchain information",
+	return newChainInfoCmd("info", "Get blockchain information", "Blockchain Information")
+}
+
+func getSafeInfoCmd() *cobra.Command {
+	return newChainInfoCmd("safe-info", "Get safe blockchain information", "Safe Blockchain Information")
+}
+
+// newChainInfoCmd creates a command printing the stored chain information,
+// as text under title or, with --json, as the JSON the API serves.
+func newChainInfoCmd(use, short, title string) *cobra.Command {
+	var asJSON bool
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// Load configuration to determine storage type
 			if err := loadConfig(); err != nil {
 				return fmt.Errorf("failed to load config: %w", err)
 			}
 
-			// Create storage using factory
-			storageFactory := storage.NewStorageFactory()
-
-			// Determine storage type from config or use default
-			storageType := storage.StorageTypeFile // Default to file storage
-			configDBType := viper.GetString("storage.db_type")
-			if configDBType == "leveldb" {
-				storageType = storage.StorageTypeLevelDB
+			info, err := readStoredChainInfo()
+			if err != nil {
+				return err
 			}
+			return printChainInfo(cmd.OutOrStdout(), title, info, asJSON)
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "print the information as JSON")
+	return cmd
+}
 
-			dataDir := viper.GetString("storage.data_dir")
-			if dataDir == "" {
-				dataDir = "./data"
-			}
+// readStoredChainInfo reads the chain information from the configured
+// storage without loading the chain.
+func readStoredChainInfo() (*api.ChainInfo, error) {
+	// Determine storage type from config or use default
+	storageType := storage.StorageTypeFile // Default to file storage
+	if viper.GetString("storage.db_type") == "leveldb" {
+		storageType = storage.StorageTypeLevelDB
+	}
 
-			// Create storage
-			nodeStorage, err := storageFactory.CreateStorage(storageType, dataDir)
-			if err != nil {
-				return fmt.Errorf("failed to create storage: %w", err)
-			}
+	dataDir := viper.GetString("storage.data_dir")
+	if dataDir == "" {
+		dataDir = "./data"
+	}
 
-			// MINIMAL APPROACH: Read chainstate directly without loading full chain
-			fmt.Printf("Blockchain Information:\n")
-			
-			// Read chainstate directly
-			chainState, err := nodeStorage.GetChainState()
-			if err != nil {
-				fmt.Printf("Height: 0 (No chain state found)\n")
-				fmt.Printf("Best Block Hash: Not available\n")
-			} else {
-				fmt.Printf("Height: %d\n", chainState.Height)
-				if len(chainState.BestBlockHash) > 0 {
-					fmt.Printf("Best Block Hash: %x\n", chainState.BestBlockHash)
-				} else {
-					fmt.Printf("Best Block Hash: Not available\n")
-				}
-			}
-			
-			// Count block files
-			blockCount := 0
-			if entries, err := os.ReadDir(dataDir); err == nil {
-				for _, entry := range entries {
-					if !entry.IsDir() && len(entry.Name()) == 64 { // Block files are 64 chars
-						blockCount++
-					}
-				}
-			}
-			fmt.Printf("Block Files: %d\n", blockCount)
-			
-			// Storage information
-			fmt.Printf("Storage Type: %s\n", storageType)
-			fmt.Printf("Data Directory: %s\n", dataDir)
+	nodeStorage, err := storage.NewStorageFactory().CreateStorage(storageType, dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage: %w", err)
+	}
+	defer nodeStorage.Close()
 
-			return nil
-		},
+	info := &api.ChainInfo{Storage: &api.StorageInfo{Type: string(storageType), DataDir: dataDir}}
+
+	// Read chainstate directly, a missing one leaves height and hash empty
+	if chainState, err := nodeStorage.GetChainState(); err == nil {
+		info.Height = chainState.Height
+		if len(chainState.BestBlockHash) > 0 {
+			info.BestBlockHash = fmt.Sprintf("%x", chainState.BestBlockHash)
+		}
 	}
-}
 
-func getSafeInfoCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "safe-info",
-		Short: "Get safe blockchain information",
-		RunE: func(cmd *cobra.Command, args []string) error {
-			// Load configuration to determine storage type
-			if err := loadConfig(); err != nil {
-				return fmt.Errorf("failed to load config: %w", err)
+	// Count block files
+	if entries, err := os.ReadDir(dataDir); err == nil {
+		for _, entry := range entries {
+			if !entry.IsDir() && len(entry.Name()) == 64 { // Block files are 64 chars
+				info.Storage.BlockCount++
 			}
+		}
+	}
 
-			// Create storage using factory
-			storageFactory := storage.NewStorageFactory()
+	return info, nil
+}
 
-			// Determine storage type from config or use default
-			storageType := storage.StorageTypeFile // Default to file storage
-			configDBType := viper.GetString("storage.db_type")
-			if configDBType == "leveldb" {
-				storageType = storage.StorageTypeLevelDB
-			}
+// printChainInfo writes info to w as text under title, or as JSON.
+func printChainInfo(w io.Writer, title string, info *api.ChainInfo, asJSON bool) error {
+	if asJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(info)
+	}
 
-			dataDir := viper.GetString("storage.data_dir")
-			if dataDir == "" {
-				dataDir = "./data"
-			}
+	fmt.Fprintf(w, "%s:\n", title)
+	if info.Height == 0 && info.BestBlockHash == "" {
+		fmt.Fprintf(w, "Height: 0 (No chain state found)\n")
+	} else {
+		fmt.Fprintf(w, "Height: %d\n", info.Height)
+	}
+	if info.BestBlockHash != "" {
+		fmt.Fprintf(w, "Best Block Hash: %s\n", info.BestBlockHash)
+	} else {
+		fmt.Fprintf(w, "Best Block Hash: Not available\n")
+	}
+	if info.Storage != nil {
+		fmt.Fprintf(w, "Block Files: %d\n", info.Storage.BlockCount)
+		fmt.Fprintf(w, "Storage Type: %s\n", info.Storage.Type)
+		fmt.Fprintf(w, "Data Directory: %s\n", info.Storage.DataDir)
+	}
+	return nil
+}
 
-			// Create storage
-			nodeStorage, err := storageFactory.CreateStorage(storageType, dataDir)
-			if err != nil {
-				return fmt.Errorf("failed to create storage: %w", err)
-			}
+// restoreMempool loads the mempool saved at path by the previous run. A file
+// in an incompatible format is dropped with a warning and the node starts
+// with an empty mempool.
+func restoreMempool(mp *mempool.Mempool, path string, log *logger.Logger) {
+	loaded, err := mp.Load(path)
+	if errors.Is(err, mempool.ErrIncompatibleMempoolFile) {
+		log.Warn("Discarded saved mempool, starting empty: %v", err)
+		return
+	}
+	if err != nil {
+		log.Error("Failed to load mempool: %v", err)
+		return
+	}
+	if loaded > 0 {
+		log.Info("Restored %d mempool transactions", loaded)
+	}
+}
 
-			// MINIMAL APPROACH: Read chainstate directly without loading full chain
-			fmt.Printf("Safe Blockchain Information:\n")
-			
-			// Read chainstate directly
-			chainState, err := nodeStorage.GetChainState()
-			if err != nil {
-				fmt.Printf("Height: 0 (No chain state found)\n")
-				fmt.Printf("Best Block Hash: Not available\n")
-			} else {
-				fmt.Printf("Height: %d\n", chainState.Height)
-				if len(chainState.BestBlockHash) > 0 {
-					fmt.Printf("Best Block Hash: %x\n", chainState.BestBlockHash)
-				} else {
-					fmt.Printf("Best Block Hash: Not available\n")
-				}
-			}
-			
-			// Count block files
-			blockCount := 0
-			if entries, err := os.ReadDir(dataDir); err == nil {
-				for _, entry := range entries {
-					if !entry.IsDir() && len(entry.Name()) == 64 { // Block files are 64 chars
-						blockCount++
-					}
-				}
-			}
-			fmt.Printf("Block Files: %d\n", blockCount)
-			
-			// Storage information
-			fmt.Printf("Storage Type: %s\n", storageType)
-			fmt.Printf("Data Directory: %s\n", dataDir)
+// trackChainInMempool keeps the mempool in step with the active chain: the
+// transactions of connected blocks leave it and those of blocks a reorg
+// disconnected return to it, validated against the new tip.
+func trackChainInMempool(c *chain.Chain, mp *mempool.Mempool) {
+	c.OnBlockEvent(func(event chain.BlockEvent) {
+		mp.SetChainHeight(c.GetHeight())
+		if event.Disconnected {
+			mp.ReturnDisconnectedTransactions(event.Block)
+		} else {
+			mp.RemoveConfirmedTransactions(event.Block)
+		}
+	})
+}
 
-			return nil
-		},
+// trackChainInWallet keeps the status of the wallet's transactions in step
+// with the active chain: connected blocks confirm them or make them
+// conflicted, and blocks a reorg disconnected make them pending again. The
+// wallet also follows the height and whether the node is still in initial
+// block download, which only blocks can end.
+func trackChainInWallet(c *chain.Chain, w *wallet.Wallet) {
+	w.SetChainHeight(c.GetHeight())
+	w.SetInitialBlockDownload(c.IsInitialBlockDownload())
+	c.OnBlockEvent(func(event chain.BlockEvent) {
+		w.SetChainHeight(c.GetHeight())
+		w.SetInitialBlockDownload(c.IsInitialBlockDownload())
+		if event.Disconnected {
+			w.DisconnectBlock(event.Block)
+		} else {
+			w.ConnectBlock(event.Block)
+		}
+	})
+}
+
+// newWalletConfig returns the configuration of the wallet file given on the
+// command line, checking the transactions it creates against the relay
+// policy of the node's mempool so that it accepts them, and maturing
+// coinbase outputs like the node's chain.
+// defaultNodeAddr is the API address wallet commands reach a node at by
+// default, that of a node serving its API on the default port.
+const defaultNodeAddr = "http://localhost:8080"
+
+// newCLIUTXOSet returns the UTXO set wallet commands spend from, still a
+// dummy for CLI commands.
+var newCLIUTXOSet = utxo.NewUTXOSet
+
+func newWalletConfig(cfg *nodeConfig) *wallet.WalletConfig {
+	config := wallet.DefaultWalletConfig()
+	config.WalletFile = walletFile
+	config.Passphrase = passphrase
+	policy := cfg.Mempool.RelayPolicy()
+	config.RelayPolicy = &policy
+	if cfg.Chain.CoinbaseMaturity > 0 {
+		config.CoinbaseMaturity = cfg.Chain.CoinbaseMaturity
+	}
+	return config
+}
+
+// loadNodeWallet opens the wallet file the node serves. Without one, a new
+// wallet is created and saved so that its accounts survive restarts.
+func loadNodeWallet(config *wallet.WalletConfig, us *utxo.UTXOSet, s *storage.Storage, log *logger.Logger) (*wallet.Wallet, error) {
+	w, err := wallet.NewWallet(config, us, s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create wallet: %w", err)
+	}
+	err = w.Load()
+	if os.IsNotExist(err) {
+		if err := w.Save(); err != nil {
+			return nil, fmt.Errorf("failed to save new wallet %s: %w", config.WalletFile, err)
+		}
+		log.Info("Created new wallet %s", config.WalletFile)
+		return w, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load wallet %s: %w", config.WalletFile, err)
 	}
+	return w, nil
 }
 
 // setupLogger creates and configures the logger based on configuration
@@ -981,5 +1333,6 @@ func createMonitoringConfig() *monitoring.Config {
 		CollectInterval:     collectInterval,
 		HealthCheckInterval: healthCheckInterval,
 		EnablePrometheus:    viper.GetBool("monitoring.metrics.prometheus_enabled"),
+		BindFailurePolicy:   monitoring.BindFailurePolicy(viper.GetString("monitoring.bind_failure_policy")),
 	}
 }
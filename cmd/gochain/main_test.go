@@ -2,14 +2,27 @@ package main
 
 import (
 	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/palaseus/adrenochain/pkg/api"
+	"github.com/palaseus/adrenochain/pkg/logger"
+	"github.com/palaseus/adrenochain/pkg/mempool"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/palaseus/adrenochain/pkg/utxo"
+	"github.com/palaseus/adrenochain/pkg/wallet"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestRunNode tests the runNode function without starting actual network services
@@ -467,6 +480,54 @@ func TestGetBlockchainInfoCmdExecution(t *testing.T) {
 	assert.NotNil(t, cmd.RunE)
 }
 
+// TestChainInfoCmdJSON tests that --json prints the same information as the text output
+func TestChainInfoCmdJSON(t *testing.T) {
+	dataDir := t.TempDir()
+	nodeStorage, err := storage.NewStorageFactory().CreateStorage(storage.StorageTypeFile, dataDir)
+	assert.NoError(t, err)
+	bestHash := bytes.Repeat([]byte{0xab}, 32)
+	assert.NoError(t, nodeStorage.StoreChainState(&storage.ChainState{BestBlockHash: bestHash, Height: 42}))
+	assert.NoError(t, nodeStorage.Close())
+
+	originalConfigFile := configFile
+	configFile = ""
+	viper.Set("storage.data_dir", dataDir)
+	viper.Set("storage.db_type", "file")
+	defer func() {
+		configFile = originalConfigFile
+		viper.Set("storage.data_dir", "")
+		viper.Set("storage.db_type", "")
+	}()
+
+	for _, newCmd := range []func() *cobra.Command{getBlockchainInfoCmd, getSafeInfoCmd} {
+		run := func(args ...string) string {
+			cmd := newCmd()
+			var out bytes.Buffer
+			cmd.SetOut(&out)
+			assert.NoError(t, cmd.ParseFlags(args))
+			assert.NoError(t, cmd.RunE(cmd, nil))
+			return out.String()
+		}
+
+		var info map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(run("--json")), &info))
+		assert.Equal(t, float64(42), info["height"])
+		assert.Equal(t, hex.EncodeToString(bestHash), info["best_block_hash"])
+		storageInfo, ok := info["storage"].(map[string]interface{})
+		if assert.True(t, ok, "JSON output should contain storage information") {
+			assert.Equal(t, "file", storageInfo["type"])
+			assert.Equal(t, dataDir, storageInfo["data_dir"])
+			assert.Contains(t, storageInfo, "block_count")
+		}
+
+		text := run()
+		assert.Contains(t, text, "Height: 42\n")
+		assert.Contains(t, text, "Best Block Hash: "+info["best_block_hash"].(string)+"\n")
+		assert.Contains(t, text, fmt.Sprintf("Block Files: %v\n", storageInfo["block_count"]))
+		assert.Contains(t, text, "Storage Type: file\n")
+	}
+}
+
 // TestRunNodeErrorHandling tests error handling in runNode function (without starting network services)
 func TestRunNodeErrorHandling(t *testing.T) {
 	// Test with invalid configuration that should cause errors
@@ -897,3 +958,143 @@ func TestCreateMonitoringConfigEdgeCases(t *testing.T) {
 		assert.NotNil(t, config.HealthPort)
 	})
 }
+
+// TestRestoreMempoolIncompatibleVersion checks that a saved mempool with an
+// unknown format version does not stop the node from starting.
+func TestRestoreMempoolIncompatibleVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mempool.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"version":99,"transactions":[]}`), 0644))
+
+	var logs bytes.Buffer
+	log := logger.NewLogger(&logger.Config{Level: logger.INFO, Output: &logs})
+	mp := mempool.NewMempool(mempool.DefaultMempoolConfig())
+
+	restoreMempool(mp, path, log)
+
+	assert.Zero(t, mp.GetTransactionCount())
+	assert.Contains(t, logs.String(), "WARN")
+	assert.Contains(t, logs.String(), "Discarded saved mempool")
+	assert.NoFileExists(t, path)
+}
+
+// TestLoadNodeWalletServesSavedAccounts checks that the node serves the
+// accounts of the wallet file it was started with rather than fresh ones.
+func TestLoadNodeWalletServesSavedAccounts(t *testing.T) {
+	s, err := storage.NewStorage(storage.DefaultStorageConfig().WithDataDir(t.TempDir()))
+	require.NoError(t, err)
+	defer s.Close()
+
+	var logs bytes.Buffer
+	log := logger.NewLogger(&logger.Config{Level: logger.INFO, Output: &logs})
+	config := wallet.DefaultWalletConfig()
+	config.WalletFile = "node_wallet.dat"
+	config.Passphrase = "node wallet passphrase"
+
+	// Without a wallet file a new wallet is created and saved
+	created, err := loadNodeWallet(config, utxo.NewUTXOSet(), s, log)
+	require.NoError(t, err)
+	assert.Contains(t, logs.String(), "Created new wallet")
+	saved := created.GetDefaultAccount()
+	require.NotNil(t, saved)
+
+	// Restarting loads it back
+	us := utxo.NewUTXOSet()
+	us.AddUTXO(utxo.NewUTXO(bytes.Repeat([]byte{1}, 32), 0, 4200, []byte("script"), saved.Address, false, 1))
+	loaded, err := loadNodeWallet(config, us, s, log)
+	require.NoError(t, err)
+	require.Len(t, loaded.GetAllAccounts(), 1)
+	assert.Equal(t, saved.Address, loaded.GetDefaultAccount().Address)
+
+	server, err := api.NewServer(&api.ServerConfig{Wallet: loaded, WalletEndpoints: true, UTXOSet: us})
+	require.NoError(t, err)
+	get := func(path string) map[string]interface{} {
+		rr := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&body))
+		return body
+	}
+
+	accounts := get("/api/v1/wallet/accounts")
+	assert.Equal(t, float64(1), accounts["count"])
+	assert.Equal(t, saved.Address, accounts["accounts"].([]interface{})[0].(map[string]interface{})["address"])
+	assert.Equal(t, float64(4200), get("/api/v1/wallet/balance/"+saved.Address)["balance"])
+
+	// A wallet file that cannot be decrypted stops the node
+	config.Passphrase = "wrong passphrase"
+	_, err = loadNodeWallet(config, us, s, log)
+	assert.ErrorContains(t, err, "failed to load wallet node_wallet.dat")
+}
+
+// TestCreateTransactionCmdWithPriority checks that send --priority pays the
+// fee rate the node at --node estimates for the level.
+func TestCreateTransactionCmdWithPriority(t *testing.T) {
+	originalDir, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	defer os.Chdir(originalDir)
+	walletFile = "test_wallet_priority.json"
+	passphrase = "test_passphrase_priority"
+
+	walletCmd := createWalletCmd()
+	require.NoError(t, walletCmd.RunE(walletCmd, []string{}))
+
+	// Fund the saved wallet's account
+	walletStorage, err := storage.NewStorage(storage.DefaultStorageConfig().WithDataDir("./wallet_data"))
+	require.NoError(t, err)
+	saved, err := wallet.NewWallet(newWalletConfig(buildNodeConfig()), utxo.NewUTXOSet(), walletStorage)
+	require.NoError(t, err)
+	require.NoError(t, saved.Load())
+	from := saved.GetDefaultAccount()
+	require.NoError(t, walletStorage.Close())
+
+	originalUTXOSet := newCLIUTXOSet
+	defer func() { newCLIUTXOSet = originalUTXOSet }()
+	newCLIUTXOSet = func() *utxo.UTXOSet {
+		us := utxo.NewUTXOSet()
+		us.AddUTXO(&utxo.UTXO{
+			TxHash:       bytes.Repeat([]byte{1}, 32),
+			Value:        2000000,
+			ScriptPubKey: from.PublicKey,
+			Address:      from.Address,
+			Height:       1,
+		})
+		return us
+	}
+
+	// The node estimates 40 per byte for the next block
+	var requested []string
+	fallback := false
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/fee/estimate", r.URL.Path)
+		requested = append(requested, r.URL.Query().Get("blocks"))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"blocks":   r.URL.Query().Get("blocks"),
+			"fee_rate": 40,
+			"fallback": fallback,
+		})
+	}))
+	defer node.Close()
+
+	send := func(nodeAddr string) error {
+		cmd := createTransactionCmd()
+		require.NoError(t, cmd.ParseFlags([]string{
+			"--from", from.Address,
+			"--to", "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa",
+			"--amount", "100000",
+			"--priority", "priority",
+			"--node", nodeAddr,
+		}))
+		return cmd.RunE(cmd, []string{})
+	}
+
+	require.NoError(t, send(node.URL))
+	assert.Contains(t, requested, "1")
+
+	// A node without an estimate, or no node at all, leaves the level unpriced
+	fallback = true
+	assert.ErrorContains(t, send(node.URL), "no fee estimate")
+	node.Close()
+	assert.ErrorContains(t, send(node.URL), "failed to get fee estimate from node")
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// errAdminReadOnly is the refusal of block administration by a read-only node
+const errAdminReadOnly = "node is read-only, block administration is disabled"
+
+// requireAdmin wraps the handler of an administrative endpoint. Read-only
+// nodes refuse it with 403. With an admin token configured, requests must
+// carry it as a bearer token; without one, only loopback clients are served.
+func (s *Server) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.readOnly {
+			http.Error(w, errAdminReadOnly, http.StatusForbidden)
+			return
+		}
+		if s.adminToken != "" {
+			token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+		} else if !isLoopbackRequest(r) {
+			http.Error(w, "Block administration is only available to local clients", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// isLoopbackRequest reports whether a request comes from the local host
+func isLoopbackRequest(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
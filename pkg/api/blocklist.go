@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxBlockPageSize is the default maximum number of blocks returned
+// by one page of the block listing.
+const DefaultMaxBlockPageSize = 100
+
+// blockSummary describes a block in the block listing.
+type blockSummary struct {
+	Height    uint64 `json:"height"`
+	Hash      string `json:"hash"`
+	Timestamp string `json:"timestamp"`
+	TxCount   int    `json:"tx_count"`
+}
+
+// blockPage is one page of the block listing. NextStartHeight is the
+// start_height of the next page, nil on the last page.
+type blockPage struct {
+	Blocks          []blockSummary `json:"blocks"`
+	NextStartHeight *uint64        `json:"next_start_height"`
+}
+
+// listBlocksHandler returns up to limit blocks of the active chain from
+// start_height upwards, one at a time through GetBlockByHeight so the chain
+// is never loaded whole. A start past the tip yields an empty page.
+func (s *Server) listBlocksHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if s.chain == nil {
+		http.Error(w, "Chain not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	query := r.URL.Query()
+	start, err := parseHeightParam(query.Get("start_height"), 0)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid start_height: %v", err), http.StatusBadRequest)
+		return
+	}
+	limit, err := parseHeightParam(query.Get("limit"), uint64(s.maxBlockPageSize))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid limit: %v", err), http.StatusBadRequest)
+		return
+	}
+	if limit == 0 {
+		http.Error(w, "Invalid limit: must be positive", http.StatusBadRequest)
+		return
+	}
+	limit = min(limit, uint64(s.maxBlockPageSize))
+
+	page := blockPage{Blocks: make([]blockSummary, 0)}
+	tip := s.chain.GetHeight()
+	for height := start; height <= tip && uint64(len(page.Blocks)) < limit; height++ {
+		b := s.chain.GetBlockByHeight(height)
+		if b == nil {
+			break
+		}
+		page.Blocks = append(page.Blocks, blockSummary{
+			Height:    b.Header.Height,
+			Hash:      fmt.Sprintf("%x", b.CalculateHash()),
+			Timestamp: b.Header.Timestamp.Format(time.RFC3339),
+			TxCount:   len(b.Transactions),
+		})
+	}
+	if n := uint64(len(page.Blocks)); n > 0 && start+n <= tip {
+		next := start + n
+		page.NextStartHeight = &next
+	}
+
+	json.NewEncoder(w).Encode(page)
+}
+
+// parseHeightParam parses a non-negative integer query parameter, returning
+// def when it is absent.
+func parseHeightParam(value string, def uint64) (uint64, error) {
+	if value == "" {
+		return def, nil
+	}
+	if strings.HasPrefix(value, "-") {
+		return 0, fmt.Errorf("%s is negative", value)
+	}
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a valid height", value)
+	}
+	return n, nil
+}
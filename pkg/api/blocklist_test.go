@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListBlocks(t *testing.T) {
+	mc := NewMockChain()
+	extendMockChain(mc, 8) // heights 0 to 9
+	server := newTestServer(t, &ServerConfig{Chain: mc, MaxBlockPageSize: 4})
+
+	list := func(query string) (*httptest.ResponseRecorder, blockPage) {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/blocks"+query, nil))
+		var page blockPage
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		}
+		return w, page
+	}
+	heights := func(page blockPage) []uint64 {
+		var heights []uint64
+		for _, summary := range page.Blocks {
+			heights = append(heights, summary.Height)
+		}
+		return heights
+	}
+
+	_, page := list("?start_height=2&limit=3")
+	assert.Equal(t, []uint64{2, 3, 4}, heights(page))
+	require.NotNil(t, page.NextStartHeight)
+	assert.Equal(t, uint64(5), *page.NextStartHeight)
+	b := mc.blocksByHeight[2]
+	assert.Equal(t, fmt.Sprintf("%x", b.CalculateHash()), page.Blocks[0].Hash)
+	assert.Equal(t, len(b.Transactions), page.Blocks[0].TxCount)
+	assert.NotEmpty(t, page.Blocks[0].Timestamp)
+
+	// The limit defaults to and is capped at the maximum page size
+	_, page = list("")
+	assert.Equal(t, []uint64{0, 1, 2, 3}, heights(page))
+	_, page = list("?start_height=5&limit=100")
+	assert.Equal(t, []uint64{5, 6, 7, 8}, heights(page))
+
+	// The last page has no cursor
+	_, page = list("?start_height=8")
+	assert.Equal(t, []uint64{8, 9}, heights(page))
+	assert.Nil(t, page.NextStartHeight)
+
+	// A start past the tip is an empty page, not an error
+	w, page := list("?start_height=50")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"blocks": [], "next_start_height": null}`, w.Body.String())
+
+	for _, query := range []string{"?start_height=-1", "?start_height=abc", "?start_height=99999999999999999999", "?limit=0", "?limit=-5"} {
+		w, _ := list(query)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+
+	server = newTestServer(t, &ServerConfig{Chain: mc})
+	assert.Equal(t, DefaultMaxBlockPageSize, server.maxBlockPageSize)
+}
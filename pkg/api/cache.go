@@ -0,0 +1,220 @@
+package api
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"github.com/palaseus/adrenochain/pkg/block"
+)
+
+const (
+	// DefaultCacheConfirmations is the number of confirmations after which a
+	// block, and the transactions in it, are treated as final.
+	DefaultCacheConfirmations = 6
+
+	// immutableCacheControl is sent with responses for final resources.
+	immutableCacheControl = "public, max-age=31536000, immutable"
+)
+
+// cachedResponse is a stored response for a final resource, together with
+// the block it depends on so that a reorg deeper than the confirmation depth
+// invalidates it.
+type cachedResponse struct {
+	contentType string
+	body        []byte
+	height      uint64
+	blockHash   []byte
+}
+
+// responseCache holds responses for final blocks and transactions keyed by
+// request path. The oldest entry is forgotten once the cache is full.
+type responseCache struct {
+	mu        sync.Mutex
+	capacity  int
+	responses map[string]*cachedResponse
+	order     []string // order holds keys oldest first for eviction.
+}
+
+// newResponseCache creates a cache holding up to capacity responses.
+func newResponseCache(capacity int) *responseCache {
+	return &responseCache{
+		capacity:  capacity,
+		responses: make(map[string]*cachedResponse),
+	}
+}
+
+func (c *responseCache) get(key string) *cachedResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.responses[key]
+}
+
+func (c *responseCache) add(key string, response *cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.responses[key]; !exists {
+		if len(c.order) >= c.capacity {
+			delete(c.responses, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.order = append(c.order, key)
+	}
+	c.responses[key] = response
+}
+
+func (c *responseCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.responses[key]; !exists {
+		return
+	}
+	delete(c.responses, key)
+	for i, cached := range c.order {
+		if cached == key {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+}
+
+func (c *responseCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.responses)
+}
+
+// responseRecorder captures a handler's response while writing it through.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+// cacheFinal wraps the handler of an immutable resource. Responses are
+// served from the cache while the block they depend on is still on the
+// active chain; successful responses are cached once finalBlock reports the
+// resource final. Without a cache the handler runs unchanged.
+func (s *Server) cacheFinal(handler http.HandlerFunc, finalBlock func(r *http.Request) *block.Block) http.HandlerFunc {
+	if s.cache == nil {
+		return handler
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Path
+		if cached := s.cache.get(key); cached != nil {
+			if s.onActiveChain(cached.height, cached.blockHash) {
+				w.Header().Set("Content-Type", cached.contentType)
+				w.Header().Set("Cache-Control", immutableCacheControl)
+				w.Header().Set("X-Cache", "HIT")
+				w.Write(cached.body)
+				return
+			}
+			s.cache.remove(key)
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w}
+		handler(recorder, r)
+		if recorder.status != http.StatusOK {
+			return
+		}
+		if b := finalBlock(r); b != nil {
+			s.cache.add(key, &cachedResponse{
+				contentType: w.Header().Get("Content-Type"),
+				body:        recorder.body.Bytes(),
+				height:      b.Header.Height,
+				blockHash:   b.CalculateHash(),
+			})
+		}
+	}
+}
+
+// isFinal reports whether a block is on the active chain with at least the
+// configured number of confirmations.
+func (s *Server) isFinal(b *block.Block) bool {
+	if b == nil || b.Header == nil {
+		return false
+	}
+	tip := s.chain.GetHeight()
+	if tip < b.Header.Height || tip-b.Header.Height+1 < s.cacheConfirmations {
+		return false
+	}
+	return s.onActiveChain(b.Header.Height, b.CalculateHash())
+}
+
+// onActiveChain reports whether the active chain has the block with the
+// given hash at height.
+func (s *Server) onActiveChain(height uint64, hash []byte) bool {
+	active := s.chain.GetBlockByHeight(height)
+	return active != nil && bytes.Equal(active.CalculateHash(), hash)
+}
+
+// finalBlockByHash returns the block named by the hash route variable if it
+// is final.
+func (s *Server) finalBlockByHash(r *http.Request) *block.Block {
+	hash, err := hex.DecodeString(mux.Vars(r)["hash"])
+	if err != nil {
+		return nil
+	}
+	if b := s.chain.GetBlock(hash); s.isFinal(b) {
+		return b
+	}
+	return nil
+}
+
+// finalBlockByHeight returns the block at the height route variable if it
+// is final.
+func (s *Server) finalBlockByHeight(r *http.Request) *block.Block {
+	height, err := strconv.ParseUint(mux.Vars(r)["height"], 10, 64)
+	if err != nil {
+		return nil
+	}
+	if b := s.chain.GetBlockByHeight(height); s.isFinal(b) {
+		return b
+	}
+	return nil
+}
+
+// finalTransactionBlock returns the block confirming the transaction named
+// by the hash route variable if it is final, found through the chain's
+// transaction index. Transactions still in the mempool, and those of chains
+// without a transaction index, are never final.
+func (s *Server) finalTransactionBlock(r *http.Request) *block.Block {
+	index, ok := s.chain.(ChainTxIndexInterface)
+	if !ok {
+		return nil
+	}
+	hash, err := hex.DecodeString(mux.Vars(r)["hash"])
+	if err != nil {
+		return nil
+	}
+	if lookup, ok := s.mempool.(MempoolLookupInterface); ok && lookup.GetTransaction(hash) != nil {
+		return nil
+	}
+
+	blockHash, found := index.GetTxBlockHash(hash)
+	if !found {
+		return nil
+	}
+	if b := s.chain.GetBlock(blockHash); s.isFinal(b) {
+		return b
+	}
+	return nil
+}
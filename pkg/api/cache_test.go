@@ -0,0 +1,113 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// extendMockChain appends count blocks with one transaction each to the
+// active chain of mc.
+func extendMockChain(mc *MockChain, count int) {
+	for i := 0; i < count; i++ {
+		b := &block.Block{
+			Header: &block.Header{
+				Version:       1,
+				PrevBlockHash: mc.bestBlock.CalculateHash(),
+				Timestamp:     time.Unix(int64(1700000000+mc.height), 0),
+				Difficulty:    1,
+				Height:        mc.height + 1,
+			},
+			Transactions: []*block.Transaction{{
+				Version: 1,
+				Outputs: []*block.TxOutput{{Value: mc.height + 1, ScriptPubKey: []byte("miner")}},
+			}},
+		}
+		b.Transactions[0].Hash = b.Transactions[0].CalculateHash()
+		b.Header.MerkleRoot = b.CalculateMerkleRoot()
+		mc.blocks[fmt.Sprintf("%x", b.CalculateHash())] = b
+		mc.blocksByHeight[b.Header.Height] = b
+		mc.bestBlock = b
+		mc.height = b.Header.Height
+	}
+}
+
+func TestFinalResponsesAreCached(t *testing.T) {
+	mc := NewMockChain()
+	extendMockChain(mc, 8)
+	server := newTestServer(t, &ServerConfig{Chain: mc, ResponseCacheSize: 16, CacheConfirmations: 6})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		return w
+	}
+
+	// Block 2 has 8 confirmations: the second request is served from cache
+	final := mc.blocksByHeight[2]
+	path := fmt.Sprintf("/api/v1/blocks/%x", final.CalculateHash())
+	first := get(path)
+	assert.Empty(t, first.Header().Get("X-Cache"))
+	second := get(path)
+	assert.Equal(t, "HIT", second.Header().Get("X-Cache"))
+	assert.Equal(t, immutableCacheControl, second.Header().Get("Cache-Control"))
+	assert.Equal(t, "application/json", second.Header().Get("Content-Type"))
+	assert.Equal(t, first.Body.String(), second.Body.String())
+
+	// So are its transactions
+	txPath := fmt.Sprintf("/api/v1/transactions/%x/hex", final.Transactions[0].Hash)
+	get(txPath)
+	assert.Equal(t, "HIT", get(txPath).Header().Get("X-Cache"))
+	assert.Equal(t, 2, server.cache.len())
+
+	// Tip-dependent responses and blocks with too few confirmations are not
+	for _, path := range []string{
+		"/api/v1/blocks/latest",
+		"/api/v1/chain/info",
+		fmt.Sprintf("/api/v1/blocks/%x", mc.bestBlock.CalculateHash()),
+		fmt.Sprintf("/api/v1/blocks/height/%d", mc.height-1),
+	} {
+		get(path)
+		assert.Empty(t, get(path).Header().Get("X-Cache"), path)
+	}
+	assert.Equal(t, 2, server.cache.len())
+
+	// A reorg replacing the cached block invalidates its response
+	mc.blocksByHeight[2] = mc.blocksByHeight[3]
+	third := get(path)
+	assert.Empty(t, third.Header().Get("X-Cache"))
+	assert.Equal(t, 1, server.cache.len())
+}
+
+func TestResponseCacheDisabled(t *testing.T) {
+	mc := NewMockChain()
+	extendMockChain(mc, 8)
+	server := newTestServer(t, &ServerConfig{Chain: mc})
+	assert.Nil(t, server.cache)
+
+	path := fmt.Sprintf("/api/v1/blocks/%x", mc.blocksByHeight[2].CalculateHash())
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("X-Cache"))
+	}
+}
+
+func TestResponseCacheBounds(t *testing.T) {
+	cache := newResponseCache(2)
+	for _, key := range []string{"a", "b", "c"} {
+		cache.add(key, &cachedResponse{body: []byte(key)})
+	}
+	assert.Nil(t, cache.get("a"))
+	assert.Equal(t, []byte("c"), cache.get("c").body)
+	cache.remove("b")
+	assert.Equal(t, 1, cache.len())
+}
@@ -0,0 +1,40 @@
+package api
+
+import "net/http"
+
+// connectionLimiter caps the number of requests served concurrently. Each
+// request holds a slot until its handler returns.
+type connectionLimiter struct {
+	slots chan struct{}
+}
+
+// newConnectionLimiter creates a limiter admitting up to max requests at a
+// time.
+func newConnectionLimiter(max int) *connectionLimiter {
+	return &connectionLimiter{slots: make(chan struct{}, max)}
+}
+
+// wrap returns a handler serving requests through next while a slot is
+// free and refusing them with 503 Service Unavailable otherwise.
+func (l *connectionLimiter) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case l.slots <- struct{}{}:
+		default:
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Too many concurrent connections", http.StatusServiceUnavailable)
+			return
+		}
+		defer func() { <-l.slots }()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Handler returns the HTTP handler of the server, with the concurrent
+// connection limit applied.
+func (s *Server) Handler() http.Handler {
+	if s.limiter == nil {
+		return s.router
+	}
+	return s.limiter.wrap(s.router)
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxConcurrentConnections(t *testing.T) {
+	const limit = 3
+	server := newTestServer(t, &ServerConfig{Chain: NewMockChain(), MaxConcurrentConnections: limit})
+
+	// Requests to /slow hold their slot until released
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server.router.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	})
+	ts := httptest.NewServer(server.Handler())
+	defer ts.Close()
+
+	done := make(chan int, limit)
+	for i := 0; i < limit; i++ {
+		go func() {
+			resp, err := http.Get(ts.URL + "/slow")
+			if err != nil {
+				done <- 0
+				return
+			}
+			resp.Body.Close()
+			done <- resp.StatusCode
+		}()
+		<-started
+	}
+
+	resp, err := http.Get(ts.URL + "/health")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	// Completing one request frees a slot
+	release <- struct{}{}
+	assert.Equal(t, http.StatusOK, <-done)
+	resp, err = http.Get(ts.URL + "/health")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	close(release)
+	for i := 1; i < limit; i++ {
+		assert.Equal(t, http.StatusOK, <-done)
+	}
+}
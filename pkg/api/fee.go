@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// DefaultFeeEstimateBlocks is the confirmation target used when the
+	// request does not give one.
+	DefaultFeeEstimateBlocks = 6
+	// FallbackFeeRate is the fee rate, per byte, recommended when the
+	// mempool cannot estimate fees at all.
+	FallbackFeeRate = 1
+)
+
+// FeeEstimatorInterface is optionally implemented by the mempool passed in
+// ServerConfig to recommend fee rates. ok is false when there is not enough
+// confirmation history, in which case feeRate is the mempool's fallback.
+type FeeEstimatorInterface interface {
+	EstimateFeeRate(blocks uint64) (feeRate uint64, ok bool)
+}
+
+// estimateFeeHandler returns the fee rate a transaction should pay to
+// confirm within the number of blocks given by the blocks query parameter.
+// When the estimate is based on too little history the response is marked
+// as a fallback.
+func (s *Server) estimateFeeHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	blocks := uint64(DefaultFeeEstimateBlocks)
+	if param := r.URL.Query().Get("blocks"); param != "" {
+		parsed, err := strconv.ParseUint(param, 10, 64)
+		if err != nil || parsed == 0 {
+			http.Error(w, "Invalid blocks parameter", http.StatusBadRequest)
+			return
+		}
+		blocks = parsed
+	}
+
+	feeRate, ok := uint64(FallbackFeeRate), false
+	if estimator, implemented := s.mempool.(FeeEstimatorInterface); implemented {
+		feeRate, ok = estimator.EstimateFeeRate(blocks)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"blocks":   blocks,
+		"fee_rate": feeRate,
+		"fallback": !ok,
+	})
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeEstimatorMempool recommends a fee rate per confirmation target, or
+// reports insufficient history when it has none.
+type fakeEstimatorMempool struct {
+	fakeBatchMempool
+	rates    map[uint64]uint64
+	fallback uint64
+}
+
+func (m *fakeEstimatorMempool) EstimateFeeRate(blocks uint64) (uint64, bool) {
+	if len(m.rates) == 0 {
+		return m.fallback, false
+	}
+	return m.rates[blocks], true
+}
+
+func TestEstimateFeeHandler(t *testing.T) {
+	get := func(server *Server, path string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		var response map[string]interface{}
+		if rr.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+		}
+		return rr, response
+	}
+
+	mp := &fakeEstimatorMempool{rates: map[uint64]uint64{1: 40, DefaultFeeEstimateBlocks: 12}}
+	server := newTestServer(t, &ServerConfig{Chain: NewMockChain(), Mempool: mp})
+
+	rr, response := get(server, "/api/v1/fee/estimate?blocks=1")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, float64(40), response["fee_rate"])
+	assert.Equal(t, float64(1), response["blocks"])
+	assert.Equal(t, false, response["fallback"])
+
+	_, response = get(server, "/api/v1/fee/estimate")
+	assert.Equal(t, float64(12), response["fee_rate"])
+	assert.Equal(t, float64(DefaultFeeEstimateBlocks), response["blocks"])
+
+	rr, _ = get(server, "/api/v1/fee/estimate?blocks=0")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// Without history the mempool's fallback is returned and flagged
+	server = newTestServer(t, &ServerConfig{Chain: NewMockChain(), Mempool: &fakeEstimatorMempool{fallback: 5}})
+	_, response = get(server, "/api/v1/fee/estimate?blocks=3")
+	assert.Equal(t, float64(5), response["fee_rate"])
+	assert.Equal(t, true, response["fallback"])
+
+	// Mempools that cannot estimate fees get the fixed fallback rate
+	server = newTestServer(t, &ServerConfig{Chain: NewMockChain(), Mempool: &fakeBatchMempool{}})
+	_, response = get(server, "/api/v1/fee/estimate?blocks=3")
+	assert.Equal(t, float64(FallbackFeeRate), response["fee_rate"])
+	assert.Equal(t, true, response["fallback"])
+}
@@ -0,0 +1,335 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/proto/rpc"
+	"github.com/palaseus/adrenochain/pkg/utxo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// MempoolInterface defines the mempool operations needed by the API
+type MempoolInterface interface {
+	AddTransaction(tx *block.Transaction) error
+	GetTransactionCount() int
+}
+
+// ErrReadOnly is returned for transaction submissions to a read-only node
+var ErrReadOnly = errors.New("node is read-only, transaction submission is disabled")
+
+// LocalMempool is implemented by mempools that track transactions submitted
+// to this node so they can be rebroadcast until confirmed
+type LocalMempool interface {
+	AddLocalTransaction(tx *block.Transaction) error
+}
+
+// addLocalTransaction submits a transaction received through the API,
+// marking it local when the mempool supports it
+func addLocalTransaction(mp MempoolInterface, tx *block.Transaction) error {
+	if local, ok := mp.(LocalMempool); ok {
+		return local.AddLocalTransaction(tx)
+	}
+	return mp.AddTransaction(tx)
+}
+
+// UTXOInterface defines the UTXO set queries needed by the API
+type UTXOInterface interface {
+	GetBalance(address string) uint64
+	GetAddressUTXOs(address string) []*utxo.UTXO
+}
+
+// GRPCServer serves the node API over gRPC alongside the HTTP server
+type GRPCServer struct {
+	rpc.UnimplementedNodeServiceServer
+
+	mu          sync.Mutex
+	server      *grpc.Server
+	chain       ChainInterface
+	mempool     MempoolInterface
+	utxoSet     UTXOInterface
+	listenAddr  string
+	authToken   string
+	readOnly    bool
+	subscribers map[chan *rpc.Block]struct{}
+}
+
+// GRPCServerConfig holds configuration for the gRPC server
+type GRPCServerConfig struct {
+	// ListenAddr is the address Start listens on. Empty selects
+	// DefaultGRPCListenAddr.
+	ListenAddr string
+	Chain      ChainInterface
+	Mempool    MempoolInterface
+	UTXOSet    UTXOInterface
+	// AuthToken is required from clients as a bearer token in the
+	// authorization metadata of every call. Without one, Start refuses to
+	// listen on addresses other than loopback ones.
+	AuthToken string
+	// ReadOnly refuses transaction submission
+	ReadOnly bool
+}
+
+// DefaultGRPCListenAddr is the address the gRPC server listens on when none
+// is configured, reachable from the local host only
+const DefaultGRPCListenAddr = "127.0.0.1:9091"
+
+// subscriberBuffer is the number of blocks queued for a slow stream before
+// further blocks are dropped for that subscriber
+const subscriberBuffer = 16
+
+// NewGRPCServer creates a new gRPC server
+func NewGRPCServer(config *GRPCServerConfig) *GRPCServer {
+	s := &GRPCServer{
+		chain:       config.Chain,
+		mempool:     config.Mempool,
+		utxoSet:     config.UTXOSet,
+		listenAddr:  config.ListenAddr,
+		authToken:   config.AuthToken,
+		readOnly:    config.ReadOnly,
+		subscribers: make(map[chan *rpc.Block]struct{}),
+	}
+	if s.listenAddr == "" {
+		s.listenAddr = DefaultGRPCListenAddr
+	}
+
+	var opts []grpc.ServerOption
+	if s.authToken != "" {
+		opts = append(opts,
+			grpc.UnaryInterceptor(s.authorizeUnary),
+			grpc.StreamInterceptor(s.authorizeStream),
+		)
+	}
+	s.server = grpc.NewServer(opts...)
+
+	rpc.RegisterNodeServiceServer(s.server, s)
+	return s
+}
+
+// Start listens on the configured address and serves gRPC requests. An
+// address reachable from other hosts requires an auth token.
+func (s *GRPCServer) Start() error {
+	if s.authToken == "" && !isLoopbackAddr(s.listenAddr) {
+		return fmt.Errorf("refusing to serve gRPC on %s without an auth token", s.listenAddr)
+	}
+	lis, err := net.Listen("tcp", s.listenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.listenAddr, err)
+	}
+	fmt.Printf("Starting gRPC server on %s\n", s.listenAddr)
+	return s.Serve(lis)
+}
+
+// ListenAddr returns the address Start listens on
+func (s *GRPCServer) ListenAddr() string {
+	return s.listenAddr
+}
+
+// isLoopbackAddr reports whether a listen address only accepts connections
+// from the local host. An empty host listens on every interface.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// authorize checks the bearer token in the metadata of a call
+func (s *GRPCServer) authorize(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		token := strings.TrimPrefix(value, "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.authToken)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "missing or invalid auth token")
+}
+
+// authorizeUnary refuses unary calls without the auth token
+func (s *GRPCServer) authorizeUnary(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// authorizeStream refuses streaming calls without the auth token
+func (s *GRPCServer) authorizeStream(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.authorize(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// Serve serves gRPC requests on an existing listener
+func (s *GRPCServer) Serve(lis net.Listener) error {
+	return s.server.Serve(lis)
+}
+
+// Stop gracefully stops the server, ending all block subscriptions
+func (s *GRPCServer) Stop() {
+	s.server.GracefulStop()
+}
+
+// PublishBlock pushes a new best block to every SubscribeBlocks stream
+func (s *GRPCServer) PublishBlock(b *block.Block) {
+	if b == nil || b.Header == nil {
+		return
+	}
+	msg := blockToProto(b)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for ch := range s.subscribers {
+		select {
+		case ch <- msg:
+		default:
+			// Subscriber is not keeping up; drop rather than block block processing
+		}
+	}
+}
+
+// GetChainInfo returns general blockchain information
+func (s *GRPCServer) GetChainInfo(ctx context.Context, req *rpc.GetChainInfoRequest) (*rpc.ChainInfo, error) {
+	info := &rpc.ChainInfo{
+		Height: s.chain.GetHeight(),
+	}
+
+	if bestBlock := s.chain.GetBestBlock(); bestBlock != nil {
+		info.BestBlockHash = bestBlock.CalculateHash()
+		info.Difficulty = bestBlock.Header.Difficulty
+		info.NextDifficulty = s.chain.CalculateNextDifficulty()
+	}
+	if genesisBlock := s.chain.GetGenesisBlock(); genesisBlock != nil {
+		info.GenesisBlockHash = genesisBlock.CalculateHash()
+	}
+	if s.mempool != nil {
+		info.MempoolSize = uint64(s.mempool.GetTransactionCount())
+	}
+
+	return info, nil
+}
+
+// GetBlock returns a block by hash or height
+func (s *GRPCServer) GetBlock(ctx context.Context, req *rpc.GetBlockRequest) (*rpc.Block, error) {
+	var b *block.Block
+	switch selector := req.GetSelector().(type) {
+	case *rpc.GetBlockRequest_Hash:
+		b = s.chain.GetBlock(selector.Hash)
+	case *rpc.GetBlockRequest_Height:
+		b = s.chain.GetBlockByHeight(selector.Height)
+	default:
+		return nil, status.Error(codes.InvalidArgument, "block hash or height is required")
+	}
+
+	if b == nil {
+		return nil, status.Error(codes.NotFound, "block not found")
+	}
+
+	return blockToProto(b), nil
+}
+
+// GetBalance returns the confirmed balance of an address
+func (s *GRPCServer) GetBalance(ctx context.Context, req *rpc.GetBalanceRequest) (*rpc.Balance, error) {
+	if req.GetAddress() == "" {
+		return nil, status.Error(codes.InvalidArgument, "address is required")
+	}
+	if s.utxoSet == nil {
+		return nil, status.Error(codes.Unavailable, "UTXO set not available")
+	}
+
+	return &rpc.Balance{
+		Address:   req.GetAddress(),
+		Balance:   s.utxoSet.GetBalance(req.GetAddress()),
+		UtxoCount: uint32(len(s.utxoSet.GetAddressUTXOs(req.GetAddress()))),
+	}, nil
+}
+
+// SendRawTransaction decodes a serialized transaction and submits it to the mempool
+func (s *GRPCServer) SendRawTransaction(ctx context.Context, req *rpc.SendRawTransactionRequest) (*rpc.SendRawTransactionResponse, error) {
+	if s.readOnly {
+		return nil, status.Error(codes.PermissionDenied, ErrReadOnly.Error())
+	}
+	if s.mempool == nil {
+		return nil, status.Error(codes.Unavailable, "mempool not available")
+	}
+
+	tx := &block.Transaction{}
+	if err := tx.Deserialize(req.GetRawTransaction()); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid transaction encoding: %v", err)
+	}
+
+	if err := addLocalTransaction(s.mempool, tx); err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "transaction rejected: %v", err)
+	}
+
+	return &rpc.SendRawTransactionResponse{TxHash: tx.Hash}, nil
+}
+
+// SubscribeBlocks streams new best blocks until the client goes away
+func (s *GRPCServer) SubscribeBlocks(req *rpc.SubscribeBlocksRequest, stream rpc.NodeService_SubscribeBlocksServer) error {
+	ch := make(chan *rpc.Block, subscriberBuffer)
+
+	s.mu.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.subscribers, ch)
+		s.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case msg := <-ch:
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// blockToProto converts a block into its gRPC representation
+func blockToProto(b *block.Block) *rpc.Block {
+	msg := &rpc.Block{
+		Hash:          b.CalculateHash(),
+		Height:        b.Header.Height,
+		Version:       b.Header.Version,
+		PrevBlockHash: b.Header.PrevBlockHash,
+		MerkleRoot:    b.Header.MerkleRoot,
+		Timestamp:     b.Header.Timestamp.Unix(),
+		Difficulty:    b.Header.Difficulty,
+		Nonce:         b.Header.Nonce,
+		Transactions:  make([]*rpc.Transaction, 0, len(b.Transactions)),
+	}
+
+	for _, tx := range b.Transactions {
+		msg.Transactions = append(msg.Transactions, &rpc.Transaction{
+			Hash:        tx.Hash,
+			InputCount:  uint32(len(tx.Inputs)),
+			OutputCount: uint32(len(tx.Outputs)),
+			Fee:         tx.Fee,
+		})
+	}
+
+	return msg
+}
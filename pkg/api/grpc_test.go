@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/mempool"
+	"github.com/palaseus/adrenochain/pkg/proto/rpc"
+	"github.com/palaseus/adrenochain/pkg/utxo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startTestGRPCServer runs a GRPCServer over an in-memory listener and
+// returns a connected client.
+func startTestGRPCServer(t *testing.T, config *GRPCServerConfig) (*GRPCServer, rpc.NodeServiceClient) {
+	lis := bufconn.Listen(1024 * 1024)
+	server := NewGRPCServer(config)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return server, rpc.NewNodeServiceClient(conn)
+}
+
+func TestGRPCGetBlock(t *testing.T) {
+	chain := NewMockChain()
+	_, client := startTestGRPCServer(t, &GRPCServerConfig{Chain: chain})
+	ctx := context.Background()
+
+	byHeight, err := client.GetBlock(ctx, &rpc.GetBlockRequest{Selector: &rpc.GetBlockRequest_Height{Height: 1}})
+	require.NoError(t, err)
+	assert.Equal(t, chain.bestBlock.CalculateHash(), byHeight.Hash)
+	assert.Equal(t, uint64(1), byHeight.Height)
+	assert.Equal(t, uint64(12345), byHeight.Nonce)
+	require.Len(t, byHeight.Transactions, 1)
+	assert.Equal(t, []byte("test-tx-hash"), byHeight.Transactions[0].Hash)
+
+	byHash, err := client.GetBlock(ctx, &rpc.GetBlockRequest{Selector: &rpc.GetBlockRequest_Hash{Hash: byHeight.Hash}})
+	require.NoError(t, err)
+	assert.Equal(t, byHeight.Hash, byHash.Hash)
+
+	_, err = client.GetBlock(ctx, &rpc.GetBlockRequest{Selector: &rpc.GetBlockRequest_Height{Height: 99}})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.GetBlock(ctx, &rpc.GetBlockRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPCSendRawTransaction(t *testing.T) {
+	mp := mempool.NewMempool(mempool.TestMempoolConfig())
+	_, client := startTestGRPCServer(t, &GRPCServerConfig{Chain: NewMockChain(), Mempool: mp})
+	ctx := context.Background()
+
+	tx := &block.Transaction{
+		Version: 1,
+		Inputs: []*block.TxInput{{
+			PrevTxHash:  make([]byte, 32),
+			PrevTxIndex: 0,
+			ScriptSig:   []byte("signature!"),
+			Sequence:    0xffffffff,
+		}},
+		Outputs: []*block.TxOutput{{Value: 1000, ScriptPubKey: []byte("pubkey")}},
+		Fee:     500,
+	}
+	tx.Hash = tx.CalculateHash()
+	raw, err := tx.Serialize()
+	require.NoError(t, err)
+
+	resp, err := client.SendRawTransaction(ctx, &rpc.SendRawTransactionRequest{RawTransaction: raw})
+	require.NoError(t, err)
+	assert.Equal(t, tx.Hash, resp.TxHash)
+	assert.NotNil(t, mp.GetTransaction(tx.Hash))
+
+	// Resubmitting is rejected by the mempool
+	_, err = client.SendRawTransaction(ctx, &rpc.SendRawTransactionRequest{RawTransaction: raw})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	_, err = client.SendRawTransaction(ctx, &rpc.SendRawTransactionRequest{RawTransaction: []byte{0x01}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	info, err := client.GetChainInfo(ctx, &rpc.GetChainInfoRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), info.MempoolSize)
+}
+
+func TestGRPCGetBalance(t *testing.T) {
+	us := utxo.NewUTXOSet()
+	us.AddUTXO(utxo.NewUTXO(make([]byte, 32), 0, 1500, []byte("script"), "addr", false, 1))
+	_, client := startTestGRPCServer(t, &GRPCServerConfig{Chain: NewMockChain(), UTXOSet: us})
+
+	balance, err := client.GetBalance(context.Background(), &rpc.GetBalanceRequest{Address: "addr"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1500), balance.Balance)
+	assert.Equal(t, uint32(1), balance.UtxoCount)
+}
+
+func TestGRPCSubscribeBlocks(t *testing.T) {
+	chain := NewMockChain()
+	server, client := startTestGRPCServer(t, &GRPCServerConfig{Chain: chain})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.SubscribeBlocks(ctx, &rpc.SubscribeBlocksRequest{})
+	require.NoError(t, err)
+
+	// Wait for the subscription to be registered before publishing
+	require.Eventually(t, func() bool {
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		return len(server.subscribers) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	server.PublishBlock(chain.bestBlock)
+
+	msg, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, chain.bestBlock.CalculateHash(), msg.Hash)
+}
+
+func TestGRPCAuthToken(t *testing.T) {
+	_, client := startTestGRPCServer(t, &GRPCServerConfig{Chain: NewMockChain(), AuthToken: "secret"})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := client.GetChainInfo(ctx, &rpc.GetChainInfoRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	wrong := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer wrong")
+	_, err = client.GetChainInfo(wrong, &rpc.GetChainInfoRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	stream, err := client.SubscribeBlocks(wrong, &rpc.SubscribeBlocksRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	authorized := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret")
+	info, err := client.GetChainInfo(authorized, &rpc.GetChainInfoRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), info.Height)
+}
+
+func TestGRPCStartRefusesPublicAddressWithoutToken(t *testing.T) {
+	server := NewGRPCServer(&GRPCServerConfig{Chain: NewMockChain(), ListenAddr: ":0"})
+	err := server.Start()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "without an auth token")
+
+	assert.Equal(t, DefaultGRPCListenAddr, NewGRPCServer(&GRPCServerConfig{Chain: NewMockChain()}).ListenAddr())
+}
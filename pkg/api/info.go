@@ -0,0 +1,54 @@
+package api
+
+import (
+	"fmt"
+	"time"
+)
+
+// ChainInfo is the blockchain information served by /api/v1/chain/info and
+// printed by the CLI info commands. The API knows the details of the loaded
+// chain but not the storage layout, while the CLI reads only the stored chain
+// state, so each leaves the part it cannot provide nil.
+type ChainInfo struct {
+	Height        uint64 `json:"height"`
+	BestBlockHash string `json:"best_block_hash"`
+	*ChainDetails
+	Storage   *StorageInfo `json:"storage,omitempty"`
+	Timestamp string       `json:"timestamp,omitempty"`
+}
+
+// ChainDetails holds the information only a loaded chain provides.
+type ChainDetails struct {
+	BestBlock        string `json:"best_block"` // Same as BestBlockHash, kept for older clients.
+	GenesisBlockHash string `json:"genesis_block_hash"`
+	Difficulty       uint64 `json:"difficulty"`
+	NextDifficulty   uint64 `json:"next_difficulty"`
+}
+
+// StorageInfo describes where and how a node stores its chain.
+type StorageInfo struct {
+	Type       string `json:"type"`
+	DataDir    string `json:"data_dir"`
+	BlockCount int    `json:"block_count"`
+}
+
+// chainInfo returns the information of the served chain.
+func (s *Server) chainInfo() *ChainInfo {
+	info := &ChainInfo{
+		Height:       s.chain.GetHeight(),
+		ChainDetails: &ChainDetails{},
+		Timestamp:    time.Now().UTC().Format(time.RFC3339),
+	}
+
+	if bestBlock := s.chain.GetBestBlock(); bestBlock != nil {
+		info.BestBlockHash = fmt.Sprintf("%x", bestBlock.CalculateHash())
+		info.BestBlock = info.BestBlockHash
+		info.Difficulty = bestBlock.Header.Difficulty
+		info.NextDifficulty = s.chain.CalculateNextDifficulty()
+	}
+	if genesisBlock := s.chain.GetGenesisBlock(); genesisBlock != nil {
+		info.GenesisBlockHash = fmt.Sprintf("%x", genesisBlock.CalculateHash())
+	}
+
+	return info
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/miner"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMiner reports fixed mining statistics.
+type fakeMiner struct {
+	info miner.MiningInfo
+}
+
+func (m *fakeMiner) GetMiningInfo() miner.MiningInfo { return m.info }
+
+func TestGetMiningInfoHandler(t *testing.T) {
+	request := func(server *Server) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/mining/info", nil))
+		return w
+	}
+
+	w := request(newTestServer(t, &ServerConfig{Chain: NewMockChain()}))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	want := miner.MiningInfo{Mining: true, Workers: 1, Height: 7, Difficulty: 4, Target: "0f", BlocksFound: 2, Hashes: 900, HashRate: 450}
+	w = request(newTestServer(t, &ServerConfig{Chain: NewMockChain(), Miner: &fakeMiner{info: want}}))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var got miner.MiningInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, want, got)
+}
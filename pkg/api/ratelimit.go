@@ -0,0 +1,115 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxRateLimitClients bounds the clients tracked by a rate limiter; beyond
+// it, clients whose allowance has fully recovered are forgotten.
+const maxRateLimitClients = 10000
+
+// clientRateLimiter limits the requests of each client to a rate per
+// minute, allowing bursts of up to burst requests. Each client has a token
+// bucket refilled continuously at the rate.
+type clientRateLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // rate is the tokens added per second
+	burst   float64
+	clients map[string]*tokenBucket
+	now     func() time.Time
+}
+
+// tokenBucket is the allowance of one client as of updated.
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// newClientRateLimiter creates a limiter admitting perMinute requests a
+// minute from each client, in bursts of up to burst. Zero burst selects
+// perMinute.
+func newClientRateLimiter(perMinute, burst int) *clientRateLimiter {
+	if burst <= 0 {
+		burst = perMinute
+	}
+	return &clientRateLimiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(burst),
+		clients: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// allow takes a token from the client's bucket. When it is empty it
+// returns false with the time until the next token.
+func (l *clientRateLimiter) allow(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	bucket, exists := l.clients[client]
+	if !exists {
+		if len(l.clients) >= maxRateLimitClients {
+			l.pruneLocked(now)
+		}
+		bucket = &tokenBucket{tokens: l.burst, updated: now}
+		l.clients[client] = bucket
+	}
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.updated).Seconds()*l.rate)
+	bucket.updated = now
+
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// pruneLocked forgets the clients whose bucket has refilled, as a new
+// bucket would be the same.
+// Note: the caller must hold the limiter lock.
+func (l *clientRateLimiter) pruneLocked(now time.Time) {
+	for client, bucket := range l.clients {
+		if bucket.tokens+now.Sub(bucket.updated).Seconds()*l.rate >= l.burst {
+			delete(l.clients, client)
+		}
+	}
+}
+
+// clientKey identifies the client of a request: the value of the
+// configured header when it is set, as put there by an authenticating
+// proxy, and the remote IP otherwise.
+func (s *Server) clientKey(r *http.Request) string {
+	if s.rateLimitKeyHeader != "" {
+		if key := r.Header.Get(s.rateLimitKeyHeader); key != "" {
+			return "key:" + key
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// limitHeavy wraps the handler of an expensive endpoint, refusing requests
+// beyond the client's heavy request allowance with 429 Too Many Requests.
+// The handler is returned as is when the limit is disabled.
+func (s *Server) limitHeavy(next http.HandlerFunc) http.HandlerFunc {
+	if s.heavyLimiter == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ok, retryAfter := s.heavyLimiter.allow(s.clientKey(r)); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
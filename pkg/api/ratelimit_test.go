@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientRateLimiter(t *testing.T) {
+	now := time.Unix(1_000_000, 0)
+	l := newClientRateLimiter(60, 2)
+	l.now = func() time.Time { return now }
+
+	ok, _ := l.allow("a")
+	assert.True(t, ok)
+	ok, _ = l.allow("a")
+	assert.True(t, ok, "bursts up to the burst size are allowed")
+	ok, retryAfter := l.allow("a")
+	assert.False(t, ok)
+	assert.Equal(t, time.Second, retryAfter)
+	ok, _ = l.allow("b")
+	assert.True(t, ok, "clients are limited independently")
+
+	// Tokens come back at the rate
+	now = now.Add(time.Second)
+	ok, _ = l.allow("a")
+	assert.True(t, ok)
+	ok, _ = l.allow("a")
+	assert.False(t, ok)
+
+	// Clients whose allowance has recovered are forgotten when the limiter
+	// is full
+	now = now.Add(time.Minute)
+	l.pruneLocked(now)
+	assert.Empty(t, l.clients)
+
+	assert.Equal(t, float64(60), newClientRateLimiter(60, 0).burst)
+}
+
+func TestHeavyEndpointRateLimit(t *testing.T) {
+	server := newTestServer(t, &ServerConfig{
+		Chain:              NewMockChain(),
+		Wallet:             NewMockWallet(),
+		WalletEndpoints:    true,
+		HeavyRateLimit:     3,
+		RateLimitKeyHeader: "X-API-Key",
+	})
+	handler := server.Handler()
+	get := func(path, apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, get("/api/v1/wallet/balance/addr", "").Code)
+	}
+	w := get("/api/v1/wallet/balance/addr", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "20", w.Header().Get("Retry-After"))
+
+	// Light endpoints remain available to the same client
+	assert.Equal(t, http.StatusOK, get("/api/v1/chain/info", "").Code)
+	assert.Equal(t, http.StatusOK, get("/health", "").Code)
+
+	// A client identified by its API key has its own allowance
+	assert.Equal(t, http.StatusOK, get("/api/v1/wallet/balance/addr", "key-1").Code)
+
+	// The balance RPC method shares the allowance
+	req := httptest.NewRequest("POST", "/rpc", strings.NewReader(`{"jsonrpc":"2.0","method":"getbalance","params":["addr"],"id":1}`))
+	req.RemoteAddr = "192.0.2.1:1234"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "too many requests")
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/palaseus/adrenochain/pkg/block"
+)
+
+// maxRawTxBodySize bounds the body of a raw transaction submission: the hex
+// encoding of the largest transaction the decoder accepts, plus whitespace.
+const maxRawTxBodySize = 2*block.MaxTxEncodedSize + 16
+
+// MempoolLookupInterface is implemented by mempools that can return a
+// pending transaction by hash. The mempool passed in ServerConfig may
+// optionally implement it, letting raw transaction lookups find pending
+// transactions as well as confirmed ones.
+type MempoolLookupInterface interface {
+	GetTransaction(hash []byte) *block.Transaction
+}
+
+// getRawTransactionHandler returns a confirmed or pending transaction hex
+// encoded in the format produced by Transaction.Serialize.
+func (s *Server) getRawTransactionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	hash, err := hex.DecodeString(mux.Vars(r)["hash"])
+	if err != nil {
+		http.Error(w, "Invalid hash format", http.StatusBadRequest)
+		return
+	}
+
+	tx := s.findTransaction(hash)
+	if tx == nil {
+		http.Error(w, "Transaction not found", http.StatusNotFound)
+		return
+	}
+
+	raw, err := tx.Hex()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode transaction: %v", err), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"hash": fmt.Sprintf("%x", tx.Hash),
+		"hex":  raw,
+	})
+}
+
+// submitRawTransactionHandler decodes a hex encoded transaction sent as the
+// request body and adds it to the mempool.
+func (s *Server) submitRawTransactionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if s.readOnly {
+		http.Error(w, ErrReadOnly.Error(), http.StatusForbidden)
+		return
+	}
+	if s.mempool == nil {
+		http.Error(w, "Mempool not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRawTxBodySize+1))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxRawTxBodySize {
+		http.Error(w, "Transaction too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	tx, err := block.DecodeTransactionHex(string(body))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid transaction: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := addLocalTransaction(s.mempool, tx); err != nil {
+		http.Error(w, fmt.Sprintf("Transaction rejected: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"hash":     fmt.Sprintf("%x", tx.Hash),
+		"accepted": true,
+	})
+}
+
+// getRawBlockHandler returns a block hex encoded in the format produced by
+// Block.Serialize.
+func (s *Server) getRawBlockHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	hash, err := hex.DecodeString(mux.Vars(r)["hash"])
+	if err != nil {
+		http.Error(w, "Invalid hash format", http.StatusBadRequest)
+		return
+	}
+
+	b := s.chain.GetBlock(hash)
+	if b == nil {
+		http.Error(w, "Block not found", http.StatusNotFound)
+		return
+	}
+
+	raw, err := b.Hex()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to encode block: %v", err), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"hash":   fmt.Sprintf("%x", b.CalculateHash()),
+		"height": b.Header.Height,
+		"hex":    raw,
+	})
+}
+
+// findTransaction returns the transaction with the given hash from the
+// mempool, if it supports lookups, or from the blocks of the chain.
+func (s *Server) findTransaction(hash []byte) *block.Transaction {
+	if lookup, ok := s.mempool.(MempoolLookupInterface); ok {
+		if tx := lookup.GetTransaction(hash); tx != nil {
+			return tx
+		}
+	}
+
+	height := s.chain.GetHeight()
+	for h := uint64(0); h <= height; h++ {
+		b := s.chain.GetBlockByHeight(h)
+		if b == nil {
+			continue
+		}
+		for _, tx := range b.Transactions {
+			if string(tx.Hash) == string(hash) {
+				return tx
+			}
+		}
+	}
+	return nil
+}
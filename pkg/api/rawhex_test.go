@@ -0,0 +1,128 @@
+package api
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lookupMempool is a fakeBatchMempool that also returns accepted
+// transactions by hash.
+type lookupMempool struct {
+	fakeBatchMempool
+	txs map[string]*block.Transaction
+}
+
+func (m *lookupMempool) AddTransaction(tx *block.Transaction) error {
+	if err := m.fakeBatchMempool.AddTransaction(tx); err != nil {
+		return err
+	}
+	m.txs[string(tx.Hash)] = tx
+	return nil
+}
+
+func (m *lookupMempool) GetTransaction(hash []byte) *block.Transaction {
+	return m.txs[string(hash)]
+}
+
+var _ MempoolLookupInterface = (*lookupMempool)(nil)
+
+func TestRawTransactionRoundTrip(t *testing.T) {
+	funded := bytes.Repeat([]byte{0x11}, 32)
+	mp := &lookupMempool{
+		fakeBatchMempool: fakeBatchMempool{known: map[string]bool{string(funded): true}},
+		txs:              make(map[string]*block.Transaction),
+	}
+	server := newTestServer(t, &ServerConfig{Chain: NewMockChain(), Mempool: mp})
+
+	tx, raw := batchTestTx(t, funded, 100, "raw")
+	req := httptest.NewRequest("POST", "/api/v1/transactions", strings.NewReader(raw+"\n"))
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var submitted map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &submitted))
+	assert.Equal(t, fmt.Sprintf("%x", tx.Hash), submitted["hash"])
+	assert.Equal(t, true, submitted["accepted"])
+
+	req = httptest.NewRequest("GET", fmt.Sprintf("/api/v1/transactions/%x/hex", tx.Hash), nil)
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var fetched map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &fetched))
+	assert.Equal(t, raw, fetched["hex"])
+
+	decoded, err := block.DecodeTransactionHex(fetched["hex"])
+	require.NoError(t, err)
+	assert.Equal(t, tx.Hash, decoded.Hash)
+
+	// Malformed and rejected submissions are reported as bad requests
+	_, noFeeRaw := batchTestTx(t, funded, 0, "nofee")
+	for _, body := range []string{"zz-not-hex", hex.EncodeToString([]byte{0x00, 0x01}), noFeeRaw} {
+		req = httptest.NewRequest("POST", "/api/v1/transactions", strings.NewReader(body))
+		w = httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+	assert.Equal(t, 1, mp.GetTransactionCount())
+
+	req = httptest.NewRequest("GET", "/api/v1/transactions/00ff/hex", nil)
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRawBlockHex(t *testing.T) {
+	chain := NewMockChain()
+	server := newTestServer(t, &ServerConfig{Chain: chain})
+
+	tx, _ := batchTestTx(t, bytes.Repeat([]byte{0x11}, 32), 100, "block")
+	b := &block.Block{
+		Header: &block.Header{
+			Version:       1,
+			PrevBlockHash: chain.GetBestBlock().CalculateHash(),
+			Timestamp:     time.Unix(1700000000, 0),
+			Difficulty:    1,
+			Height:        2,
+		},
+		Transactions: []*block.Transaction{tx},
+	}
+	b.Header.MerkleRoot = b.CalculateMerkleRoot()
+	chain.blocks[b.HexHash()] = b
+
+	req := httptest.NewRequest("GET", "/api/v1/blocks/"+b.HexHash()+"/hex", nil)
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, b.HexHash(), resp["hash"])
+	assert.Equal(t, float64(2), resp["height"])
+
+	data, err := hex.DecodeString(resp["hex"].(string))
+	require.NoError(t, err)
+	decoded := &block.Block{}
+	require.NoError(t, decoded.Deserialize(data))
+	assert.Equal(t, b.CalculateHash(), decoded.CalculateHash())
+	require.Len(t, decoded.Transactions, 1)
+	assert.Equal(t, tx.Hash, decoded.Transactions[0].Hash)
+
+	req = httptest.NewRequest("GET", "/api/v1/blocks/00ff/hex", nil)
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
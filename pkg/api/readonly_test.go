@@ -0,0 +1,49 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/proto/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestReadOnlyRefusesSubmission(t *testing.T) {
+	funded := bytes.Repeat([]byte{0x11}, 32)
+	mp := &fakeBatchMempool{known: map[string]bool{string(funded): true}}
+	server := newTestServer(t, &ServerConfig{Chain: NewMockChain(), Mempool: mp, ReadOnly: true})
+
+	_, raw := batchTestTx(t, funded, 100, "readonly")
+	req := httptest.NewRequest("POST", "/api/v1/transactions", strings.NewReader(raw))
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), ErrReadOnly.Error())
+
+	req = httptest.NewRequest("POST", "/api/v1/transactions/batch", strings.NewReader(`{"transactions":["`+raw+`"]}`))
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, mp.accepted)
+
+	// Queries keep working
+	for _, path := range []string{"/api/v1/blocks/latest", "/api/v1/blocks/height/1", "/api/v1/chain/info"} {
+		req = httptest.NewRequest("GET", path, nil)
+		w = httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, path)
+	}
+
+	_, client := startTestGRPCServer(t, &GRPCServerConfig{Chain: NewMockChain(), Mempool: mp, ReadOnly: true})
+	_, err := client.SendRawTransaction(context.Background(), &rpc.SendRawTransactionRequest{RawTransaction: []byte(raw)})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = client.GetBlock(context.Background(), &rpc.GetBlockRequest{Selector: &rpc.GetBlockRequest_Height{Height: 1}})
+	require.NoError(t, err)
+}
@@ -0,0 +1,317 @@
+package api
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+)
+
+// DefaultMaxRPCBatchSize is the default maximum number of calls in one
+// JSON-RPC batch request.
+const DefaultMaxRPCBatchSize = 100
+
+// JSON-RPC 2.0 error codes. rpcServerError reports a call the node refused,
+// such as a rejected transaction or a missing block.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+	rpcServerError    = -32000
+)
+
+// rpcRequest is a JSON-RPC 2.0 call. Params are passed by position as an
+// array or by name as an object.
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcResponse is the response to a JSON-RPC 2.0 call, carrying either a
+// result or an error. ID echoes the id of the call, null if it could not be
+// read.
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// rpcError is the error object of a failed JSON-RPC call.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// rpcMethod serves one JSON-RPC method.
+type rpcMethod func(s *Server, params json.RawMessage) (interface{}, *rpcError)
+
+// rpcMethods maps the JSON-RPC method names to the existing API logic.
+var rpcMethods = map[string]rpcMethod{
+	"getblock":        (*Server).rpcGetBlock,
+	"getbalance":      (*Server).rpcGetBalance,
+	"sendtransaction": (*Server).rpcSendTransaction,
+	"getchaininfo":    (*Server).rpcGetChainInfo,
+}
+
+// rpcHeavyMethods are the methods counted against the client's heavy
+// request allowance, as their REST endpoints are.
+var rpcHeavyMethods = map[string]bool{
+	"getbalance": true,
+}
+
+// rpcHandler serves JSON-RPC 2.0 requests. A batch is an array of calls
+// answered by an array of responses in the same order; a malformed call gets
+// its own error response without failing the rest of the batch. Calls
+// without an id are notifications and get no response, so a request made
+// only of notifications is answered with 204 No Content.
+func (s *Server) rpcHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	body = bytes.TrimSpace(body)
+	client := s.clientKey(r)
+	if !json.Valid(body) {
+		json.NewEncoder(w).Encode(newRPCErrorResponse(nil, rpcParseError, "parse error"))
+		return
+	}
+
+	if body[0] != '[' {
+		if response := s.handleRPCCall(body, client); response != nil {
+			json.NewEncoder(w).Encode(response)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+		return
+	}
+
+	var calls []json.RawMessage
+	if err := json.Unmarshal(body, &calls); err != nil {
+		json.NewEncoder(w).Encode(newRPCErrorResponse(nil, rpcParseError, "parse error"))
+		return
+	}
+	if len(calls) == 0 {
+		json.NewEncoder(w).Encode(newRPCErrorResponse(nil, rpcInvalidRequest, "empty batch"))
+		return
+	}
+	if len(calls) > s.maxRPCBatchSize {
+		json.NewEncoder(w).Encode(newRPCErrorResponse(nil, rpcInvalidRequest,
+			fmt.Sprintf("batch of %d calls exceeds maximum %d", len(calls), s.maxRPCBatchSize)))
+		return
+	}
+
+	responses := make([]*rpcResponse, 0, len(calls))
+	for _, call := range calls {
+		if response := s.handleRPCCall(call, client); response != nil {
+			responses = append(responses, response)
+		}
+	}
+	if len(responses) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	json.NewEncoder(w).Encode(responses)
+}
+
+// handleRPCCall runs one JSON-RPC call made by client, returning nil for a
+// valid notification.
+func (s *Server) handleRPCCall(raw json.RawMessage, client string) *rpcResponse {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil || fields == nil {
+		return newRPCErrorResponse(nil, rpcInvalidRequest, "request is not an object")
+	}
+
+	id, hasID := fields["id"]
+	if hasID && !validRPCID(id) {
+		return newRPCErrorResponse(nil, rpcInvalidRequest, "id must be a string, a number or null")
+	}
+
+	var req rpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return newRPCErrorResponse(id, rpcInvalidRequest, fmt.Sprintf("invalid request: %v", err))
+	}
+	if req.JSONRPC != "2.0" {
+		return newRPCErrorResponse(id, rpcInvalidRequest, `jsonrpc must be "2.0"`)
+	}
+	if req.Method == "" {
+		return newRPCErrorResponse(id, rpcInvalidRequest, "method is missing")
+	}
+
+	var response *rpcResponse
+	if method, ok := rpcMethods[req.Method]; !ok {
+		response = newRPCErrorResponse(id, rpcMethodNotFound, fmt.Sprintf("method %q not found", req.Method))
+	} else if !s.allowRPCMethod(req.Method, client) {
+		response = newRPCErrorResponse(id, rpcServerError, "too many requests")
+	} else if result, rpcErr := method(s, req.Params); rpcErr != nil {
+		response = &rpcResponse{JSONRPC: "2.0", Error: rpcErr, ID: id}
+	} else {
+		response = &rpcResponse{JSONRPC: "2.0", Result: result, ID: id}
+	}
+	if !hasID {
+		return nil
+	}
+	return response
+}
+
+// allowRPCMethod reports whether client may call method, taking from its
+// heavy request allowance for heavy methods.
+func (s *Server) allowRPCMethod(method, client string) bool {
+	if s.heavyLimiter == nil || !rpcHeavyMethods[method] {
+		return true
+	}
+	ok, _ := s.heavyLimiter.allow(client)
+	return ok
+}
+
+// newRPCErrorResponse returns an error response to the call with the given
+// id, null when nil.
+func newRPCErrorResponse(id json.RawMessage, code int, message string) *rpcResponse {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &rpcResponse{JSONRPC: "2.0", Error: &rpcError{Code: code, Message: message}, ID: id}
+}
+
+// validRPCID reports whether id is a string, a number or null.
+func validRPCID(id json.RawMessage) bool {
+	var v interface{}
+	if err := json.Unmarshal(id, &v); err != nil {
+		return false
+	}
+	switch v.(type) {
+	case string, float64, nil:
+		return true
+	}
+	return false
+}
+
+// rpcParam returns the parameter at index when params are passed by
+// position, or the one with the given name when they are passed by name.
+func rpcParam(params json.RawMessage, index int, name string) (json.RawMessage, bool) {
+	params = bytes.TrimSpace(params)
+	if len(params) == 0 {
+		return nil, false
+	}
+	switch params[0] {
+	case '[':
+		var positional []json.RawMessage
+		if json.Unmarshal(params, &positional) != nil || index >= len(positional) {
+			return nil, false
+		}
+		return positional[index], true
+	case '{':
+		var named map[string]json.RawMessage
+		if json.Unmarshal(params, &named) != nil {
+			return nil, false
+		}
+		value, ok := named[name]
+		return value, ok
+	}
+	return nil, false
+}
+
+// rpcStringParam returns a string parameter, see rpcParam.
+func rpcStringParam(params json.RawMessage, index int, name string) (string, *rpcError) {
+	raw, ok := rpcParam(params, index, name)
+	if !ok {
+		return "", &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf("missing parameter %s", name)}
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf("parameter %s must be a string", name)}
+	}
+	return value, nil
+}
+
+// rpcGetBlock returns a block, in the format of /api/v1/blocks/{hash}, by
+// its hash or by its height: the first positional parameter is a hash when
+// it is a string and a height when it is a number, named parameters are
+// "hash" or "height".
+func (s *Server) rpcGetBlock(params json.RawMessage) (interface{}, *rpcError) {
+	raw, ok := rpcParam(params, 0, "hash")
+	if !ok {
+		raw, ok = rpcParam(params, 0, "height")
+	}
+	if !ok {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: "missing parameter hash or height"}
+	}
+
+	var b *block.Block
+	var hashHex string
+	var height uint64
+	if err := json.Unmarshal(raw, &hashHex); err == nil {
+		hash, err := hex.DecodeString(hashHex)
+		if err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "invalid hash format"}
+		}
+		b = s.chain.GetBlock(hash)
+	} else if err := json.Unmarshal(raw, &height); err == nil {
+		b = s.chain.GetBlockByHeight(height)
+	} else {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: "block must be given by a hash string or a height"}
+	}
+	if b == nil {
+		return nil, &rpcError{Code: rpcServerError, Message: "block not found"}
+	}
+	return blockInfo(b), nil
+}
+
+// rpcGetBalance returns the balance of the address given as parameter
+// "address".
+func (s *Server) rpcGetBalance(params json.RawMessage) (interface{}, *rpcError) {
+	address, rpcErr := rpcStringParam(params, 0, "address")
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	if s.utxoSet == nil && s.wallet == nil {
+		return nil, &rpcError{Code: rpcServerError, Message: "balances not available"}
+	}
+	return map[string]interface{}{
+		"address": address,
+		"balance": s.balance(address),
+	}, nil
+}
+
+// rpcSendTransaction adds the hex encoded transaction given as parameter
+// "hex" to the mempool, like a POST to /api/v1/transactions.
+func (s *Server) rpcSendTransaction(params json.RawMessage) (interface{}, *rpcError) {
+	raw, rpcErr := rpcStringParam(params, 0, "hex")
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	if s.readOnly {
+		return nil, &rpcError{Code: rpcServerError, Message: ErrReadOnly.Error()}
+	}
+	if s.mempool == nil {
+		return nil, &rpcError{Code: rpcInternalError, Message: "mempool not available"}
+	}
+
+	tx, err := block.DecodeTransactionHex(raw)
+	if err != nil {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf("invalid transaction: %v", err)}
+	}
+	if err := addLocalTransaction(s.mempool, tx); err != nil {
+		return nil, &rpcError{Code: rpcServerError, Message: fmt.Sprintf("transaction rejected: %v", err)}
+	}
+	return map[string]interface{}{
+		"hash":     fmt.Sprintf("%x", tx.Hash),
+		"accepted": true,
+	}, nil
+}
+
+// rpcGetChainInfo returns the chain information served by
+// /api/v1/chain/info. It takes no parameters.
+func (s *Server) rpcGetChainInfo(json.RawMessage) (interface{}, *rpcError) {
+	return s.chainInfo(), nil
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// postRPC sends a JSON-RPC request body to the server.
+func postRPC(server *Server, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("POST", "/rpc", strings.NewReader(body)))
+	return w
+}
+
+func TestRPCSingleRequest(t *testing.T) {
+	mc := NewMockChain()
+	server := newTestServer(t, &ServerConfig{Chain: mc, Wallet: NewMockWallet()})
+
+	w := postRPC(server, `{"jsonrpc": "2.0", "method": "getchaininfo", "id": 7}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Result ChainInfo       `json:"result"`
+		Error  *rpcError       `json:"error"`
+		ID     json.RawMessage `json:"id"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Nil(t, response.Error)
+	assert.Equal(t, "7", string(response.ID))
+	assert.Equal(t, mc.GetHeight(), response.Result.Height)
+
+	w = postRPC(server, `{"jsonrpc": "2.0", "method": "getbalance", "params": {"address": "test-address-2"}, "id": "b"}`)
+	assert.JSONEq(t, `{"jsonrpc": "2.0", "result": {"address": "test-address-2", "balance": 2500}, "id": "b"}`, w.Body.String())
+
+	// Notifications get no response
+	w = postRPC(server, `{"jsonrpc": "2.0", "method": "getchaininfo"}`)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	w = postRPC(server, `{"jsonrpc": "2.0", "method": `)
+	assert.JSONEq(t, `{"jsonrpc": "2.0", "error": {"code": -32700, "message": "parse error"}, "id": null}`, w.Body.String())
+}
+
+func TestRPCBatch(t *testing.T) {
+	mc := NewMockChain()
+	funded := bytes.Repeat([]byte{0x11}, 32)
+	mp := &fakeBatchMempool{known: map[string]bool{string(funded): true}}
+	server := newTestServer(t, &ServerConfig{Chain: mc, Mempool: mp, MaxRPCBatchSize: 10})
+
+	genesis := mc.GetGenesisBlock()
+	tx, raw := batchTestTx(t, funded, 100, "rpc")
+	_, noFeeRaw := batchTestTx(t, funded, 0, "nofee")
+	w := postRPC(server, fmt.Sprintf(`[
+		{"jsonrpc": "2.0", "method": "getblock", "params": ["%x"], "id": 1},
+		{"jsonrpc": "2.0", "method": "getblock", "params": {"height": 0}, "id": 2},
+		{"jsonrpc": "2.0", "method": "sendtransaction", "params": ["%s"], "id": "send"},
+		{"jsonrpc": "2.0", "method": "sendtransaction", "params": ["%s"], "id": "nofee"},
+		{"jsonrpc": "2.0", "method": "getchaininfo"},
+		{"jsonrpc": "2.0", "method": "nosuchmethod", "id": 3},
+		{"jsonrpc": "1.0", "method": "getchaininfo", "id": 4},
+		{"jsonrpc": "2.0", "method": "getblock", "params": [], "id": 5},
+		{"jsonrpc": "2.0", "method": "getblock", "params": ["00ff"], "id": 6},
+		42
+	]`, genesis.CalculateHash(), raw, noFeeRaw))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var responses []rpcResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &responses))
+	require.Len(t, responses, 9, "the notification gets no response")
+
+	ids := make([]string, len(responses))
+	for i, response := range responses {
+		ids[i] = string(response.ID)
+	}
+	assert.Equal(t, []string{`1`, `2`, `"send"`, `"nofee"`, `3`, `4`, `5`, `6`, `null`}, ids)
+
+	hash := fmt.Sprintf("%x", genesis.CalculateHash())
+	for _, response := range responses[:3] {
+		require.Nil(t, response.Error, string(response.ID))
+	}
+	assert.Equal(t, hash, responses[0].Result.(map[string]interface{})["hash"])
+	assert.Equal(t, hash, responses[1].Result.(map[string]interface{})["hash"])
+	assert.Equal(t, fmt.Sprintf("%x", tx.Hash), responses[2].Result.(map[string]interface{})["hash"])
+	assert.Equal(t, 1, mp.GetTransactionCount())
+
+	codes := make([]int, 0)
+	for _, response := range responses[3:] {
+		require.NotNil(t, response.Error, string(response.ID))
+		codes = append(codes, response.Error.Code)
+	}
+	assert.Equal(t, []int{rpcServerError, rpcMethodNotFound, rpcInvalidRequest, rpcInvalidParams, rpcServerError, rpcInvalidRequest}, codes)
+
+	// An empty or oversized batch is a single invalid request
+	for _, body := range []string{`[]`, "[" + strings.TrimSuffix(strings.Repeat(`{"jsonrpc": "2.0", "method": "getchaininfo", "id": 1},`, 11), ",") + "]"} {
+		var response rpcResponse
+		require.NoError(t, json.Unmarshal(postRPC(server, body).Body.Bytes(), &response))
+		require.NotNil(t, response.Error)
+		assert.Equal(t, rpcInvalidRequest, response.Error.Code)
+	}
+
+	// A batch of notifications gets no response
+	w = postRPC(server, `[{"jsonrpc": "2.0", "method": "getchaininfo"}]`)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestRPCReadOnly(t *testing.T) {
+	funded := bytes.Repeat([]byte{0x11}, 32)
+	mp := &fakeBatchMempool{known: map[string]bool{string(funded): true}}
+	server := newTestServer(t, &ServerConfig{Chain: NewMockChain(), Mempool: mp, ReadOnly: true})
+
+	_, raw := batchTestTx(t, funded, 100, "readonly")
+	var response rpcResponse
+	require.NoError(t, json.Unmarshal(postRPC(server, `{"jsonrpc": "2.0", "method": "sendtransaction", "params": {"hex": "`+raw+`"}, "id": 1}`).Body.Bytes(), &response))
+	require.NotNil(t, response.Error)
+	assert.Equal(t, ErrReadOnly.Error(), response.Error.Message)
+	assert.Zero(t, mp.GetTransactionCount())
+}
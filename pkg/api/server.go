@@ -10,6 +10,8 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/miner"
+	"github.com/palaseus/adrenochain/pkg/utxo"
 	"github.com/palaseus/adrenochain/pkg/wallet"
 )
 
@@ -23,6 +25,25 @@ type ChainInterface interface {
 	CalculateNextDifficulty() uint64
 }
 
+// ChainAdminInterface defines administrative chain operations. The chain
+// passed in ServerConfig may optionally implement it.
+type ChainAdminInterface interface {
+	InvalidateBlock(hash []byte) error
+	ReconsiderBlock(hash []byte) error
+}
+
+// ChainWarningsInterface is optionally implemented by chains that raise
+// warnings for operators; they are served with the chain status.
+type ChainWarningsInterface interface {
+	GetWarnings() []string
+}
+
+// ChainTxIndexInterface is optionally implemented by chains that index the
+// transactions of the active chain by hash.
+type ChainTxIndexInterface interface {
+	GetTxBlockHash(txHash []byte) ([]byte, bool)
+}
+
 // WalletInterface defines the interface for wallet operations
 type WalletInterface interface {
 	GetBalance(address string) uint64
@@ -35,13 +56,36 @@ type NetworkInterface interface {
 	GetPeerCount() int
 }
 
+// MinerInterface defines the miner statistics served by the API
+type MinerInterface interface {
+	GetMiningInfo() miner.MiningInfo
+}
+
 // Server represents the HTTP API server
 type Server struct {
-	router  *mux.Router
-	chain   ChainInterface
-	wallet  WalletInterface
-	network NetworkInterface
-	port    int
+	router         *mux.Router
+	chain          ChainInterface
+	wallet         WalletInterface
+	network        NetworkInterface
+	mempool        MempoolInterface
+	miner          MinerInterface
+	utxoSet        *utxo.UTXOSet // utxoSet provides address balances, nil to ask the wallet
+	port           int
+	maxTxBatchSize int
+
+	maxBlockPageSize int // maxBlockPageSize caps the blocks in one page of the block listing
+	maxRPCBatchSize  int // maxRPCBatchSize caps the calls in one JSON-RPC batch
+
+	cache              *responseCache // cache holds responses for final resources, nil if disabled
+	cacheConfirmations uint64
+
+	limiter *connectionLimiter // limiter caps concurrent requests, nil if unlimited
+
+	heavyLimiter       *clientRateLimiter // heavyLimiter limits each client's address queries, nil if unlimited
+	rateLimitKeyHeader string
+
+	readOnly   bool   // readOnly refuses transaction submission and block administration
+	adminToken string // adminToken authorizes block administration, empty for loopback only
 }
 
 // ServerConfig holds configuration for the API server
@@ -50,25 +94,111 @@ type ServerConfig struct {
 	Chain   ChainInterface
 	Wallet  WalletInterface
 	Network NetworkInterface
+	Mempool MempoolInterface
+	Miner   MinerInterface
+	// UTXOSet is the node's UTXO set, from which the balance endpoint reads
+	// the balance of an address. Nil reports the balance the wallet records.
+	UTXOSet *utxo.UTXOSet
+	// WalletEndpoints serves the /api/v1/wallet endpoints, which require a
+	// Wallet.
+	WalletEndpoints bool
+	// MaxTxBatchSize is the maximum number of transactions in one batch
+	// submission. Zero selects DefaultMaxTxBatchSize.
+	MaxTxBatchSize int
+	// MaxBlockPageSize is the maximum number of blocks in one page of the
+	// block listing. Zero selects DefaultMaxBlockPageSize.
+	MaxBlockPageSize int
+	// MaxRPCBatchSize is the maximum number of calls in one JSON-RPC batch
+	// request. Zero selects DefaultMaxRPCBatchSize.
+	MaxRPCBatchSize int
+	// ResponseCacheSize is the number of responses for final blocks and
+	// transactions kept in memory and served with long cache headers.
+	// Responses depending on the tip or the mempool are never cached. Zero
+	// disables the cache.
+	ResponseCacheSize int
+	// CacheConfirmations is the number of confirmations after which a block
+	// and its transactions are final and their responses may be cached.
+	// Zero selects DefaultCacheConfirmations.
+	CacheConfirmations uint64
+	// MaxConcurrentConnections is the maximum number of requests served at
+	// once; further requests are refused with 503 until one completes. Zero
+	// disables the limit.
+	MaxConcurrentConnections int
+	// HeavyRateLimit is the number of requests a minute to expensive
+	// address queries, such as balances, served to each client apart from
+	// the concurrent connection limit; further ones are refused with 429
+	// while other endpoints remain available. Zero disables the limit.
+	HeavyRateLimit int
+	// HeavyRateBurst is the number of address requests a client may make
+	// at once before being held to HeavyRateLimit. Zero selects
+	// HeavyRateLimit.
+	HeavyRateBurst int
+	// RateLimitKeyHeader names a request header identifying clients, such
+	// as an API key set by an authenticating proxy. Requests without it,
+	// or all requests when empty, are limited by remote IP.
+	RateLimitKeyHeader string
+	// ReadOnly refuses transaction submission and block administration
+	// while keeping every query available.
+	ReadOnly bool
+	// AdminToken authorizes block administration requests carrying it as
+	// a bearer token. Empty serves them only to loopback clients.
+	AdminToken string
 }
 
 // NewServer creates a new API server
-func NewServer(config *ServerConfig) *Server {
+func NewServer(config *ServerConfig) (*Server, error) {
+	if config.WalletEndpoints && config.Wallet == nil {
+		return nil, fmt.Errorf("wallet endpoints are enabled but no wallet is configured")
+	}
+
 	router := mux.NewRouter()
 	server := &Server{
-		router:  router,
-		chain:   config.Chain,
-		wallet:  config.Wallet,
-		network: config.Network,
-		port:    config.Port,
+		router:         router,
+		chain:          config.Chain,
+		wallet:         config.Wallet,
+		network:        config.Network,
+		mempool:        config.Mempool,
+		miner:          config.Miner,
+		utxoSet:        config.UTXOSet,
+		port:           config.Port,
+		maxTxBatchSize: config.MaxTxBatchSize,
+		readOnly:       config.ReadOnly,
+		adminToken:     config.AdminToken,
+
+		maxBlockPageSize: config.MaxBlockPageSize,
+		maxRPCBatchSize:  config.MaxRPCBatchSize,
+
+		rateLimitKeyHeader: config.RateLimitKeyHeader,
+	}
+	if server.maxTxBatchSize <= 0 {
+		server.maxTxBatchSize = DefaultMaxTxBatchSize
+	}
+	if server.maxBlockPageSize <= 0 {
+		server.maxBlockPageSize = DefaultMaxBlockPageSize
+	}
+	if server.maxRPCBatchSize <= 0 {
+		server.maxRPCBatchSize = DefaultMaxRPCBatchSize
+	}
+	if config.ResponseCacheSize > 0 {
+		server.cache = newResponseCache(config.ResponseCacheSize)
+		server.cacheConfirmations = config.CacheConfirmations
+		if server.cacheConfirmations == 0 {
+			server.cacheConfirmations = DefaultCacheConfirmations
+		}
+	}
+	if config.MaxConcurrentConnections > 0 {
+		server.limiter = newConnectionLimiter(config.MaxConcurrentConnections)
+	}
+	if config.HeavyRateLimit > 0 {
+		server.heavyLimiter = newClientRateLimiter(config.HeavyRateLimit, config.HeavyRateBurst)
 	}
 
-	server.setupRoutes()
-	return server
+	server.setupRoutes(config.WalletEndpoints)
+	return server, nil
 }
 
 // setupRoutes configures all the API routes
-func (s *Server) setupRoutes() {
+func (s *Server) setupRoutes(walletEndpoints bool) {
 	// Health check
 	s.router.HandleFunc("/health", s.healthHandler).Methods("GET")
 
@@ -78,17 +208,35 @@ func (s *Server) setupRoutes() {
 	s.router.HandleFunc("/api/v1/chain/status", s.getChainStatusHandler).Methods("GET")
 
 	// Block operations
+	s.router.HandleFunc("/api/v1/blocks", s.listBlocksHandler).Methods("GET")
 	s.router.HandleFunc("/api/v1/blocks/latest", s.getLatestBlockHandler).Methods("GET")
-	s.router.HandleFunc("/api/v1/blocks/height/{height}", s.getBlockByHeightHandler).Methods("GET")
-	s.router.HandleFunc("/api/v1/blocks/{hash}", s.getBlockHandler).Methods("GET")
+	s.router.HandleFunc("/api/v1/blocks/height/{height}", s.cacheFinal(s.getBlockByHeightHandler, s.finalBlockByHeight)).Methods("GET")
+	s.router.HandleFunc("/api/v1/blocks/{hash}", s.cacheFinal(s.getBlockHandler, s.finalBlockByHash)).Methods("GET")
+	s.router.HandleFunc("/api/v1/blocks/{hash}/hex", s.cacheFinal(s.getRawBlockHandler, s.finalBlockByHash)).Methods("GET")
+	s.router.HandleFunc("/api/v1/blocks/{hash}/invalidate", s.requireAdmin(s.invalidateBlockHandler)).Methods("POST")
+	s.router.HandleFunc("/api/v1/blocks/{hash}/reconsider", s.requireAdmin(s.reconsiderBlockHandler)).Methods("POST")
 
 	// Transaction operations
-	s.router.HandleFunc("/api/v1/transactions/{hash}", s.getTransactionHandler).Methods("GET")
+	s.router.HandleFunc("/api/v1/transactions", s.submitRawTransactionHandler).Methods("POST")
+	s.router.HandleFunc("/api/v1/transactions/{hash}", s.cacheFinal(s.getTransactionHandler, s.finalTransactionBlock)).Methods("GET")
+	s.router.HandleFunc("/api/v1/transactions/{hash}/hex", s.cacheFinal(s.getRawTransactionHandler, s.finalTransactionBlock)).Methods("GET")
 	s.router.HandleFunc("/api/v1/transactions/pending", s.getPendingTransactionsHandler).Methods("GET")
+	s.router.HandleFunc("/api/v1/transactions/batch", s.submitTxBatchHandler).Methods("POST")
+
+	// Fee estimation
+	s.router.HandleFunc("/api/v1/fee/estimate", s.estimateFeeHandler).Methods("GET")
+
+	// Mining
+	s.router.HandleFunc("/api/v1/mining/info", s.getMiningInfoHandler).Methods("GET")
+
+	// JSON-RPC 2.0, single and batch requests
+	s.router.HandleFunc("/rpc", s.rpcHandler).Methods("POST")
 
 	// Wallet operations
-	s.router.HandleFunc("/api/v1/wallet/balance/{address}", s.getBalanceHandler).Methods("GET")
-	s.router.HandleFunc("/api/v1/wallet/accounts", s.getAccountsHandler).Methods("GET")
+	if walletEndpoints {
+		s.router.HandleFunc("/api/v1/wallet/balance/{address}", s.limitHeavy(s.getBalanceHandler)).Methods("GET")
+		s.router.HandleFunc("/api/v1/wallet/accounts", s.getAccountsHandler).Methods("GET")
+	}
 
 	// Network operations
 	s.router.HandleFunc("/api/v1/network/peers", s.getPeersHandler).Methods("GET")
@@ -99,7 +247,7 @@ func (s *Server) setupRoutes() {
 func (s *Server) Start() error {
 	addr := fmt.Sprintf(":%d", s.port)
 	fmt.Printf("Starting API server on port %d\n", s.port)
-	return http.ListenAndServe(addr, s.router)
+	return http.ListenAndServe(addr, s.Handler())
 }
 
 // healthHandler provides a simple health check endpoint
@@ -115,35 +263,7 @@ func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
 // getChainInfoHandler returns general blockchain information
 func (s *Server) getChainInfoHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-
-	bestBlock := s.chain.GetBestBlock()
-	genesisBlock := s.chain.GetGenesisBlock()
-
-	info := map[string]interface{}{
-		"height":    s.chain.GetHeight(),
-		"timestamp": time.Now().UTC().Format(time.RFC3339),
-	}
-
-	// Add block-specific information if available
-	if bestBlock != nil {
-		info["best_block"] = fmt.Sprintf("%x", bestBlock.CalculateHash())
-		info["best_block_hash"] = fmt.Sprintf("%x", bestBlock.CalculateHash())
-		info["difficulty"] = bestBlock.Header.Difficulty
-		info["next_difficulty"] = s.chain.CalculateNextDifficulty()
-	} else {
-		info["best_block"] = ""
-		info["best_block_hash"] = ""
-		info["difficulty"] = uint64(0)
-		info["next_difficulty"] = uint64(0)
-	}
-
-	if genesisBlock != nil {
-		info["genesis_block_hash"] = fmt.Sprintf("%x", genesisBlock.CalculateHash())
-	} else {
-		info["genesis_block_hash"] = ""
-	}
-
-	json.NewEncoder(w).Encode(info)
+	json.NewEncoder(w).Encode(s.chainInfo())
 }
 
 // getChainHeightHandler returns the current blockchain height
@@ -186,6 +306,14 @@ func (s *Server) getChainStatusHandler(w http.ResponseWriter, r *http.Request) {
 		status["genesis_block_hash"] = ""
 	}
 
+	if reporter, ok := s.chain.(ChainWarningsInterface); ok {
+		warnings := reporter.GetWarnings()
+		if warnings == nil {
+			warnings = []string{}
+		}
+		status["warnings"] = warnings
+	}
+
 	json.NewEncoder(w).Encode(status)
 }
 
@@ -209,30 +337,7 @@ func (s *Server) getBlockHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Convert block to JSON-friendly format
-	blockInfo := map[string]interface{}{
-		"hash":         fmt.Sprintf("%x", block.CalculateHash()),
-		"height":       block.Header.Height,
-		"version":      block.Header.Version,
-		"prev_hash":    fmt.Sprintf("%x", block.Header.PrevBlockHash),
-		"merkle_root":  fmt.Sprintf("%x", block.Header.MerkleRoot),
-		"timestamp":    block.Header.Timestamp.Format(time.RFC3339),
-		"difficulty":   block.Header.Difficulty,
-		"nonce":        block.Header.Nonce,
-		"tx_count":     len(block.Transactions),
-		"transactions": make([]map[string]interface{}, 0),
-	}
-
-	// Add transaction hashes
-	for _, tx := range block.Transactions {
-		txInfo := map[string]interface{}{
-			"hash": fmt.Sprintf("%x", tx.Hash),
-			"type": "transaction",
-		}
-		blockInfo["transactions"] = append(blockInfo["transactions"].([]map[string]interface{}), txInfo)
-	}
-
-	json.NewEncoder(w).Encode(blockInfo)
+	json.NewEncoder(w).Encode(blockInfo(block))
 }
 
 // getBlockByHeightHandler returns a block by its height
@@ -254,30 +359,35 @@ func (s *Server) getBlockByHeightHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Return block data directly instead of redirecting
-	blockInfo := map[string]interface{}{
-		"hash":         fmt.Sprintf("%x", block.CalculateHash()),
-		"height":       block.Header.Height,
-		"version":      block.Header.Version,
-		"prev_hash":    fmt.Sprintf("%x", block.Header.PrevBlockHash),
-		"merkle_root":  fmt.Sprintf("%x", block.Header.MerkleRoot),
-		"timestamp":    block.Header.Timestamp.Format(time.RFC3339),
-		"difficulty":   block.Header.Difficulty,
-		"nonce":        block.Header.Nonce,
-		"tx_count":     len(block.Transactions),
+	json.NewEncoder(w).Encode(blockInfo(block))
+}
+
+// blockInfo converts a block to the JSON-friendly format served by the block
+// endpoints.
+func blockInfo(b *block.Block) map[string]interface{} {
+	info := map[string]interface{}{
+		"hash":         fmt.Sprintf("%x", b.CalculateHash()),
+		"height":       b.Header.Height,
+		"version":      b.Header.Version,
+		"prev_hash":    fmt.Sprintf("%x", b.Header.PrevBlockHash),
+		"merkle_root":  fmt.Sprintf("%x", b.Header.MerkleRoot),
+		"timestamp":    b.Header.Timestamp.Format(time.RFC3339),
+		"difficulty":   b.Header.Difficulty,
+		"nonce":        b.Header.Nonce,
+		"tx_count":     len(b.Transactions),
 		"transactions": make([]map[string]interface{}, 0),
 	}
 
 	// Add transaction hashes
-	for _, tx := range block.Transactions {
+	for _, tx := range b.Transactions {
 		txInfo := map[string]interface{}{
 			"hash": fmt.Sprintf("%x", tx.Hash),
 			"type": "transaction",
 		}
-		blockInfo["transactions"] = append(blockInfo["transactions"].([]map[string]interface{}), txInfo)
+		info["transactions"] = append(info["transactions"].([]map[string]interface{}), txInfo)
 	}
 
-	json.NewEncoder(w).Encode(blockInfo)
+	return info
 }
 
 // getLatestBlockHandler returns the latest block
@@ -316,6 +426,54 @@ func (s *Server) getLatestBlockHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(blockInfo)
 }
 
+// invalidateBlockHandler marks a block invalid and reorganizes off it
+func (s *Server) invalidateBlockHandler(w http.ResponseWriter, r *http.Request) {
+	s.blockAdminHandler(w, r, "invalidation", ChainAdminInterface.InvalidateBlock)
+}
+
+// reconsiderBlockHandler removes a manual invalidation from a block
+func (s *Server) reconsiderBlockHandler(w http.ResponseWriter, r *http.Request) {
+	s.blockAdminHandler(w, r, "reconsideration", ChainAdminInterface.ReconsiderBlock)
+}
+
+// blockAdminHandler applies an administrative operation to the block named
+// in the request and reports the resulting chain tip
+func (s *Server) blockAdminHandler(w http.ResponseWriter, r *http.Request, action string, op func(ChainAdminInterface, []byte) error) {
+	w.Header().Set("Content-Type", "application/json")
+
+	admin, ok := s.chain.(ChainAdminInterface)
+	if !ok {
+		http.Error(w, "Block administration not available", http.StatusNotImplemented)
+		return
+	}
+
+	hash, err := hex.DecodeString(mux.Vars(r)["hash"])
+	if err != nil {
+		http.Error(w, "Invalid hash format", http.StatusBadRequest)
+		return
+	}
+
+	if s.chain.GetBlock(hash) == nil {
+		http.Error(w, "Block not found", http.StatusNotFound)
+		return
+	}
+
+	if err := op(admin, hash); err != nil {
+		http.Error(w, fmt.Sprintf("Block %s failed: %v", action, err), http.StatusBadRequest)
+		return
+	}
+
+	result := map[string]interface{}{
+		"hash":   fmt.Sprintf("%x", hash),
+		"action": action,
+		"height": s.chain.GetHeight(),
+	}
+	if bestBlock := s.chain.GetBestBlock(); bestBlock != nil {
+		result["best_block_hash"] = fmt.Sprintf("%x", bestBlock.CalculateHash())
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
 // getTransactionHandler returns a specific transaction by hash
 func (s *Server) getTransactionHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -330,28 +488,7 @@ func (s *Server) getTransactionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// For now, we'll search through blocks to find the transaction
-	// In a real implementation, you'd have a transaction index
-	height := s.chain.GetHeight()
-	var foundTx *block.Transaction
-
-	for h := uint64(0); h <= height; h++ {
-		block := s.chain.GetBlockByHeight(h)
-		if block == nil {
-			continue
-		}
-
-		for _, tx := range block.Transactions {
-			if string(tx.Hash) == string(hash) {
-				foundTx = tx
-				break
-			}
-		}
-		if foundTx != nil {
-			break
-		}
-	}
-
+	foundTx := s.findTransaction(hash)
 	if foundTx == nil {
 		http.Error(w, "Transaction not found", http.StatusNotFound)
 		return
@@ -392,14 +529,21 @@ func (s *Server) getBalanceHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	balance := s.wallet.GetBalance(address)
-
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"address": address,
-		"balance": balance,
+		"balance": s.balance(address),
 	})
 }
 
+// balance returns the balance of an address from the UTXO set, or as the
+// wallet records it when the server has no UTXO set.
+func (s *Server) balance(address string) uint64 {
+	if s.utxoSet != nil {
+		return s.utxoSet.GetBalance(address)
+	}
+	return s.wallet.GetBalance(address)
+}
+
 // getAccountsHandler returns all wallet accounts
 func (s *Server) getAccountsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -462,3 +606,15 @@ func (s *Server) getNetworkStatusHandler(w http.ResponseWriter, r *http.Request)
 		"timestamp":  time.Now().UTC().Format(time.RFC3339),
 	})
 }
+
+// getMiningInfoHandler returns the miner's target, difficulty and session
+// statistics
+func (s *Server) getMiningInfoHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if s.miner == nil {
+		http.Error(w, "Miner not available", http.StatusServiceUnavailable)
+		return
+	}
+	json.NewEncoder(w).Encode(s.miner.GetMiningInfo())
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -98,6 +99,21 @@ func (mc *MockChain) CalculateNextDifficulty() uint64 {
 	return mc.bestBlock.Header.Difficulty + 1
 }
 
+func (mc *MockChain) GetTxBlockHash(txHash []byte) ([]byte, bool) {
+	for h := uint64(0); h <= mc.height; h++ {
+		b := mc.blocksByHeight[h]
+		if b == nil {
+			continue
+		}
+		for _, tx := range b.Transactions {
+			if bytes.Equal(tx.Hash, txHash) {
+				return b.CalculateHash(), true
+			}
+		}
+	}
+	return nil, false
+}
+
 // MockWallet implements WalletInterface for testing
 type MockWallet struct {
 	accounts map[string]*wallet.Account
@@ -142,6 +158,16 @@ func (mw *MockWallet) GetAllAccounts() []*wallet.Account {
 	return accounts
 }
 
+// newTestServer creates a server, failing the test on error
+func newTestServer(t *testing.T, config *ServerConfig) *Server {
+	t.Helper()
+	server, err := NewServer(config)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	return server
+}
+
 func TestNewServer(t *testing.T) {
 	mockChain := NewMockChain()
 	mockWallet := NewMockWallet()
@@ -152,7 +178,10 @@ func TestNewServer(t *testing.T) {
 		Wallet: mockWallet,
 	}
 
-	server := NewServer(config)
+	server, err := NewServer(config)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
 
 	if server == nil {
 		t.Fatal("Server should not be nil")
@@ -920,7 +949,7 @@ func TestServer_Start(t *testing.T) {
 		Wallet: mockWallet,
 	}
 
-	server := NewServer(config)
+	server := newTestServer(t, config)
 
 	// Start the server in a goroutine
 	go func() {
@@ -944,7 +973,7 @@ func TestServer_Start_Error(t *testing.T) {
 		Wallet: NewMockWallet(),
 	}
 
-	server := NewServer(config)
+	server := newTestServer(t, config)
 
 	// This should fail due to invalid port
 	err := server.Start()
@@ -969,7 +998,7 @@ func TestServer_GetChainInfoHandler_EmptyChain(t *testing.T) {
 		Wallet: NewMockWallet(),
 	}
 
-	server := NewServer(config)
+	server := newTestServer(t, config)
 
 	// Create request
 	req, err := http.NewRequest("GET", "/api/v1/chain/info", nil)
@@ -1010,7 +1039,7 @@ func TestServer_GetBlockHandler_InvalidHashFormat(t *testing.T) {
 		Wallet: NewMockWallet(),
 	}
 
-	server := NewServer(config)
+	server := newTestServer(t, config)
 
 	// Test with invalid hex hash
 	req, err := http.NewRequest("GET", "/api/v1/blocks/invalid-hash", nil)
@@ -1040,7 +1069,7 @@ func TestServer_GetBlockByHeightHandler_InvalidHeightFormat(t *testing.T) {
 		Wallet: NewMockWallet(),
 	}
 
-	server := NewServer(config)
+	server := newTestServer(t, config)
 
 	// Test with invalid height
 	req, err := http.NewRequest("GET", "/api/v1/blocks/height/invalid", nil)
@@ -1070,7 +1099,7 @@ func TestServer_GetTransactionHandler_InvalidHashFormat(t *testing.T) {
 		Wallet: NewMockWallet(),
 	}
 
-	server := NewServer(config)
+	server := newTestServer(t, config)
 
 	// Test with invalid hex hash
 	req, err := http.NewRequest("GET", "/api/v1/transactions/invalid-hash", nil)
@@ -1100,7 +1129,7 @@ func TestServer_GetBalanceHandler_InvalidAddress(t *testing.T) {
 		Wallet: NewMockWallet(),
 	}
 
-	server := NewServer(config)
+	server := newTestServer(t, config)
 
 	// Test with empty address
 	req, err := http.NewRequest("GET", "/api/v1/wallet/balance/", nil)
@@ -1130,7 +1159,7 @@ func TestServer_GetPendingTransactionsHandler_Empty(t *testing.T) {
 		Wallet: NewMockWallet(),
 	}
 
-	server := NewServer(config)
+	server := newTestServer(t, config)
 
 	req, err := http.NewRequest("GET", "/api/v1/transactions/pending", nil)
 	if err != nil {
@@ -1172,7 +1201,7 @@ func TestServer_GetPeersHandler_Empty(t *testing.T) {
 		Wallet: NewMockWallet(),
 	}
 
-	server := NewServer(config)
+	server := newTestServer(t, config)
 
 	req, err := http.NewRequest("GET", "/api/v1/network/peers", nil)
 	if err != nil {
@@ -1214,7 +1243,7 @@ func TestServer_GetNetworkStatusHandler_Basic(t *testing.T) {
 		Wallet: NewMockWallet(),
 	}
 
-	server := NewServer(config)
+	server := newTestServer(t, config)
 
 	req, err := http.NewRequest("GET", "/api/v1/network/status", nil)
 	if err != nil {
@@ -1256,12 +1285,13 @@ func TestServer_GetNetworkStatusHandler_Basic(t *testing.T) {
 
 func TestServer_SetupRoutes(t *testing.T) {
 	config := &ServerConfig{
-		Port:   8080,
-		Chain:  NewMockChain(),
-		Wallet: NewMockWallet(),
+		Port:            8080,
+		Chain:           NewMockChain(),
+		Wallet:          NewMockWallet(),
+		WalletEndpoints: true,
 	}
 
-	server := NewServer(config)
+	server := newTestServer(t, config)
 
 	// Test that routes are properly set up
 	if server.router == nil {
@@ -1303,7 +1333,7 @@ func TestServer_ErrorHandling(t *testing.T) {
 		Wallet: nil,
 	}
 
-	server := NewServer(config)
+	server := newTestServer(t, config)
 
 	// Test wallet handlers with nil wallet
 	req, err := http.NewRequest("GET", "/api/v1/wallet/balance/test-address", nil)
@@ -1324,3 +1354,120 @@ func TestServer_ErrorHandling(t *testing.T) {
 		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusServiceUnavailable)
 	}
 }
+
+// MockAdminChain adds block administration to MockChain
+type MockAdminChain struct {
+	*MockChain
+	invalidated map[string]bool
+}
+
+func (mc *MockAdminChain) InvalidateBlock(hash []byte) error {
+	mc.invalidated[fmt.Sprintf("%x", hash)] = true
+	return nil
+}
+
+func (mc *MockAdminChain) ReconsiderBlock(hash []byte) error {
+	key := fmt.Sprintf("%x", hash)
+	if !mc.invalidated[key] {
+		return fmt.Errorf("block %s is not invalidated", key)
+	}
+	delete(mc.invalidated, key)
+	return nil
+}
+
+func TestServer_BlockAdminHandlers(t *testing.T) {
+	adminChain := &MockAdminChain{MockChain: NewMockChain(), invalidated: make(map[string]bool)}
+	server := newTestServer(t, &ServerConfig{Chain: adminChain})
+	hashHex := fmt.Sprintf("%x", adminChain.GetBestBlock().CalculateHash())
+
+	post := func(path string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.RemoteAddr = "127.0.0.1:40000"
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := post("/api/v1/blocks/" + hashHex + "/invalidate")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("invalidate returned status %d: %s", rr.Code, rr.Body.String())
+	}
+	var response map[string]interface{}
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response["action"] != "invalidation" || response["hash"] != hashHex {
+		t.Errorf("unexpected invalidate response: %v", response)
+	}
+	if !adminChain.invalidated[hashHex] {
+		t.Error("block should be invalidated")
+	}
+
+	if rr := post("/api/v1/blocks/" + hashHex + "/reconsider"); rr.Code != http.StatusOK {
+		t.Errorf("reconsider returned status %d", rr.Code)
+	}
+	if rr := post("/api/v1/blocks/" + hashHex + "/reconsider"); rr.Code != http.StatusBadRequest {
+		t.Errorf("second reconsider returned status %d, want %d", rr.Code, http.StatusBadRequest)
+	}
+	if rr := post("/api/v1/blocks/zz/invalidate"); rr.Code != http.StatusBadRequest {
+		t.Errorf("invalid hash returned status %d, want %d", rr.Code, http.StatusBadRequest)
+	}
+	if rr := post("/api/v1/blocks/" + fmt.Sprintf("%x", make([]byte, 32)) + "/invalidate"); rr.Code != http.StatusNotFound {
+		t.Errorf("unknown block returned status %d, want %d", rr.Code, http.StatusNotFound)
+	}
+
+	// Chains without administration support report it as unavailable
+	server = newTestServer(t, &ServerConfig{Chain: NewMockChain()})
+	if rr := post("/api/v1/blocks/" + hashHex + "/invalidate"); rr.Code != http.StatusNotImplemented {
+		t.Errorf("non-admin chain returned status %d, want %d", rr.Code, http.StatusNotImplemented)
+	}
+}
+
+func TestServer_BlockAdminAccess(t *testing.T) {
+	adminChain := &MockAdminChain{MockChain: NewMockChain(), invalidated: make(map[string]bool)}
+	path := fmt.Sprintf("/api/v1/blocks/%x/invalidate", adminChain.GetBestBlock().CalculateHash())
+
+	post := func(server *Server, remoteAddr, token string) int {
+		req := httptest.NewRequest("POST", path, nil)
+		req.RemoteAddr = remoteAddr
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// Without a token only loopback clients are served
+	server := newTestServer(t, &ServerConfig{Chain: adminChain})
+	if code := post(server, "203.0.113.7:40000", ""); code != http.StatusForbidden {
+		t.Errorf("remote client returned status %d, want %d", code, http.StatusForbidden)
+	}
+	if len(adminChain.invalidated) != 0 {
+		t.Fatal("remote client should not invalidate blocks")
+	}
+	if code := post(server, "[::1]:40000", ""); code != http.StatusOK {
+		t.Errorf("loopback client returned status %d, want %d", code, http.StatusOK)
+	}
+
+	// With a token every client must present it, loopback ones included
+	server = newTestServer(t, &ServerConfig{Chain: adminChain, AdminToken: "secret"})
+	if code := post(server, "127.0.0.1:40000", ""); code != http.StatusUnauthorized {
+		t.Errorf("request without token returned status %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := post(server, "203.0.113.7:40000", "wrong"); code != http.StatusUnauthorized {
+		t.Errorf("request with wrong token returned status %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := post(server, "203.0.113.7:40000", "secret"); code != http.StatusOK {
+		t.Errorf("request with token returned status %d, want %d", code, http.StatusOK)
+	}
+
+	// Read-only nodes refuse administration whatever the credentials
+	server = newTestServer(t, &ServerConfig{Chain: adminChain, AdminToken: "secret", ReadOnly: true})
+	if code := post(server, "127.0.0.1:40000", "secret"); code != http.StatusForbidden {
+		t.Errorf("read-only node returned status %d, want %d", code, http.StatusForbidden)
+	}
+}
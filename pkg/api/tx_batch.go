@@ -0,0 +1,175 @@
+package api
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+)
+
+// DefaultMaxTxBatchSize is the default maximum number of transactions
+// accepted in a single batch submission.
+const DefaultMaxTxBatchSize = 100
+
+// txBatchRequest is the body of a batch submission: hex encoded raw
+// transactions in the format produced by Transaction.Serialize.
+type txBatchRequest struct {
+	Transactions []string `json:"transactions"`
+}
+
+// txBatchResult reports the outcome for one transaction of a batch, in the
+// order the transactions were submitted.
+type txBatchResult struct {
+	Index    int    `json:"index"`
+	Hash     string `json:"hash,omitempty"`
+	Accepted bool   `json:"accepted"`
+	Error    string `json:"error,omitempty"`
+}
+
+// batchTx is a decoded batch entry awaiting submission.
+type batchTx struct {
+	index   int
+	tx      *block.Transaction
+	parents []int // parents are the batch indexes of transactions this one spends.
+}
+
+// submitTxBatchHandler decodes a batch of raw transactions and adds each to
+// the mempool, parents before the children spending them. Every transaction
+// gets its own result so one bad transaction does not fail the whole batch.
+func (s *Server) submitTxBatchHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if s.readOnly {
+		http.Error(w, ErrReadOnly.Error(), http.StatusForbidden)
+		return
+	}
+	if s.mempool == nil {
+		http.Error(w, "Mempool not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req txBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Transactions) == 0 {
+		http.Error(w, "Batch contains no transactions", http.StatusBadRequest)
+		return
+	}
+	if len(req.Transactions) > s.maxTxBatchSize {
+		http.Error(w, fmt.Sprintf("Batch of %d transactions exceeds maximum %d", len(req.Transactions), s.maxTxBatchSize), http.StatusBadRequest)
+		return
+	}
+
+	results := make([]txBatchResult, len(req.Transactions))
+	entries := make([]*batchTx, 0, len(req.Transactions))
+	byHash := make(map[string]int)
+	for i, raw := range req.Transactions {
+		results[i].Index = i
+
+		data, err := hex.DecodeString(raw)
+		if err != nil {
+			results[i].Error = "invalid hex encoding"
+			continue
+		}
+		tx, err := block.DecodeTransaction(data)
+		if err != nil {
+			results[i].Error = fmt.Sprintf("invalid transaction encoding: %v", err)
+			continue
+		}
+		results[i].Hash = fmt.Sprintf("%x", tx.Hash)
+		if _, duplicate := byHash[string(tx.Hash)]; duplicate {
+			results[i].Error = "duplicate transaction in batch"
+			continue
+		}
+		byHash[string(tx.Hash)] = i
+		entries = append(entries, &batchTx{index: i, tx: tx})
+	}
+
+	for _, entry := range entries {
+		for _, input := range entry.tx.Inputs {
+			if parent, inBatch := byHash[string(input.PrevTxHash)]; inBatch && parent != entry.index {
+				entry.parents = append(entry.parents, parent)
+			}
+		}
+	}
+
+	for _, entry := range orderBatch(entries) {
+		if failed := firstFailedParent(entry, results); failed >= 0 {
+			results[entry.index].Error = fmt.Sprintf("depends on rejected transaction at index %d", failed)
+			continue
+		}
+		if err := addLocalTransaction(s.mempool, entry.tx); err != nil {
+			results[entry.index].Error = err.Error()
+			continue
+		}
+		results[entry.index].Accepted = true
+	}
+
+	// Transactions left out of the ordering form a dependency cycle
+	for _, entry := range entries {
+		result := &results[entry.index]
+		if !result.Accepted && result.Error == "" {
+			result.Error = "dependency cycle in batch"
+		}
+	}
+
+	accepted := 0
+	for _, result := range results {
+		if result.Accepted {
+			accepted++
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"results":  results,
+		"accepted": accepted,
+		"rejected": len(results) - accepted,
+	})
+}
+
+// orderBatch returns the entries with every transaction after the batch
+// transactions it spends, otherwise keeping submission order. Entries that
+// are part of a dependency cycle are left out.
+func orderBatch(entries []*batchTx) []*batchTx {
+	pending := make(map[int]int, len(entries))
+	children := make(map[int][]*batchTx)
+	for _, entry := range entries {
+		pending[entry.index] = len(entry.parents)
+		for _, parent := range entry.parents {
+			children[parent] = append(children[parent], entry)
+		}
+	}
+
+	ordered := make([]*batchTx, 0, len(entries))
+	done := make(map[int]bool, len(entries))
+	for progress := true; progress; {
+		progress = false
+		for _, entry := range entries {
+			if done[entry.index] || pending[entry.index] > 0 {
+				continue
+			}
+			done[entry.index] = true
+			ordered = append(ordered, entry)
+			for _, child := range children[entry.index] {
+				pending[child.index]--
+			}
+			progress = true
+		}
+	}
+	return ordered
+}
+
+// firstFailedParent returns the index of the first batch parent of entry
+// that was not accepted, or -1 if all of them were.
+func firstFailedParent(entry *batchTx, results []txBatchResult) int {
+	for _, parent := range entry.parents {
+		if !results[parent].Accepted {
+			return parent
+		}
+	}
+	return -1
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBatchMempool accepts a transaction only when every input spends a
+// funded outpoint or a previously accepted transaction and it pays a fee.
+type fakeBatchMempool struct {
+	known    map[string]bool
+	accepted []string
+}
+
+func (m *fakeBatchMempool) AddTransaction(tx *block.Transaction) error {
+	if tx.Fee == 0 {
+		return fmt.Errorf("fee too low")
+	}
+	for _, input := range tx.Inputs {
+		if !m.known[string(input.PrevTxHash)] {
+			return fmt.Errorf("input %x not found", input.PrevTxHash)
+		}
+	}
+	m.known[string(tx.Hash)] = true
+	m.accepted = append(m.accepted, fmt.Sprintf("%x", tx.Hash))
+	return nil
+}
+
+func (m *fakeBatchMempool) GetTransactionCount() int {
+	return len(m.accepted)
+}
+
+func batchTestTx(t *testing.T, prevHash []byte, fee uint64, tag string) (*block.Transaction, string) {
+	tx := &block.Transaction{
+		Version: 1,
+		Inputs: []*block.TxInput{{
+			PrevTxHash: prevHash,
+			ScriptSig:  []byte("sig-" + tag),
+			Sequence:   0xffffffff,
+		}},
+		Outputs: []*block.TxOutput{{Value: 1000, ScriptPubKey: []byte("pubkey-" + tag)}},
+		Fee:     fee,
+	}
+	tx.Hash = tx.CalculateHash()
+	raw, err := tx.Serialize()
+	require.NoError(t, err)
+	return tx, hex.EncodeToString(raw)
+}
+
+func TestSubmitTxBatch(t *testing.T) {
+	funded := bytes.Repeat([]byte{0x11}, 32)
+	mp := &fakeBatchMempool{known: map[string]bool{string(funded): true}}
+	server := newTestServer(t, &ServerConfig{Chain: NewMockChain(), Mempool: mp, MaxTxBatchSize: 10})
+
+	parent, parentRaw := batchTestTx(t, funded, 100, "parent")
+	child, childRaw := batchTestTx(t, parent.Hash, 100, "child")
+	noFee, noFeeRaw := batchTestTx(t, funded, 0, "nofee")
+	_, orphanOfRejectedRaw := batchTestTx(t, noFee.Hash, 100, "orphan")
+	_, unknownInputRaw := batchTestTx(t, bytes.Repeat([]byte{0x22}, 32), 100, "unknown")
+
+	batch := []string{
+		childRaw, // submitted before its parent
+		parentRaw,
+		"zz-not-hex",
+		noFeeRaw,
+		orphanOfRejectedRaw,
+		unknownInputRaw,
+		parentRaw,
+		hex.EncodeToString([]byte{0x00, 0x01}),
+	}
+	body, err := json.Marshal(txBatchRequest{Transactions: batch})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("POST", "/api/v1/transactions/batch", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var response struct {
+		Results  []txBatchResult `json:"results"`
+		Accepted int             `json:"accepted"`
+		Rejected int             `json:"rejected"`
+	}
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&response))
+	require.Len(t, response.Results, len(batch))
+	assert.Equal(t, 2, response.Accepted)
+	assert.Equal(t, 6, response.Rejected)
+
+	results := response.Results
+	assert.True(t, results[0].Accepted, "child is accepted after its parent")
+	assert.Equal(t, fmt.Sprintf("%x", child.Hash), results[0].Hash)
+	assert.True(t, results[1].Accepted)
+	assert.Equal(t, "invalid hex encoding", results[2].Error)
+	assert.Equal(t, "fee too low", results[3].Error)
+	assert.Equal(t, "depends on rejected transaction at index 3", results[4].Error)
+	assert.Contains(t, results[5].Error, "not found")
+	assert.Equal(t, "duplicate transaction in batch", results[6].Error)
+	assert.Contains(t, results[7].Error, "invalid transaction encoding")
+
+	assert.Equal(t, []string{fmt.Sprintf("%x", parent.Hash), fmt.Sprintf("%x", child.Hash)}, mp.accepted)
+}
+
+func TestSubmitTxBatchLimits(t *testing.T) {
+	mp := &fakeBatchMempool{known: map[string]bool{}}
+	server := newTestServer(t, &ServerConfig{Chain: NewMockChain(), Mempool: mp, MaxTxBatchSize: 2})
+
+	post := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, httptest.NewRequest("POST", "/api/v1/transactions/batch", bytes.NewBufferString(body)))
+		return rr
+	}
+
+	assert.Equal(t, http.StatusBadRequest, post(`{"transactions": ["aa", "bb", "cc"]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(`{"transactions": []}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(`not json`).Code)
+
+	server = newTestServer(t, &ServerConfig{Chain: NewMockChain()})
+	assert.Equal(t, DefaultMaxTxBatchSize, server.maxTxBatchSize)
+	assert.Equal(t, http.StatusServiceUnavailable, post(`{"transactions": ["aa"]}`).Code)
+}
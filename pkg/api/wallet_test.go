@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/palaseus/adrenochain/pkg/utxo"
+	"github.com/palaseus/adrenochain/pkg/wallet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalletEndpointsRequireWallet(t *testing.T) {
+	_, err := NewServer(&ServerConfig{Chain: NewMockChain(), WalletEndpoints: true})
+	assert.Error(t, err)
+
+	// Without wallet endpoints no wallet is needed, and none is served
+	server, err := NewServer(&ServerConfig{Chain: NewMockChain()})
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/wallet/accounts", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestBalanceEndpointWithWallet(t *testing.T) {
+	dataDir := t.TempDir()
+	s, err := storage.NewStorage(&storage.StorageConfig{DataDir: dataDir})
+	require.NoError(t, err)
+	defer s.Close()
+
+	us := utxo.NewUTXOSet()
+	config := wallet.DefaultWalletConfig()
+	config.WalletFile = filepath.Join(dataDir, "wallet.dat")
+	nodeWallet, err := wallet.NewWallet(config, us, s)
+	require.NoError(t, err)
+
+	account := nodeWallet.GetDefaultAccount()
+	us.AddUTXO(&utxo.UTXO{
+		TxHash:       []byte("funding"),
+		Value:        12345,
+		ScriptPubKey: account.PublicKey,
+		Address:      account.Address,
+		Height:       1,
+	})
+
+	server, err := NewServer(&ServerConfig{
+		Chain:           NewMockChain(),
+		Wallet:          nodeWallet,
+		UTXOSet:         us,
+		WalletEndpoints: true,
+	})
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/wallet/balance/"+account.Address, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Address string `json:"address"`
+		Balance uint64 `json:"balance"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, account.Address, response.Address)
+	assert.Equal(t, uint64(12345), response.Balance)
+}
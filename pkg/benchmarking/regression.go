@@ -0,0 +1,225 @@
+package benchmarking
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RegressionConfig holds the tolerances of the regression gate, in percent of
+// the baseline value. A tolerance of zero or less disables the check of that
+// metric.
+type RegressionConfig struct {
+	MaxThroughputDrop    float64 `json:"max_throughput_drop_percent"`
+	MaxMemoryPerOpGrowth float64 `json:"max_memory_per_op_growth_percent"`
+	MaxDurationGrowth    float64 `json:"max_duration_growth_percent"`
+	// FailOnMissing also fails the gate when a baseline benchmark is absent
+	// from the current report.
+	FailOnMissing bool `json:"fail_on_missing"`
+}
+
+// DefaultRegressionConfig returns the default regression gate tolerances
+func DefaultRegressionConfig() *RegressionConfig {
+	return &RegressionConfig{
+		MaxThroughputDrop:    10,
+		MaxMemoryPerOpGrowth: 20,
+		MaxDurationGrowth:    0,
+		FailOnMissing:        false,
+	}
+}
+
+// Regression describes a metric of a benchmark that got worse than its
+// baseline by more than the configured tolerance
+type Regression struct {
+	Benchmark     string  `json:"benchmark"`
+	Metric        string  `json:"metric"`
+	Baseline      float64 `json:"baseline"`
+	Current       float64 `json:"current"`
+	ChangePercent float64 `json:"change_percent"`
+	Tolerance     float64 `json:"tolerance_percent"`
+}
+
+// String returns a one-line description of the regression
+func (r Regression) String() string {
+	return fmt.Sprintf("%s: %s went from %.2f to %.2f (%+.1f%%, tolerance %.1f%%)",
+		r.Benchmark, r.Metric, r.Baseline, r.Current, r.ChangePercent, r.Tolerance)
+}
+
+// RegressionReport is the outcome of comparing a report against a baseline
+type RegressionReport struct {
+	Compared      int          `json:"compared"`
+	Regressions   []Regression `json:"regressions"`
+	Missing       []string     `json:"missing"`
+	New           []string     `json:"new"`
+	failOnMissing bool
+}
+
+// Passed reports whether the gate passes
+func (rr *RegressionReport) Passed() bool {
+	if len(rr.Regressions) > 0 {
+		return false
+	}
+	return !rr.failOnMissing || len(rr.Missing) == 0
+}
+
+// String returns a human readable summary of the comparison
+func (rr *RegressionReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Compared %d benchmarks against the baseline\n", rr.Compared)
+	for _, r := range rr.Regressions {
+		fmt.Fprintf(&b, "REGRESSION %s\n", r)
+	}
+	for _, name := range rr.Missing {
+		fmt.Fprintf(&b, "MISSING %s\n", name)
+	}
+	for _, name := range rr.New {
+		fmt.Fprintf(&b, "NEW %s\n", name)
+	}
+	if rr.Passed() {
+		b.WriteString("Regression gate passed\n")
+	} else {
+		b.WriteString("Regression gate failed\n")
+	}
+	return b.String()
+}
+
+// benchmarkKey identifies a benchmark across reports
+func benchmarkKey(result *BenchmarkResult) string {
+	return result.PackageName + "/" + result.TestName
+}
+
+// CompareReports compares the results of current against those of baseline,
+// matching benchmarks by package and test name. Throughput regresses when it
+// drops, memory per operation and duration when they grow. Metrics with a
+// zero baseline are not compared.
+func CompareReports(current, baseline *BenchmarkReport, config *RegressionConfig) *RegressionReport {
+	if config == nil {
+		config = DefaultRegressionConfig()
+	}
+	report := &RegressionReport{failOnMissing: config.FailOnMissing}
+
+	currentByKey := make(map[string]*BenchmarkResult, len(current.Results))
+	for _, result := range current.Results {
+		if result != nil {
+			currentByKey[benchmarkKey(result)] = result
+		}
+	}
+
+	seen := make(map[string]bool, len(baseline.Results))
+	for _, base := range baseline.Results {
+		if base == nil {
+			continue
+		}
+		key := benchmarkKey(base)
+		seen[key] = true
+		cur, exists := currentByKey[key]
+		if !exists {
+			report.Missing = append(report.Missing, key)
+			continue
+		}
+		report.Compared++
+
+		checks := []struct {
+			metric         string
+			baseline       float64
+			current        float64
+			tolerance      float64
+			higherIsBetter bool
+		}{
+			{"throughput_ops_per_sec", base.Throughput, cur.Throughput, config.MaxThroughputDrop, true},
+			{"memory_per_op_bytes", base.MemoryPerOp, cur.MemoryPerOp, config.MaxMemoryPerOpGrowth, false},
+			{"duration_seconds", base.Duration.Seconds(), cur.Duration.Seconds(), config.MaxDurationGrowth, false},
+		}
+		for _, check := range checks {
+			if check.tolerance <= 0 || check.baseline <= 0 {
+				continue
+			}
+			change := (check.current - check.baseline) / check.baseline * 100
+			// A throughput drop is a negative change, so its sign is
+			// flipped to compare it with the tolerance
+			worse := change
+			if check.higherIsBetter {
+				worse = -change
+			}
+			if worse > check.tolerance {
+				report.Regressions = append(report.Regressions, Regression{
+					Benchmark:     key,
+					Metric:        check.metric,
+					Baseline:      check.baseline,
+					Current:       check.current,
+					ChangePercent: change,
+					Tolerance:     check.tolerance,
+				})
+			}
+		}
+	}
+
+	for key := range currentByKey {
+		if !seen[key] {
+			report.New = append(report.New, key)
+		}
+	}
+	sort.Strings(report.Missing)
+	sort.Strings(report.New)
+	return report
+}
+
+// LoadBaseline reads a benchmark report saved as a baseline
+func LoadBaseline(path string) (*BenchmarkReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline: %v", err)
+	}
+	var report BenchmarkReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to decode baseline %s: %v", path, err)
+	}
+	return &report, nil
+}
+
+// SaveBaseline writes report to path for later runs to compare against
+func SaveBaseline(report *BenchmarkReport, path string) error {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create baseline directory: %v", err)
+		}
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode baseline: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write baseline: %v", err)
+	}
+	return nil
+}
+
+// Report returns the benchmark report of the orchestrator's results
+func (mbo *MainBenchmarkOrchestrator) Report() *BenchmarkReport {
+	results := mbo.GetResults()
+	return &BenchmarkReport{
+		Timestamp:       time.Now(),
+		TotalBenchmarks: len(results),
+		Results:         results,
+		Summary:         mbo.GenerateSummary(),
+	}
+}
+
+// SaveBaseline saves the orchestrator's results as the baseline at path
+func (mbo *MainBenchmarkOrchestrator) SaveBaseline(path string) error {
+	return SaveBaseline(mbo.Report(), path)
+}
+
+// CheckRegression compares the orchestrator's results against the baseline
+// saved at path
+func (mbo *MainBenchmarkOrchestrator) CheckRegression(path string, config *RegressionConfig) (*RegressionReport, error) {
+	baseline, err := LoadBaseline(path)
+	if err != nil {
+		return nil, err
+	}
+	return CompareReports(mbo.Report(), baseline, config), nil
+}
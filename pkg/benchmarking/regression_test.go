@@ -0,0 +1,91 @@
+package benchmarking
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCompareReportsAgainstBaseline(t *testing.T) {
+	result := func(name string, throughput, memoryPerOp float64) *BenchmarkResult {
+		return &BenchmarkResult{
+			PackageName: "Layer 2",
+			TestName:    name,
+			Duration:    time.Second,
+			Throughput:  throughput,
+			MemoryPerOp: memoryPerOp,
+		}
+	}
+
+	orchestrator := NewMainBenchmarkOrchestrator()
+	orchestrator.AddResult(result("Rollup", 1000, 100))
+	orchestrator.AddResult(result("Channels", 500, 50))
+	orchestrator.AddResult(result("Sidechain", 200, 10))
+
+	path := filepath.Join(t.TempDir(), "baseline", "benchmarks.json")
+	if err := orchestrator.SaveBaseline(path); err != nil {
+		t.Fatalf("SaveBaseline failed: %v", err)
+	}
+	baseline, err := LoadBaseline(path)
+	if err != nil {
+		t.Fatalf("LoadBaseline failed: %v", err)
+	}
+	if len(baseline.Results) != 3 {
+		t.Fatalf("Expected 3 baseline results, got %d", len(baseline.Results))
+	}
+
+	config := &RegressionConfig{MaxThroughputDrop: 10, MaxMemoryPerOpGrowth: 20}
+
+	// Changes within the tolerance pass
+	current := &BenchmarkReport{Results: []*BenchmarkResult{
+		result("Rollup", 950, 110),
+		result("Channels", 600, 40),
+		result("Sidechain", 181, 11.9),
+	}}
+	report := CompareReports(current, baseline, config)
+	if !report.Passed() {
+		t.Errorf("Expected the gate to pass within tolerance, got:\n%s", report)
+	}
+	if report.Compared != 3 {
+		t.Errorf("Expected 3 compared benchmarks, got %d", report.Compared)
+	}
+
+	// Throughput and memory regressions beyond the tolerance fail
+	current = &BenchmarkReport{Results: []*BenchmarkResult{
+		result("Rollup", 850, 100),
+		result("Channels", 500, 65),
+		result("Sidechain", 200, 10),
+		result("Bridge", 10, 1),
+	}}
+	report = CompareReports(current, baseline, config)
+	if report.Passed() {
+		t.Fatal("Expected the gate to fail on regressions")
+	}
+	if len(report.Regressions) != 2 {
+		t.Fatalf("Expected 2 regressions, got %d:\n%s", len(report.Regressions), report)
+	}
+	if r := report.Regressions[0]; r.Benchmark != "Layer 2/Rollup" || r.Metric != "throughput_ops_per_sec" || r.ChangePercent != -15 {
+		t.Errorf("Unexpected throughput regression: %s", r)
+	}
+	if r := report.Regressions[1]; r.Benchmark != "Layer 2/Channels" || r.Metric != "memory_per_op_bytes" || r.ChangePercent != 30 {
+		t.Errorf("Unexpected memory regression: %s", r)
+	}
+	if len(report.New) != 1 || report.New[0] != "Layer 2/Bridge" {
+		t.Errorf("Expected Bridge to be reported as new, got %v", report.New)
+	}
+
+	// A missing benchmark only fails the gate when configured to
+	current = &BenchmarkReport{Results: []*BenchmarkResult{result("Rollup", 1000, 100)}}
+	if report = CompareReports(current, baseline, config); !report.Passed() {
+		t.Errorf("Expected missing benchmarks to pass by default, got:\n%s", report)
+	}
+	config.FailOnMissing = true
+	report = CompareReports(current, baseline, config)
+	if report.Passed() || len(report.Missing) != 2 {
+		t.Errorf("Expected 2 missing benchmarks to fail the gate, got:\n%s", report)
+	}
+
+	if _, err := orchestrator.CheckRegression(filepath.Join(t.TempDir(), "absent.json"), config); err == nil {
+		t.Error("Expected an error for a missing baseline")
+	}
+}
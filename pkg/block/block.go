@@ -355,7 +355,8 @@ func (in *TxInput) IsValid() error {
 // IsValid checks if the transaction output is valid according to its internal consistency rules.
 // It validates the output value and the presence of a script public key.
 func (out *TxOutput) IsValid() error {
-	if out.Value == 0 {
+	// Data outputs hold no value
+	if out.Value == 0 && !out.IsData() {
 		return fmt.Errorf("output value cannot be zero")
 	}
 
@@ -390,6 +391,12 @@ func (tx *Transaction) IsCoinbase() bool {
 	return len(tx.Inputs) == 0
 }
 
+// SigOpCount returns the number of signature checks validating the
+// transaction takes: one per input.
+func (tx *Transaction) SigOpCount() uint64 {
+	return uint64(len(tx.Inputs))
+}
+
 // Helper function to compare byte slices
 // bytesEqual checks if two byte slices are equal.
 func bytesEqual(a, b []byte) bool {
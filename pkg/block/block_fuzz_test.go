@@ -348,3 +348,41 @@ func FuzzHeaderValidation(f *testing.F) {
 		}
 	})
 }
+
+// FuzzDecodeTransaction checks that the strict decoder never panics and that
+// every encoding it accepts is canonical, re-serializing to the same bytes.
+func FuzzDecodeTransaction(f *testing.F) {
+	validTx := NewTransaction([]*TxInput{
+		{PrevTxHash: make([]byte, 32), PrevTxIndex: 1, ScriptSig: []byte("sig"), Sequence: 0xffffffff},
+	}, []*TxOutput{
+		{Value: 100, ScriptPubKey: []byte("script")},
+	}, 10)
+	validData, _ := validTx.Serialize()
+	f.Add(validData)
+	f.Add(validData[:len(validData)-5])               // truncated hash
+	f.Add(append(append([]byte{}, validData...), 0))  // trailing byte
+	f.Add([]byte{0, 0, 0, 1, 0xff, 0xff, 0xff, 0xff}) // oversized input count
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		tx, err := DecodeTransaction(data)
+		if err != nil {
+			if tx != nil {
+				t.Errorf("DecodeTransaction returned a transaction along with error %v", err)
+			}
+			return
+		}
+
+		if len(tx.Inputs) > MaxTxInputs || len(tx.Outputs) > MaxTxOutputs {
+			t.Errorf("decoded transaction exceeds count limits: %d inputs, %d outputs", len(tx.Inputs), len(tx.Outputs))
+		}
+
+		serialized, err := tx.Serialize()
+		if err != nil {
+			t.Fatalf("Failed to serialize decoded transaction: %v", err)
+		}
+		if !bytes.Equal(serialized, data) {
+			t.Errorf("decoded transaction does not re-serialize to its input")
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package block
+
+import "fmt"
+
+const (
+	// OpReturn is the script opcode marking an output as provably
+	// unspendable. Data outputs carry their payload after it.
+	OpReturn = 0x6a
+	// MaxDataOutputSize is the consensus limit on the payload of a data
+	// output in bytes.
+	MaxDataOutputSize = 80
+	// pubKeyHashScriptSize is the size of a plain public key hash script.
+	// Any hash can start with OpReturn, so scripts of this size are never
+	// data outputs.
+	pubKeyHashScriptSize = 20
+)
+
+// NewDataOutput returns a provably unspendable output carrying data. It
+// holds no value. Data whose script would have the size of a public key
+// hash is rejected, as the output would be read as a payment.
+func NewDataOutput(data []byte) (*TxOutput, error) {
+	if len(data)+1 == pubKeyHashScriptSize {
+		return nil, fmt.Errorf("data output of %d bytes would be read as a public key hash", len(data))
+	}
+	script := make([]byte, 0, len(data)+1)
+	script = append(script, OpReturn)
+	return &TxOutput{ScriptPubKey: append(script, data...)}, nil
+}
+
+// IsUnspendableScript reports whether an output script can provably never
+// be spent: it starts with OpReturn and is not a public key hash.
+func IsUnspendableScript(script []byte) bool {
+	return len(script) > 0 && len(script) != pubKeyHashScriptSize && script[0] == OpReturn
+}
+
+// IsData reports whether the output is a provably unspendable data output.
+// Data outputs never enter the UTXO set.
+func (out *TxOutput) IsData() bool {
+	return IsUnspendableScript(out.ScriptPubKey)
+}
+
+// Data returns the payload of a data output, or nil for other outputs.
+func (out *TxOutput) Data() []byte {
+	if !out.IsData() {
+		return nil
+	}
+	return out.ScriptPubKey[1:]
+}
+
+// CheckDataOutputs rejects a transaction with a data output whose payload
+// exceeds MaxDataOutputSize bytes.
+func (tx *Transaction) CheckDataOutputs() error {
+	for i, output := range tx.Outputs {
+		if output == nil || !output.IsData() {
+			continue
+		}
+		if size := len(output.Data()); size > MaxDataOutputSize {
+			return fmt.Errorf("data output %d carries %d bytes, more than the maximum %d", i, size, MaxDataOutputSize)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package block
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Limits enforced by DecodeTransaction. They bound the work and memory a
+// single malformed or hostile encoding can cause.
+const (
+	MaxTxInputs      = 1000   // MaxTxInputs is the maximum number of inputs in a decoded transaction.
+	MaxTxOutputs     = 1000   // MaxTxOutputs is the maximum number of outputs in a decoded transaction.
+	MaxScriptSize    = 10000  // MaxScriptSize is the maximum length of a ScriptSig or ScriptPubKey.
+	MaxTxEncodedSize = 400000 // MaxTxEncodedSize is the maximum length of an encoded transaction.
+)
+
+// DecodeTransaction strictly decodes a transaction in the format produced by
+// Transaction.Serialize. Unlike Deserialize it checks every declared count
+// and length against the data actually present and the limits above before
+// allocating, requires each input and output record to be consumed exactly,
+// and rejects trailing bytes, so that any accepted encoding re-serializes to
+// the same bytes. It never panics on arbitrary input.
+func DecodeTransaction(data []byte) (*Transaction, error) {
+	if len(data) > MaxTxEncodedSize {
+		return nil, fmt.Errorf("transaction encoding of %d bytes exceeds maximum %d", len(data), MaxTxEncodedSize)
+	}
+
+	r := &txReader{data: data}
+	tx := &Transaction{}
+
+	tx.Version = r.uint32("version")
+
+	inputCount := r.count("input count", MaxTxInputs, 4+44)
+	tx.Inputs = make([]*TxInput, 0, inputCount)
+	for i := 0; i < inputCount && r.err == nil; i++ {
+		record := r.record(fmt.Sprintf("input %d", i))
+		if r.err != nil {
+			break
+		}
+		input, err := decodeTxInput(record)
+		if err != nil {
+			return nil, fmt.Errorf("input %d: %w", i, err)
+		}
+		tx.Inputs = append(tx.Inputs, input)
+	}
+
+	outputCount := r.count("output count", MaxTxOutputs, 4+12)
+	tx.Outputs = make([]*TxOutput, 0, outputCount)
+	for i := 0; i < outputCount && r.err == nil; i++ {
+		record := r.record(fmt.Sprintf("output %d", i))
+		if r.err != nil {
+			break
+		}
+		output, err := decodeTxOutput(record)
+		if err != nil {
+			return nil, fmt.Errorf("output %d: %w", i, err)
+		}
+		tx.Outputs = append(tx.Outputs, output)
+	}
+
+	tx.LockTime = r.uint64("lock time")
+	tx.Fee = r.uint64("fee")
+	tx.Hash = r.bytes("hash", 32)
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.remaining() != 0 {
+		return nil, fmt.Errorf("%d trailing bytes after transaction", r.remaining())
+	}
+	return tx, nil
+}
+
+// DecodeTransactionHex strictly decodes a hex encoded transaction, as
+// produced by Transaction.Hex. Surrounding whitespace is ignored.
+func DecodeTransactionHex(s string) (*Transaction, error) {
+	data, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex encoding: %w", err)
+	}
+	return DecodeTransaction(data)
+}
+
+// Hex returns the hex encoding of the serialized transaction.
+func (tx *Transaction) Hex() (string, error) {
+	data, err := tx.Serialize()
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
+
+// Hex returns the hex encoding of the serialized block.
+func (b *Block) Hex() (string, error) {
+	data, err := b.Serialize()
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
+
+// decodeTxInput decodes a single input record that must be consumed exactly.
+func decodeTxInput(data []byte) (*TxInput, error) {
+	r := &txReader{data: data}
+	input := &TxInput{}
+	input.PrevTxHash = r.bytes("previous transaction hash", 32)
+	input.PrevTxIndex = r.uint32("previous transaction index")
+	input.ScriptSig = r.bytes("script signature", r.length("script signature length", MaxScriptSize))
+	input.Sequence = r.uint32("sequence")
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.remaining() != 0 {
+		return nil, fmt.Errorf("%d trailing bytes in input record", r.remaining())
+	}
+	return input, nil
+}
+
+// decodeTxOutput decodes a single output record that must be consumed exactly.
+func decodeTxOutput(data []byte) (*TxOutput, error) {
+	r := &txReader{data: data}
+	output := &TxOutput{}
+	output.Value = r.uint64("value")
+	output.ScriptPubKey = r.bytes("script public key", r.length("script public key length", MaxScriptSize))
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.remaining() != 0 {
+		return nil, fmt.Errorf("%d trailing bytes in output record", r.remaining())
+	}
+	return output, nil
+}
+
+// txReader reads big-endian fields from a byte slice. After the first
+// failure every read is a no-op and err holds the cause.
+type txReader struct {
+	data   []byte
+	offset int
+	err    error
+}
+
+func (r *txReader) remaining() int {
+	return len(r.data) - r.offset
+}
+
+// bytes returns a copy of the next n bytes.
+func (r *txReader) bytes(field string, n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > r.remaining() {
+		r.err = fmt.Errorf("truncated %s: need %d bytes, have %d", field, n, r.remaining())
+		return nil
+	}
+	out := make([]byte, n)
+	copy(out, r.data[r.offset:r.offset+n])
+	r.offset += n
+	return out
+}
+
+func (r *txReader) uint32(field string) uint32 {
+	b := r.bytes(field, 4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (r *txReader) uint64(field string) uint64 {
+	b := r.bytes(field, 8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+// length reads a 4-byte length prefix and checks it against max.
+func (r *txReader) length(field string, max int) int {
+	n := r.uint32(field)
+	if r.err != nil {
+		return 0
+	}
+	if uint64(n) > uint64(max) {
+		r.err = fmt.Errorf("%s %d exceeds maximum %d", field, n, max)
+		return 0
+	}
+	return int(n)
+}
+
+// count reads an element count, checking it against max and against the
+// smallest number of bytes each element must occupy so that a forged count
+// cannot trigger a large allocation.
+func (r *txReader) count(field string, max, minElemSize int) int {
+	n := r.length(field, max)
+	if r.err != nil {
+		return 0
+	}
+	if n*minElemSize > r.remaining() {
+		r.err = fmt.Errorf("%s %d is larger than the remaining %d bytes allow", field, n, r.remaining())
+		return 0
+	}
+	return n
+}
+
+// record reads a 4-byte length-prefixed record.
+func (r *txReader) record(field string) []byte {
+	return r.bytes(field, r.length(field+" length", MaxTxEncodedSize))
+}
@@ -0,0 +1,161 @@
+package chain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+)
+
+const (
+	// DefaultAddressHistoryLimit is the page size of an address history
+	// request that does not ask for one.
+	DefaultAddressHistoryLimit = 25
+	// MaxAddressHistoryLimit is the largest page of address history returned.
+	MaxAddressHistoryLimit = 100
+)
+
+// AddressTx is a transaction of the active chain affecting an address.
+type AddressTx struct {
+	TxHash        []byte `json:"tx_hash"`
+	BlockHash     []byte `json:"block_hash"`
+	Height        uint64 `json:"height"`
+	Received      uint64 `json:"received"` // Received is the value paid to the address.
+	Sent          uint64 `json:"sent"`     // Sent is the value of the address's outputs it spent.
+	Confirmations uint64 `json:"confirmations"`
+}
+
+// addressTx is an entry of the address index.
+type addressTx struct {
+	txHash    []byte
+	blockHash []byte
+	height    uint64
+	received  uint64
+	sent      uint64
+}
+
+// GetAddressHistory returns a page of the transactions affecting an address,
+// newest first. An empty cursor starts at the newest transaction; otherwise
+// the page continues after the transaction whose hex hash is the cursor. The
+// returned cursor continues with the next page and is empty after the last.
+func (c *Chain) GetAddressHistory(address string, cursor string, limit int) ([]*AddressTx, string, error) {
+	if !c.config.AddressIndex {
+		return nil, "", fmt.Errorf("address index is disabled")
+	}
+	if limit <= 0 {
+		limit = DefaultAddressHistoryLimit
+	}
+	if limit > MaxAddressHistoryLimit {
+		limit = MaxAddressHistoryLimit
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	history := c.addrHistory[address]
+	end := len(history)
+	if cursor != "" {
+		end = -1
+		for i := len(history) - 1; i >= 0; i-- {
+			if hex.EncodeToString(history[i].txHash) == cursor {
+				end = i
+				break
+			}
+		}
+		if end < 0 {
+			return nil, "", fmt.Errorf("cursor %s is not in the history of address %s", cursor, address)
+		}
+	}
+
+	page := make([]*AddressTx, 0, limit)
+	i := end - 1
+	for ; i >= 0 && len(page) < limit; i-- {
+		entry := history[i]
+		page = append(page, &AddressTx{
+			TxHash:        entry.txHash,
+			BlockHash:     entry.blockHash,
+			Height:        entry.height,
+			Received:      entry.received,
+			Sent:          entry.sent,
+			Confirmations: c.bestBlock.Header.Height - entry.height + 1,
+		})
+	}
+
+	next := ""
+	if i >= 0 && len(page) > 0 {
+		next = hex.EncodeToString(page[len(page)-1].TxHash)
+	}
+	return page, next, nil
+}
+
+// indexAddressesLocked adds a transaction connected to the active chain to
+// the history of every address it pays or spends from. The outputs it spends
+// must already be in the transaction index.
+// Note: the caller must hold the chain lock.
+func (c *Chain) indexAddressesLocked(tx *block.Transaction, blockHash []byte, height uint64) {
+	entries := make(map[string]*addressTx)
+	entry := func(address string) *addressTx {
+		e, exists := entries[address]
+		if !exists {
+			e = &addressTx{txHash: tx.Hash, blockHash: blockHash, height: height}
+			entries[address] = e
+			c.addrHistory[address] = append(c.addrHistory[address], e)
+		}
+		return e
+	}
+
+	for _, input := range tx.Inputs {
+		prev, exists := c.txIndex[string(input.PrevTxHash)]
+		if !exists {
+			continue
+		}
+		output, ok := prev.output(input.PrevTxIndex)
+		if !ok {
+			continue
+		}
+		entry(scriptAddress(output.ScriptPubKey)).sent += output.Value
+	}
+	for _, output := range tx.Outputs {
+		entry(scriptAddress(output.ScriptPubKey)).received += output.Value
+	}
+}
+
+// unindexAddressesLocked removes the address history entries of a
+// transaction of a block disconnected from the active chain, which are the
+// last entries of the addresses it pays or spends from.
+// Note: the caller must hold the chain lock.
+func (c *Chain) unindexAddressesLocked(tx *block.Transaction, blockHash []byte) {
+	var addresses []string
+	for _, input := range tx.Inputs {
+		prev, exists := c.txIndex[string(input.PrevTxHash)]
+		if !exists {
+			continue
+		}
+		if output, ok := prev.output(input.PrevTxIndex); ok {
+			addresses = append(addresses, scriptAddress(output.ScriptPubKey))
+		}
+	}
+	for _, output := range tx.Outputs {
+		addresses = append(addresses, scriptAddress(output.ScriptPubKey))
+	}
+
+	for _, address := range addresses {
+		history := c.addrHistory[address]
+		n := len(history)
+		if n == 0 || !bytes.Equal(history[n-1].txHash, tx.Hash) || !bytes.Equal(history[n-1].blockHash, blockHash) {
+			continue
+		}
+		if n == 1 {
+			delete(c.addrHistory, address)
+		} else {
+			c.addrHistory[address] = history[:n-1]
+		}
+	}
+}
+
+// scriptAddress returns the address an output script pays to, as the UTXO
+// set derives it.
+func scriptAddress(script []byte) string {
+	return hex.EncodeToString(script)
+}
@@ -0,0 +1,106 @@
+package chain
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/consensus"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAddressHistory(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	chain, err := NewChain(DefaultChainConfig(), consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+
+	alice, bob := testPayoutScript("alice"), testPayoutScript("bob")
+	newTx := func(inputs []*block.TxInput, outputs ...*block.TxOutput) *block.Transaction {
+		tx := &block.Transaction{Version: 1, Inputs: inputs, Outputs: outputs}
+		tx.Hash = tx.CalculateHash()
+		return tx
+	}
+
+	// Block 1 pays alice a reward, block 2 has her pay bob with change and
+	// block 3 has bob pay part of it back alongside another reward to alice
+	reward1 := newTx(nil, &block.TxOutput{Value: 1000000, ScriptPubKey: alice})
+	b1 := createValidTestBlock(chain.GetGenesisBlock(), 1, 1, []*block.Transaction{reward1})
+	require.NoError(t, chain.AddBlock(b1))
+
+	reward2 := newTx(nil, &block.TxOutput{Value: 1000000, ScriptPubKey: testPayoutScript("miner")})
+	pay := newTx([]*block.TxInput{{PrevTxHash: reward1.Hash, PrevTxIndex: 0}},
+		&block.TxOutput{Value: 600000, ScriptPubKey: bob},
+		&block.TxOutput{Value: 390000, ScriptPubKey: alice})
+	b2 := createValidTestBlock(b1, 2, 1, []*block.Transaction{reward2, pay})
+
+	reward3 := newTx(nil, &block.TxOutput{Value: 1000000, ScriptPubKey: alice})
+	refund := newTx([]*block.TxInput{{PrevTxHash: pay.Hash, PrevTxIndex: 0}},
+		&block.TxOutput{Value: 200000, ScriptPubKey: alice},
+		&block.TxOutput{Value: 390000, ScriptPubKey: bob})
+	b3 := createValidTestBlock(b2, 3, 1, []*block.Transaction{reward3, refund})
+
+	// Index the blocks as connecting them to the active chain would
+	chain.mu.Lock()
+	chain.indexBlockLocked(b2)
+	chain.indexBlockLocked(b3)
+	chain.bestBlock = b3
+	chain.mu.Unlock()
+
+	history, next, err := chain.GetAddressHistory(hex.EncodeToString(alice), "", 0)
+	require.NoError(t, err)
+	assert.Empty(t, next)
+	require.Len(t, history, 4)
+
+	// Newest first, with amounts received and sent by alice
+	expected := []struct {
+		tx             *block.Transaction
+		height         uint64
+		received, sent uint64
+		confirmations  uint64
+	}{
+		{refund, 3, 200000, 0, 1},
+		{reward3, 3, 1000000, 0, 1},
+		{pay, 2, 390000, 1000000, 2},
+		{reward1, 1, 1000000, 0, 3},
+	}
+	for i, want := range expected {
+		assert.Equal(t, want.tx.Hash, history[i].TxHash, "entry %d", i)
+		assert.Equal(t, want.height, history[i].Height, "entry %d", i)
+		assert.Equal(t, want.received, history[i].Received, "entry %d", i)
+		assert.Equal(t, want.sent, history[i].Sent, "entry %d", i)
+		assert.Equal(t, want.confirmations, history[i].Confirmations, "entry %d", i)
+	}
+
+	bobHistory, _, err := chain.GetAddressHistory(hex.EncodeToString(bob), "", 0)
+	require.NoError(t, err)
+	require.Len(t, bobHistory, 2)
+	assert.Equal(t, uint64(390000), bobHistory[0].Received)
+	assert.Equal(t, uint64(600000), bobHistory[0].Sent)
+
+	// Paging walks the same history in order
+	var paged []*AddressTx
+	cursor := ""
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 4)
+		page, next, err := chain.GetAddressHistory(hex.EncodeToString(alice), cursor, 3)
+		require.NoError(t, err)
+		paged = append(paged, page...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	assert.Equal(t, history, paged)
+
+	_, _, err = chain.GetAddressHistory(hex.EncodeToString(alice), "unknown", 3)
+	assert.Error(t, err)
+
+	history, _, err = chain.GetAddressHistory("nobody", "", 0)
+	require.NoError(t, err)
+	assert.Empty(t, history)
+}
@@ -0,0 +1,259 @@
+package chain
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+)
+
+// An archive holds the blocks of an active chain followed by an index that
+// locates each block by height, so that a range of heights can be imported
+// without reading the blocks before it:
+//
+//	magic (8) | network magic (4) | blocks | index | index offset (8) | entry count (8) | magic (8)
+//
+// Blocks are JSON encoded, as in storage, and index entries hold the height
+// (8), offset (8), length (4) and hash (32) of a block, sorted by height.
+// Integers are big-endian.
+
+// archiveMagic identifies a chain archive and its format version.
+var archiveMagic = []byte("ADRNARC1")
+
+const (
+	archiveHeaderSize  = 8 + 4
+	archiveTrailerSize = 8 + 8 + 8
+	archiveEntrySize   = 8 + 8 + 4 + 32
+)
+
+// ErrArchiveParentMissing is returned by ImportArchive when the first block
+// of the range does not extend a block the chain has.
+var ErrArchiveParentMissing = errors.New("parent of the first archived block is not in the chain")
+
+// archiveEntry locates a block in an archive.
+type archiveEntry struct {
+	height uint64
+	offset uint64
+	length uint32
+	hash   []byte
+}
+
+// ExportArchive writes the blocks of the active chain, from genesis to the
+// tip, to an archive at path. Pruned blocks are left out, so an archive of a
+// pruned chain holds genesis and the blocks above the pruned height.
+func (c *Chain) ExportArchive(path string) error {
+	magic := c.NetworkMagic()
+
+	c.mu.RLock()
+	var blocks []*block.Block
+	for b := c.bestBlock; b != nil && b.Header.Height > 0; b = c.blocks[string(b.Header.PrevBlockHash)] {
+		blocks = append(blocks, b)
+	}
+	blocks = append(blocks, c.genesisBlock)
+	c.mu.RUnlock()
+
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].Header.Height < blocks[j].Header.Height })
+
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer os.Remove(tmp)
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	header := make([]byte, archiveHeaderSize)
+	copy(header, archiveMagic)
+	binary.BigEndian.PutUint32(header[8:], magic)
+	if _, err := w.Write(header); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	offset := uint64(archiveHeaderSize)
+	entries := make([]archiveEntry, 0, len(blocks))
+	for _, b := range blocks {
+		data, err := json.Marshal(b)
+		if err != nil {
+			return fmt.Errorf("failed to encode block at height %d: %w", b.Header.Height, err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return fmt.Errorf("failed to write archive: %w", err)
+		}
+		entries = append(entries, archiveEntry{
+			height: b.Header.Height,
+			offset: offset,
+			length: uint32(len(data)),
+			hash:   b.CalculateHash(),
+		})
+		offset += uint64(len(data))
+	}
+
+	for _, entry := range entries {
+		if _, err := w.Write(entry.encode()); err != nil {
+			return fmt.Errorf("failed to write archive index: %w", err)
+		}
+	}
+	trailer := make([]byte, archiveTrailerSize)
+	binary.BigEndian.PutUint64(trailer, offset)
+	binary.BigEndian.PutUint64(trailer[8:], uint64(len(entries)))
+	copy(trailer[16:], archiveMagic)
+	if _, err := w.Write(trailer); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// ImportArchive adds the blocks from fromHeight to toHeight of the archive
+// at path to the chain, seeking to them through the archive's index. Blocks
+// the chain already has are skipped. The block at fromHeight must extend a
+// block of the chain, or ErrArchiveParentMissing is returned before any
+// block is added.
+func (c *Chain) ImportArchive(path string, fromHeight, toHeight uint64) error {
+	if fromHeight > toHeight {
+		return fmt.Errorf("invalid import range: from height %d is above to height %d", fromHeight, toHeight)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	entries, err := c.readArchiveIndex(file)
+	if err != nil {
+		return err
+	}
+
+	first := sort.Search(len(entries), func(i int) bool { return entries[i].height >= fromHeight })
+	var selected []archiveEntry
+	for i := first; i < len(entries) && entries[i].height <= toHeight; i++ {
+		if entries[i].height != fromHeight+uint64(len(selected)) {
+			break
+		}
+		selected = append(selected, entries[i])
+	}
+	if uint64(len(selected)) != toHeight-fromHeight+1 {
+		return fmt.Errorf("archive has no block at height %d", fromHeight+uint64(len(selected)))
+	}
+
+	for i, entry := range selected {
+		b, err := readArchivedBlock(file, entry)
+		if err != nil {
+			return err
+		}
+		if i == 0 && b.Header.Height > 0 && c.GetBlock(b.Header.PrevBlockHash) == nil {
+			return fmt.Errorf("%w: block at height %d extends %x", ErrArchiveParentMissing, b.Header.Height, b.Header.PrevBlockHash)
+		}
+		if c.GetBlock(entry.hash) != nil {
+			continue
+		}
+		if b.Header.Height == 0 {
+			return fmt.Errorf("archive genesis block %x differs from the chain's", entry.hash)
+		}
+		if err := c.AddBlock(b); err != nil {
+			return fmt.Errorf("failed to import block at height %d: %w", entry.height, err)
+		}
+	}
+	return nil
+}
+
+// readArchiveIndex checks the archive's magic and network and returns its
+// index.
+func (c *Chain) readArchiveIndex(file *os.File) ([]archiveEntry, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	size := uint64(info.Size())
+	if size < archiveHeaderSize+archiveTrailerSize {
+		return nil, fmt.Errorf("archive is truncated")
+	}
+
+	header := make([]byte, archiveHeaderSize)
+	if _, err := file.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	trailer := make([]byte, archiveTrailerSize)
+	if _, err := file.ReadAt(trailer, int64(size-archiveTrailerSize)); err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	if !bytes.Equal(header[:8], archiveMagic) || !bytes.Equal(trailer[16:], archiveMagic) {
+		return nil, fmt.Errorf("not a chain archive")
+	}
+	if magic := binary.BigEndian.Uint32(header[8:]); magic != c.NetworkMagic() {
+		return nil, fmt.Errorf("archive is for network %08x, chain is on network %08x", magic, c.NetworkMagic())
+	}
+
+	indexOffset := binary.BigEndian.Uint64(trailer)
+	count := binary.BigEndian.Uint64(trailer[8:])
+	if indexOffset < archiveHeaderSize || indexOffset > size-archiveTrailerSize ||
+		(size-archiveTrailerSize-indexOffset)/archiveEntrySize != count ||
+		(size-archiveTrailerSize-indexOffset)%archiveEntrySize != 0 {
+		return nil, fmt.Errorf("archive index is corrupt")
+	}
+
+	index := make([]byte, count*archiveEntrySize)
+	if _, err := file.ReadAt(index, int64(indexOffset)); err != nil {
+		return nil, fmt.Errorf("failed to read archive index: %w", err)
+	}
+	entries := make([]archiveEntry, count)
+	for i := range entries {
+		entries[i] = decodeArchiveEntry(index[i*archiveEntrySize : (i+1)*archiveEntrySize])
+		if entries[i].offset+uint64(entries[i].length) > indexOffset {
+			return nil, fmt.Errorf("archive index entry for height %d points past the blocks", entries[i].height)
+		}
+	}
+	return entries, nil
+}
+
+// readArchivedBlock reads the block an index entry points to and checks it
+// against the entry.
+func readArchivedBlock(r io.ReaderAt, entry archiveEntry) (*block.Block, error) {
+	data := make([]byte, entry.length)
+	if _, err := r.ReadAt(data, int64(entry.offset)); err != nil {
+		return nil, fmt.Errorf("failed to read archived block at height %d: %w", entry.height, err)
+	}
+	var b block.Block
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("failed to decode archived block at height %d: %w", entry.height, err)
+	}
+	if b.Header == nil || b.Header.Height != entry.height || !bytes.Equal(b.CalculateHash(), entry.hash) {
+		return nil, fmt.Errorf("archived block at height %d does not match the archive index", entry.height)
+	}
+	return &b, nil
+}
+
+// encode returns the index record of the entry.
+func (e archiveEntry) encode() []byte {
+	data := make([]byte, archiveEntrySize)
+	binary.BigEndian.PutUint64(data, e.height)
+	binary.BigEndian.PutUint64(data[8:], e.offset)
+	binary.BigEndian.PutUint32(data[16:], e.length)
+	copy(data[20:], e.hash)
+	return data
+}
+
+// decodeArchiveEntry parses an index record.
+func decodeArchiveEntry(data []byte) archiveEntry {
+	return archiveEntry{
+		height: binary.BigEndian.Uint64(data),
+		offset: binary.BigEndian.Uint64(data[8:]),
+		length: binary.BigEndian.Uint32(data[16:]),
+		hash:   append([]byte(nil), data[20:archiveEntrySize]...),
+	}
+}
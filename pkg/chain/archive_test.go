@@ -0,0 +1,72 @@
+package chain
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/consensus"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	newChain := func() *Chain {
+		storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+		require.NoError(t, err)
+		t.Cleanup(func() { storageInstance.Close() })
+		chain, err := NewChain(DefaultChainConfig(), consensus.DefaultConsensusConfig(), storageInstance)
+		require.NoError(t, err)
+		return chain
+	}
+
+	source := newChain()
+	blocks := []*block.Block{source.GetGenesisBlock()}
+	for height := uint64(1); height <= 8; height++ {
+		b := createEmptyTestBlock(blocks[height-1], height, 1)
+		require.NoError(t, source.AddBlock(b))
+		blocks = append(blocks, b)
+	}
+
+	path := filepath.Join(t.TempDir(), "chain.archive")
+	require.NoError(t, source.ExportArchive(path))
+
+	// A range whose parent the chain lacks is refused before adding anything
+	target := newChain()
+	err := target.ImportArchive(path, 4, 6)
+	require.True(t, errors.Is(err, ErrArchiveParentMissing), "unexpected error: %v", err)
+	assert.Contains(t, err.Error(), "block at height 4 extends")
+	assert.Equal(t, uint64(0), target.GetHeight())
+
+	// Importing in parts reaches the same chain as the source
+	require.NoError(t, target.ImportArchive(path, 0, 3))
+	assert.Equal(t, uint64(3), target.GetHeight())
+	assert.Equal(t, blocks[3].CalculateHash(), target.GetBestBlock().CalculateHash())
+
+	require.NoError(t, target.ImportArchive(path, 4, 6))
+	assert.Equal(t, uint64(6), target.GetHeight())
+	for height := uint64(1); height <= 6; height++ {
+		imported := target.GetBlockByHeight(height)
+		require.NotNil(t, imported)
+		assert.Equal(t, blocks[height].CalculateHash(), imported.CalculateHash())
+	}
+	assert.Nil(t, target.GetBlock(blocks[7].CalculateHash()), "blocks above the range are not imported")
+
+	assert.ErrorContains(t, target.ImportArchive(path, 7, 9), "archive has no block at height 9")
+	assert.ErrorContains(t, target.ImportArchive(path, 6, 5), "invalid import range")
+
+	require.NoError(t, target.ImportArchive(path, 5, 8), "already imported blocks are skipped")
+	assert.Equal(t, source.GetBestBlock().CalculateHash(), target.GetBestBlock().CalculateHash())
+	assert.Equal(t, source.UTXOSet.GetStats(), target.UTXOSet.GetStats())
+
+	// An index offset pointing past the trailer is corrupt, not a huge index
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	binary.BigEndian.PutUint64(data[len(data)-archiveTrailerSize:], uint64(len(data)))
+	require.NoError(t, os.WriteFile(path, data, 0644))
+	assert.ErrorContains(t, target.ImportArchive(path, 0, 8), "archive index is corrupt")
+}
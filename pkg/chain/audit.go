@@ -0,0 +1,54 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// AuditUTXOSet checks the invariants of the UTXO set while no block is
+// being connected, counting failed audits.
+func (c *Chain) AuditUTXOSet() error {
+	c.mu.RLock()
+	err := c.UTXOSet.Audit()
+	c.mu.RUnlock()
+
+	if err != nil {
+		c.auditFailures.Add(1)
+	}
+	return err
+}
+
+// UTXOAuditFailures returns the number of UTXO set audits that found an
+// inconsistency.
+func (c *Chain) UTXOAuditFailures() uint64 {
+	return c.auditFailures.Load()
+}
+
+// StartUTXOAudit audits the UTXO set every AuditInterval until ctx is done,
+// logging inconsistencies and passing them to onFailure, which may be nil.
+// It does nothing if AuditInterval is zero.
+func (c *Chain) StartUTXOAudit(ctx context.Context, onFailure func(error)) {
+	if c.config.AuditInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(c.config.AuditInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := c.AuditUTXOSet(); err != nil {
+					fmt.Printf("UTXO set audit failed: %v\n", err)
+					if onFailure != nil {
+						onFailure(err)
+					}
+				}
+			}
+		}
+	}()
+}
@@ -1,6 +1,9 @@
 package chain
 
 import (
+	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -12,6 +15,7 @@ import (
 	"github.com/palaseus/adrenochain/pkg/block"
 	"github.com/palaseus/adrenochain/pkg/consensus"
 	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/palaseus/adrenochain/pkg/utxo"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -23,6 +27,14 @@ type MockFailingStorage struct {
 	failOnStoreChainState bool
 	failOnLoadBlocks      bool
 	failOnGetBlock        bool
+	failOnWrite           bool
+}
+
+func (m *MockFailingStorage) Write(key []byte, value []byte) error {
+	if m.failOnWrite {
+		return fmt.Errorf("mock error: Write failed")
+	}
+	return m.StorageInterface.Write(key, value)
 }
 
 func (m *MockFailingStorage) GetChainState() (*storage.ChainState, error) {
@@ -74,6 +86,30 @@ func TestNewChain(t *testing.T) {
 	assert.NotNil(t, chain.GetGenesisBlock())
 }
 
+// TestGenesisDifficulty tests that the genesis block and the first mined
+// block use the configured genesis difficulty.
+func TestGenesisDifficulty(t *testing.T) {
+	dataDir := "./test_chain_genesis_difficulty"
+	defer os.RemoveAll(dataDir)
+
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: dataDir})
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storageInstance.Close()
+
+	consensusConfig := consensus.DefaultConsensusConfig()
+	consensusConfig.GenesisDifficulty = 4
+	consensusConfig.MinDifficulty = 2
+	chain, err := NewChain(DefaultChainConfig(), consensusConfig, storageInstance)
+	if err != nil {
+		t.Fatalf("NewChain returned error: %v", err)
+	}
+
+	assert.Equal(t, uint64(4), chain.GetGenesisBlock().Header.Difficulty)
+	assert.Equal(t, uint64(4), chain.CalculateNextDifficulty())
+}
+
 // TestNewChainWithNilConfig tests NewChain with nil config
 func TestNewChainWithNilConfig(t *testing.T) {
 	dataDir := "./test_chain_nil_config"
@@ -1980,6 +2016,44 @@ func TestChainValidateBlockEdgeCases(t *testing.T) {
 	_ = err // May fail due to validation, but we're testing function structure
 }
 
+func TestChainMaxTransactionsPerBlock(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer storageInstance.Close()
+
+	config := DefaultChainConfig()
+	config.MaxTransactionsPerBlock = 3
+	chain, err := NewChain(config, consensus.DefaultConsensusConfig(), storageInstance)
+	if err != nil {
+		t.Fatalf("NewChain returned error: %v", err)
+	}
+
+	var transactions []*block.Transaction
+	for i := 0; i < 4; i++ {
+		tx := &block.Transaction{
+			Version: 1,
+			Outputs: []*block.TxOutput{{Value: 1000, ScriptPubKey: []byte(fmt.Sprintf("COUNT_TEST_%d", i))}},
+		}
+		tx.Hash = tx.CalculateHash()
+		transactions = append(transactions, tx)
+	}
+
+	b := createValidTestBlock(chain.GetGenesisBlock(), 1, 1, transactions)
+	err = chain.AddBlock(b)
+	if err == nil || !strings.Contains(err.Error(), "transaction count 4 exceeds maximum 3") {
+		t.Fatalf("expected transaction count error, got %v", err)
+	}
+	if chain.GetHeight() != 0 {
+		t.Fatalf("block over the transaction count limit was accepted")
+	}
+
+	if err := chain.CheckBlockLimits(createValidTestBlock(chain.GetGenesisBlock(), 1, 1, transactions[:3])); err != nil {
+		t.Fatalf("block at the transaction count limit rejected: %v", err)
+	}
+}
+
 func TestChainIsBetterChainEdgeCases(t *testing.T) {
 	dataDir := "./test_chain_better_chain_edge"
 	defer os.RemoveAll(dataDir)
@@ -3366,12 +3440,18 @@ func TestRebuildAccumulatedDifficultyComplexChain(t *testing.T) {
 
 // createValidTestBlock creates a valid test block with proper Merkle root
 func createValidTestBlock(prevBlock *block.Block, height uint64, difficulty uint64, transactions []*block.Transaction) *block.Block {
+	// Blocks made within a second of their parent are dated a second later,
+	// to stay after the median time past
+	timestamp := time.Now()
+	if next := prevBlock.Header.Timestamp.Add(time.Second); timestamp.Before(next) {
+		timestamp = next
+	}
 	block := &block.Block{
 		Header: &block.Header{
 			Version:       1,
 			PrevBlockHash: prevBlock.CalculateHash(),
 			MerkleRoot:    make([]byte, 32), // Will be calculated
-			Timestamp:     time.Now(),
+			Timestamp:     timestamp,
 			Difficulty:    difficulty,
 			Nonce:         0,
 			Height:        height,
@@ -3397,7 +3477,7 @@ func createEmptyTestBlock(prevBlock *block.Block, height uint64, difficulty uint
 		Outputs: []*block.TxOutput{
 			{
 				Value:        1000000, // 1 million units
-				ScriptPubKey: []byte(fmt.Sprintf("COINBASE_TEST_%d", height)),
+				ScriptPubKey: testPayoutScript(fmt.Sprintf("COINBASE_TEST_%d", height)),
 			},
 		},
 		LockTime: 0,
@@ -3410,6 +3490,13 @@ func createEmptyTestBlock(prevBlock *block.Block, height uint64, difficulty uint
 	return createValidTestBlock(prevBlock, height, difficulty, []*block.Transaction{coinbaseTx})
 }
 
+// testPayoutScript returns a public key hash script unique to label, which
+// coinbase outputs may pay to.
+func testPayoutScript(label string) []byte {
+	hash := sha256.Sum256([]byte(label))
+	return hash[:utxo.P2PKHScriptSize]
+}
+
 // mineTestBlock mines a test block to find a valid nonce for the given difficulty
 func mineTestBlock(block *block.Block, difficulty uint64) {
 	// For testing, we'll use a simple mining approach
@@ -3493,3 +3580,129 @@ func hashLessThan(hash1, hash2 []byte) bool {
 	}
 	return false
 }
+
+func TestChainConfigValidate(t *testing.T) {
+	assert.NoError(t, DefaultChainConfig().Validate())
+
+	config := DefaultChainConfig()
+	config.MaxBlockSize = 0
+	config.MaxReorgDepth = 0
+	config.InvalidBlockCacheSize = -1
+	err := config.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "max block size must be positive")
+	assert.Contains(t, err.Error(), "max reorg depth must be positive")
+	assert.Contains(t, err.Error(), "invalid block cache size -1 is negative")
+}
+
+func TestNetworkMagic(t *testing.T) {
+	newTestChain := func(config *ChainConfig) *Chain {
+		storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+		if err != nil {
+			t.Fatalf("Failed to create storage: %v", err)
+		}
+		t.Cleanup(func() { storageInstance.Close() })
+		chain, err := NewChain(config, consensus.DefaultConsensusConfig(), storageInstance)
+		if err != nil {
+			t.Fatalf("NewChain returned error: %v", err)
+		}
+		return chain
+	}
+
+	mainnet := newTestChain(DefaultChainConfig()).NetworkMagic()
+	assert.Equal(t, mainnet, newTestChain(DefaultChainConfig()).NetworkMagic(), "magic must be deterministic")
+
+	testnetConfig := DefaultChainConfig()
+	testnetConfig.Network = "testnet"
+	assert.NotEqual(t, mainnet, newTestChain(testnetConfig).NetworkMagic())
+
+	otherGenesis := DefaultChainConfig()
+	otherGenesis.GenesisBlockReward++
+	assert.NotEqual(t, mainnet, newTestChain(otherGenesis).NetworkMagic())
+}
+
+func TestChainUTXOAudit(t *testing.T) {
+	config := DefaultChainConfig()
+	config.AuditInterval = 10 * time.Millisecond
+	s, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer s.Close()
+	chain, err := NewChain(config, consensus.DefaultConsensusConfig(), s)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+
+	if err := chain.AuditUTXOSet(); err != nil {
+		t.Fatalf("audit of a fresh chain failed: %v", err)
+	}
+
+	// Adding an existing output again credits its address twice, the kind
+	// of bookkeeping bug the background audit catches
+	genesisTx := chain.GetGenesisBlock().Transactions[0]
+	existing := chain.UTXOSet.GetUTXO(genesisTx.Hash, 0)
+	if existing == nil {
+		t.Fatal("genesis output not found")
+	}
+	chain.UTXOSet.AddUTXOSafe(existing)
+
+	failures := make(chan error, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	chain.StartUTXOAudit(ctx, func(err error) {
+		select {
+		case failures <- err:
+		default:
+		}
+	})
+
+	select {
+	case err := <-failures:
+		if !errors.Is(err, utxo.ErrInconsistentUTXOSet) {
+			t.Fatalf("unexpected audit error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("audit did not detect the inconsistency")
+	}
+	if chain.UTXOAuditFailures() == 0 {
+		t.Fatal("audit failure was not counted")
+	}
+}
+
+func TestBlockTimeWhileAddingBlocks(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	defer storageInstance.Close()
+	chain, err := NewChain(DefaultChainConfig(), consensus.DefaultConsensusConfig(), storageInstance)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+
+	// Block times are read concurrently, as the mempool does, while blocks
+	// are added; run with -race to catch unsynchronized access
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			chain.BlockTime(uint64(i % 5))
+		}
+	}()
+	prev := chain.GetGenesisBlock()
+	for height := uint64(1); height <= 4; height++ {
+		next := createEmptyTestBlock(prev, height, 1)
+		if err := chain.AddBlock(next); err != nil {
+			t.Fatalf("failed to add block %d: %v", height, err)
+		}
+		prev = next
+	}
+	<-done
+
+	blockTime, ok := chain.BlockTime(4)
+	assert.True(t, ok)
+	assert.Equal(t, prev.Header.Timestamp, blockTime)
+	_, ok = chain.BlockTime(5)
+	assert.False(t, ok)
+}
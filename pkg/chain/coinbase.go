@@ -0,0 +1,93 @@
+package chain
+
+import (
+	"fmt"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/utxo"
+)
+
+// MaxCoinbaseOutputs is the consensus limit on the outputs of a coinbase
+// transaction, enough for a pool to pay its miners directly.
+const MaxCoinbaseOutputs = 500
+
+// checkCoinbase checks the coinbase of a block: it pays out through at least
+// one and at most MaxCoinbaseOutputs outputs, each to a valid payout script,
+// and claims no more than the block subsidy plus the fees of the
+// block's other transactions.
+func (c *Chain) checkCoinbase(b *block.Block, fees uint64) error {
+	coinbase := b.Transactions[0]
+	if len(coinbase.Outputs) == 0 {
+		return fmt.Errorf("coinbase has no outputs")
+	}
+	if len(coinbase.Outputs) > MaxCoinbaseOutputs {
+		return fmt.Errorf("coinbase output count %d exceeds maximum %d", len(coinbase.Outputs), MaxCoinbaseOutputs)
+	}
+
+	var total uint64
+	for i, output := range coinbase.Outputs {
+		if err := c.checkPayoutScript(output); err != nil {
+			return fmt.Errorf("coinbase output %d: %w", i, err)
+		}
+		if total+output.Value < total {
+			return fmt.Errorf("coinbase output values overflow")
+		}
+		total += output.Value
+	}
+
+	allowed := c.BlockSubsidy(b.Header.Height)
+	if b.Header.Height == 0 {
+		allowed = c.config.GenesisBlockReward
+	}
+	if allowed+fees < allowed {
+		return fmt.Errorf("block subsidy and fees overflow")
+	}
+	allowed += fees
+	if total > allowed {
+		return fmt.Errorf("coinbase pays %d, more than subsidy and fees of %d", total, allowed)
+	}
+	return nil
+}
+
+// checkPayoutScript checks that a coinbase output pays to a script that can
+// be spent: a 20-byte public key hash or a script template with a spend
+// validator. Other scripts would lock the payout forever without it being
+// counted as burned. Provably unspendable outputs are allowed for data and
+// burns.
+func (c *Chain) checkPayoutScript(output *block.TxOutput) error {
+	if len(output.ScriptPubKey) == 0 {
+		return fmt.Errorf("empty payout script")
+	}
+	if IsUnspendableScript(output.ScriptPubKey) {
+		return nil
+	}
+	template, _ := utxo.ParseScriptTemplate(output.ScriptPubKey)
+	if template == utxo.ScriptTemplateP2PKH {
+		if len(output.ScriptPubKey) != utxo.P2PKHScriptSize {
+			return fmt.Errorf("payout script of %d bytes is neither a public key hash nor a script template", len(output.ScriptPubKey))
+		}
+		return nil
+	}
+	if _, exists := c.UTXOSet.SpendAuthRegistry().Validator(template); !exists {
+		return fmt.Errorf("payout script template %q has no spend validator", template)
+	}
+	return nil
+}
+
+// transactionFee returns the value of the outputs a transaction spends, as
+// found in view before it is applied, less the value of its outputs.
+func transactionFee(tx *block.Transaction, view utxo.UTXOView) uint64 {
+	var in, out uint64
+	for _, input := range tx.Inputs {
+		if prev := view.GetUTXO(input.PrevTxHash, input.PrevTxIndex); prev != nil {
+			in += prev.Value
+		}
+	}
+	for _, output := range tx.Outputs {
+		out += output.Value
+	}
+	if in < out {
+		return 0
+	}
+	return in - out
+}
@@ -0,0 +1,63 @@
+package chain
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/consensus"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/palaseus/adrenochain/pkg/utxo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoinbaseValidation(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	chain, err := NewChain(DefaultChainConfig(), consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+	genesis := chain.GetGenesisBlock()
+	subsidy := chain.BlockSubsidy(1)
+
+	coinbaseBlock := func(outputs ...*block.TxOutput) *block.Block {
+		coinbase := &block.Transaction{Version: 1, Outputs: outputs}
+		coinbase.Hash = coinbase.CalculateHash()
+		return createValidTestBlock(genesis, 1, 1, []*block.Transaction{coinbase})
+	}
+
+	// Paying more than the subsidy when the block has no fees
+	err = chain.AddBlock(coinbaseBlock(&block.TxOutput{Value: subsidy + 1, ScriptPubKey: testPayoutScript("miner")}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "more than subsidy and fees")
+
+	// Paying to a script template nobody can spend
+	unknown, err := utxo.NewTemplateScript("unregistered", []byte("payload"))
+	require.NoError(t, err)
+	err = chain.AddBlock(coinbaseBlock(&block.TxOutput{Value: subsidy, ScriptPubKey: unknown}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "has no spend validator")
+
+	err = chain.AddBlock(coinbaseBlock(&block.TxOutput{Value: subsidy, ScriptPubKey: nil}))
+	require.Error(t, err)
+
+	// Paying to bytes that are neither a public key hash nor a template
+	err = chain.AddBlock(coinbaseBlock(&block.TxOutput{Value: subsidy, ScriptPubKey: []byte("miner")}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "payout script of 5 bytes is neither a public key hash nor a script template")
+
+	// More pool payouts than allowed
+	var payouts []*block.TxOutput
+	for i := 0; i <= MaxCoinbaseOutputs; i++ {
+		payouts = append(payouts, &block.TxOutput{Value: 1, ScriptPubKey: testPayoutScript(fmt.Sprintf("miner-%d", i))})
+	}
+	err = chain.AddBlock(coinbaseBlock(payouts...))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), fmt.Sprintf("coinbase output count %d exceeds maximum %d", MaxCoinbaseOutputs+1, MaxCoinbaseOutputs))
+
+	// Splitting the subsidy across the allowed number of payouts
+	require.NoError(t, chain.AddBlock(coinbaseBlock(payouts[:MaxCoinbaseOutputs]...)))
+	assert.Equal(t, uint64(1), chain.GetHeight())
+}
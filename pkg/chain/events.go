@@ -0,0 +1,73 @@
+package chain
+
+import (
+	"sort"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+)
+
+// BlockEvent notifies a handler that a block was connected to or
+// disconnected from the active chain.
+type BlockEvent struct {
+	Block        *block.Block
+	Disconnected bool
+}
+
+// OnBlockEvent registers a handler called with every block connected to or
+// disconnected from the active chain, in order: when the chain switches
+// branches, the blocks disconnected, tip first, then the blocks connected,
+// lowest first. Handlers run once the chain lock is released, so they may
+// query the chain and see it at its new tip, but must not add, invalidate
+// or reconsider blocks.
+func (c *Chain) OnBlockEvent(handler func(BlockEvent)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.blockHandlers = append(c.blockHandlers, handler)
+}
+
+// queueBlockEventLocked queues an event for the handlers, delivered by
+// unlockAndNotify.
+// Note: the caller must hold the chain lock.
+func (c *Chain) queueBlockEventLocked(b *block.Block, disconnected bool) {
+	if len(c.blockHandlers) == 0 {
+		return
+	}
+	c.pendingEvents = append(c.pendingEvents, BlockEvent{Block: b, Disconnected: disconnected})
+}
+
+// queueReorgEventsLocked queues the events of a switch of the active chain:
+// the blocks disconnected, tip first, then the blocks connected, lowest
+// first.
+// Note: the caller must hold the chain lock.
+func (c *Chain) queueReorgEventsLocked(disconnected map[string]bool, connected []*block.Block) {
+	var undone []*block.Block
+	for hash := range disconnected {
+		if b, exists := c.blocks[hash]; exists {
+			undone = append(undone, b)
+		}
+	}
+	sort.Slice(undone, func(i, j int) bool { return undone[i].Header.Height > undone[j].Header.Height })
+	for _, b := range undone {
+		c.queueBlockEventLocked(b, true)
+	}
+	for _, b := range connected {
+		c.queueBlockEventLocked(b, false)
+	}
+}
+
+// unlockAndNotify releases the chain lock and delivers the events queued
+// while it was held. Deliveries are serialized in the order the chain
+// changed.
+func (c *Chain) unlockAndNotify() {
+	events, handlers := c.pendingEvents, c.blockHandlers
+	c.pendingEvents = nil
+	c.notifyMu.Lock()
+	defer c.notifyMu.Unlock()
+	c.mu.Unlock()
+
+	for _, event := range events {
+		for _, handler := range handlers {
+			handler(event)
+		}
+	}
+}
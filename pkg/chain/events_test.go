@@ -0,0 +1,61 @@
+package chain
+
+import (
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/consensus"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockEvents(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	chain, err := NewChain(DefaultChainConfig(), consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+
+	type seen struct {
+		hash         string
+		disconnected bool
+		tip          string
+	}
+	var events []seen
+	chain.OnBlockEvent(func(event BlockEvent) {
+		// Handlers run unlocked and see the chain at its new tip
+		events = append(events, seen{string(event.Block.CalculateHash()), event.Disconnected, string(chain.GetTipHash())})
+	})
+
+	// Active chain: genesis - a1 - a2 - a3, side branch a1 - b2
+	a1 := createEmptyTestBlock(chain.GetGenesisBlock(), 1, 1)
+	require.NoError(t, chain.AddBlock(a1))
+	a2 := createEmptyTestBlock(a1, 2, 1)
+	require.NoError(t, chain.AddBlock(a2))
+	a3 := createEmptyTestBlock(a2, 3, 1)
+	require.NoError(t, chain.AddBlock(a3))
+	b2 := createTestBlockWithScript(a1, 2, "EVENTS_B_2")
+	require.NoError(t, chain.AddBlock(b2))
+
+	assert.Equal(t, []seen{
+		{string(a1.CalculateHash()), false, string(a1.CalculateHash())},
+		{string(a2.CalculateHash()), false, string(a2.CalculateHash())},
+		{string(a3.CalculateHash()), false, string(a3.CalculateHash())},
+	}, events, "side branch blocks are not connected")
+
+	// Invalidating a2 disconnects a3 then a2 and connects b2
+	events = nil
+	require.NoError(t, chain.InvalidateBlock(a2.CalculateHash()))
+	tip := string(b2.CalculateHash())
+	assert.Equal(t, []seen{
+		{string(a3.CalculateHash()), true, tip},
+		{string(a2.CalculateHash()), true, tip},
+		{tip, false, tip},
+	}, events)
+
+	// A failed operation delivers nothing
+	events = nil
+	assert.Error(t, chain.InvalidateBlock(chain.GetGenesisBlock().CalculateHash()))
+	assert.Empty(t, events)
+}
@@ -0,0 +1,103 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/palaseus/adrenochain/pkg/storage"
+)
+
+// updateChainStateLocked records a new tip, writing the chain state once
+// StateFlushBlocks tip updates are buffered or StateFlushInterval has passed
+// since the last write. The block and its height index entry must already
+// be stored so that buffered updates can be replayed after a crash.
+// Note: the caller must hold the chain lock.
+func (c *Chain) updateChainStateLocked(state *storage.ChainState) error {
+	c.unflushedBlocks++
+	if c.unflushedBlocks < c.config.StateFlushBlocks &&
+		(c.config.StateFlushInterval <= 0 || c.now().Sub(c.lastFlush) < c.config.StateFlushInterval) {
+		return nil
+	}
+	return c.storeChainStateLocked(state)
+}
+
+// FlushState writes the chain state of the current tip if tip updates are
+// buffered. It is called by Close and should be called before shutdown.
+func (c *Chain) FlushState() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.flushStateLocked()
+}
+
+// flushStateLocked writes the chain state of the current tip if tip updates
+// are buffered.
+// Note: the caller must hold the chain lock.
+func (c *Chain) flushStateLocked() error {
+	if c.unflushedBlocks == 0 || c.bestBlock == nil {
+		return nil
+	}
+	return c.storeChainStateLocked(&storage.ChainState{
+		BestBlockHash: c.tipHash,
+		Height:        c.bestBlock.Header.Height,
+	})
+}
+
+// StartStateFlush writes buffered tip updates every StateFlushInterval until
+// ctx is done, so that the chain state catches up while no blocks arrive. It
+// does nothing if StateFlushInterval is zero.
+func (c *Chain) StartStateFlush(ctx context.Context) {
+	if c.config.StateFlushInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(c.config.StateFlushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := c.FlushState(); err != nil {
+					fmt.Printf("Chain state flush failed: %v\n", err)
+				}
+			}
+		}
+	}()
+}
+
+// replayUnflushedLocked connects the blocks of the height index above the
+// stored tip. They were accepted while their chain state update was
+// buffered and the node stopped before flushing it, e.g. because it
+// crashed. The chain is replayed from genesis to rebuild the UTXO set and
+// indexes, and the chain state is written with the last replayed block as
+// tip.
+// Note: the caller must hold the chain lock.
+func (c *Chain) replayUnflushedLocked() error {
+	if c.bestBlock == nil {
+		return nil
+	}
+
+	tip := c.bestBlock
+	replayed := 0
+	for {
+		next, err := c.loadIndexedBlock(tip.Header.Height+1, tip)
+		if err != nil {
+			break
+		}
+		c.blocks[string(next.CalculateHash())] = next
+		tip = next
+		replayed++
+	}
+	if replayed == 0 {
+		return nil
+	}
+
+	fmt.Printf("Replaying %d blocks accepted after the last chain state flush\n", replayed)
+	if err := c.replayBranchLocked(tip, false); err != nil {
+		return fmt.Errorf("failed to replay unflushed blocks: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package chain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/consensus"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateFlushReplayAfterCrash(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+
+	config := DefaultChainConfig()
+	config.StateFlushBlocks = 3
+	chain, err := NewChain(config, consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+
+	storedHeight := func() uint64 {
+		state, err := storageInstance.GetChainState()
+		require.NoError(t, err)
+		return state.Height
+	}
+
+	blocks := []*block.Block{chain.GetGenesisBlock()}
+	for height := uint64(1); height <= 5; height++ {
+		b := createEmptyTestBlock(blocks[height-1], height, 1)
+		require.NoError(t, chain.AddBlock(b))
+		blocks = append(blocks, b)
+	}
+
+	// The chain state is written every third block only
+	assert.Equal(t, uint64(5), chain.GetHeight())
+	assert.Equal(t, uint64(3), storedHeight())
+
+	// The node crashes before flushing; the restarted node replays the
+	// buffered blocks from storage
+	recovered, err := NewChain(config, consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), recovered.GetHeight())
+	assert.Equal(t, blocks[5].CalculateHash(), recovered.GetTipHash())
+	assert.NotNil(t, recovered.UTXOSet.GetUTXO(blocks[5].Transactions[0].Hash, 0))
+	assert.Equal(t, recovered.UTXOSet.GetStats()["total_value"], recovered.GetTotalSupply().Issued)
+	assert.Equal(t, uint64(5), storedHeight())
+
+	// A flush on shutdown writes buffered updates
+	b := createEmptyTestBlock(blocks[5], 6, 1)
+	require.NoError(t, recovered.AddBlock(b))
+	assert.Equal(t, uint64(5), storedHeight())
+	require.NoError(t, recovered.FlushState())
+	assert.Equal(t, uint64(6), storedHeight())
+}
+
+func TestStateFlushInterval(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+
+	config := DefaultChainConfig()
+	config.StateFlushBlocks = 100
+	config.StateFlushInterval = time.Minute
+	chain, err := NewChain(config, consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+	now := time.Now()
+	chain.now = func() time.Time { return now }
+	chain.lastFlush = now
+
+	storedHeight := func() uint64 {
+		state, err := storageInstance.GetChainState()
+		require.NoError(t, err)
+		return state.Height
+	}
+
+	first := createEmptyTestBlock(chain.GetGenesisBlock(), 1, 1)
+	require.NoError(t, chain.AddBlock(first))
+	assert.Equal(t, uint64(0), storedHeight())
+
+	now = now.Add(time.Minute)
+	require.NoError(t, chain.AddBlock(createEmptyTestBlock(first, 2, 1)))
+	assert.Equal(t, uint64(2), storedHeight())
+}
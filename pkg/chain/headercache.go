@@ -0,0 +1,168 @@
+package chain
+
+import (
+	"fmt"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+)
+
+// validatedHeaderCache remembers the hashes of headers that passed header
+// validation, so that when the body of a block arrives after its header was
+// downloaded the header is not validated again. Every entry records the
+// difficulty its proof of work was checked against and only counts while the
+// chain still requires that difficulty. Entries are keyed by hash and keep
+// the hash of their parent, so that a header and its cached descendants can
+// be forgotten together when the block they build on is found invalid or
+// reorganized away. The oldest entry is forgotten once the cache is full.
+// Note: it is protected by the chain lock.
+type validatedHeaderCache struct {
+	capacity int
+	entries  map[string]validatedHeader // entries maps a header hash to its validation.
+	order    []string                   // order holds hashes oldest first for eviction.
+}
+
+// validatedHeader is a header that passed validation.
+type validatedHeader struct {
+	difficulty uint64 // difficulty is the difficulty the header was validated at.
+	prevHash   string // prevHash is the hash of the header's parent.
+}
+
+// newValidatedHeaderCache creates a cache holding up to capacity hashes. A
+// capacity of zero disables the cache.
+func newValidatedHeaderCache(capacity int) *validatedHeaderCache {
+	return &validatedHeaderCache{
+		capacity: capacity,
+		entries:  make(map[string]validatedHeader),
+	}
+}
+
+// add records a header with parent prevHash validated at difficulty.
+func (c *validatedHeaderCache) add(hash, prevHash []byte, difficulty uint64) {
+	if c.capacity <= 0 {
+		return
+	}
+	key := string(hash)
+	if _, exists := c.entries[key]; !exists {
+		if len(c.order) >= c.capacity {
+			delete(c.entries, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = validatedHeader{difficulty: difficulty, prevHash: string(prevHash)}
+}
+
+// contains reports whether a header was validated at difficulty.
+func (c *validatedHeaderCache) contains(hash []byte, difficulty uint64) bool {
+	validated, exists := c.entries[string(hash)]
+	return exists && validated.difficulty == difficulty
+}
+
+// remove forgets a header.
+func (c *validatedHeaderCache) remove(hash []byte) {
+	key := string(hash)
+	if _, exists := c.entries[key]; !exists {
+		return
+	}
+	delete(c.entries, key)
+	for i, cached := range c.order {
+		if cached == key {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// removeBranch forgets a header and every cached header descending from
+// it. The header itself need not be cached.
+func (c *validatedHeaderCache) removeBranch(hash []byte) {
+	if len(c.entries) == 0 {
+		return
+	}
+	forgotten := map[string]bool{string(hash): true}
+	for changed := true; changed; {
+		changed = false
+		for key, entry := range c.entries {
+			if forgotten[entry.prevHash] && !forgotten[key] {
+				forgotten[key] = true
+				changed = true
+			}
+		}
+	}
+	kept := c.order[:0]
+	for _, key := range c.order {
+		if forgotten[key] {
+			delete(c.entries, key)
+			continue
+		}
+		kept = append(kept, key)
+	}
+	c.order = kept
+}
+
+// ValidateHeader checks a header on its own, before its block body is
+// available: that its fields are well formed, that it extends a known block
+// at the next height and no earlier than it, and that it has valid proof of
+// work. Headers that pass are cached so the checks are skipped when their
+// block is added.
+func (c *Chain) ValidateHeader(header *block.Header) error {
+	if header == nil {
+		return fmt.Errorf("block header cannot be nil")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	hash := (&block.Block{Header: header}).CalculateHash()
+	if err := c.checkKnownInvalid(hash, header.PrevBlockHash); err != nil {
+		return err
+	}
+	return c.validateHeaderLocked(header, hash)
+}
+
+// HeaderValidations returns the number of headers fully validated, counting
+// neither headers skipped because they were cached nor failed validations.
+func (c *Chain) HeaderValidations() uint64 {
+	return c.headerValidations.Load()
+}
+
+// validateHeaderLocked runs the header checks of ValidateHeader unless the
+// header is cached as validated at the current difficulty.
+// Note: the caller must hold the chain lock.
+func (c *Chain) validateHeaderLocked(header *block.Header, hash []byte) error {
+	difficulty := c.consensus.GetDifficulty()
+	if c.validatedHeaders.contains(hash, difficulty) {
+		return nil
+	}
+
+	if err := header.IsValid(); err != nil {
+		return fmt.Errorf("header validation failed: %w", err)
+	}
+
+	// Check if previous block exists (except for genesis)
+	if header.Height > 0 {
+		prevBlock, err := c.storage.GetBlock(header.PrevBlockHash)
+		if err != nil || prevBlock == nil {
+			return fmt.Errorf("previous block not found")
+		}
+
+		// Check height continuity
+		if prevBlock.Header.Height+1 != header.Height {
+			return fmt.Errorf("height discontinuity: expected %d, got %d",
+				prevBlock.Header.Height+1, header.Height)
+		}
+
+		// Check timestamp
+		if err := c.consensus.CheckTimestamp(header.Timestamp, prevBlock); err != nil {
+			return err
+		}
+	}
+
+	// Validate proof of work
+	if !c.consensus.ValidateProofOfWork(&block.Block{Header: header}) {
+		return fmt.Errorf("invalid proof of work")
+	}
+
+	c.headerValidations.Add(1)
+	c.validatedHeaders.add(hash, header.PrevBlockHash, difficulty)
+	return nil
+}
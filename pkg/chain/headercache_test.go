@@ -0,0 +1,118 @@
+package chain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/palaseus/adrenochain/pkg/consensus"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatedHeaderSkipsRevalidation(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	chain, err := NewChain(DefaultChainConfig(), consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+	genesisBlock := chain.GetGenesisBlock()
+
+	// The header arrives first and is validated once
+	first := createTestBlockWithScript(genesisBlock, 1, "first")
+	require.NoError(t, chain.ValidateHeader(first.Header))
+	assert.Equal(t, uint64(1), chain.HeaderValidations())
+
+	// Its body follows without the header being validated again
+	require.NoError(t, chain.AddBlock(first))
+	assert.Equal(t, uint64(1), chain.HeaderValidations())
+
+	// A block whose header was not downloaded first is validated in full
+	second := createTestBlockWithScript(first, 2, "second")
+	require.NoError(t, chain.AddBlock(second))
+	assert.Equal(t, uint64(2), chain.HeaderValidations())
+
+	// Connected headers leave the cache
+	assert.Empty(t, chain.validatedHeaders.entries)
+
+	// Headers failing validation are neither counted nor cached
+	early := createEmptyTestBlock(second, 3, 1)
+	early.Header.Timestamp = second.Header.Timestamp.Add(-time.Hour)
+	mineTestBlock(early, 1)
+	err = chain.ValidateHeader(early.Header)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is not after median time past")
+	orphan := createEmptyTestBlock(early, 4, 1)
+	err = chain.ValidateHeader(orphan.Header)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "previous block not found")
+	assert.Equal(t, uint64(2), chain.HeaderValidations())
+	assert.Empty(t, chain.validatedHeaders.entries)
+}
+
+func TestValidatedHeadersForgottenWithTheirBranch(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	chain, err := NewChain(DefaultChainConfig(), consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+	genesisBlock := chain.GetGenesisBlock()
+
+	active := createTestBlockWithScript(genesisBlock, 1, "active")
+	require.NoError(t, chain.AddBlock(active))
+
+	// A header built on a block reorganized away is forgotten
+	next := createTestBlockWithScript(active, 2, "next")
+	require.NoError(t, chain.ValidateHeader(next.Header))
+	assert.Contains(t, chain.validatedHeaders.entries, string(next.CalculateHash()))
+	side := createTestBlockWithScript(genesisBlock, 1, "side")
+	require.NoError(t, chain.AddBlock(side))
+	sideNext := createTestBlockWithScript(side, 2, "side next")
+	require.NoError(t, chain.AddBlock(sideNext))
+	assert.Equal(t, sideNext.CalculateHash(), chain.GetTipHash())
+	assert.Empty(t, chain.validatedHeaders.entries)
+
+	// A header built on a block marked invalid is forgotten
+	tip := createTestBlockWithScript(sideNext, 3, "tip")
+	require.NoError(t, chain.ValidateHeader(tip.Header))
+	assert.Contains(t, chain.validatedHeaders.entries, string(tip.CalculateHash()))
+	require.NoError(t, chain.InvalidateBlock(sideNext.CalculateHash()))
+	assert.Empty(t, chain.validatedHeaders.entries)
+
+	// Its block is then rejected without being validated
+	validations := chain.HeaderValidations()
+	require.Error(t, chain.AddBlock(tip))
+	assert.Equal(t, validations, chain.HeaderValidations())
+}
+
+func TestValidatedHeaderCacheBounds(t *testing.T) {
+	cache := newValidatedHeaderCache(2)
+	cache.add([]byte("a"), nil, 1)
+	cache.add([]byte("b"), nil, 1)
+	cache.add([]byte("c"), nil, 1)
+	assert.False(t, cache.contains([]byte("a"), 1))
+	assert.True(t, cache.contains([]byte("b"), 1))
+	assert.True(t, cache.contains([]byte("c"), 1))
+
+	// Entries only count at the difficulty they were validated at
+	assert.False(t, cache.contains([]byte("b"), 2))
+
+	cache.remove([]byte("b"))
+	assert.False(t, cache.contains([]byte("b"), 1))
+	assert.Equal(t, []string{"c"}, cache.order)
+
+	disabled := newValidatedHeaderCache(0)
+	disabled.add([]byte("a"), nil, 1)
+	assert.False(t, disabled.contains([]byte("a"), 1))
+
+	// Forgetting a header forgets the cached headers descending from it
+	branch := newValidatedHeaderCache(4)
+	branch.add([]byte("child"), []byte("parent"), 1)
+	branch.add([]byte("grandchild"), []byte("child"), 1)
+	branch.add([]byte("other"), []byte("elsewhere"), 1)
+	branch.removeBranch([]byte("parent"))
+	assert.Equal(t, []string{"other"}, branch.order)
+	assert.Len(t, branch.entries, 1)
+}
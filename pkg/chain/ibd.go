@@ -0,0 +1,58 @@
+package chain
+
+import (
+	"math/big"
+	"time"
+)
+
+// DefaultMaxTipAgeBlocks is the number of target block times the tip may
+// lag behind the current time before the node considers itself out of sync,
+// when ChainConfig.MaxTipAge is not set.
+const DefaultMaxTipAgeBlocks = 144
+
+// MaxTipAge returns the configured maximum tip age, derived from the target
+// block time when it is not set.
+func (c *Chain) MaxTipAge() time.Duration {
+	if c.config.MaxTipAge > 0 {
+		return c.config.MaxTipAge
+	}
+	return DefaultMaxTipAgeBlocks * c.consensus.GetTargetBlockTime()
+}
+
+// TipAge returns how long ago the current tip was mined.
+func (c *Chain) TipAge() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.bestBlock == nil || c.bestBlock.Header == nil {
+		return 0
+	}
+	return c.now().Sub(c.bestBlock.Header.Timestamp)
+}
+
+// IsOutOfSync reports whether no block has arrived for longer than the
+// maximum tip age, so the node is probably behind the network.
+func (c *Chain) IsOutOfSync() bool {
+	return c.TipAge() > c.MaxTipAge()
+}
+
+// IsInitialBlockDownload reports whether the node is still catching up with
+// the network: its tip is older than the maximum tip age or the active chain
+// has less accumulated difficulty than MinimumChainWork. Once the node has
+// caught up it stays out of initial block download, even if the tip later
+// becomes stale.
+func (c *Chain) IsInitialBlockDownload() bool {
+	if c.synced.Load() {
+		return false
+	}
+	if c.IsOutOfSync() {
+		return true
+	}
+
+	work, err := c.GetAccumulatedDifficulty(c.GetHeight())
+	if err != nil || work.Cmp(new(big.Int).SetUint64(c.config.MinimumChainWork)) < 0 {
+		return true
+	}
+
+	c.synced.Store(true)
+	return false
+}
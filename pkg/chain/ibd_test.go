@@ -0,0 +1,55 @@
+package chain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/palaseus/adrenochain/pkg/consensus"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsInitialBlockDownload(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	config := DefaultChainConfig()
+	config.MaxTipAge = time.Hour
+	config.MinimumChainWork = 2
+	chain, err := NewChain(config, consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+
+	// The genesis block is years old
+	assert.True(t, chain.IsOutOfSync())
+	assert.True(t, chain.IsInitialBlockDownload())
+
+	// A recent tip without enough work is still initial block download
+	a1 := createEmptyTestBlock(chain.GetGenesisBlock(), 1, 1)
+	require.NoError(t, chain.AddBlock(a1))
+	assert.False(t, chain.IsOutOfSync())
+	assert.True(t, chain.IsInitialBlockDownload())
+
+	a2 := createEmptyTestBlock(a1, 2, 1)
+	require.NoError(t, chain.AddBlock(a2))
+	assert.False(t, chain.IsInitialBlockDownload())
+
+	// Once synced, a stale tip marks the node out of sync without
+	// re-entering initial block download
+	chain.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	assert.True(t, chain.IsOutOfSync())
+	assert.False(t, chain.IsInitialBlockDownload())
+}
+
+func TestMaxTipAgeDefault(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	consensusConfig := consensus.DefaultConsensusConfig()
+	chain, err := NewChain(DefaultChainConfig(), consensusConfig, storageInstance)
+	require.NoError(t, err)
+
+	assert.Equal(t, DefaultMaxTipAgeBlocks*consensusConfig.TargetBlockTime, chain.MaxTipAge())
+}
@@ -0,0 +1,109 @@
+package chain
+
+import (
+	"fmt"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+)
+
+// invalidBlockCache remembers the hashes of recently rejected blocks so that
+// a peer re-sending one cannot force it through full validation again. The
+// oldest entry is forgotten once the cache is full.
+// Note: it is protected by the chain lock.
+type invalidBlockCache struct {
+	capacity int
+	reasons  map[string]string         // reasons maps a block hash to why it was rejected.
+	branches map[string]*invalidBranch // branches holds the invalid branches ending at rejected blocks with valid proof of work.
+	order    []string                  // order holds hashes oldest first for eviction.
+}
+
+// newInvalidBlockCache creates a cache holding up to capacity hashes. A
+// capacity of zero disables the cache.
+func newInvalidBlockCache(capacity int) *invalidBlockCache {
+	return &invalidBlockCache{
+		capacity: capacity,
+		reasons:  make(map[string]string),
+		branches: make(map[string]*invalidBranch),
+	}
+}
+
+// add records a rejected block hash.
+func (c *invalidBlockCache) add(hash []byte, reason string) {
+	if c.capacity <= 0 {
+		return
+	}
+	key := string(hash)
+	if _, exists := c.reasons[key]; exists {
+		return
+	}
+	if len(c.order) >= c.capacity {
+		delete(c.reasons, c.order[0])
+		delete(c.branches, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.reasons[key] = reason
+	c.order = append(c.order, key)
+}
+
+// get returns why a block was rejected and whether it is cached.
+func (c *invalidBlockCache) get(hash []byte) (string, bool) {
+	reason, exists := c.reasons[string(hash)]
+	return reason, exists
+}
+
+// setBranch records the invalid branch ending at a cached block.
+func (c *invalidBlockCache) setBranch(hash []byte, branch *invalidBranch) {
+	if _, exists := c.reasons[string(hash)]; exists {
+		c.branches[string(hash)] = branch
+	}
+}
+
+// checkKnownInvalid rejects a block that was rejected or manually
+// invalidated before, or whose parent is known to be invalid, without
+// validating it. Children of invalid blocks are recorded as invalid
+// themselves so their own descendants are caught too.
+// Note: the caller must hold the chain lock.
+func (c *Chain) checkKnownInvalid(hash, prevHash []byte) error {
+	if _, invalidated := c.invalidated[string(hash)]; invalidated {
+		return fmt.Errorf("block %x was manually invalidated", hash)
+	}
+	if _, invalidated := c.invalidated[string(prevHash)]; invalidated {
+		return fmt.Errorf("block %x descends from manually invalidated block %x", hash, prevHash)
+	}
+	if reason, invalid := c.invalidBlocks.get(hash); invalid {
+		return fmt.Errorf("block %x previously rejected: %s", hash, reason)
+	}
+	if _, invalid := c.invalidBlocks.get(prevHash); invalid {
+		reason := fmt.Sprintf("descends from invalid block %x", prevHash)
+		c.invalidBlocks.add(hash, reason)
+		c.validatedHeaders.removeBranch(hash)
+		return fmt.Errorf("block %x rejected: %s", hash, reason)
+	}
+	return nil
+}
+
+// checkContextFreeLocked checks the rules a block can be judged by on its
+// own: its structure, the size, transaction count and signature operation
+// limits, and, when difficulty is enforced, its hash against the target of
+// its own difficulty. Only failures of these checks are cached as invalid,
+// as the other rules depend on the branch the block extends or on the
+// current time.
+// Note: the caller must hold the chain lock.
+func (c *Chain) checkContextFreeLocked(b *block.Block, hash []byte) error {
+	if err := b.IsValid(); err != nil {
+		return err
+	}
+	if err := c.CheckBlockLimits(b); err != nil {
+		return err
+	}
+	return c.checkDifficultyLocked(b, hash)
+}
+
+// IsInvalidBlock reports whether the block with the given hash is cached as
+// invalid.
+func (c *Chain) IsInvalidBlock(hash []byte) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, invalid := c.invalidBlocks.get(hash)
+	return invalid
+}
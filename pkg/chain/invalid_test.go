@@ -0,0 +1,112 @@
+package chain
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/consensus"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvalidBlockCache(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	chain, err := NewChain(DefaultChainConfig(), consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+	genesisBlock := chain.GetGenesisBlock()
+
+	// A block whose coinbase pays nothing is invalid on any branch
+	invalidBlock := createMalformedTestBlock(genesisBlock, 1)
+	invalidHash := invalidBlock.CalculateHash()
+
+	err = chain.AddBlock(invalidBlock)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "validation failed")
+	assert.True(t, chain.IsInvalidBlock(invalidHash))
+
+	// Resubmission is rejected from the cache
+	err = chain.AddBlock(invalidBlock)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "previously rejected")
+
+	// A child is rejected without validation, even though its parent is not
+	// stored and full validation would report a missing parent instead
+	child := createEmptyTestBlock(invalidBlock, 2, 1)
+	err = chain.AddBlock(child)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "descends from invalid block")
+	assert.True(t, chain.IsInvalidBlock(child.CalculateHash()))
+
+	grandchild := createEmptyTestBlock(child, 3, 1)
+	err = chain.AddBlock(grandchild)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "descends from invalid block")
+
+	// Valid blocks are unaffected
+	require.NoError(t, chain.AddBlock(createEmptyTestBlock(genesisBlock, 1, 1)))
+	assert.Equal(t, uint64(1), chain.GetHeight())
+}
+
+func TestInvalidBlockCacheSkipsContextualFailures(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	chain, err := NewChain(DefaultChainConfig(), consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+	genesisBlock := chain.GetGenesisBlock()
+
+	// A block too far in the future may become valid later
+	future := createEmptyTestBlock(genesisBlock, 1, 1)
+	future.Header.Timestamp = time.Now().Add(24 * time.Hour)
+	mineTestBlock(future, 1)
+	require.Error(t, chain.AddBlock(future))
+	assert.False(t, chain.IsInvalidBlock(future.CalculateHash()))
+
+	// A block spending an output unknown to the active chain may be valid
+	// on its own branch
+	spend := &block.Transaction{
+		Version: 1,
+		Inputs:  []*block.TxInput{{PrevTxHash: make([]byte, 32), ScriptSig: []byte("sig")}},
+		Outputs: []*block.TxOutput{{Value: 1000, ScriptPubKey: []byte("recipient")}},
+	}
+	spend.Hash = spend.CalculateHash()
+	coinbase := createEmptyTestBlock(genesisBlock, 1, 1).Transactions[0]
+	unknownSpend := createValidTestBlock(genesisBlock, 1, 1, []*block.Transaction{coinbase, spend})
+	require.Error(t, chain.AddBlock(unknownSpend))
+	assert.False(t, chain.IsInvalidBlock(unknownSpend.CalculateHash()))
+}
+
+func TestInvalidBlockCacheEviction(t *testing.T) {
+	cache := newInvalidBlockCache(2)
+	cache.add([]byte("a"), "bad")
+	cache.add([]byte("b"), "bad")
+	cache.add([]byte("c"), "bad")
+
+	_, cachedA := cache.get([]byte("a"))
+	_, cachedC := cache.get([]byte("c"))
+	assert.False(t, cachedA)
+	assert.True(t, cachedC)
+
+	disabled := newInvalidBlockCache(0)
+	disabled.add([]byte("a"), "bad")
+	_, cached := disabled.get([]byte("a"))
+	assert.False(t, cached)
+}
+
+// createMalformedTestBlock creates a block with valid proof of work whose
+// coinbase pays a zero-value output, which no chain state makes valid.
+func createMalformedTestBlock(prevBlock *block.Block, height uint64) *block.Block {
+	coinbase := &block.Transaction{
+		Version: 1,
+		Outputs: []*block.TxOutput{{Value: 0, ScriptPubKey: []byte(fmt.Sprintf("MALFORMED_%d", height))}},
+	}
+	coinbase.Hash = coinbase.CalculateHash()
+	return createValidTestBlock(prevBlock, height, 1, []*block.Transaction{coinbase})
+}
@@ -0,0 +1,592 @@
+package chain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/palaseus/adrenochain/pkg/utxo"
+)
+
+// ErrInvalidBranch is returned when a branch the chain switches to holds a
+// block whose transactions are invalid on it. The block is cached as invalid
+// and the previous active chain is kept.
+var ErrInvalidBranch = errors.New("branch holds an invalid block")
+
+// InvalidateBlock marks a block invalid, and with it all of its descendants,
+// overriding consensus validation. If the block is part of the active chain,
+// the chain reorganizes to the tip with the most accumulated difficulty among
+// the remaining valid blocks held in memory. If the reorganization fails the
+// invalidation is undone. Invalidations are not persisted and do not survive
+// a restart.
+func (c *Chain) InvalidateBlock(hash []byte) error {
+	c.mu.Lock()
+	defer c.unlockAndNotify()
+
+	target := c.GetBlock(hash)
+	if target == nil {
+		return fmt.Errorf("block %x not found", hash)
+	}
+	if target.Header.Height == 0 {
+		return fmt.Errorf("cannot invalidate the genesis block")
+	}
+
+	previous := c.invalidatedCopyLocked()
+	c.invalidated[string(hash)] = struct{}{}
+	c.validatedHeaders.removeBranch(hash)
+	for _, descendant := range c.descendantsLocked(hash) {
+		c.invalidated[descendant] = struct{}{}
+	}
+	if err := c.activateBestChainLocked(); err != nil {
+		c.invalidated = previous
+		return err
+	}
+	return nil
+}
+
+// ReconsiderBlock removes the manual invalidation of a block and of its
+// ancestors and descendants, then switches to the best valid tip if it has
+// more accumulated difficulty than the current one. If the reorganization
+// fails the invalidations are kept.
+func (c *Chain) ReconsiderBlock(hash []byte) error {
+	c.mu.Lock()
+	defer c.unlockAndNotify()
+
+	target := c.GetBlock(hash)
+	if target == nil {
+		return fmt.Errorf("block %x not found", hash)
+	}
+
+	previous := c.invalidatedCopyLocked()
+	for b := target; b != nil && b.Header.Height > 0; b = c.GetBlock(b.Header.PrevBlockHash) {
+		delete(c.invalidated, string(b.CalculateHash()))
+	}
+	for _, descendant := range c.descendantsLocked(hash) {
+		delete(c.invalidated, descendant)
+	}
+	if err := c.activateBestChainLocked(); err != nil {
+		c.invalidated = previous
+		return err
+	}
+	return nil
+}
+
+// invalidatedCopyLocked returns a copy of the set of invalidated blocks.
+// Note: the caller must hold the chain lock.
+func (c *Chain) invalidatedCopyLocked() map[string]struct{} {
+	copied := make(map[string]struct{}, len(c.invalidated))
+	for hash := range c.invalidated {
+		copied[hash] = struct{}{}
+	}
+	return copied
+}
+
+// IsBlockInvalidated reports whether a block was marked invalid with
+// InvalidateBlock.
+func (c *Chain) IsBlockInvalidated(hash []byte) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, invalidated := c.invalidated[string(hash)]
+	return invalidated
+}
+
+// descendantsLocked returns the hashes of all blocks in memory that descend
+// from the given block.
+// Note: the caller must hold the chain lock.
+func (c *Chain) descendantsLocked(hash []byte) []string {
+	children := make(map[string][]string)
+	for key, b := range c.blocks {
+		parent := string(b.Header.PrevBlockHash)
+		children[parent] = append(children[parent], key)
+	}
+
+	var result []string
+	queue := []string{string(hash)}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		for _, child := range children[next] {
+			result = append(result, child)
+			queue = append(queue, child)
+		}
+	}
+	return result
+}
+
+// activateBestChainLocked makes the valid tip with the most accumulated
+// difficulty the active chain. The current tip is kept on a tie; otherwise
+// ties are broken by the lower hash so the choice is deterministic. A branch
+// found invalid while switching to it is cached as such, and the next-best
+// tip is tried in its place.
+// Note: the caller must hold the chain lock.
+func (c *Chain) activateBestChainLocked() error {
+	for {
+		best, bestHash := c.bestTipLocked()
+		if best == nil {
+			return fmt.Errorf("no valid chain tip remains")
+		}
+		if bytes.Equal(bestHash, c.tipHash) {
+			return nil
+		}
+		if err := c.setTipLocked(best); !errors.Is(err, ErrInvalidBranch) {
+			return err
+		}
+	}
+}
+
+// bestTipLocked returns the valid tip with the most accumulated difficulty
+// and its hash, or nil if no branch is valid.
+// Note: the caller must hold the chain lock.
+func (c *Chain) bestTipLocked() (*block.Block, []byte) {
+	candidates := make([]*block.Block, 0, len(c.blocks)+1)
+	if c.bestBlock != nil {
+		candidates = append(candidates, c.bestBlock)
+	}
+	for _, b := range c.blocks {
+		candidates = append(candidates, b)
+	}
+
+	work := make(map[string]*big.Int)
+	var best *block.Block
+	var bestHash []byte
+	var bestWork *big.Int
+	for _, candidate := range candidates {
+		candidateWork := c.chainWorkLocked(candidate, work)
+		if candidateWork == nil {
+			continue
+		}
+		candidateHash := candidate.CalculateHash()
+		better := best == nil
+		if !better {
+			cmp := candidateWork.Cmp(bestWork)
+			better = cmp > 0 || (cmp == 0 && best != c.bestBlock && bytes.Compare(candidateHash, bestHash) < 0)
+		}
+		if better {
+			best, bestHash, bestWork = candidate, candidateHash, candidateWork
+		}
+	}
+	return best, bestHash
+}
+
+// chainWorkLocked returns the accumulated difficulty of the branch ending at
+// tip, or nil if the branch does not connect to genesis or contains an
+// invalid block. Results for every block on the branch are memoized in work,
+// with nil recording an invalid branch.
+// Note: the caller must hold the chain lock.
+func (c *Chain) chainWorkLocked(tip *block.Block, work map[string]*big.Int) *big.Int {
+	var path []*block.Block
+	var base *big.Int
+	valid := true
+	for b := tip; ; {
+		hash := b.CalculateHash()
+		if known, seen := work[string(hash)]; seen {
+			base, valid = known, known != nil
+			break
+		}
+		path = append(path, b)
+		if _, invalidated := c.invalidated[string(hash)]; invalidated {
+			valid = false
+			break
+		}
+		if _, rejected := c.invalidBlocks.get(hash); rejected {
+			valid = false
+			break
+		}
+		if b.Header.Height == 0 {
+			valid = c.genesisBlock != nil && bytes.Equal(hash, c.genesisBlock.CalculateHash())
+			break
+		}
+		parent := c.GetBlock(b.Header.PrevBlockHash)
+		if parent == nil {
+			valid = false
+			break
+		}
+		b = parent
+	}
+
+	if !valid {
+		for _, b := range path {
+			work[string(b.CalculateHash())] = nil
+		}
+		return nil
+	}
+
+	total := base
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i].Header.Height == 0 {
+			total = big.NewInt(0)
+		} else {
+			total = new(big.Int).Add(total, new(big.Int).SetUint64(path[i].Header.Difficulty))
+		}
+		work[string(path[i].CalculateHash())] = total
+	}
+	return total
+}
+
+// branchLocked returns the blocks of the branch ending at tip, tip first,
+// down to genesis or, above pruned blocks, to the first block after the
+// prune base, which is then returned to replay the branch from.
+// Note: the caller must hold the chain lock.
+func (c *Chain) branchLocked(tip *block.Block) ([]*block.Block, *pruneBase) {
+	var path []*block.Block
+	for b := tip; b != nil; b = c.GetBlock(b.Header.PrevBlockHash) {
+		path = append(path, b)
+		if b.Header.Height == 0 || c.isPruneBoundary(b) {
+			break
+		}
+	}
+
+	// Above pruned blocks the chain is replayed from the prune base
+	if c.isPruneBoundary(path[len(path)-1]) {
+		return path, c.pruneBase
+	}
+	return path, nil
+}
+
+// activeSetLocked returns the hashes of the blocks of the active chain.
+// Note: the caller must hold the chain lock.
+func (c *Chain) activeSetLocked() map[string]bool {
+	active := make(map[string]bool)
+	for b := c.bestBlock; b != nil; b = c.GetBlock(b.Header.PrevBlockHash) {
+		active[string(b.CalculateHash())] = true
+		if b.Header.Height == 0 {
+			break
+		}
+	}
+	return active
+}
+
+// setTipLocked switches the active chain to the branch ending at tip. The
+// active blocks above the fork are disconnected with their undo data, then
+// the blocks of the branch above it are connected, their transactions being
+// validated against the UTXO set of the branch first. Where an active block
+// cannot be disconnected in place, the branch is replayed instead. If a
+// block of the branch is invalid or the switch fails, the previous active
+// chain is restored; an invalid block is cached as such and
+// ErrInvalidBranch returned.
+// Note: the caller must hold the chain lock.
+func (c *Chain) setTipLocked(tip *block.Block) error {
+	if c.bestBlock == nil {
+		return c.replayBranchLocked(tip, true)
+	}
+
+	// Walk the branch down to the active chain, then the active chain down
+	// to the fork
+	var connect []*block.Block
+	fork := tip
+	for fork != nil && !c.isActiveLocked(fork) {
+		connect = append(connect, fork)
+		fork = c.GetBlock(fork.Header.PrevBlockHash)
+	}
+	if fork == nil {
+		return c.replayBranchLocked(tip, true)
+	}
+	slices.Reverse(connect)
+	var disconnect []*block.Block
+	for b := c.bestBlock; b.Header.Height > fork.Header.Height; b = c.GetBlock(b.Header.PrevBlockHash) {
+		if !c.canDisconnectLocked(b) {
+			return c.replayBranchLocked(tip, true)
+		}
+		disconnect = append(disconnect, b)
+	}
+	work, err := c.accumulatedDifficultyLocked(fork.Header.Height)
+	if err != nil {
+		return c.replayBranchLocked(tip, true)
+	}
+	return c.switchBranchLocked(tip, fork.Header.Height, work, disconnect, connect)
+}
+
+// isActiveLocked reports whether a block is part of the active chain.
+// Note: the caller must hold the chain lock.
+func (c *Chain) isActiveLocked(b *block.Block) bool {
+	if b.Header.Height > c.bestBlock.Header.Height {
+		return false
+	}
+	active, exists := c.blockByHeight[b.Header.Height]
+	return exists && bytes.Equal(active.CalculateHash(), b.CalculateHash())
+}
+
+// switchBranchLocked switches the active chain to the branch ending at tip
+// in place. The active blocks above the fork at height fork, given tip
+// first, are disconnected, then the blocks of the branch above it, given
+// lowest first, are validated and connected on top of work, the
+// accumulated difficulty at the fork.
+// Note: the caller must hold the chain lock.
+func (c *Chain) switchBranchLocked(tip *block.Block, fork uint64, work *big.Int, disconnect, connect []*block.Block) error {
+	previous := c.bestBlock
+	disconnected := make(map[string]bool, len(disconnect))
+	undo := make(map[string]*utxo.BlockDiff, len(disconnect))
+	var connected []*block.Block
+	fail := func(err error) error {
+		c.revertSwitchLocked(fork, tip.Header.Height, disconnect[:len(disconnected)], connected)
+		return err
+	}
+
+	for _, b := range disconnect {
+		diff, err := c.disconnectBlockLocked(b)
+		if err != nil {
+			return fail(fmt.Errorf("failed to disconnect block %x: %w", b.CalculateHash(), err))
+		}
+		disconnected[string(diff.BlockHash)] = true
+		undo[string(diff.BlockHash)] = diff
+	}
+
+	accumulated := work
+	for _, b := range connect {
+		hash := b.CalculateHash()
+		// The block was stored without its transactions being checked, as
+		// they spend from the branch rather than the active chain
+		err := c.timeStageLocked(StageTransactions, func() error { return c.validateTransactionsLocked(b) })
+		if err != nil {
+			c.revertSwitchLocked(fork, tip.Header.Height, disconnect, connected)
+			return c.rejectBranchBlockLocked(b, hash, err)
+		}
+		if _, err := c.connectUTXOsLocked(b); err != nil {
+			return fail(fmt.Errorf("failed to connect block %x: %w", hash, err))
+		}
+		c.indexBlockLocked(b)
+		accumulated = new(big.Int).Add(accumulated, new(big.Int).SetUint64(b.Header.Difficulty))
+		c.blockByHeight[b.Header.Height] = b
+		c.accumulatedDifficulty[b.Header.Height] = accumulated
+		connected = append(connected, b)
+		if err := c.storeHeightLocked(b, hash); err != nil {
+			return fail(err)
+		}
+	}
+
+	if previous.Header.Height > tip.Header.Height {
+		if err := c.dropHeightIndexLocked(tip.Header.Height+1, previous.Header.Height); err != nil {
+			return fail(err)
+		}
+	}
+	return c.finishSwitchLocked(tip, disconnected, undo, connected)
+}
+
+// revertSwitchLocked rolls back a failed in-place switch to a branch forking
+// at height fork and ending at height tip: the branch blocks connected so
+// far are disconnected again and the active blocks disconnected so far,
+// given tip first, reconnected. Should that fail, the previous active chain
+// is replayed. The height index entries the switch may have rewritten then
+// point at its blocks again; failing writes have already put the chain in
+// safe mode, so they are not reported again.
+// Note: the caller must hold the chain lock.
+func (c *Chain) revertSwitchLocked(fork, tip uint64, disconnected, connected []*block.Block) {
+	restored := true
+	for i := len(connected) - 1; i >= 0 && restored; i-- {
+		if !c.canDisconnectLocked(connected[i]) {
+			restored = false
+		} else if _, err := c.disconnectBlockLocked(connected[i]); err != nil {
+			restored = false
+		}
+	}
+	for i := len(disconnected) - 1; i >= 0 && restored; i-- {
+		b := disconnected[i]
+		if _, err := c.connectUTXOsLocked(b); err != nil {
+			restored = false
+			break
+		}
+		c.indexBlockLocked(b)
+		c.blockByHeight[b.Header.Height] = b
+		c.updateAccumulatedDifficulty(b)
+	}
+	if !restored {
+		_ = c.replayBranchLocked(c.bestBlock, false)
+	}
+
+	height := c.bestBlock.Header.Height
+	for h := fork + 1; h <= max(tip, height); h++ {
+		if b, exists := c.blockByHeight[h]; exists && h <= height {
+			_ = c.storeHeightLocked(b, b.CalculateHash())
+		} else {
+			_ = c.dropHeightIndexLocked(h, h)
+		}
+	}
+}
+
+// replayBranchLocked switches the active chain to the branch ending at tip
+// by rebuilding the height index, accumulated difficulty, UTXO set and
+// transaction index from genesis, or from the prune base above pruned
+// blocks. The transactions of the blocks not on the previous active chain
+// are validated against the UTXO set of the branch as they are connected if
+// validate is set; replays of blocks accepted before, as on a reindex, skip
+// it. Height index entries in storage are rewritten where the branch
+// differs from the previous active chain. If a block of the branch is
+// invalid, replaying it fails or rewriting the height index fails, the
+// previous active chain is restored.
+// Note: the caller must hold the chain lock.
+func (c *Chain) replayBranchLocked(tip *block.Block, validate bool) error {
+	path, base := c.branchLocked(tip)
+	active := c.activeSetLocked()
+	// Height index entries from the fork point up may be rewritten
+	fork := tip.Header.Height + 1
+	for _, b := range path {
+		if !active[string(b.CalculateHash())] {
+			fork = b.Header.Height
+		}
+	}
+	snapshot := c.snapshotTipLocked()
+	fail := func(err error) error {
+		c.restoreTipLocked(snapshot, fork, tip.Header.Height)
+		return err
+	}
+
+	accumulated := c.restorePruneBaseLocked(base)
+	c.blockByHeight = make(map[uint64]*block.Block)
+	c.accumulatedDifficulty = make(map[uint64]*big.Int)
+	if base != nil {
+		c.accumulatedDifficulty[base.Height] = accumulated
+	}
+	var connected []*block.Block
+	for i := len(path) - 1; i >= 0; i-- {
+		b := path[i]
+		hash := b.CalculateHash()
+		if validate && !active[string(hash)] {
+			err := c.timeStageLocked(StageTransactions, func() error { return c.validateTransactionsLocked(b) })
+			if err != nil {
+				c.restoreTipLocked(snapshot, fork, tip.Header.Height)
+				return c.rejectBranchBlockLocked(b, hash, err)
+			}
+		}
+		if _, err := c.connectUTXOsLocked(b); err != nil {
+			return fail(fmt.Errorf("failed to replay block %x: %w", hash, err))
+		}
+		c.indexBlockLocked(b)
+		if !active[string(hash)] {
+			if err := c.storeHeightLocked(b, hash); err != nil {
+				return fail(err)
+			}
+			connected = append(connected, b)
+		}
+		if b.Header.Height > 0 {
+			accumulated = new(big.Int).Add(accumulated, new(big.Int).SetUint64(b.Header.Difficulty))
+		}
+		c.blockByHeight[b.Header.Height] = b
+		c.accumulatedDifficulty[b.Header.Height] = accumulated
+	}
+
+	if previous := c.bestBlock; previous != nil && previous.Header.Height > tip.Header.Height {
+		if err := c.dropHeightIndexLocked(tip.Header.Height+1, previous.Header.Height); err != nil {
+			return fail(err)
+		}
+	}
+
+	// Blocks of the previous active chain missing from the new branch were
+	// disconnected
+	for _, b := range path {
+		delete(active, string(b.CalculateHash()))
+	}
+	return c.finishSwitchLocked(tip, active, snapshot.undo, connected)
+}
+
+// finishSwitchLocked makes tip the chain tip after a switch of the active
+// chain that disconnected the given blocks, whose undo data is in undo, and
+// connected the given ones, lowest first, and reports the switch. Headers
+// cached as validated on top of the disconnected blocks are forgotten.
+// Note: the caller must hold the chain lock.
+func (c *Chain) finishSwitchLocked(tip *block.Block, disconnected map[string]bool, undo map[string]*utxo.BlockDiff, connected []*block.Block) error {
+	if n := uint64(len(disconnected)); n > 0 {
+		c.recordReorgLocked(n)
+	}
+	for hash := range disconnected {
+		c.validatedHeaders.removeBranch([]byte(hash))
+	}
+	c.publishReorgDiffsLocked(undo, disconnected, connected)
+	c.queueReorgEventsLocked(disconnected, connected)
+
+	c.bestBlock = tip
+	c.tipHash = tip.CalculateHash()
+	c.height = tip.Header.Height
+	c.consensus.ResetDifficulty(tip.Header.Difficulty)
+
+	return c.storeChainStateLocked(&storage.ChainState{
+		BestBlockHash: c.tipHash,
+		Height:        c.height,
+	})
+}
+
+// rejectBranchBlockLocked caches a block of a branch being switched to as
+// invalid, which takes the work of the branch away, and returns the error
+// reporting it. The error only wraps ErrInvalidBranch if the block could be
+// cached, so that a caller looking for another tip does not pick it again.
+// Note: the caller must hold the chain lock.
+func (c *Chain) rejectBranchBlockLocked(b *block.Block, hash []byte, err error) error {
+	c.invalidBlocks.add(hash, err.Error())
+	c.validatedHeaders.removeBranch(hash)
+	c.checkInvalidChainLocked(b, hash, err.Error())
+	if _, cached := c.invalidBlocks.get(hash); !cached {
+		return fmt.Errorf("block %x is invalid: %w", hash, err)
+	}
+	return fmt.Errorf("%w: block %x: %w", ErrInvalidBranch, hash, err)
+}
+
+// tipSnapshot holds the state of the active chain that replayBranchLocked
+// rebuilds, so that a failed switch can be rolled back.
+type tipSnapshot struct {
+	utxos                 []*utxo.UTXO
+	blockByHeight         map[uint64]*block.Block
+	accumulatedDifficulty map[uint64]*big.Int
+	txIndex               map[string]*indexedTx
+	spentBy               map[string][]byte
+	compactQueue          []*indexedTx
+	addrHistory           map[string][]*addressTx
+	undo                  map[string]*utxo.BlockDiff
+	issued                uint64
+	unspendable           uint64
+	rewards               map[uint64]uint64
+}
+
+// snapshotTipLocked captures the state of the active chain. The indexes are
+// replaced rather than modified when a branch is replayed, so they are kept
+// by reference; the UTXO set is copied.
+// Note: the caller must hold the chain lock.
+func (c *Chain) snapshotTipLocked() *tipSnapshot {
+	return &tipSnapshot{
+		utxos:                 c.UTXOSet.Snapshot(),
+		blockByHeight:         c.blockByHeight,
+		accumulatedDifficulty: c.accumulatedDifficulty,
+		txIndex:               c.txIndex,
+		spentBy:               c.spentBy,
+		compactQueue:          c.compactQueue,
+		addrHistory:           c.addrHistory,
+		undo:                  c.undo,
+		issued:                c.issued,
+		unspendable:           c.unspendable,
+		rewards:               c.rewards,
+	}
+}
+
+// restoreTipLocked rolls back a failed replay of a branch forking at height
+// fork and ending at height tip: the state of the previous active chain is
+// restored from the snapshot and the height index entries the switch may
+// have rewritten point at its blocks again. Failing writes have already put
+// the chain in safe mode, so they are not reported again.
+// Note: the caller must hold the chain lock.
+func (c *Chain) restoreTipLocked(s *tipSnapshot, fork, tip uint64) {
+	c.UTXOSet.Restore(s.utxos)
+	c.blockByHeight = s.blockByHeight
+	c.accumulatedDifficulty = s.accumulatedDifficulty
+	c.txIndex = s.txIndex
+	c.spentBy = s.spentBy
+	c.compactQueue = s.compactQueue
+	c.addrHistory = s.addrHistory
+	c.undo = s.undo
+	c.issued = s.issued
+	c.unspendable = s.unspendable
+	c.rewards = s.rewards
+
+	top := tip
+	if c.bestBlock != nil {
+		top = max(top, c.bestBlock.Header.Height)
+	}
+	for height := fork; height <= top; height++ {
+		if b, exists := s.blockByHeight[height]; exists {
+			_ = c.storeHeightLocked(b, b.CalculateHash())
+		} else {
+			_ = c.dropHeightIndexLocked(height, height)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package chain
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/consensus"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/palaseus/adrenochain/pkg/utxo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvalidateAndReconsiderBlock(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	chain, err := NewChain(DefaultChainConfig(), consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+	genesisBlock := chain.GetGenesisBlock()
+
+	// Active chain: genesis - a1 - a2 - a3 - a4
+	a1 := createEmptyTestBlock(genesisBlock, 1, 1)
+	require.NoError(t, chain.AddBlock(a1))
+	a2 := createEmptyTestBlock(a1, 2, 1)
+	require.NoError(t, chain.AddBlock(a2))
+	a3 := createEmptyTestBlock(a2, 3, 1)
+	require.NoError(t, chain.AddBlock(a3))
+	a4 := createEmptyTestBlock(a3, 4, 1)
+	require.NoError(t, chain.AddBlock(a4))
+
+	// Side branch: a1 - b2 - b3
+	b2 := createTestBlockWithScript(a1, 2, "BRANCH_B_2")
+	require.NoError(t, chain.AddBlock(b2))
+	b3 := createTestBlockWithScript(b2, 3, "BRANCH_B_3")
+	require.NoError(t, chain.AddBlock(b3))
+	require.Equal(t, a4.CalculateHash(), chain.GetTipHash())
+
+	// Invalidating a3 also invalidates the tip a4; b3 is now the best branch
+	require.NoError(t, chain.InvalidateBlock(a3.CalculateHash()))
+	assert.True(t, chain.IsBlockInvalidated(a3.CalculateHash()))
+	assert.True(t, chain.IsBlockInvalidated(a4.CalculateHash()))
+	assert.Equal(t, b3.CalculateHash(), chain.GetTipHash())
+	assert.Equal(t, uint64(3), chain.GetHeight())
+	assert.Equal(t, b2.CalculateHash(), chain.GetBlockByHeight(2).CalculateHash())
+	assert.NotNil(t, chain.UTXOSet.GetUTXO(b3.Transactions[0].Hash, 0))
+	assert.Nil(t, chain.UTXOSet.GetUTXO(a4.Transactions[0].Hash, 0))
+
+	accumulated, err := chain.GetAccumulatedDifficulty(3)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), accumulated.Int64())
+
+	// Blocks building on the invalidated branch are rejected
+	a5 := createEmptyTestBlock(a4, 5, 1)
+	err = chain.AddBlock(a5)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "descends from manually invalidated block")
+
+	// Reconsidering a3 restores the longer branch
+	require.NoError(t, chain.ReconsiderBlock(a3.CalculateHash()))
+	assert.False(t, chain.IsBlockInvalidated(a3.CalculateHash()))
+	assert.False(t, chain.IsBlockInvalidated(a4.CalculateHash()))
+	assert.Equal(t, a4.CalculateHash(), chain.GetTipHash())
+	assert.Equal(t, uint64(4), chain.GetHeight())
+	assert.Equal(t, a2.CalculateHash(), chain.GetBlockByHeight(2).CalculateHash())
+	assert.NotNil(t, chain.UTXOSet.GetUTXO(a4.Transactions[0].Hash, 0))
+	assert.Nil(t, chain.UTXOSet.GetUTXO(b3.Transactions[0].Hash, 0))
+}
+
+func TestInvalidateBlockRollsBackFailedReorg(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	mockStorage := &MockFailingStorage{StorageInterface: storageInstance}
+	chain, err := NewChain(DefaultChainConfig(), consensus.DefaultConsensusConfig(), mockStorage)
+	require.NoError(t, err)
+	genesisBlock := chain.GetGenesisBlock()
+
+	a1 := createEmptyTestBlock(genesisBlock, 1, 1)
+	require.NoError(t, chain.AddBlock(a1))
+	a2 := createEmptyTestBlock(a1, 2, 1)
+	require.NoError(t, chain.AddBlock(a2))
+	b1 := createTestBlockWithScript(genesisBlock, 1, "BRANCH_B_1")
+	require.NoError(t, chain.AddBlock(b1))
+	supply := chain.GetTotalSupply()
+
+	// Switching to b1 fails while its height index entry is written
+	mockStorage.failOnWrite = true
+	require.Error(t, chain.InvalidateBlock(a1.CalculateHash()))
+
+	assert.False(t, chain.IsBlockInvalidated(a1.CalculateHash()))
+	assert.Equal(t, a2.CalculateHash(), chain.GetTipHash())
+	assert.NotNil(t, chain.UTXOSet.GetUTXO(a2.Transactions[0].Hash, 0))
+	assert.Nil(t, chain.UTXOSet.GetUTXO(b1.Transactions[0].Hash, 0))
+	assert.Equal(t, supply, chain.GetTotalSupply())
+	_, err = chain.GetTxOut(a2.Transactions[0].Hash, 0)
+	assert.NoError(t, err)
+}
+
+// TestSwitchRejectsInvalidBranch switches to heavier side branches whose
+// blocks are only valid or invalid against the state of their own branch.
+func TestSwitchRejectsInvalidBranch(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	chain, err := NewChain(DefaultChainConfig(), consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+
+	key, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+	pubKey := key.PubKey().SerializeUncompressed()
+	pubKeyHash := sha256.Sum256(pubKey)
+	script := pubKeyHash[12:]
+	spend := func(prev *block.Transaction, to []byte) *block.Transaction {
+		tx := &block.Transaction{
+			Version: 1,
+			Inputs:  []*block.TxInput{{PrevTxHash: prev.Hash, Sequence: 0xffffffff}},
+			Outputs: []*block.TxOutput{{Value: prev.Outputs[0].Value - 10000, ScriptPubKey: to}},
+			Fee:     10000,
+		}
+		sigHash, err := utxo.TxSignatureHash(tx, 0, utxo.SigHashAll)
+		require.NoError(t, err)
+		r, s, err := ecdsa.Sign(rand.Reader, key.ToECDSA(), sigHash)
+		require.NoError(t, err)
+		tx.Inputs[0].ScriptSig = append(append(pubKey, r.FillBytes(make([]byte, 32))...), s.FillBytes(make([]byte, 32))...)
+		tx.Hash = tx.CalculateHash()
+		return tx
+	}
+	coinbase := func(to []byte) *block.Transaction {
+		tx := &block.Transaction{Version: 1, Outputs: []*block.TxOutput{{Value: 1000000, ScriptPubKey: to}}}
+		tx.Hash = tx.CalculateHash()
+		return tx
+	}
+
+	// Active chain: genesis - a1 - a2, with a1 paying its reward to the key
+	reward := coinbase(script)
+	a1 := createValidTestBlock(chain.GetGenesisBlock(), 1, 1, []*block.Transaction{reward})
+	require.NoError(t, chain.AddBlock(a1))
+	a2 := createEmptyTestBlock(a1, 2, 1)
+	require.NoError(t, chain.AddBlock(a2))
+
+	// Side branch: b2 spends the reward and b3 spends it again. Each block
+	// alone could spend it on the active chain, the branch cannot
+	first := spend(reward, script)
+	b2 := createValidTestBlock(a1, 2, 1, []*block.Transaction{coinbase(testPayoutScript("BRANCH_B_2")), first})
+	require.NoError(t, chain.AddBlock(b2))
+	double := spend(reward, testPayoutScript("DOUBLE_SPEND"))
+	b3 := createValidTestBlock(b2, 3, 1, []*block.Transaction{coinbase(testPayoutScript("BRANCH_B_3")), double})
+	err = chain.AddBlock(b3)
+	require.ErrorIs(t, err, ErrInvalidBranch)
+	assert.Contains(t, err.Error(), "input UTXO not found")
+
+	// The heavier branch did not become the tip and the active chain is intact
+	assert.True(t, chain.IsInvalidBlock(b3.CalculateHash()))
+	assert.Equal(t, a2.CalculateHash(), chain.GetTipHash())
+	assert.Equal(t, a2.CalculateHash(), chain.GetBlockByHeight(2).CalculateHash())
+	assert.NotNil(t, chain.UTXOSet.GetUTXO(reward.Hash, 0))
+	assert.Nil(t, chain.UTXOSet.GetUTXO(first.Hash, 0))
+	assert.Nil(t, chain.UTXOSet.GetUTXO(double.Hash, 0))
+	require.NoError(t, chain.AuditUTXOSet())
+
+	// A block spending an output created on its own branch is valid there,
+	// though the active chain has no such output
+	next := spend(first, testPayoutScript("BRANCH_C_3"))
+	c3 := createValidTestBlock(b2, 3, 1, []*block.Transaction{coinbase(testPayoutScript("BRANCH_C_3")), next})
+	require.NoError(t, chain.AddBlock(c3))
+	assert.Equal(t, c3.CalculateHash(), chain.GetTipHash())
+	assert.Nil(t, chain.UTXOSet.GetUTXO(reward.Hash, 0))
+	assert.NotNil(t, chain.UTXOSet.GetUTXO(next.Hash, 0))
+}
+
+// TestSwitchDisconnectsToFork switches to a heavier branch in place, leaving
+// the state below the fork alone, and checks the result against a replay.
+func TestSwitchDisconnectsToFork(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	config := DefaultChainConfig()
+	config.AddressIndex = true
+	chain, err := NewChain(config, consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+
+	// Active chain: genesis - a1 - a2 - a3 - a4, side branch: a2 - b3 - b4 - b5
+	var active []*block.Block
+	prev := chain.GetGenesisBlock()
+	for h := uint64(1); h <= 4; h++ {
+		prev = createEmptyTestBlock(prev, h, 1)
+		require.NoError(t, chain.AddBlock(prev))
+		active = append(active, prev)
+	}
+	b3 := createTestBlockWithScript(active[1], 3, "BRANCH_B_3")
+	require.NoError(t, chain.AddBlock(b3))
+	b4 := createTestBlockWithScript(b3, 4, "BRANCH_B_4")
+	require.NoError(t, chain.AddBlock(b4))
+
+	// Replaying the branch would rebuild the UTXO set without an output no
+	// block created
+	marker := &utxo.UTXO{
+		TxHash:       bytes.Repeat([]byte{7}, 32),
+		Value:        1,
+		ScriptPubKey: testPayoutScript("MARKER"),
+		Address:      hex.EncodeToString(testPayoutScript("MARKER")),
+	}
+	chain.UTXOSet.AddUTXO(marker)
+	b5 := createTestBlockWithScript(b4, 5, "BRANCH_B_5")
+	require.NoError(t, chain.AddBlock(b5))
+	assert.Equal(t, b5.CalculateHash(), chain.GetTipHash())
+	assert.NotNil(t, chain.UTXOSet.GetUTXO(marker.TxHash, 0))
+	chain.UTXOSet.RemoveUTXO(marker.TxHash, 0)
+
+	assert.Equal(t, b3.CalculateHash(), chain.GetBlockByHeight(3).CalculateHash())
+	assert.Nil(t, chain.UTXOSet.GetUTXO(active[3].Transactions[0].Hash, 0))
+	_, err = chain.GetTxOut(active[3].Transactions[0].Hash, 0)
+	assert.Error(t, err)
+	history, _, err := chain.GetAddressHistory(hex.EncodeToString(testPayoutScript("COINBASE_TEST_4")), "", 0)
+	require.NoError(t, err)
+	assert.Empty(t, history)
+	work, err := chain.GetAccumulatedDifficulty(5)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), work.Int64())
+	assert.Equal(t, uint64(2), chain.ReorgStats().LastDepth)
+
+	// The state is the one a replay of the branch rebuilds
+	utxos, supply := chain.UTXOSet.Snapshot(), chain.GetTotalSupply()
+	indexed, addresses := len(chain.txIndex), len(chain.addrHistory)
+	require.NoError(t, chain.Reindex(5))
+	assert.ElementsMatch(t, utxos, chain.UTXOSet.Snapshot())
+	assert.Equal(t, supply, chain.GetTotalSupply())
+	assert.Equal(t, indexed, len(chain.txIndex))
+	assert.Equal(t, addresses, len(chain.addrHistory))
+
+	// Without undo data, as for blocks connected before a restart, the
+	// branch switched back to is replayed. The reindex only kept the active
+	// chain in memory, so the previous one is added back first
+	require.NoError(t, chain.AddBlock(active[2]))
+	require.NoError(t, chain.AddBlock(active[3]))
+	chain.mu.Lock()
+	chain.undo = make(map[string]*utxo.BlockDiff)
+	chain.mu.Unlock()
+	chain.UTXOSet.AddUTXO(marker)
+	require.NoError(t, chain.InvalidateBlock(b3.CalculateHash()))
+	assert.Equal(t, active[3].CalculateHash(), chain.GetTipHash())
+	assert.Nil(t, chain.UTXOSet.GetUTXO(marker.TxHash, 0))
+	assert.NotNil(t, chain.UTXOSet.GetUTXO(active[3].Transactions[0].Hash, 0))
+}
+
+func TestInvalidateBlockErrors(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	chain, err := NewChain(DefaultChainConfig(), consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+
+	err = chain.InvalidateBlock(chain.GetGenesisBlock().CalculateHash())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "genesis")
+
+	err = chain.InvalidateBlock(make([]byte, 32))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not found")
+
+	err = chain.ReconsiderBlock(make([]byte, 32))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not found")
+}
+
+// createTestBlockWithScript creates a mined block whose coinbase pays to
+// script, so that blocks at the same height on different branches differ.
+func createTestBlockWithScript(prevBlock *block.Block, height uint64, script string) *block.Block {
+	coinbaseTx := &block.Transaction{
+		Version: 1,
+		Inputs:  []*block.TxInput{},
+		Outputs: []*block.TxOutput{{Value: 1000000, ScriptPubKey: testPayoutScript(script)}},
+	}
+	coinbaseTx.Hash = coinbaseTx.CalculateHash()
+	return createValidTestBlock(prevBlock, height, 1, []*block.Transaction{coinbaseTx})
+}
@@ -0,0 +1,36 @@
+package chain
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+)
+
+// checkMerkleCommitment checks that the header of b commits to the
+// transactions it carries: every transaction hash must be computed from the
+// transaction's contents and the merkle root of those hashes must match the
+// header. No transaction may appear twice: spending the same outputs twice
+// would corrupt the UTXO set, and since the merkle tree pairs the last hash
+// of an odd level with itself, repeating the trailing transactions of a
+// valid block keeps its merkle root, so a duplicate is a mutation rather
+// than a different block.
+func checkMerkleCommitment(b *block.Block) error {
+	seen := make(map[string]int, len(b.Transactions))
+	for i, tx := range b.Transactions {
+		if tx == nil {
+			return fmt.Errorf("transaction %d is nil", i)
+		}
+		if !bytes.Equal(tx.Hash, tx.CalculateHash()) {
+			return fmt.Errorf("transaction %d hash %x does not match its contents", i, tx.Hash)
+		}
+		if first, duplicate := seen[string(tx.Hash)]; duplicate {
+			return fmt.Errorf("transaction %d duplicates transaction %d (%x)", i, first, tx.Hash)
+		}
+		seen[string(tx.Hash)] = i
+	}
+	if root := b.CalculateMerkleRoot(); !bytes.Equal(root, b.Header.MerkleRoot) {
+		return fmt.Errorf("merkle root mismatch: header %x, transactions %x", b.Header.MerkleRoot, root)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package chain
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/consensus"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/palaseus/adrenochain/pkg/utxo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerkleCommitment(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	chain, err := NewChain(DefaultChainConfig(), consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+
+	newBlock := func() *block.Block {
+		coinbase := &block.Transaction{
+			Version: 1,
+			Outputs: []*block.TxOutput{{Value: 1000000, ScriptPubKey: testPayoutScript("COINBASE_MERKLE")}},
+		}
+		coinbase.Hash = coinbase.CalculateHash()
+		return createValidTestBlock(chain.GetGenesisBlock(), 1, 1, []*block.Transaction{coinbase})
+	}
+
+	// A transaction altered after the header committed to it, keeping its
+	// stored hash so that the root built from stored hashes still matches
+	tampered := newBlock()
+	tampered.Transactions[0].Outputs[0].Value = 2000000
+	err = chain.AddBlock(tampered)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match its contents")
+
+	// A transaction replaced along with its hash no longer matches the root
+	replaced := newBlock()
+	replaced.Transactions[0].Outputs[0].Value = 2000000
+	replaced.Transactions[0].Hash = replaced.Transactions[0].CalculateHash()
+	err = chain.AddBlock(replaced)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "merkle root mismatch")
+
+	// The rejections are not cached against the header, so the block with
+	// the transactions the header commits to is still accepted
+	require.NoError(t, chain.AddBlock(newBlock()))
+}
+
+func TestDuplicateTransactionsRejected(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	chain, err := NewChain(DefaultChainConfig(), consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+
+	// Two signed spends of outputs funded directly in the UTXO set
+	key, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+	pubKey := key.PubKey().SerializeUncompressed()
+	pubKeyHash := sha256.Sum256(pubKey)
+	spend := func(seed byte) *block.Transaction {
+		prev := &utxo.UTXO{
+			TxHash:       bytes.Repeat([]byte{seed}, 32),
+			Value:        100000,
+			ScriptPubKey: pubKeyHash[12:],
+			Address:      hex.EncodeToString(pubKeyHash[12:]),
+		}
+		chain.UTXOSet.AddUTXO(prev)
+		tx := &block.Transaction{
+			Version: 1,
+			Inputs:  []*block.TxInput{{PrevTxHash: prev.TxHash, Sequence: 0xffffffff}},
+			Outputs: []*block.TxOutput{{Value: 90000, ScriptPubKey: []byte("SPEND_DUPLICATE")}},
+			Fee:     10000,
+		}
+		sigHash, err := utxo.TxSignatureHash(tx, 0, utxo.SigHashAll)
+		require.NoError(t, err)
+		r, s, err := ecdsa.Sign(rand.Reader, key.ToECDSA(), sigHash)
+		require.NoError(t, err)
+		tx.Inputs[0].ScriptSig = append(append(pubKey, r.FillBytes(make([]byte, 32))...), s.FillBytes(make([]byte, 32))...)
+		tx.Hash = tx.CalculateHash()
+		return tx
+	}
+	coinbase := &block.Transaction{
+		Version: 1,
+		Outputs: []*block.TxOutput{{Value: 1000000, ScriptPubKey: testPayoutScript("COINBASE_DUPLICATE")}},
+	}
+	coinbase.Hash = coinbase.CalculateHash()
+	first, second := spend(1), spend(2)
+	valid := createValidTestBlock(chain.GetGenesisBlock(), 1, 1, []*block.Transaction{coinbase, first, second})
+
+	// Repeating the last of an odd number of transactions keeps the merkle
+	// root, so the duplicate carries the valid block's header
+	duplicated := &block.Block{
+		Header:       valid.Header,
+		Transactions: []*block.Transaction{coinbase, first, second, second},
+	}
+	require.Equal(t, valid.Header.MerkleRoot, duplicated.CalculateMerkleRoot())
+	err = chain.AddBlock(duplicated)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "transaction 3 duplicates transaction 2")
+	assert.Zero(t, chain.GetHeight())
+
+	// A duplicate that changes the root is rejected as well
+	err = chain.AddBlock(createValidTestBlock(chain.GetGenesisBlock(), 1, 1, []*block.Transaction{coinbase, first, first}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "duplicates")
+
+	// The block with distinct transactions is accepted, its header not
+	// having been cached as invalid
+	require.NoError(t, chain.AddBlock(valid))
+	assert.Equal(t, uint64(1), chain.GetHeight())
+}
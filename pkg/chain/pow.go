@@ -0,0 +1,23 @@
+package chain
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+)
+
+// checkDifficultyLocked checks that the hash of a block meets the target of
+// the difficulty in its own header, so that the work it is credited with in
+// fork choice is the work it proves. Whether that difficulty is the one the
+// chain requires is checked by consensus validation.
+// Note: the caller must hold the chain lock.
+func (c *Chain) checkDifficultyLocked(b *block.Block, hash []byte) error {
+	if !c.config.EnforceDifficulty {
+		return nil
+	}
+	if bytes.Compare(hash, c.consensus.TargetForDifficulty(b.Header.Difficulty)) >= 0 {
+		return fmt.Errorf("invalid proof of work: block hash %x does not meet the target of its difficulty %d", hash, b.Header.Difficulty)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package chain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/consensus"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnforceDifficulty(t *testing.T) {
+	newChain := func(enforce bool) *Chain {
+		storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+		require.NoError(t, err)
+		t.Cleanup(func() { storageInstance.Close() })
+
+		config := DefaultChainConfig()
+		config.EnforceDifficulty = enforce
+		chain, err := NewChain(config, consensus.DefaultConsensusConfig(), storageInstance)
+		require.NoError(t, err)
+		return chain
+	}
+
+	// A hash has to meet the target of the difficulty its header declares
+	chain := newChain(true)
+	unproven := createEmptyTestBlock(chain.GetGenesisBlock(), 1, 1)
+	unproven.Header.Difficulty = 64
+	for hashLessThan(unproven.CalculateHash(), calculateTestTarget(64)) {
+		unproven.Header.Nonce++
+	}
+	err := chain.AddBlock(unproven)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not meet the target of its difficulty 64")
+	assert.True(t, chain.IsInvalidBlock(unproven.CalculateHash()))
+
+	// The in-memory difficulty drifting from the chain's history, as after
+	// a restart, does not get blocks at the history's difficulty rejected
+	chain.GetConsensus().ResetDifficulty(4)
+	honest := createEmptyTestBlock(chain.GetGenesisBlock(), 1, 1)
+	mineTestBlock(honest, 4)
+	require.NoError(t, chain.AddBlock(honest))
+	assert.Equal(t, uint64(1), chain.GetHeight())
+
+	// Without enforcement the check is skipped
+	chain = newChain(false)
+	chain.mu.Lock()
+	err = chain.checkDifficultyLocked(unproven, unproven.CalculateHash())
+	chain.mu.Unlock()
+	assert.NoError(t, err)
+}
+
+func TestEnforceDifficultyOnSideBranch(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	consensusConfig := consensus.DefaultConsensusConfig()
+	consensusConfig.DifficultyAdjustmentInterval = 2
+	consensusConfig.GenesisDifficulty = 2
+	chain, err := NewChain(DefaultChainConfig(), consensusConfig, storageInstance)
+	require.NoError(t, err)
+	genesis := chain.GetGenesisBlock()
+
+	// blockAt dates a block after genesis and mines it hard enough for any
+	// difficulty the test declares
+	blockAt := func(prev *block.Block, height uint64, label string, after time.Duration, difficulty uint64) *block.Block {
+		b := createTestBlockWithScript(prev, height, label)
+		b.Header.Timestamp = genesis.Header.Timestamp.Add(after)
+		b.Header.Difficulty = difficulty
+		mineTestBlock(b, 10)
+		return b
+	}
+
+	// The active chain came fast, so its difficulty fell to 1 at height 2
+	a1 := blockAt(genesis, 1, "active 1", time.Second, 2)
+	require.NoError(t, chain.AddBlock(a1))
+	a2 := blockAt(a1, 2, "active 2", 2*time.Second, 1)
+	require.NoError(t, chain.AddBlock(a2))
+	a3 := blockAt(a2, 3, "active 3", 3*time.Second, 1)
+	require.NoError(t, chain.AddBlock(a3))
+
+	// A side branch that came slowly has to carry difficulty 8 at height 2,
+	// not the active chain's 1, even with a hash meeting the lower target
+	s1 := blockAt(genesis, 1, "side 1", 80*time.Second, 2)
+	require.NoError(t, chain.AddBlock(s1))
+	easy := blockAt(s1, 2, "side 2", 81*time.Second, 1)
+	err = chain.AddBlock(easy)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match expected 8")
+
+	hard := blockAt(s1, 2, "side 2", 81*time.Second, 8)
+	assert.NoError(t, chain.AddBlock(hard))
+}
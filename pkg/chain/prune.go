@@ -0,0 +1,320 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/palaseus/adrenochain/pkg/utxo"
+)
+
+// DefaultPruneInterval is how often StartPruning prunes when PruneInterval
+// is zero.
+const DefaultPruneInterval = 10 * time.Minute
+
+// pruneBaseKey is the storage key of the state recorded at the highest
+// pruned block.
+const pruneBaseKey = "chain_prune_base"
+
+// ErrUnsafePrune is returned when pruning would delete block bodies that are
+// still needed.
+var ErrUnsafePrune = errors.New("prune height is above the safe prune height")
+
+// pruneBase is the state of the active chain at the highest pruned block.
+// Once the bodies below it are deleted, the UTXO set, indexes and supply
+// are rebuilt from it instead of by replaying the chain from genesis.
+type pruneBase struct {
+	Height      uint64            `json:"height"`
+	BlockHash   []byte            `json:"block_hash"`
+	SafeHeight  uint64            `json:"safe_height"` // SafeHeight is the safe prune height when the base was recorded.
+	UTXOs       []*utxo.UTXO      `json:"utxos"`
+	Issued      uint64            `json:"issued"`
+	Unspendable uint64            `json:"unspendable"`
+	Rewards     map[uint64]uint64 `json:"rewards"`
+	Work        string            `json:"work"` // Work is the accumulated difficulty at Height, in decimal.
+}
+
+// PruneStatus describes how far the chain has been pruned.
+type PruneStatus struct {
+	Enabled      bool   `json:"enabled"`
+	PrunedHeight uint64 `json:"pruned_height"` // PrunedHeight is the highest block whose body was deleted, 0 if none.
+	SafeHeight   uint64 `json:"safe_height"`   // SafeHeight is the highest block whose body may be deleted now.
+}
+
+// GetPruneStatus returns the pruned and the safe prune heights.
+func (c *Chain) GetPruneStatus() PruneStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	status := PruneStatus{
+		Enabled:    c.config.PruneDepth > 0,
+		SafeHeight: c.safePruneHeightLocked(),
+	}
+	if c.pruneBase != nil {
+		status.PrunedHeight = c.pruneBase.Height
+	}
+	return status
+}
+
+// SafePruneHeight returns the highest block whose body may be pruned: bodies
+// within PruneDepth or MaxReorgDepth of the tip are kept, as are those from
+// the stored tip up, which are replayed on startup. It is zero when pruning
+// is disabled.
+func (c *Chain) SafePruneHeight() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.safePruneHeightLocked()
+}
+
+// safePruneHeightLocked computes SafePruneHeight.
+// Note: the caller must hold the chain lock.
+func (c *Chain) safePruneHeightLocked() uint64 {
+	if c.config.PruneDepth == 0 || c.bestBlock == nil {
+		return 0
+	}
+	tip := c.bestBlock.Header.Height
+	safe := min(heightBelow(tip, c.config.PruneDepth), heightBelow(tip, c.config.MaxReorgDepth))
+
+	state, err := c.storage.GetChainState()
+	if err != nil {
+		return 0
+	}
+	return min(safe, heightBelow(state.Height, 1))
+}
+
+// heightBelow returns height less depth, or zero.
+func heightBelow(height, depth uint64) uint64 {
+	if height <= depth {
+		return 0
+	}
+	return height - depth
+}
+
+// PruneBlocks deletes the bodies of the active chain's blocks from height 1
+// up to height, along with side-branch blocks below it, keeping genesis. The
+// steps are sequenced so that the chain stays consistent if one fails:
+// first the UTXO set, supply and work at height are recorded as the base
+// that later replays start from, then the bodies and their height index
+// entries are deleted, and finally the transaction and address index
+// entries of the pruned blocks are dropped, as a replay from the base would
+// not rebuild them. Pruning above SafePruneHeight fails with ErrUnsafePrune.
+func (c *Chain) PruneBlocks(height uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pruner, ok := c.storage.(storage.BlockPruner)
+	if !ok {
+		return fmt.Errorf("storage does not support deleting blocks")
+	}
+	safe := c.safePruneHeightLocked()
+	if height > safe {
+		return fmt.Errorf("%w: cannot prune to height %d, safe prune height is %d", ErrUnsafePrune, height, safe)
+	}
+	if height == 0 || (c.pruneBase != nil && height <= c.pruneBase.Height) {
+		return nil
+	}
+
+	base, err := c.buildPruneBaseLocked(height)
+	if err != nil {
+		return err
+	}
+	base.SafeHeight = safe
+	data, err := json.Marshal(base)
+	if err != nil {
+		return fmt.Errorf("failed to encode prune base: %w", err)
+	}
+	if err := c.storage.Write([]byte(pruneBaseKey), data); err != nil {
+		return fmt.Errorf("failed to store prune base: %w", err)
+	}
+	c.pruneBase = base
+
+	for hash, b := range c.blocks {
+		if b.Header.Height == 0 || b.Header.Height > height {
+			continue
+		}
+		if err := pruner.DeleteBlock([]byte(hash)); err != nil {
+			return fmt.Errorf("failed to delete block %x: %w", hash, err)
+		}
+		delete(c.blocks, hash)
+	}
+	for h := uint64(1); h <= height; h++ {
+		if indexed, err := c.storage.Read(heightKey(h)); err == nil {
+			if err := pruner.DeleteBlock(indexed); err != nil {
+				return fmt.Errorf("failed to delete block at height %d: %w", h, err)
+			}
+		}
+		if err := c.dropHeightIndexLocked(h, h); err != nil {
+			return err
+		}
+		delete(c.blockByHeight, h)
+	}
+
+	c.pruneIndexesLocked(height)
+	return nil
+}
+
+// buildPruneBaseLocked replays the active chain from the current base, or
+// genesis, up to height on a scratch chain to record the state at height.
+// Note: the caller must hold the chain lock.
+func (c *Chain) buildPruneBaseLocked(height uint64) (*pruneBase, error) {
+	scratch := &Chain{
+		config:  c.config,
+		storage: c.storage,
+		UTXOSet: utxo.NewUTXOSet(),
+		rewards: make(map[uint64]uint64),
+	}
+	work := scratch.restorePruneBaseLocked(c.pruneBase)
+	scratch.pruneBase = c.pruneBase
+
+	var prev *block.Block
+	for h := uint64(0); h <= height; h = scratch.nextStoredHeight(h) {
+		b, err := scratch.loadIndexedBlock(h, prev)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load block at height %d: %w", h, err)
+		}
+		if err := scratch.UTXOSet.ProcessBlock(b); err != nil {
+			return nil, fmt.Errorf("failed to replay block at height %d: %w", h, err)
+		}
+		scratch.indexBlockLocked(b)
+		if h > 0 {
+			work = new(big.Int).Add(work, new(big.Int).SetUint64(b.Header.Difficulty))
+		}
+		prev = b
+	}
+
+	return &pruneBase{
+		Height:      height,
+		BlockHash:   prev.CalculateHash(),
+		UTXOs:       scratch.UTXOSet.Snapshot(),
+		Issued:      scratch.issued,
+		Unspendable: scratch.unspendable,
+		Rewards:     scratch.rewards,
+		Work:        work.String(),
+	}, nil
+}
+
+// restorePruneBaseLocked resets the UTXO set, indexes and supply to the
+// state recorded in base, or to empty if base is nil, and returns the
+// accumulated difficulty to replay blocks on top of.
+// Note: the caller must hold the chain lock.
+func (c *Chain) restorePruneBaseLocked(base *pruneBase) *big.Int {
+	c.resetTxIndexLocked()
+	if base == nil {
+		c.UTXOSet.Reset()
+		return big.NewInt(0)
+	}
+
+	c.UTXOSet.Restore(base.UTXOs)
+	c.issued = base.Issued
+	c.unspendable = base.Unspendable
+	for height, reward := range base.Rewards {
+		c.rewards[height] = reward
+	}
+	work, ok := new(big.Int).SetString(base.Work, 10)
+	if !ok {
+		return big.NewInt(0)
+	}
+	return work
+}
+
+// pruneIndexesLocked drops the transaction and address index entries and
+// undo data of the blocks up to height.
+// Note: the caller must hold the chain lock.
+func (c *Chain) pruneIndexesLocked(height uint64) {
+	c.pruneUndoLocked(height)
+	pruned := make(map[string]struct{})
+	for txHash, entry := range c.txIndex {
+		if entry.height <= height {
+			pruned[txHash] = struct{}{}
+			delete(c.txIndex, txHash)
+		}
+	}
+	for key, spender := range c.spentBy {
+		if _, exists := pruned[string(spender)]; exists {
+			delete(c.spentBy, key)
+		}
+	}
+	for address, history := range c.addrHistory {
+		kept := history[:0]
+		for _, entry := range history {
+			if entry.height > height {
+				kept = append(kept, entry)
+			}
+		}
+		if len(kept) == 0 {
+			delete(c.addrHistory, address)
+		} else {
+			c.addrHistory[address] = kept
+		}
+	}
+}
+
+// isPruneBoundary reports whether b is the lowest stored block of the active
+// chain above genesis, the child of the prune base.
+func (c *Chain) isPruneBoundary(b *block.Block) bool {
+	return c.pruneBase != nil && b.Header.Height == c.pruneBase.Height+1 &&
+		bytes.Equal(b.Header.PrevBlockHash, c.pruneBase.BlockHash)
+}
+
+// nextStoredHeight returns the height of the active chain's next block above
+// height whose body is stored, skipping from genesis over pruned blocks.
+func (c *Chain) nextStoredHeight(height uint64) uint64 {
+	if height == 0 && c.pruneBase != nil {
+		return c.pruneBase.Height + 1
+	}
+	return height + 1
+}
+
+// loadPruneBase reads the prune base recorded in storage, if any.
+func loadPruneBase(s storage.StorageInterface) (*pruneBase, error) {
+	has, err := s.Has([]byte(pruneBaseKey))
+	if err != nil || !has {
+		return nil, nil
+	}
+	data, err := s.Read([]byte(pruneBaseKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prune base: %w", err)
+	}
+	var base pruneBase
+	if err := json.Unmarshal(data, &base); err != nil {
+		return nil, fmt.Errorf("failed to decode prune base: %w", err)
+	}
+	if base.Rewards == nil {
+		base.Rewards = make(map[uint64]uint64)
+	}
+	return &base, nil
+}
+
+// StartPruning prunes the chain to SafePruneHeight every PruneInterval until
+// ctx is done. It does nothing if PruneDepth is zero.
+func (c *Chain) StartPruning(ctx context.Context) {
+	if c.config.PruneDepth == 0 {
+		return
+	}
+	interval := c.config.PruneInterval
+	if interval <= 0 {
+		interval = DefaultPruneInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := c.PruneBlocks(c.SafePruneHeight()); err != nil {
+					fmt.Printf("Chain pruning failed: %v\n", err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,121 @@
+package chain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/consensus"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPruneBlocks(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	config := DefaultChainConfig()
+	config.MaxReorgDepth = 2
+	config.PruneDepth = 3
+	chain, err := NewChain(config, consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+
+	blocks := []*block.Block{chain.GetGenesisBlock()}
+	for height := uint64(1); height <= 10; height++ {
+		b := createEmptyTestBlock(blocks[height-1], height, 1)
+		require.NoError(t, chain.AddBlock(b))
+		blocks = append(blocks, b)
+	}
+
+	safe := chain.SafePruneHeight()
+	assert.Equal(t, uint64(7), safe)
+	utxosBefore := chain.UTXOSet.GetStats()
+	supplyBefore := chain.GetTotalSupply()
+	workBefore, err := chain.GetAccumulatedDifficulty(10)
+	require.NoError(t, err)
+
+	// Pruning a block that could still be reorganized is refused
+	err = chain.PruneBlocks(safe + 1)
+	require.True(t, errors.Is(err, ErrUnsafePrune), "unexpected error: %v", err)
+	assert.Equal(t, uint64(0), chain.GetPruneStatus().PrunedHeight)
+	for _, b := range blocks {
+		has, err := storageInstance.Has(b.CalculateHash())
+		require.NoError(t, err)
+		assert.True(t, has, "no block is deleted by a refused prune")
+	}
+
+	require.NoError(t, chain.PruneBlocks(safe))
+	status := chain.GetPruneStatus()
+	assert.True(t, status.Enabled)
+	assert.Equal(t, safe, status.PrunedHeight)
+
+	// Bodies up to the prune height are gone, genesis and later ones remain
+	for height, b := range blocks {
+		_, err := storageInstance.GetBlock(b.CalculateHash())
+		if height == 0 || uint64(height) > safe {
+			assert.NoError(t, err, "block %d should be kept", height)
+		} else {
+			assert.Error(t, err, "block %d should be pruned", height)
+			assert.Nil(t, chain.GetBlockByHeight(uint64(height)))
+		}
+	}
+
+	// The remaining data is consistent with itself and with the state before
+	_, err = chain.VerifyIntegrity()
+	require.NoError(t, err)
+	require.NoError(t, chain.AuditUTXOSet())
+	assert.Equal(t, utxosBefore, chain.UTXOSet.GetStats())
+	assert.Equal(t, supplyBefore, chain.GetTotalSupply())
+
+	_, err = chain.GetTxOut(blocks[safe].Transactions[0].Hash, 0)
+	assert.Error(t, err, "pruned transactions leave the index")
+	_, err = chain.GetTxOut(blocks[safe+1].Transactions[0].Hash, 0)
+	assert.NoError(t, err)
+
+	// A reindex replays from the prune base and reaches the same state
+	require.NoError(t, chain.Reindex(10))
+	assert.Equal(t, utxosBefore, chain.UTXOSet.GetStats())
+	assert.Equal(t, supplyBefore, chain.GetTotalSupply())
+	workAfter, err := chain.GetAccumulatedDifficulty(10)
+	require.NoError(t, err)
+	assert.Equal(t, workBefore, workAfter)
+	assert.Error(t, chain.Reindex(safe-1), "cannot reindex to a pruned height")
+
+	// The chain keeps growing on top of the pruned history
+	next := createEmptyTestBlock(blocks[10], 11, 1)
+	require.NoError(t, chain.AddBlock(next))
+	assert.Equal(t, uint64(11), chain.GetHeight())
+	require.NoError(t, chain.AuditUTXOSet())
+}
+
+func TestPrunedChainWorkSurvivesRestart(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	config := DefaultChainConfig()
+	config.MaxReorgDepth = 2
+	config.PruneDepth = 3
+	chain, err := NewChain(config, consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+
+	prev := chain.GetGenesisBlock()
+	for height := uint64(1); height <= 10; height++ {
+		b := createEmptyTestBlock(prev, height, 1)
+		require.NoError(t, chain.AddBlock(b))
+		prev = b
+	}
+	require.NoError(t, chain.PruneBlocks(chain.SafePruneHeight()))
+	workBefore, err := chain.GetAccumulatedDifficulty(10)
+	require.NoError(t, err)
+
+	// The reopened chain seeds its work from the prune base
+	reopened, err := NewChain(config, consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), reopened.GetHeight())
+	workAfter, err := reopened.GetAccumulatedDifficulty(10)
+	require.NoError(t, err)
+	assert.Equal(t, workBefore, workAfter)
+}
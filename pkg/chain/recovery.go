@@ -0,0 +1,223 @@
+package chain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+)
+
+// heightIndexPrefix prefixes the storage keys mapping heights of the active
+// chain to block hashes.
+const heightIndexPrefix = "chain_height_"
+
+// IntegrityError reports the lowest block of the active chain that is
+// missing or corrupt in storage.
+type IntegrityError struct {
+	Height uint64 // Height is the height of the bad block.
+	Err    error  // Err describes what is wrong with it.
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("block at height %d failed integrity check: %v", e.Height, e.Err)
+}
+
+func (e *IntegrityError) Unwrap() error {
+	return e.Err
+}
+
+// VerifyIntegrity checks the blocks of the active chain in storage, from
+// genesis, skipping pruned blocks, up to the stored tip: each must load,
+// hash to the indexed hash, carry transactions matching its merkle root and
+// extend the block below it. It returns the height of the highest block
+// verified before the first bad one, which is reported as an
+// *IntegrityError.
+func (c *Chain) VerifyIntegrity() (uint64, error) {
+	state, err := c.storage.GetChainState()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load chain state: %w", err)
+	}
+
+	var prev *block.Block
+	for height := uint64(0); height <= state.Height; height = c.nextStoredHeight(height) {
+		b, err := c.loadIndexedBlock(height, prev)
+		if err != nil {
+			good := uint64(0)
+			if prev != nil {
+				good = prev.Header.Height
+			}
+			return good, &IntegrityError{Height: height, Err: err}
+		}
+		prev = b
+	}
+
+	if !bytes.Equal(prev.CalculateHash(), state.BestBlockHash) {
+		return state.Height - 1, &IntegrityError{
+			Height: state.Height,
+			Err:    fmt.Errorf("indexed block %x is not the stored tip %x", prev.CalculateHash(), state.BestBlockHash),
+		}
+	}
+	return state.Height, nil
+}
+
+// Reindex rebuilds the active chain from the blocks in storage up to height,
+// dropping the blocks above it, and replays it to rebuild the UTXO set and
+// indexes. The chain state is rewritten with the block at height as tip.
+func (c *Chain) Reindex(height uint64) error {
+	c.mu.Lock()
+	defer c.unlockAndNotify()
+
+	blocks := make(map[string]*block.Block)
+	var prev *block.Block
+	for h := uint64(0); h <= height; h = c.nextStoredHeight(h) {
+		b, err := c.loadIndexedBlock(h, prev)
+		if err != nil {
+			return fmt.Errorf("failed to reindex block at height %d: %w", h, err)
+		}
+		blocks[string(b.CalculateHash())] = b
+		if h == 0 {
+			c.genesisBlock = b
+		}
+		prev = b
+	}
+	if prev.Header.Height != height {
+		return fmt.Errorf("cannot reindex to height %d, blocks up to height %d are pruned", height, c.pruneBase.Height)
+	}
+
+	c.blocks = blocks
+	return c.replayBranchLocked(prev, false)
+}
+
+// recoverFromStorage verifies the stored chain on startup and reindexes it
+// up to the last block that verified, so that a node whose storage was
+// corrupted, e.g. by a crash, restarts on a consistent chain instead of
+// failing to start.
+func (c *Chain) recoverFromStorage() error {
+	if err := c.indexLegacyChain(); err != nil {
+		return err
+	}
+
+	height, err := c.VerifyIntegrity()
+	var corrupt *IntegrityError
+	if errors.As(err, &corrupt) {
+		if corrupt.Height == 0 {
+			return fmt.Errorf("cannot recover chain: %w", err)
+		}
+		fmt.Printf("Chain storage is corrupt (%v), reindexing up to height %d\n", err, height)
+	} else if err != nil {
+		return err
+	}
+	return c.Reindex(height)
+}
+
+// indexLegacyChain writes the height index of a data dir created before the
+// chain kept one, walking back from the stored tip to genesis, so that it
+// can be verified like any other. Data dirs that already have an index are
+// left alone.
+func (c *Chain) indexLegacyChain() error {
+	if c.pruneBase != nil {
+		return nil
+	}
+	if _, err := c.storage.Read(heightKey(0)); !errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	state, err := c.storage.GetChainState()
+	if err != nil {
+		return fmt.Errorf("failed to load chain state: %w", err)
+	}
+	hashes := make([][]byte, state.Height+1)
+	hash := state.BestBlockHash
+	for height := state.Height; ; height-- {
+		b, err := c.storage.GetBlock(hash)
+		if err == nil && (b.Header == nil || b.Header.Height != height) {
+			err = fmt.Errorf("block is not at height %d", height)
+		}
+		if err != nil {
+			return fmt.Errorf("cannot recover chain: the data dir has no height index and block %x cannot be loaded to rebuild it (%v); resync into an empty data dir", hash, err)
+		}
+		hashes[height] = hash
+		if height == 0 {
+			break
+		}
+		hash = b.Header.PrevBlockHash
+	}
+
+	for height, hash := range hashes {
+		if err := c.storage.Write(heightKey(uint64(height)), hash); err != nil {
+			return fmt.Errorf("failed to store height index: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadIndexedBlock loads the block of the active chain at height from
+// storage and checks it. prev is the block below it, nil for genesis and
+// for the lowest block above pruned ones, which must extend the prune base.
+func (c *Chain) loadIndexedBlock(height uint64, prev *block.Block) (*block.Block, error) {
+	hash, err := c.storage.Read(heightKey(height))
+	if err != nil {
+		return nil, fmt.Errorf("height index entry not found: %w", err)
+	}
+	b, err := c.storage.GetBlock(hash)
+	if err != nil {
+		return nil, err
+	}
+	if b.Header == nil {
+		return nil, fmt.Errorf("block %x has no header", hash)
+	}
+	if !bytes.Equal(b.CalculateHash(), hash) {
+		return nil, fmt.Errorf("block hashes to %x, expected %x", b.CalculateHash(), hash)
+	}
+	if b.Header.Height != height {
+		return nil, fmt.Errorf("block %x has height %d", hash, b.Header.Height)
+	}
+	if prev != nil && prev.Header.Height+1 == height && !bytes.Equal(b.Header.PrevBlockHash, prev.CalculateHash()) {
+		return nil, fmt.Errorf("block %x does not extend block at height %d", hash, height-1)
+	}
+	if c.pruneBase != nil && height == c.pruneBase.Height+1 && !c.isPruneBoundary(b) {
+		return nil, fmt.Errorf("block %x does not extend the prune base at height %d", hash, c.pruneBase.Height)
+	}
+	for i, tx := range b.Transactions {
+		if tx == nil || !bytes.Equal(tx.CalculateHash(), tx.Hash) {
+			return nil, fmt.Errorf("block %x transaction %d does not match its hash", hash, i)
+		}
+	}
+	if !bytes.Equal(b.CalculateMerkleRoot(), b.Header.MerkleRoot) {
+		return nil, fmt.Errorf("block %x transactions do not match its merkle root", hash)
+	}
+	return b, nil
+}
+
+// storeHeightLocked records a block as the active chain's block at its
+// height, entering safe mode if the write fails.
+// Note: the caller must hold the chain lock.
+func (c *Chain) storeHeightLocked(b *block.Block, hash []byte) error {
+	if err := c.storage.Write(heightKey(b.Header.Height), hash); err != nil {
+		c.enterSafeModeLocked(err)
+		return fmt.Errorf("failed to store height index: %w", err)
+	}
+	return nil
+}
+
+// dropHeightIndexLocked removes the height index entries from height up to
+// top, left by a previous active chain that reached higher than the current
+// one, so that they are not replayed on startup. It enters safe mode if a
+// delete fails.
+// Note: the caller must hold the chain lock.
+func (c *Chain) dropHeightIndexLocked(height, top uint64) error {
+	for h := height; h <= top; h++ {
+		if err := c.storage.Delete(heightKey(h)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			c.enterSafeModeLocked(err)
+			return fmt.Errorf("failed to drop height index entry %d: %w", h, err)
+		}
+	}
+	return nil
+}
+
+// heightKey is the storage key of the height index entry for height.
+func heightKey(height uint64) []byte {
+	return []byte(fmt.Sprintf("%s%d", heightIndexPrefix, height))
+}
@@ -0,0 +1,127 @@
+package chain
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/consensus"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoRecoverFromCorruptBlock(t *testing.T) {
+	dataDir := t.TempDir()
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: dataDir})
+	require.NoError(t, err)
+
+	config := DefaultChainConfig()
+	config.AutoRecover = true
+	chain, err := NewChain(config, consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+
+	blocks := []*block.Block{chain.GetGenesisBlock()}
+	for height := uint64(1); height <= 5; height++ {
+		b := createEmptyTestBlock(blocks[height-1], height, 1)
+		require.NoError(t, chain.AddBlock(b))
+		blocks = append(blocks, b)
+	}
+	tipHash := chain.GetTipHash()
+
+	// An intact chain verifies up to its tip
+	height, err := chain.VerifyIntegrity()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), height)
+
+	// The node crashes, leaving the block at height 3 corrupt on disk
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, blocks[3].HexHash()), []byte("{\"header\":"), 0644))
+
+	height, err = chain.VerifyIntegrity()
+	var corrupt *IntegrityError
+	require.ErrorAs(t, err, &corrupt)
+	assert.Equal(t, uint64(3), corrupt.Height)
+	assert.Equal(t, uint64(2), height)
+
+	// The restarted node reindexes up to the last intact block
+	recovered, err := NewChain(config, consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), recovered.GetHeight())
+	assert.Equal(t, blocks[2].CalculateHash(), recovered.GetTipHash())
+	assert.Equal(t, blocks[0].CalculateHash(), recovered.GetGenesisBlock().CalculateHash())
+	assert.Equal(t, recovered.UTXOSet.GetStats()["total_value"], recovered.GetTotalSupply().Issued)
+	assert.NotNil(t, recovered.UTXOSet.GetUTXO(blocks[2].Transactions[0].Hash, 0))
+	assert.Nil(t, recovered.UTXOSet.GetUTXO(blocks[3].Transactions[0].Hash, 0))
+
+	state, err := storageInstance.GetChainState()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), state.Height)
+	_, err = recovered.VerifyIntegrity()
+	require.NoError(t, err)
+
+	// The dropped blocks are accepted again and the node reaches its old tip
+	for _, b := range blocks[3:] {
+		require.NoError(t, recovered.AddBlock(b))
+	}
+	assert.Equal(t, tipHash, recovered.GetTipHash())
+	height, err = recovered.VerifyIntegrity()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), height)
+}
+
+func TestAutoRecoverCorruptGenesis(t *testing.T) {
+	dataDir := t.TempDir()
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: dataDir})
+	require.NoError(t, err)
+
+	config := DefaultChainConfig()
+	config.AutoRecover = true
+	chain, err := NewChain(config, consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+	require.NoError(t, chain.AddBlock(createEmptyTestBlock(chain.GetGenesisBlock(), 1, 1)))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, chain.GetGenesisBlock().HexHash()), []byte("garbage"), 0644))
+
+	_, err = NewChain(config, consensus.DefaultConsensusConfig(), storageInstance)
+	assert.ErrorContains(t, err, "cannot recover chain")
+}
+
+func TestAutoRecoverLegacyDataDir(t *testing.T) {
+	dataDir := t.TempDir()
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: dataDir})
+	require.NoError(t, err)
+
+	chain, err := NewChain(DefaultChainConfig(), consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+	blocks := []*block.Block{chain.GetGenesisBlock()}
+	for height := uint64(1); height <= 3; height++ {
+		b := createEmptyTestBlock(blocks[height-1], height, 1)
+		require.NoError(t, chain.AddBlock(b))
+		blocks = append(blocks, b)
+	}
+
+	// Data dirs written before the height index have no entries
+	dropIndex := func() {
+		for height := range blocks {
+			require.NoError(t, storageInstance.Delete(heightKey(uint64(height))))
+		}
+	}
+	dropIndex()
+
+	config := DefaultChainConfig()
+	config.AutoRecover = true
+	recovered, err := NewChain(config, consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), recovered.GetHeight())
+	assert.Equal(t, blocks[3].CalculateHash(), recovered.GetTipHash())
+	height, err := recovered.VerifyIntegrity()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), height)
+
+	// Without the index a broken chain cannot be walked, so recovery refuses
+	dropIndex()
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, blocks[2].HexHash()), []byte("garbage"), 0644))
+	_, err = NewChain(config, consensus.DefaultConsensusConfig(), storageInstance)
+	assert.ErrorContains(t, err, "has no height index")
+}
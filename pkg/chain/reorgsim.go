@@ -0,0 +1,129 @@
+package chain
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/utxo"
+)
+
+// ReorgSimulation describes what switching the active chain to a block would
+// do, as computed by SimulateReorg.
+type ReorgSimulation struct {
+	Target     []byte   // Target is the hash of the block simulated as the new tip.
+	ForkHeight uint64   // ForkHeight is the height of the last block shared with the active chain.
+	Disconnect [][]byte // Disconnect lists the active chain blocks that would be disconnected, tip first.
+	Connect    [][]byte // Connect lists the blocks that would be connected, lowest first.
+	Work       *big.Int // Work is the accumulated difficulty of the target's branch, nil if it is invalid.
+	TipWork    *big.Int // TipWork is the accumulated difficulty of the active chain.
+	MoreWork   bool     // MoreWork is set when fork choice would prefer the target's branch.
+	Valid      bool     // Valid is set when every block of the branch is valid and its transactions replay.
+	Reason     string   // Reason explains why the branch is invalid.
+}
+
+// Depth returns the number of blocks the reorganization would disconnect.
+func (s *ReorgSimulation) Depth() uint64 {
+	return uint64(len(s.Disconnect))
+}
+
+// SimulateReorg computes which blocks a switch of the active chain to the
+// block with the given hash would disconnect and connect, and whether the
+// resulting chain would be valid, without changing the chain. The branch is
+// replayed from genesis, or the prune base, on a scratch UTXO set: its
+// blocks must not be known invalid and the transactions of the blocks to be
+// connected must be valid against the outputs they spend.
+func (c *Chain) SimulateReorg(hash []byte) (*ReorgSimulation, error) {
+	// GetBlock caches blocks loaded from storage, so the write lock is held
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	target := c.GetBlock(hash)
+	if target == nil {
+		return nil, fmt.Errorf("block %x not found", hash)
+	}
+
+	sim := &ReorgSimulation{Target: target.CalculateHash(), Valid: true}
+	tipWork, err := c.accumulatedDifficultyLocked(c.height)
+	if err != nil {
+		return nil, err
+	}
+	sim.TipWork = tipWork
+
+	// The branch above the last block it shares with the active chain is
+	// connected, the active chain above that block disconnected
+	path, base := c.branchLocked(target)
+	active := c.activeSetLocked()
+	forked := false
+	for _, b := range path {
+		if active[string(b.CalculateHash())] {
+			sim.ForkHeight, forked = b.Header.Height, true
+			break
+		}
+		sim.Connect = append([][]byte{b.CalculateHash()}, sim.Connect...)
+	}
+	if !forked && base != nil {
+		sim.ForkHeight = base.Height
+	}
+	for b := c.bestBlock; b != nil && b.Header.Height > sim.ForkHeight; b = c.GetBlock(b.Header.PrevBlockHash) {
+		sim.Disconnect = append(sim.Disconnect, b.CalculateHash())
+	}
+
+	sim.Work = c.chainWorkLocked(target, make(map[string]*big.Int))
+	if sim.Work == nil {
+		sim.Valid, sim.Reason = false, c.invalidBranchReasonLocked(path)
+		return sim, nil
+	}
+	sim.MoreWork = sim.Work.Cmp(tipWork) > 0
+
+	// Replay the branch, validating the transactions of the blocks to be
+	// connected against the UTXO set they would spend from
+	scratch := utxo.NewUTXOSet()
+	scratch.SetSpendAuthRegistry(c.UTXOSet.SpendAuthRegistry())
+	if base != nil {
+		scratch.Restore(base.UTXOs)
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		b := path[i]
+		if !active[string(b.CalculateHash())] {
+			if err := replayTransactions(scratch, b); err != nil {
+				sim.Valid, sim.Reason = false, fmt.Sprintf("failed to replay block %x: %v", b.CalculateHash(), err)
+				break
+			}
+		}
+		if _, err := scratch.ApplyBlock(b); err != nil {
+			sim.Valid, sim.Reason = false, fmt.Sprintf("failed to replay block %x: %v", b.CalculateHash(), err)
+			break
+		}
+	}
+	return sim, nil
+}
+
+// replayTransactions validates the transactions of a block in block order
+// against a UTXO set holding the state before the block.
+func replayTransactions(us *utxo.UTXOSet, b *block.Block) error {
+	view := utxo.NewBlockUTXOView(us, b.Header.Height)
+	for _, tx := range b.Transactions {
+		if err := us.ValidateTransactionWithView(tx, view); err != nil {
+			return fmt.Errorf("transaction %x: %w", tx.Hash, err)
+		}
+		view.Apply(tx)
+	}
+	return nil
+}
+
+// invalidBranchReasonLocked explains why the branch of blocks in path, tip
+// first, has no chain work.
+// Note: the caller must hold the chain lock.
+func (c *Chain) invalidBranchReasonLocked(path []*block.Block) string {
+	for _, b := range path {
+		hash := b.CalculateHash()
+		if _, invalidated := c.invalidated[string(hash)]; invalidated {
+			return fmt.Sprintf("block %x was manually invalidated", hash)
+		}
+		if reason, rejected := c.invalidBlocks.get(hash); rejected {
+			return fmt.Sprintf("block %x is invalid: %s", hash, reason)
+		}
+	}
+	return "branch does not connect to the genesis block"
+}
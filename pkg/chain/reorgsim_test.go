@@ -0,0 +1,118 @@
+package chain
+
+import (
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/consensus"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulateReorg(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	config := DefaultChainConfig()
+	config.BlockUndo = true
+	chain, err := NewChain(config, consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+
+	// Active chain: genesis - a1 - a2 - a3, side branch: a1 - b2 - b3
+	a1 := createEmptyTestBlock(chain.GetGenesisBlock(), 1, 1)
+	require.NoError(t, chain.AddBlock(a1))
+	a2 := createEmptyTestBlock(a1, 2, 1)
+	require.NoError(t, chain.AddBlock(a2))
+	a3 := createEmptyTestBlock(a2, 3, 1)
+	require.NoError(t, chain.AddBlock(a3))
+	b2 := createTestBlockWithScript(a1, 2, "BRANCH_B_2")
+	require.NoError(t, chain.AddBlock(b2))
+	b3 := createTestBlockWithScript(b2, 3, "BRANCH_B_3")
+	require.NoError(t, chain.AddBlock(b3))
+
+	before := chain.UTXOSet.Snapshot()
+	sim, err := chain.SimulateReorg(b3.CalculateHash())
+	require.NoError(t, err)
+	assert.True(t, sim.Valid, sim.Reason)
+	assert.Equal(t, uint64(1), sim.ForkHeight)
+	assert.Equal(t, [][]byte{a3.CalculateHash(), a2.CalculateHash()}, sim.Disconnect)
+	assert.Equal(t, [][]byte{b2.CalculateHash(), b3.CalculateHash()}, sim.Connect)
+	assert.Equal(t, uint64(2), sim.Depth())
+	assert.Equal(t, int64(3), sim.Work.Int64())
+	assert.Equal(t, int64(3), sim.TipWork.Int64())
+	assert.False(t, sim.MoreWork, "an equal amount of work does not displace the tip")
+
+	// The simulation changed nothing
+	assert.Equal(t, a3.CalculateHash(), chain.GetTipHash())
+	assert.ElementsMatch(t, before, chain.UTXOSet.Snapshot())
+	assert.Zero(t, chain.ReorgStats().Reorgs)
+
+	// An actual reorg to b3 disconnects and connects the same blocks
+	diffs, cancel := chain.SubscribeUTXODiffs(16)
+	defer cancel()
+	require.NoError(t, chain.InvalidateBlock(a2.CalculateHash()))
+	require.Equal(t, b3.CalculateHash(), chain.GetTipHash())
+	var disconnected, connected [][]byte
+	for len(diffs) > 0 {
+		event := <-diffs
+		if event.Disconnected {
+			disconnected = append(disconnected, event.Diff.BlockHash)
+		} else {
+			connected = append(connected, event.Diff.BlockHash)
+		}
+	}
+	assert.Equal(t, sim.Disconnect, disconnected)
+	assert.Equal(t, sim.Connect, connected)
+
+	// Switching back to the invalidated branch would not be valid
+	sim, err = chain.SimulateReorg(a3.CalculateHash())
+	require.NoError(t, err)
+	assert.False(t, sim.Valid)
+	assert.Contains(t, sim.Reason, "manually invalidated")
+	assert.Equal(t, [][]byte{b3.CalculateHash(), b2.CalculateHash()}, sim.Disconnect)
+
+	// Simulating the tip itself is a no-op
+	sim, err = chain.SimulateReorg(b3.CalculateHash())
+	require.NoError(t, err)
+	assert.True(t, sim.Valid)
+	assert.Empty(t, sim.Disconnect)
+	assert.Empty(t, sim.Connect)
+
+	_, err = chain.SimulateReorg(make([]byte, 32))
+	assert.ErrorContains(t, err, "not found")
+}
+
+// TestSimulateReorgReplayFailure simulates a branch whose transactions do
+// not replay on the UTXO set.
+func TestSimulateReorgReplayFailure(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	chain, err := NewChain(DefaultChainConfig(), consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+	a1 := createEmptyTestBlock(chain.GetGenesisBlock(), 1, 1)
+	require.NoError(t, chain.AddBlock(a1))
+
+	// A side block spending an output that does not exist, placed directly
+	// in memory as validation would refuse to store it
+	b1 := createTestBlockWithScript(chain.GetGenesisBlock(), 1, "BRANCH_B_1")
+	b1.Transactions = append(b1.Transactions, &block.Transaction{
+		Version: 1,
+		Inputs:  []*block.TxInput{{PrevTxHash: make([]byte, 32), ScriptSig: make([]byte, 129)}},
+		Outputs: []*block.TxOutput{{Value: 1000, ScriptPubKey: []byte("anyone")}},
+		Hash:    []byte("spends-missing-output"),
+	})
+	chain.mu.Lock()
+	chain.blocks[string(b1.CalculateHash())] = b1
+	chain.mu.Unlock()
+
+	sim, err := chain.SimulateReorg(b1.CalculateHash())
+	require.NoError(t, err)
+	assert.False(t, sim.Valid)
+	assert.Contains(t, sim.Reason, "failed to replay block")
+	assert.Equal(t, [][]byte{a1.CalculateHash()}, sim.Disconnect)
+	assert.Equal(t, [][]byte{b1.CalculateHash()}, sim.Connect)
+}
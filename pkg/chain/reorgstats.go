@@ -0,0 +1,42 @@
+package chain
+
+import "time"
+
+// DefaultDeepReorgDepth is the default depth from which a reorganization is
+// counted as deep.
+const DefaultDeepReorgDepth = 6
+
+// ReorgStats summarizes the reorganizations of the active chain since the
+// chain was opened.
+type ReorgStats struct {
+	Reorgs         uint64    // Reorgs is the number of reorganizations.
+	DeepReorgs     uint64    // DeepReorgs counts reorganizations at least DeepReorgDepth blocks deep.
+	LastDepth      uint64    // LastDepth is the number of blocks the latest reorganization disconnected.
+	MaxDepth       uint64    // MaxDepth is the deepest reorganization.
+	OrphanedBlocks uint64    // OrphanedBlocks counts blocks disconnected from the active chain.
+	LastReorg      time.Time // LastReorg is when the latest reorganization happened.
+}
+
+// ReorgStats returns the reorganization statistics of the chain.
+func (c *Chain) ReorgStats() ReorgStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.reorgStats
+}
+
+// recordReorgLocked records a reorganization that disconnected depth blocks
+// from the active chain.
+// Note: the caller must hold the chain lock.
+func (c *Chain) recordReorgLocked(depth uint64) {
+	stats := &c.reorgStats
+	stats.Reorgs++
+	stats.LastDepth = depth
+	if depth > stats.MaxDepth {
+		stats.MaxDepth = depth
+	}
+	stats.OrphanedBlocks += depth
+	stats.LastReorg = c.now()
+	if c.config.DeepReorgDepth > 0 && depth >= c.config.DeepReorgDepth {
+		stats.DeepReorgs++
+	}
+}
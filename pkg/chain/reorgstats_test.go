@@ -0,0 +1,71 @@
+package chain
+
+import (
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/consensus"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReorgStats(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	config := DefaultChainConfig()
+	config.DeepReorgDepth = 3
+	chain, err := NewChain(config, consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+	genesisBlock := chain.GetGenesisBlock()
+
+	// Active chain: genesis - a1 - a2 - a3 - a4, side branch: a1 - b2 - b3
+	a1 := createEmptyTestBlock(genesisBlock, 1, 1)
+	require.NoError(t, chain.AddBlock(a1))
+	a2 := createEmptyTestBlock(a1, 2, 1)
+	require.NoError(t, chain.AddBlock(a2))
+	a3 := createEmptyTestBlock(a2, 3, 1)
+	require.NoError(t, chain.AddBlock(a3))
+	a4 := createEmptyTestBlock(a3, 4, 1)
+	require.NoError(t, chain.AddBlock(a4))
+	b2 := createTestBlockWithScript(a1, 2, "BRANCH_B_2")
+	require.NoError(t, chain.AddBlock(b2))
+	b3 := createTestBlockWithScript(b2, 3, "BRANCH_B_3")
+	require.NoError(t, chain.AddBlock(b3))
+	assert.Zero(t, chain.ReorgStats().Reorgs)
+
+	// Switching to b3 disconnects a2, a3 and a4
+	require.NoError(t, chain.InvalidateBlock(a3.CalculateHash()))
+	stats := chain.ReorgStats()
+	assert.Equal(t, uint64(1), stats.Reorgs)
+	assert.Equal(t, uint64(3), stats.LastDepth)
+	assert.Equal(t, uint64(3), stats.MaxDepth)
+	assert.Equal(t, uint64(1), stats.DeepReorgs)
+	assert.Equal(t, uint64(3), stats.OrphanedBlocks)
+	assert.False(t, stats.LastReorg.IsZero())
+
+	// Switching back to a4 disconnects b2 and b3
+	require.NoError(t, chain.ReconsiderBlock(a3.CalculateHash()))
+	stats = chain.ReorgStats()
+	assert.Equal(t, uint64(2), stats.Reorgs)
+	assert.Equal(t, uint64(2), stats.LastDepth)
+	assert.Equal(t, uint64(3), stats.MaxDepth)
+	assert.Equal(t, uint64(1), stats.DeepReorgs)
+	assert.Equal(t, uint64(5), stats.OrphanedBlocks)
+
+	// A branch growing heavier than the active chain takes over on its own
+	b4 := createTestBlockWithScript(b3, 4, "BRANCH_B_4")
+	require.NoError(t, chain.AddBlock(b4))
+	assert.Equal(t, a4.CalculateHash(), chain.GetTipHash(), "a branch of equal work does not switch")
+	b5 := createTestBlockWithScript(b4, 5, "BRANCH_B_5")
+	require.NoError(t, chain.AddBlock(b5))
+	assert.Equal(t, b5.CalculateHash(), chain.GetTipHash())
+	assert.Equal(t, uint64(5), chain.GetHeight())
+	assert.Equal(t, b2.CalculateHash(), chain.GetBlockByHeight(2).CalculateHash())
+	stats = chain.ReorgStats()
+	assert.Equal(t, uint64(3), stats.Reorgs)
+	assert.Equal(t, uint64(3), stats.LastDepth)
+	assert.Equal(t, uint64(2), stats.DeepReorgs)
+	assert.Equal(t, uint64(8), stats.OrphanedBlocks)
+}
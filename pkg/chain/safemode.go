@@ -0,0 +1,71 @@
+package chain
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/storage"
+)
+
+// ErrSafeMode is returned by AddBlock once the chain has entered read-only
+// safe mode after a storage write failed.
+var ErrSafeMode = errors.New("chain is in read-only safe mode")
+
+// SafeModeReason returns the storage failure that put the chain in read-only
+// safe mode, or nil if it accepts blocks.
+func (c *Chain) SafeModeReason() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.safeMode
+}
+
+// InSafeMode reports whether the chain refuses new blocks because a storage
+// write failed, typically because the disk is full.
+func (c *Chain) InSafeMode() bool {
+	return c.SafeModeReason() != nil
+}
+
+// checkSafeModeLocked returns an error wrapping ErrSafeMode if the chain is
+// in safe mode.
+// Note: the caller must hold the chain lock.
+func (c *Chain) checkSafeModeLocked() error {
+	if c.safeMode == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrSafeMode, c.safeMode)
+}
+
+// storeBlockLocked persists a block, entering safe mode if the write fails.
+// Note: the caller must hold the chain lock.
+func (c *Chain) storeBlockLocked(b *block.Block) error {
+	if err := c.storage.StoreBlock(b); err != nil {
+		c.enterSafeModeLocked(err)
+		return fmt.Errorf("failed to store block: %w", err)
+	}
+	return nil
+}
+
+// storeChainStateLocked persists the chain state, entering safe mode if the
+// write fails.
+// Note: the caller must hold the chain lock.
+func (c *Chain) storeChainStateLocked(state *storage.ChainState) error {
+	if err := c.storage.StoreChainState(state); err != nil {
+		c.enterSafeModeLocked(err)
+		return fmt.Errorf("failed to store chain state: %w", err)
+	}
+	c.unflushedBlocks = 0
+	c.lastFlush = c.now()
+	return nil
+}
+
+// enterSafeModeLocked records a storage write failure so that no further
+// blocks are accepted, unless safe mode is disabled in the configuration.
+// Note: the caller must hold the chain lock.
+func (c *Chain) enterSafeModeLocked(err error) {
+	if !c.config.SafeModeOnWriteFailure || c.safeMode != nil {
+		return
+	}
+	fmt.Printf("Storage write failed, entering read-only safe mode: %v\n", err)
+	c.safeMode = err
+}
@@ -0,0 +1,68 @@
+package chain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/consensus"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSafeModeOnWriteFailure(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	mockStorage := &MockFailingStorage{StorageInterface: storageInstance}
+	chain, err := NewChain(DefaultChainConfig(), consensus.DefaultConsensusConfig(), mockStorage)
+	require.NoError(t, err)
+
+	b1 := createEmptyTestBlock(chain.GetGenesisBlock(), 1, 1)
+	require.NoError(t, chain.AddBlock(b1))
+	require.False(t, chain.InSafeMode())
+
+	// The disk fills up while the chain state of b2 is written
+	mockStorage.failOnStoreChainState = true
+	b2 := createEmptyTestBlock(b1, 2, 1)
+	require.Error(t, chain.AddBlock(b2))
+	assert.True(t, chain.InSafeMode())
+	assert.ErrorContains(t, chain.SafeModeReason(), "StoreChainState failed")
+
+	// The in-memory chain matches the chain state on disk
+	assert.Equal(t, b1.CalculateHash(), chain.GetTipHash())
+	assert.Equal(t, uint64(1), chain.GetHeight())
+	assert.Nil(t, chain.UTXOSet.GetUTXO(b2.Transactions[0].Hash, 0))
+	state, err := storageInstance.GetChainState()
+	require.NoError(t, err)
+	assert.Equal(t, b1.CalculateHash(), state.BestBlockHash)
+
+	// Further blocks are refused even once writes succeed again
+	mockStorage.failOnStoreChainState = false
+	err = chain.AddBlock(b2)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrSafeMode))
+	assert.Equal(t, b1.CalculateHash(), chain.GetTipHash())
+}
+
+func TestSafeModeDisabled(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	config := DefaultChainConfig()
+	config.SafeModeOnWriteFailure = false
+	mockStorage := &MockFailingStorage{StorageInterface: storageInstance}
+	chain, err := NewChain(config, consensus.DefaultConsensusConfig(), mockStorage)
+	require.NoError(t, err)
+
+	mockStorage.failOnStoreBlock = true
+	b1 := createEmptyTestBlock(chain.GetGenesisBlock(), 1, 1)
+	require.Error(t, chain.AddBlock(b1))
+	assert.False(t, chain.InSafeMode())
+
+	mockStorage.failOnStoreBlock = false
+	require.NoError(t, chain.AddBlock(b1))
+	assert.Equal(t, b1.CalculateHash(), chain.GetTipHash())
+}
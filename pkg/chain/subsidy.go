@@ -0,0 +1,65 @@
+package chain
+
+const (
+	// DefaultInitialBlockSubsidy is the default subsidy of blocks before the
+	// first halving.
+	DefaultInitialBlockSubsidy = 1000000000
+	// DefaultSubsidyHalvingInterval is the default number of blocks between
+	// halvings of the block subsidy.
+	DefaultSubsidyHalvingInterval = 210000
+)
+
+// HalvingInfo describes where the chain stands in the subsidy schedule.
+type HalvingInfo struct {
+	Height             uint64 `json:"height"`               // Height is the height of the tip.
+	CurrentSubsidy     uint64 `json:"current_subsidy"`      // CurrentSubsidy is the subsidy at the tip's height.
+	NextHalvingHeight  uint64 `json:"next_halving_height"`  // NextHalvingHeight is the first block paying NextSubsidy.
+	BlocksUntilHalving uint64 `json:"blocks_until_halving"` // BlocksUntilHalving is how many blocks must be mined to reach it.
+	NextSubsidy        uint64 `json:"next_subsidy"`         // NextSubsidy is the subsidy after the halving.
+}
+
+// BlockSubsidy returns the newly minted coins a block at height may claim,
+// excluding fees: InitialBlockSubsidy halved every SubsidyHalvingInterval
+// blocks.
+func (c *Chain) BlockSubsidy(height uint64) uint64 {
+	return blockSubsidy(c.config, height)
+}
+
+// GetNextHalvingInfo returns the current block subsidy, when it next
+// halves and what it halves to. Without halvings the next halving height
+// and the countdown are zero.
+func (c *Chain) GetNextHalvingInfo() *HalvingInfo {
+	c.mu.RLock()
+	height := c.height
+	c.mu.RUnlock()
+
+	info := &HalvingInfo{
+		Height:         height,
+		CurrentSubsidy: c.BlockSubsidy(height),
+	}
+	interval := c.config.SubsidyHalvingInterval
+	if interval == 0 {
+		info.NextSubsidy = info.CurrentSubsidy
+		return info
+	}
+	info.NextHalvingHeight = (height/interval + 1) * interval
+	info.BlocksUntilHalving = info.NextHalvingHeight - height
+	info.NextSubsidy = c.BlockSubsidy(info.NextHalvingHeight)
+	return info
+}
+
+// blockSubsidy computes the subsidy schedule of config.
+func blockSubsidy(config *ChainConfig, height uint64) uint64 {
+	subsidy := config.InitialBlockSubsidy
+	if subsidy == 0 {
+		subsidy = DefaultInitialBlockSubsidy
+	}
+	if config.SubsidyHalvingInterval == 0 {
+		return subsidy
+	}
+	halvings := height / config.SubsidyHalvingInterval
+	if halvings >= 64 {
+		return 0
+	}
+	return subsidy >> halvings
+}
@@ -0,0 +1,140 @@
+package chain
+
+import (
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/utxo"
+)
+
+// opReturn is the script opcode marking an output as provably unspendable.
+const opReturn = block.OpReturn
+
+// Supply summarizes the coins issued on the active chain.
+type Supply struct {
+	// Issued is the sum of the block rewards minted by coinbase transactions,
+	// excluding the fees they collect. It equals the value of the UTXO set
+	// plus the value locked in unspendable outputs, which never enter it.
+	Issued uint64 `json:"issued"`
+	// Unspendable is the value locked in provably unspendable outputs.
+	Unspendable uint64 `json:"unspendable"`
+	// Immature is the value of rewards minted by blocks that have not yet
+	// reached coinbase maturity.
+	Immature uint64 `json:"immature"`
+	// Circulating is the issued supply less unspendable outputs and
+	// immature rewards.
+	Circulating uint64 `json:"circulating"`
+}
+
+// IsUnspendableScript reports whether an output script can provably never
+// be spent, so the value it locks is permanently out of circulation.
+func IsUnspendableScript(script []byte) bool {
+	return block.IsUnspendableScript(script)
+}
+
+// GetTotalSupply returns the supply issued on the active chain.
+func (c *Chain) GetTotalSupply() *Supply {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	supply := &Supply{Issued: c.issued, Unspendable: c.unspendable}
+	for height, reward := range c.rewards {
+		if height+c.coinbaseMaturity() > c.height {
+			supply.Immature += reward
+		}
+	}
+
+	locked := supply.Unspendable + supply.Immature
+	if locked < supply.Issued {
+		supply.Circulating = supply.Issued - locked
+	}
+	return supply
+}
+
+// coinbaseMaturity returns the configured coinbase maturity.
+func (c *Chain) coinbaseMaturity() uint64 {
+	if c.config.CoinbaseMaturity > 0 {
+		return c.config.CoinbaseMaturity
+	}
+	return utxo.DefaultCoinbaseMaturity
+}
+
+// recordSupplyLocked accounts for the reward minted and the value burned by a
+// block connected to the active chain. The reward is the coinbase value less
+// the fees of the block's other transactions, whose spent outputs are looked
+// up in the transaction index. When some are no longer indexed the fees are
+// unknown, and the reward is taken to be the coinbase value up to the block
+// subsidy.
+// Note: the caller must hold the chain lock.
+func (c *Chain) recordSupplyLocked(b *block.Block) {
+	var minted, fees uint64
+	feesKnown := true
+	for _, tx := range b.Transactions {
+		if tx == nil {
+			continue
+		}
+
+		var in, out uint64
+		for _, output := range tx.Outputs {
+			out += output.Value
+			if IsUnspendableScript(output.ScriptPubKey) {
+				c.unspendable += output.Value
+			}
+		}
+		if len(tx.Inputs) == 0 {
+			minted += out
+			continue
+		}
+
+		for _, input := range tx.Inputs {
+			prev, exists := c.txIndex[string(input.PrevTxHash)]
+			if !exists {
+				in = 0
+				break
+			}
+			output, ok := prev.output(input.PrevTxIndex)
+			if !ok {
+				in = 0
+				break
+			}
+			in += output.Value
+		}
+		if in >= out {
+			fees += in - out
+		} else {
+			feesKnown = false
+		}
+	}
+
+	reward := uint64(0)
+	if !feesKnown {
+		reward = min(minted, c.BlockSubsidy(b.Header.Height))
+	} else if minted > fees {
+		reward = minted - fees
+	}
+	c.issued += reward
+	c.rewards[b.Header.Height] = reward
+	// Rewards are kept MaxReorgDepth blocks past maturity, for the blocks a
+	// reorg may disconnect and the heights it may return to
+	for height := range c.rewards {
+		if height+c.coinbaseMaturity()+c.config.MaxReorgDepth <= b.Header.Height {
+			delete(c.rewards, height)
+		}
+	}
+}
+
+// unrecordSupplyLocked reverses recordSupplyLocked for a block disconnected
+// from the active chain.
+// Note: the caller must hold the chain lock.
+func (c *Chain) unrecordSupplyLocked(b *block.Block) {
+	for _, tx := range b.Transactions {
+		if tx == nil {
+			continue
+		}
+		for _, output := range tx.Outputs {
+			if IsUnspendableScript(output.ScriptPubKey) {
+				c.unspendable -= output.Value
+			}
+		}
+	}
+	c.issued -= c.rewards[b.Header.Height]
+	delete(c.rewards, b.Header.Height)
+}
@@ -0,0 +1,185 @@
+package chain
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/consensus"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTotalSupply(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	config := DefaultChainConfig()
+	config.CoinbaseMaturity = 10
+	chain, err := NewChain(config, consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+
+	const blockReward = 1000000
+	prev := chain.GetGenesisBlock()
+	for height := uint64(1); height <= 5; height++ {
+		b := createEmptyTestBlock(prev, height, 1)
+		require.NoError(t, chain.AddBlock(b))
+		prev = b
+	}
+
+	// A block burning part of its reward in an unspendable output
+	coinbase := &block.Transaction{
+		Version: 1,
+		Outputs: []*block.TxOutput{
+			{Value: blockReward - 1000, ScriptPubKey: testPayoutScript("COINBASE_TEST_6")},
+			{Value: 1000, ScriptPubKey: []byte{opReturn, 'b', 'u', 'r', 'n'}},
+		},
+	}
+	coinbase.Hash = coinbase.CalculateHash()
+	require.NoError(t, chain.AddBlock(createValidTestBlock(prev, 6, 1, []*block.Transaction{coinbase})))
+
+	supply := chain.GetTotalSupply()
+	expected := config.GenesisBlockReward + 6*blockReward
+	assert.Equal(t, expected, supply.Issued)
+	assert.Equal(t, chain.UTXOSet.GetStats()["total_value"], supply.Issued-supply.Unspendable)
+	assert.Equal(t, uint64(1000), supply.Unspendable)
+
+	// No block has reached coinbase maturity yet
+	assert.Equal(t, expected, supply.Immature)
+	assert.Equal(t, uint64(0), supply.Circulating)
+
+	// The configured maturity later, all of them have
+	chain.mu.Lock()
+	chain.height += config.CoinbaseMaturity
+	chain.mu.Unlock()
+	supply = chain.GetTotalSupply()
+	assert.Equal(t, uint64(0), supply.Immature)
+	assert.Equal(t, expected-1000, supply.Circulating)
+}
+
+func TestSupplyWithUnindexedInputs(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	chain, err := NewChain(DefaultChainConfig(), consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+	subsidy := chain.BlockSubsidy(1)
+
+	// The declared fee of a transaction spending outputs the index no longer
+	// holds is not trusted; the subsidy bounds the reward instead
+	spend := &block.Transaction{
+		Version: 1,
+		Inputs:  []*block.TxInput{{PrevTxHash: []byte("compacted"), PrevTxIndex: 0}},
+		Outputs: []*block.TxOutput{{Value: 1000, ScriptPubKey: []byte("recipient")}},
+		Fee:     subsidy,
+	}
+	spend.Hash = spend.CalculateHash()
+	coinbase := &block.Transaction{
+		Version: 1,
+		Outputs: []*block.TxOutput{{Value: subsidy + 500, ScriptPubKey: testPayoutScript("COINBASE_TEST_1")}},
+	}
+	coinbase.Hash = coinbase.CalculateHash()
+	b := createValidTestBlock(chain.GetGenesisBlock(), 1, 1, []*block.Transaction{coinbase, spend})
+
+	chain.mu.Lock()
+	issued := chain.issued
+	chain.recordSupplyLocked(b)
+	chain.mu.Unlock()
+	assert.Equal(t, issued+subsidy, chain.GetTotalSupply().Issued)
+}
+
+func TestIsUnspendableScript(t *testing.T) {
+	assert.True(t, IsUnspendableScript([]byte{opReturn}))
+	assert.True(t, IsUnspendableScript([]byte{opReturn, 0x01, 0x02}))
+	assert.False(t, IsUnspendableScript(nil))
+	assert.False(t, IsUnspendableScript([]byte("recipient")))
+
+	// A public key hash may start with the opcode and stays spendable
+	pubKeyHash := append([]byte{opReturn}, make([]byte, 19)...)
+	assert.False(t, IsUnspendableScript(pubKeyHash))
+	_, err := block.NewDataOutput(make([]byte, 19))
+	assert.Error(t, err)
+}
+
+func TestDataOutputs(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	chain, err := NewChain(DefaultChainConfig(), consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+
+	newCoinbase := func(data []byte) *block.Transaction {
+		output, err := block.NewDataOutput(data)
+		require.NoError(t, err)
+		coinbase := &block.Transaction{
+			Version: 1,
+			Outputs: []*block.TxOutput{
+				{Value: 1000000, ScriptPubKey: testPayoutScript("COINBASE_DATA")},
+				output,
+			},
+		}
+		coinbase.Hash = coinbase.CalculateHash()
+		return coinbase
+	}
+
+	oversized := newCoinbase(make([]byte, block.MaxDataOutputSize+1))
+	err = chain.AddBlock(createValidTestBlock(chain.GetGenesisBlock(), 1, 1, []*block.Transaction{oversized}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "more than the maximum")
+
+	coinbase := newCoinbase([]byte("anchor"))
+	require.NoError(t, chain.AddBlock(createValidTestBlock(chain.GetGenesisBlock(), 1, 1, []*block.Transaction{coinbase})))
+	assert.NotNil(t, chain.UTXOSet.GetUTXO(coinbase.Hash, 0))
+	assert.Nil(t, chain.UTXOSet.GetUTXO(coinbase.Hash, 1))
+}
+
+func TestGetNextHalvingInfo(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	config := DefaultChainConfig()
+	config.InitialBlockSubsidy = 1000
+	config.SubsidyHalvingInterval = 5
+	chain, err := NewChain(config, consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+
+	// Blocks claim exactly the subsidy, which coinbase validation enforces
+	subsidyBlock := func(prev *block.Block, height uint64) *block.Block {
+		coinbase := &block.Transaction{
+			Version: 1,
+			Outputs: []*block.TxOutput{{Value: chain.BlockSubsidy(height), ScriptPubKey: testPayoutScript(fmt.Sprintf("COINBASE_TEST_%d", height))}},
+		}
+		coinbase.Hash = coinbase.CalculateHash()
+		return createValidTestBlock(prev, height, 1, []*block.Transaction{coinbase})
+	}
+
+	prev := chain.GetGenesisBlock()
+	for height := uint64(1); height <= 4; height++ {
+		b := subsidyBlock(prev, height)
+		require.NoError(t, chain.AddBlock(b))
+		prev = b
+	}
+
+	// One block before the first halving
+	assert.Equal(t, &HalvingInfo{
+		Height:             4,
+		CurrentSubsidy:     1000,
+		NextHalvingHeight:  5,
+		BlocksUntilHalving: 1,
+		NextSubsidy:        500,
+	}, chain.GetNextHalvingInfo())
+
+	require.NoError(t, chain.AddBlock(subsidyBlock(prev, 5)))
+	info := chain.GetNextHalvingInfo()
+	assert.Equal(t, uint64(500), info.CurrentSubsidy)
+	assert.Equal(t, uint64(10), info.NextHalvingHeight)
+	assert.Equal(t, uint64(5), info.BlocksUntilHalving)
+	assert.Equal(t, uint64(250), info.NextSubsidy)
+
+	assert.Equal(t, uint64(0), chain.BlockSubsidy(64*5))
+}
@@ -0,0 +1,323 @@
+package chain
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/consensus"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/palaseus/adrenochain/pkg/utxo"
+)
+
+// Outcomes a test vector block may be expected to have.
+const (
+	VectorAccept = "accept"
+	VectorReject = "reject"
+)
+
+// Mutations a test vector may apply to a block after building it.
+const (
+	// MutateMerkleRoot replaces the merkle root in the header, so that it no
+	// longer commits to the transactions.
+	MutateMerkleRoot = "merkle_root"
+	// MutatePoW picks a nonce whose hash misses the target of the block's
+	// difficulty.
+	MutatePoW = "pow"
+)
+
+// vectorBlockInterval is the time between the timestamps of successive
+// test vector blocks.
+const vectorBlockInterval = 10 * time.Minute
+
+// TestVector is a declarative consensus test: blocks built one on top of the
+// other from the genesis block, each expected to be accepted or rejected.
+// Keys are named, each name standing for a key derived from it, so that
+// vectors can describe signed transactions without carrying signatures.
+type TestVector struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// ChainConfig and ConsensusConfig override fields of DefaultChainConfig
+	// and DefaultConsensusConfig, by their Go field names.
+	ChainConfig     json.RawMessage `json:"chain_config,omitempty"`
+	ConsensusConfig json.RawMessage `json:"consensus_config,omitempty"`
+	// UTXOs are funded in the UTXO set before the blocks are added.
+	UTXOs  []VectorUTXO  `json:"utxos"`
+	Blocks []VectorBlock `json:"blocks"`
+}
+
+// VectorUTXO is an output funded directly in the UTXO set. The hash of its
+// transaction is the SHA-256 of its ID.
+type VectorUTXO struct {
+	ID    string `json:"id"`
+	Value uint64 `json:"value"`
+	Owner string `json:"owner"` // Owner names the key the output pays to.
+}
+
+// VectorBlock describes a block built on top of the last block of the
+// vector expected to be accepted, or on the genesis block.
+type VectorBlock struct {
+	Name         string     `json:"name"`
+	Transactions []VectorTx `json:"transactions"`
+	Mutate       []string   `json:"mutate,omitempty"`
+	Expect       string     `json:"expect"` // Expect is VectorAccept or VectorReject.
+	// Error is a substring the rejection error must contain.
+	Error string `json:"error,omitempty"`
+}
+
+// VectorTx describes a transaction. A transaction without inputs is a
+// coinbase.
+type VectorTx struct {
+	ID      string         `json:"id"` // ID names the transaction for the inputs of later ones.
+	Inputs  []VectorInput  `json:"inputs,omitempty"`
+	Outputs []VectorOutput `json:"outputs"`
+	Fee     uint64         `json:"fee,omitempty"`
+}
+
+// VectorInput spends an output of a funded UTXO or of an earlier
+// transaction of the vector.
+type VectorInput struct {
+	Spend string `json:"spend"` // Spend is the ID of the UTXO or transaction.
+	Index uint32 `json:"index"`
+	// Signer names the key signing the input, by default the owner of the
+	// output spent.
+	Signer string `json:"signer,omitempty"`
+}
+
+// VectorOutput pays a value to the key named by Owner.
+type VectorOutput struct {
+	Value uint64 `json:"value"`
+	Owner string `json:"owner"`
+}
+
+// LoadTestVectors reads the test vectors of the JSON files in dir, in file
+// name order.
+func LoadTestVectors(dir string) ([]*TestVector, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list test vectors: %w", err)
+	}
+	sort.Strings(paths)
+
+	vectors := make([]*TestVector, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read test vector: %w", err)
+		}
+		var v TestVector
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("failed to decode test vector %s: %w", path, err)
+		}
+		if v.Name == "" {
+			v.Name = strings.TrimSuffix(filepath.Base(path), ".json")
+		}
+		vectors = append(vectors, &v)
+	}
+	return vectors, nil
+}
+
+// RunTestVector adds the blocks of a test vector to a new chain stored in s
+// and returns an error describing every block whose outcome differs from
+// the expected one.
+func RunTestVector(v *TestVector, s storage.StorageInterface) error {
+	chainConfig := DefaultChainConfig()
+	if len(v.ChainConfig) > 0 {
+		if err := json.Unmarshal(v.ChainConfig, chainConfig); err != nil {
+			return fmt.Errorf("vector %s: invalid chain config: %w", v.Name, err)
+		}
+	}
+	consensusConfig := consensus.DefaultConsensusConfig()
+	if len(v.ConsensusConfig) > 0 {
+		if err := json.Unmarshal(v.ConsensusConfig, consensusConfig); err != nil {
+			return fmt.Errorf("vector %s: invalid consensus config: %w", v.Name, err)
+		}
+	}
+	c, err := NewChain(chainConfig, consensusConfig, s)
+	if err != nil {
+		return fmt.Errorf("vector %s: failed to create chain: %w", v.Name, err)
+	}
+
+	r := &vectorRunner{
+		chain:   c,
+		outputs: make(map[string][]*block.TxOutput),
+		hashes:  make(map[string][]byte),
+		owners:  make(map[string]string),
+	}
+	for _, funded := range v.UTXOs {
+		hash := sha256.Sum256([]byte(funded.ID))
+		script := r.script(funded.Owner)
+		c.UTXOSet.AddUTXO(&utxo.UTXO{
+			TxHash:       hash[:],
+			Value:        funded.Value,
+			ScriptPubKey: script,
+			Address:      hex.EncodeToString(script),
+		})
+		r.hashes[funded.ID] = hash[:]
+		r.outputs[funded.ID] = []*block.TxOutput{{Value: funded.Value, ScriptPubKey: script}}
+	}
+
+	var errs []error
+	parent := c.GetGenesisBlock()
+	for i, vb := range v.Blocks {
+		name := vb.Name
+		if name == "" {
+			name = fmt.Sprintf("block %d", i)
+		}
+		if vb.Expect != VectorAccept && vb.Expect != VectorReject {
+			return fmt.Errorf("vector %s: %s expects %q, not %q or %q", v.Name, name, vb.Expect, VectorAccept, VectorReject)
+		}
+
+		b, err := r.buildBlock(parent, &vb)
+		if err != nil {
+			return fmt.Errorf("vector %s: failed to build %s: %w", v.Name, name, err)
+		}
+		err = c.AddBlock(b)
+		switch {
+		case vb.Expect == VectorAccept && err != nil:
+			errs = append(errs, fmt.Errorf("%s: expected accept, rejected: %w", name, err))
+		case vb.Expect == VectorReject && err == nil:
+			errs = append(errs, fmt.Errorf("%s: expected reject, accepted", name))
+		case vb.Expect == VectorReject && !strings.Contains(err.Error(), vb.Error):
+			errs = append(errs, fmt.Errorf("%s: expected rejection with %q, got: %w", name, vb.Error, err))
+		}
+		if vb.Expect == VectorAccept {
+			parent = b
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("vector %s: %w", v.Name, err)
+	}
+	return nil
+}
+
+// vectorRunner builds the blocks of a test vector, remembering the
+// transactions built so far for the inputs of later ones.
+type vectorRunner struct {
+	chain   *Chain
+	outputs map[string][]*block.TxOutput // outputs holds the outputs of funded UTXOs and built transactions by ID
+	hashes  map[string][]byte            // hashes holds the transaction hashes by ID
+	owners  map[string]string            // owners maps the output scripts paid so far to the names of their keys
+}
+
+// buildBlock builds and mines a block on top of parent, applying the
+// mutations of vb.
+func (r *vectorRunner) buildBlock(parent *block.Block, vb *VectorBlock) (*block.Block, error) {
+	height := parent.Header.Height + 1
+	txs := make([]*block.Transaction, 0, len(vb.Transactions))
+	for _, vtx := range vb.Transactions {
+		tx, err := r.buildTx(&vtx, height)
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, tx)
+	}
+
+	b := &block.Block{
+		Header: &block.Header{
+			Version:       1,
+			PrevBlockHash: parent.CalculateHash(),
+			Timestamp:     parent.Header.Timestamp.Add(vectorBlockInterval),
+			Difficulty:    r.chain.CalculateNextDifficulty(),
+			Height:        height,
+		},
+		Transactions: txs,
+	}
+	b.Header.MerkleRoot = b.CalculateMerkleRoot()
+
+	mutations := make(map[string]bool, len(vb.Mutate))
+	for _, mutation := range vb.Mutate {
+		switch mutation {
+		case MutateMerkleRoot:
+			b.Header.MerkleRoot = bytes.Repeat([]byte{0xff}, len(b.Header.MerkleRoot))
+		case MutatePoW:
+		default:
+			return nil, fmt.Errorf("unknown mutation %q", mutation)
+		}
+		mutations[mutation] = true
+	}
+
+	target := r.chain.GetConsensus().TargetForDifficulty(b.Header.Difficulty)
+	for {
+		meets := bytes.Compare(b.CalculateHash(), target) < 0
+		if meets != mutations[MutatePoW] {
+			return b, nil
+		}
+		b.Header.Nonce++
+	}
+}
+
+// buildTx builds and signs a transaction for a block at height, recording
+// it under its ID.
+func (r *vectorRunner) buildTx(vtx *VectorTx, height uint64) (*block.Transaction, error) {
+	tx := &block.Transaction{Version: 1, Fee: vtx.Fee}
+	signers := make([]string, len(vtx.Inputs))
+	for i, in := range vtx.Inputs {
+		outputs, exists := r.outputs[in.Spend]
+		if !exists {
+			return nil, fmt.Errorf("transaction %s spends unknown %q", vtx.ID, in.Spend)
+		}
+		signers[i] = in.Signer
+		if signers[i] == "" && int(in.Index) < len(outputs) {
+			signers[i] = r.owners[string(outputs[in.Index].ScriptPubKey)]
+		}
+		tx.Inputs = append(tx.Inputs, &block.TxInput{PrevTxHash: r.hashes[in.Spend], PrevTxIndex: in.Index, Sequence: 0xffffffff})
+	}
+	for _, out := range vtx.Outputs {
+		tx.Outputs = append(tx.Outputs, &block.TxOutput{Value: out.Value, ScriptPubKey: r.script(out.Owner)})
+	}
+	if len(tx.Inputs) == 0 {
+		// Coinbases of different blocks paying the same would otherwise
+		// share a hash
+		tx.LockTime = height
+	}
+
+	for i, signer := range signers {
+		key := vectorKey(signer)
+		sigHash, err := utxo.TxSignatureHash(tx, i, utxo.SigHashAll)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign transaction %s: %w", vtx.ID, err)
+		}
+		sigR, sigS, err := ecdsa.Sign(rand.Reader, key.ToECDSA(), sigHash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign transaction %s: %w", vtx.ID, err)
+		}
+		scriptSig := key.PubKey().SerializeUncompressed()
+		scriptSig = append(scriptSig, sigR.FillBytes(make([]byte, 32))...)
+		tx.Inputs[i].ScriptSig = append(scriptSig, sigS.FillBytes(make([]byte, 32))...)
+	}
+	tx.Hash = tx.CalculateHash()
+
+	if vtx.ID != "" {
+		r.hashes[vtx.ID] = tx.Hash
+		r.outputs[vtx.ID] = tx.Outputs
+	}
+	return tx, nil
+}
+
+// script returns the output script paying to the key named owner: the
+// last 20 bytes of the SHA-256 of its uncompressed public key.
+func (r *vectorRunner) script(owner string) []byte {
+	pubKeyHash := sha256.Sum256(vectorKey(owner).PubKey().SerializeUncompressed())
+	r.owners[string(pubKeyHash[12:])] = owner
+	return pubKeyHash[12:]
+}
+
+// vectorKey derives the private key named name.
+func vectorKey(name string) *btcec.PrivateKey {
+	seed := sha256.Sum256([]byte(name))
+	key, _ := btcec.PrivKeyFromBytes(seed[:])
+	return key
+}
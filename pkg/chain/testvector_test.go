@@ -0,0 +1,58 @@
+package chain
+
+import (
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsensusVectors(t *testing.T) {
+	vectors, err := LoadTestVectors("testdata/vectors")
+	require.NoError(t, err)
+	require.NotEmpty(t, vectors)
+
+	for _, v := range vectors {
+		t.Run(v.Name, func(t *testing.T) {
+			s, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+			require.NoError(t, err)
+			defer s.Close()
+
+			assert.NoError(t, RunTestVector(v, s))
+		})
+	}
+}
+
+func TestRunTestVectorReportsMismatches(t *testing.T) {
+	vectors, err := LoadTestVectors("testdata/vectors")
+	require.NoError(t, err)
+
+	// Flipping the expected outcome of every block must be reported
+	for _, v := range vectors {
+		t.Run(v.Name, func(t *testing.T) {
+			s, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+			require.NoError(t, err)
+			defer s.Close()
+
+			flipped := *v
+			flipped.Blocks = append([]VectorBlock(nil), v.Blocks...)
+			for i := range flipped.Blocks {
+				if flipped.Blocks[i].Expect == VectorAccept {
+					flipped.Blocks[i].Expect = VectorReject
+				} else {
+					flipped.Blocks[i].Expect = VectorAccept
+				}
+			}
+			err = RunTestVector(&flipped, s)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "expected")
+		})
+	}
+
+	s, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer s.Close()
+	err = RunTestVector(&TestVector{Name: "bad", Blocks: []VectorBlock{{Expect: "maybe"}}}, s)
+	assert.ErrorContains(t, err, `expects "maybe"`)
+}
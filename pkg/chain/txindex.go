@@ -0,0 +1,224 @@
+package chain
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/utxo"
+)
+
+// TxOut describes an output of a transaction on the active chain and
+// whether it has been spent there.
+type TxOut struct {
+	Output    *block.TxOutput // Output is the transaction output itself.
+	BlockHash []byte          // BlockHash is the block that created the output.
+	Height    uint64          // Height is the height of that block.
+	Spent     bool            // Spent is set once a later transaction spends the output.
+	SpentBy   []byte          // SpentBy is the hash of the spending transaction.
+	// Compacted is set when the transaction was compacted out of the index
+	// once buried beyond TxIndexRetention with every spendable output spent;
+	// Output and SpentBy are then no longer known.
+	Compacted bool
+}
+
+// indexedTx is a transaction of the active chain with the block it was mined in.
+type indexedTx struct {
+	tx        *block.Transaction // tx is nil once the entry is compacted.
+	blockHash []byte
+	height    uint64
+	outputs   int  // outputs is the number of outputs of the transaction.
+	unspent   int  // unspent counts the spendable outputs not yet spent on the active chain.
+	buried    bool // buried is set once the entry is deeper than the retention depth.
+}
+
+// output returns an output of the transaction, if it is not compacted.
+func (e *indexedTx) output(index uint32) (*block.TxOutput, bool) {
+	if e.tx == nil || int(index) >= len(e.tx.Outputs) {
+		return nil, false
+	}
+	return e.tx.Outputs[index], true
+}
+
+// GetTxOut returns an output of a transaction on the active chain together
+// with its spentness, looked up in the transaction index.
+func (c *Chain) GetTxOut(txHash []byte, index uint32) (*TxOut, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, exists := c.txIndex[string(txHash)]
+	if !exists {
+		return nil, fmt.Errorf("transaction %x not found", txHash)
+	}
+	if int(index) >= entry.outputs {
+		return nil, fmt.Errorf("transaction %x has no output %d", txHash, index)
+	}
+
+	out := &TxOut{
+		BlockHash: entry.blockHash,
+		Height:    entry.height,
+	}
+	if entry.tx == nil {
+		out.Spent, out.Compacted = true, true
+		return out, nil
+	}
+	out.Output = entry.tx.Outputs[index]
+	if spender, spent := c.spentBy[spendKey(txHash, index)]; spent {
+		out.Spent = true
+		out.SpentBy = spender
+	}
+	return out, nil
+}
+
+// GetTxBlockHash returns the hash of the block of the active chain that
+// mined a transaction, looked up in the transaction index.
+func (c *Chain) GetTxBlockHash(txHash []byte) ([]byte, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, exists := c.txIndex[string(txHash)]
+	if !exists {
+		return nil, false
+	}
+	return entry.blockHash, true
+}
+
+// indexBlockLocked adds the transactions of a block connected to the active
+// chain to the transaction index and records the outputs they spend, then
+// compacts the entries the block buried beyond the retention depth.
+// Note: the caller must hold the chain lock.
+func (c *Chain) indexBlockLocked(b *block.Block) {
+	blockHash := b.CalculateHash()
+	var drained []*indexedTx
+	for _, tx := range b.Transactions {
+		if tx == nil || len(tx.Hash) == 0 {
+			continue
+		}
+		entry := &indexedTx{tx: tx, blockHash: blockHash, height: b.Header.Height, outputs: len(tx.Outputs)}
+		for _, output := range tx.Outputs {
+			if !IsUnspendableScript(output.ScriptPubKey) {
+				entry.unspent++
+			}
+		}
+		c.txIndex[string(tx.Hash)] = entry
+		if c.config.TxIndexRetention > 0 {
+			c.compactQueue = append(c.compactQueue, entry)
+		}
+		for _, input := range tx.Inputs {
+			if len(input.PrevTxHash) == 0 {
+				continue
+			}
+			c.spentBy[spendKey(input.PrevTxHash, input.PrevTxIndex)] = tx.Hash
+			prev, exists := c.txIndex[string(input.PrevTxHash)]
+			if !exists {
+				continue
+			}
+			if output, ok := prev.output(input.PrevTxIndex); ok && !IsUnspendableScript(output.ScriptPubKey) {
+				prev.unspent--
+				if prev.unspent == 0 && prev.buried {
+					drained = append(drained, prev)
+				}
+			}
+		}
+		if c.config.AddressIndex {
+			c.indexAddressesLocked(tx, blockHash, b.Header.Height)
+		}
+	}
+	c.recordSupplyLocked(b)
+	c.compactTxIndexLocked(b.Header.Height, drained)
+}
+
+// unindexBlockLocked reverses indexBlockLocked for a block disconnected from
+// the tip of the active chain. The entries of the outputs it spends must not
+// have been compacted.
+// Note: the caller must hold the chain lock.
+func (c *Chain) unindexBlockLocked(b *block.Block) {
+	blockHash := b.CalculateHash()
+	c.unrecordSupplyLocked(b)
+	for i := len(b.Transactions) - 1; i >= 0; i-- {
+		tx := b.Transactions[i]
+		if tx == nil || len(tx.Hash) == 0 {
+			continue
+		}
+		if c.config.AddressIndex {
+			c.unindexAddressesLocked(tx, blockHash)
+		}
+		for _, input := range tx.Inputs {
+			if len(input.PrevTxHash) == 0 {
+				continue
+			}
+			key := spendKey(input.PrevTxHash, input.PrevTxIndex)
+			if bytes.Equal(c.spentBy[key], tx.Hash) {
+				delete(c.spentBy, key)
+			}
+			prev, exists := c.txIndex[string(input.PrevTxHash)]
+			if !exists {
+				continue
+			}
+			if output, ok := prev.output(input.PrevTxIndex); ok && !IsUnspendableScript(output.ScriptPubKey) {
+				prev.unspent++
+			}
+		}
+		if entry, exists := c.txIndex[string(tx.Hash)]; exists && bytes.Equal(entry.blockHash, blockHash) {
+			delete(c.txIndex, string(tx.Hash))
+		}
+	}
+
+	// The block's entries are the last queued, being too recent to be buried
+	for n := len(c.compactQueue); n > 0 && bytes.Equal(c.compactQueue[n-1].blockHash, blockHash); n-- {
+		c.compactQueue[n-1] = nil
+		c.compactQueue = c.compactQueue[:n-1]
+	}
+}
+
+// compactTxIndexLocked compacts the transaction index entries buried beyond
+// the retention depth, never less than MaxReorgDepth, below tip whose
+// spendable outputs are all spent, along with drained, the buried entries
+// whose last output was just spent. A compacted entry keeps the block and
+// height of its transaction but drops the transaction and the spent-output
+// entries of its outputs; the address history is kept in full.
+// Note: the caller must hold the chain lock.
+func (c *Chain) compactTxIndexLocked(tip uint64, drained []*indexedTx) {
+	if c.config.TxIndexRetention == 0 {
+		return
+	}
+	depth := max(c.config.TxIndexRetention, c.config.MaxReorgDepth)
+	for len(c.compactQueue) > 0 && c.compactQueue[0].height+depth <= tip {
+		entry := c.compactQueue[0]
+		c.compactQueue[0] = nil
+		c.compactQueue = c.compactQueue[1:]
+		entry.buried = true
+		if entry.unspent <= 0 {
+			drained = append(drained, entry)
+		}
+	}
+
+	for _, entry := range drained {
+		if entry.tx == nil || c.txIndex[string(entry.tx.Hash)] != entry {
+			continue
+		}
+		for i := range entry.tx.Outputs {
+			delete(c.spentBy, spendKey(entry.tx.Hash, uint32(i)))
+		}
+		entry.tx = nil
+	}
+}
+
+// resetTxIndexLocked empties the transaction and address indexes, undo data and supply accounting
+// before the active chain is replayed.
+// Note: the caller must hold the chain lock.
+func (c *Chain) resetTxIndexLocked() {
+	c.txIndex = make(map[string]*indexedTx)
+	c.spentBy = make(map[string][]byte)
+	c.compactQueue = nil
+	c.addrHistory = make(map[string][]*addressTx)
+	c.undo = make(map[string]*utxo.BlockDiff)
+	c.issued = 0
+	c.unspendable = 0
+	c.rewards = make(map[uint64]uint64)
+}
+
+// spendKey identifies an output in the spent-output index.
+func spendKey(txHash []byte, index uint32) string {
+	return fmt.Sprintf("%x:%d", txHash, index)
+}
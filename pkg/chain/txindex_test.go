@@ -0,0 +1,171 @@
+package chain
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/consensus"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTxOut(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	chain, err := NewChain(DefaultChainConfig(), consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+
+	b1 := createEmptyTestBlock(chain.GetGenesisBlock(), 1, 1)
+	require.NoError(t, chain.AddBlock(b1))
+	coinbase := b1.Transactions[0]
+
+	// An output nothing has spent yet
+	out, err := chain.GetTxOut(coinbase.Hash, 0)
+	require.NoError(t, err)
+	assert.False(t, out.Spent)
+	assert.Nil(t, out.SpentBy)
+	assert.Equal(t, coinbase.Outputs[0].Value, out.Output.Value)
+	assert.Equal(t, b1.CalculateHash(), out.BlockHash)
+	assert.Equal(t, uint64(1), out.Height)
+	blockHash, found := chain.GetTxBlockHash(coinbase.Hash)
+	assert.True(t, found)
+	assert.Equal(t, b1.CalculateHash(), blockHash)
+	_, found = chain.GetTxBlockHash([]byte("unknown"))
+	assert.False(t, found)
+
+	// Index a block spending it, as connecting it to the active chain would
+	spend := &block.Transaction{
+		Version: 1,
+		Inputs:  []*block.TxInput{{PrevTxHash: coinbase.Hash, PrevTxIndex: 0}},
+		Outputs: []*block.TxOutput{{Value: 900000, ScriptPubKey: []byte("recipient")}},
+		Fee:     100000,
+	}
+	spend.Hash = spend.CalculateHash()
+	reward := &block.Transaction{
+		Version: 1,
+		Outputs: []*block.TxOutput{{Value: 1000000, ScriptPubKey: testPayoutScript("COINBASE_TEST_2")}},
+	}
+	reward.Hash = reward.CalculateHash()
+	b2 := createValidTestBlock(b1, 2, 1, []*block.Transaction{reward, spend})
+	chain.mu.Lock()
+	chain.indexBlockLocked(b2)
+	chain.mu.Unlock()
+
+	out, err = chain.GetTxOut(coinbase.Hash, 0)
+	require.NoError(t, err)
+	assert.True(t, out.Spent)
+	assert.Equal(t, spend.Hash, out.SpentBy)
+
+	out, err = chain.GetTxOut(spend.Hash, 0)
+	require.NoError(t, err)
+	assert.False(t, out.Spent)
+	assert.Equal(t, b2.CalculateHash(), out.BlockHash)
+
+	_, err = chain.GetTxOut(coinbase.Hash, 1)
+	assert.Error(t, err)
+	_, err = chain.GetTxOut([]byte("unknown"), 0)
+	assert.Error(t, err)
+}
+
+func TestTxIndexCompaction(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	config := DefaultChainConfig()
+	config.MaxReorgDepth = 2
+	config.TxIndexRetention = 2
+	chain, err := NewChain(config, consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+
+	alice, bob := []byte("alice"), []byte("bob")
+	newTx := func(inputs []*block.TxInput, outputs ...*block.TxOutput) *block.Transaction {
+		tx := &block.Transaction{Version: 1, Inputs: inputs, Outputs: outputs}
+		tx.Hash = tx.CalculateHash()
+		return tx
+	}
+	reward := func(height int) *block.Transaction {
+		return newTx(nil, &block.TxOutput{Value: 1000000, ScriptPubKey: testPayoutScript(fmt.Sprintf("miner-%d", height))})
+	}
+	spend := func(prev *block.Transaction, index uint32) *block.TxInput {
+		return &block.TxInput{PrevTxHash: prev.Hash, PrevTxIndex: index}
+	}
+
+	// Block 2 spends the reward of block 1 to bob, with change to alice and
+	// a data output that can never be spent. Block 4 spends both payments,
+	// block 6 the reward of block 2 and block 7 the reward of block 6
+	reward1 := newTx(nil, &block.TxOutput{Value: 1000000, ScriptPubKey: alice})
+	reward2, reward3, reward6 := reward(2), reward(3), reward(6)
+	memo, err := block.NewDataOutput([]byte("memo"))
+	require.NoError(t, err)
+	pay := newTx([]*block.TxInput{spend(reward1, 0)},
+		&block.TxOutput{Value: 600000, ScriptPubKey: bob},
+		&block.TxOutput{Value: 390000, ScriptPubKey: alice},
+		memo)
+	sweep := newTx([]*block.TxInput{spend(pay, 0), spend(pay, 1)}, &block.TxOutput{Value: 980000, ScriptPubKey: alice})
+	late := newTx([]*block.TxInput{spend(reward2, 0)}, &block.TxOutput{Value: 990000, ScriptPubKey: bob})
+	recent := newTx([]*block.TxInput{spend(reward6, 0)}, &block.TxOutput{Value: 990000, ScriptPubKey: bob})
+	txs := [][]*block.Transaction{
+		{reward1},
+		{reward2, pay},
+		{reward3},
+		{reward(4), sweep},
+		{reward(5)},
+		{reward6, late},
+		{reward(7), recent},
+	}
+
+	// Index the blocks as connecting them to the active chain would
+	prev := chain.GetGenesisBlock()
+	chain.mu.Lock()
+	for i, blockTxs := range txs {
+		prev = createValidTestBlock(prev, uint64(i+1), 1, blockTxs)
+		chain.indexBlockLocked(prev)
+	}
+	chain.bestBlock = prev
+	chain.mu.Unlock()
+
+	// Fully spent transactions buried beyond the retention depth keep only
+	// their block, whether they were spent before or after being buried
+	for _, tx := range []*block.Transaction{reward1, pay, reward2} {
+		out, err := chain.GetTxOut(tx.Hash, 0)
+		require.NoError(t, err)
+		assert.True(t, out.Compacted, "%x", tx.Hash)
+		assert.True(t, out.Spent)
+		assert.Nil(t, out.Output)
+		assert.Nil(t, out.SpentBy)
+	}
+	_, err = chain.GetTxOut(pay.Hash, 3)
+	assert.Error(t, err, "a compacted transaction still knows its output count")
+	chain.mu.RLock()
+	assert.NotContains(t, chain.spentBy, spendKey(pay.Hash, 1))
+	chain.mu.RUnlock()
+
+	// Recent transactions, and buried ones with unspent outputs, are kept
+	// in full even when spent
+	for _, tx := range []*block.Transaction{reward3, sweep, reward6, recent} {
+		out, err := chain.GetTxOut(tx.Hash, 0)
+		require.NoError(t, err)
+		assert.False(t, out.Compacted, "%x", tx.Hash)
+		assert.Equal(t, tx.Outputs[0].Value, out.Output.Value)
+	}
+	out, err := chain.GetTxOut(reward6.Hash, 0)
+	require.NoError(t, err)
+	assert.Equal(t, recent.Hash, out.SpentBy)
+
+	// The address history is kept in full
+	history, _, err := chain.GetAddressHistory(hex.EncodeToString(alice), "", 0)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	assert.Equal(t, sweep.Hash, history[0].TxHash)
+	assert.Equal(t, uint64(390000), history[0].Sent)
+	assert.Equal(t, uint64(980000), history[0].Received)
+	assert.Equal(t, pay.Hash, history[1].TxHash)
+	assert.Equal(t, uint64(1000000), history[1].Sent)
+	assert.Equal(t, reward1.Hash, history[2].TxHash)
+}
@@ -0,0 +1,183 @@
+package chain
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/utxo"
+)
+
+// UTXODiffEvent notifies a subscriber that a block was connected to or
+// disconnected from the active chain, with its effect on the UTXO set. A
+// disconnected block's diff is applied in reverse.
+type UTXODiffEvent struct {
+	// Sequence numbers the events of the chain from 1 without gaps, so a
+	// subscriber can tell that events were dropped and it has to resync.
+	// It starts over when the chain is reopened.
+	Sequence     uint64
+	Diff         *utxo.BlockDiff
+	Disconnected bool
+}
+
+// GetBlockUndo returns the UTXO diff of a block of the active chain: the
+// outputs it created and the outputs it spent, with their values and
+// scripts, which is what is needed to disconnect it. It requires
+// ChainConfig.BlockUndo. Undo data is kept in memory for the MaxReorgDepth
+// most recent blocks only, and is lost on restart: blocks connected before
+// the chain was reopened have none.
+func (c *Chain) GetBlockUndo(hash []byte) (*utxo.BlockDiff, error) {
+	if !c.config.BlockUndo {
+		return nil, fmt.Errorf("block undo data is disabled")
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	diff, exists := c.undo[string(hash)]
+	if !exists {
+		return nil, fmt.Errorf("no undo data for block %x", hash)
+	}
+	return diff, nil
+}
+
+// SubscribeUTXODiffs returns a channel receiving the UTXO diff of every
+// block connected to or disconnected from the active chain, in order, and a
+// function ending the subscription. Events are dropped while the channel's
+// buffer is full; a gap in the event sequence numbers tells the subscriber.
+// It requires ChainConfig.BlockUndo.
+func (c *Chain) SubscribeUTXODiffs(buffer int) (<-chan UTXODiffEvent, func()) {
+	events := make(chan UTXODiffEvent, buffer)
+
+	c.diffSubsMu.Lock()
+	if c.diffSubs == nil {
+		c.diffSubs = make(map[chan UTXODiffEvent]struct{})
+	}
+	c.diffSubs[events] = struct{}{}
+	c.diffSubsMu.Unlock()
+
+	return events, func() {
+		c.diffSubsMu.Lock()
+		defer c.diffSubsMu.Unlock()
+		if _, exists := c.diffSubs[events]; exists {
+			delete(c.diffSubs, events)
+			close(events)
+		}
+	}
+}
+
+// publishUTXODiff numbers an event and sends it to every subscriber without
+// blocking.
+func (c *Chain) publishUTXODiff(event UTXODiffEvent) {
+	c.diffSubsMu.Lock()
+	defer c.diffSubsMu.Unlock()
+	c.diffSeq++
+	event.Sequence = c.diffSeq
+	for events := range c.diffSubs {
+		select {
+		case events <- event:
+		default:
+			// The subscriber is not keeping up; drop rather than block the chain
+		}
+	}
+}
+
+// connectUTXOsLocked applies a block of the active chain to the UTXO set
+// and keeps its diff as undo data, dropping the undo data of the blocks more
+// than MaxReorgDepth below it.
+// Note: the caller must hold the chain lock.
+func (c *Chain) connectUTXOsLocked(b *block.Block) (*utxo.BlockDiff, error) {
+	diff, err := c.UTXOSet.ApplyBlock(b)
+	if err != nil {
+		return nil, err
+	}
+	if c.undo != nil {
+		c.undo[string(diff.BlockHash)] = diff
+		if diff.Height > c.config.MaxReorgDepth {
+			c.pruneUndoLocked(diff.Height - c.config.MaxReorgDepth)
+		}
+	}
+	return diff, nil
+}
+
+// canDisconnectLocked reports whether a block of the active chain can be
+// disconnected in place: its undo data and reward are still kept, and none
+// of the outputs it spends was compacted out of the transaction index.
+// Note: the caller must hold the chain lock.
+func (c *Chain) canDisconnectLocked(b *block.Block) bool {
+	if _, exists := c.undo[string(b.CalculateHash())]; !exists {
+		return false
+	}
+	if _, exists := c.rewards[b.Header.Height]; !exists {
+		return false
+	}
+	for _, tx := range b.Transactions {
+		if tx == nil {
+			continue
+		}
+		for _, input := range tx.Inputs {
+			if prev, exists := c.txIndex[string(input.PrevTxHash)]; exists && prev.tx == nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// disconnectBlockLocked disconnects the tip of the active chain, which
+// canDisconnectLocked allows: the UTXO set is reverted with its undo data
+// and its transactions are taken out of the indexes and the supply. It
+// returns the undo data.
+// Note: the caller must hold the chain lock.
+func (c *Chain) disconnectBlockLocked(b *block.Block) (*utxo.BlockDiff, error) {
+	hash := b.CalculateHash()
+	diff, exists := c.undo[string(hash)]
+	if !exists {
+		return nil, fmt.Errorf("no undo data for block %x", hash)
+	}
+	if err := c.UTXOSet.RevertBlock(diff); err != nil {
+		return nil, err
+	}
+	delete(c.undo, string(hash))
+	c.unindexBlockLocked(b)
+	delete(c.blockByHeight, b.Header.Height)
+	delete(c.accumulatedDifficulty, b.Header.Height)
+	return diff, nil
+}
+
+// publishReorgDiffsLocked notifies subscribers of a switch of the active
+// chain: the blocks disconnected, tip first, with the undo data they had on
+// the previous active chain, then the blocks connected, lowest first.
+// Note: the caller must hold the chain lock.
+func (c *Chain) publishReorgDiffsLocked(previousUndo map[string]*utxo.BlockDiff, disconnected map[string]bool, connected []*block.Block) {
+	if !c.config.BlockUndo {
+		return
+	}
+
+	var undone []*utxo.BlockDiff
+	for hash := range disconnected {
+		if diff, exists := previousUndo[hash]; exists {
+			undone = append(undone, diff)
+		}
+	}
+	sort.Slice(undone, func(i, j int) bool { return undone[i].Height > undone[j].Height })
+	for _, diff := range undone {
+		c.publishUTXODiff(UTXODiffEvent{Diff: diff, Disconnected: true})
+	}
+
+	for _, b := range connected {
+		if diff, exists := c.undo[string(b.CalculateHash())]; exists {
+			c.publishUTXODiff(UTXODiffEvent{Diff: diff})
+		}
+	}
+}
+
+// pruneUndoLocked drops the undo data of the blocks up to height.
+// Note: the caller must hold the chain lock.
+func (c *Chain) pruneUndoLocked(height uint64) {
+	for hash, diff := range c.undo {
+		if diff.Height <= height {
+			delete(c.undo, hash)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package chain
+
+import (
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/consensus"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockUndo(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	config := DefaultChainConfig()
+	config.BlockUndo = true
+	chain, err := NewChain(config, consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+	diffs, cancel := chain.SubscribeUTXODiffs(16)
+	defer cancel()
+	sequence := uint64(0)
+	expectEvent := func(b *block.Block, disconnected bool) {
+		t.Helper()
+		event := <-diffs
+		sequence++
+		assert.Equal(t, sequence, event.Sequence)
+		assert.Equal(t, b.CalculateHash(), event.Diff.BlockHash)
+		assert.Equal(t, disconnected, event.Disconnected)
+	}
+
+	a1 := createEmptyTestBlock(chain.GetGenesisBlock(), 1, 1)
+	require.NoError(t, chain.AddBlock(a1))
+	before := chain.UTXOSet.Snapshot()
+	a2 := createEmptyTestBlock(a1, 2, 1)
+	require.NoError(t, chain.AddBlock(a2))
+	b2 := createTestBlockWithScript(a1, 2, "BRANCH_B_2")
+	require.NoError(t, chain.AddBlock(b2))
+
+	undo, err := chain.GetBlockUndo(a2.CalculateHash())
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), undo.Height)
+	require.Len(t, undo.Created, 1)
+	assert.Equal(t, a2.Transactions[0].Hash, undo.Created[0].TxHash)
+	_, err = chain.GetBlockUndo(b2.CalculateHash())
+	assert.Error(t, err, "side branch blocks have no undo data")
+
+	expectEvent(a1, false)
+	expectEvent(a2, false)
+
+	// Switching to the side branch disconnects a2, then connects b2
+	require.NoError(t, chain.InvalidateBlock(a2.CalculateHash()))
+	expectEvent(a2, true)
+	expectEvent(b2, false)
+	assert.Empty(t, diffs)
+	_, err = chain.GetBlockUndo(a2.CalculateHash())
+	assert.Error(t, err)
+
+	// The undo data of the tip exactly reverses its effect on the UTXO set
+	undo, err = chain.GetBlockUndo(b2.CalculateHash())
+	require.NoError(t, err)
+	require.NoError(t, chain.UTXOSet.RevertBlock(undo))
+	assert.ElementsMatch(t, before, chain.UTXOSet.Snapshot())
+
+	config.BlockUndo = false
+	_, err = chain.GetBlockUndo(a1.CalculateHash())
+	assert.ErrorContains(t, err, "disabled")
+}
+
+func TestBlockUndoIsBounded(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	config := DefaultChainConfig()
+	config.BlockUndo = true
+	config.MaxReorgDepth = 3
+	chain, err := NewChain(config, consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+
+	var blocks []*block.Block
+	prev := chain.GetGenesisBlock()
+	for h := uint64(1); h <= 5; h++ {
+		prev = createEmptyTestBlock(prev, h, 1)
+		require.NoError(t, chain.AddBlock(prev))
+		blocks = append(blocks, prev)
+	}
+
+	// Only the undo data of the blocks a reorg may disconnect is kept
+	for _, b := range blocks[:2] {
+		_, err := chain.GetBlockUndo(b.CalculateHash())
+		assert.Error(t, err, "block %d", b.Header.Height)
+	}
+	for _, b := range blocks[2:] {
+		_, err := chain.GetBlockUndo(b.CalculateHash())
+		assert.NoError(t, err, "block %d", b.Header.Height)
+	}
+}
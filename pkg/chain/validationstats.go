@@ -0,0 +1,86 @@
+package chain
+
+import "time"
+
+// ValidationStage names a stage of block validation whose duration is
+// recorded.
+type ValidationStage string
+
+// Block validation stages, in the order AddBlock runs them.
+const (
+	// StageMerkle checks that the header commits to the transactions.
+	StageMerkle ValidationStage = "merkle"
+	// StageConsensus runs the consensus rules: proof of work, timestamp,
+	// difficulty and checkpoints.
+	StageConsensus ValidationStage = "consensus"
+	// StageHeader checks the block's structure, limits, parent, height and
+	// timestamp.
+	StageHeader ValidationStage = "header"
+	// StagePoW checks the hash against the declared difficulty.
+	StagePoW ValidationStage = "pow"
+	// StageTransactions validates the transactions against the UTXO set
+	// and the coinbase against the subsidy and fees.
+	StageTransactions ValidationStage = "transactions"
+)
+
+// ValidationStages lists the block validation stages in the order they
+// run.
+var ValidationStages = []ValidationStage{StageMerkle, StageConsensus, StageHeader, StagePoW, StageTransactions}
+
+// StageTiming summarizes the durations of one validation stage since the
+// chain was opened.
+type StageTiming struct {
+	Samples uint64        // Samples is the number of times the stage ran.
+	Total   time.Duration // Total is the time spent in the stage.
+	Last    time.Duration // Last is the duration of the latest run.
+	Max     time.Duration // Max is the longest run.
+}
+
+// Average returns the mean duration of the stage, zero before it ran.
+func (t StageTiming) Average() time.Duration {
+	if t.Samples == 0 {
+		return 0
+	}
+	return t.Total / time.Duration(t.Samples)
+}
+
+// ValidationStats holds the timing of each block validation stage.
+type ValidationStats map[ValidationStage]StageTiming
+
+// ValidationStats returns the timing of each block validation stage, empty
+// when ValidationTimings is disabled. Stages run by blocks that failed an
+// earlier stage are not counted.
+func (c *Chain) ValidationStats() ValidationStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	stats := make(ValidationStats, len(c.validationStats))
+	for stage, timing := range c.validationStats {
+		stats[stage] = timing
+	}
+	return stats
+}
+
+// timeStageLocked runs a validation stage, recording its duration when
+// ValidationTimings is enabled.
+// Note: the caller must hold the chain lock.
+func (c *Chain) timeStageLocked(stage ValidationStage, run func() error) error {
+	if !c.config.ValidationTimings {
+		return run()
+	}
+
+	start := time.Now()
+	err := run()
+	elapsed := time.Since(start)
+
+	if c.validationStats == nil {
+		c.validationStats = make(ValidationStats, len(ValidationStages))
+	}
+	timing := c.validationStats[stage]
+	timing.Samples++
+	timing.Total += elapsed
+	timing.Last = elapsed
+	timing.Max = max(timing.Max, elapsed)
+	c.validationStats[stage] = timing
+	return err
+}
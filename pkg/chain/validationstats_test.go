@@ -0,0 +1,48 @@
+package chain
+
+import (
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/consensus"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidationStats(t *testing.T) {
+	newChain := func(config *ChainConfig) *Chain {
+		storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+		require.NoError(t, err)
+		t.Cleanup(func() { storageInstance.Close() })
+		chain, err := NewChain(config, consensus.DefaultConsensusConfig(), storageInstance)
+		require.NoError(t, err)
+		return chain
+	}
+
+	chain := newChain(DefaultChainConfig())
+	before := chain.ValidationStats()
+	b := createEmptyTestBlock(chain.GetGenesisBlock(), 1, 1)
+	require.NoError(t, chain.AddBlock(b))
+
+	stats := chain.ValidationStats()
+	for _, stage := range ValidationStages {
+		timing := stats[stage]
+		assert.Equal(t, before[stage].Samples+1, timing.Samples, "stage %s", stage)
+		assert.GreaterOrEqual(t, timing.Total, timing.Last, "stage %s", stage)
+		assert.GreaterOrEqual(t, timing.Max, timing.Last, "stage %s", stage)
+	}
+
+	// A block failing the merkle check runs no later stage
+	mutated := createEmptyTestBlock(b, 2, 1)
+	mutated.Header.MerkleRoot = make([]byte, len(mutated.Header.MerkleRoot))
+	require.Error(t, chain.AddBlock(mutated))
+	after := chain.ValidationStats()
+	assert.Equal(t, stats[StageMerkle].Samples+1, after[StageMerkle].Samples)
+	assert.Equal(t, stats[StageTransactions].Samples, after[StageTransactions].Samples)
+
+	config := DefaultChainConfig()
+	config.ValidationTimings = false
+	untimed := newChain(config)
+	require.NoError(t, untimed.AddBlock(createEmptyTestBlock(untimed.GetGenesisBlock(), 1, 1)))
+	assert.Empty(t, untimed.ValidationStats())
+}
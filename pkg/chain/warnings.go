@@ -0,0 +1,122 @@
+package chain
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+)
+
+// invalidBranch describes a branch of blocks proving their work that fails
+// validation from its first block on.
+type invalidBranch struct {
+	root       []byte   // root is the first invalid block of the branch.
+	rootHeight uint64   // rootHeight is the height of the first invalid block.
+	reason     string   // reason is why the first invalid block was rejected.
+	height     uint64   // height is the height of the branch tip.
+	work       *big.Int // work is the accumulated difficulty of the branch.
+}
+
+// String describes the branch for operators.
+func (b *invalidBranch) String() string {
+	return fmt.Sprintf("found a chain with more work than the active chain, up to height %d, that is invalid from block %x (height %d): %s",
+		b.height, b.root, b.rootHeight, b.reason)
+}
+
+// GetWarnings returns the conditions an operator should look into, empty if
+// there are none: the chain being in read-only safe mode, and a chain with
+// more work than the active chain that fails validation, which means either
+// that most miners follow rules this node does not or that this node has a
+// consensus bug.
+func (c *Chain) GetWarnings() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var warnings []string
+	if c.safeMode != nil {
+		warnings = append(warnings, fmt.Sprintf("chain is in read-only safe mode: %v", c.safeMode))
+	}
+	if c.invalidChain != nil {
+		if tipWork := c.tipWorkLocked(); tipWork != nil && c.invalidChain.work.Cmp(tipWork) > 0 {
+			warnings = append(warnings, c.invalidChain.String())
+		}
+	}
+	return warnings
+}
+
+// tipWorkLocked returns the accumulated difficulty of the active chain, or
+// nil if it cannot be calculated.
+// Note: the caller must hold the chain lock.
+func (c *Chain) tipWorkLocked() *big.Int {
+	if c.bestBlock == nil {
+		return nil
+	}
+	work, err := c.accumulatedDifficultyLocked(c.bestBlock.Header.Height)
+	if err != nil {
+		return nil
+	}
+	return work
+}
+
+// checkInvalidChainLocked is called for a block that was rejected as
+// invalid. If its hash meets the target of its difficulty and its branch
+// connects to known blocks, the branch is recorded so that its descendants
+// add to its work, and a warning is logged if it has more work than the
+// active chain. The node keeps following the active chain either way.
+// Note: the caller must hold the chain lock.
+func (c *Chain) checkInvalidChainLocked(b *block.Block, hash []byte, reason string) {
+	if !c.config.WarnOnInvalidChain || b.Header.Height == 0 {
+		return
+	}
+	if _, invalidated := c.invalidated[string(hash)]; invalidated {
+		return
+	}
+	if _, recorded := c.invalidBlocks.branches[string(hash)]; recorded {
+		return
+	}
+	// Only work the block proves counts: without it the block costs nothing
+	// to make and says nothing about what the rest of the network is mining
+	if bytes.Compare(hash, c.consensus.TargetForDifficulty(b.Header.Difficulty)) >= 0 {
+		return
+	}
+
+	difficulty := new(big.Int).SetUint64(b.Header.Difficulty)
+	var branch *invalidBranch
+	if parentBranch, exists := c.invalidBlocks.branches[string(b.Header.PrevBlockHash)]; exists {
+		branch = &invalidBranch{
+			root:       parentBranch.root,
+			rootHeight: parentBranch.rootHeight,
+			reason:     parentBranch.reason,
+			height:     b.Header.Height,
+			work:       new(big.Int).Add(parentBranch.work, difficulty),
+		}
+	} else {
+		parent, known := c.blocks[string(b.Header.PrevBlockHash)]
+		if !known {
+			return
+		}
+		parentWork := c.chainWorkLocked(parent, make(map[string]*big.Int))
+		if parentWork == nil {
+			return
+		}
+		branch = &invalidBranch{
+			root:       hash,
+			rootHeight: b.Header.Height,
+			reason:     reason,
+			height:     b.Header.Height,
+			work:       new(big.Int).Add(parentWork, difficulty),
+		}
+	}
+	c.invalidBlocks.setBranch(hash, branch)
+
+	tipWork := c.tipWorkLocked()
+	if tipWork == nil || branch.work.Cmp(tipWork) <= 0 {
+		return
+	}
+	if c.invalidChain != nil && branch.work.Cmp(c.invalidChain.work) <= 0 {
+		return
+	}
+	c.invalidChain = branch
+	fmt.Printf("Warning: %v\n", branch)
+}
@@ -0,0 +1,70 @@
+package chain
+
+import (
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/consensus"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarnOnInvalidChain(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	chain, err := NewChain(DefaultChainConfig(), consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+	genesisBlock := chain.GetGenesisBlock()
+
+	b1 := createEmptyTestBlock(genesisBlock, 1, 1)
+	require.NoError(t, chain.AddBlock(b1))
+
+	// A competing branch whose first block has a malformed coinbase
+	invalid := createMalformedTestBlock(genesisBlock, 1)
+	require.Error(t, chain.AddBlock(invalid))
+	assert.Empty(t, chain.GetWarnings(), "the invalid branch has no more work than the active chain yet")
+
+	// Once the invalid branch has more work, the node warns but stays put
+	child := createEmptyTestBlock(invalid, 2, 1)
+	require.Error(t, chain.AddBlock(child))
+	warnings := chain.GetWarnings()
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "more work than the active chain")
+	assert.Contains(t, warnings[0], "height 1")
+	assert.Equal(t, b1.CalculateHash(), chain.GetTipHash())
+	assert.Equal(t, uint64(1), chain.GetHeight())
+
+	// The warning clears once the active chain catches up with it
+	b2 := createEmptyTestBlock(b1, 2, 1)
+	require.NoError(t, chain.AddBlock(b2))
+	assert.Empty(t, chain.GetWarnings())
+
+	// A block without valid proof of work does not count
+	unmined := createEmptyTestBlock(child, 3, 1)
+	unmined.Header.Difficulty = 64
+	require.Error(t, chain.AddBlock(unmined))
+	assert.Empty(t, chain.GetWarnings())
+
+	require.Error(t, chain.AddBlock(createEmptyTestBlock(child, 3, 1)))
+	assert.Len(t, chain.GetWarnings(), 1)
+	assert.Equal(t, b2.CalculateHash(), chain.GetTipHash())
+}
+
+func TestWarnOnInvalidChainDisabled(t *testing.T) {
+	storageInstance, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storageInstance.Close()
+
+	config := DefaultChainConfig()
+	config.WarnOnInvalidChain = false
+	chain, err := NewChain(config, consensus.DefaultConsensusConfig(), storageInstance)
+	require.NoError(t, err)
+	genesisBlock := chain.GetGenesisBlock()
+
+	invalid := createMalformedTestBlock(genesisBlock, 1)
+	require.Error(t, chain.AddBlock(invalid))
+	require.Error(t, chain.AddBlock(createEmptyTestBlock(invalid, 2, 1)))
+	assert.Empty(t, chain.GetWarnings())
+}
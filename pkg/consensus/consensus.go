@@ -2,6 +2,7 @@ package consensus
 
 import (
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"math/big"
 	"sync"
@@ -28,10 +29,15 @@ type Consensus struct {
 	lastAdjustment time.Time        // lastAdjustment records the time of the last difficulty adjustment.
 	blockTimes     []time.Duration  // blockTimes stores the durations of recent blocks for difficulty adjustment.
 	chain          ChainReader      // chain is a reference to the chain, used to query block information.
+	now            func() time.Time // now returns the current time for future timestamp checks; nil uses the local clock.
 
 	// Finality-related fields
 	finalityDepth uint64            // finalityDepth is the number of blocks required for finality
 	checkpoints   map[uint64][]byte // checkpoints stores known good block hashes at specific heights
+
+	// deploymentCache holds deployment states by deployment name and the
+	// hash of the last block before each signal window
+	deploymentCache map[string]map[string]DeploymentState
 }
 
 // ConsensusConfig holds configuration parameters for the consensus mechanism.
@@ -39,10 +45,15 @@ type ConsensusConfig struct {
 	TargetBlockTime              time.Duration // TargetBlockTime is the desired average time between blocks.
 	DifficultyAdjustmentInterval uint64        // DifficultyAdjustmentInterval is the number of blocks after which difficulty is adjusted.
 	MaxDifficulty                uint64        // MaxDifficulty is the maximum allowed difficulty.
-	MinDifficulty                uint64        // MinDifficulty is the minimum allowed difficulty.
+	MinDifficulty                uint64        // MinDifficulty is the minimum allowed difficulty; adjustments never go below it.
+	GenesisDifficulty            uint64        // GenesisDifficulty is the difficulty of the genesis block and the starting difficulty; zero means MinDifficulty.
 	DifficultyAdjustmentFactor   float64       // DifficultyAdjustmentFactor is used to dampen difficulty swings.
 	FinalityDepth                uint64        // FinalityDepth is the number of blocks required for finality
 	CheckpointInterval           uint64        // CheckpointInterval is the height interval for checkpoints
+	SignalWindow                 uint64        // SignalWindow is the number of blocks version bit signals are counted over; zero means DifficultyAdjustmentInterval.
+	SignalThreshold              uint64        // SignalThreshold is the number of signaling blocks in a window that locks a deployment in; zero means 95% of the window.
+	Deployments                  []Deployment  // Deployments are the soft forks activated by version bit signaling.
+	TestnetMinDifficultyAfter    time.Duration // TestnetMinDifficultyAfter lets a block more than this long after its parent use MinDifficulty; zero disables it.
 }
 
 // DefaultConsensusConfig returns the default consensus configuration.
@@ -52,24 +63,140 @@ func DefaultConsensusConfig() *ConsensusConfig {
 		DifficultyAdjustmentInterval: 2016,
 		MaxDifficulty:                256,
 		MinDifficulty:                1,
+		GenesisDifficulty:            1,
 		DifficultyAdjustmentFactor:   4.0,
 		FinalityDepth:                100,   // 100 blocks for finality
 		CheckpointInterval:           10000, // Checkpoint every 10,000 blocks
 	}
 }
 
+// MainnetConsensusConfig returns the consensus configuration of the main
+// network. It is the default configuration: the main network's genesis
+// block, and so its network magic, is the one existing data directories and
+// peers were created with, and must not change.
+func MainnetConsensusConfig() *ConsensusConfig {
+	return DefaultConsensusConfig()
+}
+
+// TestnetConsensusConfig returns the consensus configuration of the test
+// network, with faster blocks and a shorter adjustment window than the main
+// network so that difficulty follows the hash rate of a few miners.
+func TestnetConsensusConfig() *ConsensusConfig {
+	config := DefaultConsensusConfig()
+	config.TargetBlockTime = 5 * time.Second
+	config.DifficultyAdjustmentInterval = 144
+	config.GenesisDifficulty = 8
+	return config
+}
+
+// DevnetConsensusConfig returns the consensus configuration of a local
+// development network: blocks every second with the difficulty pinned at
+// the minimum, so a single CPU miner produces them within seconds however
+// fast they come.
+func DevnetConsensusConfig() *ConsensusConfig {
+	config := DefaultConsensusConfig()
+	config.TargetBlockTime = time.Second
+	config.DifficultyAdjustmentInterval = 10
+	config.GenesisDifficulty = 1
+	config.MaxDifficulty = config.MinDifficulty
+	return config
+}
+
+// NetworkConsensusConfig returns the consensus configuration of the named
+// network, "mainnet", "testnet" or "devnet", and the default configuration
+// for any other name.
+func NetworkConsensusConfig(network string) *ConsensusConfig {
+	switch network {
+	case "mainnet":
+		return MainnetConsensusConfig()
+	case "testnet":
+		return TestnetConsensusConfig()
+	case "devnet":
+		return DevnetConsensusConfig()
+	}
+	return DefaultConsensusConfig()
+}
+
+// Validate checks that the configuration is usable and returns every
+// problem found.
+func (cc *ConsensusConfig) Validate() error {
+	var errs []error
+	if cc.TargetBlockTime <= 0 {
+		errs = append(errs, fmt.Errorf("consensus: target block time must be positive"))
+	}
+	if cc.DifficultyAdjustmentInterval == 0 {
+		errs = append(errs, fmt.Errorf("consensus: difficulty adjustment interval must be positive"))
+	}
+	if cc.MinDifficulty == 0 {
+		errs = append(errs, fmt.Errorf("consensus: min difficulty must be positive"))
+	}
+	if cc.MaxDifficulty < cc.MinDifficulty {
+		errs = append(errs, fmt.Errorf("consensus: max difficulty %d is below min difficulty %d", cc.MaxDifficulty, cc.MinDifficulty))
+	}
+	if cc.GenesisDifficulty > cc.MaxDifficulty {
+		errs = append(errs, fmt.Errorf("consensus: genesis difficulty %d exceeds max difficulty %d", cc.GenesisDifficulty, cc.MaxDifficulty))
+	}
+	if cc.TestnetMinDifficultyAfter < 0 {
+		errs = append(errs, fmt.Errorf("consensus: testnet min difficulty delay %v is negative", cc.TestnetMinDifficultyAfter))
+	}
+	if cc.DifficultyAdjustmentFactor < 1 {
+		errs = append(errs, fmt.Errorf("consensus: difficulty adjustment factor %v must be at least 1", cc.DifficultyAdjustmentFactor))
+	}
+	window := cc.SignalWindow
+	if window == 0 {
+		window = cc.DifficultyAdjustmentInterval
+	}
+	if cc.SignalThreshold > window {
+		errs = append(errs, fmt.Errorf("consensus: signal threshold %d exceeds signal window %d", cc.SignalThreshold, window))
+	}
+	errs = append(errs, validateDeployments(cc.Deployments)...)
+	return errors.Join(errs...)
+}
+
 // NewConsensus creates a new consensus instance.
 // It initializes the consensus mechanism with the given configuration and a reference to the chain.
 func NewConsensus(config *ConsensusConfig, chain ChainReader) *Consensus {
 	return &Consensus{
 		config:         config,
-		difficulty:     config.MinDifficulty,
+		difficulty:     genesisDifficulty(config),
 		lastAdjustment: time.Now(),
 		blockTimes:     make([]time.Duration, 0),
 		chain:          chain,
 		finalityDepth:  config.FinalityDepth,
 		checkpoints:    make(map[uint64][]byte),
+
+		deploymentCache: make(map[string]map[string]DeploymentState),
+	}
+}
+
+// genesisDifficulty returns the configured genesis difficulty, raised to the
+// minimum difficulty floor if it is unset or below it.
+func genesisDifficulty(config *ConsensusConfig) uint64 {
+	if config.GenesisDifficulty < config.MinDifficulty {
+		return config.MinDifficulty
+	}
+	return config.GenesisDifficulty
+}
+
+// GenesisDifficulty returns the difficulty the genesis block must carry.
+func (c *Consensus) GenesisDifficulty() uint64 {
+	return genesisDifficulty(c.config)
+}
+
+// MinimumDifficulty returns the difficulty floor below which adjustments never go.
+func (c *Consensus) MinimumDifficulty() uint64 {
+	return c.config.MinDifficulty
+}
+
+// clampDifficulty bounds difficulty to the configured minimum and maximum.
+func (c *Consensus) clampDifficulty(difficulty uint64) uint64 {
+	if difficulty < c.config.MinDifficulty {
+		return c.config.MinDifficulty
 	}
+	if difficulty > c.config.MaxDifficulty {
+		return c.config.MaxDifficulty
+	}
+	return difficulty
 }
 
 // IsBlockFinal checks if a block at the given height is considered final.
@@ -134,16 +261,31 @@ func (c *Consensus) GetAccumulatedDifficulty(height uint64) (*big.Int, error) {
 	return accumulated, nil
 }
 
-// calculateExpectedDifficulty calculates the expected difficulty for a given block height.
-// This is used during block validation to ensure the block's difficulty matches the network's rules.
+// calculateExpectedDifficulty calculates the expected difficulty for a block
+// at the given height extending the active chain.
 func (c *Consensus) calculateExpectedDifficulty(blockHeight uint64) (uint64, error) {
 	if blockHeight == 0 {
-		return c.config.MinDifficulty, nil // Genesis block always has min difficulty
+		return genesisDifficulty(c.config), nil
+	}
+	return c.expectedDifficultyAfter(c.chain.GetBlockByHeight(blockHeight-1), blockHeight)
+}
+
+// expectedDifficultyAfter calculates the expected difficulty for a block at
+// the given height extending parent, from parent's own ancestry, so that a
+// block on a side branch is held to the difficulty of its branch rather
+// than that of the active chain at the same height.
+func (c *Consensus) expectedDifficultyAfter(parent *block.Block, blockHeight uint64) (uint64, error) {
+	if blockHeight == 0 {
+		return genesisDifficulty(c.config), nil
+	}
+	if parent == nil {
+		return 0, fmt.Errorf("previous block not found for height %d", blockHeight)
 	}
 
 	if blockHeight%c.config.DifficultyAdjustmentInterval != 0 {
-		// If not an adjustment block, difficulty is the same as the previous block
-		prevBlock := c.chain.GetBlockByHeight(blockHeight - 1)
+		// If not an adjustment block, difficulty is the same as the previous
+		// block not mined under the testnet inactivity rule
+		prevBlock := c.lastNormalBlock(parent)
 		if prevBlock == nil {
 			return 0, fmt.Errorf("previous block not found for height %d", blockHeight)
 		}
@@ -151,13 +293,9 @@ func (c *Consensus) calculateExpectedDifficulty(blockHeight uint64) (uint64, err
 	}
 
 	// It's an adjustment block, calculate new difficulty
-	currentBlock := c.chain.GetBlockByHeight(blockHeight - 1)
-	if currentBlock == nil {
-		return 0, fmt.Errorf("current block not found for height %d", blockHeight-1)
-	}
-
+	currentBlock := parent
 	oldBlockHeight := blockHeight - c.config.DifficultyAdjustmentInterval
-	oldBlock := c.chain.GetBlockByHeight(oldBlockHeight)
+	oldBlock := c.ancestorAt(parent, oldBlockHeight)
 	if oldBlock == nil {
 		return 0, fmt.Errorf("old block not found for height %d", oldBlockHeight)
 	}
@@ -177,14 +315,39 @@ func (c *Consensus) calculateExpectedDifficulty(blockHeight uint64) (uint64, err
 	oldDifficulty := oldBlock.Header.Difficulty
 	newDifficulty := uint64(float64(oldDifficulty) * adjustmentFactor)
 
-	if newDifficulty < c.config.MinDifficulty {
-		newDifficulty = c.config.MinDifficulty
+	return c.clampDifficulty(newDifficulty), nil
+}
+
+// ancestorAt returns the ancestor of b at height, or nil if it is unknown.
+func (c *Consensus) ancestorAt(b *block.Block, height uint64) *block.Block {
+	for b != nil && b.Header.Height > height {
+		if c.isActive(b) {
+			return c.chain.GetBlockByHeight(height)
+		}
+		b = c.chain.GetBlock(b.Header.PrevBlockHash)
 	}
-	if newDifficulty > c.config.MaxDifficulty {
-		newDifficulty = c.config.MaxDifficulty
+	if b == nil || b.Header.Height != height {
+		return nil
 	}
+	return b
+}
 
-	return newDifficulty, nil
+// parentOf returns the parent of b, or nil if it is unknown.
+func (c *Consensus) parentOf(b *block.Block) *block.Block {
+	if b.Header.Height == 0 {
+		return nil
+	}
+	if c.isActive(b) {
+		return c.chain.GetBlockByHeight(b.Header.Height - 1)
+	}
+	return c.chain.GetBlock(b.Header.PrevBlockHash)
+}
+
+// isActive reports whether b is the block of the active chain at its
+// height, whose ancestors are then found by height.
+func (c *Consensus) isActive(b *block.Block) bool {
+	active := c.chain.GetBlockByHeight(b.Header.Height)
+	return active != nil && (active == b || c.bytesEqual(active.CalculateHash(), b.CalculateHash()))
 }
 
 // ValidateBlock validates a block according to consensus rules.
@@ -207,26 +370,25 @@ func (c *Consensus) ValidateBlock(block *block.Block, prevBlock *block.Block) er
 
 	// Check timestamp
 	if prevBlock != nil {
-		if block.Header.Timestamp.Before(prevBlock.Header.Timestamp) {
-			return fmt.Errorf("block timestamp %v is before previous block %v",
-				block.Header.Timestamp, prevBlock.Header.Timestamp)
-		}
-
-		// Check if block is too far in the future (2 hours)
-		maxFutureTime := time.Now().Add(2 * time.Hour)
-		if block.Header.Timestamp.After(maxFutureTime) {
-			return fmt.Errorf("block timestamp %v is too far in the future",
-				block.Header.Timestamp)
+		if err := c.CheckTimestamp(block.Header.Timestamp, prevBlock); err != nil {
+			return err
 		}
 	}
 
-	// Check difficulty
-	expectedDifficulty, err := c.calculateExpectedDifficulty(block.Header.Height)
+	// Check difficulty, which may be the minimum on testnets after a long
+	// gap between blocks, against the branch the block extends
+	var expectedDifficulty uint64
+	var err error
+	if prevBlock != nil {
+		expectedDifficulty, err = c.expectedDifficultyAfter(prevBlock, block.Header.Height)
+	} else {
+		expectedDifficulty, err = c.calculateExpectedDifficulty(block.Header.Height)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to calculate expected difficulty: %w", err)
 	}
 
-	if block.Header.Difficulty != expectedDifficulty {
+	if block.Header.Difficulty != expectedDifficulty && !c.isMinDifficultyException(block) {
 		return fmt.Errorf("block difficulty %d does not match expected %d",
 			block.Header.Difficulty, expectedDifficulty)
 	}
@@ -381,7 +543,7 @@ func (c *Consensus) bytesEqual(a, b []byte) bool {
 // It checks if the block's hash is less than or equal to the target derived from the current difficulty.
 func (c *Consensus) ValidateProofOfWork(block *block.Block) bool {
 	hash := block.CalculateHash()
-	target := c.calculateTarget(c.difficulty)
+	target := c.calculateTarget(c.proofOfWorkDifficulty(block))
 
 	return c.hashLessThan(hash, target)
 }
@@ -436,10 +598,21 @@ func (c *Consensus) hashLessThan(hash1, hash2 []byte) bool {
 // MineBlock mines a block by finding a nonce that satisfies the proof-of-work requirement.
 // It continuously increments the nonce and calculates the block hash until the target is met or mining is stopped.
 func (c *Consensus) MineBlock(block *block.Block, stopChan <-chan struct{}) error {
-	target := c.calculateTarget(c.difficulty)
+	return c.MineBlockFrom(block, 0, 1, stopChan)
+}
 
-	// Try different nonces
-	for nonce := uint64(0); nonce < ^uint64(0); nonce++ {
+// MineBlockFrom mines a block like MineBlock, trying nonces from start in
+// steps of stride, which is treated as 1 if zero. Miners working on the same
+// template can split the nonce space by sharing a stride with distinct
+// starts, and tests get a reproducible nonce for a fixed block.
+func (c *Consensus) MineBlockFrom(block *block.Block, start, stride uint64, stopChan <-chan struct{}) error {
+	target := c.calculateTarget(c.proofOfWorkDifficulty(block))
+	if stride == 0 {
+		stride = 1
+	}
+
+	// Try different nonces until the next one would overflow
+	for nonce := start; ; nonce += stride {
 		select {
 		case <-stopChan:
 			return fmt.Errorf("mining stopped")
@@ -457,6 +630,9 @@ func (c *Consensus) MineBlock(block *block.Block, stopChan <-chan struct{}) erro
 		if c.hashLessThan(hash, target) {
 			return nil // Block mined successfully
 		}
+		if nonce > ^uint64(0)-stride {
+			break
+		}
 	}
 
 	return fmt.Errorf("failed to find valid nonce")
@@ -482,6 +658,17 @@ func (c *Consensus) UpdateDifficulty(blockTime time.Duration) {
 	}
 }
 
+// ResetDifficulty sets the current difficulty, bounded to the configured
+// limits, and discards the collected block times. It is used after the chain
+// switches to a different tip.
+func (c *Consensus) ResetDifficulty(difficulty uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.difficulty = c.clampDifficulty(difficulty)
+	c.blockTimes = make([]time.Duration, 0)
+}
+
 // adjustDifficulty adjusts the difficulty based on recent block times.
 // It aims to keep the average block time close to the TargetBlockTime.
 func (c *Consensus) adjustDifficulty() {
@@ -527,20 +714,18 @@ func (c *Consensus) adjustDifficulty() {
 	newDifficulty := uint64(float64(oldDifficulty) * adjustmentFactor)
 
 	// Ensure difficulty is within bounds
-	if newDifficulty < c.config.MinDifficulty {
-		newDifficulty = c.config.MinDifficulty
-	}
-	if newDifficulty > c.config.MaxDifficulty {
-		newDifficulty = c.config.MaxDifficulty
-	}
-
-	c.difficulty = newDifficulty
+	c.difficulty = c.clampDifficulty(newDifficulty)
 	c.lastAdjustment = time.Now()
 
 	fmt.Printf("Difficulty adjusted from %d to %d (actual time: %v, expected time: %v)\n",
 		oldDifficulty, c.difficulty, actualTime, expectedTime)
 }
 
+// GetTargetBlockTime returns the desired average time between blocks.
+func (c *Consensus) GetTargetBlockTime() time.Duration {
+	return c.config.TargetBlockTime
+}
+
 // GetDifficulty returns the current mining difficulty.
 func (c *Consensus) GetDifficulty() uint64 {
 	c.mu.RLock()
@@ -557,6 +742,12 @@ func (c *Consensus) GetTarget() []byte {
 	return c.calculateTarget(c.difficulty)
 }
 
+// TargetForDifficulty returns the target hash a block of the given
+// difficulty must not exceed.
+func (c *Consensus) TargetForDifficulty(difficulty uint64) []byte {
+	return c.calculateTarget(difficulty)
+}
+
 // GetNextDifficulty calculates and returns what the next difficulty would be
 // based on the collected block times, without actually adjusting the current difficulty.
 func (c *Consensus) GetNextDifficulty() uint64 {
@@ -590,14 +781,7 @@ func (c *Consensus) GetNextDifficulty() uint64 {
 		nextDifficulty := uint64(float64(c.difficulty) * adjustmentFactor)
 
 		// Ensure difficulty is within bounds
-		if nextDifficulty < c.config.MinDifficulty {
-			nextDifficulty = c.config.MinDifficulty
-		}
-		if nextDifficulty > c.config.MaxDifficulty {
-			nextDifficulty = c.config.MaxDifficulty
-		}
-
-		return nextDifficulty
+		return c.clampDifficulty(nextDifficulty)
 	}
 
 	return c.difficulty
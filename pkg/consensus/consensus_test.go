@@ -10,11 +10,13 @@ import (
 
 	"github.com/palaseus/adrenochain/pkg/block"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // MockChainReader implements ChainReader for testing
 type MockChainReader struct {
 	blocks map[uint64]*block.Block
+	side   []*block.Block // blocks off the active chain, found only by hash
 	height uint64
 }
 
@@ -27,7 +29,16 @@ func (m *MockChainReader) GetBlockByHeight(height uint64) *block.Block {
 }
 
 func (m *MockChainReader) GetBlock(hash []byte) *block.Block {
-	// Not used in these tests
+	for _, b := range m.blocks {
+		if string(b.CalculateHash()) == string(hash) {
+			return b
+		}
+	}
+	for _, b := range m.side {
+		if string(b.CalculateHash()) == string(hash) {
+			return b
+		}
+	}
 	return nil
 }
 
@@ -2039,3 +2050,130 @@ func TestUltraOptimizedConsensus(t *testing.T) {
 		assert.Greater(t, consensus.Metrics.FastPathLatency, time.Duration(0))
 	})
 }
+
+func TestDifficultyFloorWithFastBlocks(t *testing.T) {
+	config := DefaultConsensusConfig()
+	config.DifficultyAdjustmentInterval = 4
+	config.GenesisDifficulty = 16
+	config.MinDifficulty = 8
+	mockChain := &MockChainReader{
+		blocks: make(map[uint64]*block.Block),
+		height: 0,
+	}
+	consensus := NewConsensus(config, mockChain)
+
+	assert.Equal(t, uint64(16), consensus.GenesisDifficulty())
+	assert.Equal(t, uint64(16), consensus.GetDifficulty())
+	expected, err := consensus.calculateExpectedDifficulty(0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(16), expected)
+
+	// Blocks arriving a millisecond apart push every adjustment downwards
+	start := time.Unix(1700000000, 0)
+	for i := uint64(0); i <= 40; i++ {
+		mockChain.blocks[i] = &block.Block{
+			Header: &block.Header{
+				Height:     i,
+				Difficulty: consensus.GetDifficulty(),
+				Timestamp:  start.Add(time.Duration(i) * time.Millisecond),
+			},
+		}
+		mockChain.height = i
+
+		consensus.UpdateDifficulty(time.Millisecond)
+		assert.GreaterOrEqual(t, consensus.GetDifficulty(), config.MinDifficulty)
+		assert.GreaterOrEqual(t, consensus.GetNextDifficulty(), config.MinDifficulty)
+
+		if (i+1)%config.DifficultyAdjustmentInterval == 0 {
+			next, err := consensus.calculateExpectedDifficulty(i + 1)
+			assert.NoError(t, err)
+			assert.GreaterOrEqual(t, next, config.MinDifficulty)
+		}
+	}
+	assert.Equal(t, config.MinDifficulty, consensus.GetDifficulty())
+}
+
+func TestGenesisDifficultyDefaultsToFloor(t *testing.T) {
+	config := DefaultConsensusConfig()
+	config.GenesisDifficulty = 0
+	config.MinDifficulty = 3
+	consensus := NewConsensus(config, &MockChainReader{height: 0})
+
+	assert.Equal(t, uint64(3), consensus.GenesisDifficulty())
+	assert.Equal(t, uint64(3), consensus.GetDifficulty())
+}
+
+func TestConsensusConfigValidate(t *testing.T) {
+	assert.NoError(t, DefaultConsensusConfig().Validate())
+
+	config := DefaultConsensusConfig()
+	config.TargetBlockTime = 0
+	config.MinDifficulty = 10
+	config.MaxDifficulty = 5
+	config.DifficultyAdjustmentFactor = 0.5
+	err := config.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "target block time must be positive")
+	assert.Contains(t, err.Error(), "max difficulty 5 is below min difficulty 10")
+	assert.Contains(t, err.Error(), "difficulty adjustment factor 0.5 must be at least 1")
+
+	config = DefaultConsensusConfig()
+	config.GenesisDifficulty = config.MaxDifficulty + 1
+	err = config.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "genesis difficulty 257 exceeds max difficulty 256")
+}
+
+func TestNetworkConsensusConfigs(t *testing.T) {
+	mainnet, testnet, devnet := MainnetConsensusConfig(), TestnetConsensusConfig(), DevnetConsensusConfig()
+	for _, config := range []*ConsensusConfig{mainnet, testnet, devnet} {
+		assert.NoError(t, config.Validate())
+	}
+
+	// The main network keeps the default genesis parameters
+	assert.Equal(t, DefaultConsensusConfig(), mainnet)
+	assert.Equal(t, uint64(1), mainnet.GenesisDifficulty)
+
+	// Each test network targets faster blocks and adjusts over a shorter
+	// window than the one before it
+	assert.Greater(t, mainnet.TargetBlockTime, testnet.TargetBlockTime)
+	assert.Greater(t, testnet.TargetBlockTime, devnet.TargetBlockTime)
+	assert.Greater(t, mainnet.DifficultyAdjustmentInterval, testnet.DifficultyAdjustmentInterval)
+	assert.Greater(t, testnet.DifficultyAdjustmentInterval, devnet.DifficultyAdjustmentInterval)
+
+	assert.Equal(t, devnet, NetworkConsensusConfig("devnet"))
+	assert.Equal(t, testnet, NetworkConsensusConfig("testnet"))
+	assert.Equal(t, mainnet, NetworkConsensusConfig("mainnet"))
+	assert.Equal(t, DefaultConsensusConfig(), NetworkConsensusConfig("moonnet"))
+}
+
+func TestMineBlockFromIsDeterministic(t *testing.T) {
+	// A low fixed difficulty, as used for local test networks
+	config := DefaultConsensusConfig()
+	config.GenesisDifficulty = 8
+	consensus := NewConsensus(config, nil)
+
+	// mine mines a fresh copy of the same block from start in steps of stride
+	mine := func(start, stride uint64) *block.Block {
+		b := block.NewBlock(make([]byte, 32), 1, 8)
+		b.Header.Timestamp = time.Unix(1700000000, 0)
+		coinbaseTx := &block.Transaction{
+			Version: 1,
+			Outputs: []*block.TxOutput{{Value: 1000000, ScriptPubKey: []byte("COINBASE_1")}},
+		}
+		coinbaseTx.Hash = coinbaseTx.CalculateHash()
+		b.AddTransaction(coinbaseTx)
+		require.NoError(t, consensus.MineBlockFrom(b, start, stride, make(chan struct{})))
+		return b
+	}
+
+	first := mine(1000, 7)
+	second := mine(1000, 7)
+	assert.Equal(t, first.Header.Nonce, second.Header.Nonce, "the same seed finds the same nonce")
+	assert.GreaterOrEqual(t, first.Header.Nonce, uint64(1000))
+	assert.Zero(t, (first.Header.Nonce-1000)%7, "only nonces of the partition are tried")
+	assert.True(t, consensus.ValidateProofOfWork(first))
+
+	// MineBlock searches the whole nonce space from zero
+	assert.Equal(t, mine(0, 1).Header.Nonce, mine(0, 0).Header.Nonce)
+}
@@ -0,0 +1,57 @@
+package consensus
+
+import (
+	"time"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+)
+
+// AllowsMinDifficulty reports whether a block extending parent with the
+// given timestamp may be mined at the minimum difficulty because more than
+// TestnetMinDifficultyAfter passed since parent. The exception never applies
+// to difficulty adjustment blocks, and only when the rule is configured.
+func (c *Consensus) AllowsMinDifficulty(parent *block.Block, timestamp time.Time) bool {
+	after := c.config.TestnetMinDifficultyAfter
+	if after <= 0 || parent == nil || parent.Header == nil {
+		return false
+	}
+	if (parent.Header.Height+1)%c.config.DifficultyAdjustmentInterval == 0 {
+		return false
+	}
+	return timestamp.Sub(parent.Header.Timestamp) > after
+}
+
+// isMinDifficultyException reports whether b was mined at the minimum
+// difficulty under the testnet inactivity rule, measured from its own
+// parent.
+func (c *Consensus) isMinDifficultyException(b *block.Block) bool {
+	if c.config.TestnetMinDifficultyAfter <= 0 || b.Header.Difficulty != c.config.MinDifficulty {
+		return false
+	}
+	return c.AllowsMinDifficulty(c.parentOf(b), b.Header.Timestamp)
+}
+
+// proofOfWorkDifficulty returns the difficulty the proof of work of b is
+// checked against: the minimum difficulty for blocks mined under the
+// testnet inactivity rule and the current difficulty otherwise.
+func (c *Consensus) proofOfWorkDifficulty(b *block.Block) uint64 {
+	if b.Header != nil && c.isMinDifficultyException(b) {
+		return c.config.MinDifficulty
+	}
+	return c.difficulty
+}
+
+// lastNormalBlock returns the most recent of b and its ancestors that was
+// not mined under the testnet inactivity rule, whose difficulty the next
+// block inherits, so that a minimum-difficulty block does not lower the
+// difficulty of the blocks after it. Adjustment blocks never use the rule,
+// so the walk stops at the last one at the latest.
+func (c *Consensus) lastNormalBlock(b *block.Block) *block.Block {
+	for b != nil {
+		if c.config.TestnetMinDifficultyAfter <= 0 || !c.isMinDifficultyException(b) {
+			return b
+		}
+		b = c.parentOf(b)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package consensus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTestnetMinDifficultyAfterInactivity(t *testing.T) {
+	config := DefaultConsensusConfig()
+	config.GenesisDifficulty = 8
+	config.TestnetMinDifficultyAfter = 20 * time.Minute
+	require.NoError(t, config.Validate())
+	chain := &MockChainReader{blocks: make(map[uint64]*block.Block)}
+	c := NewConsensus(config, chain)
+
+	start := time.Now().Add(-3 * time.Hour).Truncate(time.Second)
+	newBlock := func(height uint64, timestamp time.Time, difficulty uint64) *block.Block {
+		coinbase := &block.Transaction{
+			Version: 1,
+			Outputs: []*block.TxOutput{{Value: height + 1, ScriptPubKey: []byte("miner")}},
+		}
+		coinbase.Hash = coinbase.CalculateHash()
+		b := &block.Block{
+			Header: &block.Header{
+				Version:    1,
+				Timestamp:  timestamp,
+				Difficulty: difficulty,
+				Height:     height,
+			},
+			Transactions: []*block.Transaction{coinbase},
+		}
+		b.Header.PrevBlockHash = make([]byte, 32)
+		if prev := chain.blocks[height-1]; height > 0 && prev != nil {
+			b.Header.PrevBlockHash = prev.CalculateHash()
+		}
+		b.Header.MerkleRoot = b.CalculateMerkleRoot()
+		return b
+	}
+	// mineMinimal finds a nonce meeting the minimum difficulty but not the
+	// current one, so only the inactivity rule can make the block valid
+	mineMinimal := func(b *block.Block) {
+		for nonce := uint64(0); ; nonce++ {
+			b.Header.Nonce = nonce
+			hash := b.CalculateHash()
+			if c.hashLessThan(hash, c.calculateTarget(config.MinDifficulty)) && !c.hashLessThan(hash, c.calculateTarget(8)) {
+				return
+			}
+		}
+	}
+	connect := func(b *block.Block) {
+		chain.blocks[b.Header.Height] = b
+		chain.height = b.Header.Height
+	}
+
+	genesis := newBlock(0, start, 8)
+	require.NoError(t, c.MineBlock(genesis, nil))
+	connect(genesis)
+	first := newBlock(1, start.Add(10*time.Second), 8)
+	require.NoError(t, c.MineBlock(first, nil))
+	require.NoError(t, c.ValidateBlock(first, genesis))
+	connect(first)
+
+	// Shortly after the tip the minimum difficulty is not allowed
+	early := newBlock(2, first.Header.Timestamp.Add(time.Minute), config.MinDifficulty)
+	mineMinimal(early)
+	assert.False(t, c.AllowsMinDifficulty(first, early.Header.Timestamp))
+	assert.False(t, c.ValidateProofOfWork(early))
+	assert.ErrorContains(t, c.ValidateBlock(early, first), "invalid proof of work")
+	require.NoError(t, c.MineBlock(early, nil))
+	assert.ErrorContains(t, c.ValidateBlock(early, first), "does not match expected")
+
+	// After the inactivity window it is, with proof of work to match
+	late := newBlock(2, first.Header.Timestamp.Add(30*time.Minute), config.MinDifficulty)
+	mineMinimal(late)
+	assert.True(t, c.AllowsMinDifficulty(first, late.Header.Timestamp))
+	assert.True(t, c.ValidateProofOfWork(late))
+	require.NoError(t, c.ValidateBlock(late, first))
+	connect(late)
+
+	// The gap is measured from the block's own parent, not from the active
+	// chain's block below it
+	sideParent := newBlock(1, first.Header.Timestamp.Add(25*time.Minute), 8)
+	require.NoError(t, c.MineBlock(sideParent, nil))
+	chain.side = append(chain.side, sideParent)
+	sideLate := newBlock(2, late.Header.Timestamp, config.MinDifficulty)
+	sideLate.Header.PrevBlockHash = sideParent.CalculateHash()
+	mineMinimal(sideLate)
+	assert.False(t, c.AllowsMinDifficulty(sideParent, sideLate.Header.Timestamp))
+	assert.False(t, c.ValidateProofOfWork(sideLate))
+	assert.ErrorContains(t, c.ValidateBlock(sideLate, sideParent), "invalid proof of work")
+
+	// The following block returns to the difficulty before the gap
+	next, err := c.calculateExpectedDifficulty(3)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(8), next)
+	after := newBlock(3, late.Header.Timestamp.Add(10*time.Second), config.MinDifficulty)
+	require.NoError(t, c.MineBlock(after, nil))
+	assert.ErrorContains(t, c.ValidateBlock(after, late), "does not match expected")
+	after = newBlock(3, late.Header.Timestamp.Add(10*time.Second), 8)
+	require.NoError(t, c.MineBlock(after, nil))
+	assert.NoError(t, c.ValidateBlock(after, late))
+
+	// Without the setting long gaps change nothing
+	config.TestnetMinDifficultyAfter = 0
+	assert.False(t, c.AllowsMinDifficulty(first, late.Header.Timestamp))
+	assert.False(t, c.ValidateProofOfWork(late))
+
+	config.TestnetMinDifficultyAfter = -time.Second
+	assert.ErrorContains(t, config.Validate(), "testnet min difficulty delay")
+}
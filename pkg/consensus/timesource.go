@@ -0,0 +1,84 @@
+package consensus
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+)
+
+// MaxFutureBlockTime is how far ahead of the current time a block
+// timestamp may be.
+const MaxFutureBlockTime = 2 * time.Hour
+
+// MedianTimeSpan is the number of blocks the median time past covers: a
+// block's timestamp must be later than the median timestamp of its parent
+// and the blocks before it.
+const MedianTimeSpan = 11
+
+// SetTimeSource sets the function returning the current time used to reject
+// blocks too far in the future, such as a clock adjusted by the median
+// offset of the node's peers. Without it the local clock is used.
+func (c *Consensus) SetTimeSource(now func() time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// currentTime returns the current time of the configured time source.
+func (c *Consensus) currentTime() time.Time {
+	c.mu.RLock()
+	now := c.now
+	c.mu.RUnlock()
+	if now == nil {
+		return time.Now()
+	}
+	return now()
+}
+
+// MedianTimePast returns the median timestamp of prev and the blocks before
+// it, up to MedianTimeSpan blocks, and false without a previous block.
+// Timestamps count in whole seconds, as block hashes commit to them.
+func (c *Consensus) MedianTimePast(prev *block.Block) (time.Time, bool) {
+	if prev == nil {
+		return time.Time{}, false
+	}
+
+	timestamps := make([]int64, 0, MedianTimeSpan)
+	for b := prev; b != nil && len(timestamps) < MedianTimeSpan; {
+		timestamps = append(timestamps, b.Header.Timestamp.Unix())
+		if b.Header.Height == 0 || c.chain == nil {
+			break
+		}
+		b = c.chain.GetBlock(b.Header.PrevBlockHash)
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+	return time.Unix(timestamps[len(timestamps)/2], 0), true
+}
+
+// MinimumTimestamp returns the earliest timestamp a block extending prev may
+// carry, one second after the median time past, or the zero time without a
+// previous block.
+func (c *Consensus) MinimumTimestamp(prev *block.Block) time.Time {
+	mtp, ok := c.MedianTimePast(prev)
+	if !ok {
+		return time.Time{}
+	}
+	return mtp.Add(time.Second)
+}
+
+// CheckTimestamp checks the timestamp of a block extending prev. It must be
+// later than the median time past, which lets a block be earlier than its
+// parent but keeps a minority of miners from dragging the chain's clock
+// backwards, and not more than MaxFutureBlockTime ahead of the current time.
+func (c *Consensus) CheckTimestamp(timestamp time.Time, prev *block.Block) error {
+	if mtp, ok := c.MedianTimePast(prev); ok && timestamp.Unix() <= mtp.Unix() {
+		return fmt.Errorf("block timestamp %v is not after median time past %v", timestamp, mtp)
+	}
+
+	if maxFutureTime := c.currentTime().Add(MaxFutureBlockTime); timestamp.After(maxFutureTime) {
+		return fmt.Errorf("block timestamp %v is too far in the future", timestamp)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package consensus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockTimestampMedianTimePast(t *testing.T) {
+	config := DefaultConsensusConfig()
+	chain := &MockChainReader{blocks: make(map[uint64]*block.Block)}
+	c := NewConsensus(config, chain)
+
+	newBlock := func(height uint64, timestamp time.Time) *block.Block {
+		coinbase := &block.Transaction{
+			Version: 1,
+			Outputs: []*block.TxOutput{{Value: height + 1, ScriptPubKey: []byte("miner")}},
+		}
+		coinbase.Hash = coinbase.CalculateHash()
+		b := &block.Block{
+			Header: &block.Header{
+				Version:       1,
+				PrevBlockHash: make([]byte, 32),
+				Timestamp:     timestamp,
+				Difficulty:    config.GenesisDifficulty,
+				Height:        height,
+			},
+			Transactions: []*block.Transaction{coinbase},
+		}
+		if height > 0 {
+			b.Header.PrevBlockHash = chain.blocks[height-1].CalculateHash()
+		}
+		b.Header.MerkleRoot = b.CalculateMerkleRoot()
+		require.NoError(t, c.MineBlock(b, nil))
+		return b
+	}
+
+	// Blocks may be earlier than their parent as long as they are later
+	// than the median time past, so the last five go backwards down to just
+	// above the sixth block, whose timestamp becomes the median
+	start := time.Now().Add(-3 * time.Hour).Truncate(time.Second)
+	median := start.Add(50 * time.Second)
+	for height := uint64(0); height <= 10; height++ {
+		timestamp := start.Add(time.Duration(height) * 10 * time.Second)
+		if height > 5 {
+			timestamp = start.Add(time.Duration(90-height) * time.Second)
+		}
+		b := newBlock(height, timestamp)
+		if height > 0 {
+			require.NoError(t, c.ValidateBlock(b, chain.blocks[height-1]))
+		}
+		chain.blocks[height] = b
+		chain.height = height
+	}
+	tip := chain.blocks[10]
+
+	mtp, ok := c.MedianTimePast(tip)
+	require.True(t, ok)
+	assert.Equal(t, median, mtp)
+	assert.Equal(t, median.Add(time.Second), c.MinimumTimestamp(tip))
+
+	// A timestamp equal to the median time past is rejected, even with
+	// sub-second precision the block hash does not commit to
+	equal := newBlock(11, median.Add(500*time.Millisecond))
+	assert.ErrorContains(t, c.ValidateBlock(equal, tip), "is not after median time past")
+
+	above := newBlock(11, median.Add(time.Second))
+	assert.NoError(t, c.ValidateBlock(above, tip))
+
+	// Timestamps too far ahead of the current time are rejected
+	c.SetTimeSource(func() time.Time { return median.Add(time.Hour) })
+	ahead := newBlock(11, median.Add(time.Hour+MaxFutureBlockTime+time.Minute))
+	assert.ErrorContains(t, c.ValidateBlock(ahead, tip), "too far in the future")
+	assert.NoError(t, c.ValidateBlock(newBlock(11, median.Add(time.Hour+MaxFutureBlockTime)), tip))
+}
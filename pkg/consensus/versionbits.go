@@ -0,0 +1,237 @@
+package consensus
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// VersionBitsTopBits is the value of the top three version bits of a
+	// block that signals for deployments with the remaining bits.
+	VersionBitsTopBits uint32 = 0x20000000
+	// VersionBitsTopMask selects the top three bits of a block version.
+	VersionBitsTopMask uint32 = 0xe0000000
+	// VersionBitsNumBits is the number of bits available for signaling.
+	VersionBitsNumBits = 29
+
+	// baseBlockVersion is the version of blocks that signal nothing.
+	baseBlockVersion uint32 = 1
+)
+
+// ErrUnknownDeployment is returned when asking for the state of a deployment
+// that is not configured.
+var ErrUnknownDeployment = errors.New("unknown deployment")
+
+// DeploymentState is the activation state of a soft-fork deployment. The
+// state only changes at signal window boundaries.
+type DeploymentState int
+
+const (
+	// DeploymentDefined is the state before the deployment's start height.
+	DeploymentDefined DeploymentState = iota
+	// DeploymentStarted means miners signal for the deployment and signals
+	// are counted per window.
+	DeploymentStarted
+	// DeploymentLockedIn means a window reached the signal threshold; the
+	// deployment activates one window later.
+	DeploymentLockedIn
+	// DeploymentActive means the deployment's rules are enforced.
+	DeploymentActive
+	// DeploymentFailed means the timeout height passed without lock-in.
+	DeploymentFailed
+)
+
+// String returns the name of the state.
+func (s DeploymentState) String() string {
+	switch s {
+	case DeploymentDefined:
+		return "DEFINED"
+	case DeploymentStarted:
+		return "STARTED"
+	case DeploymentLockedIn:
+		return "LOCKED_IN"
+	case DeploymentActive:
+		return "ACTIVE"
+	case DeploymentFailed:
+		return "FAILED"
+	default:
+		return fmt.Sprintf("DeploymentState(%d)", int(s))
+	}
+}
+
+// Deployment describes a soft fork activated by miners signaling with a
+// version bit.
+type Deployment struct {
+	Name          string // Name identifies the deployment.
+	Bit           uint8  // Bit is the version bit miners set to signal, below VersionBitsNumBits.
+	StartHeight   uint64 // StartHeight is the first height at which signals are counted.
+	TimeoutHeight uint64 // TimeoutHeight is the height at which an unlocked deployment fails; zero means never.
+}
+
+// signals reports whether a block version signals for the deployment.
+func (d *Deployment) signals(version uint32) bool {
+	return version&VersionBitsTopMask == VersionBitsTopBits && version&(1<<d.Bit) != 0
+}
+
+// validateDeployments checks the deployments of a configuration.
+func validateDeployments(deployments []Deployment) []error {
+	var errs []error
+	names := make(map[string]bool, len(deployments))
+	bits := make(map[uint8]string, len(deployments))
+	for _, d := range deployments {
+		if d.Name == "" {
+			errs = append(errs, fmt.Errorf("consensus: deployment with bit %d has no name", d.Bit))
+		} else if names[d.Name] {
+			errs = append(errs, fmt.Errorf("consensus: duplicate deployment %q", d.Name))
+		}
+		names[d.Name] = true
+		if d.Bit >= VersionBitsNumBits {
+			errs = append(errs, fmt.Errorf("consensus: deployment %q bit %d must be below %d", d.Name, d.Bit, VersionBitsNumBits))
+		} else if other, used := bits[d.Bit]; used {
+			errs = append(errs, fmt.Errorf("consensus: deployments %q and %q share bit %d", other, d.Name, d.Bit))
+		}
+		bits[d.Bit] = d.Name
+		if d.TimeoutHeight != 0 && d.TimeoutHeight <= d.StartHeight {
+			errs = append(errs, fmt.Errorf("consensus: deployment %q timeout height %d is not after start height %d", d.Name, d.TimeoutHeight, d.StartHeight))
+		}
+	}
+	return errs
+}
+
+// signalWindow returns the number of blocks signals are counted over.
+func (c *Consensus) signalWindow() uint64 {
+	if c.config.SignalWindow > 0 {
+		return c.config.SignalWindow
+	}
+	return c.config.DifficultyAdjustmentInterval
+}
+
+// signalThreshold returns the number of signaling blocks in a window that
+// locks a deployment in.
+func (c *Consensus) signalThreshold() uint64 {
+	if c.config.SignalThreshold > 0 {
+		return c.config.SignalThreshold
+	}
+	return (c.signalWindow()*95 + 99) / 100
+}
+
+// deployment returns the configured deployment with the given name.
+func (c *Consensus) deployment(name string) (*Deployment, error) {
+	for i := range c.config.Deployments {
+		if c.config.Deployments[i].Name == name {
+			return &c.config.Deployments[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownDeployment, name)
+}
+
+// GetDeploymentState returns the state of a deployment for the block at
+// height, which must extend the current chain. The state of a window depends
+// only on the blocks before it, so the result is cached by the hash of the
+// last block of the previous window and stays correct across reorgs.
+func (c *Consensus) GetDeploymentState(name string, height uint64) (DeploymentState, error) {
+	d, err := c.deployment(name)
+	if err != nil {
+		return DeploymentDefined, err
+	}
+
+	window := c.signalWindow()
+	threshold := c.signalThreshold()
+	windowStart := height - height%window
+
+	// Walk back to the most recent window with a known state, then replay
+	// the windows after it
+	var pending []uint64
+	state := DeploymentDefined
+	for start := windowStart; start > 0; start -= window {
+		last := c.chain.GetBlockByHeight(start - 1)
+		if last == nil {
+			return DeploymentDefined, fmt.Errorf("block not found at height %d", start-1)
+		}
+		if cached, ok := c.cachedDeploymentState(name, last.CalculateHash()); ok {
+			state = cached
+			break
+		}
+		pending = append(pending, start)
+	}
+
+	for i := len(pending) - 1; i >= 0; i-- {
+		start := pending[i]
+		switch state {
+		case DeploymentDefined:
+			if d.TimeoutHeight != 0 && start >= d.TimeoutHeight {
+				state = DeploymentFailed
+			} else if start >= d.StartHeight {
+				state = DeploymentStarted
+			}
+		case DeploymentStarted:
+			count := uint64(0)
+			for h := start - window; h < start; h++ {
+				b := c.chain.GetBlockByHeight(h)
+				if b == nil {
+					return DeploymentDefined, fmt.Errorf("block not found at height %d", h)
+				}
+				if d.signals(b.Header.Version) {
+					count++
+				}
+			}
+			if count >= threshold {
+				state = DeploymentLockedIn
+			} else if d.TimeoutHeight != 0 && start >= d.TimeoutHeight {
+				state = DeploymentFailed
+			}
+		case DeploymentLockedIn:
+			state = DeploymentActive
+		}
+
+		last := c.chain.GetBlockByHeight(start - 1)
+		if last == nil {
+			return DeploymentDefined, fmt.Errorf("block not found at height %d", start-1)
+		}
+		c.cacheDeploymentState(name, last.CalculateHash(), state)
+	}
+	return state, nil
+}
+
+// IsDeploymentActive reports whether a deployment's rules apply to the block
+// at height. Unknown deployments are never active.
+func (c *Consensus) IsDeploymentActive(name string, height uint64) bool {
+	state, err := c.GetDeploymentState(name, height)
+	return err == nil && state == DeploymentActive
+}
+
+// ComputeBlockVersion returns the version for a new block at height, setting
+// the bit of every deployment that is started or locked in. Blocks that
+// signal nothing keep the base version.
+func (c *Consensus) ComputeBlockVersion(height uint64) uint32 {
+	var bits uint32
+	for _, d := range c.config.Deployments {
+		state, err := c.GetDeploymentState(d.Name, height)
+		if err != nil {
+			continue
+		}
+		if state == DeploymentStarted || state == DeploymentLockedIn {
+			bits |= 1 << d.Bit
+		}
+	}
+	if bits == 0 {
+		return baseBlockVersion
+	}
+	return VersionBitsTopBits | bits
+}
+
+func (c *Consensus) cachedDeploymentState(name string, hash []byte) (DeploymentState, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	state, ok := c.deploymentCache[name][string(hash)]
+	return state, ok
+}
+
+func (c *Consensus) cacheDeploymentState(name string, hash []byte, state DeploymentState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.deploymentCache[name] == nil {
+		c.deploymentCache[name] = make(map[string]DeploymentState)
+	}
+	c.deploymentCache[name][string(hash)] = state
+}
@@ -0,0 +1,131 @@
+package consensus
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// versionBitsChain returns a consensus instance counting signals over
+// windows of 10 blocks with a threshold of 8, and a function appending a
+// block with the given version to its chain.
+func versionBitsChain(deployments ...Deployment) (*Consensus, *MockChainReader, func(version uint32)) {
+	config := DefaultConsensusConfig()
+	config.SignalWindow = 10
+	config.SignalThreshold = 8
+	config.Deployments = deployments
+	chain := &MockChainReader{blocks: make(map[uint64]*block.Block)}
+	c := NewConsensus(config, chain)
+
+	appendBlock := func(version uint32) {
+		height := uint64(len(chain.blocks))
+		b := &block.Block{Header: &block.Header{Version: version, Height: height, Nonce: height}}
+		if height > 0 {
+			b.Header.PrevBlockHash = chain.blocks[height-1].CalculateHash()
+		}
+		chain.blocks[height] = b
+		chain.height = height
+	}
+	return c, chain, appendBlock
+}
+
+func TestDeploymentActivation(t *testing.T) {
+	c, chain, appendBlock := versionBitsChain(Deployment{Name: "testfork", Bit: 1, StartHeight: 10, TimeoutHeight: 100})
+	signaling := VersionBitsTopBits | 1<<1
+
+	// mine appends a full window of blocks, the first signals of which
+	// signal for the deployment
+	mine := func(signals int) {
+		for i := 0; i < 10; i++ {
+			if i < signals {
+				appendBlock(signaling)
+			} else {
+				appendBlock(baseBlockVersion)
+			}
+		}
+	}
+	stateAt := func(height uint64) DeploymentState {
+		state, err := c.GetDeploymentState("testfork", height)
+		require.NoError(t, err)
+		return state
+	}
+
+	assert.Equal(t, DeploymentDefined, stateAt(0))
+	assert.Equal(t, baseBlockVersion, c.ComputeBlockVersion(0))
+	mine(10) // signals before the start height are ignored
+	assert.Equal(t, DeploymentStarted, stateAt(10))
+	assert.Equal(t, signaling, c.ComputeBlockVersion(10))
+
+	mine(7) // one short of the threshold
+	assert.Equal(t, DeploymentStarted, stateAt(20))
+	assert.Equal(t, DeploymentStarted, stateAt(29), "state only changes at window boundaries")
+
+	mine(8)
+	assert.Equal(t, DeploymentLockedIn, stateAt(30))
+	assert.Equal(t, signaling, c.ComputeBlockVersion(30))
+	assert.False(t, c.IsDeploymentActive("testfork", 39))
+
+	mine(0)
+	assert.Equal(t, DeploymentActive, stateAt(40))
+	assert.True(t, c.IsDeploymentActive("testfork", 40))
+	assert.Equal(t, baseBlockVersion, c.ComputeBlockVersion(40))
+
+	mine(0)
+	assert.Equal(t, DeploymentActive, stateAt(50), "active is final")
+
+	// States are cached by block hash, so a reorg of the locking window is
+	// recounted
+	for h := uint64(20); h < 30; h++ {
+		b := chain.blocks[h]
+		b.Header.Version = baseBlockVersion
+		b.Header.Nonce += 1000
+	}
+	for h := uint64(21); h < 50; h++ {
+		chain.blocks[h].Header.PrevBlockHash = chain.blocks[h-1].CalculateHash()
+	}
+	assert.Equal(t, DeploymentStarted, stateAt(50))
+
+	_, err := c.GetDeploymentState("missing", 10)
+	assert.True(t, errors.Is(err, ErrUnknownDeployment))
+	assert.False(t, c.IsDeploymentActive("missing", 10))
+}
+
+func TestDeploymentTimeout(t *testing.T) {
+	c, _, appendBlock := versionBitsChain(Deployment{Name: "testfork", Bit: 3, StartHeight: 10, TimeoutHeight: 30})
+	for i := 0; i < 30; i++ {
+		appendBlock(c.ComputeBlockVersion(uint64(i)) &^ (1 << 3)) // miners refuse to signal
+	}
+
+	state, err := c.GetDeploymentState("testfork", 30)
+	require.NoError(t, err)
+	assert.Equal(t, DeploymentFailed, state)
+	assert.Equal(t, baseBlockVersion, c.ComputeBlockVersion(30))
+}
+
+func TestDeploymentConfigValidation(t *testing.T) {
+	config := DefaultConsensusConfig()
+	config.SignalWindow = 10
+	config.SignalThreshold = 11
+	config.Deployments = []Deployment{
+		{Name: "a", Bit: 1},
+		{Name: "a", Bit: 2},
+		{Name: "b", Bit: 1},
+		{Name: "c", Bit: VersionBitsNumBits},
+		{Name: "d", Bit: 4, StartHeight: 20, TimeoutHeight: 10},
+	}
+
+	err := config.Validate()
+	require.Error(t, err)
+	for _, want := range []string{
+		"signal threshold 11 exceeds signal window 10",
+		`duplicate deployment "a"`,
+		"share bit 1",
+		"bit 29 must be below 29",
+		"timeout height 10 is not after start height 20",
+	} {
+		assert.ErrorContains(t, err, want)
+	}
+}
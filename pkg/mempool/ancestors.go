@@ -0,0 +1,341 @@
+package mempool
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+)
+
+// MempoolEntryInfo is a snapshot of a mempool entry together with the
+// aggregate statistics of its in-mempool ancestors and descendants.
+// Ancestor and descendant totals include the transaction itself.
+type MempoolEntryInfo struct {
+	Hash              string    `json:"hash"`
+	Size              uint64    `json:"size"`
+	Fee               uint64    `json:"fee"`
+	FeeRate           uint64    `json:"fee_rate"`
+	Time              time.Time `json:"time"`
+	AncestorCount     int       `json:"ancestor_count"`
+	AncestorSize      uint64    `json:"ancestor_size"`
+	AncestorFees      uint64    `json:"ancestor_fees"`
+	AncestorFeeRate   uint64    `json:"ancestor_fee_rate"`
+	DescendantCount   int       `json:"descendant_count"`
+	DescendantSize    uint64    `json:"descendant_size"`
+	DescendantFees    uint64    `json:"descendant_fees"`
+	DescendantFeeRate uint64    `json:"descendant_fee_rate"`
+	Depends           []string  `json:"depends"`
+}
+
+// AncestorFeeRate returns the fee rate of the entry together with all of its
+// unconfirmed ancestors. This is the rate a miner effectively earns when the
+// entry is included, since its ancestors have to be included as well.
+func (e *TransactionEntry) AncestorFeeRate() uint64 {
+	if e.AncestorSize == 0 {
+		return 0
+	}
+	return e.AncestorFees / e.AncestorSize
+}
+
+// DescendantFeeRate returns the fee rate of the entry together with all of
+// its in-mempool descendants. A child paying a high fee raises the
+// descendant fee rate of its parents (child-pays-for-parent).
+func (e *TransactionEntry) DescendantFeeRate() uint64 {
+	if e.DescendantSize == 0 {
+		return 0
+	}
+	return e.DescendantFees / e.DescendantSize
+}
+
+// GetRawMempoolVerbose returns a snapshot of every mempool entry keyed by
+// the hex encoded transaction hash.
+func (mp *Mempool) GetRawMempoolVerbose() map[string]*MempoolEntryInfo {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	result := make(map[string]*MempoolEntryInfo, len(mp.transactions))
+	for _, entry := range mp.transactions {
+		info := &MempoolEntryInfo{
+			Hash:              hex.EncodeToString(entry.Transaction.Hash),
+			Size:              entry.Size,
+			Fee:               entry.Transaction.Fee,
+			FeeRate:           entry.FeeRate,
+			Time:              entry.Timestamp,
+			AncestorCount:     entry.AncestorCount,
+			AncestorSize:      entry.AncestorSize,
+			AncestorFees:      entry.AncestorFees,
+			AncestorFeeRate:   entry.AncestorFeeRate(),
+			DescendantCount:   entry.DescendantCount,
+			DescendantSize:    entry.DescendantSize,
+			DescendantFees:    entry.DescendantFees,
+			DescendantFeeRate: entry.DescendantFeeRate(),
+			Depends:           make([]string, 0, len(entry.parents)),
+		}
+		for parent := range entry.parents {
+			info.Depends = append(info.Depends, hex.EncodeToString([]byte(parent)))
+		}
+		sort.Strings(info.Depends)
+		result[info.Hash] = info
+	}
+
+	return result
+}
+
+// linkEntry connects a newly added entry to its in-mempool parents and
+// updates the ancestor statistics of the entry and the descendant
+// statistics of every ancestor.
+// Note: the caller must hold the mempool lock.
+func (mp *Mempool) linkEntry(entry *TransactionEntry) {
+	txHash := string(entry.Transaction.Hash)
+	fee := entry.Transaction.Fee
+
+	entry.parents = make(map[string]struct{})
+	entry.children = make(map[string]struct{})
+	entry.AncestorCount, entry.AncestorSize, entry.AncestorFees = 1, entry.Size, fee
+	entry.DescendantCount, entry.DescendantSize, entry.DescendantFees = 1, entry.Size, fee
+
+	for _, input := range entry.Transaction.Inputs {
+		parentHash := string(input.PrevTxHash)
+		parent, exists := mp.transactions[parentHash]
+		if !exists || parentHash == txHash {
+			continue
+		}
+		entry.parents[parentHash] = struct{}{}
+		parent.children[txHash] = struct{}{}
+	}
+
+	for _, ancestor := range mp.collectRelatives(entry, true) {
+		entry.AncestorCount++
+		entry.AncestorSize += ancestor.Size
+		entry.AncestorFees += ancestor.Transaction.Fee
+
+		ancestor.DescendantCount++
+		ancestor.DescendantSize += entry.Size
+		ancestor.DescendantFees += fee
+	}
+}
+
+// unlinkEntry detaches an entry that is leaving the mempool from its
+// relatives. Removing an entry can cut a descendant off from ancestors it
+// was only reachable through, so the aggregates of every former relative
+// are recomputed rather than adjusted by the entry's own contribution.
+// Note: the caller must hold the mempool lock.
+func (mp *Mempool) unlinkEntry(entry *TransactionEntry) {
+	affected := append(mp.collectRelatives(entry, true), mp.collectRelatives(entry, false)...)
+
+	txHash := string(entry.Transaction.Hash)
+	for parentHash := range entry.parents {
+		if parent, exists := mp.transactions[parentHash]; exists {
+			delete(parent.children, txHash)
+		}
+	}
+	for childHash := range entry.children {
+		if child, exists := mp.transactions[childHash]; exists {
+			delete(child.parents, txHash)
+		}
+	}
+	entry.parents = nil
+	entry.children = nil
+
+	for _, relative := range affected {
+		mp.refreshRelativeStats(relative)
+	}
+}
+
+// refreshRelativeStats recomputes the ancestor and descendant aggregates of
+// an entry from its current links.
+func (mp *Mempool) refreshRelativeStats(entry *TransactionEntry) {
+	fee := entry.Transaction.Fee
+	entry.AncestorCount, entry.AncestorSize, entry.AncestorFees = 1, entry.Size, fee
+	entry.DescendantCount, entry.DescendantSize, entry.DescendantFees = 1, entry.Size, fee
+
+	for _, ancestor := range mp.collectRelatives(entry, true) {
+		entry.AncestorCount++
+		entry.AncestorSize += ancestor.Size
+		entry.AncestorFees += ancestor.Transaction.Fee
+	}
+	for _, descendant := range mp.collectRelatives(entry, false) {
+		entry.DescendantCount++
+		entry.DescendantSize += descendant.Size
+		entry.DescendantFees += descendant.Transaction.Fee
+	}
+}
+
+// collectRelatives walks the parent (ancestors) or child (descendants)
+// links of an entry and returns every distinct relative, excluding the
+// entry itself.
+func (mp *Mempool) collectRelatives(entry *TransactionEntry, ancestors bool) []*TransactionEntry {
+	var relatives []*TransactionEntry
+	visited := map[string]bool{string(entry.Transaction.Hash): true}
+	queue := []*TransactionEntry{entry}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		links := current.children
+		if ancestors {
+			links = current.parents
+		}
+		for hash := range links {
+			if visited[hash] {
+				continue
+			}
+			visited[hash] = true
+			if relative, exists := mp.transactions[hash]; exists {
+				relatives = append(relatives, relative)
+				queue = append(queue, relative)
+			}
+		}
+	}
+
+	return relatives
+}
+
+// checkRelativeLimits rejects a transaction that would have more than
+// maxAncestors unconfirmed ancestors, or would take one of them past
+// maxDescendants descendants, both counting the transaction itself.
+// Note: the caller must hold the mempool lock.
+func (mp *Mempool) checkRelativeLimits(tx *block.Transaction) error {
+	ancestors := mp.inMempoolAncestors(tx)
+	if count := len(ancestors) + 1; count > mp.maxAncestors {
+		return fmt.Errorf("%w: transaction would have %d unconfirmed ancestors (max: %d)", ErrChainLimit, count, mp.maxAncestors)
+	}
+	for hash := range ancestors {
+		ancestor := mp.transactions[hash]
+		if count := ancestor.DescendantCount + 1; count > mp.maxDescendants {
+			return fmt.Errorf("%w: ancestor %x would have %d descendants (max: %d)", ErrChainLimit, ancestor.Transaction.Hash, count, mp.maxDescendants)
+		}
+	}
+	return nil
+}
+
+// GetAncestors returns the in-mempool ancestors of a mempool transaction,
+// the transactions a block must include before it, parents before
+// children. It returns nil if the transaction is not in the mempool.
+func (mp *Mempool) GetAncestors(txHash []byte) []*block.Transaction {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	entry, exists := mp.transactions[string(txHash)]
+	if !exists {
+		return nil
+	}
+	return parentsFirst(mp.collectRelatives(entry, true))
+}
+
+// GetDescendants returns the in-mempool descendants of a mempool
+// transaction, the transactions spending its outputs directly or through
+// other mempool transactions, parents before children. It returns nil if
+// the transaction is not in the mempool.
+func (mp *Mempool) GetDescendants(txHash []byte) []*block.Transaction {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	entry, exists := mp.transactions[string(txHash)]
+	if !exists {
+		return nil
+	}
+	return parentsFirst(mp.collectRelatives(entry, false))
+}
+
+// parentsFirst sorts related entries so that every transaction comes after
+// the ones it spends from and returns their transactions. An ancestor has
+// fewer ancestors than each of its descendants, so ordering by ancestor
+// count puts parents before children.
+func parentsFirst(entries []*TransactionEntry) []*block.Transaction {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].AncestorCount != entries[j].AncestorCount {
+			return entries[i].AncestorCount < entries[j].AncestorCount
+		}
+		return string(entries[i].Transaction.Hash) < string(entries[j].Transaction.Hash)
+	})
+	txs := make([]*block.Transaction, 0, len(entries))
+	for _, entry := range entries {
+		txs = append(txs, entry.Transaction)
+	}
+	return txs
+}
+
+// chainDepth returns the length of the longest chain of unconfirmed
+// ancestors the transaction would have if it entered the mempool. A
+// transaction spending only confirmed outputs has depth zero.
+// Note: the caller must hold the mempool lock.
+func (mp *Mempool) chainDepth(tx *block.Transaction) int {
+	depths := make(map[string]int)
+	depth := 0
+	for _, input := range tx.Inputs {
+		if parent, exists := mp.transactions[string(input.PrevTxHash)]; exists {
+			if d := mp.entryDepth(parent, depths) + 1; d > depth {
+				depth = d
+			}
+		}
+	}
+	return depth
+}
+
+// entryDepth returns the number of unconfirmed generations above an entry,
+// memoising intermediate results in depths.
+func (mp *Mempool) entryDepth(entry *TransactionEntry, depths map[string]int) int {
+	txHash := string(entry.Transaction.Hash)
+	if d, ok := depths[txHash]; ok {
+		return d
+	}
+	depth := 0
+	for parentHash := range entry.parents {
+		if parent, exists := mp.transactions[parentHash]; exists {
+			if d := mp.entryDepth(parent, depths) + 1; d > depth {
+				depth = d
+			}
+		}
+	}
+	depths[txHash] = depth
+	return depth
+}
+
+// TxPackage is a mempool transaction together with its in-mempool
+// ancestors, which a block must include before it.
+type TxPackage struct {
+	// Transactions holds the ancestors, parents before children, followed
+	// by the transaction itself.
+	Transactions []*block.Transaction
+	// Size and Fees are the totals of the package, as AncestorSize and
+	// AncestorFees of the transaction's entry.
+	Size uint64
+	Fees uint64
+}
+
+// GetPackagesForBlock returns the package of every transaction selected by
+// fee rate, in the order GetTransactionsForBlock returns them. Packages
+// overlap when transactions share ancestors.
+func (mp *Mempool) GetPackagesForBlock() []*TxPackage {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	entries := make([]*TransactionEntry, 0, len(mp.transactions))
+	for _, entry := range mp.transactions {
+		// Free transactions are selected separately by priority
+		if !entry.free {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].FeeRate != entries[j].FeeRate {
+			return entries[i].FeeRate > entries[j].FeeRate
+		}
+		return entries[i].Timestamp.Before(entries[j].Timestamp)
+	})
+
+	packages := make([]*TxPackage, 0, len(entries))
+	for _, entry := range entries {
+		pkg := &TxPackage{
+			Transactions: append(parentsFirst(mp.collectRelatives(entry, true)), entry.Transaction),
+			Size:         entry.AncestorSize,
+			Fees:         entry.AncestorFees,
+		}
+		packages = append(packages, pkg)
+	}
+	return packages
+}
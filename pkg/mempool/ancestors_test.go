@@ -0,0 +1,224 @@
+package mempool
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/crypto_utils"
+	"github.com/palaseus/adrenochain/pkg/utxo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createChildTransaction builds a transaction spending output 0 of parent.
+func createChildTransaction(hash string, fee uint64, parent *block.Transaction) *block.Transaction {
+	tx := createBasicValidTransaction(hash, fee)
+	tx.Inputs[0].PrevTxHash = parent.Hash
+	tx.Inputs[0].PrevTxIndex = 0
+	return tx
+}
+
+func TestAncestorDescendantFeeRates(t *testing.T) {
+	mp := NewMempool(TestMempoolConfig())
+
+	// All transactions are 211 bytes
+	parent := createBasicValidTransaction("parent", 211)
+	child := createChildTransaction("child", 2110, parent)
+	grandchild := createChildTransaction("grandchild", 633, child)
+
+	require.NoError(t, mp.AddTransaction(parent))
+	require.NoError(t, mp.AddTransaction(child))
+	require.NoError(t, mp.AddTransaction(grandchild))
+
+	entries := mp.GetRawMempoolVerbose()
+	require.Len(t, entries, 3)
+
+	parentInfo := entries[hex.EncodeToString(parent.Hash)]
+	childInfo := entries[hex.EncodeToString(child.Hash)]
+	grandchildInfo := entries[hex.EncodeToString(grandchild.Hash)]
+	require.NotNil(t, parentInfo)
+	require.NotNil(t, childInfo)
+	require.NotNil(t, grandchildInfo)
+
+	assert.Equal(t, 1, parentInfo.AncestorCount)
+	assert.Equal(t, uint64(1), parentInfo.AncestorFeeRate)
+	assert.Equal(t, 3, parentInfo.DescendantCount)
+	assert.Equal(t, uint64(2954), parentInfo.DescendantFees)
+	assert.Equal(t, uint64(633), parentInfo.DescendantSize)
+	assert.Equal(t, uint64(4), parentInfo.DescendantFeeRate)
+	assert.Empty(t, parentInfo.Depends)
+
+	assert.Equal(t, 2, childInfo.AncestorCount)
+	assert.Equal(t, uint64(2321), childInfo.AncestorFees)
+	assert.Equal(t, uint64(5), childInfo.AncestorFeeRate)
+	assert.Equal(t, 2, childInfo.DescendantCount)
+	assert.Equal(t, uint64(6), childInfo.DescendantFeeRate)
+	assert.Equal(t, []string{hex.EncodeToString(parent.Hash)}, childInfo.Depends)
+
+	assert.Equal(t, 3, grandchildInfo.AncestorCount)
+	assert.Equal(t, uint64(2954), grandchildInfo.AncestorFees)
+	assert.Equal(t, uint64(4), grandchildInfo.AncestorFeeRate)
+	assert.Equal(t, 1, grandchildInfo.DescendantCount)
+	assert.Equal(t, uint64(3), grandchildInfo.DescendantFeeRate)
+
+	// Removing the parent (e.g. once it is mined) drops it from the ancestor
+	// aggregates of everything that spent it
+	require.True(t, mp.RemoveTransaction(parent.Hash))
+
+	entries = mp.GetRawMempoolVerbose()
+	require.Len(t, entries, 2)
+
+	childInfo = entries[hex.EncodeToString(child.Hash)]
+	grandchildInfo = entries[hex.EncodeToString(grandchild.Hash)]
+
+	assert.Equal(t, 1, childInfo.AncestorCount)
+	assert.Equal(t, uint64(2110), childInfo.AncestorFees)
+	assert.Equal(t, uint64(10), childInfo.AncestorFeeRate)
+	assert.Empty(t, childInfo.Depends)
+	assert.Equal(t, 2, grandchildInfo.AncestorCount)
+	assert.Equal(t, uint64(2743), grandchildInfo.AncestorFees)
+	assert.Equal(t, uint64(6), grandchildInfo.AncestorFeeRate)
+
+	// Removing the child leaves the grandchild without in-mempool relatives
+	require.True(t, mp.RemoveTransaction(child.Hash))
+
+	grandchildInfo = mp.GetRawMempoolVerbose()[hex.EncodeToString(grandchild.Hash)]
+	require.NotNil(t, grandchildInfo)
+	assert.Equal(t, 1, grandchildInfo.AncestorCount)
+	assert.Equal(t, uint64(3), grandchildInfo.AncestorFeeRate)
+	assert.Equal(t, 1, grandchildInfo.DescendantCount)
+}
+
+func TestDescendantStatsUpdateOnChildRemoval(t *testing.T) {
+	mp := NewMempool(TestMempoolConfig())
+
+	parent := createBasicValidTransaction("parent", 211)
+	child := createChildTransaction("child", 2110, parent)
+
+	require.NoError(t, mp.AddTransaction(parent))
+	require.NoError(t, mp.AddTransaction(child))
+
+	parentInfo := mp.GetRawMempoolVerbose()[hex.EncodeToString(parent.Hash)]
+	assert.Equal(t, uint64(5), parentInfo.DescendantFeeRate)
+
+	require.True(t, mp.RemoveTransaction(child.Hash))
+
+	parentInfo = mp.GetRawMempoolVerbose()[hex.EncodeToString(parent.Hash)]
+	assert.Equal(t, 1, parentInfo.DescendantCount)
+	assert.Equal(t, uint64(211), parentInfo.DescendantFees)
+	assert.Equal(t, uint64(1), parentInfo.DescendantFeeRate)
+}
+
+// spendOutput creates a signed transaction moving output index 0 of prevHash
+// back to the same key, paying the given fee.
+func spendOutput(ctu *crypto_utils.CryptoTestUtils, kp *crypto_utils.TestKeyPair, prevHash []byte, value, fee uint64) *block.Transaction {
+	script, _ := hex.DecodeString(kp.Address)
+	inputs := []*block.TxInput{{PrevTxHash: prevHash, PrevTxIndex: 0, Sequence: 0xffffffff}}
+	outputs := []*block.TxOutput{{Value: value - fee, ScriptPubKey: script}}
+	return ctu.CreateSignedTransaction(inputs, outputs, map[string]*crypto_utils.TestKeyPair{kp.Address: kp}, fee)
+}
+
+func TestAcceptChainedUnconfirmedTransactions(t *testing.T) {
+	ctu := crypto_utils.NewCryptoTestUtils(t)
+	kp := ctu.GenerateTestKeyPair()
+	script, err := hex.DecodeString(kp.Address)
+	require.NoError(t, err)
+
+	newChain := func(maxDepth int) (*Mempool, []*block.Transaction) {
+		config := DefaultMempoolConfig()
+		config.MaxAncestorDepth = maxDepth
+		mp := NewMempool(config)
+
+		utxoSet := utxo.NewUTXOSet()
+		confirmed := &utxo.UTXO{
+			TxHash:       bytes.Repeat([]byte{0xaa}, 32),
+			TxIndex:      0,
+			Value:        100000,
+			ScriptPubKey: script,
+			Address:      kp.Address,
+			Height:       1,
+		}
+		utxoSet.AddUTXO(confirmed)
+		mp.SetUTXOSet(utxoSet)
+
+		grandparent := spendOutput(ctu, kp, confirmed.TxHash, 100000, 1000)
+		parent := spendOutput(ctu, kp, grandparent.Hash, 99000, 1500)
+		grandchild := spendOutput(ctu, kp, parent.Hash, 97500, 2000)
+		return mp, []*block.Transaction{grandparent, parent, grandchild}
+	}
+
+	t.Run("accepted within depth", func(t *testing.T) {
+		mp, txs := newChain(0)
+		for _, tx := range txs {
+			require.NoError(t, mp.AddTransaction(tx))
+		}
+
+		entries := mp.GetRawMempoolVerbose()
+		grandchild := entries[hex.EncodeToString(txs[2].Hash)]
+		require.NotNil(t, grandchild)
+		assert.Equal(t, 3, grandchild.AncestorCount)
+		assert.Equal(t, uint64(4500), grandchild.AncestorFees)
+	})
+
+	t.Run("fee must match chained input value", func(t *testing.T) {
+		mp, txs := newChain(0)
+		require.NoError(t, mp.AddTransaction(txs[0]))
+		require.NoError(t, mp.AddTransaction(txs[1]))
+
+		// Declares a 2500 fee, but the in-pool parent output of 97500 only
+		// leaves 2000 once the 95500 output is paid.
+		overclaimed := spendOutput(ctu, kp, txs[1].Hash, 98000, 2500)
+		err := mp.AddTransaction(overclaimed)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "less than specified fee")
+	})
+
+	t.Run("rejected beyond depth", func(t *testing.T) {
+		mp, txs := newChain(1)
+		require.NoError(t, mp.AddTransaction(txs[0]))
+		require.NoError(t, mp.AddTransaction(txs[1]))
+
+		err := mp.AddTransaction(txs[2])
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unconfirmed ancestor generations")
+	})
+}
+
+func TestAncestorDescendantLimits(t *testing.T) {
+	config := TestMempoolConfig()
+	config.MaxAncestors = 3
+	config.MaxDescendants = 3
+	mp := NewMempool(config)
+
+	a := createBasicValidTransaction("a", 211)
+	b := createChildTransaction("b", 211, a)
+	c := createChildTransaction("c", 211, b)
+	require.NoError(t, mp.AddTransaction(a))
+	require.NoError(t, mp.AddTransaction(b))
+	require.NoError(t, mp.AddTransaction(c))
+
+	// A fourth generation has too many ancestors
+	err := mp.AddTransaction(createChildTransaction("d", 211, c))
+	assert.ErrorIs(t, err, ErrChainLimit)
+	assert.ErrorContains(t, err, "4 unconfirmed ancestors (max: 3)")
+
+	// A second child of a has few ancestors but gives a too many descendants
+	sibling := createChildTransaction("sibling", 211, a)
+	sibling.Inputs[0].PrevTxIndex = 1
+	err = mp.AddTransaction(sibling)
+	assert.ErrorIs(t, err, ErrChainLimit)
+	assert.ErrorContains(t, err, "would have 4 descendants (max: 3)")
+
+	assert.Equal(t, []*block.Transaction{a, b}, mp.GetAncestors(c.Hash))
+	assert.Equal(t, []*block.Transaction{b, c}, mp.GetDescendants(a.Hash))
+	assert.Empty(t, mp.GetAncestors(a.Hash))
+	assert.Empty(t, mp.GetDescendants(c.Hash))
+	assert.Nil(t, mp.GetDescendants([]byte("unknown")))
+
+	// Once c leaves the mempool there is room for the sibling
+	require.True(t, mp.RemoveTransaction(c.Hash))
+	require.NoError(t, mp.AddTransaction(sibling))
+	assert.ElementsMatch(t, []*block.Transaction{b, sibling}, mp.GetDescendants(a.Hash))
+}
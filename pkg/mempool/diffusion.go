@@ -0,0 +1,104 @@
+package mempool
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+)
+
+// diffusionCheckInterval is how often StartDiffusion releases the local
+// transactions whose delay has passed, which bounds how late they are sent.
+const diffusionCheckInterval = 100 * time.Millisecond
+
+// DelayRange is a range of durations a random delay is drawn from.
+type DelayRange struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// IsZero reports whether the range is empty, which disables the delay.
+func (r DelayRange) IsZero() bool {
+	return r.Min == 0 && r.Max == 0
+}
+
+// Validate checks that the range is not negative or inverted.
+func (r DelayRange) Validate() error {
+	if r.Min < 0 {
+		return fmt.Errorf("minimum delay %v is negative", r.Min)
+	}
+	if r.Max < r.Min {
+		return fmt.Errorf("maximum delay %v is below minimum delay %v", r.Max, r.Min)
+	}
+	return nil
+}
+
+// random returns a delay drawn uniformly from the range.
+func (r DelayRange) random() time.Duration {
+	if r.Max <= r.Min {
+		return r.Min
+	}
+	return r.Min + rand.N(r.Max-r.Min+1)
+}
+
+// holdForDiffusion delays the first broadcast of a local transaction by a
+// random delay from the diffusion range, so that peers cannot tell it
+// originated here from how soon it is relayed. The caller must hold mp.mu.
+func (mp *Mempool) holdForDiffusion(entry *TransactionEntry) {
+	if mp.diffusionDelay.IsZero() {
+		return
+	}
+	entry.releaseAt = mp.now().Add(mp.diffusionDelay.random())
+}
+
+// ReleaseDiffused passes each local transaction whose diffusion delay has
+// passed to publish, once, and returns the number of transactions
+// published. Rebroadcasting of a transaction starts after its release.
+func (mp *Mempool) ReleaseDiffused(publish func(tx *block.Transaction) error) int {
+	mp.mu.Lock()
+	now := mp.now()
+	var due []*block.Transaction
+	for _, entry := range mp.transactions {
+		if entry.releaseAt.IsZero() || now.Before(entry.releaseAt) {
+			continue
+		}
+		entry.releaseAt = time.Time{}
+		entry.lastBroadcast = now
+		due = append(due, entry.Transaction)
+	}
+	mp.mu.Unlock()
+
+	published := 0
+	for _, tx := range due {
+		if err := publish(tx); err != nil {
+			fmt.Printf("Failed to broadcast transaction %x: %v\n", tx.Hash, err)
+			continue
+		}
+		published++
+	}
+	return published
+}
+
+// StartDiffusion calls ReleaseDiffused with publish until ctx is done. It
+// does nothing if DiffusionDelayRange is zero.
+func (mp *Mempool) StartDiffusion(ctx context.Context, publish func(tx *block.Transaction) error) {
+	if mp.diffusionDelay.IsZero() {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(diffusionCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				mp.ReleaseDiffused(publish)
+			}
+		}
+	}()
+}
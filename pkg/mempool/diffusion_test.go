@@ -0,0 +1,87 @@
+package mempool
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffusionDelaysLocalTransactions(t *testing.T) {
+	config := TestMempoolConfig()
+	config.RebroadcastInterval = time.Minute
+	config.DiffusionDelayRange = DelayRange{Min: 2 * time.Second, Max: 5 * time.Second}
+	mp := NewMempool(config)
+	start := time.Unix(1700000000, 0)
+	now := start
+	mp.now = func() time.Time { return now }
+
+	var published []*block.Transaction
+	publish := func(tx *block.Transaction) error {
+		published = append(published, tx)
+		return nil
+	}
+
+	local := createBasicValidTransaction("local", 1000)
+	require.NoError(t, mp.AddLocalTransaction(local))
+	require.NoError(t, mp.AddTransaction(createBasicValidTransaction("relayed", 1000)))
+
+	// The originated transaction is not broadcast immediately
+	assert.Equal(t, 0, mp.ReleaseDiffused(publish))
+	releaseAt := mp.transactions[string(local.Hash)].releaseAt
+	assert.False(t, releaseAt.Before(start.Add(2*time.Second)))
+	assert.False(t, releaseAt.After(start.Add(5*time.Second)))
+
+	now = releaseAt.Add(-time.Millisecond)
+	assert.Equal(t, 0, mp.ReleaseDiffused(publish))
+
+	// It is released once within the configured range
+	now = start.Add(5 * time.Second)
+	assert.Equal(t, 1, mp.ReleaseDiffused(publish))
+	require.Len(t, published, 1)
+	assert.Equal(t, local.Hash, published[0].Hash)
+	assert.Equal(t, 0, mp.ReleaseDiffused(publish))
+
+	// Rebroadcasting counts from the release
+	now = now.Add(time.Minute - time.Millisecond)
+	assert.Equal(t, 0, mp.Rebroadcast(publish))
+	now = now.Add(time.Second)
+	assert.Equal(t, 1, mp.Rebroadcast(publish))
+}
+
+func TestStartDiffusion(t *testing.T) {
+	config := TestMempoolConfig()
+	config.DiffusionDelayRange = DelayRange{Min: 200 * time.Millisecond, Max: 400 * time.Millisecond}
+	mp := NewMempool(config)
+
+	var mu sync.Mutex
+	var releasedAfter time.Duration
+	added := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mp.StartDiffusion(ctx, func(tx *block.Transaction) error {
+		mu.Lock()
+		defer mu.Unlock()
+		releasedAfter = time.Since(added)
+		return nil
+	})
+	require.NoError(t, mp.AddLocalTransaction(createBasicValidTransaction("local", 1000)))
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return releasedAfter > 0
+	}, 2*time.Second, 10*time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.GreaterOrEqual(t, releasedAfter, 200*time.Millisecond)
+	assert.LessOrEqual(t, releasedAfter, 400*time.Millisecond+diffusionCheckInterval+100*time.Millisecond)
+
+	// Inverted ranges are rejected
+	config.DiffusionDelayRange = DelayRange{Min: time.Second, Max: time.Millisecond}
+	assert.ErrorContains(t, config.Validate(), "diffusion delay range")
+}
@@ -0,0 +1,116 @@
+package mempool
+
+import "time"
+
+// AcceptanceEventType tells whether a transaction entered or left the
+// mempool.
+type AcceptanceEventType int
+
+const (
+	// TxAccepted is emitted when a transaction enters the mempool,
+	// including when a reorg returns it.
+	TxAccepted AcceptanceEventType = iota
+	// TxEvicted is emitted when a transaction leaves the mempool.
+	TxEvicted
+)
+
+// String returns the name of the event type.
+func (t AcceptanceEventType) String() string {
+	switch t {
+	case TxAccepted:
+		return "accepted"
+	case TxEvicted:
+		return "evicted"
+	default:
+		return "unknown"
+	}
+}
+
+// EvictionReason tells why a transaction left the mempool.
+type EvictionReason string
+
+const (
+	// EvictionSizeLimit is the reason of transactions with the lowest fee
+	// rate evicted to make room for a new one.
+	EvictionSizeLimit EvictionReason = "size limit"
+	// EvictionExpired is the reason of transactions that stayed in the
+	// mempool too long.
+	EvictionExpired EvictionReason = "expired"
+	// EvictionConfirmed is the reason of transactions included in a block
+	// connected to the active chain.
+	EvictionConfirmed EvictionReason = "confirmed"
+	// EvictionRemoved is the reason of transactions removed by a caller of
+	// RemoveTransaction.
+	EvictionRemoved EvictionReason = "removed"
+)
+
+// AcceptanceEvent describes a transaction entering or leaving the mempool,
+// for analytics such as dashboards and fee models.
+type AcceptanceEvent struct {
+	Type    AcceptanceEventType
+	TxHash  []byte
+	FeeRate uint64         // FeeRate is the fee per byte, or per virtual byte under weight accounting.
+	Size    uint64         // Size is the size the mempool accounts the transaction for.
+	Time    time.Time      // Time is when the transaction entered or left the mempool.
+	Reason  EvictionReason // Reason is why an evicted transaction left, empty for accepted ones.
+}
+
+// SubscribeAcceptance returns a channel receiving an event for every
+// transaction accepted into or evicted from the mempool, and a function
+// ending the subscription. Sending never blocks the mempool: events are
+// dropped while the channel's buffer is full.
+func (mp *Mempool) SubscribeAcceptance(buffer int) (<-chan AcceptanceEvent, func()) {
+	events := make(chan AcceptanceEvent, buffer)
+
+	mp.subsMu.Lock()
+	if mp.acceptanceSubs == nil {
+		mp.acceptanceSubs = make(map[chan AcceptanceEvent]struct{})
+	}
+	mp.acceptanceSubs[events] = struct{}{}
+	mp.subsMu.Unlock()
+
+	return events, func() {
+		mp.subsMu.Lock()
+		defer mp.subsMu.Unlock()
+		if _, exists := mp.acceptanceSubs[events]; exists {
+			delete(mp.acceptanceSubs, events)
+			close(events)
+		}
+	}
+}
+
+// publishAccepted notifies subscribers that entry entered the mempool.
+func (mp *Mempool) publishAccepted(entry *TransactionEntry) {
+	mp.publishAcceptance(AcceptanceEvent{
+		Type:    TxAccepted,
+		TxHash:  entry.Transaction.Hash,
+		FeeRate: entry.FeeRate,
+		Size:    entry.Size,
+		Time:    mp.now(),
+	})
+}
+
+// publishEvicted notifies subscribers that entry left the mempool.
+func (mp *Mempool) publishEvicted(entry *TransactionEntry, reason EvictionReason) {
+	mp.publishAcceptance(AcceptanceEvent{
+		Type:    TxEvicted,
+		TxHash:  entry.Transaction.Hash,
+		FeeRate: entry.FeeRate,
+		Size:    entry.Size,
+		Time:    mp.now(),
+		Reason:  reason,
+	})
+}
+
+// publishAcceptance sends an event to every subscriber without blocking.
+func (mp *Mempool) publishAcceptance(event AcceptanceEvent) {
+	mp.subsMu.Lock()
+	defer mp.subsMu.Unlock()
+	for events := range mp.acceptanceSubs {
+		select {
+		case events <- event:
+		default:
+			// The subscriber is not keeping up; drop rather than block the mempool
+		}
+	}
+}
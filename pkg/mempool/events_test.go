@@ -0,0 +1,73 @@
+package mempool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribeAcceptance(t *testing.T) {
+	low := createBasicValidTransaction("low", 300)
+	high := createBasicValidTransaction("high", 1000)
+	mid := createBasicValidTransaction("mid", 600)
+
+	config := TestMempoolConfig()
+	size := accountedSize(low, false)
+	config.MaxSize = 2*size + size/2
+	config.MaxTxSize = size
+	mp := NewMempool(config)
+
+	events, unsubscribe := mp.SubscribeAcceptance(16)
+	// A subscriber that never reads does not hold the mempool up
+	_, unsubscribeStalled := mp.SubscribeAcceptance(0)
+	defer unsubscribeStalled()
+
+	next := func() AcceptanceEvent {
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(time.Second):
+			t.Fatal("no acceptance event")
+			return AcceptanceEvent{}
+		}
+	}
+	expect := func(eventType AcceptanceEventType, tx *block.Transaction, reason EvictionReason) {
+		t.Helper()
+		event := next()
+		assert.Equal(t, eventType, event.Type)
+		assert.Equal(t, tx.Hash, event.TxHash)
+		assert.Equal(t, size, event.Size)
+		assert.Equal(t, tx.Fee/size, event.FeeRate)
+		assert.Equal(t, reason, event.Reason)
+		assert.WithinDuration(t, time.Now(), event.Time, time.Minute)
+	}
+
+	require.NoError(t, mp.AddTransaction(low))
+	require.NoError(t, mp.AddTransaction(high))
+	expect(TxAccepted, low, "")
+	expect(TxAccepted, high, "")
+
+	// The full mempool evicts the lowest fee rate to admit a new transaction
+	require.NoError(t, mp.AddTransaction(mid))
+	expect(TxEvicted, low, EvictionSizeLimit)
+	expect(TxAccepted, mid, "")
+
+	mp.RemoveConfirmedTransactions(&block.Block{
+		Header:       &block.Header{Height: 1},
+		Transactions: []*block.Transaction{high},
+	})
+	expect(TxEvicted, high, EvictionConfirmed)
+
+	mp.transactions[string(mid.Hash)].Timestamp = time.Now().Add(-2 * time.Hour)
+	assert.Equal(t, 1, mp.CleanupExpiredTransactions(time.Hour))
+	expect(TxEvicted, mid, EvictionExpired)
+
+	// Ending the subscription closes the channel
+	unsubscribe()
+	_, open := <-events
+	assert.False(t, open)
+	require.NoError(t, mp.AddTransaction(low))
+}
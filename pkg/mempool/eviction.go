@@ -0,0 +1,143 @@
+package mempool
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+)
+
+// GetLowestFeeRate returns the eviction floor: the lowest fee rate of the
+// transactions in the mempool, zero if it is empty. While the mempool is
+// full a transaction paying less is rejected, as admitting it would mean
+// evicting transactions paying more, so callers can compare their fee rate
+// with the floor before submitting.
+func (mp *Mempool) GetLowestFeeRate() uint64 {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	return mp.lowestFeeRateLocked()
+}
+
+// lowestFeeRateLocked returns the lowest fee rate in the mempool.
+// Note: the caller must hold the mempool lock.
+func (mp *Mempool) lowestFeeRateLocked() uint64 {
+	if mp.byFee.Len() == 0 {
+		return 0
+	}
+	return (*mp.byFee)[0].FeeRate
+}
+
+// evictLowFeeTransactions evicts the packages with the lowest eviction
+// rates until the incoming entry fits within the size limit. A transaction
+// is evicted together with its in-mempool descendants, each dependent before
+// the transaction it spends, so no child is left without its parent, and is
+// ranked by its eviction rate so a parent whose children pay for it is kept.
+// The incoming entry's own in-mempool ancestors are never evicted. The
+// eviction set is chosen first and evicted only if it makes enough room
+// while every package in it pays less than the incoming entry; otherwise
+// nothing is evicted. It reports whether enough room was made.
+// Note: the caller must hold the mempool lock.
+func (mp *Mempool) evictLowFeeTransactions(incoming *TransactionEntry) bool {
+	protected := mp.inMempoolAncestors(incoming.Transaction)
+	needed := mp.currentSize + incoming.Size - mp.maxSize
+
+	var roots []*TransactionEntry
+	selected := make(map[string]struct{})
+	freed := uint64(0)
+	for _, entry := range mp.sortedByEvictionRate() {
+		if freed >= needed {
+			break
+		}
+		hash := string(entry.Transaction.Hash)
+		if _, ok := selected[hash]; ok {
+			continue
+		}
+		if _, ok := protected[hash]; ok {
+			continue
+		}
+		// Every remaining package pays at least as much as this one
+		if evictionRate(entry) >= incoming.FeeRate {
+			return false
+		}
+		roots = append(roots, entry)
+		for _, member := range append(mp.collectRelatives(entry, false), entry) {
+			if _, ok := selected[string(member.Transaction.Hash)]; ok {
+				continue
+			}
+			selected[string(member.Transaction.Hash)] = struct{}{}
+			freed += member.Size
+		}
+	}
+	if freed < needed {
+		return false
+	}
+
+	for _, entry := range roots {
+		if _, exists := mp.transactions[string(entry.Transaction.Hash)]; exists {
+			mp.evictWithDescendants(entry)
+		}
+	}
+	return true
+}
+
+// evictWithDescendants evicts an entry after the descendants spending it.
+// Note: the caller must hold the mempool lock.
+func (mp *Mempool) evictWithDescendants(entry *TransactionEntry) {
+	for childHash := range entry.children {
+		if child, exists := mp.transactions[childHash]; exists {
+			mp.evictWithDescendants(child)
+		}
+	}
+	mp.dropEntry(entry, EvictionSizeLimit)
+}
+
+// evictionRate returns the rate an entry is ranked by for eviction: the
+// higher of its own fee rate and the fee rate of its package with its
+// descendants, as evicting it evicts them too.
+func evictionRate(entry *TransactionEntry) uint64 {
+	return max(entry.FeeRate, entry.DescendantFeeRate())
+}
+
+// sortedByFeeRate returns the entries of the fee queue lowest fee rate
+// first, leaving the queue itself untouched.
+// Note: the caller must hold the mempool lock.
+func (mp *Mempool) sortedByFeeRate() []*TransactionEntry {
+	entries := slices.Clone(*mp.byFee)
+	slices.SortStableFunc(entries, func(a, b *TransactionEntry) int {
+		return cmp.Compare(a.FeeRate, b.FeeRate)
+	})
+	return entries
+}
+
+// sortedByEvictionRate returns the entries of the fee queue lowest eviction
+// rate first, then lowest fee rate, leaving the queue itself untouched.
+// Note: the caller must hold the mempool lock.
+func (mp *Mempool) sortedByEvictionRate() []*TransactionEntry {
+	entries := slices.Clone(*mp.byFee)
+	slices.SortStableFunc(entries, func(a, b *TransactionEntry) int {
+		return cmp.Or(cmp.Compare(evictionRate(a), evictionRate(b)), cmp.Compare(a.FeeRate, b.FeeRate))
+	})
+	return entries
+}
+
+// inMempoolAncestors returns the hashes of the mempool transactions a
+// transaction spends from, directly or through other mempool transactions.
+// Note: the caller must hold the mempool lock.
+func (mp *Mempool) inMempoolAncestors(tx *block.Transaction) map[string]struct{} {
+	ancestors := make(map[string]struct{})
+	for _, input := range tx.Inputs {
+		parent, exists := mp.transactions[string(input.PrevTxHash)]
+		if !exists {
+			continue
+		}
+		if _, seen := ancestors[string(input.PrevTxHash)]; seen {
+			continue
+		}
+		ancestors[string(input.PrevTxHash)] = struct{}{}
+		for _, ancestor := range mp.collectRelatives(parent, true) {
+			ancestors[string(ancestor.Transaction.Hash)] = struct{}{}
+		}
+	}
+	return ancestors
+}
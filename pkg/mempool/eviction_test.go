@@ -0,0 +1,120 @@
+package mempool
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeeRateEviction(t *testing.T) {
+	// All transactions are 211 bytes, so three fill the mempool
+	config := TestMempoolConfig()
+	config.MaxSize = 3 * 211
+	mp := NewMempool(config)
+	assert.Zero(t, mp.GetLowestFeeRate())
+
+	parent := createBasicValidTransaction("parent", 2*211)
+	child := createChildTransaction("child", 5*211, parent)
+	mid := createBasicValidTransaction("mid", 3*211)
+	require.NoError(t, mp.AddTransaction(parent))
+	require.NoError(t, mp.AddTransaction(child))
+	require.NoError(t, mp.AddTransaction(mid))
+	assert.Equal(t, uint64(2), mp.GetLowestFeeRate())
+
+	// Below the eviction floor a transaction does not displace others
+	err := mp.AddTransaction(createBasicValidTransaction("low", 211))
+	assert.ErrorIs(t, err, ErrInsufficientFee)
+	assert.ErrorContains(t, err, "eviction floor 2")
+	assert.Equal(t, 3, mp.GetTransactionCount())
+
+	// The lowest fee rate is evicted, after the child depending on it
+	events, cancel := mp.SubscribeAcceptance(8)
+	defer cancel()
+	high := createBasicValidTransaction("high", 10*211)
+	require.NoError(t, mp.AddTransaction(high))
+	for _, want := range []struct {
+		hash      []byte
+		eventType AcceptanceEventType
+	}{{child.Hash, TxEvicted}, {parent.Hash, TxEvicted}, {high.Hash, TxAccepted}} {
+		event := <-events
+		assert.Equal(t, want.hash, event.TxHash)
+		assert.Equal(t, want.eventType, event.Type)
+	}
+	assert.NotNil(t, mp.GetTransaction(mid.Hash))
+	assert.Equal(t, uint64(2*211), mp.GetSize())
+	assert.Equal(t, uint64(3), mp.GetLowestFeeRate())
+}
+
+func TestEvictionKeepsIncomingAncestors(t *testing.T) {
+	config := TestMempoolConfig()
+	config.MaxSize = 3 * 211
+	mp := NewMempool(config)
+
+	a := createBasicValidTransaction("a", 2*211)
+	b := createBasicValidTransaction("b", 4*211)
+	c := createBasicValidTransaction("c", 6*211)
+	require.NoError(t, mp.AddTransaction(a))
+	require.NoError(t, mp.AddTransaction(b))
+	require.NoError(t, mp.AddTransaction(c))
+
+	// The lowest fee rate is the parent of the incoming transaction, so the
+	// next lowest is evicted instead
+	childOfA := createChildTransaction("child-of-a", 8*211, a)
+	require.NoError(t, mp.AddTransaction(childOfA))
+	assert.NotNil(t, mp.GetTransaction(a.Hash))
+	assert.Nil(t, mp.GetTransaction(b.Hash))
+	assert.NotNil(t, mp.GetTransaction(c.Hash))
+	assert.NotNil(t, mp.GetTransaction(childOfA.Hash))
+}
+
+func TestEvictionKeepsParentsPaidForByChildren(t *testing.T) {
+	config := TestMempoolConfig()
+	config.MaxSize = 3 * 211
+	mp := NewMempool(config)
+
+	// The parent pays 2 but its child lifts the package to 6
+	parent := createBasicValidTransaction("parent", 2*211)
+	child := createChildTransaction("child", 10*211, parent)
+	b := createBasicValidTransaction("b", 4*211)
+	require.NoError(t, mp.AddTransaction(parent))
+	require.NoError(t, mp.AddTransaction(child))
+	require.NoError(t, mp.AddTransaction(b))
+
+	incoming := createBasicValidTransaction("incoming", 5*211)
+	require.NoError(t, mp.AddTransaction(incoming))
+	assert.NotNil(t, mp.GetTransaction(parent.Hash))
+	assert.NotNil(t, mp.GetTransaction(child.Hash))
+	assert.Nil(t, mp.GetTransaction(b.Hash))
+}
+
+func TestEvictionIsAllOrNothing(t *testing.T) {
+	config := TestMempoolConfig()
+	config.MaxSize = 3 * 211
+	mp := NewMempool(config)
+
+	a := createBasicValidTransaction("a", 2*211)
+	b := createBasicValidTransaction("b", 4*211)
+	c := createBasicValidTransaction("c", 6*211)
+	require.NoError(t, mp.AddTransaction(a))
+	require.NoError(t, mp.AddTransaction(b))
+	require.NoError(t, mp.AddTransaction(c))
+
+	// The incoming transaction needs the room of two others, but only a
+	// pays less than it, so nothing is evicted
+	big := createBasicValidTransaction("big", 0)
+	big.Outputs = append(big.Outputs, big.Outputs[0])
+	size := mp.calculateTransactionSize(big)
+	require.Greater(t, size, uint64(211))
+	big.Fee = 3 * size
+	assert.Error(t, mp.AddTransaction(big))
+	assert.Equal(t, 3, mp.GetTransactionCount())
+	assert.NotNil(t, mp.GetTransaction(a.Hash))
+
+	// A transaction paying more than both makes room for itself
+	big.Fee = 5 * size
+	require.NoError(t, mp.AddTransaction(big))
+	assert.Nil(t, mp.GetTransaction(a.Hash))
+	assert.Nil(t, mp.GetTransaction(b.Hash))
+	assert.NotNil(t, mp.GetTransaction(c.Hash))
+}
@@ -0,0 +1,140 @@
+package mempool
+
+import (
+	"sort"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+)
+
+const (
+	// maxFeeSamples is the number of most recently confirmed transactions
+	// fee estimates are computed from.
+	maxFeeSamples = 1000
+	// minFeeSamples is the number of confirmed transactions needed before
+	// the estimator trusts its data; below it estimates fall back to the
+	// minimum fee rate.
+	minFeeSamples = 20
+	// feeEstimateSuccessRate is the share of transactions paying at least the
+	// estimated fee rate that must have confirmed within the target.
+	feeEstimateSuccessRate = 0.85
+)
+
+// feeSample records the fee rate of a confirmed transaction and how many
+// blocks it waited in the mempool.
+type feeSample struct {
+	feeRate uint64
+	blocks  uint64
+}
+
+// feeEstimator keeps a ring of the latest confirmation samples.
+type feeEstimator struct {
+	samples []feeSample
+	next    int
+}
+
+// record adds a confirmation sample, replacing the oldest once full.
+func (fe *feeEstimator) record(s feeSample) {
+	if len(fe.samples) < maxFeeSamples {
+		fe.samples = append(fe.samples, s)
+		return
+	}
+	fe.samples[fe.next] = s
+	fe.next = (fe.next + 1) % maxFeeSamples
+}
+
+// estimate returns the lowest fee rate at which at least
+// feeEstimateSuccessRate of the transactions paying that rate or more
+// confirmed within target blocks. If no rate qualifies, the highest observed
+// rate is returned.
+func (fe *feeEstimator) estimate(target uint64) uint64 {
+	sorted := append([]feeSample(nil), fe.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].feeRate > sorted[j].feeRate })
+
+	best := sorted[0].feeRate
+	confirmed := 0
+	for i, s := range sorted {
+		if s.blocks <= target {
+			confirmed++
+		}
+		// Only consider thresholds between distinct fee rates
+		if i+1 < len(sorted) && sorted[i+1].feeRate == s.feeRate {
+			continue
+		}
+		if float64(confirmed)/float64(i+1) >= feeEstimateSuccessRate {
+			best = s.feeRate
+		}
+	}
+	return best
+}
+
+// EstimateFeeRate returns the fee rate, per byte, a transaction should pay to
+// confirm within the given number of blocks, based on how long recently
+// mined transactions waited in the mempool. ok is false when fewer than
+// minFeeSamples confirmations have been observed, in which case the minimum
+// fee rate is returned as a fallback.
+func (mp *Mempool) EstimateFeeRate(blocks uint64) (feeRate uint64, ok bool) {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	if len(mp.feeEstimates.samples) < minFeeSamples {
+		return mp.minFeeRate, false
+	}
+	if blocks == 0 {
+		blocks = 1
+	}
+	feeRate = mp.feeEstimates.estimate(blocks)
+	if feeRate < mp.minFeeRate {
+		feeRate = mp.minFeeRate
+	}
+	return feeRate, true
+}
+
+// RemoveConfirmedTransactions removes the transactions of a block connected
+// to the active chain from the mempool, recording how many blocks each
+// waited for fee estimation and retaining their entries for reorgs. It
+// returns the number removed.
+func (mp *Mempool) RemoveConfirmedTransactions(b *block.Block) int {
+	if b == nil || b.Header == nil {
+		return 0
+	}
+
+	removed := 0
+	for _, tx := range b.Transactions {
+		mp.mu.RLock()
+		entry, exists := mp.transactions[string(tx.Hash)]
+		mp.mu.RUnlock()
+		if !exists || !mp.removeTransaction(tx.Hash, EvictionConfirmed) {
+			continue
+		}
+		removed++
+
+		waited := uint64(1)
+		if b.Header.Height > entry.entryHeight {
+			waited = b.Header.Height - entry.entryHeight
+		}
+		mp.mu.Lock()
+		mp.feeEstimates.record(feeSample{feeRate: entry.FeeRate, blocks: waited})
+		mp.retainLocked(entry)
+		mp.mu.Unlock()
+	}
+	return removed
+}
+
+// ConfirmedInPool returns the hashes of the transactions of a block that are
+// still in the mempool. It is empty once RemoveConfirmedTransactions has
+// processed the block.
+func (mp *Mempool) ConfirmedInPool(b *block.Block) [][]byte {
+	if b == nil {
+		return nil
+	}
+
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+	var remaining [][]byte
+	for _, tx := range b.Transactions {
+		if _, exists := mp.transactions[string(tx.Hash)]; exists {
+			remaining = append(remaining, tx.Hash)
+		}
+	}
+	return remaining
+}
@@ -0,0 +1,51 @@
+package mempool
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateFeeRate(t *testing.T) {
+	config := TestMempoolConfig()
+	config.MinFeeRate = 2
+	mp := NewMempool(config)
+
+	// No history yet: the minimum fee rate is the fallback
+	rate, ok := mp.EstimateFeeRate(1)
+	assert.False(t, ok)
+	assert.Equal(t, uint64(2), rate)
+
+	// High-fee transactions confirm in the next block, low-fee ones wait
+	// five blocks
+	var high, low []*block.Transaction
+	for i := 0; i < 15; i++ {
+		high = append(high, createBasicValidTransaction(fmt.Sprintf("high_%d", i), 4000))
+		low = append(low, createBasicValidTransaction(fmt.Sprintf("low_%d", i), 600))
+	}
+	mp.SetChainHeight(10)
+	for _, tx := range append(append([]*block.Transaction(nil), high...), low...) {
+		require.NoError(t, mp.AddTransaction(tx))
+	}
+
+	removed := mp.RemoveConfirmedTransactions(&block.Block{Header: &block.Header{Height: 11}, Transactions: high})
+	assert.Equal(t, len(high), removed)
+	removed = mp.RemoveConfirmedTransactions(&block.Block{Header: &block.Header{Height: 15}, Transactions: low})
+	assert.Equal(t, len(low), removed)
+	assert.Equal(t, 0, mp.GetTransactionCount())
+
+	highRate := mp.calculateFeeRate(high[0], mp.calculateTransactionSize(high[0]))
+	lowRate := mp.calculateFeeRate(low[0], mp.calculateTransactionSize(low[0]))
+	require.Greater(t, highRate, lowRate)
+
+	rate, ok = mp.EstimateFeeRate(1)
+	assert.True(t, ok)
+	assert.Equal(t, highRate, rate)
+
+	rate, ok = mp.EstimateFeeRate(5)
+	assert.True(t, ok)
+	assert.Equal(t, lowRate, rate)
+}
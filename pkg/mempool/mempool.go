@@ -3,6 +3,7 @@ package mempool
 import (
 	"bytes"
 	"container/heap"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -11,6 +12,22 @@ import (
 	"github.com/palaseus/adrenochain/pkg/utxo"
 )
 
+// Errors returned when a transaction is not accepted, so that callers can
+// tell why without matching on messages.
+var (
+	// ErrAlreadyInMempool is returned for a transaction already in the mempool.
+	ErrAlreadyInMempool = errors.New("transaction already in mempool")
+	// ErrInsufficientFee is returned for a transaction paying less than the
+	// minimum fee rate.
+	ErrInsufficientFee = errors.New("insufficient fee")
+	// ErrDoubleSpend is returned for a transaction spending an output that a
+	// mempool transaction already spends.
+	ErrDoubleSpend = errors.New("double spend")
+	// ErrChainLimit is returned for a transaction that would exceed the
+	// limits on unconfirmed ancestors and descendants.
+	ErrChainLimit = errors.New("unconfirmed chain limit exceeded")
+)
+
 // Mempool represents the transaction memory pool.
 // It stores unconfirmed transactions and prioritizes them for inclusion in blocks.
 type Mempool struct {
@@ -24,6 +41,33 @@ type Mempool struct {
 	utxoSet      *utxo.UTXOSet                // utxoSet is used for transaction validation
 	maxTxSize    uint64                       // maxTxSize is the maximum allowed transaction size in bytes
 	testMode     bool                         // testMode allows skipping UTXO validation for testing
+
+	maxAncestorDepth int // maxAncestorDepth limits how many unconfirmed generations a transaction may build on
+	maxAncestors     int // maxAncestors limits the unconfirmed ancestors of a transaction, itself included
+	maxDescendants   int // maxDescendants limits the in-mempool descendants of a transaction, itself included
+
+	freeTxMinPriority uint64 // freeTxMinPriority is the coin-age priority needed for free relay, zero disables it
+	chainHeight       uint64 // chainHeight is the tip height used for coin-age priority and relative lock-times
+
+	enforceSequenceLocks bool               // enforceSequenceLocks rejects inputs whose relative lock-time has not passed
+	blockTime            utxo.BlockTimeFunc // blockTime returns chain block timestamps for time-based relative lock-times
+	weightAccounting     bool               // weightAccounting measures transactions in virtual bytes
+	scriptLimits         ScriptSizeLimits   // scriptLimits bounds the total script sizes of relayed transactions
+
+	feeEstimates feeEstimator // feeEstimates records how long confirmed transactions waited
+
+	reorgRetention time.Duration          // reorgRetention is how long confirmed entries are kept for reorgs
+	retained       map[string]*retainedTx // retained holds recently confirmed entries, keyed by hash
+	validations    uint64                 // validations counts full transaction validations
+	now            func() time.Time       // now returns the current time, replaceable in tests
+
+	rebroadcastInterval time.Duration // rebroadcastInterval is how often unconfirmed local transactions are resent, zero disables it
+	maxRebroadcasts     int           // maxRebroadcasts caps how often a local transaction is resent
+	diffusionDelay      DelayRange    // diffusionDelay is the range of random delays before a local transaction is first sent
+	localAdded          chan struct{} // localAdded wakes StartRebroadcast to send a local transaction not held for diffusion
+
+	subsMu         sync.Mutex                        // subsMu protects acceptanceSubs
+	acceptanceSubs map[chan AcceptanceEvent]struct{} // acceptanceSubs receive the transactions accepted and evicted
 }
 
 // TransactionEntry wraps a transaction with metadata used for mempool management.
@@ -33,6 +77,29 @@ type TransactionEntry struct {
 	Size        uint64             // Size is the approximate size of the transaction in bytes.
 	Timestamp   time.Time          // Timestamp is when the transaction was added to the mempool.
 	index       int                // index is used by the heap.Interface implementation.
+	feeIndex    int                // feeIndex is the position in the fee queue, apart from index as an entry is in both queues.
+
+	// Ancestor and descendant aggregates include the transaction itself and are
+	// kept up to date as related transactions enter and leave the mempool.
+	AncestorCount   int    // AncestorCount is the number of in-mempool ancestors plus one.
+	AncestorSize    uint64 // AncestorSize is the total size of the entry and its ancestors.
+	AncestorFees    uint64 // AncestorFees is the total fee of the entry and its ancestors.
+	DescendantCount int    // DescendantCount is the number of in-mempool descendants plus one.
+	DescendantSize  uint64 // DescendantSize is the total size of the entry and its descendants.
+	DescendantFees  uint64 // DescendantFees is the total fee of the entry and its descendants.
+
+	parents  map[string]struct{} // parents holds the hashes of in-mempool transactions this one spends.
+	children map[string]struct{} // children holds the hashes of in-mempool transactions spending this one.
+
+	confirmedValue uint64 // confirmedValue is the total value of confirmed outputs spent.
+	valueHeightSum uint64 // valueHeightSum is the sum of each confirmed input value times its height.
+	free           bool   // free marks entries admitted below the minimum fee rate on priority.
+	entryHeight    uint64 // entryHeight is the chain height when the transaction entered the mempool.
+
+	local         bool      // local marks transactions submitted to this node, which are rebroadcast.
+	lastBroadcast time.Time // lastBroadcast is when a local transaction was last sent to peers, zero before its first broadcast.
+	rebroadcasts  int       // rebroadcasts counts the times a local transaction was rebroadcast.
+	releaseAt     time.Time // releaseAt is when a local transaction held back for diffusion is first sent, zero once sent.
 }
 
 // TransactionHeap implements heap.Interface for transaction prioritization based on fee rate (max-heap).
@@ -40,11 +107,73 @@ type TransactionHeap []*TransactionEntry
 
 // MempoolConfig holds configuration parameters for the mempool.
 type MempoolConfig struct {
-	MaxSize    uint64 // MaxSize is the maximum allowed size of the mempool in bytes.
+	MaxSize    uint64 // MaxSize is the maximum allowed size of the mempool in bytes, beyond which the lowest fee rates are evicted.
 	MinFeeRate uint64 // MinFeeRate is the minimum fee per byte required for a transaction.
 	MaxTxSize  uint64 // MaxTxSize is the maximum allowed transaction size in bytes.
 	TestMode   bool   // TestMode allows skipping UTXO validation for testing
-}
+
+	// MaxAncestorDepth is the maximum number of unconfirmed generations a
+	// transaction may build on. Zero selects DefaultMaxAncestorDepth.
+	MaxAncestorDepth int
+	// MaxAncestors is the maximum number of unconfirmed ancestors a
+	// transaction may have, itself included. Zero selects
+	// DefaultMaxAncestors.
+	MaxAncestors int
+	// MaxDescendants is the maximum number of in-mempool descendants a
+	// transaction may have, itself included; a transaction that would take
+	// one of its ancestors past it is rejected. Zero selects
+	// DefaultMaxDescendants.
+	MaxDescendants int
+
+	// FreeTxMinPriority is the coin-age priority (value times confirmations
+	// per byte) at which a transaction paying less than MinFeeRate is still
+	// accepted. Zero disables free relay.
+	FreeTxMinPriority uint64
+
+	// ReorgRetention is how long the entries of confirmed transactions are
+	// kept so that a reorg disconnecting their block can return them to the
+	// mempool without validating them again. Zero disables retention.
+	ReorgRetention time.Duration
+
+	// EnforceSequenceLocks rejects transactions with an input whose
+	// relative lock-time (BIP68) would not be met in the next block.
+	EnforceSequenceLocks bool
+
+	// RebroadcastInterval is how long a local transaction stays
+	// unconfirmed before it is sent to peers again. Zero disables
+	// rebroadcasting.
+	RebroadcastInterval time.Duration
+	// MaxRebroadcasts is the number of times a local transaction is
+	// rebroadcast. Zero selects DefaultMaxRebroadcasts.
+	MaxRebroadcasts int
+	// DiffusionDelayRange delays the first broadcast of a local
+	// transaction by a random duration in the range, so that the node
+	// cannot be identified as its origin by relaying it first. A zero
+	// range disables diffusion.
+	DiffusionDelayRange DelayRange
+
+	// WeightAccounting measures transactions by their virtual size
+	// (TransactionVSize) instead of their size in bytes, so that MaxSize,
+	// MaxTxSize, MinFeeRate and fee rate ordering discount signature data.
+	WeightAccounting bool
+
+	// ScriptLimits bounds the total size of the scripts of a relayed
+	// transaction, a standardness rule stricter than consensus.
+	ScriptLimits ScriptSizeLimits
+}
+
+// DefaultMaxAncestorDepth is the default limit on chains of unconfirmed
+// transactions accepted into the mempool.
+const DefaultMaxAncestorDepth = 25
+
+const (
+	// DefaultMaxAncestors is the default limit on the unconfirmed ancestors
+	// of a mempool transaction.
+	DefaultMaxAncestors = 25
+	// DefaultMaxDescendants is the default limit on the in-mempool
+	// descendants of a mempool transaction.
+	DefaultMaxDescendants = 25
+)
 
 // DefaultMempoolConfig returns the default mempool configuration.
 func DefaultMempoolConfig() *MempoolConfig {
@@ -53,9 +182,58 @@ func DefaultMempoolConfig() *MempoolConfig {
 		MinFeeRate: 1,      // 1 unit per byte
 		MaxTxSize:  100000, // 100KB max transaction size
 		TestMode:   false,  // Production mode by default
+
+		MaxAncestorDepth:     DefaultMaxAncestorDepth,
+		MaxAncestors:         DefaultMaxAncestors,
+		MaxDescendants:       DefaultMaxDescendants,
+		ReorgRetention:       DefaultReorgRetention,
+		EnforceSequenceLocks: true,
+		RebroadcastInterval:  DefaultRebroadcastInterval,
+		MaxRebroadcasts:      DefaultMaxRebroadcasts,
+		ScriptLimits:         DefaultScriptSizeLimits,
 	}
 }
 
+// Validate checks that the configuration is usable and returns every
+// problem found.
+func (mc *MempoolConfig) Validate() error {
+	var errs []error
+	if mc.MaxSize == 0 {
+		errs = append(errs, fmt.Errorf("mempool: max size must be positive"))
+	}
+	if mc.MaxTxSize == 0 {
+		errs = append(errs, fmt.Errorf("mempool: max transaction size must be positive"))
+	}
+	if mc.MaxTxSize > mc.MaxSize {
+		errs = append(errs, fmt.Errorf("mempool: max transaction size %d exceeds max size %d", mc.MaxTxSize, mc.MaxSize))
+	}
+	if mc.MinFeeRate == 0 && !mc.TestMode {
+		errs = append(errs, fmt.Errorf("mempool: min fee rate must be positive"))
+	}
+	if mc.MaxAncestorDepth < 0 {
+		errs = append(errs, fmt.Errorf("mempool: max ancestor depth %d is negative", mc.MaxAncestorDepth))
+	}
+	if mc.MaxAncestors < 0 {
+		errs = append(errs, fmt.Errorf("mempool: max ancestors %d is negative", mc.MaxAncestors))
+	}
+	if mc.MaxDescendants < 0 {
+		errs = append(errs, fmt.Errorf("mempool: max descendants %d is negative", mc.MaxDescendants))
+	}
+	if mc.ReorgRetention < 0 {
+		errs = append(errs, fmt.Errorf("mempool: reorg retention must not be negative"))
+	}
+	if mc.RebroadcastInterval < 0 {
+		errs = append(errs, fmt.Errorf("mempool: rebroadcast interval must not be negative"))
+	}
+	if mc.MaxRebroadcasts < 0 {
+		errs = append(errs, fmt.Errorf("mempool: max rebroadcasts %d is negative", mc.MaxRebroadcasts))
+	}
+	if err := mc.DiffusionDelayRange.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("mempool: diffusion delay range: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
 // TestMempoolConfig returns a mempool configuration suitable for testing.
 // It enables test mode to skip UTXO validation and uses smaller limits.
 func TestMempoolConfig() *MempoolConfig {
@@ -64,6 +242,10 @@ func TestMempoolConfig() *MempoolConfig {
 		MinFeeRate: 1,     // Minimum fee rate of 1 per byte for testing (accounts for default validation)
 		MaxTxSize:  10000, // 10KB max transaction size for testing
 		TestMode:   true,  // Test mode enabled
+
+		MaxAncestorDepth: DefaultMaxAncestorDepth,
+		MaxAncestors:     DefaultMaxAncestors,
+		MaxDescendants:   DefaultMaxDescendants,
 	}
 }
 
@@ -79,6 +261,37 @@ func NewMempool(config *MempoolConfig) *Mempool {
 		maxTxSize:    config.MaxTxSize,
 		utxoSet:      utxo.NewUTXOSet(),
 		testMode:     config.TestMode,
+
+		maxAncestorDepth: config.MaxAncestorDepth,
+		maxAncestors:     config.MaxAncestors,
+		maxDescendants:   config.MaxDescendants,
+
+		freeTxMinPriority: config.FreeTxMinPriority,
+
+		enforceSequenceLocks: config.EnforceSequenceLocks,
+		weightAccounting:     config.WeightAccounting,
+		scriptLimits:         config.ScriptLimits,
+
+		reorgRetention: config.ReorgRetention,
+		retained:       make(map[string]*retainedTx),
+		now:            time.Now,
+
+		rebroadcastInterval: config.RebroadcastInterval,
+		maxRebroadcasts:     config.MaxRebroadcasts,
+		diffusionDelay:      config.DiffusionDelayRange,
+		localAdded:          make(chan struct{}, 1),
+	}
+	if mp.maxAncestorDepth <= 0 {
+		mp.maxAncestorDepth = DefaultMaxAncestorDepth
+	}
+	if mp.maxAncestors <= 0 {
+		mp.maxAncestors = DefaultMaxAncestors
+	}
+	if mp.maxDescendants <= 0 {
+		mp.maxDescendants = DefaultMaxDescendants
+	}
+	if mp.maxRebroadcasts <= 0 {
+		mp.maxRebroadcasts = DefaultMaxRebroadcasts
 	}
 
 	heap.Init(mp.byFee)
@@ -87,7 +300,11 @@ func NewMempool(config *MempoolConfig) *Mempool {
 	return mp
 }
 
-// SetUTXOSet sets the UTXO set for transaction validation
+// SetUTXOSet sets the UTXO set for transaction validation. It must be the
+// confirmed UTXO set of the best chain, which only changes once a block is
+// connected: transactions are validated against confirmed outputs and the
+// outputs of pooled transactions, never against outputs of blocks that are
+// still being validated or were rejected.
 func (mp *Mempool) SetUTXOSet(utxoSet *utxo.UTXOSet) {
 	mp.mu.Lock()
 	defer mp.mu.Unlock()
@@ -96,19 +313,35 @@ func (mp *Mempool) SetUTXOSet(utxoSet *utxo.UTXOSet) {
 
 // AddTransaction adds a transaction to the mempool.
 // It validates the transaction, calculates its fee rate, and adds it to the internal data structures.
-// If the mempool is full, it attempts to evict lower-fee transactions.
+// If the mempool is full, it attempts to evict lower-fee transactions; a
+// transaction paying less than the eviction floor (GetLowestFeeRate) is
+// rejected with ErrInsufficientFee.
 func (mp *Mempool) AddTransaction(tx *block.Transaction) error {
+	return mp.addTransaction(tx, true)
+}
+
+// AddWhitelistedTransaction adds a transaction relayed by a trusted peer. It
+// is validated like AddTransaction except for relay policy: the size limit,
+// unconfirmed ancestor depth, fee rate and dust checks are skipped.
+func (mp *Mempool) AddWhitelistedTransaction(tx *block.Transaction) error {
+	return mp.addTransaction(tx, false)
+}
+
+// addTransaction adds a transaction to the mempool, enforcing relay policy
+// only if policy is set.
+func (mp *Mempool) addTransaction(tx *block.Transaction, policy bool) error {
 	mp.mu.Lock()
 	defer mp.mu.Unlock()
 
 	// Check if transaction already exists
 	txHash := string(tx.Hash)
 	if _, exists := mp.transactions[txHash]; exists {
-		return fmt.Errorf("transaction already in mempool")
+		return ErrAlreadyInMempool
 	}
 
 	// Use the dedicated validation method instead of duplicating logic
-	if err := mp.IsTransactionValid(tx); err != nil {
+	mp.validations++
+	if err := mp.validateTransaction(tx, policy); err != nil {
 		return fmt.Errorf("transaction validation failed: %w", err)
 	}
 
@@ -118,36 +351,59 @@ func (mp *Mempool) AddTransaction(tx *block.Transaction) error {
 	// Calculate fee rate for mempool management
 	feeRate := mp.calculateFeeRate(tx, size)
 
-	// Check if adding this transaction would exceed mempool size
-	if mp.currentSize+size > mp.maxSize {
-		// Try to evict low-fee transactions to make room
-		if !mp.evictLowFeeTransactions(size) {
-			return fmt.Errorf("mempool full and cannot evict enough transactions")
-		}
-	}
-
 	// Create transaction entry
 	entry := &TransactionEntry{
 		Transaction: tx,
 		FeeRate:     feeRate,
 		Size:        size,
 		Timestamp:   time.Now(),
+		free:        feeRate < mp.minFeeRate,
+		entryHeight: mp.chainHeight,
+	}
+	entry.confirmedValue, entry.valueHeightSum = mp.coinAgeInputs(tx)
+
+	return mp.insertEntry(entry)
+}
+
+// insertEntry adds a validated entry to the mempool, evicting low-fee
+// transactions if it is full.
+// Note: the caller must hold the mempool lock.
+func (mp *Mempool) insertEntry(entry *TransactionEntry) error {
+	// Check if adding this transaction would exceed mempool size
+	if mp.currentSize+entry.Size > mp.maxSize {
+		// Only a transaction paying at least the eviction floor displaces
+		// others
+		if floor := mp.lowestFeeRateLocked(); entry.FeeRate < floor {
+			return fmt.Errorf("%w: mempool full, fee rate %d below eviction floor %d", ErrInsufficientFee, entry.FeeRate, floor)
+		}
+		// Try to evict low-fee transactions to make room
+		if !mp.evictLowFeeTransactions(entry) {
+			return fmt.Errorf("mempool full and cannot evict enough transactions")
+		}
 	}
 
 	// Add to mempool
-	mp.transactions[txHash] = entry
-	mp.currentSize += size
+	mp.linkEntry(entry)
+	mp.transactions[string(entry.Transaction.Hash)] = entry
+	mp.currentSize += entry.Size
 
 	// Add to priority queues
 	heap.Push(mp.byFee, entry)
 	heap.Push(mp.byTime, entry)
 
+	mp.publishAccepted(entry)
 	return nil
 }
 
 // RemoveTransaction removes a transaction from the mempool given its hash.
 // It returns true if the transaction was found and removed, false otherwise.
 func (mp *Mempool) RemoveTransaction(txHash []byte) bool {
+	return mp.removeTransaction(txHash, EvictionRemoved)
+}
+
+// removeTransaction removes a transaction from the mempool, reporting
+// reason to acceptance subscribers.
+func (mp *Mempool) removeTransaction(txHash []byte, reason EvictionReason) bool {
 	mp.mu.Lock()
 	defer mp.mu.Unlock()
 
@@ -157,9 +413,18 @@ func (mp *Mempool) RemoveTransaction(txHash []byte) bool {
 		return false
 	}
 
+	mp.dropEntry(entry, reason)
+	return true
+}
+
+// dropEntry removes an entry from the mempool and its queues, reporting
+// reason to acceptance subscribers.
+// Note: the caller must hold the mempool lock.
+func (mp *Mempool) dropEntry(entry *TransactionEntry, reason EvictionReason) {
 	// Remove from maps and queues
-	delete(mp.transactions, hash)
+	delete(mp.transactions, string(entry.Transaction.Hash))
 	mp.currentSize -= entry.Size
+	mp.unlinkEntry(entry)
 
 	// Remove from fee queue
 	mp.byFee.Remove(entry)
@@ -167,7 +432,7 @@ func (mp *Mempool) RemoveTransaction(txHash []byte) bool {
 	// Remove from time queue
 	mp.byTime.Remove(entry)
 
-	return true
+	mp.publishEvicted(entry, reason)
 }
 
 // GetTransaction returns a transaction from the mempool by its hash.
@@ -193,24 +458,20 @@ func (mp *Mempool) GetTransactionsForBlock(maxSize uint64) []*block.Transaction
 	var transactions []*block.Transaction
 	currentSize := uint64(0)
 
-	// Create a copy of the fee queue to avoid modifying the original
-	feeQueue := make(TransactionHeapMin, mp.byFee.Len())
-	copy(feeQueue, *mp.byFee)
-
-	// Since TransactionHeapMin is a min-heap (lowest fee rate first),
-	// we need to collect all transactions first, then reverse the order
-	// to get highest fee rate first
+	// Collect the transactions lowest fee rate first, then reverse the
+	// order to get highest fee rate first
 	var tempTransactions []*TransactionEntry
-	
-	// Collect all transactions from the min-heap
-	for feeQueue.Len() > 0 {
-		entry := heap.Pop(&feeQueue).(*TransactionEntry)
-
+	for _, entry := range mp.sortedByFeeRate() {
 		// Check if transaction still exists in mempool
 		if _, exists := mp.transactions[string(entry.Transaction.Hash)]; !exists {
 			continue
 		}
 
+		// Free transactions are selected separately by priority
+		if entry.free {
+			continue
+		}
+
 		tempTransactions = append(tempTransactions, entry)
 	}
 
@@ -261,50 +522,11 @@ func (mp *Mempool) Clear() {
 	heap.Init(mp.byTime)
 }
 
-// evictLowFeeTransactions evicts low-fee transactions to make room for new ones
-// evictLowFeeTransactions evicts transactions with the lowest fee rates to free up space in the mempool.
-// It continues to evict until the requiredSize is met or no more transactions can be evicted.
-func (mp *Mempool) evictLowFeeTransactions(requiredSize uint64) bool {
-	evictedSize := uint64(0)
-
-	// Evict transactions by lowest fee rate first
-	for mp.byFee.Len() > 0 && evictedSize < requiredSize {
-		entry := heap.Pop(mp.byFee).(*TransactionEntry)
-
-		// Remove from mempool
-		delete(mp.transactions, string(entry.Transaction.Hash))
-		mp.currentSize -= entry.Size
-		evictedSize += entry.Size
-
-		// Remove from time queue
-		mp.byTime.Remove(entry)
-	}
-
-	return evictedSize >= requiredSize
-}
-
 // calculateTransactionSize calculates the size of a transaction
-// calculateTransactionSize calculates the approximate size of a transaction in bytes.
+// calculateTransactionSize calculates the approximate size of a transaction,
+// in virtual bytes under weight accounting and in bytes otherwise.
 func (mp *Mempool) calculateTransactionSize(tx *block.Transaction) uint64 {
-	size := uint64(0)
-
-	// Version + LockTime + Fee
-	size += 4 + 8 + 8
-
-	// Input count + Output count
-	size += 4 + 4
-
-	// Inputs
-	for _, input := range tx.Inputs {
-		size += 32 + 4 + uint64(len(input.ScriptSig)) + 4
-	}
-
-	// Outputs
-	for _, output := range tx.Outputs {
-		size += 8 + uint64(len(output.ScriptPubKey))
-	}
-
-	return size
+	return accountedSize(tx, mp.weightAccounting)
 }
 
 // calculateFeeRate calculates the fee rate (fee per byte) of a transaction
@@ -316,20 +538,23 @@ func (mp *Mempool) calculateFeeRate(tx *block.Transaction, size uint64) uint64 {
 	return tx.Fee / size
 }
 
+// checkDustOutputs rejects transactions creating outputs below the dust threshold.
+func (mp *Mempool) checkDustOutputs(tx *block.Transaction) error {
+	return checkDust(tx)
+}
+
 // validateFeeRate performs comprehensive fee rate validation with enhanced security features
 func (mp *Mempool) validateFeeRate(tx *block.Transaction, feeRate uint64) error {
 	// Check for dust transactions (very low value outputs)
-	for i, output := range tx.Outputs {
-		if output.Value < 546 { // Standard dust threshold (546 satoshis)
-			return fmt.Errorf("output %d value %d below dust threshold", i, output.Value)
-		}
+	if err := mp.checkDustOutputs(tx); err != nil {
+		return err
 	}
 
 	// Enhanced fee rate validation with dynamic thresholds
 	if mp.minFeeRate > 0 {
 		// Check minimum fee rate
 		if feeRate < mp.minFeeRate {
-			return fmt.Errorf("fee rate %d below minimum %d", feeRate, mp.minFeeRate)
+			return fmt.Errorf("%w: fee rate %d below minimum %d", ErrInsufficientFee, feeRate, mp.minFeeRate)
 		}
 
 		// Add absolute maximum fee rate limit regardless of utilization
@@ -376,17 +601,18 @@ func (mp *Mempool) validateFeeRate(tx *block.Transaction, feeRate uint64) error
 	}
 
 	if tx.Fee < txSize*minFeePerByte {
-		return fmt.Errorf("fee %d is too low for transaction size %d (minimum: %d)",
-			tx.Fee, txSize, txSize*minFeePerByte)
+		return fmt.Errorf("%w: fee %d is too low for transaction size %d (minimum: %d)",
+			ErrInsufficientFee, tx.Fee, txSize, txSize*minFeePerByte)
 	}
 
 	// Check for suspicious fee patterns
 	if len(tx.Inputs) > 0 && len(tx.Outputs) > 0 {
 		// Calculate total input value from UTXO set if available
 		if mp.utxoSet != nil && !mp.testMode {
+			view := mp.utxoView()
 			totalInput := uint64(0)
 			for _, input := range tx.Inputs {
-				utxo := mp.utxoSet.GetUTXO(input.PrevTxHash, input.PrevTxIndex)
+				utxo := view.GetUTXO(input.PrevTxHash, input.PrevTxIndex)
 				if utxo != nil {
 					totalInput += utxo.Value
 				}
@@ -466,14 +692,14 @@ func (h TransactionHeapMin) Less(i, j int) bool {
 
 func (h TransactionHeapMin) Swap(i, j int) {
 	h[i], h[j] = h[j], h[i]
-	h[i].index = i
-	h[j].index = j
+	h[i].feeIndex = i
+	h[j].feeIndex = j
 }
 
 func (h *TransactionHeapMin) Push(x interface{}) {
 	n := len(*h)
 	entry := x.(*TransactionEntry)
-	entry.index = n
+	entry.feeIndex = n
 	*h = append(*h, entry)
 }
 
@@ -481,16 +707,16 @@ func (h *TransactionHeapMin) Pop() interface{} {
 	old := *h
 	n := len(old)
 	entry := old[n-1]
-	old[n-1] = nil   // avoid memory leak
-	entry.index = -1 // for safety
+	old[n-1] = nil      // avoid memory leak
+	entry.feeIndex = -1 // for safety
 	*h = old[0 : n-1]
 	return entry
 }
 
 // Remove removes a TransactionEntry from the TransactionHeapMin.
 func (h *TransactionHeapMin) Remove(entry *TransactionEntry) {
-	if entry.index >= 0 && entry.index < h.Len() {
-		heap.Remove(h, entry.index)
+	if entry.feeIndex >= 0 && entry.feeIndex < h.Len() {
+		heap.Remove(h, entry.feeIndex)
 	}
 }
 
@@ -530,6 +756,13 @@ func (h *TimeHeap) Pop() interface{} {
 // IsTransactionValid validates a transaction for inclusion in the mempool.
 // It performs comprehensive validation including signature verification, UTXO checks, and fee validation.
 func (mp *Mempool) IsTransactionValid(tx *block.Transaction) error {
+	return mp.validateTransaction(tx, true)
+}
+
+// validateTransaction validates a transaction for inclusion in the mempool.
+// Relay policy checks, which a valid block may violate, are only applied if
+// policy is set.
+func (mp *Mempool) validateTransaction(tx *block.Transaction, policy bool) error {
 	// Basic transaction structure validation
 	if err := tx.IsValid(); err != nil {
 		return fmt.Errorf("invalid transaction structure: %w", err)
@@ -537,7 +770,7 @@ func (mp *Mempool) IsTransactionValid(tx *block.Transaction) error {
 
 	// Check transaction size limits
 	size := mp.calculateTransactionSize(tx)
-	if size > mp.maxTxSize {
+	if policy && size > mp.maxTxSize {
 		return fmt.Errorf("transaction size %d exceeds maximum allowed size %d", size, mp.maxTxSize)
 	}
 
@@ -546,48 +779,73 @@ func (mp *Mempool) IsTransactionValid(tx *block.Transaction) error {
 		return fmt.Errorf("security validation failed: %w", err)
 	}
 
-	// Enhanced UTXO validation with signature verification
+	// Data outputs are limited by consensus, so this applies without policy
+	if err := tx.CheckDataOutputs(); err != nil {
+		return fmt.Errorf("transaction validation failed: %w", err)
+	}
+
+	// Check if UTXO is already spent in mempool (even in test mode)
+	// This check should always run to maintain mempool consistency
+	if !tx.IsCoinbase() {
+		for i, input := range tx.Inputs {
+			if mp.isUTXOSpentInMempool(input.PrevTxHash, input.PrevTxIndex) {
+				return fmt.Errorf("%w: input %d references UTXO already spent in mempool", ErrDoubleSpend, i)
+			}
+		}
+	}
+
+	// Enhanced UTXO validation with signature verification. Inputs are
+	// resolved against the chain UTXO set with mempool effects applied, so
+	// outputs of unconfirmed transactions can be spent.
 	if mp.utxoSet != nil && !mp.testMode {
-		if err := mp.utxoSet.ValidateTransaction(tx); err != nil {
+		view := mp.utxoView()
+		if err := mp.utxoSet.ValidateTransactionWithView(tx, view); err != nil {
 			return fmt.Errorf("transaction validation failed: %w", err)
 		}
 
-		// Additional security checks for non-coinbase transactions
+		// Validate that all inputs reference existing outputs
 		if !tx.IsCoinbase() {
-			// Check for double-spend attempts
-			if mp.utxoSet.IsDoubleSpend(tx) {
-				return fmt.Errorf("transaction attempts double-spend")
-			}
-
-			// Validate that all inputs reference existing UTXOs
 			for i, input := range tx.Inputs {
-				utxo := mp.utxoSet.GetUTXO(input.PrevTxHash, input.PrevTxIndex)
-				if utxo == nil {
+				if view.GetUTXO(input.PrevTxHash, input.PrevTxIndex) == nil {
 					return fmt.Errorf("input %d references non-existent UTXO", i)
 				}
-
-				// Check if UTXO is already spent in mempool
-				if mp.isUTXOSpentInMempool(input.PrevTxHash, input.PrevTxIndex) {
-					return fmt.Errorf("input %d references UTXO already spent in mempool", i)
-				}
 			}
 		}
-	}
 
-	// Check if UTXO is already spent in mempool (even in test mode)
-	// This check should always run to maintain mempool consistency
-	if !tx.IsCoinbase() {
-		for i, input := range tx.Inputs {
-			if mp.isUTXOSpentInMempool(input.PrevTxHash, input.PrevTxIndex) {
-				return fmt.Errorf("input %d references UTXO already spent in mempool", i)
+		// Only accept transactions that could be mined in the next block
+		if mp.enforceSequenceLocks {
+			if err := utxo.CheckSequenceLocks(tx, view, mp.chainHeight+1, mp.blockTime); err != nil {
+				return fmt.Errorf("transaction validation failed: %w", err)
 			}
 		}
 	}
 
-	// Enhanced fee rate validation (do this AFTER security validation)
+	if !policy {
+		return nil
+	}
+
+	// Pathologically large scripts are valid but not relayed
+	if err := mp.scriptLimits.check(tx); err != nil {
+		return err
+	}
+
+	// Limit how long a chain of unconfirmed transactions may grow
+	if depth := mp.chainDepth(tx); depth > mp.maxAncestorDepth {
+		return fmt.Errorf("transaction has %d unconfirmed ancestor generations (max: %d)", depth, mp.maxAncestorDepth)
+	}
+	if err := mp.checkRelativeLimits(tx); err != nil {
+		return err
+	}
+
+	// Enhanced fee rate validation (do this AFTER security validation).
+	// Transactions below the minimum fee rate may still be relayed for free
+	// if they spend old, high-value coins.
 	feeRate := mp.calculateFeeRate(tx, size)
+	if feeRate < mp.minFeeRate && mp.qualifiesForFreeRelay(tx, size) {
+		return mp.checkDustOutputs(tx)
+	}
 	if feeRate < mp.minFeeRate {
-		return fmt.Errorf("fee rate %d below minimum %d", feeRate, mp.minFeeRate)
+		return fmt.Errorf("%w: fee rate %d below minimum %d", ErrInsufficientFee, feeRate, mp.minFeeRate)
 	}
 
 	if err := mp.validateFeeRate(tx, feeRate); err != nil {
@@ -614,12 +872,8 @@ func (mp *Mempool) isUTXOSpentInMempool(txHash []byte, txIndex uint32) bool {
 // validateTransactionSecurity performs additional security validations
 func (mp *Mempool) validateTransactionSecurity(tx *block.Transaction) error {
 	// Check for excessive input/output counts (DoS prevention)
-	if len(tx.Inputs) > 1000 {
-		return fmt.Errorf("transaction has too many inputs: %d (max: 1000)", len(tx.Inputs))
-	}
-
-	if len(tx.Outputs) > 1000 {
-		return fmt.Errorf("transaction has too many outputs: %d (max: 1000)", len(tx.Outputs))
+	if err := checkInputOutputCounts(tx); err != nil {
+		return err
 	}
 
 	// Check for suspicious transaction patterns
@@ -676,6 +930,8 @@ func (mp *Mempool) GetTransactionStats() map[string]interface{} {
 		"avg_fee_rate":      avgFeeRate,
 		"total_fees":        totalFee,
 		"utilization":       utilization,
+		"validations":       mp.validations,
+		"retained":          len(mp.retained),
 	}
 }
 
@@ -715,8 +971,10 @@ func (mp *Mempool) CleanupExpiredTransactions(maxAge time.Duration) int {
 			// Remove expired transaction
 			delete(mp.transactions, hash)
 			mp.currentSize -= entry.Size
+			mp.unlinkEntry(entry)
 			mp.byFee.Remove(entry)
 			mp.byTime.Remove(entry)
+			mp.publishEvicted(entry, EvictionExpired)
 			removed++
 		}
 	}
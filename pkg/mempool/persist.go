@@ -0,0 +1,137 @@
+package mempool
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+)
+
+// MempoolFileVersion is the version of the mempool file format written by
+// Save. Load only accepts files of this version.
+const MempoolFileVersion = 1
+
+// ErrIncompatibleMempoolFile is returned by Load for mempool files that are
+// unreadable or written in another format version.
+var ErrIncompatibleMempoolFile = errors.New("incompatible mempool file")
+
+// mempoolFile is the on-disk format of a saved mempool.
+type mempoolFile struct {
+	Version      int              `json:"version"`
+	SavedAt      time.Time        `json:"saved_at"`
+	Transactions []mempoolFileTxn `json:"transactions"`
+}
+
+// mempoolFileTxn is a saved transaction, hex encoded as by Transaction.Hex,
+// with the time it entered the mempool.
+type mempoolFileTxn struct {
+	Hex     string    `json:"hex"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// Save writes the transactions in the mempool to path, parents before the
+// transactions spending them. The file is replaced atomically.
+func (mp *Mempool) Save(path string) error {
+	mp.mu.RLock()
+	entries := make([]*TransactionEntry, 0, len(mp.transactions))
+	for _, entry := range mp.transactions {
+		entries = append(entries, entry)
+	}
+	mp.mu.RUnlock()
+
+	// An entry has more ancestors than any of its parents, so ordering by
+	// ancestor count puts parents first
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].AncestorCount != entries[j].AncestorCount {
+			return entries[i].AncestorCount < entries[j].AncestorCount
+		}
+		return entries[i].Timestamp.Before(entries[j].Timestamp)
+	})
+
+	file := mempoolFile{Version: MempoolFileVersion, SavedAt: time.Now()}
+	for _, entry := range entries {
+		raw, err := entry.Transaction.Hex()
+		if err != nil {
+			return fmt.Errorf("failed to encode transaction %x: %w", entry.Transaction.Hash, err)
+		}
+		file.Transactions = append(file.Transactions, mempoolFileTxn{Hex: raw, AddedAt: entry.Timestamp})
+	}
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("failed to encode mempool: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to write mempool: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write mempool: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write mempool: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write mempool: %w", err)
+	}
+	return nil
+}
+
+// Load adds the transactions saved in path to the mempool and returns how
+// many were accepted. Transactions no longer valid, for example because
+// they were confirmed while the node was down, are skipped. A missing file
+// loads nothing. A file that cannot be decoded or has another format
+// version is removed, so the node starts with an empty mempool instead of
+// failing, and ErrIncompatibleMempoolFile is returned.
+func (mp *Mempool) Load(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read mempool: %w", err)
+	}
+
+	var file mempoolFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return 0, mp.dropFile(path, fmt.Errorf("%w: %v", ErrIncompatibleMempoolFile, err))
+	}
+	if file.Version != MempoolFileVersion {
+		return 0, mp.dropFile(path, fmt.Errorf("%w: version %d, expected %d",
+			ErrIncompatibleMempoolFile, file.Version, MempoolFileVersion))
+	}
+
+	loaded := 0
+	for _, saved := range file.Transactions {
+		tx, err := block.DecodeTransactionHex(saved.Hex)
+		if err != nil {
+			continue
+		}
+		if err := mp.AddTransaction(tx); err != nil {
+			continue
+		}
+		mp.mu.Lock()
+		if entry, exists := mp.transactions[string(tx.Hash)]; exists && !saved.AddedAt.IsZero() {
+			entry.Timestamp = saved.AddedAt
+		}
+		mp.mu.Unlock()
+		loaded++
+	}
+	return loaded, nil
+}
+
+// dropFile removes an incompatible mempool file and returns reason, with
+// any failure to remove it.
+func (mp *Mempool) dropFile(path string, reason error) error {
+	if err := os.Remove(path); err != nil {
+		return errors.Join(reason, fmt.Errorf("failed to remove mempool file: %w", err))
+	}
+	return reason
+}
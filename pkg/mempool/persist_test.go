@@ -0,0 +1,84 @@
+package mempool
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/crypto_utils"
+	"github.com/palaseus/adrenochain/pkg/utxo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMempoolSaveLoad(t *testing.T) {
+	ctu := crypto_utils.NewCryptoTestUtils(t)
+	kp := ctu.GenerateTestKeyPair()
+	script, err := hex.DecodeString(kp.Address)
+	require.NoError(t, err)
+
+	utxoSet := utxo.NewUTXOSet()
+	confirmed := &utxo.UTXO{
+		TxHash:       bytes.Repeat([]byte{0xaa}, 32),
+		Value:        100000,
+		ScriptPubKey: script,
+		Address:      kp.Address,
+		Height:       1,
+	}
+	utxoSet.AddUTXO(confirmed)
+
+	mp := NewMempool(DefaultMempoolConfig())
+	mp.SetUTXOSet(utxoSet)
+	parent := spendOutput(ctu, kp, confirmed.TxHash, 100000, 1000)
+	child := spendOutput(ctu, kp, parent.Hash, 99000, 1500)
+	require.NoError(t, mp.AddTransaction(parent))
+	require.NoError(t, mp.AddTransaction(child))
+
+	path := filepath.Join(t.TempDir(), "mempool.json")
+	require.NoError(t, mp.Save(path))
+
+	t.Run("round trip", func(t *testing.T) {
+		restored := NewMempool(DefaultMempoolConfig())
+		restored.SetUTXOSet(utxoSet)
+		loaded, err := restored.Load(path)
+		require.NoError(t, err)
+		assert.Equal(t, 2, loaded)
+		assert.NotNil(t, restored.GetTransaction(parent.Hash))
+		assert.NotNil(t, restored.GetTransaction(child.Hash))
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		restored := NewMempool(DefaultMempoolConfig())
+		loaded, err := restored.Load(filepath.Join(t.TempDir(), "mempool.json"))
+		require.NoError(t, err)
+		assert.Zero(t, loaded)
+	})
+
+	t.Run("unknown version", func(t *testing.T) {
+		future := filepath.Join(t.TempDir(), "mempool.json")
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		data = bytes.Replace(data, []byte(`"version":1`), []byte(`"version":99`), 1)
+		require.NoError(t, os.WriteFile(future, data, 0644))
+
+		restored := NewMempool(DefaultMempoolConfig())
+		restored.SetUTXOSet(utxoSet)
+		loaded, err := restored.Load(future)
+		assert.ErrorIs(t, err, ErrIncompatibleMempoolFile)
+		assert.Zero(t, loaded)
+		assert.Zero(t, restored.GetTransactionCount())
+		assert.NoFileExists(t, future)
+	})
+
+	t.Run("malformed file", func(t *testing.T) {
+		broken := filepath.Join(t.TempDir(), "mempool.json")
+		require.NoError(t, os.WriteFile(broken, []byte("{not json"), 0644))
+
+		loaded, err := NewMempool(DefaultMempoolConfig()).Load(broken)
+		assert.ErrorIs(t, err, ErrIncompatibleMempoolFile)
+		assert.Zero(t, loaded)
+		assert.NoFileExists(t, broken)
+	})
+}
@@ -0,0 +1,185 @@
+package mempool
+
+import (
+	"fmt"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+)
+
+const (
+	// DustThreshold is the smallest output value relayed; smaller outputs
+	// cost more to spend than they are worth.
+	DustThreshold = 546
+	// maxTxInputs and maxTxOutputs bound the inputs and outputs of a relayed
+	// transaction.
+	maxTxInputs  = 1000
+	maxTxOutputs = 1000
+	// WitnessScaleFactor is how many times more a byte of a transaction
+	// weighs than a byte of its input scripts, which carry the signatures
+	// a witness would hold.
+	WitnessScaleFactor = 4
+)
+
+// RelayPolicy holds the rules on the shape and fee of a transaction the
+// mempool applies before accepting it. Wallets check the transactions they
+// build against the same policy so that they are not rejected after
+// broadcast.
+type RelayPolicy struct {
+	MaxTxSize        uint64           // MaxTxSize is the largest transaction size in bytes.
+	MinFeeRate       uint64           // MinFeeRate is the smallest fee per byte.
+	WeightAccounting bool             // WeightAccounting measures sizes in virtual bytes instead of bytes.
+	ScriptLimits     ScriptSizeLimits // ScriptLimits bounds the total script sizes.
+}
+
+// ScriptSizeLimits bounds the total size of the scripts of a transaction.
+// Consensus only bounds each scriptSig, so these standardness limits keep
+// transactions with pathologically large scripts from being relayed.
+type ScriptSizeLimits struct {
+	// MaxScriptSigSize is the largest total size of the input scripts in
+	// bytes. Zero disables the limit.
+	MaxScriptSigSize uint64
+	// MaxScriptPubKeySize is the largest total size of the output scripts
+	// in bytes. Zero disables the limit.
+	MaxScriptPubKeySize uint64
+}
+
+// DefaultScriptSizeLimits are the default standardness script-size limits.
+var DefaultScriptSizeLimits = ScriptSizeLimits{
+	MaxScriptSigSize:    50000,
+	MaxScriptPubKeySize: 25000,
+}
+
+// check rejects a transaction whose scripts exceed the limits.
+func (l ScriptSizeLimits) check(tx *block.Transaction) error {
+	if l.MaxScriptSigSize > 0 {
+		var size uint64
+		for _, input := range tx.Inputs {
+			size += uint64(len(input.ScriptSig))
+		}
+		if size > l.MaxScriptSigSize {
+			return fmt.Errorf("total scriptSig size %d exceeds standard maximum %d", size, l.MaxScriptSigSize)
+		}
+	}
+	if l.MaxScriptPubKeySize > 0 {
+		var size uint64
+		for _, output := range tx.Outputs {
+			size += uint64(len(output.ScriptPubKey))
+		}
+		if size > l.MaxScriptPubKeySize {
+			return fmt.Errorf("total scriptPubKey size %d exceeds standard maximum %d", size, l.MaxScriptPubKeySize)
+		}
+	}
+	return nil
+}
+
+// RelayPolicy returns the relay policy of a mempool with this
+// configuration.
+func (mc *MempoolConfig) RelayPolicy() RelayPolicy {
+	return RelayPolicy{
+		MaxTxSize:        mc.MaxTxSize,
+		MinFeeRate:       mc.MinFeeRate,
+		WeightAccounting: mc.WeightAccounting,
+		ScriptLimits:     mc.ScriptLimits,
+	}
+}
+
+// Check reports the first rule a transaction breaks: its size, its number
+// of inputs or outputs, its script sizes, an oversized data output, a dust
+// output or a fee rate below the minimum.
+func (p RelayPolicy) Check(tx *block.Transaction) error {
+	size := accountedSize(tx, p.WeightAccounting)
+	if size > p.MaxTxSize {
+		return fmt.Errorf("transaction size %d exceeds maximum allowed size %d", size, p.MaxTxSize)
+	}
+	if err := checkInputOutputCounts(tx); err != nil {
+		return err
+	}
+	if err := p.ScriptLimits.check(tx); err != nil {
+		return err
+	}
+	if err := tx.CheckDataOutputs(); err != nil {
+		return err
+	}
+	if err := checkDust(tx); err != nil {
+		return err
+	}
+	if feeRate := tx.Fee / size; feeRate < p.MinFeeRate {
+		return fmt.Errorf("%w: fee rate %d below minimum %d", ErrInsufficientFee, feeRate, p.MinFeeRate)
+	}
+	return nil
+}
+
+// checkInputOutputCounts rejects transactions with excessive inputs or
+// outputs.
+func checkInputOutputCounts(tx *block.Transaction) error {
+	if len(tx.Inputs) > maxTxInputs {
+		return fmt.Errorf("transaction has too many inputs: %d (max: %d)", len(tx.Inputs), maxTxInputs)
+	}
+	if len(tx.Outputs) > maxTxOutputs {
+		return fmt.Errorf("transaction has too many outputs: %d (max: %d)", len(tx.Outputs), maxTxOutputs)
+	}
+	return nil
+}
+
+// checkDust rejects transactions creating outputs below the dust threshold.
+// Data outputs hold no value and are exempt.
+func checkDust(tx *block.Transaction) error {
+	for i, output := range tx.Outputs {
+		if output.Value < DustThreshold && !output.IsData() {
+			return fmt.Errorf("output %d value %d below dust threshold", i, output.Value)
+		}
+	}
+	return nil
+}
+
+// TransactionSize returns the approximate size of a transaction in bytes,
+// as counted against the size limit and in fee rates.
+func TransactionSize(tx *block.Transaction) uint64 {
+	size := uint64(0)
+
+	// Version + LockTime + Fee
+	size += 4 + 8 + 8
+
+	// Input count + Output count
+	size += 4 + 4
+
+	// Inputs
+	for _, input := range tx.Inputs {
+		size += 32 + 4 + uint64(len(input.ScriptSig)) + 4
+	}
+
+	// Outputs
+	for _, output := range tx.Outputs {
+		size += 8 + uint64(len(output.ScriptPubKey))
+	}
+
+	return size
+}
+
+// TransactionWeight returns the weight of a transaction: the bytes of its
+// input scripts count once and all other bytes WitnessScaleFactor times, so
+// that signature data is cheaper than the data every node keeps in its UTXO
+// set.
+func TransactionWeight(tx *block.Transaction) uint64 {
+	var scripts uint64
+	for _, input := range tx.Inputs {
+		scripts += uint64(len(input.ScriptSig))
+	}
+	return (TransactionSize(tx)-scripts)*WitnessScaleFactor + scripts
+}
+
+// TransactionVSize returns the virtual size of a transaction, its weight
+// divided by WitnessScaleFactor and rounded up.
+func TransactionVSize(tx *block.Transaction) uint64 {
+	return (TransactionWeight(tx) + WitnessScaleFactor - 1) / WitnessScaleFactor
+}
+
+// accountedSize returns the size of a transaction as counted against size
+// limits and in fee rates: its virtual size under weight accounting, else
+// its size in bytes.
+func accountedSize(tx *block.Transaction, weightAccounting bool) uint64 {
+	if weightAccounting {
+		return TransactionVSize(tx)
+	}
+	return TransactionSize(tx)
+}
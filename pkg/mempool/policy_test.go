@@ -0,0 +1,172 @@
+package mempool
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/utxo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayPolicyCheck(t *testing.T) {
+	policy := DefaultMempoolConfig().RelayPolicy()
+	newTx := func(fee uint64, values ...uint64) *block.Transaction {
+		tx := &block.Transaction{
+			Inputs: []*block.TxInput{{PrevTxHash: make([]byte, 32), ScriptSig: make([]byte, 64)}},
+			Fee:    fee,
+		}
+		for _, value := range values {
+			tx.Outputs = append(tx.Outputs, &block.TxOutput{Value: value, ScriptPubKey: make([]byte, 20)})
+		}
+		return tx
+	}
+
+	assert.NoError(t, policy.Check(newTx(1000, 5000, DustThreshold)))
+	assert.ErrorContains(t, policy.Check(newTx(1000, 5000, DustThreshold-1)), "output 1 value 545 below dust threshold")
+	assert.ErrorIs(t, policy.Check(newTx(10, 5000)), ErrInsufficientFee)
+
+	large := newTx(1000000, 5000)
+	large.Inputs[0].ScriptSig = []byte(strings.Repeat("x", int(policy.MaxTxSize)))
+	assert.ErrorContains(t, policy.Check(large), "exceeds maximum allowed size")
+}
+
+// TestWeightAccounting compares a transaction whose bytes are mostly input
+// script with one of the same size whose bytes are mostly output script:
+// byte accounting treats them alike, weight accounting discounts the input
+// script.
+func TestWeightAccounting(t *testing.T) {
+	newTx := func(scriptSig, scriptPubKey int, fee uint64) *block.Transaction {
+		prev := make([]byte, 32)
+		prev[0], prev[1] = byte(scriptSig), byte(fee)
+		tx := &block.Transaction{
+			Version: 1,
+			Inputs:  []*block.TxInput{{PrevTxHash: prev, ScriptSig: make([]byte, scriptSig), Sequence: 0xffffffff}},
+			Outputs: []*block.TxOutput{{Value: 5000, ScriptPubKey: make([]byte, scriptPubKey)}},
+			Fee:     fee,
+		}
+		tx.Hash = tx.CalculateHash()
+		return tx
+	}
+	sigHeavy := func(fee uint64) *block.Transaction { return newTx(400, 20, fee) }
+	outputHeavy := func(fee uint64) *block.Transaction { return newTx(20, 400, fee) }
+
+	require.Equal(t, TransactionSize(sigHeavy(0)), TransactionSize(outputHeavy(0)))
+	assert.Equal(t, uint64(496), TransactionSize(sigHeavy(0)))
+	assert.Equal(t, uint64(784), TransactionWeight(sigHeavy(0)))
+	assert.Equal(t, uint64(196), TransactionVSize(sigHeavy(0)))
+	assert.Equal(t, uint64(481), TransactionVSize(outputHeavy(0)))
+
+	newMempool := func(weight bool, minFeeRate uint64) *Mempool {
+		config := TestMempoolConfig()
+		config.MinFeeRate = minFeeRate
+		config.WeightAccounting = weight
+		return NewMempool(config)
+	}
+
+	// A fee of 700 is 1 per byte for both, below the minimum of 2, but 3
+	// per virtual byte for the transaction made of input script
+	bytes := newMempool(false, 2)
+	assert.ErrorIs(t, bytes.AddTransaction(sigHeavy(700)), ErrInsufficientFee)
+	assert.ErrorIs(t, bytes.AddTransaction(outputHeavy(700)), ErrInsufficientFee)
+
+	weighted := newMempool(true, 2)
+	assert.NoError(t, weighted.AddTransaction(sigHeavy(700)))
+	assert.ErrorIs(t, weighted.AddTransaction(outputHeavy(700)), ErrInsufficientFee)
+	assert.Equal(t, uint64(196), weighted.GetSize())
+
+	policy := TestMempoolConfig().RelayPolicy()
+	policy.MinFeeRate = 2
+	assert.ErrorIs(t, policy.Check(sigHeavy(700)), ErrInsufficientFee)
+	policy.WeightAccounting = true
+	assert.NoError(t, policy.Check(sigHeavy(700)))
+
+	// At equal fees the two have the same fee rate per byte, while the
+	// transaction made of input script pays more per virtual byte
+	for _, weight := range []bool{false, true} {
+		mp := newMempool(weight, 1)
+		cheap, dear := outputHeavy(1000), sigHeavy(1000)
+		require.NoError(t, mp.AddTransaction(cheap))
+		require.NoError(t, mp.AddTransaction(dear))
+
+		cheapEntry, dearEntry := mp.transactions[string(cheap.Hash)], mp.transactions[string(dear.Hash)]
+		if !weight {
+			assert.Equal(t, cheapEntry.FeeRate, dearEntry.FeeRate)
+			assert.Equal(t, uint64(992), mp.GetSize())
+			continue
+		}
+		assert.Equal(t, uint64(5), dearEntry.FeeRate)
+		assert.Equal(t, uint64(2), cheapEntry.FeeRate)
+		assert.Equal(t, uint64(677), mp.GetSize())
+		assert.Equal(t, []*block.Transaction{dear, cheap}, mp.GetTransactionsForBlock(1000000))
+	}
+}
+
+// TestScriptSizeLimits spends an output to a transaction whose output
+// script is larger than the standardness limit: consensus accepts it, so it
+// may be mined, but the mempool does not relay it.
+func TestScriptSizeLimits(t *testing.T) {
+	key, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+	pubKey := key.PubKey().SerializeUncompressed()
+	pubKeyHash := sha256.Sum256(pubKey)
+
+	us := utxo.NewUTXOSet()
+	prev := &utxo.UTXO{
+		TxHash:       bytes.Repeat([]byte{1}, 32),
+		Value:        100000,
+		ScriptPubKey: pubKeyHash[12:],
+		Address:      hex.EncodeToString(pubKeyHash[12:]),
+		Height:       1,
+	}
+	us.AddUTXO(prev)
+
+	tx := &block.Transaction{
+		Version: 1,
+		Inputs:  []*block.TxInput{{PrevTxHash: prev.TxHash, Sequence: 0xffffffff}},
+		Outputs: []*block.TxOutput{{Value: 90000, ScriptPubKey: bytes.Repeat([]byte{2}, 600)}},
+		Fee:     10000,
+	}
+	sigHash, err := utxo.TxSignatureHash(tx, 0, utxo.SigHashAll)
+	require.NoError(t, err)
+	r, s, err := ecdsa.Sign(rand.Reader, key.ToECDSA(), sigHash)
+	require.NoError(t, err)
+	tx.Inputs[0].ScriptSig = append(append(pubKey, r.FillBytes(make([]byte, 32))...), s.FillBytes(make([]byte, 32))...)
+	tx.Hash = tx.CalculateHash()
+
+	// The transaction is consensus-valid and could be included in a block
+	require.NoError(t, tx.IsValid())
+	require.NoError(t, us.ValidateTransaction(tx))
+
+	newMempool := func(limits ScriptSizeLimits) *Mempool {
+		config := TestMempoolConfig()
+		config.TestMode = false
+		config.ScriptLimits = limits
+		mp := NewMempool(config)
+		mp.SetUTXOSet(us)
+		return mp
+	}
+
+	mp := newMempool(ScriptSizeLimits{MaxScriptPubKeySize: 500})
+	assert.ErrorContains(t, mp.AddTransaction(tx), "total scriptPubKey size 600 exceeds standard maximum 500")
+	assert.Zero(t, mp.GetTransactionCount())
+
+	mp = newMempool(ScriptSizeLimits{MaxScriptSigSize: 128})
+	assert.ErrorContains(t, mp.AddTransaction(tx), "total scriptSig size 129 exceeds standard maximum 128")
+
+	// Within the limits the mempool accepts it
+	mp = newMempool(DefaultScriptSizeLimits)
+	assert.NoError(t, mp.AddTransaction(tx))
+
+	// Wallets see the limits through the relay policy
+	policy := TestMempoolConfig().RelayPolicy()
+	policy.ScriptLimits.MaxScriptPubKeySize = 500
+	assert.ErrorContains(t, policy.Check(tx), "exceeds standard maximum")
+}
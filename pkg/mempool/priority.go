@@ -0,0 +1,110 @@
+package mempool
+
+import (
+	"sort"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/utxo"
+)
+
+// CoinAge returns the sum over the entry's confirmed inputs of value times
+// confirmations at the given tip height. Inputs spending other mempool
+// transactions have no confirmations and do not contribute.
+func (e *TransactionEntry) CoinAge(tipHeight uint64) uint64 {
+	weighted := e.confirmedValue * (tipHeight + 1)
+	if weighted < e.valueHeightSum {
+		return 0
+	}
+	return weighted - e.valueHeightSum
+}
+
+// Priority returns the coin age per byte of the entry at the given tip
+// height. Old, high-value coins have a high priority even without a fee.
+func (e *TransactionEntry) Priority(tipHeight uint64) uint64 {
+	if e.Size == 0 {
+		return 0
+	}
+	return e.CoinAge(tipHeight) / e.Size
+}
+
+// SetChainHeight sets the height of the chain tip used to compute the
+// coin-age priority and check the relative lock-times of incoming
+// transactions.
+func (mp *Mempool) SetChainHeight(height uint64) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	mp.chainHeight = height
+}
+
+// SetBlockTimeSource sets the function returning the timestamps of chain
+// blocks, needed to check time-based relative lock-times. Without it such
+// locks are never considered met.
+func (mp *Mempool) SetBlockTimeSource(blockTime utxo.BlockTimeFunc) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+	mp.blockTime = blockTime
+}
+
+// GetFreeTransactionsForBlock returns transactions admitted below the minimum
+// fee rate on coin-age priority, highest priority first, limited to maxSize
+// bytes. It also returns the total size of the returned transactions.
+func (mp *Mempool) GetFreeTransactionsForBlock(tipHeight, maxSize uint64) ([]*block.Transaction, uint64) {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	var candidates []*TransactionEntry
+	for _, entry := range mp.transactions {
+		if entry.free {
+			candidates = append(candidates, entry)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Priority(tipHeight) > candidates[j].Priority(tipHeight)
+	})
+
+	var transactions []*block.Transaction
+	currentSize := uint64(0)
+	for _, entry := range candidates {
+		if currentSize+entry.Size > maxSize {
+			continue
+		}
+		transactions = append(transactions, entry.Transaction)
+		currentSize += entry.Size
+	}
+
+	return transactions, currentSize
+}
+
+// coinAgeInputs returns the total value of the confirmed outputs spent by tx
+// and the sum of each value multiplied by its height, from which the coin
+// age at any later tip can be derived.
+// Note: the caller must hold the mempool lock.
+func (mp *Mempool) coinAgeInputs(tx *block.Transaction) (value, valueHeightSum uint64) {
+	if mp.utxoSet == nil {
+		return 0, 0
+	}
+	for _, input := range tx.Inputs {
+		if _, inPool := mp.transactions[string(input.PrevTxHash)]; inPool {
+			continue
+		}
+		u := mp.utxoSet.GetUTXO(input.PrevTxHash, input.PrevTxIndex)
+		if u == nil {
+			continue
+		}
+		value += u.Value
+		valueHeightSum += u.Value * u.Height
+	}
+	return value, valueHeightSum
+}
+
+// qualifiesForFreeRelay reports whether a transaction paying less than the
+// minimum fee rate may still be accepted on coin-age priority.
+// Note: the caller must hold the mempool lock.
+func (mp *Mempool) qualifiesForFreeRelay(tx *block.Transaction, size uint64) bool {
+	if mp.freeTxMinPriority == 0 {
+		return false
+	}
+	value, valueHeightSum := mp.coinAgeInputs(tx)
+	entry := &TransactionEntry{Size: size, confirmedValue: value, valueHeightSum: valueHeightSum}
+	return entry.Priority(mp.chainHeight) >= mp.freeTxMinPriority
+}
@@ -0,0 +1,123 @@
+package mempool
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+)
+
+const (
+	// DefaultRebroadcastInterval is the default time after which unconfirmed
+	// local transactions are sent to peers again.
+	DefaultRebroadcastInterval = 15 * time.Minute
+	// DefaultMaxRebroadcasts is the default number of times a local
+	// transaction is rebroadcast.
+	DefaultMaxRebroadcasts = 8
+)
+
+// AddLocalTransaction adds a transaction submitted to this node, e.g.
+// through the API, rather than relayed by a peer. Local transactions are
+// released to peers by ReleaseDiffused after a random delay when diffusion
+// is enabled, and otherwise sent by the next Rebroadcast, which
+// StartRebroadcast runs right away. Rebroadcast then resends them until
+// they leave the mempool, in case peers dropped them.
+func (mp *Mempool) AddLocalTransaction(tx *block.Transaction) error {
+	if err := mp.addTransaction(tx, true); err != nil {
+		return err
+	}
+
+	mp.mu.Lock()
+	entry, exists := mp.transactions[string(tx.Hash)]
+	if exists {
+		entry.local = true
+		mp.holdForDiffusion(entry)
+		exists = entry.releaseAt.IsZero()
+	}
+	mp.mu.Unlock()
+
+	if exists {
+		select {
+		case mp.localAdded <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// IsLocal reports whether the transaction with the given hash is in the
+// mempool and was submitted to this node.
+func (mp *Mempool) IsLocal(txHash []byte) bool {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+	entry, exists := mp.transactions[string(txHash)]
+	return exists && entry.local
+}
+
+// Rebroadcast passes each local transaction that was never sent to peers,
+// and each one that has not been sent for RebroadcastInterval, to publish.
+// Transactions are resent at most MaxRebroadcasts times, and not at all if
+// RebroadcastInterval is zero. Transactions confirmed or otherwise removed
+// from the mempool are no longer rebroadcast. It returns the number of
+// transactions published.
+func (mp *Mempool) Rebroadcast(publish func(tx *block.Transaction) error) int {
+	mp.mu.Lock()
+	now := mp.now()
+	var due []*block.Transaction
+	for _, entry := range mp.transactions {
+		if !entry.local || !entry.releaseAt.IsZero() {
+			continue
+		}
+		if entry.lastBroadcast.IsZero() {
+			entry.lastBroadcast = now
+			due = append(due, entry.Transaction)
+			continue
+		}
+		if mp.rebroadcastInterval <= 0 || entry.rebroadcasts >= mp.maxRebroadcasts {
+			continue
+		}
+		if now.Sub(entry.lastBroadcast) < mp.rebroadcastInterval {
+			continue
+		}
+		entry.lastBroadcast = now
+		entry.rebroadcasts++
+		due = append(due, entry.Transaction)
+	}
+	mp.mu.Unlock()
+
+	published := 0
+	for _, tx := range due {
+		if err := publish(tx); err != nil {
+			fmt.Printf("Failed to rebroadcast transaction %x: %v\n", tx.Hash, err)
+			continue
+		}
+		published++
+	}
+	return published
+}
+
+// StartRebroadcast calls Rebroadcast with publish until ctx is done: every
+// RebroadcastInterval, unless it is zero, and whenever a local transaction
+// not held for diffusion is added.
+func (mp *Mempool) StartRebroadcast(ctx context.Context, publish func(tx *block.Transaction) error) {
+	go func() {
+		var tick <-chan time.Time
+		if mp.rebroadcastInterval > 0 {
+			ticker := time.NewTicker(mp.rebroadcastInterval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick:
+				mp.Rebroadcast(publish)
+			case <-mp.localAdded:
+				mp.Rebroadcast(publish)
+			}
+		}
+	}()
+}
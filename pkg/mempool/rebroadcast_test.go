@@ -0,0 +1,104 @@
+package mempool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRebroadcast(t *testing.T) {
+	config := TestMempoolConfig()
+	config.RebroadcastInterval = 10 * time.Minute
+	config.MaxRebroadcasts = 2
+	mp := NewMempool(config)
+	now := time.Unix(1700000000, 0)
+	mp.now = func() time.Time { return now }
+
+	var published []*block.Transaction
+	publish := func(tx *block.Transaction) error {
+		published = append(published, tx)
+		return nil
+	}
+
+	local := createBasicValidTransaction("local", 1000)
+	require.NoError(t, mp.AddLocalTransaction(local))
+	relayed := createBasicValidTransaction("relayed", 1000)
+	require.NoError(t, mp.AddTransaction(relayed))
+	assert.True(t, mp.IsLocal(local.Hash))
+	assert.False(t, mp.IsLocal(relayed.Hash))
+
+	// Only the local transaction is sent, right away
+	assert.Equal(t, 1, mp.Rebroadcast(publish))
+	require.Len(t, published, 1)
+	assert.Equal(t, local.Hash, published[0].Hash)
+
+	// Nothing is due again before the interval has passed
+	now = now.Add(5 * time.Minute)
+	assert.Equal(t, 0, mp.Rebroadcast(publish))
+
+	now = now.Add(5 * time.Minute)
+	assert.Equal(t, 1, mp.Rebroadcast(publish))
+	require.Len(t, published, 2)
+	assert.Equal(t, local.Hash, published[1].Hash)
+	assert.Equal(t, 0, mp.Rebroadcast(publish), "interval restarts after a rebroadcast")
+
+	// Rebroadcasts stop after confirmation
+	now = now.Add(10 * time.Minute)
+	b := &block.Block{Header: &block.Header{Height: 1}, Transactions: []*block.Transaction{local}}
+	require.Equal(t, 1, mp.RemoveConfirmedTransactions(b))
+	assert.Equal(t, 0, mp.Rebroadcast(publish))
+	assert.Len(t, published, 2)
+}
+
+func TestStartRebroadcastSendsOnSubmit(t *testing.T) {
+	config := TestMempoolConfig()
+	config.RebroadcastInterval = time.Hour
+	mp := NewMempool(config)
+
+	published := make(chan *block.Transaction, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mp.StartRebroadcast(ctx, func(tx *block.Transaction) error {
+		published <- tx
+		return nil
+	})
+
+	local := createBasicValidTransaction("local", 1000)
+	require.NoError(t, mp.AddLocalTransaction(local))
+	select {
+	case tx := <-published:
+		assert.Equal(t, local.Hash, tx.Hash)
+	case <-time.After(5 * time.Second):
+		t.Fatal("local transaction was not sent on submit")
+	}
+}
+
+func TestRebroadcastLimit(t *testing.T) {
+	config := TestMempoolConfig()
+	config.RebroadcastInterval = time.Minute
+	config.MaxRebroadcasts = 2
+	mp := NewMempool(config)
+	now := time.Unix(1700000000, 0)
+	mp.now = func() time.Time { return now }
+
+	require.NoError(t, mp.AddLocalTransaction(createBasicValidTransaction("local", 1000)))
+	publish := func(tx *block.Transaction) error { return nil }
+	assert.Equal(t, 1, mp.Rebroadcast(publish), "the first broadcast is not counted")
+	for i, want := range []int{1, 1, 0} {
+		now = now.Add(time.Minute)
+		assert.Equal(t, want, mp.Rebroadcast(publish), "round %d", i)
+	}
+
+	// A zero interval disables rebroadcasting, not the first broadcast
+	config.RebroadcastInterval = 0
+	mp = NewMempool(config)
+	mp.now = func() time.Time { return now }
+	require.NoError(t, mp.AddLocalTransaction(createBasicValidTransaction("local", 1000)))
+	assert.Equal(t, 1, mp.Rebroadcast(publish))
+	now = now.Add(time.Hour)
+	assert.Equal(t, 0, mp.Rebroadcast(publish))
+}
@@ -0,0 +1,101 @@
+package mempool
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/utxo"
+)
+
+// DefaultReorgRetention is the default time confirmed transactions are
+// retained for reorgs.
+const DefaultReorgRetention = 30 * time.Minute
+
+// retainedTx is the entry of a transaction confirmed on the active chain,
+// kept in case a reorg disconnects its block.
+type retainedTx struct {
+	entry       *TransactionEntry
+	confirmedAt time.Time
+}
+
+// retainLocked keeps the entry of a transaction that left the mempool
+// because it was confirmed, and forgets entries older than the retention
+// period.
+// Note: the caller must hold the mempool lock.
+func (mp *Mempool) retainLocked(entry *TransactionEntry) {
+	if mp.reorgRetention <= 0 {
+		return
+	}
+	now := mp.now()
+	for hash, retained := range mp.retained {
+		if now.Sub(retained.confirmedAt) > mp.reorgRetention {
+			delete(mp.retained, hash)
+		}
+	}
+	mp.retained[string(entry.Transaction.Hash)] = &retainedTx{entry: entry, confirmedAt: now}
+}
+
+// ReturnDisconnectedTransactions returns the transactions of a block a reorg
+// disconnected from the active chain to the mempool, so that they can be
+// mined again. It must be called once the chain is at its new tip.
+// Transactions confirmed within the retention period are restored from their
+// retained entries without their signatures being verified again, provided
+// their inputs are still unspent at the new tip; the others are validated
+// like new transactions. It returns the number of transactions back in the
+// mempool.
+func (mp *Mempool) ReturnDisconnectedTransactions(b *block.Block) int {
+	if b == nil {
+		return 0
+	}
+
+	returned := 0
+	for _, tx := range b.Transactions {
+		if tx == nil || tx.IsCoinbase() {
+			continue
+		}
+		if err := mp.returnTransaction(tx); err != nil {
+			continue
+		}
+		returned++
+	}
+	return returned
+}
+
+// returnTransaction puts a disconnected transaction back in the mempool.
+func (mp *Mempool) returnTransaction(tx *block.Transaction) error {
+	mp.mu.Lock()
+	retained, exists := mp.retained[string(tx.Hash)]
+	if exists {
+		delete(mp.retained, string(tx.Hash))
+	}
+	if !exists || mp.now().Sub(retained.confirmedAt) > mp.reorgRetention {
+		mp.mu.Unlock()
+		return mp.AddTransaction(tx)
+	}
+	defer mp.mu.Unlock()
+
+	if _, inMempool := mp.transactions[string(tx.Hash)]; inMempool {
+		return ErrAlreadyInMempool
+	}
+	for i, input := range tx.Inputs {
+		if mp.isUTXOSpentInMempool(input.PrevTxHash, input.PrevTxIndex) {
+			return fmt.Errorf("%w: input %d references UTXO already spent in mempool", ErrDoubleSpend, i)
+		}
+	}
+	// The new branch may have spent the inputs or not created them
+	if mp.utxoSet != nil && !mp.testMode {
+		view := mp.utxoView()
+		for i, input := range tx.Inputs {
+			if view.GetUTXO(input.PrevTxHash, input.PrevTxIndex) == nil {
+				return fmt.Errorf("input %d references non-existent UTXO", i)
+			}
+		}
+		if mp.enforceSequenceLocks {
+			if err := utxo.CheckSequenceLocks(tx, view, mp.chainHeight+1, mp.blockTime); err != nil {
+				return err
+			}
+		}
+	}
+	return mp.insertEntry(retained.entry)
+}
@@ -0,0 +1,118 @@
+package mempool
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/crypto_utils"
+	"github.com/palaseus/adrenochain/pkg/utxo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReorgRetention(t *testing.T) {
+	config := TestMempoolConfig()
+	config.ReorgRetention = 10 * time.Minute
+	mp := NewMempool(config)
+	now := time.Unix(1700000000, 0)
+	mp.now = func() time.Time { return now }
+
+	// confirm mines a block of fresh transactions, returning it
+	confirm := func(name string, height uint64) *block.Block {
+		var txs []*block.Transaction
+		for i := 0; i < 5; i++ {
+			tx := createBasicValidTransaction(fmt.Sprintf("%s_%d", name, i), 1000)
+			require.NoError(t, mp.AddTransaction(tx))
+			txs = append(txs, tx)
+		}
+		b := &block.Block{Header: &block.Header{Height: height}, Transactions: txs}
+		require.Equal(t, len(txs), mp.RemoveConfirmedTransactions(b))
+		return b
+	}
+	validations := func() uint64 {
+		return mp.GetTransactionStats()["validations"].(uint64)
+	}
+
+	// A reorg within the retention window restores without validating
+	recent := confirm("recent", 1)
+	before := validations()
+	now = now.Add(5 * time.Minute)
+	assert.Equal(t, 5, mp.ReturnDisconnectedTransactions(recent))
+	assert.Equal(t, before, validations())
+	assert.Equal(t, 5, mp.GetTransactionCount())
+	for _, tx := range recent.Transactions {
+		assert.NotNil(t, mp.GetTransaction(tx.Hash))
+	}
+	mp.Clear()
+
+	// Outside the window every transaction is validated again
+	old := confirm("old", 2)
+	before = validations()
+	now = now.Add(11 * time.Minute)
+	assert.Equal(t, 5, mp.ReturnDisconnectedTransactions(old))
+	assert.Equal(t, before+5, validations())
+	assert.Equal(t, 5, mp.GetTransactionCount())
+
+	// Expired entries are forgotten as new transactions are confirmed
+	confirm("stale", 3)
+	now = now.Add(11 * time.Minute)
+	confirm("new", 4)
+	assert.Equal(t, 5, mp.GetTransactionStats()["retained"])
+}
+
+func TestReorgRetentionDisabled(t *testing.T) {
+	config := TestMempoolConfig()
+	config.ReorgRetention = 0
+	mp := NewMempool(config)
+
+	tx := createBasicValidTransaction("unretained", 1000)
+	require.NoError(t, mp.AddTransaction(tx))
+	b := &block.Block{Header: &block.Header{Height: 1}, Transactions: []*block.Transaction{tx}}
+	require.Equal(t, 1, mp.RemoveConfirmedTransactions(b))
+	assert.Equal(t, 0, mp.GetTransactionStats()["retained"])
+
+	before := mp.GetTransactionStats()["validations"].(uint64)
+	assert.Equal(t, 1, mp.ReturnDisconnectedTransactions(b))
+	assert.Equal(t, before+1, mp.GetTransactionStats()["validations"].(uint64))
+}
+
+func TestReturnDisconnectedTransactionsChecksNewTip(t *testing.T) {
+	ctu := crypto_utils.NewCryptoTestUtils(t)
+	kp := ctu.GenerateTestKeyPair()
+	script, err := hex.DecodeString(kp.Address)
+	require.NoError(t, err)
+
+	utxoSet := utxo.NewUTXOSet()
+	confirmed := &utxo.UTXO{
+		TxHash:       bytes.Repeat([]byte{0xbb}, 32),
+		Value:        100000,
+		ScriptPubKey: script,
+		Address:      kp.Address,
+		Height:       1,
+	}
+	utxoSet.AddUTXO(confirmed)
+
+	mp := NewMempool(DefaultMempoolConfig())
+	mp.SetUTXOSet(utxoSet)
+	tx := spendOutput(ctu, kp, confirmed.TxHash, 100000, 1000)
+	require.NoError(t, mp.AddTransaction(tx))
+	b := &block.Block{Header: &block.Header{Height: 2}, Transactions: []*block.Transaction{tx}}
+	require.Equal(t, 1, mp.RemoveConfirmedTransactions(b))
+
+	// The new branch spent the output the retained transaction spends
+	utxoSet.RemoveUTXO(confirmed.TxHash, confirmed.TxIndex)
+	assert.Equal(t, 0, mp.ReturnDisconnectedTransactions(b))
+	assert.Nil(t, mp.GetTransaction(tx.Hash))
+
+	// Without a conflicting spend on the new branch it is returned
+	utxoSet.AddUTXO(confirmed)
+	require.NoError(t, mp.AddTransaction(tx))
+	require.Equal(t, 1, mp.RemoveConfirmedTransactions(b))
+	assert.Equal(t, 0, mp.GetTransactionCount())
+	assert.Equal(t, 1, mp.ReturnDisconnectedTransactions(b))
+	assert.NotNil(t, mp.GetTransaction(tx.Hash))
+}
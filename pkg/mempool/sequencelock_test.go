@@ -0,0 +1,46 @@
+package mempool
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/crypto_utils"
+	"github.com/palaseus/adrenochain/pkg/utxo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMempoolEnforcesSequenceLocks(t *testing.T) {
+	ctu := crypto_utils.NewCryptoTestUtils(t)
+	kp := ctu.GenerateTestKeyPair()
+	script, err := hex.DecodeString(kp.Address)
+	require.NoError(t, err)
+
+	utxoSet := utxo.NewUTXOSet()
+	confirmed := utxo.NewUTXO(bytes.Repeat([]byte{0xdd}, 32), 0, 50000, script, kp.Address, false, 10)
+	utxoSet.AddUTXO(confirmed)
+
+	mp := NewMempool(DefaultMempoolConfig())
+	mp.SetUTXOSet(utxoSet)
+	mp.SetChainHeight(11)
+
+	// A version 2 transaction that can only be mined 5 blocks above the
+	// coin it spends
+	tx := spendOutput(ctu, kp, confirmed.TxHash, 50000, 1000)
+	tx.Version = 2
+	tx.Inputs[0].Sequence = 5
+	signature, err := ctu.SignData(ctu.CreateSignatureData(tx, 0), kp.PrivateKey)
+	require.NoError(t, err)
+	tx.Inputs[0].ScriptSig = append(kp.PublicKey.SerializeUncompressed(), signature...)
+
+	// The next block is at height 12, only 2 blocks above the coin
+	err = mp.AddTransaction(tx)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, utxo.ErrSequenceLockNotMet))
+
+	// Once the next block is 5 blocks above the coin it is accepted
+	mp.SetChainHeight(14)
+	assert.NoError(t, mp.AddTransaction(tx))
+}
@@ -0,0 +1,81 @@
+package mempool
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/palaseus/adrenochain/pkg/utxo"
+)
+
+// MempoolUTXOView is a read-only view of the chain UTXO set with the effects
+// of in-mempool transactions applied: confirmed outputs spent by pooled
+// transactions are hidden and outputs created by pooled transactions are
+// visible. It implements utxo.UTXOView and is a snapshot; later mempool
+// changes are not reflected.
+type MempoolUTXOView struct {
+	base    *utxo.UTXOSet         // base is the confirmed chain UTXO set.
+	created map[string]*utxo.UTXO // created holds unspent outputs of in-mempool transactions.
+	spent   map[string]struct{}   // spent holds outputs consumed by in-mempool transactions.
+}
+
+// UTXOView returns a snapshot of the chain UTXO set overlaid with the
+// current mempool contents.
+func (mp *Mempool) UTXOView() *MempoolUTXOView {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+	return mp.utxoView()
+}
+
+// utxoView builds a MempoolUTXOView.
+// Note: the caller must hold the mempool lock.
+func (mp *Mempool) utxoView() *MempoolUTXOView {
+	view := &MempoolUTXOView{
+		base:    mp.utxoSet,
+		created: make(map[string]*utxo.UTXO),
+		spent:   make(map[string]struct{}),
+	}
+
+	for _, entry := range mp.transactions {
+		tx := entry.Transaction
+		for _, input := range tx.Inputs {
+			view.spent[outpointKey(input.PrevTxHash, input.PrevTxIndex)] = struct{}{}
+		}
+		for i, output := range tx.Outputs {
+			if output.IsData() {
+				continue
+			}
+			view.created[outpointKey(tx.Hash, uint32(i))] = &utxo.UTXO{
+				TxHash:       tx.Hash,
+				TxIndex:      uint32(i),
+				Value:        output.Value,
+				ScriptPubKey: output.ScriptPubKey,
+				Address:      hex.EncodeToString(output.ScriptPubKey),
+				IsCoinbase:   false,
+				Height:       mp.chainHeight + 1,
+			}
+		}
+	}
+
+	return view
+}
+
+// GetUTXO returns the unspent output identified by txHash and txIndex, or nil
+// if it does not exist or is already spent by an in-mempool transaction.
+func (v *MempoolUTXOView) GetUTXO(txHash []byte, txIndex uint32) *utxo.UTXO {
+	key := outpointKey(txHash, txIndex)
+	if _, spent := v.spent[key]; spent {
+		return nil
+	}
+	if u, exists := v.created[key]; exists {
+		return u
+	}
+	if v.base == nil {
+		return nil
+	}
+	return v.base.GetUTXO(txHash, txIndex)
+}
+
+// outpointKey builds the map key for a transaction output reference.
+func outpointKey(txHash []byte, txIndex uint32) string {
+	return fmt.Sprintf("%x:%d", txHash, txIndex)
+}
@@ -0,0 +1,105 @@
+package mempool
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/crypto_utils"
+	"github.com/palaseus/adrenochain/pkg/utxo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMempoolUTXOView(t *testing.T) {
+	ctu := crypto_utils.NewCryptoTestUtils(t)
+	kp := ctu.GenerateTestKeyPair()
+	script, err := hex.DecodeString(kp.Address)
+	require.NoError(t, err)
+
+	utxoSet := utxo.NewUTXOSet()
+	confirmed := &utxo.UTXO{
+		TxHash:       bytes.Repeat([]byte{0xbb}, 32),
+		TxIndex:      0,
+		Value:        50000,
+		ScriptPubKey: script,
+		Address:      kp.Address,
+		Height:       1,
+	}
+	utxoSet.AddUTXO(confirmed)
+
+	mp := NewMempool(DefaultMempoolConfig())
+	mp.SetUTXOSet(utxoSet)
+
+	parent := spendOutput(ctu, kp, confirmed.TxHash, 50000, 1000)
+	require.NoError(t, mp.AddTransaction(parent))
+
+	view := mp.UTXOView()
+
+	// The confirmed output is spent by the pooled parent, and the parent's
+	// output is only visible through the view.
+	assert.Nil(t, view.GetUTXO(confirmed.TxHash, 0))
+	assert.NotNil(t, utxoSet.GetUTXO(confirmed.TxHash, 0))
+	created := view.GetUTXO(parent.Hash, 0)
+	require.NotNil(t, created)
+	assert.Equal(t, uint64(49000), created.Value)
+	assert.Nil(t, utxoSet.GetUTXO(parent.Hash, 0))
+
+	child := spendOutput(ctu, kp, parent.Hash, 49000, 1000)
+	assert.NoError(t, utxoSet.ValidateTransactionWithView(child, view))
+	err = utxoSet.ValidateTransaction(child)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "input UTXO not found")
+
+	// Once the child is pooled, the parent's output is spent in the view.
+	require.NoError(t, mp.AddTransaction(child))
+	assert.Nil(t, mp.UTXOView().GetUTXO(parent.Hash, 0))
+	assert.NotNil(t, mp.UTXOView().GetUTXO(child.Hash, 0))
+}
+
+// TestMempoolIgnoresUnconnectedBlockOutputs checks that a transaction
+// spending an output that only exists in a block that is not connected to
+// the chain yet is rejected, and accepted once the block is connected.
+func TestMempoolIgnoresUnconnectedBlockOutputs(t *testing.T) {
+	ctu := crypto_utils.NewCryptoTestUtils(t)
+	kp := ctu.GenerateTestKeyPair()
+	script, err := hex.DecodeString(kp.Address)
+	require.NoError(t, err)
+
+	utxoSet := utxo.NewUTXOSet()
+	confirmed := &utxo.UTXO{
+		TxHash:       bytes.Repeat([]byte{0xcc}, 32),
+		TxIndex:      0,
+		Value:        50000,
+		ScriptPubKey: script,
+		Address:      kp.Address,
+		Height:       1,
+	}
+	utxoSet.AddUTXO(confirmed)
+
+	mp := NewMempool(DefaultMempoolConfig())
+	mp.SetUTXOSet(utxoSet)
+
+	// The parent is only included in a block that is being validated; the
+	// block view resolves its output but the chain UTXO set does not
+	parent := spendOutput(ctu, kp, confirmed.TxHash, 50000, 1000)
+	pending := &block.Block{
+		Header:       &block.Header{Height: 2},
+		Transactions: []*block.Transaction{parent},
+	}
+	blockView := utxo.NewBlockUTXOView(utxoSet, pending.Header.Height)
+	require.NoError(t, utxoSet.ValidateTransactionWithView(parent, blockView))
+	blockView.Apply(parent)
+	require.NotNil(t, blockView.GetUTXO(parent.Hash, 0))
+
+	child := spendOutput(ctu, kp, parent.Hash, 49000, 1000)
+	err = mp.AddTransaction(child)
+	require.Error(t, err)
+	assert.Nil(t, mp.GetTransaction(child.Hash))
+	assert.Nil(t, mp.UTXOView().GetUTXO(parent.Hash, 0))
+
+	// Once the block is connected its output is confirmed
+	require.NoError(t, utxoSet.ProcessBlock(pending))
+	assert.NoError(t, mp.AddTransaction(child))
+}
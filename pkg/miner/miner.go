@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -12,8 +13,12 @@ import (
 	"github.com/palaseus/adrenochain/pkg/chain"
 	"github.com/palaseus/adrenochain/pkg/consensus"
 	"github.com/palaseus/adrenochain/pkg/mempool"
+	"github.com/palaseus/adrenochain/pkg/wallet"
 )
 
+// ErrReadOnly is returned when mining is started on a read-only node.
+var ErrReadOnly = errors.New("miner: node is read-only, mining is disabled")
+
 // Miner represents a blockchain miner
 type Miner struct {
 	mu           sync.RWMutex
@@ -27,18 +32,56 @@ type Miner struct {
 	cancel       context.CancelFunc
 	consensus    *consensus.Consensus
 	onBlockMined func(*block.Block) // Callback for when a block is successfully mined
+
+	blocksFound uint64        // blocksFound counts blocks mined and accepted by the chain this session
+	hashes      uint64        // hashes counts the nonces tried this session
+	hashTime    time.Duration // hashTime is the time spent trying nonces this session
 }
 
 // MinerConfig holds configuration for the miner
 type MinerConfig struct {
-	MiningEnabled   bool
-	MiningThreads   int
-	BlockTime       time.Duration
-	MaxBlockSize    uint64
+	MiningEnabled bool
+	MiningThreads int
+	BlockTime     time.Duration
+	// MaxBlockSize is the largest block template the miner builds, counted
+	// as chain validation counts it.
+	MaxBlockSize uint64
+	// MaxBlockSigOps is the maximum number of signature operations in a
+	// block template. Zero disables the limit.
+	MaxBlockSigOps uint64
+	// CoinbaseAddress is the wallet address block rewards are paid to.
+	// Without one they are burned.
 	CoinbaseAddress string
-	CoinbaseReward  uint64
+	// CoinbaseReward is the subsidy claimed by mined blocks, capped by the
+	// chain's subsidy schedule once it halves below it.
+	CoinbaseReward uint64
+	// FreeTxSpace is the number of block bytes reserved for transactions
+	// accepted on coin-age priority without paying the minimum fee rate.
+	// Zero excludes free transactions from mined blocks.
+	FreeTxSpace uint64
+	// MaxTransactionsPerBlock is the maximum number of transactions in a
+	// block template, coinbase included. Zero disables the limit.
+	MaxTransactionsPerBlock uint64
+	// MaxPackageSize is the largest total size of a transaction and its
+	// unconfirmed ancestors the miner includes together, so that a
+	// high-fee child cannot pull a large chain of parents into the block.
+	// Zero disables the limit; packages must still fit the block.
+	MaxPackageSize uint64
+	// ReadOnly refuses to start mining, for nodes that only sync and serve
+	// data.
+	ReadOnly bool
+	// NonceStart is the first nonce tried for each block.
+	NonceStart uint64
+	// NonceStride is the step between the nonces tried; zero means 1.
+	// Together with NonceStart it partitions the nonce space between
+	// miners and makes the nonce found for a fixed block reproducible.
+	NonceStride uint64
 }
 
+// DefaultMaxPackageSize is the default largest size of a transaction
+// package included in a block template.
+const DefaultMaxPackageSize = 101000
+
 // DefaultMinerConfig returns the default miner configuration
 func DefaultMinerConfig() *MinerConfig {
 	return &MinerConfig{
@@ -46,9 +89,37 @@ func DefaultMinerConfig() *MinerConfig {
 		MiningThreads:   1,
 		BlockTime:       10 * time.Second,
 		MaxBlockSize:    1000000, // 1MB
+		MaxBlockSigOps:  chain.DefaultMaxBlockSigOps,
 		CoinbaseAddress: "",
 		CoinbaseReward:  1000000000, // 1 billion units
+
+		MaxTransactionsPerBlock: chain.DefaultMaxTransactionsPerBlock,
+		MaxPackageSize:          DefaultMaxPackageSize,
+	}
+}
+
+// Validate checks that the configuration is usable and returns every
+// problem found.
+func (mc *MinerConfig) Validate() error {
+	var errs []error
+	if mc.MiningThreads <= 0 {
+		errs = append(errs, fmt.Errorf("miner: mining threads must be positive, got %d", mc.MiningThreads))
+	}
+	if mc.BlockTime <= 0 {
+		errs = append(errs, fmt.Errorf("miner: block time must be positive"))
+	}
+	if mc.MaxBlockSize == 0 {
+		errs = append(errs, fmt.Errorf("miner: max block size must be positive"))
+	}
+	if mc.FreeTxSpace > mc.MaxBlockSize {
+		errs = append(errs, fmt.Errorf("miner: free transaction space %d exceeds max block size %d", mc.FreeTxSpace, mc.MaxBlockSize))
+	}
+	if mc.CoinbaseAddress != "" {
+		if _, err := wallet.AddressScript(mc.CoinbaseAddress); err != nil {
+			errs = append(errs, fmt.Errorf("miner: invalid coinbase address %q: %w", mc.CoinbaseAddress, err))
+		}
 	}
+	return errors.Join(errs...)
 }
 
 // NewMiner creates a new miner
@@ -75,6 +146,10 @@ func (m *Miner) SetOnBlockMined(callback func(*block.Block)) {
 
 // StartMining starts the mining process
 func (m *Miner) StartMining() error {
+	if m.config.ReadOnly {
+		return ErrReadOnly
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -223,8 +298,11 @@ func (m *Miner) mineNextBlock() error {
 		return fmt.Errorf("failed to create new block")
 	}
 
-	// Mine the block
-	if err := m.mineBlock(newBlock); err != nil {
+	// Mine the block, counting the nonces tried
+	start := time.Now()
+	err := m.mineBlock(newBlock)
+	m.recordHashes(m.noncesTried(newBlock.Header.Nonce), time.Since(start))
+	if err != nil {
 		if err.Error() == "mining stopped" {
 			// This is expected when stopping mining, don't log as error
 			return err
@@ -242,6 +320,14 @@ func (m *Miner) mineNextBlock() error {
 		return fmt.Errorf("failed to add block to chain: %w", err)
 	}
 
+	m.recordBlockFound()
+
+	m.mempool.RemoveConfirmedTransactions(newBlock)
+	if err := m.checkMempoolConsistency(newBlock); err != nil {
+		fmt.Printf("Mempool inconsistency after mining: %v\n", err)
+	}
+	m.mempool.SetChainHeight(newBlock.Header.Height)
+
 	// Call the callback if set
 	if m.onBlockMined != nil {
 		m.onBlockMined(newBlock)
@@ -252,19 +338,56 @@ func (m *Miner) mineNextBlock() error {
 	return nil
 }
 
+// checkMempoolConsistency checks that none of the transactions of a block
+// accepted by the chain is left in the mempool, where it would be mined
+// again. Transactions left behind are removed and reported in the error.
+func (m *Miner) checkMempoolConsistency(b *block.Block) error {
+	remaining := m.mempool.ConfirmedInPool(b)
+	if len(remaining) == 0 {
+		return nil
+	}
+	for _, hash := range remaining {
+		m.mempool.RemoveTransaction(hash)
+	}
+	return fmt.Errorf("%d transactions of block %d were still in the mempool, first %x",
+		len(remaining), b.Header.Height, remaining[0])
+}
+
 // createNewBlock creates a new block for mining
 func (m *Miner) createNewBlock(prevBlock *block.Block) *block.Block {
-	// Get transactions from mempool
-	transactions := m.mempool.GetTransactionsForBlock(m.config.MaxBlockSize)
+	// Fill the free-transaction quota by coin-age priority first, then the
+	// remaining space with packages by package fee rate
+	var freeTxs []*block.Transaction
+	if m.config.FreeTxSpace > 0 {
+		freeSpace := m.config.FreeTxSpace
+		if freeSpace > m.config.MaxBlockSize {
+			freeSpace = m.config.MaxBlockSize
+		}
+		freeTxs, _ = m.mempool.GetFreeTransactionsForBlock(prevBlock.Header.Height, freeSpace)
+	}
+	packages := m.mempool.GetPackagesForBlock()
+
+	// The timestamp must be later than the median time past even when the
+	// clock lags behind recent blocks
+	timestamp := time.Now()
+	if minimum := m.consensus.MinimumTimestamp(prevBlock); timestamp.Before(minimum) {
+		timestamp = minimum
+	}
+
+	// Testnets accept a minimum-difficulty block after a long gap
+	difficulty := m.chain.CalculateNextDifficulty()
+	if m.consensus.AllowsMinDifficulty(prevBlock, timestamp) {
+		difficulty = m.consensus.MinimumDifficulty()
+	}
 
 	// Create new block
 	newBlock := &block.Block{
 		Header: &block.Header{
-			Version:       1,
+			Version:       m.consensus.ComputeBlockVersion(prevBlock.Header.Height + 1),
 			PrevBlockHash: prevBlock.CalculateHash(),
 			MerkleRoot:    nil, // Will be calculated after adding transactions
-			Timestamp:     time.Now(),
-			Difficulty:    m.chain.CalculateNextDifficulty(),
+			Timestamp:     timestamp,
+			Difficulty:    difficulty,
 			Nonce:         0,
 			Height:        prevBlock.Header.Height + 1,
 		},
@@ -282,17 +405,63 @@ func (m *Miner) createNewBlock(prevBlock *block.Block) *block.Block {
 	// Add coinbase transaction first
 	newBlock.AddTransaction(coinbaseTx)
 
-	// Add other transactions
-	for _, tx := range transactions {
-		newBlock.AddTransaction(tx)
+	// Add other transactions while the block size, transaction count and
+	// signature operation limits allow. A transaction is added together with
+	// its unconfirmed ancestors, so a package that does not fit is skipped
+	// as a whole and smaller ones after it still get in.
+	size := m.chain.GetBlockSize(newBlock)
+	sigOps := coinbaseTx.SigOpCount()
+	included := make(map[string]bool)
+	addTransactions := func(txs []*block.Transaction) bool {
+		var txsSize, txsSigOps uint64
+		for _, tx := range txs {
+			txsSize += m.chain.GetTransactionSize(tx)
+			txsSigOps += tx.SigOpCount()
+		}
+		if m.config.MaxTransactionsPerBlock > 0 && uint64(len(newBlock.Transactions)+len(txs)) > m.config.MaxTransactionsPerBlock {
+			return false
+		}
+		if size+txsSize > m.config.MaxBlockSize {
+			return false
+		}
+		if m.config.MaxBlockSigOps > 0 && sigOps+txsSigOps > m.config.MaxBlockSigOps {
+			return false
+		}
+		for _, tx := range txs {
+			newBlock.AddTransaction(tx)
+		}
+		size += txsSize
+		sigOps += txsSigOps
+		return true
 	}
 
+	// Free transactions may spend earlier ones, so none are added once one
+	// does not fit
+	for _, tx := range freeTxs {
+		if !addTransactions([]*block.Transaction{tx}) {
+			break
+		}
+		included[string(tx.Hash)] = true
+	}
+	m.SelectTransactionsByPackage(packages, included, addTransactions)
+
 	// Calculate Merkle root
 	newBlock.Header.MerkleRoot = newBlock.CalculateMerkleRoot()
 
 	return newBlock
 }
 
+// payoutScript returns the script the coinbase pays to: the public key hash
+// of the coinbase address, or a provably unspendable script burning the
+// reward when no valid address is configured.
+func (m *Miner) payoutScript() []byte {
+	script, err := wallet.AddressScript(m.config.CoinbaseAddress)
+	if err != nil {
+		return []byte{block.OpReturn}
+	}
+	return script
+}
+
 // createCoinbaseTransaction creates a coinbase transaction
 func (m *Miner) createCoinbaseTransaction(height uint64) *block.Transaction {
 	// Calculate total fees from transactions
@@ -307,22 +476,22 @@ func (m *Miner) createCoinbaseTransaction(height uint64) *block.Transaction {
 	}
 	m.mu.RUnlock()
 
-	// Create coinbase output
-	// Ensure we have a valid script public key (cannot be empty)
-	scriptPubKey := m.config.CoinbaseAddress
-	if scriptPubKey == "" {
-		scriptPubKey = "coinbase" // Default fallback
+	reward := m.config.CoinbaseReward
+	if m.chain != nil {
+		if subsidy := m.chain.BlockSubsidy(height); reward > subsidy {
+			reward = subsidy
+		}
 	}
 
 	// Ensure we have a valid value (cannot be zero)
-	value := m.config.CoinbaseReward + totalFees
+	value := reward + totalFees
 	if value == 0 {
 		value = 1 // Minimum valid value
 	}
 
 	out := &block.TxOutput{
 		Value:        value,
-		ScriptPubKey: []byte(scriptPubKey),
+		ScriptPubKey: m.payoutScript(),
 	}
 
 	// Create transaction
@@ -342,7 +511,20 @@ func (m *Miner) createCoinbaseTransaction(height uint64) *block.Transaction {
 
 // mineBlock performs proof-of-work mining on a block
 func (m *Miner) mineBlock(block *block.Block) error {
-	return m.consensus.MineBlock(block, m.stopMining)
+	return m.consensus.MineBlockFrom(block, m.config.NonceStart, m.config.NonceStride, m.stopMining)
+}
+
+// noncesTried returns how many nonces mineBlock tried when its last attempt
+// was lastNonce.
+func (m *Miner) noncesTried(lastNonce uint64) uint64 {
+	stride := m.config.NonceStride
+	if stride == 0 {
+		stride = 1
+	}
+	if lastNonce < m.config.NonceStart {
+		return 0
+	}
+	return (lastNonce-m.config.NonceStart)/stride + 1
 }
 
 // calculateTransactionHash calculates the hash of a transaction
@@ -2,6 +2,7 @@ package miner
 
 import (
 	"context"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"sync"
@@ -11,8 +12,11 @@ import (
 	"github.com/palaseus/adrenochain/pkg/block"
 	"github.com/palaseus/adrenochain/pkg/chain"
 	"github.com/palaseus/adrenochain/pkg/consensus"
+	"github.com/palaseus/adrenochain/pkg/crypto_utils"
 	"github.com/palaseus/adrenochain/pkg/mempool"
 	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/palaseus/adrenochain/pkg/utxo"
+	"github.com/palaseus/adrenochain/pkg/wallet"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -46,6 +50,25 @@ func TestMiner(t *testing.T) {
 	assert.False(t, miner.IsMining())
 }
 
+func TestReadOnlyMiner(t *testing.T) {
+	dataDir := "./test_miner_data_test_read_only"
+	defer os.RemoveAll(dataDir)
+
+	storage, err := storage.NewStorage(&storage.StorageConfig{DataDir: dataDir})
+	require.NoError(t, err)
+	defer storage.Close()
+
+	consensusConfig := consensus.DefaultConsensusConfig()
+	chainInstance, err := chain.NewChain(chain.DefaultChainConfig(), consensusConfig, storage)
+	require.NoError(t, err)
+	config := DefaultMinerConfig()
+	config.ReadOnly = true
+	miner := NewMiner(chainInstance, mempool.NewMempool(mempool.TestMempoolConfig()), config, consensusConfig)
+
+	assert.ErrorIs(t, miner.StartMining(), ErrReadOnly)
+	assert.False(t, miner.IsMining())
+}
+
 func TestCreateNewBlock(t *testing.T) {
 	dataDir := "./test_miner_data_test_create_new_block"
 	defer os.RemoveAll(dataDir)
@@ -628,12 +651,8 @@ func TestMinerUncoveredFunctions(t *testing.T) {
 		assert.Len(t, tx.Outputs, 1) // Coinbase has one output
 		assert.Equal(t, config.CoinbaseReward, tx.Outputs[0].Value)
 		
-		// Check ScriptPubKey - if CoinbaseAddress is empty, it should use fallback "coinbase"
-		if config.CoinbaseAddress == "" {
-			assert.Equal(t, []byte("coinbase"), tx.Outputs[0].ScriptPubKey)
-		} else {
-			assert.Equal(t, []byte(config.CoinbaseAddress), tx.Outputs[0].ScriptPubKey)
-		}
+		// Without a coinbase address the reward is burned
+		assert.Equal(t, []byte{block.OpReturn}, tx.Outputs[0].ScriptPubKey)
 	})
 
 	t.Run("StartMiningRestart", func(t *testing.T) {
@@ -1638,3 +1657,447 @@ func TestMinerUltraFinal(t *testing.T) {
 		}
 	})
 }
+
+// TestFreeTransactionQuota tests that transactions relayed on coin-age
+// priority are only mined within the configured free space.
+func TestFreeTransactionQuota(t *testing.T) {
+	storage, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storage.Close()
+
+	consensusConfig := consensus.DefaultConsensusConfig()
+	chainInstance, err := chain.NewChain(chain.DefaultChainConfig(), consensusConfig, storage)
+	require.NoError(t, err)
+
+	mempoolConfig := mempool.TestMempoolConfig()
+	mempoolConfig.MaxSize = 100000
+	mempoolConfig.FreeTxMinPriority = 100000
+	mp := mempool.NewMempool(mempoolConfig)
+
+	utxoSet := utxo.NewUTXOSet()
+	mp.SetUTXOSet(utxoSet)
+
+	// newTx spends a confirmed output of the given value; all transactions
+	// are 211 bytes
+	newTx := func(name string, value, fee uint64) *block.Transaction {
+		prevHash := make([]byte, 32)
+		copy(prevHash, "prev_"+name)
+		utxoSet.AddUTXO(&utxo.UTXO{TxHash: prevHash, Value: value, ScriptPubKey: []byte("owner"), Height: 0})
+
+		tx := &block.Transaction{
+			Version: 1,
+			Inputs: []*block.TxInput{{
+				PrevTxHash: prevHash,
+				ScriptSig:  make([]byte, 129),
+				Sequence:   0xffffffff,
+			}},
+			Outputs: []*block.TxOutput{{Value: 1000, ScriptPubKey: []byte("pubkey")}},
+			Fee:     fee,
+			Hash:    make([]byte, 32),
+		}
+		copy(tx.Hash, name)
+		return tx
+	}
+
+	highPriority := newTx("high", 1000000000, 0)
+	mediumPriority := newTx("medium", 100000000, 0)
+	paid := newTx("paid", 5000, 422)
+	require.NoError(t, mp.AddTransaction(highPriority))
+	require.NoError(t, mp.AddTransaction(mediumPriority))
+	require.NoError(t, mp.AddTransaction(paid))
+
+	// Young or low-value coins do not qualify for free relay
+	lowPriority := newTx("low", 1000000, 0)
+	assert.Error(t, mp.AddTransaction(lowPriority))
+
+	config := DefaultMinerConfig()
+	config.FreeTxSpace = 300
+	miner := NewMiner(chainInstance, mp, config, consensusConfig)
+
+	newBlock := miner.createNewBlock(chainInstance.GetBestBlock())
+	require.Len(t, newBlock.Transactions, 3)
+	assert.Equal(t, highPriority, newBlock.Transactions[1])
+	assert.Equal(t, paid, newBlock.Transactions[2])
+
+	// Without a free quota only fee-paying transactions are mined
+	config.FreeTxSpace = 0
+	newBlock = miner.createNewBlock(chainInstance.GetBestBlock())
+	require.Len(t, newBlock.Transactions, 2)
+	assert.Equal(t, paid, newBlock.Transactions[1])
+}
+
+func TestMinerConfigValidate(t *testing.T) {
+	assert.NoError(t, DefaultMinerConfig().Validate())
+
+	config := DefaultMinerConfig()
+	config.MiningThreads = 0
+	config.BlockTime = 0
+	config.FreeTxSpace = config.MaxBlockSize + 1
+	config.CoinbaseAddress = "miner_reward"
+	err := config.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "mining threads must be positive, got 0")
+	assert.Contains(t, err.Error(), "block time must be positive")
+	assert.Contains(t, err.Error(), "free transaction space 1000001 exceeds max block size 1000000")
+	assert.Contains(t, err.Error(), `invalid coinbase address "miner_reward"`)
+
+	// A wallet address is paid through its public key hash
+	pubKeyHash := make([]byte, utxo.P2PKHScriptSize)
+	pubKeyHash[0] = 0x42
+	config = DefaultMinerConfig()
+	config.CoinbaseAddress = wallet.ScriptAddress(pubKeyHash)
+	assert.NoError(t, config.Validate())
+	assert.Equal(t, pubKeyHash, (&Miner{config: config}).payoutScript())
+}
+
+// TestBlockTemplateLimits tests that block templates stop including
+// transactions at the block size and signature operation limits, so that
+// mined blocks pass chain validation.
+func TestBlockTemplateLimits(t *testing.T) {
+	storage, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storage.Close()
+
+	consensusConfig := consensus.DefaultConsensusConfig()
+	chainConfig := chain.DefaultChainConfig()
+	chainInstance, err := chain.NewChain(chainConfig, consensusConfig, storage)
+	require.NoError(t, err)
+
+	mempoolConfig := mempool.TestMempoolConfig()
+	mempoolConfig.MaxSize = 1000000
+	mp := mempool.NewMempool(mempoolConfig)
+	utxoSet := utxo.NewUTXOSet()
+	mp.SetUTXOSet(utxoSet)
+
+	for i := 0; i < 50; i++ {
+		prevHash := make([]byte, 32)
+		copy(prevHash, fmt.Sprintf("prev_%d", i))
+		utxoSet.AddUTXO(&utxo.UTXO{TxHash: prevHash, Value: 5000, ScriptPubKey: []byte("owner"), Height: 0})
+
+		tx := &block.Transaction{
+			Version: 1,
+			Inputs: []*block.TxInput{{
+				PrevTxHash: prevHash,
+				ScriptSig:  make([]byte, 129),
+				Sequence:   0xffffffff,
+			}},
+			Outputs: []*block.TxOutput{{Value: 1000, ScriptPubKey: []byte("pubkey")}},
+			Fee:     422,
+			Hash:    make([]byte, 32),
+		}
+		copy(tx.Hash, fmt.Sprintf("tx_%d", i))
+		require.NoError(t, mp.AddTransaction(tx))
+	}
+
+	// Room for the coinbase and five of the 211 byte transactions
+	config := DefaultMinerConfig()
+	config.MaxBlockSize = 128 + 5*211 + 100
+	miner := NewMiner(chainInstance, mp, config, consensusConfig)
+
+	template := miner.createNewBlock(chainInstance.GetBestBlock())
+	assert.Len(t, template.Transactions, 6)
+	assert.LessOrEqual(t, chainInstance.GetBlockSize(template), config.MaxBlockSize)
+
+	chainConfig.MaxBlockSize = config.MaxBlockSize
+	assert.NoError(t, chainInstance.CheckBlockLimits(template))
+	template.AddTransaction(mp.GetTransactionsForBlock(1000000)[10])
+	assert.ErrorContains(t, chainInstance.CheckBlockLimits(template), "block size")
+
+	// The signature operation limit stops inclusion the same way
+	config.MaxBlockSize = DefaultMinerConfig().MaxBlockSize
+	config.MaxBlockSigOps = 3
+	template = miner.createNewBlock(chainInstance.GetBestBlock())
+	assert.Len(t, template.Transactions, 4)
+
+	chainConfig.MaxBlockSize = config.MaxBlockSize
+	chainConfig.MaxBlockSigOps = config.MaxBlockSigOps
+	assert.NoError(t, chainInstance.CheckBlockLimits(template))
+	template.AddTransaction(mp.GetTransactionsForBlock(1000000)[10])
+	assert.ErrorContains(t, chainInstance.CheckBlockLimits(template), "signature operations 4 exceed maximum 3")
+
+	// So does the transaction count limit, which includes the coinbase
+	config.MaxBlockSigOps = DefaultMinerConfig().MaxBlockSigOps
+	config.MaxTransactionsPerBlock = 8
+	template = miner.createNewBlock(chainInstance.GetBestBlock())
+	assert.Len(t, template.Transactions, 8)
+
+	chainConfig.MaxBlockSigOps = config.MaxBlockSigOps
+	chainConfig.MaxTransactionsPerBlock = config.MaxTransactionsPerBlock
+	assert.NoError(t, chainInstance.CheckBlockLimits(template))
+	template.AddTransaction(mp.GetTransactionsForBlock(1000000)[10])
+	assert.ErrorContains(t, chainInstance.CheckBlockLimits(template), "transaction count 9 exceeds maximum 8")
+}
+
+func TestMinedTransactionsLeaveMempool(t *testing.T) {
+	storage, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storage.Close()
+
+	consensusConfig := consensus.DefaultConsensusConfig()
+	chainInstance, err := chain.NewChain(chain.DefaultChainConfig(), consensusConfig, storage)
+	require.NoError(t, err)
+	mp := mempool.NewMempool(mempool.TestMempoolConfig())
+	mp.SetUTXOSet(chainInstance.UTXOSet)
+
+	// Pay the coinbases to a key the test can spend with
+	ctu := crypto_utils.NewCryptoTestUtils(t)
+	kp := ctu.GenerateTestKeyPair()
+	script, err := hex.DecodeString(kp.Address)
+	require.NoError(t, err)
+	config := DefaultMinerConfig()
+	config.CoinbaseAddress = wallet.ScriptAddress(script)
+	miner := NewMiner(chainInstance, mp, config, consensusConfig)
+
+	keys := map[string]*crypto_utils.TestKeyPair{kp.Address: kp}
+	spend := func(prev *block.Transaction, index uint32, outputs ...*block.TxOutput) *block.Transaction {
+		tx := ctu.CreateSignedTransaction(
+			[]*block.TxInput{{PrevTxHash: prev.Hash, PrevTxIndex: index, Sequence: 0xffffffff}},
+			outputs, keys, 1000)
+		tx.Hash = tx.CalculateHash() // consensus hashes transactions itself
+		return tx
+	}
+
+	// Split the first coinbase so the mempool can hold independent spends
+	require.NoError(t, miner.mineNextBlock())
+	coinbase := chainInstance.GetBestBlock().Transactions[0]
+	var outputs []*block.TxOutput
+	for i := 0; i < 4; i++ {
+		outputs = append(outputs, &block.TxOutput{Value: 10000, ScriptPubKey: script})
+	}
+	outputs[0].Value = coinbase.Outputs[0].Value - 30000 - 1000
+	split := spend(coinbase, 0, outputs...)
+	require.NoError(t, mp.AddTransaction(split))
+	require.NoError(t, miner.mineNextBlock())
+	require.Equal(t, 0, mp.GetTransactionCount())
+
+	var pending []*block.Transaction
+	for i := uint32(0); i < 3; i++ {
+		tx := spend(split, i, &block.TxOutput{Value: outputs[i].Value - 1000, ScriptPubKey: script})
+		require.NoError(t, mp.AddTransaction(tx))
+		pending = append(pending, tx)
+	}
+	require.Equal(t, 3, mp.GetTransactionCount())
+
+	require.NoError(t, miner.mineNextBlock())
+	mined := chainInstance.GetBestBlock()
+	require.Equal(t, uint64(3), mined.Header.Height)
+	require.Len(t, mined.Transactions, 4)
+	for _, tx := range pending {
+		assert.Nil(t, mp.GetTransaction(tx.Hash))
+	}
+	assert.Equal(t, 0, mp.GetTransactionCount())
+	assert.Empty(t, mp.ConfirmedInPool(mined))
+	assert.NoError(t, miner.checkMempoolConsistency(mined))
+
+	// Transactions left behind by a missed removal are reported and dropped
+	leftover := spend(split, 3, &block.TxOutput{Value: 9000, ScriptPubKey: script})
+	require.NoError(t, mp.AddTransaction(leftover))
+	stale := &block.Block{Header: mined.Header, Transactions: []*block.Transaction{mined.Transactions[0], leftover}}
+	assert.ErrorContains(t, miner.checkMempoolConsistency(stale), "1 transactions of block 3")
+	assert.Nil(t, mp.GetTransaction(leftover.Hash))
+}
+
+func TestGetMiningInfo(t *testing.T) {
+	storage, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storage.Close()
+
+	consensusConfig := consensus.DefaultConsensusConfig()
+	chainInstance, err := chain.NewChain(chain.DefaultChainConfig(), consensusConfig, storage)
+	require.NoError(t, err)
+	miner := NewMiner(chainInstance, mempool.NewMempool(mempool.TestMempoolConfig()), DefaultMinerConfig(), consensusConfig)
+
+	info := miner.GetMiningInfo()
+	assert.False(t, info.Mining)
+	assert.Equal(t, 0, info.Workers)
+	assert.Equal(t, uint64(1), info.Height)
+	assert.Equal(t, chainInstance.CalculateNextDifficulty(), info.Difficulty)
+	assert.NotEmpty(t, info.Target)
+	assert.Zero(t, info.BlocksFound)
+	assert.Zero(t, info.HashRate)
+
+	require.NoError(t, miner.mineNextBlock())
+	info = miner.GetMiningInfo()
+	assert.Equal(t, uint64(1), info.BlocksFound)
+	assert.Equal(t, uint64(2), info.Height)
+	assert.Greater(t, info.Hashes, uint64(0))
+	assert.Greater(t, info.HashRate, 0.0)
+}
+
+func TestMinerNonceRange(t *testing.T) {
+	storage, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storage.Close()
+
+	consensusConfig := consensus.DefaultConsensusConfig()
+	chainInstance, err := chain.NewChain(chain.DefaultChainConfig(), consensusConfig, storage)
+	require.NoError(t, err)
+	config := DefaultMinerConfig()
+	config.NonceStart = 500
+	config.NonceStride = 3
+	miner := NewMiner(chainInstance, mempool.NewMempool(mempool.TestMempoolConfig()), config, consensusConfig)
+
+	require.NoError(t, miner.mineNextBlock())
+	nonce := chainInstance.GetBestBlock().Header.Nonce
+	assert.GreaterOrEqual(t, nonce, uint64(500))
+	assert.Zero(t, (nonce-500)%3)
+	assert.Equal(t, (nonce-500)/3+1, miner.GetMiningInfo().Hashes)
+}
+
+// TestBlockTemplatePackageSize tests that a high-fee child is only included
+// together with its unconfirmed parent, and that a package too large for the
+// remaining block space or the package size limit is skipped in favor of
+// smaller transactions.
+func TestBlockTemplatePackageSize(t *testing.T) {
+	storage, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storage.Close()
+
+	consensusConfig := consensus.DefaultConsensusConfig()
+	chainInstance, err := chain.NewChain(chain.DefaultChainConfig(), consensusConfig, storage)
+	require.NoError(t, err)
+
+	mp := mempool.NewMempool(mempool.TestMempoolConfig())
+	newTx := func(name string, scriptSigSize int, fee uint64, prevHash []byte) *block.Transaction {
+		tx := &block.Transaction{
+			Version: 1,
+			Inputs: []*block.TxInput{{
+				PrevTxHash: prevHash,
+				ScriptSig:  make([]byte, scriptSigSize),
+				Sequence:   0xffffffff,
+			}},
+			Outputs: []*block.TxOutput{{Value: 1000, ScriptPubKey: []byte("pubkey")}},
+			Fee:     fee,
+			Hash:    make([]byte, 32),
+		}
+		copy(tx.Hash, name)
+		return tx
+	}
+	confirmed := func(name string) []byte {
+		hash := make([]byte, 32)
+		copy(hash, name)
+		return hash
+	}
+
+	// A large low-fee parent with a small child paying for both
+	parent := newTx("parent", 1129, 1211, confirmed("prev_parent"))
+	child := newTx("child", 129, 211*30, parent.Hash)
+	require.NoError(t, mp.AddTransaction(parent))
+	require.NoError(t, mp.AddTransaction(child))
+	require.Equal(t, uint64(1211), chainInstance.GetTransactionSize(parent))
+
+	var small [][]byte
+	for i := 0; i < 4; i++ {
+		tx := newTx(fmt.Sprintf("small_%d", i), 129, 211*5, confirmed(fmt.Sprintf("prev_%d", i)))
+		require.NoError(t, mp.AddTransaction(tx))
+		small = append(small, tx.Hash)
+	}
+
+	hashes := func(b *block.Block) [][]byte {
+		var result [][]byte
+		for _, tx := range b.Transactions[1:] {
+			result = append(result, tx.Hash)
+		}
+		return result
+	}
+
+	// The package does not fit next to the coinbase, the small transactions do
+	config := DefaultMinerConfig()
+	config.MaxBlockSize = 128 + 4*211 + 300
+	miner := NewMiner(chainInstance, mp, config, consensusConfig)
+	template := miner.createNewBlock(chainInstance.GetBestBlock())
+	assert.ElementsMatch(t, small, hashes(template))
+	assert.LessOrEqual(t, chainInstance.GetBlockSize(template), config.MaxBlockSize)
+
+	// With room in the block, the package size limit keeps it out
+	config.MaxBlockSize = DefaultMinerConfig().MaxBlockSize
+	config.MaxPackageSize = 1000
+	template = miner.createNewBlock(chainInstance.GetBestBlock())
+	assert.ElementsMatch(t, small, hashes(template))
+
+	// Without the limit the child comes first by fee rate, after its parent
+	config.MaxPackageSize = 0
+	template = miner.createNewBlock(chainInstance.GetBestBlock())
+	included := hashes(template)
+	require.Len(t, included, 6)
+	assert.Equal(t, [][]byte{parent.Hash, child.Hash}, included[:2])
+	assert.ElementsMatch(t, small, included[2:])
+}
+
+func TestSelectTransactionsByPackage(t *testing.T) {
+	storage, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer storage.Close()
+
+	consensusConfig := consensus.DefaultConsensusConfig()
+	chainInstance, err := chain.NewChain(chain.DefaultChainConfig(), consensusConfig, storage)
+	require.NoError(t, err)
+
+	mp := mempool.NewMempool(mempool.TestMempoolConfig())
+	newTx := func(name string, feeRate uint64, prevHash []byte, prevIndex uint32) *block.Transaction {
+		tx := &block.Transaction{
+			Version: 1,
+			Inputs: []*block.TxInput{{
+				PrevTxHash:  prevHash,
+				PrevTxIndex: prevIndex,
+				ScriptSig:   make([]byte, 129),
+				Sequence:    0xffffffff,
+			}},
+			Outputs: []*block.TxOutput{
+				{Value: 1000, ScriptPubKey: []byte("pubkey")},
+				{Value: 1000, ScriptPubKey: []byte("pubkey")},
+			},
+			Hash: make([]byte, 32),
+		}
+		copy(tx.Hash, name)
+		tx.Fee = feeRate * chainInstance.GetTransactionSize(tx)
+		require.NoError(t, mp.AddTransaction(tx))
+		return tx
+	}
+	confirmed := func(name string) []byte {
+		hash := make([]byte, 32)
+		copy(hash, name)
+		return hash
+	}
+
+	// The parent pays 1, its children 20 and 8, and an unrelated
+	// transaction 12. The first child pays more than the unrelated one, but
+	// its package only 10.5, so it goes after it. The second child's
+	// package pays 4.5, but once the first child brings in the parent it
+	// pays 8.
+	parent := newTx("parent", 1, confirmed("prev_parent"), 0)
+	other := newTx("other", 12, confirmed("prev_other"), 0)
+	high := newTx("high", 20, parent.Hash, 0)
+	low := newTx("low", 8, parent.Hash, 1)
+	size := chainInstance.GetTransactionSize(parent)
+
+	miner := NewMiner(chainInstance, mp, DefaultMinerConfig(), consensusConfig)
+	var selected [][]byte
+	miner.SelectTransactionsByPackage(mp.GetPackagesForBlock(), map[string]bool{}, func(txs []*block.Transaction) bool {
+		for _, tx := range txs {
+			selected = append(selected, tx.Hash)
+		}
+		return true
+	})
+	assert.Equal(t, [][]byte{other.Hash, parent.Hash, high.Hash, low.Hash}, selected)
+
+	// The block template follows the same order after the coinbase, and
+	// skips the second child's package when it no longer fits
+	config := DefaultMinerConfig()
+	config.MaxBlockSize = 128 + 3*size + 100
+	miner = NewMiner(chainInstance, mp, config, consensusConfig)
+	template := miner.createNewBlock(chainInstance.GetBestBlock())
+	require.Len(t, template.Transactions, 4)
+	assert.Empty(t, template.Transactions[0].Inputs)
+	assert.Equal(t, [][]byte{other.Hash, parent.Hash, high.Hash}, [][]byte{
+		template.Transactions[1].Hash, template.Transactions[2].Hash, template.Transactions[3].Hash,
+	})
+
+	// A child never goes in without its parent: with room for two
+	// transactions, neither child's package fits next to the unrelated
+	// transaction and the parent goes in alone
+	config.MaxBlockSize = 128 + 2*size + 100
+	template = miner.createNewBlock(chainInstance.GetBestBlock())
+	require.Len(t, template.Transactions, 3)
+	assert.Equal(t, [][]byte{other.Hash, parent.Hash}, [][]byte{template.Transactions[1].Hash, template.Transactions[2].Hash})
+}
@@ -0,0 +1,67 @@
+package miner
+
+import (
+	"fmt"
+	"time"
+)
+
+// MiningInfo describes the miner's current work and what it achieved since
+// the node started.
+type MiningInfo struct {
+	Mining      bool    `json:"mining"`       // Mining reports whether the miner is running.
+	Workers     int     `json:"workers"`      // Workers is the number of goroutines searching nonces.
+	Height      uint64  `json:"height"`       // Height is the height of the block being mined.
+	Difficulty  uint64  `json:"difficulty"`   // Difficulty is the difficulty of the block being mined.
+	Target      string  `json:"target"`       // Target is the hex hash a mined block must not exceed.
+	BlocksFound uint64  `json:"blocks_found"` // BlocksFound counts blocks mined and accepted this session.
+	Hashes      uint64  `json:"hashes"`       // Hashes counts the nonces tried this session.
+	HashRate    float64 `json:"hash_rate"`    // HashRate is the measured local hash rate in hashes per second.
+}
+
+// GetMiningInfo returns the miner's current target and difficulty, whether
+// it is mining and its statistics for this session. The hash rate is
+// measured from the nonces tried over the time spent searching them.
+func (m *Miner) GetMiningInfo() MiningInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	info := MiningInfo{
+		Mining:      m.isMining,
+		BlocksFound: m.blocksFound,
+		Hashes:      m.hashes,
+	}
+	if m.isMining {
+		// Nonces are searched by the single mining goroutine
+		info.Workers = 1
+	}
+	if m.hashTime > 0 {
+		info.HashRate = float64(m.hashes) / m.hashTime.Seconds()
+	}
+
+	// The block template being mined, if any, fixes the difficulty of the
+	// next block
+	if bestBlock := m.chain.GetBestBlock(); bestBlock != nil {
+		info.Height = bestBlock.Header.Height + 1
+	}
+	info.Difficulty = m.chain.CalculateNextDifficulty()
+	if m.isMining && m.currentBlock != nil && m.currentBlock.Header.Height == info.Height {
+		info.Difficulty = m.currentBlock.Header.Difficulty
+	}
+	info.Target = fmt.Sprintf("%x", m.consensus.TargetForDifficulty(info.Difficulty))
+	return info
+}
+
+// recordHashes adds nonces tried over elapsed to the session statistics.
+func (m *Miner) recordHashes(hashes uint64, elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hashes += hashes
+	m.hashTime += elapsed
+}
+
+// recordBlockFound counts a mined block accepted by the chain.
+func (m *Miner) recordBlockFound() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blocksFound++
+}
@@ -0,0 +1,146 @@
+package miner
+
+import (
+	"container/heap"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/mempool"
+)
+
+// packageCandidate is a mempool package not yet included in the block
+// template, with the fees and size of its transactions still missing from
+// the template.
+type packageCandidate struct {
+	pkg     *mempool.TxPackage
+	order   int // order is the position of the package in the mempool's list, breaking fee rate ties.
+	fees    uint64
+	size    uint64
+	version int  // version counts recomputations, so that stale queue items are skipped.
+	done    bool // done marks packages whose transactions are all in the template.
+}
+
+// packageItem queues a candidate at the fee rate it had when pushed.
+type packageItem struct {
+	candidate *packageCandidate
+	fees      uint64
+	size      uint64
+	version   int
+}
+
+// packageQueue is a max-heap of package items by fee rate.
+type packageQueue []packageItem
+
+func (q packageQueue) Len() int { return len(q) }
+
+func (q packageQueue) Less(i, j int) bool {
+	// Compare fees/size without dividing, so that close fee rates are not
+	// rounded to the same value
+	left, right := q[i].fees*q[j].size, q[j].fees*q[i].size
+	if left != right {
+		return left > right
+	}
+	return q[i].candidate.order < q[j].candidate.order
+}
+
+func (q packageQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *packageQueue) Push(x any) { *q = append(*q, x.(packageItem)) }
+
+func (q *packageQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// SelectTransactionsByPackage offers mempool packages to a block template
+// in descending package fee rate: the fees of the transactions of a package
+// not yet in the template over their size. A low-fee parent is thereby
+// included for the sake of a high-fee child, and once a package is
+// included the fee rates of the packages sharing its transactions are
+// recomputed without them.
+//
+// included holds the hashes of the transactions already in the template.
+// add is called with the missing transactions of each package, parents
+// before children, and reports whether it added them all; a package that
+// does not fit is skipped as a whole, so a child is never added without
+// its unconfirmed parents, and smaller packages after it still get in. Packages larger than MaxPackageSize are not
+// offered.
+func (m *Miner) SelectTransactionsByPackage(packages []*mempool.TxPackage, included map[string]bool, add func([]*block.Transaction) bool) {
+	containing := make(map[string][]*packageCandidate)
+	queue := make(packageQueue, 0, len(packages))
+	for i, pkg := range packages {
+		if m.config.MaxPackageSize > 0 && pkg.Size > m.config.MaxPackageSize {
+			continue
+		}
+		candidate := &packageCandidate{pkg: pkg, order: i}
+		for _, tx := range pkg.Transactions {
+			containing[string(tx.Hash)] = append(containing[string(tx.Hash)], candidate)
+		}
+		if m.updateCandidate(candidate, included) {
+			queue = append(queue, packageItem{candidate, candidate.fees, candidate.size, candidate.version})
+		}
+	}
+	heap.Init(&queue)
+
+	for queue.Len() > 0 {
+		item := heap.Pop(&queue).(packageItem)
+		candidate := item.candidate
+		if candidate.done || item.version != candidate.version {
+			continue
+		}
+
+		// A package that does not fit is offered again only if other
+		// packages include some of its transactions, leaving less to add
+		missing := m.missingTransactions(candidate.pkg, included)
+		if !add(missing) {
+			continue
+		}
+		candidate.done = true
+
+		// Packages sharing the transactions just added are worth their
+		// remaining transactions only
+		for _, tx := range missing {
+			included[string(tx.Hash)] = true
+		}
+		for _, tx := range missing {
+			for _, other := range containing[string(tx.Hash)] {
+				if other.done {
+					continue
+				}
+				if m.updateCandidate(other, included) {
+					heap.Push(&queue, packageItem{other, other.fees, other.size, other.version})
+				}
+			}
+		}
+	}
+}
+
+// updateCandidate recomputes the fees and size of the transactions of a
+// candidate missing from the template. It reports whether any remain,
+// marking the candidate done otherwise.
+func (m *Miner) updateCandidate(candidate *packageCandidate, included map[string]bool) bool {
+	candidate.fees, candidate.size = 0, 0
+	for _, tx := range m.missingTransactions(candidate.pkg, included) {
+		candidate.fees += tx.Fee
+		candidate.size += m.chain.GetTransactionSize(tx)
+	}
+	candidate.version++
+	if candidate.size == 0 {
+		candidate.done = true
+		return false
+	}
+	return true
+}
+
+// missingTransactions returns the transactions of a package not in the
+// template, in package order.
+func (m *Miner) missingTransactions(pkg *mempool.TxPackage, included map[string]bool) []*block.Transaction {
+	var missing []*block.Transaction
+	for _, tx := range pkg.Transactions {
+		if !included[string(tx.Hash)] {
+			missing = append(missing, tx)
+		}
+	}
+	return missing
+}
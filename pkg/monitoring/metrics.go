@@ -5,6 +5,9 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/palaseus/adrenochain/pkg/chain"
+	"github.com/palaseus/adrenochain/pkg/miner"
 )
 
 // Metrics represents a collection of blockchain metrics
@@ -17,12 +20,19 @@ type Metrics struct {
 	totalTxns       int64
 	pendingTxns     int64
 	chainDifficulty float64
+	safeMode        bool // set while the chain refuses blocks after a storage write failure
 
 	// Network metrics
 	connectedPeers int64
 	totalPeers     int64
 	networkLatency int64 // in milliseconds
 
+	// Block propagation latencies from mining to peer acknowledgement, in milliseconds
+	blockPropagationP50     int64
+	blockPropagationP90     int64
+	blockPropagationP99     int64
+	blockPropagationSamples int64
+
 	// Mining metrics
 	hashRate      int64 // hashes per second
 	blocksMined   int64
@@ -32,6 +42,7 @@ type Metrics struct {
 	blockProcessingTime int64 // in milliseconds
 	txnProcessingTime   int64 // in milliseconds
 	memoryUsage         int64 // in bytes
+	validationTimings   chain.ValidationStats
 
 	// Error metrics
 	totalErrors      int64
@@ -46,8 +57,15 @@ type Metrics struct {
 	// Additional blockchain metrics
 	utxoCount      int64
 	chainSize      int64 // in bytes
-	orphanedBlocks int64
+	orphanedBlocks int64 // blocks disconnected from the active chain by reorgs
+	reorgs         int64
+	deepReorgs     int64 // reorgs at least the configured deep reorg depth
+	lastReorgDepth int64
+	maxReorgDepth  int64
 	rejectedBlocks int64
+	droppedBlocks  int64 // blocks dropped because the processing queue was full
+	auditFailures  int64 // UTXO set audits that found an inconsistency
+	warnings       int64 // chain warnings operators should look into
 	rejectedTxns   int64
 	avgBlockTime   int64 // in seconds
 	avgTxnPerBlock float64
@@ -88,6 +106,18 @@ func (m *Metrics) UpdateChainDifficulty(difficulty float64) {
 	m.chainDifficulty = difficulty
 }
 
+// SetSafeMode sets whether the chain is in read-only safe mode
+func (m *Metrics) SetSafeMode(enabled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.safeMode = enabled
+}
+
+// UpdateWarnings updates the number of chain warnings raised
+func (m *Metrics) UpdateWarnings(count int64) {
+	atomic.StoreInt64(&m.warnings, count)
+}
+
 // UpdateConnectedPeers updates the number of connected peers
 func (m *Metrics) UpdateConnectedPeers(count int64) {
 	atomic.StoreInt64(&m.connectedPeers, count)
@@ -103,6 +133,15 @@ func (m *Metrics) UpdateNetworkLatency(latency int64) {
 	atomic.StoreInt64(&m.networkLatency, latency)
 }
 
+// UpdateBlockPropagation updates the block propagation latency percentiles
+// and the number of acknowledgements they were computed from
+func (m *Metrics) UpdateBlockPropagation(p50, p90, p99 time.Duration, samples int64) {
+	atomic.StoreInt64(&m.blockPropagationP50, p50.Milliseconds())
+	atomic.StoreInt64(&m.blockPropagationP90, p90.Milliseconds())
+	atomic.StoreInt64(&m.blockPropagationP99, p99.Milliseconds())
+	atomic.StoreInt64(&m.blockPropagationSamples, samples)
+}
+
 // UpdateHashRate updates the current hash rate
 func (m *Metrics) UpdateHashRate(rate int64) {
 	atomic.StoreInt64(&m.hashRate, rate)
@@ -113,6 +152,14 @@ func (m *Metrics) UpdateBlocksMined(count int64) {
 	atomic.StoreInt64(&m.blocksMined, count)
 }
 
+// UpdateMiningInfo updates the hash rate, blocks mined and mining state from
+// the miner's statistics
+func (m *Metrics) UpdateMiningInfo(info miner.MiningInfo) {
+	m.UpdateHashRate(int64(info.HashRate))
+	m.UpdateBlocksMined(int64(info.BlocksFound))
+	m.SetMiningEnabled(info.Mining)
+}
+
 // SetMiningEnabled sets whether mining is enabled
 func (m *Metrics) SetMiningEnabled(enabled bool) {
 	m.mu.Lock()
@@ -179,11 +226,40 @@ func (m *Metrics) IncrementOrphanedBlocks() {
 	atomic.AddInt64(&m.orphanedBlocks, 1)
 }
 
+// UpdateReorgStats updates the reorganization counts and depths and the
+// number of blocks they orphaned
+func (m *Metrics) UpdateReorgStats(stats chain.ReorgStats) {
+	atomic.StoreInt64(&m.reorgs, int64(stats.Reorgs))
+	atomic.StoreInt64(&m.deepReorgs, int64(stats.DeepReorgs))
+	atomic.StoreInt64(&m.lastReorgDepth, int64(stats.LastDepth))
+	atomic.StoreInt64(&m.maxReorgDepth, int64(stats.MaxDepth))
+	atomic.StoreInt64(&m.orphanedBlocks, int64(stats.OrphanedBlocks))
+}
+
+// UpdateValidationStats updates the timing of each block validation stage
+func (m *Metrics) UpdateValidationStats(stats chain.ValidationStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.validationTimings = stats
+}
+
 // IncrementRejectedBlocks increments the rejected blocks count
 func (m *Metrics) IncrementRejectedBlocks() {
 	atomic.AddInt64(&m.rejectedBlocks, 1)
 }
 
+// IncrementDroppedBlocks increments the count of received blocks dropped
+// because the block processing queue was full
+func (m *Metrics) IncrementDroppedBlocks() {
+	atomic.AddInt64(&m.droppedBlocks, 1)
+}
+
+// IncrementUTXOAuditFailures increments the count of UTXO set audits that
+// found an inconsistency
+func (m *Metrics) IncrementUTXOAuditFailures() {
+	atomic.AddInt64(&m.auditFailures, 1)
+}
+
 // IncrementRejectedTxns increments the rejected transactions count
 func (m *Metrics) IncrementRejectedTxns() {
 	atomic.AddInt64(&m.rejectedTxns, 1)
@@ -224,17 +300,31 @@ func (m *Metrics) GetMetrics() map[string]interface{} {
 			"utxo_count":             atomic.LoadInt64(&m.utxoCount),
 			"chain_size_bytes":       atomic.LoadInt64(&m.chainSize),
 			"orphaned_blocks":        atomic.LoadInt64(&m.orphanedBlocks),
+			"reorgs":                 atomic.LoadInt64(&m.reorgs),
+			"deep_reorgs":            atomic.LoadInt64(&m.deepReorgs),
+			"last_reorg_depth":       atomic.LoadInt64(&m.lastReorgDepth),
+			"max_reorg_depth":        atomic.LoadInt64(&m.maxReorgDepth),
 			"rejected_blocks":        atomic.LoadInt64(&m.rejectedBlocks),
+			"dropped_blocks":         atomic.LoadInt64(&m.droppedBlocks),
+			"utxo_audit_failures":    atomic.LoadInt64(&m.auditFailures),
+			"warnings":               atomic.LoadInt64(&m.warnings),
 			"rejected_transactions":  atomic.LoadInt64(&m.rejectedTxns),
 			"avg_block_time_seconds": atomic.LoadInt64(&m.avgBlockTime),
 			"avg_txn_per_block":      m.avgTxnPerBlock,
 			"avg_block_size_bytes":   atomic.LoadInt64(&m.avgBlockSize),
+			"safe_mode":              m.safeMode,
 		},
 		"network": map[string]interface{}{
 			"connected_peers": atomic.LoadInt64(&m.connectedPeers),
 			"total_peers":     atomic.LoadInt64(&m.totalPeers),
 			"network_latency": atomic.LoadInt64(&m.networkLatency),
 			"last_sync_time":  m.lastSyncTime,
+			"block_propagation": map[string]interface{}{
+				"p50_ms":  atomic.LoadInt64(&m.blockPropagationP50),
+				"p90_ms":  atomic.LoadInt64(&m.blockPropagationP90),
+				"p99_ms":  atomic.LoadInt64(&m.blockPropagationP99),
+				"samples": atomic.LoadInt64(&m.blockPropagationSamples),
+			},
 		},
 		"mining": map[string]interface{}{
 			"hash_rate":      atomic.LoadInt64(&m.hashRate),
@@ -245,6 +335,7 @@ func (m *Metrics) GetMetrics() map[string]interface{} {
 			"block_processing_time": atomic.LoadInt64(&m.blockProcessingTime),
 			"txn_processing_time":   atomic.LoadInt64(&m.txnProcessingTime),
 			"memory_usage":          atomic.LoadInt64(&m.memoryUsage),
+			"block_validation":      m.validationStageMetrics(),
 		},
 		"errors": map[string]interface{}{
 			"total_errors":      atomic.LoadInt64(&m.totalErrors),
@@ -288,6 +379,46 @@ func (m *Metrics) GetPrometheusMetrics() string {
 	prometheus += fmt.Sprintf("# TYPE adrenochain_chain_difficulty gauge\n")
 	prometheus += fmt.Sprintf("adrenochain_chain_difficulty %f\n", m.chainDifficulty)
 
+	safeMode := 0
+	if m.safeMode {
+		safeMode = 1
+	}
+	prometheus += fmt.Sprintf("# HELP adrenochain_safe_mode Whether the chain refuses blocks after a storage write failure\n")
+	prometheus += fmt.Sprintf("# TYPE adrenochain_safe_mode gauge\n")
+	prometheus += fmt.Sprintf("adrenochain_safe_mode %d\n", safeMode)
+
+	prometheus += fmt.Sprintf("# HELP adrenochain_dropped_blocks Received blocks dropped because the processing queue was full\n")
+	prometheus += fmt.Sprintf("# TYPE adrenochain_dropped_blocks counter\n")
+	prometheus += fmt.Sprintf("adrenochain_dropped_blocks %d\n", atomic.LoadInt64(&m.droppedBlocks))
+
+	prometheus += fmt.Sprintf("# HELP adrenochain_utxo_audit_failures UTXO set audits that found an inconsistency\n")
+	prometheus += fmt.Sprintf("# TYPE adrenochain_utxo_audit_failures counter\n")
+	prometheus += fmt.Sprintf("adrenochain_utxo_audit_failures %d\n", atomic.LoadInt64(&m.auditFailures))
+
+	prometheus += fmt.Sprintf("# HELP adrenochain_warnings Chain warnings operators should look into, such as a longer invalid chain\n")
+	prometheus += fmt.Sprintf("# TYPE adrenochain_warnings gauge\n")
+	prometheus += fmt.Sprintf("adrenochain_warnings %d\n", atomic.LoadInt64(&m.warnings))
+
+	prometheus += fmt.Sprintf("# HELP adrenochain_reorgs_total Reorganizations of the active chain\n")
+	prometheus += fmt.Sprintf("# TYPE adrenochain_reorgs_total counter\n")
+	prometheus += fmt.Sprintf("adrenochain_reorgs_total %d\n", atomic.LoadInt64(&m.reorgs))
+
+	prometheus += fmt.Sprintf("# HELP adrenochain_deep_reorgs_total Reorganizations at least the configured deep reorg depth\n")
+	prometheus += fmt.Sprintf("# TYPE adrenochain_deep_reorgs_total counter\n")
+	prometheus += fmt.Sprintf("adrenochain_deep_reorgs_total %d\n", atomic.LoadInt64(&m.deepReorgs))
+
+	prometheus += fmt.Sprintf("# HELP adrenochain_reorg_depth Blocks disconnected by the latest reorganization\n")
+	prometheus += fmt.Sprintf("# TYPE adrenochain_reorg_depth gauge\n")
+	prometheus += fmt.Sprintf("adrenochain_reorg_depth %d\n", atomic.LoadInt64(&m.lastReorgDepth))
+
+	prometheus += fmt.Sprintf("# HELP adrenochain_reorg_depth_max Blocks disconnected by the deepest reorganization\n")
+	prometheus += fmt.Sprintf("# TYPE adrenochain_reorg_depth_max gauge\n")
+	prometheus += fmt.Sprintf("adrenochain_reorg_depth_max %d\n", atomic.LoadInt64(&m.maxReorgDepth))
+
+	prometheus += fmt.Sprintf("# HELP adrenochain_orphaned_blocks Blocks disconnected from the active chain by reorganizations\n")
+	prometheus += fmt.Sprintf("# TYPE adrenochain_orphaned_blocks counter\n")
+	prometheus += fmt.Sprintf("adrenochain_orphaned_blocks %d\n", atomic.LoadInt64(&m.orphanedBlocks))
+
 	// Network metrics
 	prometheus += fmt.Sprintf("# HELP adrenochain_connected_peers Number of connected peers\n")
 	prometheus += fmt.Sprintf("# TYPE adrenochain_connected_peers gauge\n")
@@ -297,6 +428,13 @@ func (m *Metrics) GetPrometheusMetrics() string {
 	prometheus += fmt.Sprintf("# TYPE adrenochain_total_peers gauge\n")
 	prometheus += fmt.Sprintf("adrenochain_total_peers %d\n", atomic.LoadInt64(&m.totalPeers))
 
+	prometheus += fmt.Sprintf("# HELP adrenochain_block_propagation_ms Latency from mining a block to peer acknowledgement\n")
+	prometheus += fmt.Sprintf("# TYPE adrenochain_block_propagation_ms summary\n")
+	prometheus += fmt.Sprintf("adrenochain_block_propagation_ms{quantile=\"0.5\"} %d\n", atomic.LoadInt64(&m.blockPropagationP50))
+	prometheus += fmt.Sprintf("adrenochain_block_propagation_ms{quantile=\"0.9\"} %d\n", atomic.LoadInt64(&m.blockPropagationP90))
+	prometheus += fmt.Sprintf("adrenochain_block_propagation_ms{quantile=\"0.99\"} %d\n", atomic.LoadInt64(&m.blockPropagationP99))
+	prometheus += fmt.Sprintf("adrenochain_block_propagation_ms_count %d\n", atomic.LoadInt64(&m.blockPropagationSamples))
+
 	// Mining metrics
 	prometheus += fmt.Sprintf("# HELP adrenochain_hash_rate Current hash rate\n")
 	prometheus += fmt.Sprintf("# TYPE adrenochain_hash_rate gauge\n")
@@ -311,6 +449,17 @@ func (m *Metrics) GetPrometheusMetrics() string {
 	prometheus += fmt.Sprintf("# TYPE adrenochain_memory_usage_bytes gauge\n")
 	prometheus += fmt.Sprintf("adrenochain_memory_usage_bytes %d\n", atomic.LoadInt64(&m.memoryUsage))
 
+	prometheus += fmt.Sprintf("# HELP adrenochain_block_validation_seconds Time spent in each stage of block validation\n")
+	prometheus += fmt.Sprintf("# TYPE adrenochain_block_validation_seconds summary\n")
+	for _, stage := range chain.ValidationStages {
+		timing, exists := m.validationTimings[stage]
+		if !exists {
+			continue
+		}
+		prometheus += fmt.Sprintf("adrenochain_block_validation_seconds_sum{stage=\"%s\"} %f\n", stage, timing.Total.Seconds())
+		prometheus += fmt.Sprintf("adrenochain_block_validation_seconds_count{stage=\"%s\"} %d\n", stage, timing.Samples)
+	}
+
 	// Error metrics
 	prometheus += fmt.Sprintf("# HELP adrenochain_total_errors Total number of errors\n")
 	prometheus += fmt.Sprintf("# TYPE adrenochain_total_errors counter\n")
@@ -324,6 +473,22 @@ func (m *Metrics) GetPrometheusMetrics() string {
 	return prometheus
 }
 
+// validationStageMetrics returns the timing of each block validation stage
+// that ran, in milliseconds.
+// Note: the caller must hold the metrics lock.
+func (m *Metrics) validationStageMetrics() map[string]interface{} {
+	stages := make(map[string]interface{}, len(m.validationTimings))
+	for stage, timing := range m.validationTimings {
+		stages[string(stage)] = map[string]interface{}{
+			"samples": timing.Samples,
+			"avg_ms":  float64(timing.Average()) / float64(time.Millisecond),
+			"last_ms": float64(timing.Last) / float64(time.Millisecond),
+			"max_ms":  float64(timing.Max) / float64(time.Millisecond),
+		}
+	}
+	return stages
+}
+
 // Reset resets all metrics to zero
 func (m *Metrics) Reset() {
 	m.mu.Lock()
@@ -336,6 +501,10 @@ func (m *Metrics) Reset() {
 	atomic.StoreInt64(&m.connectedPeers, 0)
 	atomic.StoreInt64(&m.totalPeers, 0)
 	atomic.StoreInt64(&m.networkLatency, 0)
+	atomic.StoreInt64(&m.blockPropagationP50, 0)
+	atomic.StoreInt64(&m.blockPropagationP90, 0)
+	atomic.StoreInt64(&m.blockPropagationP99, 0)
+	atomic.StoreInt64(&m.blockPropagationSamples, 0)
 	atomic.StoreInt64(&m.hashRate, 0)
 	atomic.StoreInt64(&m.blocksMined, 0)
 	atomic.StoreInt64(&m.blockProcessingTime, 0)
@@ -347,15 +516,24 @@ func (m *Metrics) Reset() {
 	atomic.StoreInt64(&m.utxoCount, 0)
 	atomic.StoreInt64(&m.chainSize, 0)
 	atomic.StoreInt64(&m.orphanedBlocks, 0)
+	atomic.StoreInt64(&m.reorgs, 0)
+	atomic.StoreInt64(&m.deepReorgs, 0)
+	atomic.StoreInt64(&m.lastReorgDepth, 0)
+	atomic.StoreInt64(&m.maxReorgDepth, 0)
 	atomic.StoreInt64(&m.rejectedBlocks, 0)
+	atomic.StoreInt64(&m.droppedBlocks, 0)
+	atomic.StoreInt64(&m.auditFailures, 0)
+	atomic.StoreInt64(&m.warnings, 0)
 	atomic.StoreInt64(&m.rejectedTxns, 0)
 	atomic.StoreInt64(&m.avgBlockTime, 0)
 	atomic.StoreInt64(&m.avgBlockSize, 0)
 
 	m.chainDifficulty = 0
 	m.miningEnabled = false
+	m.safeMode = false
 	m.lastBlockTime = time.Time{}
 	m.lastSyncTime = time.Time{}
 	m.avgTxnPerBlock = 0
+	m.validationTimings = nil
 	m.startTime = time.Now()
 }
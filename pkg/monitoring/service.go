@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"runtime"
@@ -14,6 +15,7 @@ import (
 	"github.com/palaseus/adrenochain/pkg/chain"
 	"github.com/palaseus/adrenochain/pkg/health"
 	"github.com/palaseus/adrenochain/pkg/logger"
+	"github.com/palaseus/adrenochain/pkg/miner"
 )
 
 // ChainInterface defines the interface for blockchain operations
@@ -24,6 +26,38 @@ type ChainInterface interface {
 	GetBlockByHeight(height uint64) *block.Block
 }
 
+// SyncStatusReporter is optionally implemented by chains that can tell
+// whether the node has caught up with the network
+type SyncStatusReporter interface {
+	IsInitialBlockDownload() bool
+	IsOutOfSync() bool
+	TipAge() time.Duration
+}
+
+// SafeModeReporter is optionally implemented by chains that stop accepting
+// blocks after a storage write failure
+type SafeModeReporter interface {
+	SafeModeReason() error
+}
+
+// WarningReporter is optionally implemented by chains that raise warnings
+// for operators, such as an invalid chain with more work than their own
+type WarningReporter interface {
+	GetWarnings() []string
+}
+
+// ReorgReporter is optionally implemented by chains that count their
+// reorganizations
+type ReorgReporter interface {
+	ReorgStats() chain.ReorgStats
+}
+
+// ValidationReporter is optionally implemented by chains that time the
+// stages of block validation
+type ValidationReporter interface {
+	ValidationStats() chain.ValidationStats
+}
+
 // MempoolInterface defines the interface for mempool operations
 type MempoolInterface interface {
 	GetTransactionCount() int
@@ -34,6 +68,17 @@ type NetworkInterface interface {
 	GetPeers() []string
 }
 
+// MinerInterface defines the miner statistics collected by the service
+type MinerInterface interface {
+	GetMiningInfo() miner.MiningInfo
+}
+
+// PropagationReporter is optionally implemented by networks that measure how
+// long blocks mined by this node take to be acknowledged by peers
+type PropagationReporter interface {
+	BlockPropagationPercentiles() (p50, p90, p99 time.Duration, samples int)
+}
+
 // SimpleHealthChecker is a simple health checker for testing
 type SimpleHealthChecker struct {
 	name   string
@@ -62,14 +107,35 @@ type Service struct {
 	chain         ChainInterface
 	mempool       MempoolInterface
 	network       NetworkInterface
+	miner         MinerInterface
 	config        *Config
 	ctx           context.Context
 	cancel        context.CancelFunc
 	metricsServer *http.Server
 	healthServer  *http.Server
+	endpoints     []EndpointStatus
 	checkers      []health.HealthChecker
 }
 
+// BindFailurePolicy decides what Start does when an endpoint cannot listen
+// on its port, e.g. because another process already uses it
+type BindFailurePolicy string
+
+const (
+	// BindFailureContinue logs the failure and runs without the endpoint
+	BindFailureContinue BindFailurePolicy = "continue"
+	// BindFailureFailFast makes Start return an error
+	BindFailureFailFast BindFailurePolicy = "fail_fast"
+)
+
+// EndpointStatus reports whether a monitoring endpoint is serving
+type EndpointStatus struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+	Live    bool   `json:"live"`
+	Error   string `json:"error,omitempty"` // Error is why the endpoint could not listen.
+}
+
 // Config holds configuration for the monitoring service
 type Config struct {
 	MetricsPort         int
@@ -79,10 +145,14 @@ type Config struct {
 	LogFile             string
 	MetricsPath         string
 	HealthPath          string
+	ReadinessPath       string
 	PrometheusPath      string
 	CollectInterval     time.Duration
 	HealthCheckInterval time.Duration
 	EnablePrometheus    bool
+	// BindFailurePolicy applies when the metrics or health port cannot be
+	// bound. Empty means BindFailureContinue.
+	BindFailurePolicy BindFailurePolicy
 }
 
 // DefaultConfig returns default monitoring configuration
@@ -95,10 +165,12 @@ func DefaultConfig() *Config {
 		LogFile:             "",
 		MetricsPath:         "/metrics",
 		HealthPath:          "/health",
+		ReadinessPath:       "/ready",
 		PrometheusPath:      "/prometheus",
 		CollectInterval:     30 * time.Second,
 		HealthCheckInterval: 15 * time.Second,
 		EnablePrometheus:    true,
+		BindFailurePolicy:   BindFailureContinue,
 	}
 }
 
@@ -197,6 +269,14 @@ func (s *Service) RegisterHealthChecker(checker health.HealthChecker) {
 	s.checkers = append(s.checkers, checker)
 }
 
+// SetMiner sets the miner whose statistics are collected with the other
+// metrics
+func (s *Service) SetMiner(m MinerInterface) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.miner = m
+}
+
 // startBackgroundMonitoring starts the background monitoring loop
 func (s *Service) startBackgroundMonitoring() {
 	metricsTicker := time.NewTicker(s.config.CollectInterval)
@@ -249,6 +329,19 @@ func (s *Service) UpdateMetrics() {
 			}
 		}
 		s.metrics.UpdateTotalBlocks(int64(s.chain.GetHeight() + 1))
+
+		if reporter, ok := s.chain.(SafeModeReporter); ok {
+			s.metrics.SetSafeMode(reporter.SafeModeReason() != nil)
+		}
+		if reporter, ok := s.chain.(ReorgReporter); ok {
+			s.metrics.UpdateReorgStats(reporter.ReorgStats())
+		}
+		if reporter, ok := s.chain.(ValidationReporter); ok {
+			s.metrics.UpdateValidationStats(reporter.ValidationStats())
+		}
+		if reporter, ok := s.chain.(WarningReporter); ok {
+			s.metrics.UpdateWarnings(int64(len(reporter.GetWarnings())))
+		}
 	}
 
 	// Update mempool metrics
@@ -260,6 +353,19 @@ func (s *Service) UpdateMetrics() {
 	if s.network != nil {
 		peers := s.network.GetPeers()
 		s.metrics.UpdateConnectedPeers(int64(len(peers)))
+
+		if reporter, ok := s.network.(PropagationReporter); ok {
+			p50, p90, p99, samples := reporter.BlockPropagationPercentiles()
+			s.metrics.UpdateBlockPropagation(p50, p90, p99, int64(samples))
+		}
+	}
+
+	// Update mining metrics
+	s.mu.RLock()
+	mining := s.miner
+	s.mu.RUnlock()
+	if mining != nil {
+		s.metrics.UpdateMiningInfo(mining.GetMiningInfo())
 	}
 
 	// Update system metrics
@@ -311,21 +417,57 @@ func (s *Service) runComponentCheck(checker health.HealthChecker) {
 // Start starts the monitoring service
 func (s *Service) Start() error {
 	s.logger.Info("Starting monitoring service")
+	switch s.config.BindFailurePolicy {
+	case "", BindFailureContinue, BindFailureFailFast:
+	default:
+		return fmt.Errorf("unknown bind failure policy %q", s.config.BindFailurePolicy)
+	}
 
 	// Start metrics server
 	if err := s.startMetricsServer(); err != nil {
-		return fmt.Errorf("failed to start metrics server: %w", err)
+		if s.config.BindFailurePolicy == BindFailureFailFast {
+			return fmt.Errorf("failed to start metrics server: %w", err)
+		}
+		s.logger.Warn("Continuing without metrics server: %v", err)
 	}
 
 	// Start health server
 	if err := s.startHealthServer(); err != nil {
-		return fmt.Errorf("failed to start health server: %w", err)
+		if s.config.BindFailurePolicy == BindFailureFailFast {
+			s.Stop()
+			return fmt.Errorf("failed to start health server: %w", err)
+		}
+		s.logger.Warn("Continuing without health server: %v", err)
 	}
 
 	s.logger.Info("Monitoring service started successfully")
 	return nil
 }
 
+// Endpoints returns the monitoring endpoints started by Start and whether
+// each is serving
+func (s *Service) Endpoints() []EndpointStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]EndpointStatus(nil), s.endpoints...)
+}
+
+// listen binds an endpoint's port and records whether it is live
+func (s *Service) listen(name string, port int) (net.Listener, error) {
+	status := EndpointStatus{Name: name, Address: fmt.Sprintf(":%d", port)}
+	listener, err := net.Listen("tcp", status.Address)
+	if err != nil {
+		status.Error = err.Error()
+	} else {
+		status.Live = true
+	}
+
+	s.mu.Lock()
+	s.endpoints = append(s.endpoints, status)
+	s.mu.Unlock()
+	return listener, err
+}
+
 // startMetricsServer starts the metrics HTTP server
 func (s *Service) startMetricsServer() error {
 	mux := http.NewServeMux()
@@ -335,13 +477,17 @@ func (s *Service) startMetricsServer() error {
 		mux.HandleFunc(s.config.PrometheusPath, s.prometheusHandler)
 	}
 
+	listener, err := s.listen("metrics", s.config.MetricsPort)
+	if err != nil {
+		return err
+	}
 	s.metricsServer = &http.Server{
-		Addr:    fmt.Sprintf(":%d", s.config.MetricsPort),
+		Addr:    listener.Addr().String(),
 		Handler: mux,
 	}
 
 	go func() {
-		if err := s.metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := s.metricsServer.Serve(listener); err != nil && err != http.ErrServerClosed {
 			s.logger.Error("Metrics server error: %v", err)
 		}
 	}()
@@ -354,14 +500,21 @@ func (s *Service) startMetricsServer() error {
 func (s *Service) startHealthServer() error {
 	mux := http.NewServeMux()
 	mux.HandleFunc(s.config.HealthPath, s.healthHandler)
+	if s.config.ReadinessPath != "" {
+		mux.HandleFunc(s.config.ReadinessPath, s.readinessHandler)
+	}
 
+	listener, err := s.listen("health", s.config.HealthPort)
+	if err != nil {
+		return err
+	}
 	s.healthServer = &http.Server{
-		Addr:    fmt.Sprintf(":%d", s.config.HealthPort),
+		Addr:    listener.Addr().String(),
 		Handler: mux,
 	}
 
 	go func() {
-		if err := s.healthServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := s.healthServer.Serve(listener); err != nil && err != http.ErrServerClosed {
 			s.logger.Error("Health server error: %v", err)
 		}
 	}()
@@ -400,6 +553,39 @@ func (s *Service) healthHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// readinessHandler reports whether the node is ready to serve requests. A
+// node in safe mode after a storage failure, in initial block download or
+// whose tip is older than the maximum tip age is not ready.
+func (s *Service) readinessHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	response := map[string]interface{}{"ready": true}
+	if reporter, ok := s.chain.(SyncStatusReporter); ok {
+		response["tip_age_seconds"] = int64(reporter.TipAge().Seconds())
+		switch {
+		case reporter.IsInitialBlockDownload():
+			response["ready"] = false
+			response["reason"] = "initial block download"
+		case reporter.IsOutOfSync():
+			response["ready"] = false
+			response["reason"] = "tip is stale"
+		}
+	}
+
+	if reporter, ok := s.chain.(SafeModeReporter); ok {
+		if reason := reporter.SafeModeReason(); reason != nil {
+			response["ready"] = false
+			response["reason"] = "safe mode"
+			response["safe_mode_reason"] = reason.Error()
+		}
+	}
+
+	if response["ready"] == false {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
 // Stop stops the monitoring service
 func (s *Service) Stop() error {
 	s.logger.Info("Stopping monitoring service")
@@ -3,6 +3,7 @@ package monitoring
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net"
 	"net/http"
 	"net/http/httptest"
@@ -10,8 +11,10 @@ import (
 	"time"
 
 	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/chain"
 	"github.com/palaseus/adrenochain/pkg/health"
 	"github.com/palaseus/adrenochain/pkg/logger"
+	"github.com/palaseus/adrenochain/pkg/miner"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -42,6 +45,42 @@ func (mc *MockChain) GetBlockByHeight(height uint64) *block.Block {
 	return nil
 }
 
+// MockSyncChain is a mock chain that also reports its sync status
+type MockSyncChain struct {
+	MockChain
+	ibd       bool
+	outOfSync bool
+	tipAge    time.Duration
+}
+
+func (mc *MockSyncChain) IsInitialBlockDownload() bool { return mc.ibd }
+func (mc *MockSyncChain) IsOutOfSync() bool            { return mc.outOfSync }
+func (mc *MockSyncChain) TipAge() time.Duration        { return mc.tipAge }
+
+// MockSafeModeChain is a mock chain that reports a storage failure
+type MockSafeModeChain struct {
+	MockChain
+	reason error
+}
+
+func (mc *MockSafeModeChain) SafeModeReason() error { return mc.reason }
+
+// MockReorgChain is a mock chain that has been reorganized
+type MockReorgChain struct {
+	MockChain
+	stats chain.ReorgStats
+}
+
+func (mc *MockReorgChain) ReorgStats() chain.ReorgStats { return mc.stats }
+
+// MockValidationChain is a mock chain that times block validation
+type MockValidationChain struct {
+	MockChain
+	stats chain.ValidationStats
+}
+
+func (mc *MockValidationChain) ValidationStats() chain.ValidationStats { return mc.stats }
+
 // MockMempool is a mock implementation of the mempool for testing
 type MockMempool struct {
 	txnCount int
@@ -60,6 +99,17 @@ func (mn *MockNetwork) GetPeers() []string {
 	return mn.peers
 }
 
+// MockPropagationNetwork is a mock network that also reports block propagation latencies
+type MockPropagationNetwork struct {
+	MockNetwork
+	p50, p90, p99 time.Duration
+	samples       int
+}
+
+func (mn *MockPropagationNetwork) BlockPropagationPercentiles() (p50, p90, p99 time.Duration, samples int) {
+	return mn.p50, mn.p90, mn.p99, mn.samples
+}
+
 // MockHealthChecker is a mock implementation of the health checker for testing
 type MockHealthChecker struct {
 	name   string
@@ -220,6 +270,49 @@ func TestServiceStartStop(t *testing.T) {
 	assert.Error(t, err, "Expected error when accessing stopped service")
 }
 
+// TestBindFailurePolicy occupies the health port before starting the
+// service, which must then fail with fail_fast and run without the health
+// endpoint with continue.
+func TestBindFailurePolicy(t *testing.T) {
+	taken, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	defer taken.Close()
+
+	newService := func(policy BindFailurePolicy) *Service {
+		config, err := createTestConfig()
+		require.NoError(t, err)
+		config.HealthPort = taken.Addr().(*net.TCPAddr).Port
+		config.BindFailurePolicy = policy
+		return NewService(config, &MockChain{}, &MockMempool{}, &MockNetwork{})
+	}
+
+	service := newService(BindFailureFailFast)
+	err = service.Start()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to start health server")
+	_, err = http.Get(service.GetMetricsEndpoint())
+	assert.Error(t, err, "the metrics server is stopped when start fails")
+
+	service = newService(BindFailureContinue)
+	require.NoError(t, service.Start())
+	defer service.Stop()
+
+	endpoints := service.Endpoints()
+	require.Len(t, endpoints, 2)
+	assert.Equal(t, "metrics", endpoints[0].Name)
+	assert.True(t, endpoints[0].Live)
+	assert.Equal(t, "health", endpoints[1].Name)
+	assert.False(t, endpoints[1].Live)
+	assert.NotEmpty(t, endpoints[1].Error)
+
+	resp, err := http.Get(service.GetMetricsEndpoint())
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	assert.Error(t, newService("retry").Start(), "unknown policies are rejected")
+}
+
 func TestHealthCheckersRegistration(t *testing.T) {
 	// Create test configuration with dynamic ports
 	config, err := createTestConfig()
@@ -354,6 +447,171 @@ func TestMetricsCollection(t *testing.T) {
 	assert.Equal(t, int64(2), metrics["network"].(map[string]interface{})["connected_peers"])
 }
 
+func TestBlockPropagationMetrics(t *testing.T) {
+	mockNetwork := &MockPropagationNetwork{
+		MockNetwork: MockNetwork{peers: []string{"QmPeer1"}},
+		p50:         120 * time.Millisecond,
+		p90:         450 * time.Millisecond,
+		p99:         900 * time.Millisecond,
+		samples:     42,
+	}
+	service := NewService(nil, &MockChain{}, &MockMempool{}, mockNetwork)
+
+	service.UpdateMetrics()
+
+	network := service.GetMetrics().GetMetrics()["network"].(map[string]interface{})
+	propagation := network["block_propagation"].(map[string]interface{})
+	assert.Equal(t, int64(120), propagation["p50_ms"])
+	assert.Equal(t, int64(450), propagation["p90_ms"])
+	assert.Equal(t, int64(900), propagation["p99_ms"])
+	assert.Equal(t, int64(42), propagation["samples"])
+
+	prometheus := service.GetMetrics().GetPrometheusMetrics()
+	assert.Contains(t, prometheus, `adrenochain_block_propagation_ms{quantile="0.9"} 450`)
+	assert.Contains(t, prometheus, "adrenochain_block_propagation_ms_count 42")
+}
+
+func TestSafeModeMetrics(t *testing.T) {
+	mockChain := &MockSafeModeChain{reason: errors.New("no space left on device")}
+	service := NewService(nil, mockChain, &MockMempool{}, &MockNetwork{})
+
+	service.UpdateMetrics()
+
+	blockchain := service.GetMetrics().GetMetrics()["blockchain"].(map[string]interface{})
+	assert.Equal(t, true, blockchain["safe_mode"])
+	assert.Contains(t, service.GetMetrics().GetPrometheusMetrics(), "adrenochain_safe_mode 1")
+}
+
+func TestReorgMetrics(t *testing.T) {
+	mockChain := &MockReorgChain{stats: chain.ReorgStats{
+		Reorgs:         3,
+		DeepReorgs:     1,
+		LastDepth:      2,
+		MaxDepth:       7,
+		OrphanedBlocks: 11,
+	}}
+	service := NewService(nil, mockChain, &MockMempool{}, &MockNetwork{})
+
+	service.UpdateMetrics()
+
+	blockchain := service.GetMetrics().GetMetrics()["blockchain"].(map[string]interface{})
+	assert.Equal(t, int64(3), blockchain["reorgs"])
+	assert.Equal(t, int64(1), blockchain["deep_reorgs"])
+	assert.Equal(t, int64(2), blockchain["last_reorg_depth"])
+	assert.Equal(t, int64(7), blockchain["max_reorg_depth"])
+	assert.Equal(t, int64(11), blockchain["orphaned_blocks"])
+
+	prometheus := service.GetMetrics().GetPrometheusMetrics()
+	assert.Contains(t, prometheus, "adrenochain_reorgs_total 3")
+	assert.Contains(t, prometheus, "adrenochain_deep_reorgs_total 1")
+	assert.Contains(t, prometheus, "adrenochain_reorg_depth 2")
+	assert.Contains(t, prometheus, "adrenochain_reorg_depth_max 7")
+	assert.Contains(t, prometheus, "adrenochain_orphaned_blocks 11")
+}
+
+func TestValidationMetrics(t *testing.T) {
+	mockChain := &MockValidationChain{stats: chain.ValidationStats{
+		chain.StageHeader: {Samples: 4, Total: 8 * time.Millisecond, Last: time.Millisecond, Max: 3 * time.Millisecond},
+		chain.StagePoW:    {Samples: 4, Total: 2 * time.Second, Last: 500 * time.Millisecond, Max: 500 * time.Millisecond},
+	}}
+	service := NewService(nil, mockChain, &MockMempool{}, &MockNetwork{})
+
+	service.UpdateMetrics()
+
+	performance := service.GetMetrics().GetMetrics()["performance"].(map[string]interface{})
+	stages := performance["block_validation"].(map[string]interface{})
+	require.Len(t, stages, 2)
+	header := stages["header"].(map[string]interface{})
+	assert.Equal(t, uint64(4), header["samples"])
+	assert.Equal(t, 2.0, header["avg_ms"])
+	assert.Equal(t, 1.0, header["last_ms"])
+	assert.Equal(t, 3.0, header["max_ms"])
+
+	prometheus := service.GetMetrics().GetPrometheusMetrics()
+	assert.Contains(t, prometheus, `adrenochain_block_validation_seconds_sum{stage="pow"} 2.000000`)
+	assert.Contains(t, prometheus, `adrenochain_block_validation_seconds_count{stage="pow"} 4`)
+	assert.Contains(t, prometheus, `adrenochain_block_validation_seconds_count{stage="header"} 4`)
+	assert.NotContains(t, prometheus, `stage="merkle"`)
+
+	service.GetMetrics().Reset()
+	assert.NotContains(t, service.GetMetrics().GetPrometheusMetrics(), "adrenochain_block_validation_seconds_count")
+}
+
+// MockMiner is a mock miner reporting fixed statistics
+type MockMiner struct {
+	info miner.MiningInfo
+}
+
+func (mm *MockMiner) GetMiningInfo() miner.MiningInfo { return mm.info }
+
+func TestMiningMetrics(t *testing.T) {
+	service := NewService(nil, &MockChain{}, &MockMempool{}, &MockNetwork{})
+	service.SetMiner(&MockMiner{info: miner.MiningInfo{Mining: true, BlocksFound: 4, HashRate: 1500.5}})
+
+	service.UpdateMetrics()
+
+	mining := service.GetMetrics().GetMetrics()["mining"].(map[string]interface{})
+	assert.Equal(t, int64(1500), mining["hash_rate"])
+	assert.Equal(t, int64(4), mining["blocks_mined"])
+	assert.Equal(t, true, mining["mining_enabled"])
+}
+
+func TestDroppedBlocksMetrics(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.IncrementDroppedBlocks()
+	metrics.IncrementDroppedBlocks()
+
+	blockchain := metrics.GetMetrics()["blockchain"].(map[string]interface{})
+	assert.Equal(t, int64(2), blockchain["dropped_blocks"])
+	assert.Contains(t, metrics.GetPrometheusMetrics(), "adrenochain_dropped_blocks 2")
+
+	metrics.Reset()
+	blockchain = metrics.GetMetrics()["blockchain"].(map[string]interface{})
+	assert.Equal(t, int64(0), blockchain["dropped_blocks"])
+}
+
+func TestUTXOAuditFailureMetrics(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.IncrementUTXOAuditFailures()
+
+	blockchain := metrics.GetMetrics()["blockchain"].(map[string]interface{})
+	assert.Equal(t, int64(1), blockchain["utxo_audit_failures"])
+	assert.Contains(t, metrics.GetPrometheusMetrics(), "adrenochain_utxo_audit_failures 1")
+}
+
+func TestReadinessEndpointResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		chain      ChainInterface
+		wantStatus int
+		wantReason string
+	}{
+		{"synced", &MockSyncChain{tipAge: 5 * time.Second}, http.StatusOK, ""},
+		{"initial block download", &MockSyncChain{ibd: true, outOfSync: true, tipAge: 48 * time.Hour}, http.StatusServiceUnavailable, "initial block download"},
+		{"stale tip", &MockSyncChain{outOfSync: true, tipAge: 3 * time.Hour}, http.StatusServiceUnavailable, "tip is stale"},
+		{"no sync status", &MockChain{}, http.StatusOK, ""},
+		{"safe mode", &MockSafeModeChain{reason: errors.New("no space left on device")}, http.StatusServiceUnavailable, "safe mode"},
+		{"no safe mode", &MockSafeModeChain{}, http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewService(nil, tt.chain, &MockMempool{}, &MockNetwork{})
+
+			w := httptest.NewRecorder()
+			service.readinessHandler(w, httptest.NewRequest("GET", "/ready", nil))
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			var response map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.wantStatus == http.StatusOK, response["ready"])
+			if tt.wantReason != "" {
+				assert.Equal(t, tt.wantReason, response["reason"])
+			}
+		})
+	}
+}
+
 func TestHealthEndpointResponse(t *testing.T) {
 	// Create test configuration with dynamic ports
 	config, err := createTestConfig()
@@ -0,0 +1,276 @@
+package net
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/palaseus/adrenochain/pkg/storage"
+)
+
+// addrBookKey is the storage key under which the address book is persisted.
+var addrBookKey = []byte("net:addrbook")
+
+const (
+	// maxAddrBookEntries bounds the number of peers remembered; the lowest
+	// scored entries are dropped first.
+	maxAddrBookEntries = 1000
+
+	addrBookSuccessScore = 1  // addrBookSuccessScore is added for every successful connection.
+	addrBookFailureScore = -2 // addrBookFailureScore is added for every failed connection attempt.
+)
+
+// AddrBookEntry records what is known about a peer's reliability.
+type AddrBookEntry struct {
+	ID        string    `json:"id"`
+	Addrs     []string  `json:"addrs"`
+	Score     int       `json:"score"`
+	Successes int       `json:"successes"`
+	Failures  int       `json:"failures"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// AddrBook is a scored set of peer addresses that survives restarts. Peers
+// that connect reliably gain score and are dialled first on startup.
+type AddrBook struct {
+	mu      sync.RWMutex
+	store   storage.StorageInterface
+	entries map[string]*AddrBookEntry
+}
+
+// NewAddrBook creates an empty address book persisted in store.
+func NewAddrBook(store storage.StorageInterface) *AddrBook {
+	return &AddrBook{
+		store:   store,
+		entries: make(map[string]*AddrBookEntry),
+	}
+}
+
+// Load replaces the in-memory entries with the persisted address book.
+// A missing address book is not an error.
+func (ab *AddrBook) Load() error {
+	exists, err := ab.store.Has(addrBookKey)
+	if err != nil {
+		return fmt.Errorf("failed to check address book: %w", err)
+	}
+	if !exists {
+		return nil
+	}
+
+	data, err := ab.store.Read(addrBookKey)
+	if err != nil {
+		return fmt.Errorf("failed to read address book: %w", err)
+	}
+
+	var entries []*AddrBookEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to decode address book: %w", err)
+	}
+
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	ab.entries = make(map[string]*AddrBookEntry, len(entries))
+	for _, entry := range entries {
+		if entry == nil || entry.ID == "" {
+			continue
+		}
+		ab.entries[entry.ID] = entry
+	}
+	return nil
+}
+
+// Save persists the address book.
+func (ab *AddrBook) Save() error {
+	ab.mu.RLock()
+	data, err := json.Marshal(ab.sortedLocked())
+	ab.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode address book: %w", err)
+	}
+	if err := ab.store.Write(addrBookKey, data); err != nil {
+		return fmt.Errorf("failed to write address book: %w", err)
+	}
+	return nil
+}
+
+// RecordSuccess notes a successful connection to a peer at the given
+// addresses and raises its score.
+func (ab *AddrBook) RecordSuccess(id peer.ID, addrs ...multiaddr.Multiaddr) {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+
+	entry := ab.entryLocked(id)
+	for _, addr := range addrs {
+		if addr == nil {
+			continue
+		}
+		entry.addAddr(addr.String())
+	}
+	entry.Successes++
+	entry.Score += addrBookSuccessScore
+	entry.LastSeen = time.Now()
+}
+
+// AddAddrs records addresses learned for a peer from another node, without
+// changing its score. Unlike RecordSuccess it never evicts a known peer to
+// make room: when the book is full an unknown peer is not added. It reports
+// whether the peer is in the book.
+func (ab *AddrBook) AddAddrs(id peer.ID, addrs ...multiaddr.Multiaddr) bool {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+
+	entry, exists := ab.entries[id.String()]
+	if !exists {
+		if len(ab.entries) >= maxAddrBookEntries {
+			return false
+		}
+		entry = ab.entryLocked(id)
+	}
+	for _, addr := range addrs {
+		if addr == nil {
+			continue
+		}
+		entry.addAddr(addr.String())
+	}
+	return true
+}
+
+// RecordFailure notes a failed connection attempt and lowers the peer's
+// score.
+func (ab *AddrBook) RecordFailure(id peer.ID) {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+
+	entry := ab.entryLocked(id)
+	entry.Failures++
+	entry.Score += addrBookFailureScore
+}
+
+// Penalize adds a negative score adjustment for peer misbehaviour.
+func (ab *AddrBook) Penalize(id peer.ID, penalty int) {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+
+	entry := ab.entryLocked(id)
+	entry.Score += penalty
+}
+
+// Get returns a copy of the entry for a peer, or nil if it is unknown.
+func (ab *AddrBook) Get(id peer.ID) *AddrBookEntry {
+	ab.mu.RLock()
+	defer ab.mu.RUnlock()
+
+	entry, exists := ab.entries[id.String()]
+	if !exists {
+		return nil
+	}
+	entryCopy := *entry
+	entryCopy.Addrs = append([]string(nil), entry.Addrs...)
+	return &entryCopy
+}
+
+// Len returns the number of peers in the address book.
+func (ab *AddrBook) Len() int {
+	ab.mu.RLock()
+	defer ab.mu.RUnlock()
+	return len(ab.entries)
+}
+
+// BestPeers returns up to limit dialable peers, highest score first. Peers
+// with a negative score or without a usable address are skipped.
+func (ab *AddrBook) BestPeers(limit int) []peer.AddrInfo {
+	return ab.peers(limit, false)
+}
+
+// GoodPeers is like BestPeers but only returns peers this node has
+// connected to before, leaving out those only learned from other nodes.
+func (ab *AddrBook) GoodPeers(limit int) []peer.AddrInfo {
+	return ab.peers(limit, true)
+}
+
+// peers returns up to limit dialable peers with a non-negative score,
+// highest first, only those connected to before if connected is set.
+func (ab *AddrBook) peers(limit int, connected bool) []peer.AddrInfo {
+	ab.mu.RLock()
+	defer ab.mu.RUnlock()
+
+	var result []peer.AddrInfo
+	for _, entry := range ab.sortedLocked() {
+		if len(result) >= limit {
+			break
+		}
+		if entry.Score < 0 || (connected && entry.Successes == 0) {
+			continue
+		}
+		id, err := peer.Decode(entry.ID)
+		if err != nil {
+			continue
+		}
+		info := peer.AddrInfo{ID: id}
+		for _, addr := range entry.Addrs {
+			ma, err := multiaddr.NewMultiaddr(addr)
+			if err != nil {
+				continue
+			}
+			info.Addrs = append(info.Addrs, ma)
+		}
+		if len(info.Addrs) == 0 {
+			continue
+		}
+		result = append(result, info)
+	}
+	return result
+}
+
+// entryLocked returns the entry for id, creating it and evicting the lowest
+// scored entry if the book is full.
+// Note: the caller must hold the write lock.
+func (ab *AddrBook) entryLocked(id peer.ID) *AddrBookEntry {
+	key := id.String()
+	if entry, exists := ab.entries[key]; exists {
+		return entry
+	}
+
+	if len(ab.entries) >= maxAddrBookEntries {
+		sorted := ab.sortedLocked()
+		delete(ab.entries, sorted[len(sorted)-1].ID)
+	}
+
+	entry := &AddrBookEntry{ID: key}
+	ab.entries[key] = entry
+	return entry
+}
+
+// sortedLocked returns the entries ordered by score, then by most recently
+// seen, then by ID.
+// Note: the caller must hold the lock.
+func (ab *AddrBook) sortedLocked() []*AddrBookEntry {
+	entries := make([]*AddrBookEntry, 0, len(ab.entries))
+	for _, entry := range ab.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Score != entries[j].Score {
+			return entries[i].Score > entries[j].Score
+		}
+		if !entries[i].LastSeen.Equal(entries[j].LastSeen) {
+			return entries[i].LastSeen.After(entries[j].LastSeen)
+		}
+		return entries[i].ID < entries[j].ID
+	})
+	return entries
+}
+
+// addAddr appends addr unless it is already known.
+func (e *AddrBookEntry) addAddr(addr string) {
+	for _, known := range e.Addrs {
+		if known == addr {
+			return
+		}
+	}
+	e.Addrs = append(e.Addrs, addr)
+}
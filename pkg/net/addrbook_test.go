@@ -0,0 +1,66 @@
+package net
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPeerID(t *testing.T) peer.ID {
+	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	id, err := peer.IDFromPrivateKey(priv)
+	require.NoError(t, err)
+	return id
+}
+
+func TestAddrBookPersistence(t *testing.T) {
+	store, err := storage.NewStorage(storage.DefaultStorageConfig().WithDataDir(t.TempDir()))
+	require.NoError(t, err)
+
+	reliable := newTestPeerID(t)
+	occasional := newTestPeerID(t)
+	flaky := newTestPeerID(t)
+	addr := multiaddr.StringCast("/ip4/127.0.0.1/tcp/4001")
+
+	book := NewAddrBook(store)
+	for i := 0; i < 3; i++ {
+		book.RecordSuccess(reliable, addr)
+	}
+	book.RecordSuccess(occasional, addr)
+	book.RecordSuccess(flaky, addr)
+	book.RecordFailure(flaky)
+	require.NoError(t, book.Save())
+
+	// Simulate a restart
+	reloaded := NewAddrBook(store)
+	require.NoError(t, reloaded.Load())
+	assert.Equal(t, 3, reloaded.Len())
+
+	entry := reloaded.Get(reliable)
+	require.NotNil(t, entry)
+	assert.Equal(t, 3, entry.Successes)
+	assert.Equal(t, []string{addr.String()}, entry.Addrs)
+
+	best := reloaded.BestPeers(10)
+	require.Len(t, best, 2, "peers with a negative score are not dialled")
+	assert.Equal(t, reliable, best[0].ID)
+	assert.Equal(t, occasional, best[1].ID)
+	assert.Equal(t, reliable, reloaded.BestPeers(1)[0].ID)
+}
+
+func TestAddrBookLoadEmpty(t *testing.T) {
+	store, err := storage.NewStorage(storage.DefaultStorageConfig().WithDataDir(t.TempDir()))
+	require.NoError(t, err)
+
+	book := NewAddrBook(store)
+	require.NoError(t, book.Load())
+	assert.Equal(t, 0, book.Len())
+	assert.Empty(t, book.BestPeers(5))
+}
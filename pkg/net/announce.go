@@ -0,0 +1,119 @@
+package net
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// DefaultMaxAnnouncementsPerPeer is the default number of announcements
+	// accepted from a single peer on one topic per window.
+	DefaultMaxAnnouncementsPerPeer = 200
+	// DefaultAnnouncementWindow is the default length of the announcement
+	// rate window.
+	DefaultAnnouncementWindow = 10 * time.Second
+
+	// announcementSpamScore is added to a peer's address book score for every
+	// window in which it exceeds the announcement limit.
+	announcementSpamScore = -1
+)
+
+// announcementLimiter counts the block and transaction announcements each
+// peer relays per topic in fixed windows, so that a flooding peer cannot
+// monopolize decoding and signature verification.
+type announcementLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	window  time.Duration
+	windows map[announcementKey]*announcementWindow
+	now     func() time.Time
+}
+
+type announcementKey struct {
+	topic string
+	peer  peer.ID
+}
+
+// announcementWindow tracks one peer's announcements on one topic.
+type announcementWindow struct {
+	start     time.Time
+	count     int
+	penalized bool // penalized is set once the peer first exceeds the limit in this window.
+}
+
+// newAnnouncementLimiter creates a limiter allowing limit announcements per
+// peer and topic in every window. A limit of zero or less disables it.
+func newAnnouncementLimiter(limit int, window time.Duration) *announcementLimiter {
+	if window <= 0 {
+		window = DefaultAnnouncementWindow
+	}
+	return &announcementLimiter{
+		limit:   limit,
+		window:  window,
+		windows: make(map[announcementKey]*announcementWindow),
+		now:     time.Now,
+	}
+}
+
+// allow records an announcement from id on topic and reports whether it is
+// within the limit. penalize is true for the first excess announcement in a
+// window, so that the caller lowers the peer's score once per window.
+func (l *announcementLimiter) allow(topic string, id peer.ID) (allowed, penalize bool) {
+	if l.limit <= 0 {
+		return true, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	key := announcementKey{topic: topic, peer: id}
+	w, exists := l.windows[key]
+	if !exists || now.Sub(w.start) >= l.window {
+		w = &announcementWindow{start: now}
+		l.windows[key] = w
+	}
+
+	if w.count < l.limit {
+		w.count++
+		return true, false
+	}
+	if !w.penalized {
+		w.penalized = true
+		return false, true
+	}
+	return false, false
+}
+
+// forget drops the counters of a disconnected peer.
+func (l *announcementLimiter) forget(id peer.ID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key := range l.windows {
+		if key.peer == id {
+			delete(l.windows, key)
+		}
+	}
+}
+
+// AllowAnnouncement reports whether a block or transaction announcement on
+// topic relayed by from should be processed. Announcements beyond the
+// configured per-peer rate are ignored and lower the peer's address book
+// score. Whitelisted peers are not limited.
+func (n *Network) AllowAnnouncement(topic string, from peer.ID) bool {
+	if n.IsWhitelisted(from) {
+		return true
+	}
+	allowed, penalize := n.announcements.allow(topic, from)
+	if penalize {
+		fmt.Printf("Peer %s exceeded %d %s announcements per %v, ignoring excess\n",
+			from.String(), n.announcements.limit, topic, n.announcements.window)
+		if n.addrBook != nil {
+			n.addrBook.Penalize(from, announcementSpamScore)
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,55 @@
+package net
+
+import (
+	"testing"
+	"time"
+
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnouncementLimiter(t *testing.T) {
+	store, err := storage.NewStorage(storage.DefaultStorageConfig().WithDataDir(t.TempDir()))
+	require.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+	limiter := newAnnouncementLimiter(5, time.Minute)
+	limiter.now = func() time.Time { return now }
+	network := &Network{announcements: limiter, addrBook: NewAddrBook(store)}
+
+	spammer := newTestPeerID(t)
+	wellBehaved := newTestPeerID(t)
+
+	processed := 0
+	for i := 0; i < 50; i++ {
+		if network.AllowAnnouncement("transactions", spammer) {
+			processed++
+		}
+	}
+	assert.Equal(t, 5, processed, "announcements beyond the limit are ignored")
+
+	for i := 0; i < 5; i++ {
+		assert.True(t, network.AllowAnnouncement("transactions", wellBehaved))
+	}
+	assert.True(t, network.AllowAnnouncement("blocks", spammer), "limits are tracked per topic")
+
+	// The spammer is penalized once for the window
+	require.NotNil(t, network.addrBook.Get(spammer))
+	assert.Equal(t, announcementSpamScore, network.addrBook.Get(spammer).Score)
+	assert.Nil(t, network.addrBook.Get(wellBehaved))
+
+	// A new window accepts announcements again
+	now = now.Add(time.Minute)
+	assert.True(t, network.AllowAnnouncement("transactions", spammer))
+
+	// Disconnecting forgets the counters
+	limiter.forget(wellBehaved)
+	assert.True(t, network.AllowAnnouncement("transactions", wellBehaved))
+
+	unlimited := newAnnouncementLimiter(0, time.Minute)
+	for i := 0; i < 1000; i++ {
+		allowed, _ := unlimited.allow("blocks", spammer)
+		require.True(t, allowed)
+	}
+}
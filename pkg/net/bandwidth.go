@@ -0,0 +1,215 @@
+package net
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// uploadLimiter caps the bytes per second served to each peer with a token
+// bucket per peer holding up to one second of traffic, so that a single
+// peer downloading from this node cannot take the upload bandwidth other
+// peers need.
+type uploadLimiter struct {
+	mu      sync.Mutex
+	rate    int // rate is the cap in bytes per second, zero or less disables it.
+	buckets map[peer.ID]*uploadBucket
+	now     func() time.Time
+}
+
+// uploadBucket holds the bytes a peer may be sent without waiting. It goes
+// negative when a write is reserved ahead of its refill.
+type uploadBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newUploadLimiter creates a limiter capping uploads to each peer at rate
+// bytes per second. A rate of zero or less disables it.
+func newUploadLimiter(rate int) *uploadLimiter {
+	return &uploadLimiter{
+		rate:    rate,
+		buckets: make(map[peer.ID]*uploadBucket),
+		now:     time.Now,
+	}
+}
+
+// reserve takes n bytes from the bucket of id and returns how long the
+// caller must wait before sending them.
+func (l *uploadLimiter) reserve(id peer.ID, n int) time.Duration {
+	if l.rate <= 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	rate := float64(l.rate)
+	b, exists := l.buckets[id]
+	if !exists {
+		b = &uploadBucket{tokens: rate, last: now}
+		l.buckets[id] = b
+	}
+	b.tokens = min(rate, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / rate * float64(time.Second))
+}
+
+// forget drops the bucket of a disconnected peer.
+func (l *uploadLimiter) forget(id peer.ID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.buckets, id)
+}
+
+// throttledWriter writes to a peer no faster than its upload limiter allows.
+type throttledWriter struct {
+	ctx     context.Context
+	limiter *uploadLimiter
+	peer    peer.ID
+	w       io.Writer
+}
+
+// Write sends p in chunks of at most the per-second cap, waiting for each
+// chunk's share of the peer's upload bandwidth.
+func (tw *throttledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		chunk := min(len(p)-written, tw.limiter.rate)
+		if wait := tw.limiter.reserve(tw.peer, chunk); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-tw.ctx.Done():
+				timer.Stop()
+				return written, tw.ctx.Err()
+			case <-timer.C:
+			}
+		}
+		n, err := tw.w.Write(p[written : written+chunk])
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// UploadWriter returns a writer sending to w, a stream to p, at no more than
+// the configured MaxUploadRatePerPeer. Writes fail once ctx is done.
+// Whitelisted peers are not throttled, nor is anyone when the cap is zero.
+func (n *Network) UploadWriter(ctx context.Context, p peer.ID, w io.Writer) io.Writer {
+	if n.uploads == nil || n.uploads.rate <= 0 || n.IsWhitelisted(p) {
+		return w
+	}
+	return &throttledWriter{ctx: ctx, limiter: n.uploads, peer: p, w: w}
+}
+
+// downloadRates remembers the block download throughput measured for each
+// peer, so that later downloads start with the fastest peers.
+type downloadRates struct {
+	mu    sync.Mutex
+	rates map[peer.ID]float64
+}
+
+// record stores the throughput of a peer in blocks per second.
+func (r *downloadRates) record(id peer.ID, rate float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.rates == nil {
+		r.rates = make(map[peer.ID]float64)
+	}
+	r.rates[id] = rate
+}
+
+// snapshot returns a copy of the recorded throughputs.
+func (r *downloadRates) snapshot() map[peer.ID]float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rates := make(map[peer.ID]float64, len(r.rates))
+	for id, rate := range r.rates {
+		rates[id] = rate
+	}
+	return rates
+}
+
+// forget drops the throughput of a disconnected peer.
+func (r *downloadRates) forget(id peer.ID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.rates, id)
+}
+
+// rankPeers returns peers without duplicates, those with the highest known
+// throughput first, followed by the peers without a measurement in their
+// original order.
+func rankPeers(peers []peer.ID, rates map[peer.ID]float64) []peer.ID {
+	seen := make(map[peer.ID]bool, len(peers))
+	ranked := make([]peer.ID, 0, len(peers))
+	for _, id := range peers {
+		if !seen[id] {
+			seen[id] = true
+			ranked = append(ranked, id)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		rateI, knownI := rates[ranked[i]]
+		rateJ, knownJ := rates[ranked[j]]
+		if knownI != knownJ {
+			return knownI
+		}
+		return rateI > rateJ
+	})
+	return ranked
+}
+
+// uploadHost is the host gossipsub opens its streams through. Its streams
+// write at no more than the upload cap, so gossip to one peer cannot take
+// the bandwidth of the others; when a peer falls behind, gossipsub drops
+// the messages queued for it beyond its outbound queue.
+type uploadHost struct {
+	host.Host
+	network atomic.Pointer[Network] // network is set once the node is built, streams are not throttled before.
+}
+
+// NewStream opens a stream whose writes are throttled to the upload cap of
+// the peer.
+func (h *uploadHost) NewStream(ctx context.Context, p peer.ID, pids ...protocol.ID) (network.Stream, error) {
+	s, err := h.Host.NewStream(ctx, p, pids...)
+	if err != nil {
+		return nil, err
+	}
+	n := h.network.Load()
+	if n == nil {
+		return s, nil
+	}
+	upload := n.UploadWriter(n.ctx, p, s)
+	if upload == io.Writer(s) {
+		return s, nil
+	}
+	return &uploadStream{Stream: s, upload: upload}, nil
+}
+
+// uploadStream is a stream whose writes go through an upload writer.
+type uploadStream struct {
+	network.Stream
+	upload io.Writer
+}
+
+// Write sends p once the peer's upload cap allows it.
+func (s *uploadStream) Write(p []byte) (int, error) {
+	return s.upload.Write(p)
+}
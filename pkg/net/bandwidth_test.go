@@ -0,0 +1,116 @@
+package net
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/palaseus/adrenochain/pkg/chain"
+	"github.com/palaseus/adrenochain/pkg/mempool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestUploadThrottledPerPeer serves 25000 bytes to each of three peers at
+// once under a cap of 10000 bytes per second: a single peer gets no more
+// than the cap after its first second of burst, while the peers together
+// are served at several times the cap.
+func TestUploadThrottledPerPeer(t *testing.T) {
+	const rate, size = 10000, 25000
+	n := &Network{uploads: newUploadLimiter(rate)}
+	ctx := context.Background()
+
+	peers := []peer.ID{newTestPeerID(t), newTestPeerID(t), newTestPeerID(t)}
+	buffers := make([]bytes.Buffer, len(peers))
+	elapsed := make([]time.Duration, len(peers))
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i, p := range peers {
+		wg.Add(1)
+		go func(i int, p peer.ID) {
+			defer wg.Done()
+			w := n.UploadWriter(ctx, p, &buffers[i])
+			for sent := 0; sent < size; sent += 1000 {
+				_, err := w.Write(make([]byte, 1000))
+				assert.NoError(t, err)
+			}
+			elapsed[i] = time.Since(start)
+		}(i, p)
+	}
+	wg.Wait()
+	total := time.Since(start)
+
+	for i := range peers {
+		require.Equal(t, size, buffers[i].Len())
+		// The first second's worth is sent at once, the rest at the cap
+		assert.GreaterOrEqual(t, elapsed[i], time.Duration(float64(size-rate)/rate*float64(time.Second))-50*time.Millisecond)
+		perPeer := float64(size-rate) / (elapsed[i].Seconds())
+		assert.LessOrEqual(t, perPeer, rate*1.1, "peer %d served above the cap", i)
+	}
+	overall := float64(len(peers)*size) / total.Seconds()
+	assert.Greater(t, overall, 2.0*rate, "peers are throttled independently")
+
+	// Without a cap writes are not delayed
+	unlimited := &Network{uploads: newUploadLimiter(0)}
+	var buf bytes.Buffer
+	w := unlimited.UploadWriter(ctx, peers[0], &buf)
+	assert.Same(t, &buf, w)
+}
+
+// TestUploadThrottleCancel checks a throttled write gives up when its
+// context is done.
+func TestUploadThrottleCancel(t *testing.T) {
+	n := &Network{uploads: newUploadLimiter(100)}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var buf bytes.Buffer
+	written, err := n.UploadWriter(ctx, newTestPeerID(t), &buf).Write(make([]byte, 1000))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 100, written)
+}
+
+// TestGossipStreamsThrottled checks the streams gossipsub opens through
+// uploadHost are held to the upload cap of the peer.
+func TestGossipStreamsThrottled(t *testing.T) {
+	const rate, size = 10000, 25000
+	config := DefaultNetworkConfig()
+	config.ListenPort = 0
+	config.EnableMDNS = false
+	config.EnableRelay = false
+	config.MaxUploadRatePerPeer = rate
+	sender, err := NewNetwork(config, &chain.Chain{}, mempool.NewMempool(mempool.TestMempoolConfig()))
+	require.NoError(t, err)
+	t.Cleanup(func() { sender.Close() })
+	receiver := newMagicTestNetwork(t, config.NetworkMagic)
+
+	received := make(chan int, 1)
+	receiver.GetHost().SetStreamHandler("/adrenochain/test-upload/1.0.0", func(s network.Stream) {
+		n, _ := io.Copy(io.Discard, s)
+		received <- int(n)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	require.NoError(t, sender.GetHost().Connect(ctx, peer.AddrInfo{ID: receiver.GetHost().ID(), Addrs: receiver.GetHost().Addrs()}))
+
+	h := &uploadHost{Host: sender.GetHost()}
+	h.network.Store(sender)
+	s, err := h.NewStream(ctx, receiver.GetHost().ID(), "/adrenochain/test-upload/1.0.0")
+	require.NoError(t, err)
+
+	start := time.Now()
+	written, err := s.Write(make([]byte, size))
+	require.NoError(t, err)
+	require.NoError(t, s.CloseWrite())
+	assert.Equal(t, size, written)
+	assert.Equal(t, size, <-received)
+	// The first second's worth is sent at once, the rest at the cap
+	assert.GreaterOrEqual(t, time.Since(start), time.Duration(float64(size-rate)/rate*float64(time.Second))-50*time.Millisecond)
+}
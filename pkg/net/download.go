@@ -0,0 +1,311 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// DefaultStallTimeout is the default time block download may make no
+	// progress before the slowest peer is evicted.
+	DefaultStallTimeout = 30 * time.Second
+	// DefaultDownloadBatchSize is the number of blocks requested from a peer
+	// at a time.
+	DefaultDownloadBatchSize = 16
+)
+
+// BlockFetchFunc downloads the encoded blocks at heights start to
+// start+count-1 from a peer, in height order. It must return once ctx is
+// done.
+type BlockFetchFunc func(ctx context.Context, p peer.ID, start, count uint64) ([][]byte, error)
+
+// BlockDownloader downloads a range of blocks from several peers in
+// parallel batches and applies them in height order. It measures the
+// throughput of every peer, and when no block has been applied for the
+// stall timeout it evicts the slowest peer still working on a batch and
+// hands the batch to another peer. Peers whose fetches fail are dropped the
+// same way. Peers with the highest known throughput are preferred, and when
+// the number of peers downloaded from at once is capped the others stand by
+// to replace evicted ones.
+type BlockDownloader struct {
+	fetch        BlockFetchFunc
+	apply        func(height uint64, data []byte) error
+	stallTimeout time.Duration
+	batchSize    uint64
+	onEvict      func(peer.ID)
+	maxPeers     int                    // maxPeers caps the peers downloaded from at once, zero for all.
+	rates        map[peer.ID]float64    // rates are known peer throughputs in blocks per second.
+	onRate       func(peer.ID, float64) // onRate receives the throughput measured for a peer.
+
+	mu      sync.Mutex
+	evicted []peer.ID
+}
+
+// downloadBatch is a range of heights requested from one peer.
+type downloadBatch struct {
+	start, count uint64
+}
+
+// peerDownload tracks the work and throughput of one download peer.
+type peerDownload struct {
+	id      peer.ID
+	ctx     context.Context
+	cancel  context.CancelFunc
+	blocks  uint64         // blocks is the number of blocks delivered.
+	busy    time.Duration  // busy is the time spent on delivered batches.
+	batch   *downloadBatch // batch is the batch in flight, nil when idle.
+	started time.Time      // started is when the batch in flight was requested.
+}
+
+// batchResult is the outcome of fetching a batch from a peer.
+type batchResult struct {
+	peer    *peerDownload
+	batch   downloadBatch
+	blocks  [][]byte
+	err     error
+	elapsed time.Duration
+}
+
+// NewBlockDownloader creates a downloader fetching blocks with fetch and
+// handing them to apply. A stall timeout of zero or less uses
+// DefaultStallTimeout.
+func NewBlockDownloader(stallTimeout time.Duration, fetch BlockFetchFunc, apply func(height uint64, data []byte) error) *BlockDownloader {
+	if stallTimeout <= 0 {
+		stallTimeout = DefaultStallTimeout
+	}
+	return &BlockDownloader{
+		fetch:        fetch,
+		apply:        apply,
+		stallTimeout: stallTimeout,
+		batchSize:    DefaultDownloadBatchSize,
+	}
+}
+
+// NewBlockDownloader creates a block downloader using the configured stall
+// timeout and peer cap that disconnects the peers it evicts. It prefers the
+// peers that were fastest in earlier downloads.
+func (n *Network) NewBlockDownloader(fetch BlockFetchFunc, apply func(height uint64, data []byte) error) *BlockDownloader {
+	d := NewBlockDownloader(n.config.StallTimeout, fetch, apply)
+	d.SetOnEvict(func(p peer.ID) {
+		n.host.Network().ClosePeer(p)
+	})
+	d.SetMaxPeers(n.config.MaxDownloadPeers)
+	d.SetPeerRates(n.downloadRates.snapshot(), n.downloadRates.record)
+	return d
+}
+
+// SetBatchSize sets the number of blocks requested from a peer at a time.
+// It must be called before Download.
+func (d *BlockDownloader) SetBatchSize(size uint64) {
+	if size > 0 {
+		d.batchSize = size
+	}
+}
+
+// SetOnEvict sets a callback run for every evicted peer. It must be called
+// before Download.
+func (d *BlockDownloader) SetOnEvict(onEvict func(peer.ID)) {
+	d.onEvict = onEvict
+}
+
+// SetMaxPeers caps the number of peers downloaded from at once; the
+// remaining peers replace evicted ones. Zero or less downloads from every
+// peer. It must be called before Download.
+func (d *BlockDownloader) SetMaxPeers(max int) {
+	d.maxPeers = max
+}
+
+// SetPeerRates sets the known throughput of peers in blocks per second,
+// used to download from the fastest peers first, and a callback receiving
+// the throughput measured for a peer whenever it delivers a batch or is
+// evicted. It must be called before Download.
+func (d *BlockDownloader) SetPeerRates(rates map[peer.ID]float64, onRate func(peer.ID, float64)) {
+	d.rates = rates
+	d.onRate = onRate
+}
+
+// Evicted returns the peers evicted so far, in eviction order.
+func (d *BlockDownloader) Evicted() []peer.ID {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]peer.ID(nil), d.evicted...)
+}
+
+// Download fetches and applies the blocks at heights from to to from peers.
+// It returns once every block was applied, applying a block fails, every
+// peer was evicted or ctx is done.
+func (d *BlockDownloader) Download(ctx context.Context, peers []peer.ID, from, to uint64) error {
+	if to < from {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var pending []downloadBatch
+	for start := from; start <= to; start += d.batchSize {
+		count := d.batchSize
+		if to-start+1 < count {
+			count = to - start + 1
+		}
+		pending = append(pending, downloadBatch{start: start, count: count})
+	}
+
+	results := make(chan batchResult)
+	assign := func(pd *peerDownload) {
+		if len(pending) == 0 {
+			return
+		}
+		batch := pending[0]
+		pending = pending[1:]
+		started := time.Now()
+		pd.batch = &batch
+		pd.started = started
+		go func() {
+			blocks, err := d.fetch(pd.ctx, pd.id, batch.start, batch.count)
+			select {
+			case results <- batchResult{peer: pd, batch: batch, blocks: blocks, err: err, elapsed: time.Since(started)}:
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	standby := rankPeers(peers, d.rates)
+	active := make(map[peer.ID]*peerDownload, len(standby))
+	activate := func() {
+		id := standby[0]
+		standby = standby[1:]
+		peerCtx, peerCancel := context.WithCancel(ctx)
+		pd := &peerDownload{id: id, ctx: peerCtx, cancel: peerCancel}
+		active[id] = pd
+		assign(pd)
+	}
+	for len(standby) > 0 && (d.maxPeers <= 0 || len(active) < d.maxPeers) {
+		activate()
+	}
+
+	// drop evicts a peer and puts the batch it was working on back at the
+	// front of the queue for the remaining peers, bringing in a standby
+	// peer if there is one
+	drop := func(pd *peerDownload) {
+		pd.cancel()
+		delete(active, pd.id)
+		if d.onRate != nil {
+			d.onRate(pd.id, pd.rate())
+		}
+		if pd.batch != nil {
+			pending = append([]downloadBatch{*pd.batch}, pending...)
+			pd.batch = nil
+		}
+		d.mu.Lock()
+		d.evicted = append(d.evicted, pd.id)
+		d.mu.Unlock()
+		if d.onEvict != nil {
+			d.onEvict(pd.id)
+		}
+		if len(standby) > 0 {
+			activate()
+		}
+		for _, other := range active {
+			if other.batch == nil {
+				assign(other)
+			}
+		}
+	}
+
+	checkInterval := d.stallTimeout / 4
+	if checkInterval <= 0 {
+		checkInterval = d.stallTimeout
+	}
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	ready := make(map[uint64][][]byte)
+	next := from
+	lastProgress := time.Now()
+	for next <= to {
+		if len(active) == 0 {
+			return fmt.Errorf("no peers left to download blocks %d-%d", next, to)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case result := <-results:
+			pd := result.peer
+			if active[pd.id] != pd {
+				continue // the peer was evicted and its batch reassigned
+			}
+			if result.err != nil || uint64(len(result.blocks)) != result.batch.count {
+				drop(pd)
+				continue
+			}
+			pd.batch = nil
+			pd.blocks += result.batch.count
+			pd.busy += result.elapsed
+			ready[result.batch.start] = result.blocks
+			if d.onRate != nil {
+				d.onRate(pd.id, pd.rate())
+			}
+
+			for blocks, ok := ready[next]; ok; blocks, ok = ready[next] {
+				delete(ready, next)
+				for i, data := range blocks {
+					if err := d.apply(next+uint64(i), data); err != nil {
+						return fmt.Errorf("failed to apply block %d: %w", next+uint64(i), err)
+					}
+				}
+				next += uint64(len(blocks))
+				lastProgress = time.Now()
+			}
+			assign(pd)
+
+		case <-ticker.C:
+			if time.Since(lastProgress) < d.stallTimeout || len(active)+len(standby) < 2 {
+				continue
+			}
+			if slowest := slowestPeer(active); slowest != nil {
+				drop(slowest)
+				lastProgress = time.Now()
+			}
+		}
+	}
+	return nil
+}
+
+// slowestPeer returns the peer with a batch in flight that has the lowest
+// throughput, counting the time spent on the batch in flight.
+func slowestPeer(active map[peer.ID]*peerDownload) *peerDownload {
+	var slowest *peerDownload
+	var slowestRate float64
+	for _, pd := range active {
+		if pd.batch == nil {
+			continue
+		}
+		if pd.busy+time.Since(pd.started) <= 0 {
+			continue
+		}
+		rate := pd.rate()
+		if slowest == nil || rate < slowestRate {
+			slowest, slowestRate = pd, rate
+		}
+	}
+	return slowest
+}
+
+// rate returns the throughput of a peer in blocks per second, counting the
+// time spent on the batch in flight.
+func (pd *peerDownload) rate() float64 {
+	elapsed := pd.busy
+	if pd.batch != nil {
+		elapsed += time.Since(pd.started)
+	}
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(pd.blocks) / elapsed.Seconds()
+}
@@ -0,0 +1,159 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// simulatedPeer serves blocks of a simulated chain after a fixed delay per
+// request; a failing peer returns an error instead.
+type simulatedPeer struct {
+	delay time.Duration
+	fail  bool
+}
+
+func simulatedFetch(peers map[peer.ID]simulatedPeer) BlockFetchFunc {
+	return func(ctx context.Context, p peer.ID, start, count uint64) ([][]byte, error) {
+		sim := peers[p]
+		select {
+		case <-time.After(sim.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if sim.fail {
+			return nil, errors.New("connection reset")
+		}
+		blocks := make([][]byte, count)
+		for i := range blocks {
+			blocks[i] = []byte(fmt.Sprintf("block-%d", start+uint64(i)))
+		}
+		return blocks, nil
+	}
+}
+
+// TestBlockDownloadEvictsSlowPeer syncs 200 blocks from two fast peers and
+// one that answers far slower than the stall timeout, and checks the slow
+// peer is evicted and its batch completed by the fast peers.
+func TestBlockDownloadEvictsSlowPeer(t *testing.T) {
+	fast1, fast2, slow := newTestPeerID(t), newTestPeerID(t), newTestPeerID(t)
+	peers := map[peer.ID]simulatedPeer{
+		fast1: {delay: 5 * time.Millisecond},
+		fast2: {delay: 5 * time.Millisecond},
+		slow:  {delay: time.Hour},
+	}
+
+	var applied []uint64
+	d := NewBlockDownloader(100*time.Millisecond, simulatedFetch(peers), func(height uint64, data []byte) error {
+		if string(data) != fmt.Sprintf("block-%d", height) {
+			return fmt.Errorf("unexpected block %q at height %d", data, height)
+		}
+		applied = append(applied, height)
+		return nil
+	})
+	d.SetBatchSize(10)
+	var evicted []peer.ID
+	d.SetOnEvict(func(p peer.ID) { evicted = append(evicted, p) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, d.Download(ctx, []peer.ID{slow, fast1, fast2}, 1, 200))
+
+	assert.Equal(t, []peer.ID{slow}, d.Evicted())
+	assert.Equal(t, []peer.ID{slow}, evicted)
+	require.Len(t, applied, 200)
+	for i, height := range applied {
+		assert.Equal(t, uint64(i+1), height)
+	}
+}
+
+func TestBlockDownloadDropsFailingPeers(t *testing.T) {
+	good, bad := newTestPeerID(t), newTestPeerID(t)
+	peers := map[peer.ID]simulatedPeer{
+		good: {delay: time.Millisecond},
+		bad:  {delay: time.Millisecond, fail: true},
+	}
+
+	applied := 0
+	d := NewBlockDownloader(time.Second, simulatedFetch(peers), func(uint64, []byte) error {
+		applied++
+		return nil
+	})
+	d.SetBatchSize(4)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, d.Download(ctx, []peer.ID{bad, good}, 0, 19))
+	assert.Equal(t, 20, applied)
+	assert.Equal(t, []peer.ID{bad}, d.Evicted())
+
+	// Without any working peer the download fails
+	d = NewBlockDownloader(time.Second, simulatedFetch(peers), func(uint64, []byte) error { return nil })
+	assert.ErrorContains(t, d.Download(ctx, []peer.ID{bad}, 0, 19), "no peers left")
+}
+
+// TestBlockDownloadPrefersFastPeers caps the download at two peers and
+// checks that the two fastest from earlier measurements are used, and that
+// a standby peer takes over when a preferred one stalls.
+func TestBlockDownloadPrefersFastPeers(t *testing.T) {
+	fast, medium, slow := newTestPeerID(t), newTestPeerID(t), newTestPeerID(t)
+	peers := map[peer.ID]simulatedPeer{
+		fast:   {delay: time.Millisecond},
+		medium: {delay: 2 * time.Millisecond},
+		slow:   {delay: time.Millisecond},
+	}
+
+	var mu sync.Mutex
+	requested := make(map[peer.ID]int)
+	measured := make(map[peer.ID]float64)
+	fetch := simulatedFetch(peers)
+	newDownloader := func(rates map[peer.ID]float64, maxPeers int) *BlockDownloader {
+		d := NewBlockDownloader(100*time.Millisecond, func(ctx context.Context, p peer.ID, start, count uint64) ([][]byte, error) {
+			mu.Lock()
+			requested[p]++
+			mu.Unlock()
+			return fetch(ctx, p, start, count)
+		}, func(uint64, []byte) error { return nil })
+		d.SetBatchSize(5)
+		d.SetMaxPeers(maxPeers)
+		d.SetPeerRates(rates, func(p peer.ID, rate float64) {
+			mu.Lock()
+			measured[p] = rate
+			mu.Unlock()
+		})
+		return d
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	d := newDownloader(map[peer.ID]float64{fast: 100, medium: 50, slow: 1}, 2)
+	require.NoError(t, d.Download(ctx, []peer.ID{slow, medium, fast}, 1, 100))
+	assert.Zero(t, requested[slow], "the slowest peer is not used")
+	assert.Positive(t, requested[fast])
+	assert.Positive(t, requested[medium])
+	assert.Positive(t, measured[fast])
+	assert.Positive(t, measured[medium])
+
+	// A preferred peer that now stalls is replaced by a standby peer
+	peers[fast] = simulatedPeer{delay: time.Hour}
+	clear(requested)
+	d = newDownloader(map[peer.ID]float64{fast: 100}, 1)
+	require.NoError(t, d.Download(ctx, []peer.ID{slow, fast}, 1, 20))
+	assert.Equal(t, []peer.ID{fast}, d.Evicted())
+	assert.Equal(t, 1, requested[fast])
+	assert.Positive(t, requested[slow])
+}
+
+func TestRankPeers(t *testing.T) {
+	a, b, c, d := newTestPeerID(t), newTestPeerID(t), newTestPeerID(t), newTestPeerID(t)
+	ranked := rankPeers([]peer.ID{a, b, c, b, d}, map[peer.ID]float64{c: 10, d: 20})
+	assert.Equal(t, []peer.ID{d, c, a, b}, ranked, "measured peers first, fastest first, without duplicates")
+}
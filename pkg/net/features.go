@@ -0,0 +1,115 @@
+package net
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// PeerFeature is a bit in the feature bitmask peers exchange during the
+// handshake. A node only uses an optional protocol feature with peers that
+// advertised it.
+type PeerFeature uint64
+
+const (
+	// FeatureCompactBlocks means the peer accepts blocks relayed as compact
+	// blocks.
+	FeatureCompactBlocks PeerFeature = 1 << iota
+	// FeatureBloomFilters means the peer serves bloom filtered blocks and
+	// transactions.
+	FeatureBloomFilters
+	// FeatureWitness means the peer relays and validates witness data.
+	FeatureWitness
+)
+
+// featureNames maps the configuration names of the features to their bits.
+var featureNames = map[string]PeerFeature{
+	"compact_blocks": FeatureCompactBlocks,
+	"bloom_filters":  FeatureBloomFilters,
+	"witness":        FeatureWitness,
+}
+
+// String returns the configuration names of the features set in f.
+func (f PeerFeature) String() string {
+	var names []string
+	for _, name := range []string{"compact_blocks", "bloom_filters", "witness"} {
+		if f&featureNames[name] != 0 {
+			names = append(names, name)
+		}
+	}
+	if unknown := f &^ (FeatureCompactBlocks | FeatureBloomFilters | FeatureWitness); unknown != 0 {
+		names = append(names, fmt.Sprintf("%#x", uint64(unknown)))
+	}
+	return strings.Join(names, ",")
+}
+
+// parseFeatures returns the bitmask of the named features.
+func parseFeatures(names []string) (PeerFeature, error) {
+	var features PeerFeature
+	for _, name := range names {
+		feature, known := featureNames[strings.TrimSpace(name)]
+		if !known {
+			return 0, fmt.Errorf("unknown peer feature %q", name)
+		}
+		features |= feature
+	}
+	return features, nil
+}
+
+// BlockRelay is the way blocks are relayed to a peer.
+type BlockRelay int
+
+const (
+	// RelayFullBlocks sends blocks with all their transactions.
+	RelayFullBlocks BlockRelay = iota
+	// RelayCompactBlocks sends block headers with short transaction IDs,
+	// letting the peer rebuild the block from its mempool.
+	RelayCompactBlocks
+)
+
+// String returns the name of the relay mode.
+func (r BlockRelay) String() string {
+	if r == RelayCompactBlocks {
+		return "compact"
+	}
+	return "full"
+}
+
+// Features returns the features this node advertises to its peers.
+func (n *Network) Features() PeerFeature {
+	return n.features
+}
+
+// PeerSupports reports whether a peer advertised a feature in its handshake.
+// Peers that have not completed the handshake support no features.
+func (n *Network) PeerSupports(p peer.ID, feature PeerFeature) bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.peerFeatures[p]&feature == feature
+}
+
+// BlockRelayMode returns how blocks are relayed to a peer: as compact blocks
+// if both this node and the peer advertise support for them, and as full
+// blocks otherwise.
+func (n *Network) BlockRelayMode(p peer.ID) BlockRelay {
+	if n.features&FeatureCompactBlocks != 0 && n.PeerSupports(p, FeatureCompactBlocks) {
+		return RelayCompactBlocks
+	}
+	return RelayFullBlocks
+}
+
+// setPeerFeatures records the features a peer advertised.
+func (n *Network) setPeerFeatures(p peer.ID, features PeerFeature) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.peerFeatures[p] = features
+}
+
+// forgetPeerFeatures drops the features of a disconnected peer, which
+// advertises them again on reconnecting.
+func (n *Network) forgetPeerFeatures(p peer.ID) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.peerFeatures, p)
+}
@@ -0,0 +1,92 @@
+package net
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/palaseus/adrenochain/pkg/chain"
+	"github.com/palaseus/adrenochain/pkg/mempool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFeatures(t *testing.T) {
+	features, err := parseFeatures([]string{"compact_blocks", " witness"})
+	require.NoError(t, err)
+	assert.Equal(t, FeatureCompactBlocks|FeatureWitness, features)
+	assert.Equal(t, "compact_blocks,witness", features.String())
+
+	_, err = parseFeatures([]string{"graphene"})
+	assert.ErrorContains(t, err, `unknown peer feature "graphene"`)
+
+	config := DefaultNetworkConfig()
+	config.Features = []string{"graphene"}
+	assert.ErrorContains(t, config.Validate(), "unknown peer feature")
+}
+
+func TestHelloRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	sent := time.Unix(1700000000, 0)
+	require.NoError(t, writeHello(&buf, 0x0badcafe, FeatureCompactBlocks|FeatureBloomFilters, sent))
+	assert.Equal(t, helloSize, buf.Len())
+
+	magic, features, theirTime, err := readHello(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0x0badcafe), magic)
+	assert.Equal(t, FeatureCompactBlocks|FeatureBloomFilters, features)
+	assert.True(t, sent.Equal(theirTime))
+
+	_, _, _, err = readHello(bytes.NewReader([]byte{0x0b, 0xad, 0xca, 0xfe}))
+	assert.Error(t, err)
+}
+
+// newFeatureTestNetwork starts a node advertising the given features.
+func newFeatureTestNetwork(t *testing.T, features ...string) *Network {
+	config := DefaultNetworkConfig()
+	config.ListenPort = 0
+	config.EnableMDNS = false
+	config.EnableRelay = false
+	config.Features = features
+
+	n, err := NewNetwork(config, &chain.Chain{}, mempool.NewMempool(mempool.TestMempoolConfig()))
+	require.NoError(t, err)
+	t.Cleanup(func() { n.Close() })
+	return n
+}
+
+func TestBlockRelayFallsBackWithoutCompactBlocks(t *testing.T) {
+	node := newFeatureTestNetwork(t, "compact_blocks")
+	compact := newFeatureTestNetwork(t, "compact_blocks", "witness")
+	legacy := newFeatureTestNetwork(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	nodeInfo := peer.AddrInfo{ID: node.GetHost().ID(), Addrs: node.GetHost().Addrs()}
+	require.NoError(t, compact.GetHost().Connect(ctx, nodeInfo))
+	require.NoError(t, legacy.GetHost().Connect(ctx, nodeInfo))
+
+	compactID, legacyID := compact.GetHost().ID(), legacy.GetHost().ID()
+	require.Eventually(t, func() bool {
+		return node.PeerSupports(compactID, FeatureCompactBlocks) && legacy.PeerSupports(node.GetHost().ID(), FeatureCompactBlocks)
+	}, 10*time.Second, 50*time.Millisecond)
+
+	// Both ends advertise compact blocks, so they are used
+	assert.Equal(t, RelayCompactBlocks, node.BlockRelayMode(compactID))
+	assert.True(t, node.PeerSupports(compactID, FeatureWitness))
+	assert.Equal(t, RelayCompactBlocks, compact.BlockRelayMode(node.GetHost().ID()))
+
+	// The legacy peer advertises nothing, so it gets full blocks either way
+	assert.False(t, node.PeerSupports(legacyID, FeatureCompactBlocks))
+	assert.Equal(t, RelayFullBlocks, node.BlockRelayMode(legacyID))
+	assert.Equal(t, RelayFullBlocks, legacy.BlockRelayMode(node.GetHost().ID()))
+
+	// Features are forgotten with the connection
+	require.NoError(t, node.GetHost().Network().ClosePeer(compactID))
+	require.Eventually(t, func() bool {
+		return !node.PeerSupports(compactID, FeatureCompactBlocks)
+	}, 10*time.Second, 50*time.Millisecond)
+	assert.Equal(t, RelayFullBlocks, node.BlockRelayMode(compactID))
+}
@@ -0,0 +1,112 @@
+package net
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/palaseus/adrenochain/pkg/mempool"
+	proto_net "github.com/palaseus/adrenochain/pkg/proto/net"
+	"google.golang.org/protobuf/proto"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+const (
+	// DefaultMaxBlockMessageSize is the default size limit of a message on
+	// the blocks topic, the largest message gossipsub delivers.
+	DefaultMaxBlockMessageSize = pubsub.DefaultMaxMessageSize
+
+	// maxSignatureSize bounds the signature of a gossip message; it fits the
+	// signatures of every key type libp2p supports.
+	maxSignatureSize = 1024
+
+	// txJSONExpansion bounds the bytes of JSON a transaction takes per byte
+	// the relay policy counts. An output with an empty script is 8 bytes
+	// of value in about 50 bytes of JSON, and with weight accounting a
+	// virtual byte of scriptSig is four bytes, 16/3 once base64 encoded.
+	txJSONExpansion = 7
+	// txMessageOverhead bounds the rest of a transaction message: the JSON
+	// of the transaction fields outside its inputs and outputs, and the
+	// network magic, timestamp, peer ID and signature around it.
+	txMessageOverhead = 2*maxSignatureSize + 512
+)
+
+// DefaultMaxTxMessageSize is the default size limit of a message on the
+// transactions topic, the one of the default mempool relay policy.
+var DefaultMaxTxMessageSize = TxMessageSize(mempool.DefaultMempoolConfig().RelayPolicy())
+
+// TxMessageSize returns the size limit of transaction gossip messages that
+// fits every JSON encoded transaction a relay policy accepts, so that no
+// transaction the mempool relays is dropped by peers for its size.
+func TxMessageSize(policy mempool.RelayPolicy) int {
+	return int(policy.MaxTxSize)*txJSONExpansion + txMessageOverhead
+}
+
+// validateGossip returns the gossip validator of topic. It runs before a
+// message is delivered to subscribers or forwarded to other peers, so only
+// cheap checks belong here: the network magic, the size of the message and
+// its structure. Signatures and contents are verified by the subscribers.
+func (n *Network) validateGossip(topic string) pubsub.ValidatorEx {
+	return func(ctx context.Context, from peer.ID, msg *pubsub.Message) pubsub.ValidationResult {
+		if result := n.validateMagic(ctx, from, msg); result != pubsub.ValidationAccept {
+			return result
+		}
+		if err := n.checkGossipMessage(topic, msg.Data); err != nil {
+			return pubsub.ValidationReject
+		}
+		return pubsub.ValidationAccept
+	}
+}
+
+// checkGossipMessage checks the structure of a sealed message received on
+// the blocks or transactions topic without verifying its signature.
+func (n *Network) checkGossipMessage(topic string, data []byte) error {
+	if limit := n.maxGossipMessageSize(topic); len(data) > limit {
+		return fmt.Errorf("%s message of %d bytes exceeds %d", topic, len(data), limit)
+	}
+	payload, err := n.OpenMessage(data)
+	if err != nil {
+		return err
+	}
+
+	var msg proto_net.Message
+	if err := proto.Unmarshal(payload, &msg); err != nil {
+		return fmt.Errorf("failed to unmarshal %s message: %w", topic, err)
+	}
+	if len(msg.Signature) == 0 {
+		return fmt.Errorf("%s message is not signed", topic)
+	}
+	if len(msg.Signature) > maxSignatureSize {
+		return fmt.Errorf("%s message signature of %d bytes exceeds %d", topic, len(msg.Signature), maxSignatureSize)
+	}
+	if _, err := peer.IDFromBytes(msg.FromPeerId); err != nil {
+		return fmt.Errorf("%s message has an invalid sender: %w", topic, err)
+	}
+
+	switch topic {
+	case "blocks":
+		if msg.GetBlockMessage() == nil {
+			return fmt.Errorf("blocks message carries no block")
+		}
+	case "transactions":
+		if msg.GetTransactionMessage() == nil {
+			return fmt.Errorf("transactions message carries no transaction")
+		}
+	}
+	return nil
+}
+
+// maxGossipMessageSize returns the size limit of messages on topic.
+func (n *Network) maxGossipMessageSize(topic string) int {
+	if topic == "blocks" {
+		if n.config.MaxBlockMessageSize > 0 {
+			return n.config.MaxBlockMessageSize
+		}
+		return DefaultMaxBlockMessageSize
+	}
+	if n.config.MaxTxMessageSize > 0 {
+		return n.config.MaxTxMessageSize
+	}
+	return DefaultMaxTxMessageSize
+}
@@ -0,0 +1,107 @@
+package net
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	proto_net "github.com/palaseus/adrenochain/pkg/proto/net"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCheckGossipMessage(t *testing.T) {
+	n := &Network{config: &NetworkConfig{NetworkMagic: 0x0badcafe, MaxTxMessageSize: 2048}}
+	sender, err := peer.Decode("12D3KooWKkRHzSqW3cpL4MRe7Hbr8iWJbbHDqKUdYpVmDpGa6QiN")
+	require.NoError(t, err)
+	senderBytes, err := sender.MarshalBinary()
+	require.NoError(t, err)
+
+	seal := func(msg *proto_net.Message) []byte {
+		data, err := proto.Marshal(msg)
+		require.NoError(t, err)
+		return n.sealMessage(data)
+	}
+	txMessage := func(txData, signature []byte) *proto_net.Message {
+		return &proto_net.Message{
+			FromPeerId: senderBytes,
+			Signature:  signature,
+			Content: &proto_net.Message_TransactionMessage{
+				TransactionMessage: &proto_net.TransactionMessage{TransactionData: txData},
+			},
+		}
+	}
+
+	assert.NoError(t, n.checkGossipMessage("transactions", seal(txMessage([]byte("tx"), []byte("signature")))))
+
+	tests := []struct {
+		name  string
+		topic string
+		data  []byte
+		want  string
+	}{
+		{"oversized", "transactions", seal(txMessage(make([]byte, 3000), []byte("signature"))), "exceeds 2048"},
+		{"unsigned", "transactions", seal(txMessage([]byte("tx"), nil)), "not signed"},
+		{"oversized signature", "transactions", seal(txMessage([]byte("tx"), make([]byte, maxSignatureSize+1))), "signature of"},
+		{"no sender", "transactions", seal(&proto_net.Message{Signature: []byte("signature")}), "invalid sender"},
+		{"no content", "transactions", seal(&proto_net.Message{FromPeerId: senderBytes, Signature: []byte("signature")}), "carries no transaction"},
+		{"wrong topic", "blocks", seal(txMessage([]byte("tx"), []byte("signature"))), "carries no block"},
+		{"garbage", "transactions", n.sealMessage([]byte{0xff, 0xff, 0xff}), "failed to unmarshal"},
+		{"no magic", "transactions", []byte{0x0b}, "too short"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := n.checkGossipMessage(tt.topic, tt.data)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestGossipValidatorDropsMalformedMessages(t *testing.T) {
+	sender := newMagicTestNetwork(t, 0x0badcafe)
+	receiver := newMagicTestNetwork(t, 0x0badcafe)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	sub, err := receiver.SubscribeToTransactions()
+	require.NoError(t, err)
+	defer sub.Cancel()
+
+	senderInfo := peer.AddrInfo{ID: sender.GetHost().ID(), Addrs: sender.GetHost().Addrs()}
+	require.NoError(t, receiver.GetHost().Connect(ctx, senderInfo))
+	require.Eventually(t, func() bool {
+		return len(sender.pubsub.ListPeers("transactions")) == 1
+	}, 10*time.Second, 50*time.Millisecond)
+
+	// The sender's own validator refuses to publish an unsigned message
+	unsigned, err := proto.Marshal(&proto_net.Message{
+		Content: &proto_net.Message_TransactionMessage{
+			TransactionMessage: &proto_net.TransactionMessage{TransactionData: []byte("unsigned tx")},
+		},
+	})
+	require.NoError(t, err)
+	assert.Error(t, sender.pubsub.Publish("transactions", sender.sealMessage(unsigned)))
+
+	// A misbehaving sender without the validator gets it out, but the
+	// receiver drops it and only delivers the signed message that follows
+	require.NoError(t, sender.pubsub.UnregisterTopicValidator("transactions"))
+	require.NoError(t, sender.pubsub.Publish("transactions", sender.sealMessage(unsigned)))
+	require.NoError(t, sender.PublishTransaction([]byte("signed tx")))
+
+	msg, err := sub.Next(ctx)
+	require.NoError(t, err)
+	payload, err := receiver.OpenMessage(msg.Data)
+	require.NoError(t, err)
+	var received proto_net.Message
+	require.NoError(t, proto.Unmarshal(payload, &received))
+	assert.Equal(t, []byte("signed tx"), received.GetTransactionMessage().GetTransactionData())
+
+	shortCtx, shortCancel := context.WithTimeout(ctx, time.Second)
+	defer shortCancel()
+	_, err = sub.Next(shortCtx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,419 @@
+package net
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/palaseus/adrenochain/pkg/block"
+	proto_net "github.com/palaseus/adrenochain/pkg/proto/net"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// inventoryProtocol is the stream protocol on which a node announces the
+	// hashes of blocks and transactions it can provide.
+	inventoryProtocol = protocol.ID("/adrenochain/inv/1.0.0")
+	// getDataProtocol is the stream protocol on which a node requests the
+	// announced items it lacks; the items are returned on the same stream.
+	getDataProtocol = protocol.ID("/adrenochain/getdata/1.0.0")
+	// inventoryTimeout bounds announcing inventory and fetching items.
+	inventoryTimeout = 10 * time.Second
+	// maxInventoryMessageSize is the largest inv or getdata message read.
+	maxInventoryMessageSize = 64 * 1024
+	// maxInventoryItemSize is the largest block or transaction read in reply
+	// to a getdata request.
+	maxInventoryItemSize = 8 * 1024 * 1024
+	// maxInventoryItems is the number of vectors handled per message.
+	maxInventoryItems = 1000
+	// maxInventoryHashLen is the longest hash accepted in a vector.
+	maxInventoryHashLen = 64
+
+	// maxKnownInventory is the number of most recently seen items remembered
+	// so that an item announced by several peers is only fetched once.
+	maxKnownInventory = 10000
+	// maxRelayInventory is the number of most recently announced items kept
+	// to answer getdata requests.
+	maxRelayInventory = 1000
+)
+
+// inventoryCache remembers the blocks and transactions this node has seen,
+// to deduplicate announcements, and the data of those it announced, to
+// answer requests for them.
+type inventoryCache struct {
+	mu         sync.Mutex
+	known      map[string]struct{}
+	knownOrder []string // knownOrder lists known keys, oldest first.
+	relay      map[string][]byte
+	relayOrder []string // relayOrder lists relayed keys, oldest first.
+	served     int      // served counts items sent in reply to getdata.
+}
+
+// newInventoryCache creates an empty inventory cache.
+func newInventoryCache() *inventoryCache {
+	return &inventoryCache{
+		known: make(map[string]struct{}),
+		relay: make(map[string][]byte),
+	}
+}
+
+// inventoryKey identifies an item by its type and hash.
+func inventoryKey(invType proto_net.InvType, hash []byte) string {
+	return string(rune(invType)) + string(hash)
+}
+
+// markKnown records an item as seen and reports whether it was new,
+// forgetting the oldest item once maxKnownInventory is reached.
+func (c *inventoryCache) markKnown(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.known[key]; exists {
+		return false
+	}
+	if len(c.knownOrder) >= maxKnownInventory {
+		delete(c.known, c.knownOrder[0])
+		c.knownOrder = c.knownOrder[1:]
+	}
+	c.known[key] = struct{}{}
+	c.knownOrder = append(c.knownOrder, key)
+	return true
+}
+
+// forget removes an item requested from a peer that did not deliver it, so
+// that an announcement from another peer fetches it again.
+func (c *inventoryCache) forget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.known, key)
+	for i, k := range c.knownOrder {
+		if k == key {
+			c.knownOrder = append(c.knownOrder[:i], c.knownOrder[i+1:]...)
+			break
+		}
+	}
+}
+
+// store keeps the data of an announced item, forgetting the oldest once
+// maxRelayInventory is reached.
+func (c *inventoryCache) store(key string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.relay[key]; !exists {
+		if len(c.relayOrder) >= maxRelayInventory {
+			delete(c.relay, c.relayOrder[0])
+			c.relayOrder = c.relayOrder[1:]
+		}
+		c.relayOrder = append(c.relayOrder, key)
+	}
+	c.relay[key] = data
+}
+
+// get returns the data of an announced item and counts it as served.
+func (c *inventoryCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, exists := c.relay[key]
+	if exists {
+		c.served++
+	}
+	return data, exists
+}
+
+// SetInventoryHandler sets the function called with every block or
+// transaction fetched from a peer after it announced it. An error marks the
+// item as rejected; it is logged and not fetched again.
+func (n *Network) SetInventoryHandler(handler func(from peer.ID, invType proto_net.InvType, data []byte) error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.onInventory = handler
+}
+
+// SetInventoryLookup sets the function reporting whether this node already
+// has a block or transaction, in which case announcements of it are not
+// followed by a request. Without a lookup only items seen through inventory
+// relay are skipped.
+func (n *Network) SetInventoryLookup(lookup func(invType proto_net.InvType, hash []byte) bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.haveInventory = lookup
+}
+
+// AnnounceInventory announces a block or transaction to all connected peers
+// by hash, keeping its data so that peers lacking it can request it.
+func (n *Network) AnnounceInventory(invType proto_net.InvType, hash, data []byte) error {
+	key := inventoryKey(invType, hash)
+	n.inventory.markKnown(key)
+	n.inventory.store(key, data)
+
+	msg, err := proto.Marshal(&proto_net.Message{
+		TimestampUnixNano: time.Now().UnixNano(),
+		FromPeerId:        []byte(n.host.ID()),
+		Content: &proto_net.Message_InvMessage{
+			InvMessage: &proto_net.InvMessage{
+				Inventory: []*proto_net.InvVector{{Type: invType, Hash: hash}},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal inventory message: %w", err)
+	}
+
+	for _, p := range n.host.Network().Peers() {
+		if n.isIncompatible(p) {
+			continue
+		}
+		go func(p peer.ID) {
+			if err := n.sendInventory(p, msg); err != nil {
+				fmt.Printf("Failed to announce inventory to %s: %v\n", p, err)
+			}
+		}(p)
+	}
+	return nil
+}
+
+// sendInventory writes an encoded inv message to a peer.
+func (n *Network) sendInventory(to peer.ID, msg []byte) error {
+	ctx, cancel := context.WithTimeout(n.ctx, inventoryTimeout)
+	defer cancel()
+
+	s, err := n.host.NewStream(ctx, to, inventoryProtocol)
+	if err != nil {
+		return fmt.Errorf("failed to open inventory stream to %s: %w", to, err)
+	}
+	defer s.Close()
+
+	s.SetWriteDeadline(time.Now().Add(inventoryTimeout))
+	if _, err := s.Write(msg); err != nil {
+		s.Reset()
+		return fmt.Errorf("failed to send inventory to %s: %w", to, err)
+	}
+	return nil
+}
+
+// handleInventory requests the items announced by a peer that this node
+// has neither seen nor already has.
+func (n *Network) handleInventory(s network.Stream) {
+	from := s.Conn().RemotePeer()
+
+	s.SetReadDeadline(time.Now().Add(inventoryTimeout))
+	data, err := io.ReadAll(io.LimitReader(s, maxInventoryMessageSize))
+	if err != nil {
+		s.Reset()
+		return
+	}
+	s.Close()
+
+	if n.isIncompatible(from) {
+		return
+	}
+	var msg proto_net.Message
+	if err := proto.Unmarshal(data, &msg); err != nil {
+		return
+	}
+	content, ok := msg.Content.(*proto_net.Message_InvMessage)
+	if !ok {
+		return
+	}
+
+	n.mu.RLock()
+	have := n.haveInventory
+	n.mu.RUnlock()
+
+	var wanted []*proto_net.InvVector
+	for i, inv := range content.InvMessage.Inventory {
+		if i >= maxInventoryItems {
+			break
+		}
+		if !validInventory(inv) {
+			continue
+		}
+		key := inventoryKey(inv.Type, inv.Hash)
+		if have != nil && have(inv.Type, inv.Hash) {
+			n.inventory.markKnown(key)
+			continue
+		}
+		if !n.inventory.markKnown(key) {
+			continue
+		}
+		wanted = append(wanted, inv)
+	}
+	if len(wanted) == 0 {
+		return
+	}
+
+	if err := n.requestData(from, wanted); err != nil {
+		fmt.Printf("Failed to fetch inventory from %s: %v\n", from, err)
+	}
+}
+
+// validInventory reports whether a vector names a known type and a
+// plausible hash.
+func validInventory(inv *proto_net.InvVector) bool {
+	if inv.Type != proto_net.InvType_INV_TX && inv.Type != proto_net.InvType_INV_BLOCK {
+		return false
+	}
+	return len(inv.Hash) > 0 && len(inv.Hash) <= maxInventoryHashLen
+}
+
+// requestData sends a getdata request for the wanted items to the peer that
+// announced them and passes each item returned to the inventory handler.
+// Items the peer does not return are forgotten so that they can be fetched
+// from another peer.
+func (n *Network) requestData(from peer.ID, wanted []*proto_net.InvVector) error {
+	pending := make(map[string]struct{}, len(wanted))
+	for _, inv := range wanted {
+		pending[inventoryKey(inv.Type, inv.Hash)] = struct{}{}
+	}
+	defer func() {
+		for key := range pending {
+			n.inventory.forget(key)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(n.ctx, inventoryTimeout)
+	defer cancel()
+
+	s, err := n.host.NewStream(ctx, from, getDataProtocol)
+	if err != nil {
+		return fmt.Errorf("failed to open getdata stream: %w", err)
+	}
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(inventoryTimeout))
+
+	request := &proto_net.Message{
+		TimestampUnixNano: time.Now().UnixNano(),
+		FromPeerId:        []byte(n.host.ID()),
+		Content: &proto_net.Message_GetDataMessage{
+			GetDataMessage: &proto_net.GetDataMessage{Inventory: wanted},
+		},
+	}
+	if _, err := protodelim.MarshalTo(s, request); err != nil {
+		s.Reset()
+		return fmt.Errorf("failed to send getdata: %w", err)
+	}
+	if err := s.CloseWrite(); err != nil {
+		s.Reset()
+		return fmt.Errorf("failed to send getdata: %w", err)
+	}
+
+	n.mu.RLock()
+	handler := n.onInventory
+	n.mu.RUnlock()
+
+	reader := bufio.NewReader(s)
+	opts := protodelim.UnmarshalOptions{MaxSize: maxInventoryItemSize}
+	for len(pending) > 0 {
+		var msg proto_net.Message
+		if err := opts.UnmarshalFrom(reader, &msg); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			s.Reset()
+			return fmt.Errorf("failed to read requested item: %w", err)
+		}
+
+		invType, data, hash, err := inventoryItem(&msg)
+		if err != nil {
+			s.Reset()
+			return err
+		}
+		key := inventoryKey(invType, hash)
+		if _, requested := pending[key]; !requested {
+			s.Reset()
+			return fmt.Errorf("peer sent unrequested %s %x", invType, hash)
+		}
+		delete(pending, key)
+
+		if handler == nil {
+			fmt.Printf("Fetched %s %x from %s with no inventory handler set\n", invType, hash, from)
+			continue
+		}
+		if err := handler(from, invType, data); err != nil {
+			fmt.Printf("Rejected %s %x fetched from %s: %v\n", invType, hash, from, err)
+		}
+	}
+	return nil
+}
+
+// inventoryItem extracts the block or transaction carried by a getdata reply
+// along with its hash, computed from the data rather than trusted.
+func inventoryItem(msg *proto_net.Message) (proto_net.InvType, []byte, []byte, error) {
+	switch content := msg.Content.(type) {
+	case *proto_net.Message_BlockMessage:
+		var b block.Block
+		if err := json.Unmarshal(content.BlockMessage.BlockData, &b); err != nil || b.Header == nil {
+			return 0, nil, nil, fmt.Errorf("%w: undecodable block in getdata reply", ErrMalformed)
+		}
+		return proto_net.InvType_INV_BLOCK, content.BlockMessage.BlockData, b.CalculateHash(), nil
+	case *proto_net.Message_TransactionMessage:
+		var tx block.Transaction
+		if err := json.Unmarshal(content.TransactionMessage.TransactionData, &tx); err != nil {
+			return 0, nil, nil, fmt.Errorf("%w: undecodable transaction in getdata reply", ErrMalformed)
+		}
+		return proto_net.InvType_INV_TX, content.TransactionMessage.TransactionData, tx.CalculateHash(), nil
+	default:
+		return 0, nil, nil, fmt.Errorf("%w: unexpected %T in getdata reply", ErrMalformed, content)
+	}
+}
+
+// handleGetData returns the requested items this node announced, in the
+// order requested, skipping those it no longer has.
+func (n *Network) handleGetData(s network.Stream) {
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(inventoryTimeout))
+
+	if n.isIncompatible(s.Conn().RemotePeer()) {
+		s.Reset()
+		return
+	}
+
+	var msg proto_net.Message
+	reader := bufio.NewReader(io.LimitReader(s, maxInventoryMessageSize))
+	if err := (protodelim.UnmarshalOptions{MaxSize: maxInventoryMessageSize}).UnmarshalFrom(reader, &msg); err != nil {
+		s.Reset()
+		return
+	}
+	content, ok := msg.Content.(*proto_net.Message_GetDataMessage)
+	if !ok {
+		s.Reset()
+		return
+	}
+
+	upload := n.UploadWriter(n.ctx, s.Conn().RemotePeer(), s)
+	for i, inv := range content.GetDataMessage.Inventory {
+		if i >= maxInventoryItems {
+			break
+		}
+		data, exists := n.inventory.get(inventoryKey(inv.Type, inv.Hash))
+		if !exists {
+			continue
+		}
+
+		reply := &proto_net.Message{
+			TimestampUnixNano: time.Now().UnixNano(),
+			FromPeerId:        []byte(n.host.ID()),
+		}
+		if inv.Type == proto_net.InvType_INV_BLOCK {
+			reply.Content = &proto_net.Message_BlockMessage{BlockMessage: &proto_net.BlockMessage{BlockData: data}}
+		} else {
+			reply.Content = &proto_net.Message_TransactionMessage{TransactionMessage: &proto_net.TransactionMessage{TransactionData: data}}
+		}
+
+		if _, err := protodelim.MarshalTo(upload, reply); err != nil {
+			s.Reset()
+			return
+		}
+	}
+}
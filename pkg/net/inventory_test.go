@@ -0,0 +1,119 @@
+package net
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/chain"
+	"github.com/palaseus/adrenochain/pkg/mempool"
+	proto_net "github.com/palaseus/adrenochain/pkg/proto/net"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInventoryCacheDedup(t *testing.T) {
+	c := newInventoryCache()
+	key := inventoryKey(proto_net.InvType_INV_TX, []byte{1})
+
+	assert.True(t, c.markKnown(key))
+	assert.False(t, c.markKnown(key), "an item is only new once")
+	assert.True(t, c.markKnown(inventoryKey(proto_net.InvType_INV_BLOCK, []byte{1})), "types do not share hashes")
+
+	c.forget(key)
+	assert.True(t, c.markKnown(key), "a forgotten item can be fetched again")
+}
+
+// TestInventoryRelay announces a transaction the receiver already has, which
+// must not be requested, and one it lacks, which must be requested and
+// handed to the receiver's inventory handler.
+func TestInventoryRelay(t *testing.T) {
+	nodes := make([]*Network, 2)
+	for i := range nodes {
+		config := DefaultNetworkConfig()
+		config.ListenPort = 0
+		config.EnableMDNS = false
+		config.EnableRelay = false
+		config.InventoryRelay = true
+
+		node, err := NewNetwork(config, &chain.Chain{}, mempool.NewMempool(mempool.TestMempoolConfig()))
+		require.NoError(t, err)
+		defer node.Close()
+		nodes[i] = node
+	}
+	sender, receiver := nodes[0], nodes[1]
+
+	newTx := func(fee uint64) (*block.Transaction, []byte) {
+		tx := &block.Transaction{
+			Version: 1,
+			Inputs:  []*block.TxInput{{PrevTxHash: bytes.Repeat([]byte{byte(fee)}, 32), ScriptSig: []byte{1}, Sequence: 0xffffffff}},
+			Outputs: []*block.TxOutput{{Value: 1000, ScriptPubKey: []byte{2}}},
+			Fee:     fee,
+		}
+		tx.Hash = tx.CalculateHash()
+		data, err := json.Marshal(tx)
+		require.NoError(t, err)
+		return tx, data
+	}
+	owned, ownedData := newTx(1)
+	missing, missingData := newTx(2)
+
+	lookups := make(chan []byte, 3)
+	receiver.SetInventoryLookup(func(invType proto_net.InvType, hash []byte) bool {
+		lookups <- hash
+		return bytes.Equal(hash, owned.Hash)
+	})
+	fetched := make(chan []byte, 2)
+	receiver.SetInventoryHandler(func(from peer.ID, invType proto_net.InvType, data []byte) error {
+		assert.Equal(t, sender.GetHost().ID(), from)
+		assert.Equal(t, proto_net.InvType_INV_TX, invType)
+		fetched <- data
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	require.NoError(t, receiver.GetHost().Connect(ctx, peer.AddrInfo{ID: sender.GetHost().ID(), Addrs: sender.GetHost().Addrs()}))
+
+	// An item the receiver already has is looked up but never requested
+	require.NoError(t, sender.AnnounceInventory(proto_net.InvType_INV_TX, owned.Hash, ownedData))
+	select {
+	case hash := <-lookups:
+		assert.Equal(t, owned.Hash, hash)
+	case <-ctx.Done():
+		t.Fatal("receiver never handled the inventory announcement")
+	}
+
+	// An item the receiver lacks is requested and transferred
+	require.NoError(t, sender.AnnounceInventory(proto_net.InvType_INV_TX, missing.Hash, missingData))
+	select {
+	case data := <-fetched:
+		assert.Equal(t, missingData, data)
+	case <-ctx.Done():
+		t.Fatal("missing transaction was not fetched")
+	}
+	assert.Equal(t, missing.Hash, <-lookups)
+
+	sender.inventory.mu.Lock()
+	served := sender.inventory.served
+	sender.inventory.mu.Unlock()
+	assert.Equal(t, 1, served, "only the missing transaction is requested")
+	assert.Empty(t, fetched)
+
+	// Announcing the fetched item again does not fetch it twice
+	require.NoError(t, sender.AnnounceInventory(proto_net.InvType_INV_TX, missing.Hash, missingData))
+	select {
+	case <-lookups:
+	case <-ctx.Done():
+		t.Fatal("receiver never handled the repeated announcement")
+	}
+	select {
+	case <-fetched:
+		t.Fatal("known transaction fetched again")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,187 @@
+package net
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+const (
+	// handshakeProtocol is the stream protocol on which connecting peers
+	// exchange their network magic, feature bitmask and clock.
+	handshakeProtocol = protocol.ID("/adrenochain/handshake/1.2.0")
+	// handshakeTimeout bounds the handshake with a new peer.
+	handshakeTimeout = 10 * time.Second
+	// magicSize is the size of the network magic on the wire.
+	magicSize = 4
+	// featuresSize is the size of the feature bitmask on the wire.
+	featuresSize = 8
+	// timeSize is the size of the sender's clock, in Unix seconds, on the
+	// wire.
+	timeSize = 8
+	// helloSize is the size of the handshake message.
+	helloSize = magicSize + featuresSize + timeSize
+)
+
+// Magic returns the network magic stamped on every message and checked
+// during the handshake.
+func (n *Network) Magic() uint32 {
+	return n.config.NetworkMagic
+}
+
+// sealMessage prefixes an encoded message with the network magic.
+func (n *Network) sealMessage(data []byte) []byte {
+	sealed := make([]byte, magicSize, magicSize+len(data))
+	binary.BigEndian.PutUint32(sealed, n.config.NetworkMagic)
+	return append(sealed, data...)
+}
+
+// OpenMessage checks the network magic of a message received on a gossip
+// topic and returns the encoded message that follows it.
+func (n *Network) OpenMessage(data []byte) ([]byte, error) {
+	if len(data) < magicSize {
+		return nil, fmt.Errorf("message too short for network magic: %d bytes", len(data))
+	}
+	if magic := binary.BigEndian.Uint32(data); magic != n.config.NetworkMagic {
+		return nil, fmt.Errorf("message network magic %08x does not match %08x", magic, n.config.NetworkMagic)
+	}
+	return data[magicSize:], nil
+}
+
+// validateMagic is the first check of the gossip validator of the block and
+// transaction topics. Messages stamped with another network's magic, or
+// relayed by a peer that failed the handshake, are rejected and so neither
+// delivered nor forwarded.
+func (n *Network) validateMagic(ctx context.Context, from peer.ID, msg *pubsub.Message) pubsub.ValidationResult {
+	if n.isIncompatible(from) {
+		return pubsub.ValidationReject
+	}
+	if _, err := n.OpenMessage(msg.Data); err != nil {
+		return pubsub.ValidationReject
+	}
+	return pubsub.ValidationAccept
+}
+
+// handshake sends our network magic, features and clock to a peer we
+// connected to and checks the magic it answers with, rejecting it on a
+// mismatch and disconnecting it if the exchange fails, and otherwise
+// recording the features it advertises and the offset of its clock, then
+// asking it for peer addresses if peer exchange is enabled.
+func (n *Network) handshake(p peer.ID) {
+	ctx, cancel := context.WithTimeout(n.ctx, handshakeTimeout)
+	defer cancel()
+
+	s, err := n.host.NewStream(ctx, p, handshakeProtocol)
+	if err != nil {
+		n.dropPeer(p, fmt.Errorf("handshake failed: %w", err))
+		return
+	}
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(handshakeTimeout))
+
+	if err := writeHello(s, n.config.NetworkMagic, n.features, time.Now()); err != nil {
+		s.Reset()
+		return
+	}
+	theirs, features, theirTime, err := readHello(s)
+	if err != nil {
+		s.Reset()
+		n.dropPeer(p, fmt.Errorf("handshake failed: %w", err))
+		return
+	}
+	if theirs != n.config.NetworkMagic {
+		n.rejectPeer(p, fmt.Errorf("network magic %08x does not match %08x", theirs, n.config.NetworkMagic))
+		return
+	}
+	n.setPeerFeatures(p, features)
+	n.clock.addSample(p, theirTime, time.Now())
+
+	if n.pex != nil {
+		go func() {
+			if _, err := n.RequestPeerAddrs(p); err != nil {
+				fmt.Printf("Peer exchange with %s failed: %v\n", p, err)
+			}
+		}()
+	}
+}
+
+// handleHandshake answers the handshake of a peer that connected to us.
+func (n *Network) handleHandshake(s network.Stream) {
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(handshakeTimeout))
+
+	p := s.Conn().RemotePeer()
+	theirs, features, theirTime, err := readHello(s)
+	if err != nil {
+		s.Reset()
+		return
+	}
+	if err := writeHello(s, n.config.NetworkMagic, n.features, time.Now()); err != nil {
+		s.Reset()
+	}
+	if theirs != n.config.NetworkMagic {
+		// Let the peer read our magic and reject us in turn, as it only
+		// disconnects without banning when the exchange fails
+		s.CloseWrite()
+		io.Copy(io.Discard, s)
+		n.rejectPeer(p, fmt.Errorf("network magic %08x does not match %08x", theirs, n.config.NetworkMagic))
+		return
+	}
+	n.setPeerFeatures(p, features)
+	n.clock.addSample(p, theirTime, time.Now())
+}
+
+// rejectPeer marks a peer as belonging to another network and disconnects it.
+func (n *Network) rejectPeer(p peer.ID, reason error) {
+	fmt.Printf("Rejecting peer %s: %v\n", p.String(), reason)
+	n.mu.Lock()
+	n.incompatible[p] = struct{}{}
+	n.mu.Unlock()
+	n.host.Network().ClosePeer(p)
+}
+
+// dropPeer disconnects a peer whose handshake failed without a magic
+// mismatch, e.g. on a timeout, leaving it free to connect again.
+func (n *Network) dropPeer(p peer.ID, reason error) {
+	fmt.Printf("Disconnecting peer %s: %v\n", p.String(), reason)
+	n.host.Network().ClosePeer(p)
+}
+
+// isIncompatible reports whether a peer failed the network magic handshake.
+func (n *Network) isIncompatible(p peer.ID) bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	_, incompatible := n.incompatible[p]
+	return incompatible
+}
+
+// writeHello writes the handshake message, a network magic followed by a
+// feature bitmask and the sender's clock, to w.
+func writeHello(w io.Writer, magic uint32, features PeerFeature, now time.Time) error {
+	var buf [helloSize]byte
+	binary.BigEndian.PutUint32(buf[:magicSize], magic)
+	binary.BigEndian.PutUint64(buf[magicSize:], uint64(features))
+	binary.BigEndian.PutUint64(buf[magicSize+featuresSize:], uint64(now.Unix()))
+	_, err := w.Write(buf[:])
+	return err
+}
+
+// readHello reads a handshake message from r.
+func readHello(r io.Reader) (uint32, PeerFeature, time.Time, error) {
+	var buf [helloSize]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, 0, time.Time{}, err
+	}
+	magic := binary.BigEndian.Uint32(buf[:magicSize])
+	features := PeerFeature(binary.BigEndian.Uint64(buf[magicSize:]))
+	sent := time.Unix(int64(binary.BigEndian.Uint64(buf[magicSize+featuresSize:])), 0)
+	return magic, features, sent, nil
+}
@@ -0,0 +1,112 @@
+package net
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/palaseus/adrenochain/pkg/chain"
+	"github.com/palaseus/adrenochain/pkg/mempool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSealAndOpenMessage(t *testing.T) {
+	mainnet := &Network{config: &NetworkConfig{NetworkMagic: 0x0badcafe}}
+	testnet := &Network{config: &NetworkConfig{NetworkMagic: 0x7e57e7e7}}
+
+	sealed := mainnet.sealMessage([]byte("payload"))
+	opened, err := mainnet.OpenMessage(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("payload"), opened)
+
+	_, err = testnet.OpenMessage(sealed)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match")
+
+	_, err = mainnet.OpenMessage([]byte{0x0b})
+	assert.Error(t, err)
+}
+
+// newMagicTestNetwork starts a node with the given network magic.
+func newMagicTestNetwork(t *testing.T, magic uint32) *Network {
+	config := DefaultNetworkConfig()
+	config.ListenPort = 0
+	config.EnableMDNS = false
+	config.EnableRelay = false
+	config.NetworkMagic = magic
+
+	n, err := NewNetwork(config, &chain.Chain{}, mempool.NewMempool(mempool.TestMempoolConfig()))
+	require.NoError(t, err)
+	t.Cleanup(func() { n.Close() })
+	return n
+}
+
+func TestNetworkMagicHandshake(t *testing.T) {
+	mainnet := newMagicTestNetwork(t, 0x0badcafe)
+	mainnetPeer := newMagicTestNetwork(t, 0x0badcafe)
+	testnet := newMagicTestNetwork(t, 0x7e57e7e7)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	mainnetInfo := peer.AddrInfo{ID: mainnet.GetHost().ID(), Addrs: mainnet.GetHost().Addrs()}
+
+	mainnetSub, err := mainnetPeer.SubscribeToBlocks()
+	require.NoError(t, err)
+	defer mainnetSub.Cancel()
+	testnetSub, err := testnet.SubscribeToBlocks()
+	require.NoError(t, err)
+	defer testnetSub.Cancel()
+
+	require.NoError(t, mainnetPeer.GetHost().Connect(ctx, mainnetInfo))
+	// The dial succeeds, but the handshake disconnects the testnet node
+	_ = testnet.GetHost().Connect(ctx, mainnetInfo)
+
+	require.Eventually(t, func() bool {
+		return mainnet.isIncompatible(testnet.GetHost().ID()) && testnet.isIncompatible(mainnet.GetHost().ID())
+	}, 10*time.Second, 50*time.Millisecond)
+	require.Eventually(t, func() bool {
+		return testnet.GetHost().Network().Connectedness(mainnet.GetHost().ID()) != network.Connected
+	}, 10*time.Second, 50*time.Millisecond)
+	assert.Equal(t, network.Connected, mainnetPeer.GetHost().Network().Connectedness(mainnet.GetHost().ID()))
+	assert.False(t, mainnet.isIncompatible(mainnetPeer.GetHost().ID()))
+
+	require.Eventually(t, func() bool {
+		return len(mainnet.pubsub.ListPeers("blocks")) == 1
+	}, 10*time.Second, 50*time.Millisecond)
+	require.NoError(t, mainnet.PublishBlock([]byte("mainnet block")))
+
+	// The node on the same network receives the block
+	msg, err := mainnetSub.Next(ctx)
+	require.NoError(t, err)
+	_, err = mainnetPeer.OpenMessage(msg.Data)
+	require.NoError(t, err)
+
+	// The testnet node does not
+	shortCtx, shortCancel := context.WithTimeout(ctx, 2*time.Second)
+	defer shortCancel()
+	_, err = testnetSub.Next(shortCtx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Messages stamped with another magic are rejected by the gossip validator
+	foreign := testnet.sealMessage([]byte("testnet block"))
+	assert.Error(t, mainnet.pubsub.Publish("blocks", foreign))
+}
+
+func TestFailedHandshakeDoesNotRejectPeer(t *testing.T) {
+	dialer := newMagicTestNetwork(t, 0x0badcafe)
+	silent := newMagicTestNetwork(t, 0x0badcafe)
+	// The peer does not answer handshakes, as if the exchange timed out
+	silent.GetHost().RemoveStreamHandler(handshakeProtocol)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	silentInfo := peer.AddrInfo{ID: silent.GetHost().ID(), Addrs: silent.GetHost().Addrs()}
+	_ = dialer.GetHost().Connect(ctx, silentInfo)
+
+	// The dialer disconnects it without marking it as another network's
+	dialer.handshake(silent.GetHost().ID())
+	assert.False(t, dialer.isIncompatible(silent.GetHost().ID()))
+}
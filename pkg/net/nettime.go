@@ -0,0 +1,109 @@
+package net
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// DefaultMaxClockOffset is the default bound on the clock offset taken
+	// from peers.
+	DefaultMaxClockOffset = 70 * time.Minute
+	// minTimeSamples is the number of peers that must report their time
+	// before the network time offset is applied.
+	minTimeSamples = 5
+	// maxTimeSamples is the number of peer time samples kept; once full,
+	// further peers are ignored so a flood of connections cannot shift the
+	// median.
+	maxTimeSamples = 200
+)
+
+// networkTime estimates the offset of the local clock from the network as
+// the median of the offsets peers report in their handshakes. The offset is
+// bounded by maxOffset: a larger median means the local clock is probably
+// wrong, which is reported with a warning, and only maxOffset is applied.
+type networkTime struct {
+	mu        sync.Mutex
+	maxOffset time.Duration
+	samples   map[peer.ID]time.Duration
+	offset    time.Duration
+	warned    bool
+	warn      func(format string, args ...interface{})
+}
+
+// newNetworkTime creates an estimator whose offset never exceeds maxOffset
+// in either direction. A zero maxOffset uses DefaultMaxClockOffset.
+func newNetworkTime(maxOffset time.Duration) *networkTime {
+	if maxOffset == 0 {
+		maxOffset = DefaultMaxClockOffset
+	}
+	return &networkTime{
+		maxOffset: maxOffset,
+		samples:   make(map[peer.ID]time.Duration),
+		warn: func(format string, args ...interface{}) {
+			fmt.Printf("WARNING: "+format+"\n", args...)
+		},
+	}
+}
+
+// addSample records the time a peer reported, received when the local clock
+// read local, and recomputes the offset. Only the first sample of each peer
+// counts.
+func (nt *networkTime) addSample(p peer.ID, reported, local time.Time) {
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+	if _, exists := nt.samples[p]; exists || len(nt.samples) >= maxTimeSamples {
+		return
+	}
+	nt.samples[p] = reported.Sub(local).Truncate(time.Second)
+	if len(nt.samples) < minTimeSamples {
+		return
+	}
+
+	offsets := make([]time.Duration, 0, len(nt.samples))
+	for _, offset := range nt.samples {
+		offsets = append(offsets, offset)
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	median := offsets[len(offsets)/2]
+
+	switch {
+	case median > nt.maxOffset:
+		nt.offset = nt.maxOffset
+	case median < -nt.maxOffset:
+		nt.offset = -nt.maxOffset
+	default:
+		nt.offset = median
+		return
+	}
+	if !nt.warned {
+		nt.warned = true
+		nt.warn("peers report a clock offset of %v, more than the %v allowed; check that the local clock is correct", median, nt.maxOffset)
+	}
+}
+
+// get returns the current offset.
+func (nt *networkTime) get() time.Duration {
+	nt.mu.Lock()
+	defer nt.mu.Unlock()
+	return nt.offset
+}
+
+// TimeOffset returns the estimated offset of the local clock from the
+// network, the median of the offsets reported by peers bounded by
+// MaxClockOffset. It is zero until enough peers have been sampled.
+func (n *Network) TimeOffset() time.Duration {
+	if n.clock == nil {
+		return 0
+	}
+	return n.clock.get()
+}
+
+// AdjustedTime returns the local time corrected by TimeOffset.
+func (n *Network) AdjustedTime() time.Time {
+	return time.Now().Add(n.TimeOffset())
+}
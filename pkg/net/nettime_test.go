@@ -0,0 +1,67 @@
+package net
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+)
+
+// sampleClocks feeds the estimator one handshake per mock peer, each
+// reporting a clock the given offset away from local.
+func sampleClocks(nt *networkTime, local time.Time, offsets ...time.Duration) {
+	for i, offset := range offsets {
+		nt.addSample(peer.ID(fmt.Sprintf("peer-%d", i)), local.Add(offset), local)
+	}
+}
+
+func TestNetworkTimeMedianOffset(t *testing.T) {
+	local := time.Unix(1700000000, 0)
+
+	t.Run("needs enough peers", func(t *testing.T) {
+		nt := newNetworkTime(DefaultMaxClockOffset)
+		sampleClocks(nt, local, time.Minute, time.Minute, time.Minute, time.Minute)
+		assert.Zero(t, nt.get())
+	})
+
+	t.Run("median ignores outliers", func(t *testing.T) {
+		nt := newNetworkTime(DefaultMaxClockOffset)
+		sampleClocks(nt, local, -3*time.Second, 5*time.Second, 12*time.Second, 20*time.Second, 48*time.Hour)
+		assert.Equal(t, 12*time.Second, nt.get())
+	})
+
+	t.Run("repeated peer counts once", func(t *testing.T) {
+		nt := newNetworkTime(DefaultMaxClockOffset)
+		sampleClocks(nt, local, time.Second, 2*time.Second, 3*time.Second, 4*time.Second, 5*time.Second)
+		nt.addSample(peer.ID("peer-0"), local.Add(time.Hour), local)
+		assert.Equal(t, 3*time.Second, nt.get())
+	})
+
+	t.Run("bounded with a warning", func(t *testing.T) {
+		nt := newNetworkTime(10 * time.Minute)
+		var warnings []string
+		nt.warn = func(format string, args ...interface{}) {
+			warnings = append(warnings, fmt.Sprintf(format, args...))
+		}
+
+		sampleClocks(nt, local, -time.Hour, -2*time.Hour, -3*time.Hour, -90*time.Minute, -time.Minute)
+		assert.Equal(t, -10*time.Minute, nt.get())
+		assert.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], "-1h30m0s")
+	})
+}
+
+func TestAdjustedTime(t *testing.T) {
+	n := &Network{clock: newNetworkTime(DefaultMaxClockOffset)}
+	sampleClocks(n.clock, time.Now(), time.Hour, time.Hour, time.Hour, time.Hour, time.Hour)
+
+	assert.InDelta(t, time.Hour.Seconds(), n.TimeOffset().Seconds(), 1)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), n.AdjustedTime(), 2*time.Second)
+	assert.Zero(t, (&Network{}).TimeOffset())
+
+	config := DefaultNetworkConfig()
+	config.MaxClockOffset = -time.Minute
+	assert.ErrorContains(t, config.Validate(), "max clock offset must not be negative")
+}
@@ -3,6 +3,7 @@ package net
 import (
 	"context"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -16,9 +17,11 @@ import (
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
 	"github.com/palaseus/adrenochain/pkg/chain"
 	"github.com/palaseus/adrenochain/pkg/mempool"
 	proto_net "github.com/palaseus/adrenochain/pkg/proto/net"
+	"github.com/palaseus/adrenochain/pkg/storage"
 
 	dht "github.com/libp2p/go-libp2p-kad-dht"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
@@ -34,10 +37,35 @@ import (
 // Notifiee methods for network.Notifiee interface
 func (n *Network) Connected(net network.Network, conn network.Conn) {
 	fmt.Printf("Connected to: %s/p2p/%s\n", conn.RemoteMultiaddr(), conn.RemotePeer().String())
+	if n.incompatible != nil && n.isIncompatible(conn.RemotePeer()) {
+		go conn.Close()
+		return
+	}
+	if n.addrBook != nil {
+		n.addrBook.RecordSuccess(conn.RemotePeer(), conn.RemoteMultiaddr())
+	}
+	if n.host != nil && conn.Stat().Direction == network.DirOutbound {
+		go n.handshake(conn.RemotePeer())
+	}
 }
 
 func (n *Network) Disconnected(net network.Network, conn network.Conn) {
 	fmt.Printf("Disconnected from: %s/p2p/%s\n", conn.RemoteMultiaddr(), conn.RemotePeer().String())
+	if n.announcements != nil {
+		n.announcements.forget(conn.RemotePeer())
+	}
+	if net == nil || net.Connectedness(conn.RemotePeer()) != network.Connected {
+		if n.peerFeatures != nil {
+			n.forgetPeerFeatures(conn.RemotePeer())
+		}
+		if n.persistent != nil {
+			n.peerDropped(conn.RemotePeer())
+		}
+		if n.uploads != nil {
+			n.uploads.forget(conn.RemotePeer())
+		}
+		n.downloadRates.forget(conn.RemotePeer())
+	}
 }
 
 func (n *Network) OpenedStream(net network.Network, s network.Stream) {
@@ -120,6 +148,22 @@ type Network struct {
 	chain          *chain.Chain
 	mempool        *mempool.Mempool
 	privKey        crypto.PrivKey // Private key of the host
+	addrBook       *AddrBook      // Persistent scored peer addresses, nil if disabled
+	announcements  *announcementLimiter
+	propagation    *propagationTracker
+	incompatible   map[peer.ID]struct{}    // Peers that failed the network magic handshake
+	features       PeerFeature             // Features advertised to peers
+	peerFeatures   map[peer.ID]PeerFeature // Features advertised by connected peers
+	clock          *networkTime            // Clock offset estimated from peers
+	whitelist      *relayWhitelist         // Trusted peers exempt from relay policy and rate limits
+	persistent     *persistentPeers        // Peers kept connected at all times
+	onReject       func(Reject)            // Called with reject messages received from peers
+	inventory      *inventoryCache         // Seen items and the data of announced ones
+	onInventory    func(peer.ID, proto_net.InvType, []byte) error
+	haveInventory  func(proto_net.InvType, []byte) bool
+	uploads        *uploadLimiter       // Per-peer cap on the bytes served to peers
+	downloadRates  downloadRates        // Block download throughput measured per peer
+	pex            *peerExchangeLimiter // Spaces out address requests served, nil if peer exchange is disabled
 }
 
 // PeerInfo holds information about a connected peer
@@ -139,6 +183,76 @@ type NetworkConfig struct {
 	EnableRelay       bool
 	MaxPeers          int
 	ConnectionTimeout time.Duration
+	// RequiredSecurity is the only security protocol accepted on connections
+	// (e.g. "/noise" or "/tls/1.0.0"). Empty accepts any encrypted transport;
+	// plaintext connections are always refused.
+	RequiredSecurity string
+	// AddrBookStore persists a scored address book of known peers across
+	// restarts. Nil disables the address book.
+	AddrBookStore storage.StorageInterface
+	// MaxAnnouncementsPerPeer is the number of block or transaction
+	// announcements accepted from one peer per topic in each
+	// AnnouncementWindow; excess announcements are ignored. Zero disables
+	// the limit.
+	MaxAnnouncementsPerPeer int
+	AnnouncementWindow      time.Duration
+	// NetworkMagic identifies the chain this node follows. It is exchanged
+	// when peers connect and stamped on every gossip message; peers and
+	// messages with another magic are dropped.
+	NetworkMagic uint32
+	// Whitelist lists trusted peers, by peer ID or CIDR subnet, whose
+	// transactions skip relay policy (but not consensus) checks and whose
+	// announcements are not rate limited.
+	Whitelist []string
+	// PersistentPeers lists peer multiaddrs, including the peer ID, that
+	// the node keeps connected to, redialing them with backoff whenever
+	// they drop regardless of MaxPeers and peer scoring.
+	PersistentPeers []string
+	// BlockQueueSize is the number of received block messages queued for
+	// processing; blocks arriving while the queue is full are dropped.
+	BlockQueueSize int
+	// StallTimeout is how long block download during sync may go without
+	// applying a block before the slowest peer is evicted and its work
+	// reassigned. Zero uses DefaultStallTimeout.
+	StallTimeout time.Duration
+	// Features lists the optional protocol features, by name (e.g.
+	// "compact_blocks"), advertised to peers in the handshake. A feature is
+	// only used with peers that advertise it too.
+	Features []string
+	// MaxClockOffset bounds the adjustment of the local clock by the median
+	// clock offset of peers; a larger median is logged as a warning. Zero
+	// uses DefaultMaxClockOffset.
+	MaxClockOffset time.Duration
+	// InventoryRelay announces new blocks and transactions to peers by hash
+	// instead of gossiping them in full; peers then request only the items
+	// they lack.
+	InventoryRelay bool
+	// MaxUploadRatePerPeer caps the bytes per second sent to a single peer
+	// on the gossip streams and in reply to getdata requests, so that one
+	// peer cannot starve the others. Zero disables the cap; whitelisted
+	// peers are never throttled.
+	MaxUploadRatePerPeer int
+	// MaxDownloadPeers is the number of peers blocks are downloaded from at
+	// once during sync, the fastest measured first; the others replace
+	// peers evicted for stalling. Zero downloads from every peer.
+	MaxDownloadPeers int
+	// MaxBlockMessageSize and MaxTxMessageSize bound the size of messages
+	// on the blocks and transactions topics. Larger messages, like unsigned
+	// or otherwise malformed ones, are dropped by the gossip validator
+	// before they are delivered or forwarded. Zero uses
+	// DefaultMaxBlockMessageSize and DefaultMaxTxMessageSize; nodes derive
+	// MaxTxMessageSize from their relay policy with TxMessageSize.
+	MaxBlockMessageSize int
+	MaxTxMessageSize    int
+	// PeerExchange asks each peer this node connects to for the addresses
+	// of peers it knows to be reachable, and answers such requests, so that
+	// peers are found beyond DHT and mDNS discovery. Learned addresses are
+	// added to the address book and dialed while below MaxPeers.
+	PeerExchange bool
+	// PeerExchangeInterval is the minimum time between two address
+	// requests served to the same peer; earlier requests are refused. Zero
+	// uses DefaultPeerExchangeInterval.
+	PeerExchangeInterval time.Duration
 }
 
 // DefaultNetworkConfig returns the default network configuration
@@ -150,7 +264,77 @@ func DefaultNetworkConfig() *NetworkConfig {
 		EnableRelay:       false,
 		MaxPeers:          50,
 		ConnectionTimeout: 30 * time.Second,
+		RequiredSecurity:  noise.ID,
+
+		MaxAnnouncementsPerPeer: DefaultMaxAnnouncementsPerPeer,
+		AnnouncementWindow:      DefaultAnnouncementWindow,
+		BlockQueueSize:          DefaultBlockQueueSize,
+		StallTimeout:            DefaultStallTimeout,
+		MaxClockOffset:          DefaultMaxClockOffset,
+	}
+}
+
+// Validate checks that the configuration is usable and returns every
+// problem found.
+func (nc *NetworkConfig) Validate() error {
+	var errs []error
+	if nc.ListenPort < 0 || nc.ListenPort > 65535 {
+		errs = append(errs, fmt.Errorf("network: listen port %d is outside 0-65535", nc.ListenPort))
+	}
+	if nc.MaxPeers <= 0 {
+		errs = append(errs, fmt.Errorf("network: max peers must be positive, got %d", nc.MaxPeers))
+	}
+	if nc.ConnectionTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("network: connection timeout must be positive"))
+	}
+	if _, err := securityOptions(nc.RequiredSecurity); err != nil {
+		errs = append(errs, fmt.Errorf("network: %w", err))
+	}
+	for _, addr := range nc.BootstrapPeers {
+		if _, err := multiaddr.NewMultiaddr(addr); err != nil {
+			errs = append(errs, fmt.Errorf("network: invalid bootstrap peer %q: %w", addr, err))
+		}
+	}
+	if nc.MaxAnnouncementsPerPeer < 0 {
+		errs = append(errs, fmt.Errorf("network: max announcements per peer %d is negative", nc.MaxAnnouncementsPerPeer))
+	}
+	if nc.AnnouncementWindow < 0 {
+		errs = append(errs, fmt.Errorf("network: announcement window must not be negative"))
+	}
+	if nc.BlockQueueSize < 0 {
+		errs = append(errs, fmt.Errorf("network: block queue size %d is negative", nc.BlockQueueSize))
+	}
+	if nc.StallTimeout < 0 {
+		errs = append(errs, fmt.Errorf("network: stall timeout must not be negative"))
+	}
+	if _, err := parseWhitelist(nc.Whitelist); err != nil {
+		errs = append(errs, fmt.Errorf("network: %w", err))
+	}
+	if _, err := parsePersistentPeers(nc.PersistentPeers); err != nil {
+		errs = append(errs, fmt.Errorf("network: %w", err))
+	}
+	if _, err := parseFeatures(nc.Features); err != nil {
+		errs = append(errs, fmt.Errorf("network: %w", err))
+	}
+	if nc.MaxClockOffset < 0 {
+		errs = append(errs, fmt.Errorf("network: max clock offset must not be negative"))
+	}
+	if nc.MaxUploadRatePerPeer < 0 {
+		errs = append(errs, fmt.Errorf("network: max upload rate per peer %d is negative", nc.MaxUploadRatePerPeer))
+	}
+	if nc.MaxDownloadPeers < 0 {
+		errs = append(errs, fmt.Errorf("network: max download peers %d is negative", nc.MaxDownloadPeers))
+	}
+	if nc.MaxBlockMessageSize < 0 {
+		errs = append(errs, fmt.Errorf("network: max block message size %d is negative", nc.MaxBlockMessageSize))
+	}
+	if nc.MaxTxMessageSize < 0 {
+		errs = append(errs, fmt.Errorf("network: max transaction message size %d is negative", nc.MaxTxMessageSize))
+	}
+	if nc.PeerExchangeInterval < 0 {
+		errs = append(errs, fmt.Errorf("network: peer exchange interval must not be negative"))
 	}
+	return errors.Join(errs...)
 }
 
 // NewNetwork creates a new P2P network
@@ -164,16 +348,39 @@ func NewNetwork(config *NetworkConfig, chain *chain.Chain, mempool *mempool.Memp
 		return nil, fmt.Errorf("failed to generate key pair: %w", err)
 	}
 
+	securityOpts, err := securityOptions(config.RequiredSecurity)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	whitelist, err := parseWhitelist(config.Whitelist)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	persistentPeers, err := parsePersistentPeers(config.PersistentPeers)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	features, err := parseFeatures(config.Features)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
 	// Create libp2p host options
 	hostOpts := []libp2p.Option{
 		libp2p.Identity(priv),
 		libp2p.ListenAddrStrings(fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", config.ListenPort)),
 		libp2p.ListenAddrStrings(fmt.Sprintf("/ip4/0.0.0.0/tcp/%d/ws", config.ListenPort)),
-		libp2p.Security(noise.ID, noise.New),
 		libp2p.Transport(tcp.NewTCPTransport),
 		libp2p.Transport(websocket.New),
 		libp2p.EnableHolePunching(),
+		libp2p.ConnectionGater(&securityGater{required: protocol.ID(config.RequiredSecurity)}),
 	}
+	hostOpts = append(hostOpts, securityOpts...)
 
 	// Only enable NAT port mapping if not in test mode
 	if !isTestEnvironment() {
@@ -194,8 +401,10 @@ func NewNetwork(config *NetworkConfig, chain *chain.Chain, mempool *mempool.Memp
 		return nil, fmt.Errorf("failed to create DHT: %w", err)
 	}
 
-	// Create pubsub
-	pubsub, err := pubsub.NewGossipSub(ctx, host, pubsub.WithMessageSigning(true))
+	// Create pubsub. Its streams are opened through uploadHost so that the
+	// blocks and transactions gossiped to a peer respect the upload cap.
+	gossipHost := &uploadHost{Host: host}
+	pubsub, err := pubsub.NewGossipSub(ctx, gossipHost, pubsub.WithMessageSigning(true))
 	if err != nil {
 		cancel()
 		return nil, fmt.Errorf("failed to create pubsub: %w", err)
@@ -223,10 +432,43 @@ func NewNetwork(config *NetworkConfig, chain *chain.Chain, mempool *mempool.Memp
 		chain:          chain,
 		mempool:        mempool,
 		privKey:        priv,
+		announcements:  newAnnouncementLimiter(config.MaxAnnouncementsPerPeer, config.AnnouncementWindow),
+		propagation:    newPropagationTracker(),
+		incompatible:   make(map[peer.ID]struct{}),
+		features:       features,
+		peerFeatures:   make(map[peer.ID]PeerFeature),
+		clock:          newNetworkTime(config.MaxClockOffset),
+		whitelist:      whitelist,
+		persistent:     newPersistentPeers(persistentPeers, host.Connect),
+		inventory:      newInventoryCache(),
+		uploads:        newUploadLimiter(config.MaxUploadRatePerPeer),
+	}
+	gossipHost.network.Store(network)
+
+	if config.AddrBookStore != nil {
+		network.addrBook = NewAddrBook(config.AddrBookStore)
+		if err := network.addrBook.Load(); err != nil {
+			fmt.Printf("Failed to load address book: %v\n", err)
+		}
 	}
 
 	// Set up event handlers
 	host.Network().Notify(network)
+	host.SetStreamHandler(blockAckProtocol, network.handleBlockAck)
+	host.SetStreamHandler(handshakeProtocol, network.handleHandshake)
+	host.SetStreamHandler(rejectProtocol, network.handleReject)
+	host.SetStreamHandler(inventoryProtocol, network.handleInventory)
+	host.SetStreamHandler(getDataProtocol, network.handleGetData)
+	if config.PeerExchange {
+		network.pex = newPeerExchangeLimiter(config.PeerExchangeInterval)
+		host.SetStreamHandler(pexProtocol, network.handlePeerExchange)
+	}
+	for _, topic := range []string{"blocks", "transactions"} {
+		if err := pubsub.RegisterTopicValidator(topic, network.validateGossip(topic)); err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to register %s validator: %w", topic, err)
+		}
+	}
 
 	// Start peer discovery
 	if err := network.startPeerDiscovery(); err != nil {
@@ -234,8 +476,12 @@ func NewNetwork(config *NetworkConfig, chain *chain.Chain, mempool *mempool.Memp
 		return nil, fmt.Errorf("failed to start peer discovery: %w", err)
 	}
 
-	// Connect to bootstrap peers
+	// Connect to persistent, bootstrap and previously reliable peers
+	network.connectPersistentPeers()
 	go network.connectToBootstrapPeers()
+	if network.addrBook != nil {
+		go network.connectToKnownPeers()
+	}
 
 	return network, nil
 }
@@ -288,6 +534,9 @@ func (n *Network) connectToBootstrapPeers() {
 
 			if err := n.host.Connect(n.ctx, *peerinfo); err != nil {
 				fmt.Printf("Failed to connect to bootstrap peer %s: %v\n", peerinfo.ID.String(), err)
+				if n.addrBook != nil {
+					n.addrBook.RecordFailure(peerinfo.ID)
+				}
 			} else {
 				fmt.Printf("Connected to bootstrap peer: %s\n", peerinfo.ID.String())
 			}
@@ -296,9 +545,36 @@ func (n *Network) connectToBootstrapPeers() {
 	wg.Wait()
 }
 
+// connectToKnownPeers dials the highest scored peers from the address book,
+// leaving room for bootstrap and discovered peers within MaxPeers.
+func (n *Network) connectToKnownPeers() {
+	for _, info := range n.addrBook.BestPeers(n.config.MaxPeers) {
+		if info.ID == n.host.ID() {
+			continue
+		}
+		if len(n.host.Network().Peers()) >= n.config.MaxPeers {
+			return
+		}
+		if err := n.host.Connect(n.ctx, info); err != nil {
+			n.addrBook.RecordFailure(info.ID)
+			fmt.Printf("Failed to connect to known peer %s: %v\n", info.ID.String(), err)
+		}
+	}
+}
+
+// GetAddrBook returns the peer address book, or nil if it is disabled.
+func (n *Network) GetAddrBook() *AddrBook {
+	return n.addrBook
+}
+
 // Close closes the network host and DHT
 func (n *Network) Close() error {
 	n.cancel()
+	if n.addrBook != nil {
+		if err := n.addrBook.Save(); err != nil {
+			fmt.Printf("Failed to save address book: %v\n", err)
+		}
+	}
 	if err := n.host.Close(); err != nil {
 		return fmt.Errorf("failed to close host: %w", err)
 	}
@@ -386,7 +662,7 @@ func (n *Network) PublishBlock(blockData []byte) error {
 		return fmt.Errorf("failed to marshal block message: %w", err)
 	}
 
-	return n.pubsub.Publish("blocks", data)
+	return n.pubsub.Publish("blocks", n.sealMessage(data))
 }
 
 // PublishTransaction publishes a transaction to the network
@@ -432,7 +708,7 @@ func (n *Network) PublishTransaction(txData []byte) error {
 		return fmt.Errorf("failed to marshal transaction message: %w", err)
 	}
 
-	return n.pubsub.Publish("transactions", data)
+	return n.pubsub.Publish("transactions", n.sealMessage(data))
 }
 
 // isTestEnvironment checks if the code is running in a test environment
@@ -3253,3 +3253,21 @@ func TestNetworkWithExtremeMaxPeersScenarios(t *testing.T) {
 		})
 	}
 }
+
+func TestNetworkConfigValidate(t *testing.T) {
+	assert.NoError(t, DefaultNetworkConfig().Validate())
+
+	config := DefaultNetworkConfig()
+	config.ListenPort = 70000
+	config.MaxPeers = 0
+	config.RequiredSecurity = "/plaintext/2.0.0"
+	config.BootstrapPeers = []string{"not-a-multiaddr"}
+	config.MaxAnnouncementsPerPeer = -1
+	err := config.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "listen port 70000 is outside 0-65535")
+	assert.Contains(t, err.Error(), "max peers must be positive, got 0")
+	assert.Contains(t, err.Error(), "unsupported security protocol: /plaintext/2.0.0")
+	assert.Contains(t, err.Error(), `invalid bootstrap peer "not-a-multiaddr"`)
+	assert.Contains(t, err.Error(), "max announcements per peer -1 is negative")
+}
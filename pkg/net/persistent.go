@@ -0,0 +1,148 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// persistentPeerMinBackoff is the delay before retrying a failed dial to
+	// a persistent peer; it doubles on every consecutive failure.
+	persistentPeerMinBackoff = time.Second
+	// persistentPeerMaxBackoff caps the delay between dials to a persistent
+	// peer.
+	persistentPeerMaxBackoff = 5 * time.Minute
+)
+
+// persistentPeers tracks the peers the node keeps connected to, redialing
+// them with exponential backoff whenever they drop, regardless of MaxPeers
+// or their address book score.
+type persistentPeers struct {
+	mu      sync.Mutex
+	peers   map[peer.ID]peer.AddrInfo
+	dialing map[peer.ID]bool // dialing is set while a reconnection loop runs.
+
+	dial       func(ctx context.Context, info peer.AddrInfo) error
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// parsePersistentPeers parses persistent peer multiaddrs, each of which must
+// include the peer ID.
+func parsePersistentPeers(addrs []string) ([]peer.AddrInfo, error) {
+	infos := make([]peer.AddrInfo, 0, len(addrs))
+	for _, addr := range addrs {
+		info, err := peer.AddrInfoFromString(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid persistent peer %q: %w", addr, err)
+		}
+		infos = append(infos, *info)
+	}
+	return infos, nil
+}
+
+// newPersistentPeers creates the set of persistent peers dialed with dial.
+func newPersistentPeers(infos []peer.AddrInfo, dial func(ctx context.Context, info peer.AddrInfo) error) *persistentPeers {
+	pp := &persistentPeers{
+		peers:      make(map[peer.ID]peer.AddrInfo, len(infos)),
+		dialing:    make(map[peer.ID]bool),
+		dial:       dial,
+		minBackoff: persistentPeerMinBackoff,
+		maxBackoff: persistentPeerMaxBackoff,
+	}
+	for _, info := range infos {
+		pp.peers[info.ID] = info
+	}
+	return pp
+}
+
+// startDialing marks a reconnection loop to a persistent peer as running and
+// reports whether the caller should run it.
+func (pp *persistentPeers) startDialing(id peer.ID) (peer.AddrInfo, bool) {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	info, persistent := pp.peers[id]
+	if !persistent || pp.dialing[id] {
+		return peer.AddrInfo{}, false
+	}
+	pp.dialing[id] = true
+	return info, true
+}
+
+// stopDialing marks the reconnection loop to a peer as finished.
+func (pp *persistentPeers) stopDialing(id peer.ID) {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	delete(pp.dialing, id)
+}
+
+// IsPersistentPeer reports whether the node keeps a connection to the peer
+// at all times.
+func (n *Network) IsPersistentPeer(id peer.ID) bool {
+	if n.persistent == nil {
+		return false
+	}
+	n.persistent.mu.Lock()
+	defer n.persistent.mu.Unlock()
+	_, persistent := n.persistent.peers[id]
+	return persistent
+}
+
+// connectPersistentPeers starts a reconnection loop for every persistent peer.
+func (n *Network) connectPersistentPeers() {
+	n.persistent.mu.Lock()
+	ids := make([]peer.ID, 0, len(n.persistent.peers))
+	for id := range n.persistent.peers {
+		ids = append(ids, id)
+	}
+	n.persistent.mu.Unlock()
+
+	for _, id := range ids {
+		go n.maintainPersistentPeer(id)
+	}
+}
+
+// peerDropped is called once the node has no connection left to a peer and
+// starts reconnecting to it if it is persistent.
+func (n *Network) peerDropped(id peer.ID) {
+	if n.IsPersistentPeer(id) {
+		go n.maintainPersistentPeer(id)
+	}
+}
+
+// maintainPersistentPeer dials a persistent peer until it connects, backing
+// off exponentially between attempts. It gives up when the network closes or
+// the peer turns out to follow another network.
+func (n *Network) maintainPersistentPeer(id peer.ID) {
+	info, start := n.persistent.startDialing(id)
+	if !start {
+		return
+	}
+	defer n.persistent.stopDialing(id)
+
+	backoff := n.persistent.minBackoff
+	for {
+		if n.isIncompatible(id) {
+			return
+		}
+		err := n.persistent.dial(n.ctx, info)
+		if err == nil {
+			return
+		}
+		fmt.Printf("Failed to connect to persistent peer %s, retrying in %v: %v\n", id.String(), backoff, err)
+
+		select {
+		case <-n.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > n.persistent.maxBackoff {
+			backoff = n.persistent.maxBackoff
+		}
+	}
+}
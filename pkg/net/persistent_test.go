@@ -0,0 +1,77 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePersistentPeers(t *testing.T) {
+	id := newTestPeerID(t)
+	infos, err := parsePersistentPeers([]string{"/ip4/10.0.0.1/tcp/4001/p2p/" + id.String()})
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.Equal(t, id, infos[0].ID)
+
+	_, err = parsePersistentPeers([]string{"/ip4/10.0.0.1/tcp/4001"})
+	assert.Error(t, err)
+}
+
+// TestPersistentPeerReconnection checks that dropping a persistent peer
+// starts redialing it, with retries after failures, while dropping any other
+// peer does not.
+func TestPersistentPeerReconnection(t *testing.T) {
+	persistent := newTestPeerID(t)
+	other := newTestPeerID(t)
+
+	dials := make(chan peer.ID, 10)
+	failures := 2
+	dial := func(ctx context.Context, info peer.AddrInfo) error {
+		dials <- info.ID
+		if failures > 0 {
+			failures--
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n := &Network{
+		ctx:          ctx,
+		incompatible: make(map[peer.ID]struct{}),
+		persistent:   newPersistentPeers([]peer.AddrInfo{{ID: persistent}}, dial),
+	}
+	n.persistent.minBackoff = time.Millisecond
+	n.persistent.maxBackoff = 4 * time.Millisecond
+	assert.True(t, n.IsPersistentPeer(persistent))
+	assert.False(t, n.IsPersistentPeer(other))
+
+	n.peerDropped(other)
+	select {
+	case id := <-dials:
+		t.Fatalf("unexpected dial to %s", id)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Two failed attempts are retried until the third succeeds
+	n.peerDropped(persistent)
+	for i := 0; i < 3; i++ {
+		select {
+		case id := <-dials:
+			assert.Equal(t, persistent, id)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for reconnection attempt")
+		}
+	}
+	select {
+	case <-dials:
+		t.Fatal("dialed again after reconnecting")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,249 @@
+package net
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/multiformats/go-multiaddr"
+	proto_net "github.com/palaseus/adrenochain/pkg/proto/net"
+	"google.golang.org/protobuf/encoding/protodelim"
+)
+
+const (
+	// pexProtocol is the stream protocol on which a node asks a peer for
+	// the addresses of peers it knows to be reachable; the addresses are
+	// returned on the same stream.
+	pexProtocol = protocol.ID("/adrenochain/pex/1.0.0")
+	// pexTimeout bounds a peer exchange request.
+	pexTimeout = 10 * time.Second
+	// maxPexMessageSize is the largest getaddr or addr message read.
+	maxPexMessageSize = 64 * 1024
+	// maxPexPeers is the number of peers shared in, and read from, one addr
+	// message.
+	maxPexPeers = 32
+	// maxPexAddrsPerPeer is the number of addresses kept for a peer learned
+	// through peer exchange.
+	maxPexAddrsPerPeer = 8
+
+	// DefaultPeerExchangeInterval is the default minimum time between two
+	// address requests served to the same peer.
+	DefaultPeerExchangeInterval = 10 * time.Minute
+)
+
+// peerExchangeLimiter spaces out the address requests served to each peer,
+// so that a peer cannot harvest the address book by asking repeatedly.
+type peerExchangeLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	served   map[peer.ID]time.Time
+	now      func() time.Time
+}
+
+// newPeerExchangeLimiter creates a limiter serving each peer at most once
+// per interval. Zero uses DefaultPeerExchangeInterval.
+func newPeerExchangeLimiter(interval time.Duration) *peerExchangeLimiter {
+	if interval <= 0 {
+		interval = DefaultPeerExchangeInterval
+	}
+	return &peerExchangeLimiter{
+		interval: interval,
+		served:   make(map[peer.ID]time.Time),
+		now:      time.Now,
+	}
+}
+
+// allow reports whether a request from id may be served, recording it if
+// so. Peers are remembered across reconnections until their interval
+// expires, so reconnecting does not reset the limit.
+func (l *peerExchangeLimiter) allow(id peer.ID) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for p, last := range l.served {
+		if now.Sub(last) >= l.interval {
+			delete(l.served, p)
+		}
+	}
+	if _, limited := l.served[id]; limited {
+		return false
+	}
+	l.served[id] = now
+	return true
+}
+
+// shareablePeers returns up to maxPexPeers known-good peers, chosen at
+// random, to send to the requesting peer: connected peers that passed the
+// handshake and address book peers this node connected to before. The
+// requester itself is left out.
+func (n *Network) shareablePeers(requester peer.ID) []*proto_net.PeerAddress {
+	candidates := make(map[peer.ID][]multiaddr.Multiaddr)
+	for _, p := range n.host.Network().Peers() {
+		if p == requester || n.isIncompatible(p) {
+			continue
+		}
+		if addrs := n.host.Peerstore().Addrs(p); len(addrs) > 0 {
+			candidates[p] = addrs
+		}
+	}
+	if n.addrBook != nil {
+		for _, info := range n.addrBook.GoodPeers(maxAddrBookEntries) {
+			if _, exists := candidates[info.ID]; exists || info.ID == requester || info.ID == n.host.ID() {
+				continue
+			}
+			candidates[info.ID] = info.Addrs
+		}
+	}
+
+	shared := make([]*proto_net.PeerAddress, 0, min(len(candidates), maxPexPeers))
+	for p, addrs := range candidates {
+		entry := &proto_net.PeerAddress{PeerId: []byte(p)}
+		for _, addr := range addrs[:min(len(addrs), maxPexAddrsPerPeer)] {
+			entry.Addrs = append(entry.Addrs, addr.String())
+		}
+		shared = append(shared, entry)
+	}
+	rand.Shuffle(len(shared), func(i, j int) { shared[i], shared[j] = shared[j], shared[i] })
+	return shared[:min(len(shared), maxPexPeers)]
+}
+
+// handlePeerExchange answers a getaddr request with a subset of the peers
+// this node knows to be reachable. Requests from a peer within
+// PeerExchangeInterval of the last one served are refused.
+func (n *Network) handlePeerExchange(s network.Stream) {
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(pexTimeout))
+
+	from := s.Conn().RemotePeer()
+	if n.isIncompatible(from) {
+		s.Reset()
+		return
+	}
+
+	var msg proto_net.Message
+	reader := bufio.NewReader(io.LimitReader(s, maxPexMessageSize))
+	if err := (protodelim.UnmarshalOptions{MaxSize: maxPexMessageSize}).UnmarshalFrom(reader, &msg); err != nil {
+		s.Reset()
+		return
+	}
+	if _, ok := msg.Content.(*proto_net.Message_GetAddrMessage); !ok {
+		s.Reset()
+		return
+	}
+	if !n.pex.allow(from) {
+		s.Reset()
+		return
+	}
+
+	reply := &proto_net.Message{
+		TimestampUnixNano: time.Now().UnixNano(),
+		FromPeerId:        []byte(n.host.ID()),
+		Content: &proto_net.Message_AddrsMessage{
+			AddrsMessage: &proto_net.AddrsMessage{Peers: n.shareablePeers(from)},
+		},
+	}
+	if _, err := protodelim.MarshalTo(s, reply); err != nil {
+		s.Reset()
+	}
+}
+
+// RequestPeerAddrs asks a peer for the addresses of the peers it knows,
+// adds them to the address book and connects to those not yet connected
+// while below MaxPeers. It returns the number of peers learned.
+func (n *Network) RequestPeerAddrs(from peer.ID) (int, error) {
+	ctx, cancel := context.WithTimeout(n.ctx, pexTimeout)
+	defer cancel()
+
+	s, err := n.host.NewStream(ctx, from, pexProtocol)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open peer exchange stream: %w", err)
+	}
+	defer s.Close()
+	s.SetDeadline(time.Now().Add(pexTimeout))
+
+	request := &proto_net.Message{
+		TimestampUnixNano: time.Now().UnixNano(),
+		FromPeerId:        []byte(n.host.ID()),
+		Content:           &proto_net.Message_GetAddrMessage{GetAddrMessage: &proto_net.GetAddrMessage{}},
+	}
+	if _, err := protodelim.MarshalTo(s, request); err != nil {
+		s.Reset()
+		return 0, fmt.Errorf("failed to send getaddr: %w", err)
+	}
+	if err := s.CloseWrite(); err != nil {
+		s.Reset()
+		return 0, fmt.Errorf("failed to send getaddr: %w", err)
+	}
+
+	var msg proto_net.Message
+	reader := bufio.NewReader(io.LimitReader(s, maxPexMessageSize))
+	if err := (protodelim.UnmarshalOptions{MaxSize: maxPexMessageSize}).UnmarshalFrom(reader, &msg); err != nil {
+		s.Reset()
+		return 0, fmt.Errorf("failed to read addr reply: %w", err)
+	}
+	content, ok := msg.Content.(*proto_net.Message_AddrsMessage)
+	if !ok {
+		s.Reset()
+		return 0, fmt.Errorf("%w: unexpected %T in getaddr reply", ErrMalformed, msg.Content)
+	}
+
+	learned := n.learnPeers(from, content.AddrsMessage.Peers)
+	for _, info := range learned {
+		if len(n.host.Network().Peers()) >= n.config.MaxPeers {
+			break
+		}
+		if n.host.Network().Connectedness(info.ID) == network.Connected {
+			continue
+		}
+		dialCtx, cancel := context.WithTimeout(n.ctx, pexTimeout)
+		err := n.host.Connect(dialCtx, info)
+		cancel()
+		if err != nil {
+			fmt.Printf("Failed to connect to peer %s learned from %s: %v\n", info.ID, from, err)
+			if n.addrBook != nil {
+				n.addrBook.RecordFailure(info.ID)
+			}
+		}
+	}
+	return len(learned), nil
+}
+
+// learnPeers records the valid entries of an addr message sent by a peer,
+// skipping this node, the sender and peers that failed the handshake, and
+// returns them. Entries beyond maxPexPeers are ignored.
+func (n *Network) learnPeers(from peer.ID, entries []*proto_net.PeerAddress) []peer.AddrInfo {
+	var learned []peer.AddrInfo
+	for _, entry := range entries[:min(len(entries), maxPexPeers)] {
+		id, err := peer.IDFromBytes(entry.PeerId)
+		if err != nil || id == n.host.ID() || id == from || n.isIncompatible(id) {
+			continue
+		}
+		info := peer.AddrInfo{ID: id}
+		for _, addr := range entry.Addrs[:min(len(entry.Addrs), maxPexAddrsPerPeer)] {
+			ma, err := multiaddr.NewMultiaddr(addr)
+			if err != nil {
+				continue
+			}
+			info.Addrs = append(info.Addrs, ma)
+		}
+		if len(info.Addrs) == 0 {
+			continue
+		}
+
+		n.host.Peerstore().AddAddrs(id, info.Addrs, peerstore.AddressTTL)
+		if n.addrBook != nil {
+			n.addrBook.AddAddrs(id, info.Addrs...)
+		}
+		learned = append(learned, info)
+	}
+	return learned
+}
@@ -0,0 +1,103 @@
+package net
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/palaseus/adrenochain/pkg/chain"
+	"github.com/palaseus/adrenochain/pkg/mempool"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeerExchangeLimiter(t *testing.T) {
+	now := time.Unix(1_000_000, 0)
+	l := newPeerExchangeLimiter(time.Minute)
+	l.now = func() time.Time { return now }
+	a, b := newTestPeerID(t), newTestPeerID(t)
+
+	assert.True(t, l.allow(a))
+	assert.False(t, l.allow(a), "a second request within the interval is refused")
+	assert.True(t, l.allow(b), "peers are limited independently")
+
+	now = now.Add(time.Minute)
+	assert.True(t, l.allow(a))
+	assert.Len(t, l.served, 1, "expired peers are forgotten")
+
+	assert.Equal(t, DefaultPeerExchangeInterval, newPeerExchangeLimiter(0).interval)
+}
+
+func TestAddrBookLearnedPeers(t *testing.T) {
+	store, err := storage.NewStorage(storage.DefaultStorageConfig().WithDataDir(t.TempDir()))
+	require.NoError(t, err)
+	defer store.Close()
+	ab := NewAddrBook(store)
+
+	addr, err := multiaddr.NewMultiaddr("/ip4/10.0.0.1/tcp/4001")
+	require.NoError(t, err)
+	good, learned := newTestPeerID(t), newTestPeerID(t)
+	ab.RecordSuccess(good, addr)
+	assert.True(t, ab.AddAddrs(learned, addr))
+	assert.Zero(t, ab.Get(learned).Score, "learned addresses do not change the score")
+
+	assert.Len(t, ab.BestPeers(10), 2)
+	goodPeers := ab.GoodPeers(10)
+	require.Len(t, goodPeers, 1, "only peers connected to before are known good")
+	assert.Equal(t, good, goodPeers[0].ID)
+
+	// A full book does not evict known peers for learned ones
+	for len(ab.entries) < maxAddrBookEntries {
+		ab.RecordFailure(newTestPeerID(t))
+	}
+	assert.False(t, ab.AddAddrs(newTestPeerID(t), addr))
+	assert.Equal(t, maxAddrBookEntries, ab.Len())
+	assert.NotNil(t, ab.Get(learned))
+}
+
+// TestPeerExchange connects B to C, then A to B: A must learn C's address
+// from B, add it to its address book and connect to C.
+func TestPeerExchange(t *testing.T) {
+	store, err := storage.NewStorage(storage.DefaultStorageConfig().WithDataDir(t.TempDir()))
+	require.NoError(t, err)
+	defer store.Close()
+
+	nodes := make([]*Network, 3)
+	for i := range nodes {
+		config := DefaultNetworkConfig()
+		config.ListenPort = 0
+		config.EnableMDNS = false
+		config.EnableRelay = false
+		config.PeerExchange = true
+		if i == 0 {
+			config.AddrBookStore = store
+		}
+
+		node, err := NewNetwork(config, &chain.Chain{}, mempool.NewMempool(mempool.TestMempoolConfig()))
+		require.NoError(t, err)
+		defer node.Close()
+		nodes[i] = node
+	}
+	a, b, c := nodes[0], nodes[1], nodes[2]
+	addrInfo := func(n *Network) peer.AddrInfo {
+		return peer.AddrInfo{ID: n.GetHost().ID(), Addrs: n.GetHost().Addrs()}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	require.NoError(t, b.GetHost().Connect(ctx, addrInfo(c)))
+	require.NoError(t, a.GetHost().Connect(ctx, addrInfo(b)))
+
+	require.Eventually(t, func() bool {
+		return a.GetHost().Network().Connectedness(c.GetHost().ID()) == network.Connected
+	}, 15*time.Second, 50*time.Millisecond, "A connects to C learned from B")
+	assert.NotNil(t, a.GetAddrBook().Get(c.GetHost().ID()))
+
+	// B already served A, so a second request is refused
+	_, err = a.RequestPeerAddrs(b.GetHost().ID())
+	assert.Error(t, err)
+}
@@ -0,0 +1,90 @@
+package net
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+// DefaultBlockQueueSize is the default number of received block messages
+// queued for processing before further ones are dropped.
+const DefaultBlockQueueSize = 64
+
+// MessageProcessor hands gossip messages from a subscription to a handler
+// running on its own goroutine through a bounded queue. When the handler
+// falls behind and the queue is full, new messages are dropped instead of
+// piling up, so a burst of announcements cannot grow memory without bound.
+// Dropped blocks are fetched again by sync once the node catches up.
+type MessageProcessor struct {
+	queue   chan *pubsub.Message
+	handle  func(*pubsub.Message)
+	onDrop  func()
+	dropped atomic.Uint64
+	wg      sync.WaitGroup
+}
+
+// NewMessageProcessor creates a processor queueing up to queueSize messages
+// for handle. A queue size below one is raised to one.
+func NewMessageProcessor(queueSize int, handle func(*pubsub.Message)) *MessageProcessor {
+	if queueSize < 1 {
+		queueSize = 1
+	}
+	return &MessageProcessor{
+		queue:  make(chan *pubsub.Message, queueSize),
+		handle: handle,
+	}
+}
+
+// SetOnDrop sets a callback run for every dropped message, e.g. to count
+// drops in metrics. It must be set before the processor is used.
+func (p *MessageProcessor) SetOnDrop(onDrop func()) {
+	p.onDrop = onDrop
+}
+
+// Start runs the handler on queued messages until ctx is done.
+func (p *MessageProcessor) Start(ctx context.Context) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-p.queue:
+				p.handle(msg)
+			}
+		}
+	}()
+}
+
+// Wait blocks until the handler goroutine has stopped.
+func (p *MessageProcessor) Wait() {
+	p.wg.Wait()
+}
+
+// Submit queues a message for the handler without blocking. It reports
+// false, and records a drop, when the queue is full.
+func (p *MessageProcessor) Submit(msg *pubsub.Message) bool {
+	select {
+	case p.queue <- msg:
+		return true
+	default:
+		p.dropped.Add(1)
+		if p.onDrop != nil {
+			p.onDrop()
+		}
+		return false
+	}
+}
+
+// Pending returns the number of queued messages awaiting the handler.
+func (p *MessageProcessor) Pending() int {
+	return len(p.queue)
+}
+
+// Dropped returns the number of messages dropped because the queue was full.
+func (p *MessageProcessor) Dropped() uint64 {
+	return p.dropped.Load()
+}
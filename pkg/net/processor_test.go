@@ -0,0 +1,54 @@
+package net
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageProcessorBackpressure(t *testing.T) {
+	const queueSize = 8
+	const flood = 10000
+
+	release := make(chan struct{})
+	var handled atomic.Int64
+	processor := NewMessageProcessor(queueSize, func(*pubsub.Message) {
+		<-release
+		handled.Add(1)
+	})
+	var drops atomic.Int64
+	processor.SetOnDrop(func() { drops.Add(1) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	processor.Start(ctx)
+
+	// Flood the processor while its handler is stuck
+	accepted := 0
+	for i := 0; i < flood; i++ {
+		if processor.Submit(&pubsub.Message{}) {
+			accepted++
+		}
+		require.LessOrEqual(t, processor.Pending(), queueSize)
+	}
+
+	// Only the queue and the message being handled were taken
+	assert.LessOrEqual(t, accepted, queueSize+1)
+	assert.Equal(t, uint64(flood-accepted), processor.Dropped())
+	assert.Equal(t, int64(flood-accepted), drops.Load())
+
+	// Accepted messages are still handled once the handler catches up
+	close(release)
+	require.Eventually(t, func() bool {
+		return handled.Load() == int64(accepted)
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 0, processor.Pending())
+
+	cancel()
+	processor.Wait()
+}
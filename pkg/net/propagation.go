@@ -0,0 +1,185 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+const (
+	// blockAckProtocol is the stream protocol peers use to tell the miner of
+	// a block that they accepted it.
+	blockAckProtocol = protocol.ID("/adrenochain/block-ack/1.0.0")
+	// blockAckTimeout bounds opening and writing an acknowledgement stream.
+	blockAckTimeout = 5 * time.Second
+	// maxBlockAckSize is the largest acknowledgement read, a block hash.
+	maxBlockAckSize = 64
+
+	// maxPropagationSamples is the number of most recent latency samples the
+	// percentiles are computed from.
+	maxPropagationSamples = 1000
+	// maxTrackedBlocks is the number of most recently mined blocks for which
+	// acknowledgements are still accepted.
+	maxTrackedBlocks = 100
+)
+
+// propagationStats summarizes the time between mining a block and peers
+// acknowledging that they accepted it.
+type propagationStats struct {
+	samples       int
+	p50, p90, p99 time.Duration
+}
+
+// propagationTracker records when this node mined blocks and how long each
+// peer took to acknowledge them.
+type propagationTracker struct {
+	mu      sync.Mutex
+	mined   map[string]*minedBlock // mined is keyed by block hash.
+	order   []string               // order lists tracked hashes, oldest first.
+	samples []time.Duration        // samples is a ring of the latest latencies.
+	next    int
+	now     func() time.Time
+}
+
+// minedBlock is a locally mined block awaiting acknowledgements.
+type minedBlock struct {
+	at    time.Time
+	acked map[peer.ID]struct{}
+}
+
+// newPropagationTracker creates an empty propagation tracker.
+func newPropagationTracker() *propagationTracker {
+	return &propagationTracker{
+		mined: make(map[string]*minedBlock),
+		now:   time.Now,
+	}
+}
+
+// markMined records that the block with the given hash was mined now,
+// forgetting the oldest tracked block once maxTrackedBlocks is reached.
+func (t *propagationTracker) markMined(hash []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := string(hash)
+	if _, exists := t.mined[key]; exists {
+		return
+	}
+	if len(t.order) >= maxTrackedBlocks {
+		delete(t.mined, t.order[0])
+		t.order = t.order[1:]
+	}
+	t.mined[key] = &minedBlock{at: t.now(), acked: make(map[peer.ID]struct{})}
+	t.order = append(t.order, key)
+}
+
+// recordAck records an acknowledgement of a block from a peer and returns the
+// latency since the block was mined. Acknowledgements of unknown blocks and
+// repeated acknowledgements from the same peer are not recorded.
+func (t *propagationTracker) recordAck(hash []byte, from peer.ID) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b, exists := t.mined[string(hash)]
+	if !exists {
+		return 0, false
+	}
+	if _, acked := b.acked[from]; acked {
+		return 0, false
+	}
+	b.acked[from] = struct{}{}
+
+	latency := t.now().Sub(b.at)
+	if len(t.samples) < maxPropagationSamples {
+		t.samples = append(t.samples, latency)
+	} else {
+		t.samples[t.next] = latency
+		t.next = (t.next + 1) % maxPropagationSamples
+	}
+	return latency, true
+}
+
+// stats returns the latency percentiles over the recorded samples.
+func (t *propagationTracker) stats() propagationStats {
+	t.mu.Lock()
+	sorted := append([]time.Duration(nil), t.samples...)
+	t.mu.Unlock()
+
+	if len(sorted) == 0 {
+		return propagationStats{}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return propagationStats{
+		samples: len(sorted),
+		p50:     percentile(sorted, 50),
+		p90:     percentile(sorted, 90),
+		p99:     percentile(sorted, 99),
+	}
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted samples.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// MarkBlockMined records that this node mined the block with the given hash,
+// so that acknowledgements from peers accepting it yield propagation
+// latencies. It should be called before the block is published.
+func (n *Network) MarkBlockMined(hash []byte) {
+	n.propagation.markMined(hash)
+}
+
+// AckBlock tells the peer that mined a block that this node accepted it.
+func (n *Network) AckBlock(miner peer.ID, hash []byte) error {
+	if miner == n.host.ID() {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(n.ctx, blockAckTimeout)
+	defer cancel()
+
+	s, err := n.host.NewStream(ctx, miner, blockAckProtocol)
+	if err != nil {
+		return fmt.Errorf("failed to open block ack stream to %s: %w", miner, err)
+	}
+	defer s.Close()
+
+	s.SetWriteDeadline(time.Now().Add(blockAckTimeout))
+	if _, err := s.Write(hash); err != nil {
+		s.Reset()
+		return fmt.Errorf("failed to send block ack to %s: %w", miner, err)
+	}
+	return nil
+}
+
+// BlockPropagationPercentiles returns the 50th, 90th and 99th percentile
+// latencies between mining a block and a peer acknowledging it, over the most
+// recent acknowledgements of blocks mined by this node.
+func (n *Network) BlockPropagationPercentiles() (p50, p90, p99 time.Duration, samples int) {
+	stats := n.propagation.stats()
+	return stats.p50, stats.p90, stats.p99, stats.samples
+}
+
+// handleBlockAck records a block acknowledgement sent by a peer.
+func (n *Network) handleBlockAck(s network.Stream) {
+	defer s.Close()
+
+	s.SetReadDeadline(time.Now().Add(blockAckTimeout))
+	hash, err := io.ReadAll(io.LimitReader(s, maxBlockAckSize))
+	if err != nil || len(hash) == 0 {
+		s.Reset()
+		return
+	}
+	n.propagation.recordAck(hash, s.Conn().RemotePeer())
+}
@@ -0,0 +1,127 @@
+package net
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/palaseus/adrenochain/pkg/chain"
+	"github.com/palaseus/adrenochain/pkg/mempool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPropagationTracker(t *testing.T) {
+	tracker := newPropagationTracker()
+	now := time.Unix(1700000000, 0)
+	tracker.now = func() time.Time { return now }
+
+	peerA := newTestPeerID(t)
+	peerB := newTestPeerID(t)
+	hash := []byte("block-1")
+
+	// Acknowledgements of blocks not mined locally are ignored
+	_, recorded := tracker.recordAck(hash, peerA)
+	assert.False(t, recorded)
+
+	tracker.markMined(hash)
+	now = now.Add(100 * time.Millisecond)
+	latency, recorded := tracker.recordAck(hash, peerA)
+	require.True(t, recorded)
+	assert.Equal(t, 100*time.Millisecond, latency)
+
+	// A peer is counted once per block
+	now = now.Add(100 * time.Millisecond)
+	_, recorded = tracker.recordAck(hash, peerA)
+	assert.False(t, recorded)
+	_, recorded = tracker.recordAck(hash, peerB)
+	assert.True(t, recorded)
+
+	for i := 0; i < 8; i++ {
+		now = now.Add(100 * time.Millisecond)
+		_, recorded = tracker.recordAck(hash, newTestPeerID(t))
+		require.True(t, recorded)
+	}
+
+	stats := tracker.stats()
+	assert.Equal(t, 10, stats.samples)
+	assert.Equal(t, 500*time.Millisecond, stats.p50)
+	assert.Equal(t, 900*time.Millisecond, stats.p90)
+	assert.Equal(t, time.Second, stats.p99)
+
+	// Only the most recently mined blocks are tracked
+	for i := 0; i < maxTrackedBlocks; i++ {
+		tracker.markMined([]byte{byte(i), byte(i >> 8), 0xff})
+	}
+	_, recorded = tracker.recordAck(hash, newTestPeerID(t))
+	assert.False(t, recorded)
+}
+
+// TestBlockPropagationAcrossNodes runs three nodes where one mines a block,
+// the others receive it over gossip and acknowledge it, and checks the miner
+// measures the propagation latency of both acknowledgements.
+func TestBlockPropagationAcrossNodes(t *testing.T) {
+	nodes := make([]*Network, 3)
+	for i := range nodes {
+		config := DefaultNetworkConfig()
+		config.ListenPort = 0
+		config.EnableMDNS = false
+		config.EnableRelay = false
+
+		node, err := NewNetwork(config, &chain.Chain{}, mempool.NewMempool(mempool.TestMempoolConfig()))
+		require.NoError(t, err)
+		defer node.Close()
+		nodes[i] = node
+	}
+	miner, receivers := nodes[0], nodes[1:]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	minerInfo := peer.AddrInfo{ID: miner.GetHost().ID(), Addrs: miner.GetHost().Addrs()}
+	acked := make(chan error, len(receivers))
+	for _, receiver := range receivers {
+		require.NoError(t, receiver.GetHost().Connect(ctx, minerInfo))
+		sub, err := receiver.SubscribeToBlocks()
+		require.NoError(t, err)
+		defer sub.Cancel()
+
+		go func(receiver *Network) {
+			msg, err := sub.Next(ctx)
+			if err != nil {
+				acked <- err
+				return
+			}
+			acked <- receiver.AckBlock(msg.ReceivedFrom, []byte("mined-block-hash"))
+		}(receiver)
+	}
+
+	// Wait until the miner knows both receivers subscribe to blocks
+	require.Eventually(t, func() bool {
+		return len(miner.pubsub.ListPeers("blocks")) == len(receivers)
+	}, 10*time.Second, 50*time.Millisecond)
+
+	miner.MarkBlockMined([]byte("mined-block-hash"))
+	require.NoError(t, miner.PublishBlock([]byte("mined block")))
+
+	for range receivers {
+		select {
+		case err := <-acked:
+			require.NoError(t, err)
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for block acknowledgements")
+		}
+	}
+
+	require.Eventually(t, func() bool {
+		_, _, _, samples := miner.BlockPropagationPercentiles()
+		return samples == len(receivers)
+	}, 5*time.Second, 20*time.Millisecond)
+
+	p50, p90, p99, _ := miner.BlockPropagationPercentiles()
+	assert.Greater(t, p50, time.Duration(0))
+	assert.LessOrEqual(t, p50, p90)
+	assert.LessOrEqual(t, p90, p99)
+	assert.Less(t, p99, 15*time.Second)
+}
@@ -0,0 +1,156 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/palaseus/adrenochain/pkg/chain"
+	"github.com/palaseus/adrenochain/pkg/mempool"
+	proto_net "github.com/palaseus/adrenochain/pkg/proto/net"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// rejectProtocol is the stream protocol on which a node tells the sender
+	// of a block or transaction why it rejected it.
+	rejectProtocol = protocol.ID("/adrenochain/reject/1.0.0")
+	// rejectTimeout bounds opening and writing a reject stream.
+	rejectTimeout = 5 * time.Second
+	// maxRejectSize is the largest reject message read.
+	maxRejectSize = 1024
+	// maxRejectReasonLen is the longest reason sent in a reject message.
+	maxRejectReasonLen = 256
+
+	// RejectTypeBlock and RejectTypeTx name the kind of object rejected.
+	RejectTypeBlock = "block"
+	RejectTypeTx    = "tx"
+)
+
+// ErrMalformed marks a received block or transaction that could not be
+// decoded; it is reported to the sender as REJECT_MALFORMED.
+var ErrMalformed = errors.New("malformed message")
+
+// Reject is a rejection reported by a peer for a block or transaction this
+// node sent it.
+type Reject struct {
+	From        peer.ID
+	MessageType string
+	Code        proto_net.RejectCode
+	Hash        []byte
+	Reason      string
+}
+
+// RejectCodeFor classifies the error returned when decoding or accepting a
+// block or transaction.
+func RejectCodeFor(err error) proto_net.RejectCode {
+	switch {
+	case errors.Is(err, ErrMalformed):
+		return proto_net.RejectCode_REJECT_MALFORMED
+	case errors.Is(err, mempool.ErrAlreadyInMempool):
+		return proto_net.RejectCode_REJECT_DUPLICATE
+	case errors.Is(err, mempool.ErrInsufficientFee):
+		return proto_net.RejectCode_REJECT_INSUFFICIENT_FEE
+	case errors.Is(err, mempool.ErrDoubleSpend):
+		return proto_net.RejectCode_REJECT_DOUBLE_SPEND
+	default:
+		return proto_net.RejectCode_REJECT_INVALID
+	}
+}
+
+// SetRejectHandler sets the function called with every reject message
+// received from a peer. By default rejects are only logged.
+func (n *Network) SetRejectHandler(handler func(Reject)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.onReject = handler
+}
+
+// SendReject tells the peer that sent a block or transaction that this node
+// rejected it, with a reason code derived from err. Failures of this node,
+// such as the chain being in safe mode, are not the sender's fault and are
+// not reported.
+func (n *Network) SendReject(to peer.ID, messageType string, hash []byte, err error) error {
+	if to == n.host.ID() || errors.Is(err, chain.ErrSafeMode) {
+		return nil
+	}
+
+	reason := err.Error()
+	if len(reason) > maxRejectReasonLen {
+		reason = reason[:maxRejectReasonLen]
+	}
+	data, err := proto.Marshal(&proto_net.Message{
+		TimestampUnixNano: time.Now().UnixNano(),
+		FromPeerId:        []byte(n.host.ID()),
+		Content: &proto_net.Message_RejectMessage{
+			RejectMessage: &proto_net.RejectMessage{
+				MessageType: messageType,
+				Code:        RejectCodeFor(err),
+				Hash:        hash,
+				Reason:      reason,
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal reject message: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(n.ctx, rejectTimeout)
+	defer cancel()
+
+	s, err := n.host.NewStream(ctx, to, rejectProtocol)
+	if err != nil {
+		return fmt.Errorf("failed to open reject stream to %s: %w", to, err)
+	}
+	defer s.Close()
+
+	s.SetWriteDeadline(time.Now().Add(rejectTimeout))
+	if _, err := s.Write(data); err != nil {
+		s.Reset()
+		return fmt.Errorf("failed to send reject to %s: %w", to, err)
+	}
+	return nil
+}
+
+// handleReject passes a reject message sent by a peer to the reject handler.
+func (n *Network) handleReject(s network.Stream) {
+	defer s.Close()
+
+	s.SetReadDeadline(time.Now().Add(rejectTimeout))
+	data, err := io.ReadAll(io.LimitReader(s, maxRejectSize))
+	if err != nil {
+		s.Reset()
+		return
+	}
+
+	var msg proto_net.Message
+	if err := proto.Unmarshal(data, &msg); err != nil {
+		return
+	}
+	content, ok := msg.Content.(*proto_net.Message_RejectMessage)
+	if !ok {
+		return
+	}
+
+	reject := Reject{
+		From:        s.Conn().RemotePeer(),
+		MessageType: content.RejectMessage.MessageType,
+		Code:        content.RejectMessage.Code,
+		Hash:        content.RejectMessage.Hash,
+		Reason:      content.RejectMessage.Reason,
+	}
+
+	n.mu.RLock()
+	handler := n.onReject
+	n.mu.RUnlock()
+	if handler == nil {
+		fmt.Printf("Peer %s rejected %s %x: %s (%s)\n", reject.From, reject.MessageType, reject.Hash, reject.Code, reject.Reason)
+		return
+	}
+	handler(reject)
+}
@@ -0,0 +1,111 @@
+package net
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/chain"
+	"github.com/palaseus/adrenochain/pkg/mempool"
+	proto_net "github.com/palaseus/adrenochain/pkg/proto/net"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRejectCodeFor(t *testing.T) {
+	assert.Equal(t, proto_net.RejectCode_REJECT_MALFORMED, RejectCodeFor(fmt.Errorf("%w: bad json", ErrMalformed)))
+	assert.Equal(t, proto_net.RejectCode_REJECT_DUPLICATE, RejectCodeFor(mempool.ErrAlreadyInMempool))
+	assert.Equal(t, proto_net.RejectCode_REJECT_INSUFFICIENT_FEE,
+		RejectCodeFor(fmt.Errorf("transaction validation failed: %w", mempool.ErrInsufficientFee)))
+	assert.Equal(t, proto_net.RejectCode_REJECT_DOUBLE_SPEND, RejectCodeFor(mempool.ErrDoubleSpend))
+	assert.Equal(t, proto_net.RejectCode_REJECT_INVALID, RejectCodeFor(errors.New("invalid proof of work")))
+}
+
+// TestRejectSentToOrigin publishes a transaction paying no fee from one node
+// and checks the node rejecting it reports the reason back to the sender.
+func TestRejectSentToOrigin(t *testing.T) {
+	nodes := make([]*Network, 2)
+	for i := range nodes {
+		config := DefaultNetworkConfig()
+		config.ListenPort = 0
+		config.EnableMDNS = false
+		config.EnableRelay = false
+
+		node, err := NewNetwork(config, &chain.Chain{}, mempool.NewMempool(mempool.TestMempoolConfig()))
+		require.NoError(t, err)
+		defer node.Close()
+		nodes[i] = node
+	}
+	sender, receiver := nodes[0], nodes[1]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	rejects := make(chan Reject, 1)
+	sender.SetRejectHandler(func(r Reject) { rejects <- r })
+
+	require.NoError(t, receiver.GetHost().Connect(ctx, peer.AddrInfo{ID: sender.GetHost().ID(), Addrs: sender.GetHost().Addrs()}))
+	sub, err := receiver.SubscribeToTransactions()
+	require.NoError(t, err)
+	defer sub.Cancel()
+
+	// The receiver handles transactions as the node does: decode, try to
+	// accept, and report a rejection to the peer that published it
+	go func() {
+		msg, err := sub.Next(ctx)
+		if err != nil {
+			return
+		}
+		payload, err := receiver.OpenMessage(msg.Data)
+		if err != nil {
+			return
+		}
+		var networkMsg proto_net.Message
+		if err := proto.Unmarshal(payload, &networkMsg); err != nil {
+			return
+		}
+		var tx block.Transaction
+		if err := json.Unmarshal(networkMsg.GetTransactionMessage().GetTransactionData(), &tx); err != nil {
+			return
+		}
+		if err := receiver.AcceptTransaction(msg.ReceivedFrom, &tx); err != nil {
+			receiver.SendReject(peer.ID(networkMsg.FromPeerId), RejectTypeTx, tx.Hash, err)
+		}
+	}()
+
+	require.Eventually(t, func() bool {
+		return len(sender.pubsub.ListPeers("transactions")) == 1
+	}, 10*time.Second, 50*time.Millisecond)
+
+	tx := &block.Transaction{
+		Version: 1,
+		Inputs: []*block.TxInput{{
+			PrevTxHash: make([]byte, 32),
+			ScriptSig:  []byte("signature"),
+			Sequence:   0xffffffff,
+		}},
+		Outputs: []*block.TxOutput{{Value: 1000, ScriptPubKey: []byte("pubkey")}},
+		Fee:     0,
+	}
+	tx.Hash = tx.CalculateHash()
+	data, err := json.Marshal(tx)
+	require.NoError(t, err)
+	require.NoError(t, sender.PublishTransaction(data))
+
+	select {
+	case r := <-rejects:
+		assert.Equal(t, receiver.GetHost().ID(), r.From)
+		assert.Equal(t, RejectTypeTx, r.MessageType)
+		assert.Equal(t, proto_net.RejectCode_REJECT_INSUFFICIENT_FEE, r.Code)
+		assert.Equal(t, tx.Hash, r.Hash)
+		assert.Contains(t, r.Reason, "fee rate 0 below minimum 1")
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for reject message")
+	}
+}
@@ -0,0 +1,124 @@
+package net
+
+import (
+	"fmt"
+	"sort"
+
+	libp2p "github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/control"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/core/sec/insecure"
+	"github.com/libp2p/go-libp2p/p2p/security/noise"
+	libp2ptls "github.com/libp2p/go-libp2p/p2p/security/tls"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// PeerSecurityInfo describes the security properties of a single connection
+type PeerSecurityInfo struct {
+	PeerID           string `json:"peer_id"`
+	RemoteAddr       string `json:"remote_addr"`
+	Direction        string `json:"direction"`
+	Security         string `json:"security"`
+	Muxer            string `json:"muxer"`
+	Transport        string `json:"transport"`
+	Encrypted        bool   `json:"encrypted"`
+	IdentityVerified bool   `json:"identity_verified"`
+}
+
+// securityOptions returns the libp2p security transports to offer. When a
+// protocol is required only that transport is offered; otherwise every
+// supported encrypted transport is. Plaintext is never offered.
+func securityOptions(required string) ([]libp2p.Option, error) {
+	switch required {
+	case "":
+		return []libp2p.Option{
+			libp2p.Security(noise.ID, noise.New),
+			libp2p.Security(libp2ptls.ID, libp2ptls.New),
+		}, nil
+	case noise.ID:
+		return []libp2p.Option{libp2p.Security(noise.ID, noise.New)}, nil
+	case libp2ptls.ID:
+		return []libp2p.Option{libp2p.Security(libp2ptls.ID, libp2ptls.New)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported security protocol: %s", required)
+	}
+}
+
+// securityGater rejects upgraded connections that are not encrypted with an
+// acceptable protocol or whose remote peer ID does not match the key the
+// peer authenticated with.
+type securityGater struct {
+	required protocol.ID
+}
+
+func (g *securityGater) InterceptPeerDial(peer.ID) bool { return true }
+
+func (g *securityGater) InterceptAddrDial(peer.ID, multiaddr.Multiaddr) bool { return true }
+
+func (g *securityGater) InterceptAccept(network.ConnMultiaddrs) bool { return true }
+
+func (g *securityGater) InterceptSecured(network.Direction, peer.ID, network.ConnMultiaddrs) bool {
+	return true
+}
+
+func (g *securityGater) InterceptUpgraded(conn network.Conn) (bool, control.DisconnectReason) {
+	security := conn.ConnState().Security
+	if !isEncrypted(security) {
+		return false, 0
+	}
+	if g.required != "" && security != g.required {
+		return false, 0
+	}
+	if !identityVerified(conn) {
+		return false, 0
+	}
+	return true, 0
+}
+
+// isEncrypted reports whether a negotiated security protocol encrypts traffic
+func isEncrypted(security protocol.ID) bool {
+	return security != "" && security != insecure.ID
+}
+
+// identityVerified checks that the remote peer ID is derived from the public
+// key presented during the security handshake
+func identityVerified(conn network.Conn) bool {
+	pubKey := conn.RemotePublicKey()
+	if pubKey == nil {
+		return false
+	}
+	id, err := peer.IDFromPublicKey(pubKey)
+	if err != nil {
+		return false
+	}
+	return id == conn.RemotePeer()
+}
+
+// GetSecurityInfo reports the negotiated security protocol and identity
+// verification status of every open connection, sorted by peer ID
+func (n *Network) GetSecurityInfo() []PeerSecurityInfo {
+	conns := n.host.Network().Conns()
+	infos := make([]PeerSecurityInfo, 0, len(conns))
+
+	for _, conn := range conns {
+		state := conn.ConnState()
+		infos = append(infos, PeerSecurityInfo{
+			PeerID:           conn.RemotePeer().String(),
+			RemoteAddr:       conn.RemoteMultiaddr().String(),
+			Direction:        conn.Stat().Direction.String(),
+			Security:         string(state.Security),
+			Muxer:            string(state.StreamMultiplexer),
+			Transport:        state.Transport,
+			Encrypted:        isEncrypted(state.Security),
+			IdentityVerified: identityVerified(conn),
+		})
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].PeerID < infos[j].PeerID
+	})
+
+	return infos
+}
@@ -0,0 +1,82 @@
+package net
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	libp2p "github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/sec/insecure"
+	"github.com/libp2p/go-libp2p/p2p/security/noise"
+	"github.com/palaseus/adrenochain/pkg/chain"
+	"github.com/palaseus/adrenochain/pkg/mempool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSecurityTestNetwork(t *testing.T, required string) *Network {
+	config := DefaultNetworkConfig()
+	config.EnableMDNS = false
+	config.RequiredSecurity = required
+
+	network, err := NewNetwork(config, &chain.Chain{}, mempool.NewMempool(mempool.TestMempoolConfig()))
+	require.NoError(t, err)
+	t.Cleanup(func() { network.Close() })
+	return network
+}
+
+func newSecurityTestHost(t *testing.T, opts ...libp2p.Option) host.Host {
+	opts = append(opts, libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	h, err := libp2p.New(opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func TestPlaintextConnectionRefused(t *testing.T) {
+	network := newSecurityTestNetwork(t, noise.ID)
+
+	plaintext := newSecurityTestHost(t, libp2p.Security(insecure.ID, insecure.NewWithIdentity))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	target := peer.AddrInfo{ID: network.GetHost().ID(), Addrs: network.GetHost().Addrs()}
+	err := plaintext.Connect(ctx, target)
+	assert.Error(t, err)
+	assert.Empty(t, network.GetHost().Network().ConnsToPeer(plaintext.ID()))
+	assert.Empty(t, network.GetSecurityInfo())
+}
+
+func TestGetSecurityInfo(t *testing.T) {
+	network := newSecurityTestNetwork(t, noise.ID)
+
+	remote := newSecurityTestHost(t, libp2p.Security(noise.ID, noise.New))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	target := peer.AddrInfo{ID: network.GetHost().ID(), Addrs: network.GetHost().Addrs()}
+	require.NoError(t, remote.Connect(ctx, target))
+
+	require.Eventually(t, func() bool {
+		return len(network.GetSecurityInfo()) > 0
+	}, 5*time.Second, 50*time.Millisecond)
+
+	info := network.GetSecurityInfo()[0]
+	assert.Equal(t, remote.ID().String(), info.PeerID)
+	assert.Equal(t, noise.ID, info.Security)
+	assert.True(t, info.Encrypted)
+	assert.True(t, info.IdentityVerified)
+}
+
+func TestUnsupportedRequiredSecurity(t *testing.T) {
+	config := DefaultNetworkConfig()
+	config.EnableMDNS = false
+	config.RequiredSecurity = insecure.ID
+
+	_, err := NewNetwork(config, &chain.Chain{}, mempool.NewMempool(mempool.TestMempoolConfig()))
+	assert.Error(t, err)
+}
@@ -0,0 +1,83 @@
+package net
+
+import (
+	"fmt"
+	stdnet "net"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	manet "github.com/multiformats/go-multiaddr/net"
+	"github.com/palaseus/adrenochain/pkg/block"
+)
+
+// relayWhitelist holds the trusted peers, by ID or by the subnet they
+// connect from, whose transactions bypass relay policy and rate limits.
+type relayWhitelist struct {
+	peers   map[peer.ID]struct{}
+	subnets []*stdnet.IPNet
+}
+
+// parseWhitelist parses whitelist entries, each a peer ID or a CIDR subnet.
+func parseWhitelist(entries []string) (*relayWhitelist, error) {
+	wl := &relayWhitelist{peers: make(map[peer.ID]struct{})}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if strings.Contains(entry, "/") {
+			_, subnet, err := stdnet.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid whitelist subnet %q: %w", entry, err)
+			}
+			wl.subnets = append(wl.subnets, subnet)
+			continue
+		}
+		id, err := peer.Decode(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid whitelist peer ID %q: %w", entry, err)
+		}
+		wl.peers[id] = struct{}{}
+	}
+	return wl, nil
+}
+
+// containsIP reports whether ip is in a whitelisted subnet.
+func (wl *relayWhitelist) containsIP(ip stdnet.IP) bool {
+	for _, subnet := range wl.subnets {
+		if subnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsWhitelisted reports whether a peer is trusted, either by its ID or
+// because a connection to it comes from a whitelisted subnet.
+func (n *Network) IsWhitelisted(p peer.ID) bool {
+	if n.whitelist == nil {
+		return false
+	}
+	if _, trusted := n.whitelist.peers[p]; trusted {
+		return true
+	}
+	if len(n.whitelist.subnets) == 0 || n.host == nil {
+		return false
+	}
+	for _, conn := range n.host.Network().ConnsToPeer(p) {
+		if ip, err := manet.ToIP(conn.RemoteMultiaddr()); err == nil && n.whitelist.containsIP(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// AcceptTransaction adds a transaction relayed by a peer to the mempool.
+// Transactions from whitelisted peers skip relay policy checks but are still
+// fully validated against the UTXO set.
+func (n *Network) AcceptTransaction(from peer.ID, tx *block.Transaction) error {
+	if n.mempool == nil {
+		return fmt.Errorf("mempool not available")
+	}
+	if n.IsWhitelisted(from) {
+		return n.mempool.AddWhitelistedTransaction(tx)
+	}
+	return n.mempool.AddTransaction(tx)
+}
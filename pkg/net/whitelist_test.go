@@ -0,0 +1,64 @@
+package net
+
+import (
+	stdnet "net"
+	"testing"
+	"time"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/mempool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWhitelist(t *testing.T) {
+	trusted := newTestPeerID(t)
+	wl, err := parseWhitelist([]string{trusted.String(), "10.0.0.0/8"})
+	require.NoError(t, err)
+	assert.Contains(t, wl.peers, trusted)
+	assert.True(t, wl.containsIP(stdnet.ParseIP("10.1.2.3")))
+	assert.False(t, wl.containsIP(stdnet.ParseIP("192.168.1.1")))
+
+	_, err = parseWhitelist([]string{"10.0.0.0/99"})
+	assert.Error(t, err)
+	_, err = parseWhitelist([]string{"not-a-peer-id"})
+	assert.Error(t, err)
+}
+
+// TestWhitelistedPeerRelay checks that a whitelisted peer can relay a
+// non-standard but valid transaction, and bypass the announcement limit,
+// where another peer cannot.
+func TestWhitelistedPeerRelay(t *testing.T) {
+	trusted := newTestPeerID(t)
+	other := newTestPeerID(t)
+	wl, err := parseWhitelist([]string{trusted.String()})
+	require.NoError(t, err)
+
+	n := &Network{
+		mempool:       mempool.NewMempool(mempool.TestMempoolConfig()),
+		whitelist:     wl,
+		announcements: newAnnouncementLimiter(1, time.Minute),
+	}
+	assert.True(t, n.IsWhitelisted(trusted))
+	assert.False(t, n.IsWhitelisted(other))
+
+	// A transaction creating a dust output
+	tx := &block.Transaction{
+		Version: 1,
+		Inputs:  []*block.TxInput{{PrevTxHash: make([]byte, 32), ScriptSig: make([]byte, 129), Sequence: 0xffffffff}},
+		Outputs: []*block.TxOutput{{Value: 100, ScriptPubKey: make([]byte, 20)}},
+		Fee:     2500,
+	}
+	tx.Hash = tx.CalculateHash()
+
+	err = n.AcceptTransaction(other, tx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "dust")
+	require.NoError(t, n.AcceptTransaction(trusted, tx))
+
+	for i := 0; i < 5; i++ {
+		assert.True(t, n.AllowAnnouncement("transactions", trusted))
+	}
+	assert.True(t, n.AllowAnnouncement("transactions", other))
+	assert.False(t, n.AllowAnnouncement("transactions", other))
+}
@@ -21,6 +21,115 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// RejectCode classifies why a node rejected a relayed block or transaction
+type RejectCode int32
+
+const (
+	RejectCode_REJECT_UNSPECIFIED      RejectCode = 0
+	RejectCode_REJECT_MALFORMED        RejectCode = 1 // could not be decoded
+	RejectCode_REJECT_INVALID          RejectCode = 2 // failed consensus or policy validation
+	RejectCode_REJECT_DUPLICATE        RejectCode = 3 // already known
+	RejectCode_REJECT_INSUFFICIENT_FEE RejectCode = 4 // fee below the node's minimum
+	RejectCode_REJECT_DOUBLE_SPEND     RejectCode = 5 // spends an output already spent
+)
+
+// Enum value maps for RejectCode.
+var (
+	RejectCode_name = map[int32]string{
+		0: "REJECT_UNSPECIFIED",
+		1: "REJECT_MALFORMED",
+		2: "REJECT_INVALID",
+		3: "REJECT_DUPLICATE",
+		4: "REJECT_INSUFFICIENT_FEE",
+		5: "REJECT_DOUBLE_SPEND",
+	}
+	RejectCode_value = map[string]int32{
+		"REJECT_UNSPECIFIED":      0,
+		"REJECT_MALFORMED":        1,
+		"REJECT_INVALID":          2,
+		"REJECT_DUPLICATE":        3,
+		"REJECT_INSUFFICIENT_FEE": 4,
+		"REJECT_DOUBLE_SPEND":     5,
+	}
+)
+
+func (x RejectCode) Enum() *RejectCode {
+	p := new(RejectCode)
+	*p = x
+	return p
+}
+
+func (x RejectCode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (RejectCode) Descriptor() protoreflect.EnumDescriptor {
+	return file_message_proto_enumTypes[0].Descriptor()
+}
+
+func (RejectCode) Type() protoreflect.EnumType {
+	return &file_message_proto_enumTypes[0]
+}
+
+func (x RejectCode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use RejectCode.Descriptor instead.
+func (RejectCode) EnumDescriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{0}
+}
+
+// InvType names the kind of object an inventory vector refers to
+type InvType int32
+
+const (
+	InvType_INV_UNSPECIFIED InvType = 0
+	InvType_INV_TX          InvType = 1
+	InvType_INV_BLOCK       InvType = 2
+)
+
+// Enum value maps for InvType.
+var (
+	InvType_name = map[int32]string{
+		0: "INV_UNSPECIFIED",
+		1: "INV_TX",
+		2: "INV_BLOCK",
+	}
+	InvType_value = map[string]int32{
+		"INV_UNSPECIFIED": 0,
+		"INV_TX":          1,
+		"INV_BLOCK":       2,
+	}
+)
+
+func (x InvType) Enum() *InvType {
+	p := new(InvType)
+	*p = x
+	return p
+}
+
+func (x InvType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (InvType) Descriptor() protoreflect.EnumDescriptor {
+	return file_message_proto_enumTypes[1].Descriptor()
+}
+
+func (InvType) Type() protoreflect.EnumType {
+	return &file_message_proto_enumTypes[1]
+}
+
+func (x InvType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use InvType.Descriptor instead.
+func (InvType) EnumDescriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{1}
+}
+
 // Specific message types for different content
 type BlockMessage struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -675,6 +784,357 @@ func (x *StateResponse) GetFound() bool {
 	return false
 }
 
+// RejectMessage tells the peer that sent a block or transaction why it was
+// rejected
+type RejectMessage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MessageType   string                 `protobuf:"bytes,1,opt,name=message_type,json=messageType,proto3" json:"message_type,omitempty"` // "block" or "tx"
+	Code          RejectCode             `protobuf:"varint,2,opt,name=code,proto3,enum=net.RejectCode" json:"code,omitempty"`
+	Hash          []byte                 `protobuf:"bytes,3,opt,name=hash,proto3" json:"hash,omitempty"`
+	Reason        string                 `protobuf:"bytes,4,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RejectMessage) Reset() {
+	*x = RejectMessage{}
+	mi := &file_message_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RejectMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RejectMessage) ProtoMessage() {}
+
+func (x *RejectMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RejectMessage.ProtoReflect.Descriptor instead.
+func (*RejectMessage) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *RejectMessage) GetMessageType() string {
+	if x != nil {
+		return x.MessageType
+	}
+	return ""
+}
+
+func (x *RejectMessage) GetCode() RejectCode {
+	if x != nil {
+		return x.Code
+	}
+	return RejectCode_REJECT_UNSPECIFIED
+}
+
+func (x *RejectMessage) GetHash() []byte {
+	if x != nil {
+		return x.Hash
+	}
+	return nil
+}
+
+func (x *RejectMessage) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+// InvVector identifies a block or transaction by hash
+type InvVector struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          InvType                `protobuf:"varint,1,opt,name=type,proto3,enum=net.InvType" json:"type,omitempty"`
+	Hash          []byte                 `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InvVector) Reset() {
+	*x = InvVector{}
+	mi := &file_message_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InvVector) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InvVector) ProtoMessage() {}
+
+func (x *InvVector) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InvVector.ProtoReflect.Descriptor instead.
+func (*InvVector) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *InvVector) GetType() InvType {
+	if x != nil {
+		return x.Type
+	}
+	return InvType_INV_UNSPECIFIED
+}
+
+func (x *InvVector) GetHash() []byte {
+	if x != nil {
+		return x.Hash
+	}
+	return nil
+}
+
+// InvMessage announces blocks and transactions a node can provide
+type InvMessage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Inventory     []*InvVector           `protobuf:"bytes,1,rep,name=inventory,proto3" json:"inventory,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InvMessage) Reset() {
+	*x = InvMessage{}
+	mi := &file_message_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InvMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InvMessage) ProtoMessage() {}
+
+func (x *InvMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InvMessage.ProtoReflect.Descriptor instead.
+func (*InvMessage) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *InvMessage) GetInventory() []*InvVector {
+	if x != nil {
+		return x.Inventory
+	}
+	return nil
+}
+
+// GetDataMessage requests announced blocks and transactions the receiver
+// lacks
+type GetDataMessage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Inventory     []*InvVector           `protobuf:"bytes,1,rep,name=inventory,proto3" json:"inventory,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetDataMessage) Reset() {
+	*x = GetDataMessage{}
+	mi := &file_message_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetDataMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetDataMessage) ProtoMessage() {}
+
+func (x *GetDataMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetDataMessage.ProtoReflect.Descriptor instead.
+func (*GetDataMessage) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *GetDataMessage) GetInventory() []*InvVector {
+	if x != nil {
+		return x.Inventory
+	}
+	return nil
+}
+
+// GetAddrMessage asks a peer for the addresses of peers it knows to be
+// reachable
+type GetAddrMessage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetAddrMessage) Reset() {
+	*x = GetAddrMessage{}
+	mi := &file_message_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetAddrMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetAddrMessage) ProtoMessage() {}
+
+func (x *GetAddrMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetAddrMessage.ProtoReflect.Descriptor instead.
+func (*GetAddrMessage) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{15}
+}
+
+// PeerAddress is a peer and the multiaddresses it can be reached at
+type PeerAddress struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PeerId        []byte                 `protobuf:"bytes,1,opt,name=peer_id,json=peerId,proto3" json:"peer_id,omitempty"`
+	Addrs         []string               `protobuf:"bytes,2,rep,name=addrs,proto3" json:"addrs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PeerAddress) Reset() {
+	*x = PeerAddress{}
+	mi := &file_message_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PeerAddress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PeerAddress) ProtoMessage() {}
+
+func (x *PeerAddress) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PeerAddress.ProtoReflect.Descriptor instead.
+func (*PeerAddress) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *PeerAddress) GetPeerId() []byte {
+	if x != nil {
+		return x.PeerId
+	}
+	return nil
+}
+
+func (x *PeerAddress) GetAddrs() []string {
+	if x != nil {
+		return x.Addrs
+	}
+	return nil
+}
+
+// AddrsMessage answers a GetAddrMessage with a subset of the sender's
+// known-good peers
+type AddrsMessage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Peers         []*PeerAddress         `protobuf:"bytes,1,rep,name=peers,proto3" json:"peers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddrsMessage) Reset() {
+	*x = AddrsMessage{}
+	mi := &file_message_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddrsMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddrsMessage) ProtoMessage() {}
+
+func (x *AddrsMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddrsMessage.ProtoReflect.Descriptor instead.
+func (*AddrsMessage) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *AddrsMessage) GetPeers() []*PeerAddress {
+	if x != nil {
+		return x.Peers
+	}
+	return nil
+}
+
 // Message represents a generic network message
 type Message struct {
 	state             protoimpl.MessageState `protogen:"open.v1"`
@@ -694,6 +1154,11 @@ type Message struct {
 	//	*Message_SyncResponse
 	//	*Message_StateRequest
 	//	*Message_StateResponse
+	//	*Message_RejectMessage
+	//	*Message_InvMessage
+	//	*Message_GetDataMessage
+	//	*Message_GetAddrMessage
+	//	*Message_AddrsMessage
 	Content       isMessage_Content `protobuf_oneof:"content"`
 	Signature     []byte            `protobuf:"bytes,5,opt,name=signature,proto3" json:"signature,omitempty"`
 	unknownFields protoimpl.UnknownFields
@@ -702,7 +1167,7 @@ type Message struct {
 
 func (x *Message) Reset() {
 	*x = Message{}
-	mi := &file_message_proto_msgTypes[11]
+	mi := &file_message_proto_msgTypes[18]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -714,7 +1179,7 @@ func (x *Message) String() string {
 func (*Message) ProtoMessage() {}
 
 func (x *Message) ProtoReflect() protoreflect.Message {
-	mi := &file_message_proto_msgTypes[11]
+	mi := &file_message_proto_msgTypes[18]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -727,7 +1192,7 @@ func (x *Message) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Message.ProtoReflect.Descriptor instead.
 func (*Message) Descriptor() ([]byte, []int) {
-	return file_message_proto_rawDescGZIP(), []int{11}
+	return file_message_proto_rawDescGZIP(), []int{18}
 }
 
 func (x *Message) GetTimestampUnixNano() int64 {
@@ -841,6 +1306,51 @@ func (x *Message) GetStateResponse() *StateResponse {
 	return nil
 }
 
+func (x *Message) GetRejectMessage() *RejectMessage {
+	if x != nil {
+		if x, ok := x.Content.(*Message_RejectMessage); ok {
+			return x.RejectMessage
+		}
+	}
+	return nil
+}
+
+func (x *Message) GetInvMessage() *InvMessage {
+	if x != nil {
+		if x, ok := x.Content.(*Message_InvMessage); ok {
+			return x.InvMessage
+		}
+	}
+	return nil
+}
+
+func (x *Message) GetGetDataMessage() *GetDataMessage {
+	if x != nil {
+		if x, ok := x.Content.(*Message_GetDataMessage); ok {
+			return x.GetDataMessage
+		}
+	}
+	return nil
+}
+
+func (x *Message) GetGetAddrMessage() *GetAddrMessage {
+	if x != nil {
+		if x, ok := x.Content.(*Message_GetAddrMessage); ok {
+			return x.GetAddrMessage
+		}
+	}
+	return nil
+}
+
+func (x *Message) GetAddrsMessage() *AddrsMessage {
+	if x != nil {
+		if x, ok := x.Content.(*Message_AddrsMessage); ok {
+			return x.AddrsMessage
+		}
+	}
+	return nil
+}
+
 func (x *Message) GetSignature() []byte {
 	if x != nil {
 		return x.Signature
@@ -892,6 +1402,26 @@ type Message_StateResponse struct {
 	StateResponse *StateResponse `protobuf:"bytes,17,opt,name=state_response,json=stateResponse,proto3,oneof"`
 }
 
+type Message_RejectMessage struct {
+	RejectMessage *RejectMessage `protobuf:"bytes,18,opt,name=reject_message,json=rejectMessage,proto3,oneof"`
+}
+
+type Message_InvMessage struct {
+	InvMessage *InvMessage `protobuf:"bytes,19,opt,name=inv_message,json=invMessage,proto3,oneof"`
+}
+
+type Message_GetDataMessage struct {
+	GetDataMessage *GetDataMessage `protobuf:"bytes,20,opt,name=get_data_message,json=getDataMessage,proto3,oneof"`
+}
+
+type Message_GetAddrMessage struct {
+	GetAddrMessage *GetAddrMessage `protobuf:"bytes,21,opt,name=get_addr_message,json=getAddrMessage,proto3,oneof"`
+}
+
+type Message_AddrsMessage struct {
+	AddrsMessage *AddrsMessage `protobuf:"bytes,22,opt,name=addrs_message,json=addrsMessage,proto3,oneof"`
+}
+
 func (*Message_BlockMessage) isMessage_Content() {}
 
 func (*Message_TransactionMessage) isMessage_Content() {}
@@ -912,6 +1442,16 @@ func (*Message_StateRequest) isMessage_Content() {}
 
 func (*Message_StateResponse) isMessage_Content() {}
 
+func (*Message_RejectMessage) isMessage_Content() {}
+
+func (*Message_InvMessage) isMessage_Content() {}
+
+func (*Message_GetDataMessage) isMessage_Content() {}
+
+func (*Message_GetAddrMessage) isMessage_Content() {}
+
+func (*Message_AddrsMessage) isMessage_Content() {}
+
 var File_message_proto protoreflect.FileDescriptor
 
 const file_message_proto_rawDesc = "" +
@@ -970,7 +1510,26 @@ const file_message_proto_rawDesc = "" +
 	"\x06height\x18\x02 \x01(\x04R\x06height\x12\x1d\n" +
 	"\n" +
 	"state_root\x18\x03 \x01(\fR\tstateRoot\x12\x14\n" +
-	"\x05found\x18\x04 \x01(\bR\x05found\"\xf6\x05\n" +
+	"\x05found\x18\x04 \x01(\bR\x05found\"\x83\x01\n" +
+	"\rRejectMessage\x12!\n" +
+	"\fmessage_type\x18\x01 \x01(\tR\vmessageType\x12#\n" +
+	"\x04code\x18\x02 \x01(\x0e2\x0f.net.RejectCodeR\x04code\x12\x12\n" +
+	"\x04hash\x18\x03 \x01(\fR\x04hash\x12\x16\n" +
+	"\x06reason\x18\x04 \x01(\tR\x06reason\"A\n" +
+	"\tInvVector\x12 \n" +
+	"\x04type\x18\x01 \x01(\x0e2\f.net.InvTypeR\x04type\x12\x12\n" +
+	"\x04hash\x18\x02 \x01(\fR\x04hash\":\n" +
+	"\n" +
+	"InvMessage\x12,\n" +
+	"\tinventory\x18\x01 \x03(\v2\x0e.net.InvVectorR\tinventory\">\n" +
+	"\x0eGetDataMessage\x12,\n" +
+	"\tinventory\x18\x01 \x03(\v2\x0e.net.InvVectorR\tinventory\"\x10\n" +
+	"\x0eGetAddrMessage\"<\n" +
+	"\vPeerAddress\x12\x17\n" +
+	"\apeer_id\x18\x01 \x01(\fR\x06peerId\x12\x14\n" +
+	"\x05addrs\x18\x02 \x03(\tR\x05addrs\"6\n" +
+	"\fAddrsMessage\x12&\n" +
+	"\x05peers\x18\x01 \x03(\v2\x10.net.PeerAddressR\x05peers\"\xa3\b\n" +
 	"\aMessage\x12.\n" +
 	"\x13timestamp_unix_nano\x18\x01 \x01(\x03R\x11timestampUnixNano\x12 \n" +
 	"\ffrom_peer_id\x18\x02 \x01(\fR\n" +
@@ -985,9 +1544,28 @@ const file_message_proto_rawDesc = "" +
 	"\fsync_request\x18\x0e \x01(\v2\x10.net.SyncRequestH\x00R\vsyncRequest\x128\n" +
 	"\rsync_response\x18\x0f \x01(\v2\x11.net.SyncResponseH\x00R\fsyncResponse\x128\n" +
 	"\rstate_request\x18\x10 \x01(\v2\x11.net.StateRequestH\x00R\fstateRequest\x12;\n" +
-	"\x0estate_response\x18\x11 \x01(\v2\x12.net.StateResponseH\x00R\rstateResponse\x12\x1c\n" +
+	"\x0estate_response\x18\x11 \x01(\v2\x12.net.StateResponseH\x00R\rstateResponse\x12;\n" +
+	"\x0ereject_message\x18\x12 \x01(\v2\x12.net.RejectMessageH\x00R\rrejectMessage\x122\n" +
+	"\vinv_message\x18\x13 \x01(\v2\x0f.net.InvMessageH\x00R\n" +
+	"invMessage\x12?\n" +
+	"\x10get_data_message\x18\x14 \x01(\v2\x13.net.GetDataMessageH\x00R\x0egetDataMessage\x12?\n" +
+	"\x10get_addr_message\x18\x15 \x01(\v2\x13.net.GetAddrMessageH\x00R\x0egetAddrMessage\x128\n" +
+	"\raddrs_message\x18\x16 \x01(\v2\x11.net.AddrsMessageH\x00R\faddrsMessage\x12\x1c\n" +
 	"\tsignature\x18\x05 \x01(\fR\tsignatureB\t\n" +
-	"\acontentB2Z0github.com/adrenochain/adrenochain/pkg/proto/netb\x06proto3"
+	"\acontent*\x9a\x01\n" +
+	"\n" +
+	"RejectCode\x12\x16\n" +
+	"\x12REJECT_UNSPECIFIED\x10\x00\x12\x14\n" +
+	"\x10REJECT_MALFORMED\x10\x01\x12\x12\n" +
+	"\x0eREJECT_INVALID\x10\x02\x12\x14\n" +
+	"\x10REJECT_DUPLICATE\x10\x03\x12\x1b\n" +
+	"\x17REJECT_INSUFFICIENT_FEE\x10\x04\x12\x17\n" +
+	"\x13REJECT_DOUBLE_SPEND\x10\x05*9\n" +
+	"\aInvType\x12\x13\n" +
+	"\x0fINV_UNSPECIFIED\x10\x00\x12\n" +
+	"\n" +
+	"\x06INV_TX\x10\x01\x12\r\n" +
+	"\tINV_BLOCK\x10\x02B2Z0github.com/adrenochain/adrenochain/pkg/proto/netb\x06proto3"
 
 var (
 	file_message_proto_rawDescOnce sync.Once
@@ -1001,39 +1579,59 @@ func file_message_proto_rawDescGZIP() []byte {
 	return file_message_proto_rawDescData
 }
 
-var file_message_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_message_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_message_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
 var file_message_proto_goTypes = []any{
-	(*BlockMessage)(nil),         // 0: net.BlockMessage
-	(*TransactionMessage)(nil),   // 1: net.TransactionMessage
-	(*BlockHeader)(nil),          // 2: net.BlockHeader
-	(*BlockHeadersRequest)(nil),  // 3: net.BlockHeadersRequest
-	(*BlockHeadersResponse)(nil), // 4: net.BlockHeadersResponse
-	(*BlockRequest)(nil),         // 5: net.BlockRequest
-	(*BlockResponse)(nil),        // 6: net.BlockResponse
-	(*SyncRequest)(nil),          // 7: net.SyncRequest
-	(*SyncResponse)(nil),         // 8: net.SyncResponse
-	(*StateRequest)(nil),         // 9: net.StateRequest
-	(*StateResponse)(nil),        // 10: net.StateResponse
-	(*Message)(nil),              // 11: net.Message
+	(RejectCode)(0),              // 0: net.RejectCode
+	(InvType)(0),                 // 1: net.InvType
+	(*BlockMessage)(nil),         // 2: net.BlockMessage
+	(*TransactionMessage)(nil),   // 3: net.TransactionMessage
+	(*BlockHeader)(nil),          // 4: net.BlockHeader
+	(*BlockHeadersRequest)(nil),  // 5: net.BlockHeadersRequest
+	(*BlockHeadersResponse)(nil), // 6: net.BlockHeadersResponse
+	(*BlockRequest)(nil),         // 7: net.BlockRequest
+	(*BlockResponse)(nil),        // 8: net.BlockResponse
+	(*SyncRequest)(nil),          // 9: net.SyncRequest
+	(*SyncResponse)(nil),         // 10: net.SyncResponse
+	(*StateRequest)(nil),         // 11: net.StateRequest
+	(*StateResponse)(nil),        // 12: net.StateResponse
+	(*RejectMessage)(nil),        // 13: net.RejectMessage
+	(*InvVector)(nil),            // 14: net.InvVector
+	(*InvMessage)(nil),           // 15: net.InvMessage
+	(*GetDataMessage)(nil),       // 16: net.GetDataMessage
+	(*GetAddrMessage)(nil),       // 17: net.GetAddrMessage
+	(*PeerAddress)(nil),          // 18: net.PeerAddress
+	(*AddrsMessage)(nil),         // 19: net.AddrsMessage
+	(*Message)(nil),              // 20: net.Message
 }
 var file_message_proto_depIdxs = []int32{
-	2,  // 0: net.BlockHeadersResponse.headers:type_name -> net.BlockHeader
-	2,  // 1: net.SyncResponse.headers:type_name -> net.BlockHeader
-	0,  // 2: net.Message.block_message:type_name -> net.BlockMessage
-	1,  // 3: net.Message.transaction_message:type_name -> net.TransactionMessage
-	3,  // 4: net.Message.headers_request:type_name -> net.BlockHeadersRequest
-	4,  // 5: net.Message.headers_response:type_name -> net.BlockHeadersResponse
-	5,  // 6: net.Message.block_request:type_name -> net.BlockRequest
-	6,  // 7: net.Message.block_response:type_name -> net.BlockResponse
-	7,  // 8: net.Message.sync_request:type_name -> net.SyncRequest
-	8,  // 9: net.Message.sync_response:type_name -> net.SyncResponse
-	9,  // 10: net.Message.state_request:type_name -> net.StateRequest
-	10, // 11: net.Message.state_response:type_name -> net.StateResponse
-	12, // [12:12] is the sub-list for method output_type
-	12, // [12:12] is the sub-list for method input_type
-	12, // [12:12] is the sub-list for extension type_name
-	12, // [12:12] is the sub-list for extension extendee
-	0,  // [0:12] is the sub-list for field type_name
+	4,  // 0: net.BlockHeadersResponse.headers:type_name -> net.BlockHeader
+	4,  // 1: net.SyncResponse.headers:type_name -> net.BlockHeader
+	0,  // 2: net.RejectMessage.code:type_name -> net.RejectCode
+	1,  // 3: net.InvVector.type:type_name -> net.InvType
+	14, // 4: net.InvMessage.inventory:type_name -> net.InvVector
+	14, // 5: net.GetDataMessage.inventory:type_name -> net.InvVector
+	18, // 6: net.AddrsMessage.peers:type_name -> net.PeerAddress
+	2,  // 7: net.Message.block_message:type_name -> net.BlockMessage
+	3,  // 8: net.Message.transaction_message:type_name -> net.TransactionMessage
+	5,  // 9: net.Message.headers_request:type_name -> net.BlockHeadersRequest
+	6,  // 10: net.Message.headers_response:type_name -> net.BlockHeadersResponse
+	7,  // 11: net.Message.block_request:type_name -> net.BlockRequest
+	8,  // 12: net.Message.block_response:type_name -> net.BlockResponse
+	9,  // 13: net.Message.sync_request:type_name -> net.SyncRequest
+	10, // 14: net.Message.sync_response:type_name -> net.SyncResponse
+	11, // 15: net.Message.state_request:type_name -> net.StateRequest
+	12, // 16: net.Message.state_response:type_name -> net.StateResponse
+	13, // 17: net.Message.reject_message:type_name -> net.RejectMessage
+	15, // 18: net.Message.inv_message:type_name -> net.InvMessage
+	16, // 19: net.Message.get_data_message:type_name -> net.GetDataMessage
+	17, // 20: net.Message.get_addr_message:type_name -> net.GetAddrMessage
+	19, // 21: net.Message.addrs_message:type_name -> net.AddrsMessage
+	22, // [22:22] is the sub-list for method output_type
+	22, // [22:22] is the sub-list for method input_type
+	22, // [22:22] is the sub-list for extension type_name
+	22, // [22:22] is the sub-list for extension extendee
+	0,  // [0:22] is the sub-list for field type_name
 }
 
 func init() { file_message_proto_init() }
@@ -1041,7 +1639,7 @@ func file_message_proto_init() {
 	if File_message_proto != nil {
 		return
 	}
-	file_message_proto_msgTypes[11].OneofWrappers = []any{
+	file_message_proto_msgTypes[18].OneofWrappers = []any{
 		(*Message_BlockMessage)(nil),
 		(*Message_TransactionMessage)(nil),
 		(*Message_HeadersRequest)(nil),
@@ -1052,19 +1650,25 @@ func file_message_proto_init() {
 		(*Message_SyncResponse)(nil),
 		(*Message_StateRequest)(nil),
 		(*Message_StateResponse)(nil),
+		(*Message_RejectMessage)(nil),
+		(*Message_InvMessage)(nil),
+		(*Message_GetDataMessage)(nil),
+		(*Message_GetAddrMessage)(nil),
+		(*Message_AddrsMessage)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_message_proto_rawDesc), len(file_message_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   12,
+			NumEnums:      2,
+			NumMessages:   19,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
 		GoTypes:           file_message_proto_goTypes,
 		DependencyIndexes: file_message_proto_depIdxs,
+		EnumInfos:         file_message_proto_enumTypes,
 		MessageInfos:      file_message_proto_msgTypes,
 	}.Build()
 	File_message_proto = out.File
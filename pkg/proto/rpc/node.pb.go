@@ -0,0 +1,762 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.7
+// 	protoc        v3.12.4
+// source: node.proto
+
+package rpc
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetChainInfoRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetChainInfoRequest) Reset() {
+	*x = GetChainInfoRequest{}
+	mi := &file_node_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetChainInfoRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetChainInfoRequest) ProtoMessage() {}
+
+func (x *GetChainInfoRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_node_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetChainInfoRequest.ProtoReflect.Descriptor instead.
+func (*GetChainInfoRequest) Descriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{0}
+}
+
+type ChainInfo struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Height           uint64                 `protobuf:"varint,1,opt,name=height,proto3" json:"height,omitempty"`
+	BestBlockHash    []byte                 `protobuf:"bytes,2,opt,name=best_block_hash,json=bestBlockHash,proto3" json:"best_block_hash,omitempty"`
+	GenesisBlockHash []byte                 `protobuf:"bytes,3,opt,name=genesis_block_hash,json=genesisBlockHash,proto3" json:"genesis_block_hash,omitempty"`
+	Difficulty       uint64                 `protobuf:"varint,4,opt,name=difficulty,proto3" json:"difficulty,omitempty"`
+	NextDifficulty   uint64                 `protobuf:"varint,5,opt,name=next_difficulty,json=nextDifficulty,proto3" json:"next_difficulty,omitempty"`
+	MempoolSize      uint64                 `protobuf:"varint,6,opt,name=mempool_size,json=mempoolSize,proto3" json:"mempool_size,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *ChainInfo) Reset() {
+	*x = ChainInfo{}
+	mi := &file_node_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChainInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChainInfo) ProtoMessage() {}
+
+func (x *ChainInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_node_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChainInfo.ProtoReflect.Descriptor instead.
+func (*ChainInfo) Descriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ChainInfo) GetHeight() uint64 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *ChainInfo) GetBestBlockHash() []byte {
+	if x != nil {
+		return x.BestBlockHash
+	}
+	return nil
+}
+
+func (x *ChainInfo) GetGenesisBlockHash() []byte {
+	if x != nil {
+		return x.GenesisBlockHash
+	}
+	return nil
+}
+
+func (x *ChainInfo) GetDifficulty() uint64 {
+	if x != nil {
+		return x.Difficulty
+	}
+	return 0
+}
+
+func (x *ChainInfo) GetNextDifficulty() uint64 {
+	if x != nil {
+		return x.NextDifficulty
+	}
+	return 0
+}
+
+func (x *ChainInfo) GetMempoolSize() uint64 {
+	if x != nil {
+		return x.MempoolSize
+	}
+	return 0
+}
+
+type GetBlockRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Selector:
+	//
+	//	*GetBlockRequest_Hash
+	//	*GetBlockRequest_Height
+	Selector      isGetBlockRequest_Selector `protobuf_oneof:"selector"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetBlockRequest) Reset() {
+	*x = GetBlockRequest{}
+	mi := &file_node_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetBlockRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetBlockRequest) ProtoMessage() {}
+
+func (x *GetBlockRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_node_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetBlockRequest.ProtoReflect.Descriptor instead.
+func (*GetBlockRequest) Descriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetBlockRequest) GetSelector() isGetBlockRequest_Selector {
+	if x != nil {
+		return x.Selector
+	}
+	return nil
+}
+
+func (x *GetBlockRequest) GetHash() []byte {
+	if x != nil {
+		if x, ok := x.Selector.(*GetBlockRequest_Hash); ok {
+			return x.Hash
+		}
+	}
+	return nil
+}
+
+func (x *GetBlockRequest) GetHeight() uint64 {
+	if x != nil {
+		if x, ok := x.Selector.(*GetBlockRequest_Height); ok {
+			return x.Height
+		}
+	}
+	return 0
+}
+
+type isGetBlockRequest_Selector interface {
+	isGetBlockRequest_Selector()
+}
+
+type GetBlockRequest_Hash struct {
+	Hash []byte `protobuf:"bytes,1,opt,name=hash,proto3,oneof"`
+}
+
+type GetBlockRequest_Height struct {
+	Height uint64 `protobuf:"varint,2,opt,name=height,proto3,oneof"`
+}
+
+func (*GetBlockRequest_Hash) isGetBlockRequest_Selector() {}
+
+func (*GetBlockRequest_Height) isGetBlockRequest_Selector() {}
+
+type Transaction struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Hash          []byte                 `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	InputCount    uint32                 `protobuf:"varint,2,opt,name=input_count,json=inputCount,proto3" json:"input_count,omitempty"`
+	OutputCount   uint32                 `protobuf:"varint,3,opt,name=output_count,json=outputCount,proto3" json:"output_count,omitempty"`
+	Fee           uint64                 `protobuf:"varint,4,opt,name=fee,proto3" json:"fee,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Transaction) Reset() {
+	*x = Transaction{}
+	mi := &file_node_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Transaction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Transaction) ProtoMessage() {}
+
+func (x *Transaction) ProtoReflect() protoreflect.Message {
+	mi := &file_node_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Transaction.ProtoReflect.Descriptor instead.
+func (*Transaction) Descriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Transaction) GetHash() []byte {
+	if x != nil {
+		return x.Hash
+	}
+	return nil
+}
+
+func (x *Transaction) GetInputCount() uint32 {
+	if x != nil {
+		return x.InputCount
+	}
+	return 0
+}
+
+func (x *Transaction) GetOutputCount() uint32 {
+	if x != nil {
+		return x.OutputCount
+	}
+	return 0
+}
+
+func (x *Transaction) GetFee() uint64 {
+	if x != nil {
+		return x.Fee
+	}
+	return 0
+}
+
+type Block struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Hash          []byte                 `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	Height        uint64                 `protobuf:"varint,2,opt,name=height,proto3" json:"height,omitempty"`
+	Version       uint32                 `protobuf:"varint,3,opt,name=version,proto3" json:"version,omitempty"`
+	PrevBlockHash []byte                 `protobuf:"bytes,4,opt,name=prev_block_hash,json=prevBlockHash,proto3" json:"prev_block_hash,omitempty"`
+	MerkleRoot    []byte                 `protobuf:"bytes,5,opt,name=merkle_root,json=merkleRoot,proto3" json:"merkle_root,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,6,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Difficulty    uint64                 `protobuf:"varint,7,opt,name=difficulty,proto3" json:"difficulty,omitempty"`
+	Nonce         uint64                 `protobuf:"varint,8,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	Transactions  []*Transaction         `protobuf:"bytes,9,rep,name=transactions,proto3" json:"transactions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Block) Reset() {
+	*x = Block{}
+	mi := &file_node_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Block) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Block) ProtoMessage() {}
+
+func (x *Block) ProtoReflect() protoreflect.Message {
+	mi := &file_node_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Block.ProtoReflect.Descriptor instead.
+func (*Block) Descriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Block) GetHash() []byte {
+	if x != nil {
+		return x.Hash
+	}
+	return nil
+}
+
+func (x *Block) GetHeight() uint64 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *Block) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Block) GetPrevBlockHash() []byte {
+	if x != nil {
+		return x.PrevBlockHash
+	}
+	return nil
+}
+
+func (x *Block) GetMerkleRoot() []byte {
+	if x != nil {
+		return x.MerkleRoot
+	}
+	return nil
+}
+
+func (x *Block) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *Block) GetDifficulty() uint64 {
+	if x != nil {
+		return x.Difficulty
+	}
+	return 0
+}
+
+func (x *Block) GetNonce() uint64 {
+	if x != nil {
+		return x.Nonce
+	}
+	return 0
+}
+
+func (x *Block) GetTransactions() []*Transaction {
+	if x != nil {
+		return x.Transactions
+	}
+	return nil
+}
+
+type GetBalanceRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Address       string                 `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetBalanceRequest) Reset() {
+	*x = GetBalanceRequest{}
+	mi := &file_node_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetBalanceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetBalanceRequest) ProtoMessage() {}
+
+func (x *GetBalanceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_node_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetBalanceRequest.ProtoReflect.Descriptor instead.
+func (*GetBalanceRequest) Descriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetBalanceRequest) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+type Balance struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Address       string                 `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	Balance       uint64                 `protobuf:"varint,2,opt,name=balance,proto3" json:"balance,omitempty"`
+	UtxoCount     uint32                 `protobuf:"varint,3,opt,name=utxo_count,json=utxoCount,proto3" json:"utxo_count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Balance) Reset() {
+	*x = Balance{}
+	mi := &file_node_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Balance) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Balance) ProtoMessage() {}
+
+func (x *Balance) ProtoReflect() protoreflect.Message {
+	mi := &file_node_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Balance.ProtoReflect.Descriptor instead.
+func (*Balance) Descriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Balance) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *Balance) GetBalance() uint64 {
+	if x != nil {
+		return x.Balance
+	}
+	return 0
+}
+
+func (x *Balance) GetUtxoCount() uint32 {
+	if x != nil {
+		return x.UtxoCount
+	}
+	return 0
+}
+
+type SendRawTransactionRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	RawTransaction []byte                 `protobuf:"bytes,1,opt,name=raw_transaction,json=rawTransaction,proto3" json:"raw_transaction,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *SendRawTransactionRequest) Reset() {
+	*x = SendRawTransactionRequest{}
+	mi := &file_node_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendRawTransactionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendRawTransactionRequest) ProtoMessage() {}
+
+func (x *SendRawTransactionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_node_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendRawTransactionRequest.ProtoReflect.Descriptor instead.
+func (*SendRawTransactionRequest) Descriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *SendRawTransactionRequest) GetRawTransaction() []byte {
+	if x != nil {
+		return x.RawTransaction
+	}
+	return nil
+}
+
+type SendRawTransactionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TxHash        []byte                 `protobuf:"bytes,1,opt,name=tx_hash,json=txHash,proto3" json:"tx_hash,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendRawTransactionResponse) Reset() {
+	*x = SendRawTransactionResponse{}
+	mi := &file_node_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendRawTransactionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendRawTransactionResponse) ProtoMessage() {}
+
+func (x *SendRawTransactionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_node_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendRawTransactionResponse.ProtoReflect.Descriptor instead.
+func (*SendRawTransactionResponse) Descriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *SendRawTransactionResponse) GetTxHash() []byte {
+	if x != nil {
+		return x.TxHash
+	}
+	return nil
+}
+
+type SubscribeBlocksRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeBlocksRequest) Reset() {
+	*x = SubscribeBlocksRequest{}
+	mi := &file_node_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeBlocksRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeBlocksRequest) ProtoMessage() {}
+
+func (x *SubscribeBlocksRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_node_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeBlocksRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeBlocksRequest) Descriptor() ([]byte, []int) {
+	return file_node_proto_rawDescGZIP(), []int{9}
+}
+
+var File_node_proto protoreflect.FileDescriptor
+
+const file_node_proto_rawDesc = "" +
+	"\n" +
+	"\n" +
+	"node.proto\x12\x03rpc\"\x15\n" +
+	"\x13GetChainInfoRequest\"\xe5\x01\n" +
+	"\tChainInfo\x12\x16\n" +
+	"\x06height\x18\x01 \x01(\x04R\x06height\x12&\n" +
+	"\x0fbest_block_hash\x18\x02 \x01(\fR\rbestBlockHash\x12,\n" +
+	"\x12genesis_block_hash\x18\x03 \x01(\fR\x10genesisBlockHash\x12\x1e\n" +
+	"\n" +
+	"difficulty\x18\x04 \x01(\x04R\n" +
+	"difficulty\x12'\n" +
+	"\x0fnext_difficulty\x18\x05 \x01(\x04R\x0enextDifficulty\x12!\n" +
+	"\fmempool_size\x18\x06 \x01(\x04R\vmempoolSize\"M\n" +
+	"\x0fGetBlockRequest\x12\x14\n" +
+	"\x04hash\x18\x01 \x01(\fH\x00R\x04hash\x12\x18\n" +
+	"\x06height\x18\x02 \x01(\x04H\x00R\x06heightB\n" +
+	"\n" +
+	"\bselector\"w\n" +
+	"\vTransaction\x12\x12\n" +
+	"\x04hash\x18\x01 \x01(\fR\x04hash\x12\x1f\n" +
+	"\vinput_count\x18\x02 \x01(\rR\n" +
+	"inputCount\x12!\n" +
+	"\foutput_count\x18\x03 \x01(\rR\voutputCount\x12\x10\n" +
+	"\x03fee\x18\x04 \x01(\x04R\x03fee\"\xa0\x02\n" +
+	"\x05Block\x12\x12\n" +
+	"\x04hash\x18\x01 \x01(\fR\x04hash\x12\x16\n" +
+	"\x06height\x18\x02 \x01(\x04R\x06height\x12\x18\n" +
+	"\aversion\x18\x03 \x01(\rR\aversion\x12&\n" +
+	"\x0fprev_block_hash\x18\x04 \x01(\fR\rprevBlockHash\x12\x1f\n" +
+	"\vmerkle_root\x18\x05 \x01(\fR\n" +
+	"merkleRoot\x12\x1c\n" +
+	"\ttimestamp\x18\x06 \x01(\x03R\ttimestamp\x12\x1e\n" +
+	"\n" +
+	"difficulty\x18\a \x01(\x04R\n" +
+	"difficulty\x12\x14\n" +
+	"\x05nonce\x18\b \x01(\x04R\x05nonce\x124\n" +
+	"\ftransactions\x18\t \x03(\v2\x10.rpc.TransactionR\ftransactions\"-\n" +
+	"\x11GetBalanceRequest\x12\x18\n" +
+	"\aaddress\x18\x01 \x01(\tR\aaddress\"\\\n" +
+	"\aBalance\x12\x18\n" +
+	"\aaddress\x18\x01 \x01(\tR\aaddress\x12\x18\n" +
+	"\abalance\x18\x02 \x01(\x04R\abalance\x12\x1d\n" +
+	"\n" +
+	"utxo_count\x18\x03 \x01(\rR\tutxoCount\"D\n" +
+	"\x19SendRawTransactionRequest\x12'\n" +
+	"\x0fraw_transaction\x18\x01 \x01(\fR\x0erawTransaction\"5\n" +
+	"\x1aSendRawTransactionResponse\x12\x17\n" +
+	"\atx_hash\x18\x01 \x01(\fR\x06txHash\"\x18\n" +
+	"\x16SubscribeBlocksRequest2\xbe\x02\n" +
+	"\vNodeService\x128\n" +
+	"\fGetChainInfo\x12\x18.rpc.GetChainInfoRequest\x1a\x0e.rpc.ChainInfo\x12,\n" +
+	"\bGetBlock\x12\x14.rpc.GetBlockRequest\x1a\n" +
+	".rpc.Block\x122\n" +
+	"\n" +
+	"GetBalance\x12\x16.rpc.GetBalanceRequest\x1a\f.rpc.Balance\x12U\n" +
+	"\x12SendRawTransaction\x12\x1e.rpc.SendRawTransactionRequest\x1a\x1f.rpc.SendRawTransactionResponse\x12<\n" +
+	"\x0fSubscribeBlocks\x12\x1b.rpc.SubscribeBlocksRequest\x1a\n" +
+	".rpc.Block0\x01B/Z-github.com/palaseus/adrenochain/pkg/proto/rpcb\x06proto3"
+
+var (
+	file_node_proto_rawDescOnce sync.Once
+	file_node_proto_rawDescData []byte
+)
+
+func file_node_proto_rawDescGZIP() []byte {
+	file_node_proto_rawDescOnce.Do(func() {
+		file_node_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_node_proto_rawDesc), len(file_node_proto_rawDesc)))
+	})
+	return file_node_proto_rawDescData
+}
+
+var file_node_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_node_proto_goTypes = []any{
+	(*GetChainInfoRequest)(nil),        // 0: rpc.GetChainInfoRequest
+	(*ChainInfo)(nil),                  // 1: rpc.ChainInfo
+	(*GetBlockRequest)(nil),            // 2: rpc.GetBlockRequest
+	(*Transaction)(nil),                // 3: rpc.Transaction
+	(*Block)(nil),                      // 4: rpc.Block
+	(*GetBalanceRequest)(nil),          // 5: rpc.GetBalanceRequest
+	(*Balance)(nil),                    // 6: rpc.Balance
+	(*SendRawTransactionRequest)(nil),  // 7: rpc.SendRawTransactionRequest
+	(*SendRawTransactionResponse)(nil), // 8: rpc.SendRawTransactionResponse
+	(*SubscribeBlocksRequest)(nil),     // 9: rpc.SubscribeBlocksRequest
+}
+var file_node_proto_depIdxs = []int32{
+	3, // 0: rpc.Block.transactions:type_name -> rpc.Transaction
+	0, // 1: rpc.NodeService.GetChainInfo:input_type -> rpc.GetChainInfoRequest
+	2, // 2: rpc.NodeService.GetBlock:input_type -> rpc.GetBlockRequest
+	5, // 3: rpc.NodeService.GetBalance:input_type -> rpc.GetBalanceRequest
+	7, // 4: rpc.NodeService.SendRawTransaction:input_type -> rpc.SendRawTransactionRequest
+	9, // 5: rpc.NodeService.SubscribeBlocks:input_type -> rpc.SubscribeBlocksRequest
+	1, // 6: rpc.NodeService.GetChainInfo:output_type -> rpc.ChainInfo
+	4, // 7: rpc.NodeService.GetBlock:output_type -> rpc.Block
+	6, // 8: rpc.NodeService.GetBalance:output_type -> rpc.Balance
+	8, // 9: rpc.NodeService.SendRawTransaction:output_type -> rpc.SendRawTransactionResponse
+	4, // 10: rpc.NodeService.SubscribeBlocks:output_type -> rpc.Block
+	6, // [6:11] is the sub-list for method output_type
+	1, // [1:6] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_node_proto_init() }
+func file_node_proto_init() {
+	if File_node_proto != nil {
+		return
+	}
+	file_node_proto_msgTypes[2].OneofWrappers = []any{
+		(*GetBlockRequest_Hash)(nil),
+		(*GetBlockRequest_Height)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_node_proto_rawDesc), len(file_node_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   10,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_node_proto_goTypes,
+		DependencyIndexes: file_node_proto_depIdxs,
+		MessageInfos:      file_node_proto_msgTypes,
+	}.Build()
+	File_node_proto = out.File
+	file_node_proto_goTypes = nil
+	file_node_proto_depIdxs = nil
+}
@@ -24,6 +24,18 @@ type StorageInterface interface {
 	Close() error
 }
 
+// BlockPruner is implemented by storages that can delete the bodies of
+// blocks, which pruning requires.
+type BlockPruner interface {
+	DeleteBlock(hash []byte) error
+}
+
+// Compacter is implemented by storages that can compact their data to
+// reclaim the space of deleted keys.
+type Compacter interface {
+	Compact() error
+}
+
 // StorageType represents the type of storage backend
 type StorageType string
 
@@ -33,19 +45,33 @@ const (
 )
 
 // StorageFactory creates storage instances based on configuration
-type StorageFactory struct{}
+type StorageFactory struct {
+	levelDB *LevelDBStorageConfig
+}
 
 // NewStorageFactory creates a new storage factory
 func NewStorageFactory() *StorageFactory {
 	return &StorageFactory{}
 }
 
+// WithLevelDBConfig sets the configuration of the LevelDB storages the
+// factory creates; their data directory is still the one passed to
+// CreateStorage.
+func (f *StorageFactory) WithLevelDBConfig(config *LevelDBStorageConfig) *StorageFactory {
+	f.levelDB = config
+	return f
+}
+
 // CreateStorage creates a storage instance based on the specified type
 func (f *StorageFactory) CreateStorage(storageType StorageType, dataDir string) (StorageInterface, error) {
 	switch storageType {
 	case StorageTypeLevelDB:
-		config := DefaultLevelDBStorageConfig().WithDataDir(dataDir)
-		return NewLevelDBStorage(config)
+		config := DefaultLevelDBStorageConfig()
+		if f.levelDB != nil {
+			copied := *f.levelDB
+			config = &copied
+		}
+		return NewLevelDBStorage(config.WithDataDir(dataDir))
 	case StorageTypeFile:
 		fallthrough
 	default:
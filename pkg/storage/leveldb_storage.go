@@ -2,8 +2,12 @@ package storage
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/palaseus/adrenochain/pkg/block"
 	"github.com/syndtr/goleveldb/leveldb"
@@ -15,6 +19,16 @@ import (
 type LevelDBStorage struct {
 	db      *leveldb.DB
 	dataDir string
+
+	// Deletions leave tombstones that slow reads down until a compaction
+	// drops them, so the storage compacts itself on a schedule
+	compactAfterDeletions int64
+	deletions             atomic.Int64 // Deletions since the last compaction
+	compactions           atomic.Int64
+	compactNow            chan struct{}
+	stop                  chan struct{}
+	stopOnce              sync.Once
+	scheduler             sync.WaitGroup
 }
 
 // LevelDBStorageConfig holds configuration for LevelDB storage
@@ -24,6 +38,13 @@ type LevelDBStorageConfig struct {
 	WriteBufferSize        int
 	OpenFilesCacheCapacity int
 	Compression            bool
+	// CompactionInterval is how often the whole database is compacted.
+	// Zero disables scheduled compaction.
+	CompactionInterval time.Duration
+	// CompactAfterDeletions compacts the database once this many keys
+	// were deleted since the last compaction, e.g. after pruning. Zero
+	// disables it.
+	CompactAfterDeletions int
 }
 
 // DefaultLevelDBStorageConfig returns the default LevelDB storage configuration
@@ -60,8 +81,38 @@ func (c *LevelDBStorageConfig) WithCompression(enable bool) *LevelDBStorageConfi
 	return c
 }
 
+// WithCompactionInterval sets how often the database is compacted
+func (c *LevelDBStorageConfig) WithCompactionInterval(interval time.Duration) *LevelDBStorageConfig {
+	c.CompactionInterval = interval
+	return c
+}
+
+// WithCompactAfterDeletions sets the number of deletions that trigger a
+// compaction
+func (c *LevelDBStorageConfig) WithCompactAfterDeletions(deletions int) *LevelDBStorageConfig {
+	c.CompactAfterDeletions = deletions
+	return c
+}
+
+// Validate checks that the configuration is usable and returns every
+// problem found.
+func (c *LevelDBStorageConfig) Validate() error {
+	var errs []error
+	if c.CompactionInterval < 0 {
+		errs = append(errs, fmt.Errorf("storage: compaction interval must not be negative"))
+	}
+	if c.CompactAfterDeletions < 0 {
+		errs = append(errs, fmt.Errorf("storage: compact after deletions %d is negative", c.CompactAfterDeletions))
+	}
+	return errors.Join(errs...)
+}
+
 // NewLevelDBStorage creates a new LevelDB-based storage
 func NewLevelDBStorage(config *LevelDBStorageConfig) (*LevelDBStorage, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
 	// Create data directory if it doesn't exist
 	if err := ensureDir(config.DataDir); err != nil {
 		return nil, fmt.Errorf("failed to create data directory: %w", err)
@@ -86,10 +137,56 @@ func NewLevelDBStorage(config *LevelDBStorageConfig) (*LevelDBStorage, error) {
 		return nil, fmt.Errorf("failed to open LevelDB: %w", err)
 	}
 
-	return &LevelDBStorage{
-		db:      db,
-		dataDir: config.DataDir,
-	}, nil
+	s := &LevelDBStorage{
+		db:                    db,
+		dataDir:               config.DataDir,
+		compactAfterDeletions: int64(config.CompactAfterDeletions),
+		compactNow:            make(chan struct{}, 1),
+		stop:                  make(chan struct{}),
+	}
+	if config.CompactionInterval > 0 || config.CompactAfterDeletions > 0 {
+		s.scheduler.Add(1)
+		go s.runCompactions(config.CompactionInterval)
+	}
+	return s, nil
+}
+
+// runCompactions compacts the database every interval, if positive, and
+// whenever enough keys were deleted, until the storage is closed.
+func (s *LevelDBStorage) runCompactions(interval time.Duration) {
+	defer s.scheduler.Done()
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-tick:
+		case <-s.compactNow:
+		case <-s.stop:
+			return
+		}
+		if err := s.Compact(); err != nil {
+			fmt.Printf("LevelDB compaction failed: %v\n", err)
+		}
+	}
+}
+
+// deleted counts a deleted key and schedules a compaction once enough keys
+// were deleted.
+func (s *LevelDBStorage) deleted() {
+	if s.compactAfterDeletions <= 0 || s.deletions.Add(1) < s.compactAfterDeletions {
+		return
+	}
+	select {
+	case s.compactNow <- struct{}{}:
+	default:
+		// A compaction is pending already
+	}
 }
 
 // StoreBlock stores a block in LevelDB
@@ -132,6 +229,19 @@ func (s *LevelDBStorage) GetBlock(hash []byte) (*block.Block, error) {
 	return &b, nil
 }
 
+// DeleteBlock removes a block from LevelDB. Deleting a block that is not
+// stored is not an error.
+func (s *LevelDBStorage) DeleteBlock(hash []byte) error {
+	if len(hash) == 0 {
+		return fmt.Errorf("invalid hash: cannot be nil or empty")
+	}
+	if err := s.db.Delete(makeBlockKey(hash), nil); err != nil {
+		return err
+	}
+	s.deleted()
+	return nil
+}
+
 // StoreChainState stores the chain state in LevelDB
 func (s *LevelDBStorage) StoreChainState(state *ChainState) error {
 	if state == nil {
@@ -201,7 +311,11 @@ func (s *LevelDBStorage) Delete(key []byte) error {
 		return fmt.Errorf("invalid key: cannot be nil or empty")
 	}
 
-	return s.db.Delete(key, nil)
+	if err := s.db.Delete(key, nil); err != nil {
+		return err
+	}
+	s.deleted()
+	return nil
 }
 
 // Has checks if a key exists in LevelDB
@@ -213,19 +327,31 @@ func (s *LevelDBStorage) Has(key []byte) (bool, error) {
 	return s.db.Has(key, nil)
 }
 
-// Close closes the LevelDB connection
+// Close stops scheduled compactions and closes the LevelDB connection
 func (s *LevelDBStorage) Close() error {
+	s.stopOnce.Do(func() {
+		if s.stop != nil {
+			close(s.stop)
+		}
+	})
+	s.scheduler.Wait()
+
 	if s.db != nil {
 		return s.db.Close()
 	}
 	return nil
 }
 
-// Compact compacts the LevelDB database to reclaim space
+// Compact compacts the LevelDB database to reclaim space and drop the
+// tombstones of deleted keys
 func (s *LevelDBStorage) Compact() error {
 	if s.db != nil {
 		// Compact the entire database
-		return s.db.CompactRange(util.Range{Start: nil, Limit: nil})
+		s.deletions.Store(0)
+		if err := s.db.CompactRange(util.Range{Start: nil, Limit: nil}); err != nil {
+			return err
+		}
+		s.compactions.Add(1)
 	}
 	return nil
 }
@@ -241,6 +367,8 @@ func (s *LevelDBStorage) GetStats() map[string]interface{} {
 		// Note: LevelDB doesn't expose many metrics by default
 		// In a production system, you might want to use prometheus or similar
 		stats["db_open"] = true
+		stats["compactions"] = s.compactions.Load()
+		stats["deletions_since_compaction"] = s.deletions.Load()
 	}
 
 	return stats
@@ -1,10 +1,12 @@
 package storage
 
 import (
+	"crypto/rand"
 	"fmt"
 	"os"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/palaseus/adrenochain/pkg/block"
 	"github.com/stretchr/testify/assert"
@@ -559,3 +561,56 @@ func TestLevelDBStorageEdgeCases(t *testing.T) {
 		assert.Equal(t, "new_value", string(value))
 	})
 }
+
+// dirSize returns the total size of the files in dir.
+func dirSize(t *testing.T, dir string) int64 {
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var size int64
+	for _, entry := range entries {
+		info, err := entry.Info()
+		require.NoError(t, err)
+		size += info.Size()
+	}
+	return size
+}
+
+func TestLevelDBStorageCompaction(t *testing.T) {
+	tempDir := t.TempDir()
+	config := DefaultLevelDBStorageConfig().
+		WithDataDir(tempDir).
+		WithWriteBufferSize(1024 * 1024).
+		WithCompactAfterDeletions(1900)
+	storage, err := NewLevelDBStorage(config)
+	require.NoError(t, err)
+	defer storage.Close()
+
+	value := make([]byte, 4096)
+	for i := 0; i < 2000; i++ {
+		_, err := rand.Read(value)
+		require.NoError(t, err)
+		require.NoError(t, storage.Write([]byte(fmt.Sprintf("key%04d", i)), value))
+	}
+	require.NoError(t, storage.Compact())
+	compactions := storage.GetStats()["compactions"].(int64)
+	before := dirSize(t, tempDir)
+
+	// Heavy pruning triggers a compaction that reclaims the deleted space
+	for i := 0; i < 1900; i++ {
+		require.NoError(t, storage.Delete([]byte(fmt.Sprintf("key%04d", i))))
+	}
+	require.Eventually(t, func() bool {
+		return storage.GetStats()["compactions"].(int64) > compactions
+	}, 10*time.Second, 20*time.Millisecond)
+	assert.Less(t, dirSize(t, tempDir), before/2)
+	assert.Equal(t, int64(0), storage.GetStats()["deletions_since_compaction"])
+
+	for i := 0; i < 2000; i++ {
+		exists, err := storage.Has([]byte(fmt.Sprintf("key%04d", i)))
+		require.NoError(t, err)
+		assert.Equal(t, i >= 1900, exists)
+	}
+
+	_, err = NewLevelDBStorage(DefaultLevelDBStorageConfig().WithDataDir(t.TempDir()).WithCompactionInterval(-time.Second))
+	assert.Error(t, err)
+}
@@ -80,6 +80,18 @@ func (s *Storage) GetBlock(hash []byte) (*block.Block, error) {
 	return &b, nil
 }
 
+// DeleteBlock removes the file of a block. Deleting a block that is not
+// stored is not an error.
+func (s *Storage) DeleteBlock(hash []byte) error {
+	if len(hash) == 0 {
+		return fmt.Errorf("invalid hash: cannot be nil or empty")
+	}
+	if err := os.Remove(filepath.Join(s.dataDir, fmt.Sprintf("%x", hash))); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete block file: %w", err)
+	}
+	return nil
+}
+
 // ChainState represents the state of the blockchain.
 type ChainState struct {
 	BestBlockHash []byte `json:"best_block_hash"`
@@ -57,6 +57,31 @@ func TestFileStorage(t *testing.T) {
 	assert.Equal(t, state.Height, retrievedState.Height)
 }
 
+func TestDeleteBlock(t *testing.T) {
+	fileStorage, err := NewStorage(&StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer fileStorage.Close()
+	levelDBStorage, err := NewLevelDBStorage(&LevelDBStorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer levelDBStorage.Close()
+
+	for name, s := range map[string]interface {
+		StorageInterface
+		BlockPruner
+	}{"file": fileStorage, "leveldb": levelDBStorage} {
+		t.Run(name, func(t *testing.T) {
+			b := &block.Block{Header: &block.Header{Version: 1, Timestamp: time.Now(), Difficulty: 1, Height: 1}}
+			b.Header.MerkleRoot = b.CalculateMerkleRoot()
+			require.NoError(t, s.StoreBlock(b))
+
+			require.NoError(t, s.DeleteBlock(b.CalculateHash()))
+			_, err := s.GetBlock(b.CalculateHash())
+			assert.Error(t, err)
+			assert.NoError(t, s.DeleteBlock(b.CalculateHash()), "deleting a missing block is not an error")
+		})
+	}
+}
+
 // TestStorageErrorHandling tests comprehensive error scenarios
 func TestStorageErrorHandling(t *testing.T) {
 	// Test with invalid data directory (no write permissions)
@@ -0,0 +1,165 @@
+package sync
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/proto/net"
+)
+
+// BadHeaderBatchPenalty is the score adjustment applied to a peer that sends
+// a header batch failing validation.
+const BadHeaderBatchPenalty = -20
+
+// PeerPenalizer lowers the score of misbehaving peers, such as the
+// network's address book.
+type PeerPenalizer interface {
+	Penalize(id peer.ID, penalty int)
+}
+
+// SetPenalizer sets where peers sending invalid header batches are
+// penalized. Without it they are only recorded in their sync state.
+func (sp *SyncProtocol) SetPenalizer(penalizer PeerPenalizer) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.penalizer = penalizer
+}
+
+// maxHeadersPerMessage returns the configured limit on headers in one
+// message, falling back to MaxHeadersPerRequest.
+func (sp *SyncProtocol) maxHeadersPerMessage() uint64 {
+	if sp.config.MaxHeadersPerMessage == 0 {
+		return MaxHeadersPerRequest
+	}
+	return sp.config.MaxHeadersPerMessage
+}
+
+// HeaderChainWork returns the cumulative work, the sum of difficulties, of
+// the header batches accepted so far.
+func (sp *SyncProtocol) HeaderChainWork() *big.Int {
+	sp.headerMutex.RLock()
+	defer sp.headerMutex.RUnlock()
+	return new(big.Int).Set(sp.headerWork)
+}
+
+// processHeaderBatch validates a batch of headers received from a peer as a
+// whole and adds it to the header chain. The batch must not exceed
+// MaxHeadersPerMessage, its first header must extend a known header and
+// every following header its predecessor, and each header must add work.
+// A header extending a stored block must also pass the chain's header
+// validation. On the first failure the whole batch is rejected and the peer penalized.
+func (sp *SyncProtocol) processHeaderBatch(peerID peer.ID, headers []*net.BlockHeader) error {
+	if err := sp.validateHeaderBatch(headers); err != nil {
+		sp.penalize(peerID, err)
+		return fmt.Errorf("rejected header batch from %s: %w", peerID, err)
+	}
+	if len(headers) == 0 {
+		return nil
+	}
+
+	work := new(big.Int)
+	sp.headerMutex.Lock()
+	for _, header := range headers {
+		sp.headerCache[header.Height] = toBlockHeader(header)
+		work.Add(work, new(big.Int).SetUint64(header.Difficulty))
+	}
+	sp.headerWork.Add(sp.headerWork, work)
+	sp.headerMutex.Unlock()
+	return nil
+}
+
+// validateHeaderBatch checks the linkage and work of a header batch.
+func (sp *SyncProtocol) validateHeaderBatch(headers []*net.BlockHeader) error {
+	if limit := sp.maxHeadersPerMessage(); uint64(len(headers)) > limit {
+		return fmt.Errorf("batch has %d headers, more than the limit of %d", len(headers), limit)
+	}
+	if len(headers) == 0 {
+		return nil
+	}
+
+	first := headers[0]
+	if first.Height == 0 {
+		return fmt.Errorf("batch starts at the genesis height")
+	}
+	prev := sp.knownHeader(first.Height - 1)
+	if prev == nil {
+		return fmt.Errorf("batch starts at height %d without a known parent", first.Height)
+	}
+
+	for _, header := range headers {
+		blockHeader := toBlockHeader(header)
+		if err := blockHeader.IsValid(); err != nil {
+			return fmt.Errorf("invalid header at height %d: %w", header.Height, err)
+		}
+		if header.Height != prev.Height+1 {
+			return fmt.Errorf("header at height %d does not follow height %d", header.Height, prev.Height)
+		}
+		if !bytes.Equal(header.PrevBlockHash, headerHash(prev)) {
+			return fmt.Errorf("header at height %d does not link to its predecessor", header.Height)
+		}
+		if len(header.Hash) > 0 && !bytes.Equal(header.Hash, headerHash(blockHeader)) {
+			return fmt.Errorf("header at height %d does not match its hash", header.Height)
+		}
+		if header.Difficulty == 0 {
+			return fmt.Errorf("header at height %d adds no work", header.Height)
+		}
+		if err := sp.validateWithChain(blockHeader); err != nil {
+			return fmt.Errorf("invalid header at height %d: %w", header.Height, err)
+		}
+		prev = blockHeader
+	}
+	return nil
+}
+
+// knownHeader returns the header at height from the header chain, falling
+// back to the block chain.
+func (sp *SyncProtocol) knownHeader(height uint64) *block.Header {
+	sp.headerMutex.RLock()
+	header := sp.headerCache[height]
+	sp.headerMutex.RUnlock()
+	if header != nil {
+		return header
+	}
+	if b := sp.chain.GetBlockByHeight(height); b != nil {
+		return b.Header
+	}
+	return nil
+}
+
+// penalize records a peer's invalid header batch and lowers its score.
+func (sp *SyncProtocol) penalize(peerID peer.ID, reason error) {
+	sp.mu.Lock()
+	penalizer := sp.penalizer
+	if state := sp.syncState[peerID]; state != nil {
+		state.LastError = reason
+		state.Penalty += BadHeaderBatchPenalty
+	}
+	sp.mu.Unlock()
+
+	fmt.Printf("Penalizing peer %s for invalid headers: %v\n", peerID, reason)
+	if penalizer != nil {
+		penalizer.Penalize(peerID, BadHeaderBatchPenalty)
+	}
+}
+
+// toBlockHeader converts a received header to a block header.
+func toBlockHeader(header *net.BlockHeader) *block.Header {
+	return &block.Header{
+		Version:       header.Version,
+		PrevBlockHash: header.PrevBlockHash,
+		MerkleRoot:    header.MerkleRoot,
+		Timestamp:     time.Unix(header.Timestamp, 0),
+		Difficulty:    header.Difficulty,
+		Nonce:         header.Nonce,
+		Height:        header.Height,
+	}
+}
+
+// headerHash returns the hash of the block a header belongs to.
+func headerHash(header *block.Header) []byte {
+	return (&block.Block{Header: header}).CalculateHash()
+}
@@ -0,0 +1,174 @@
+package sync
+
+import (
+	"context"
+	"io"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/palaseus/adrenochain/pkg/block"
+	netproto "github.com/palaseus/adrenochain/pkg/proto/net"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// mockPenalizer records the penalties applied to peers.
+type mockPenalizer struct {
+	penalties map[peer.ID]int
+}
+
+func (mp *mockPenalizer) Penalize(id peer.ID, penalty int) {
+	mp.penalties[id] += penalty
+}
+
+// buildHeaderBatch returns count linked headers extending parent.
+func buildHeaderBatch(parent *block.Header, count int) []*netproto.BlockHeader {
+	headers := make([]*netproto.BlockHeader, 0, count)
+	prev := parent
+	for i := 0; i < count; i++ {
+		header := &block.Header{
+			Version:       1,
+			PrevBlockHash: headerHash(prev),
+			MerkleRoot:    make([]byte, 32),
+			Timestamp:     prev.Timestamp.Add(10 * time.Second),
+			Difficulty:    1000,
+			Nonce:         uint64(i),
+			Height:        prev.Height + 1,
+		}
+		headers = append(headers, &netproto.BlockHeader{
+			Version:       header.Version,
+			PrevBlockHash: header.PrevBlockHash,
+			MerkleRoot:    header.MerkleRoot,
+			Timestamp:     header.Timestamp.Unix(),
+			Difficulty:    header.Difficulty,
+			Nonce:         header.Nonce,
+			Height:        header.Height,
+			Hash:          headerHash(header),
+		})
+		prev = header
+	}
+	return headers
+}
+
+func TestProcessHeaderBatch(t *testing.T) {
+	host := createTestHost(t)
+	defer host.Close()
+
+	chain := NewMockChain()
+	config := DefaultSyncConfig()
+	config.MaxHeadersPerMessage = 10
+	sp := NewSyncProtocol(host, chain, chain, &MockStorage{}, config)
+	penalizer := &mockPenalizer{penalties: make(map[peer.ID]int)}
+	sp.SetPenalizer(penalizer)
+
+	badPeer := peer.ID("bad-peer")
+	goodPeer := peer.ID("good-peer")
+	sp.syncState[badPeer] = &PeerSyncState{PeerID: badPeer}
+	sp.syncState[goodPeer] = &PeerSyncState{PeerID: goodPeer}
+
+	tip := chain.GetBestBlock().Header
+
+	t.Run("broken linkage rejects the batch", func(t *testing.T) {
+		headers := buildHeaderBatch(tip, 5)
+		headers[3].PrevBlockHash = make([]byte, 32)
+
+		err := sp.processHeaderBatch(badPeer, headers)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "does not link to its predecessor")
+		for _, header := range headers {
+			assert.Nil(t, sp.GetHeaderFromCache(header.Height))
+		}
+		assert.Equal(t, BadHeaderBatchPenalty, penalizer.penalties[badPeer])
+		assert.Equal(t, BadHeaderBatchPenalty, sp.getPeerState(badPeer).Penalty)
+		assert.Zero(t, sp.HeaderChainWork().Sign())
+	})
+
+	t.Run("oversized batch is rejected", func(t *testing.T) {
+		err := sp.processHeaderBatch(badPeer, buildHeaderBatch(tip, 11))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "more than the limit of 10")
+		assert.Equal(t, 2*BadHeaderBatchPenalty, penalizer.penalties[badPeer])
+	})
+
+	t.Run("valid batches extend the header chain", func(t *testing.T) {
+		first := buildHeaderBatch(tip, 10)
+		require.NoError(t, sp.processHeaderBatch(goodPeer, first))
+
+		last := sp.GetHeaderFromCache(tip.Height + 10)
+		require.NotNil(t, last)
+		second := buildHeaderBatch(last, 5)
+		require.NoError(t, sp.processHeaderBatch(goodPeer, second))
+
+		assert.NotNil(t, sp.GetHeaderFromCache(tip.Height+15))
+		assert.Equal(t, big.NewInt(15*1000), sp.HeaderChainWork())
+		assert.Zero(t, penalizer.penalties[goodPeer])
+	})
+
+	t.Run("batch without a known parent is rejected", func(t *testing.T) {
+		orphan := &block.Header{Height: tip.Height + 50, Timestamp: tip.Timestamp}
+		err := sp.processHeaderBatch(badPeer, buildHeaderBatch(orphan, 2))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "without a known parent")
+	})
+}
+
+func TestSyncHeadersRejectsReceivedBatches(t *testing.T) {
+	local := createTestHost(t)
+	defer local.Close()
+	remote := createTestHost(t)
+	defer remote.Close()
+	require.NoError(t, local.Connect(context.Background(), peer.AddrInfo{ID: remote.ID(), Addrs: remote.Addrs()}))
+
+	chain := NewMockChain()
+	config := DefaultSyncConfig()
+	config.MaxHeadersPerMessage = 10
+	sp := NewSyncProtocol(local, chain, chain, &MockStorage{}, config)
+	penalizer := &mockPenalizer{penalties: make(map[peer.ID]int)}
+	sp.SetPenalizer(penalizer)
+
+	tip := chain.GetBestBlock().Header
+	sp.syncState[remote.ID()] = &PeerSyncState{PeerID: remote.ID(), Height: tip.Height + 10}
+
+	// The remote peer answers every header request with the given headers
+	serve := func(headers []*netproto.BlockHeader) {
+		remote.SetStreamHandler(protocol.ID(HeaderSyncProtocolID), func(s network.Stream) {
+			defer s.Close()
+			if _, err := s.Read(make([]byte, 1024)); err != nil && err != io.EOF {
+				return
+			}
+			data, err := proto.Marshal(&netproto.BlockHeadersResponse{Headers: headers})
+			if err != nil {
+				return
+			}
+			s.Write(data)
+		})
+	}
+
+	// More headers than a message may carry
+	serve(buildHeaderBatch(tip, 11))
+	err := sp.syncHeaders(remote.ID())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "more than the limit of 10")
+	assert.Equal(t, BadHeaderBatchPenalty, penalizer.penalties[remote.ID()])
+
+	// Headers that do not link up
+	broken := buildHeaderBatch(tip, 10)
+	broken[5].PrevBlockHash = make([]byte, 32)
+	serve(broken)
+	err = sp.syncHeaders(remote.ID())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not link to its predecessor")
+	assert.Equal(t, 2*BadHeaderBatchPenalty, penalizer.penalties[remote.ID()])
+	assert.Nil(t, sp.GetHeaderFromCache(tip.Height+1))
+
+	// A valid batch is accepted without a penalty
+	serve(buildHeaderBatch(tip, 10))
+	require.NoError(t, sp.syncHeaders(remote.ID()))
+	assert.NotNil(t, sp.GetHeaderFromCache(tip.Height+10))
+	assert.Equal(t, 2*BadHeaderBatchPenalty, sp.getPeerState(remote.ID()).Penalty)
+}
@@ -3,6 +3,8 @@ package sync
 import (
 	"context"
 	"fmt"
+	"io"
+	"math/big"
 	"os"
 	"runtime"
 	"strings"
@@ -10,6 +12,7 @@ import (
 	"time"
 
 	"github.com/palaseus/adrenochain/pkg/block"
+	netpkg "github.com/palaseus/adrenochain/pkg/net"
 	"github.com/palaseus/adrenochain/pkg/proto/net"
 	"github.com/palaseus/adrenochain/pkg/storage"
 	"github.com/libp2p/go-libp2p/core/host"
@@ -48,7 +51,13 @@ type SyncProtocol struct {
 
 	// Header storage for fast sync
 	headerCache map[uint64]*block.Header
+	headerWork  *big.Int // headerWork is the cumulative work of accepted header batches.
 	headerMutex sync.RWMutex
+
+	// penalizer lowers the score of peers sending invalid headers
+	penalizer PeerPenalizer
+	// uploads caps the rate responses are served to each peer
+	uploads UploadThrottle
 }
 
 // PeerSyncState tracks the sync state for a specific peer
@@ -64,6 +73,7 @@ type PeerSyncState struct {
 	LastError     error
 	RetryCount    int
 	SyncEnd       time.Time
+	Penalty       int // Penalty is the score lost for invalid header batches.
 }
 
 // NewSyncProtocol creates a new sync protocol instance
@@ -76,6 +86,7 @@ func NewSyncProtocol(host host.Host, chain ChainReader, chainWriter ChainWriter,
 		config:      config,
 		syncState:   make(map[peer.ID]*PeerSyncState),
 		headerCache: make(map[uint64]*block.Header),
+		headerWork:  new(big.Int),
 	}
 
 	sp.setupHandlers()
@@ -278,7 +289,7 @@ func (sp *SyncProtocol) syncHeaders(peerID peer.ID) error {
 
 	// Request headers in batches
 	for currentHeight < peerState.Height {
-		endHeight := currentHeight + MaxHeadersPerRequest
+		endHeight := currentHeight + sp.maxHeadersPerMessage()
 		if endHeight > peerState.Height {
 			endHeight = peerState.Height
 		}
@@ -293,12 +304,9 @@ func (sp *SyncProtocol) syncHeaders(peerID peer.ID) error {
 			return fmt.Errorf("failed to request headers: %w", err)
 		}
 
-		// Process headers
-		for _, header := range headers {
-			if err := sp.processHeader(header); err != nil {
-				fmt.Printf("Failed to process header at height %d: %v\n", header.Height, err)
-				continue
-			}
+		// Validate and store the headers as one batch
+		if err := sp.processHeaderBatch(peerID, headers); err != nil {
+			return err
 		}
 
 		currentHeight = endHeight
@@ -314,51 +322,74 @@ func (sp *SyncProtocol) syncHeaders(peerID peer.ID) error {
 	return nil
 }
 
-// syncBlocks synchronizes blocks with a peer
+// syncBlocks synchronizes blocks with a peer. The blocks are downloaded in
+// parallel from the peer and every other peer known to have reached its
+// height; a stalled peer is evicted and its blocks fetched elsewhere.
 func (sp *SyncProtocol) syncBlocks(peerID peer.ID) error {
 	currentHeight := sp.chain.GetHeight()
 	peerState := sp.getPeerState(peerID)
 	if peerState == nil {
 		return fmt.Errorf("peer state not found")
 	}
+	if currentHeight >= peerState.Height {
+		return nil
+	}
 
-	// Request blocks in batches
-	for currentHeight < peerState.Height {
-		endHeight := currentHeight + MaxBlocksPerRequest
-		if endHeight > peerState.Height {
-			endHeight = peerState.Height
+	downloader := netpkg.NewBlockDownloader(sp.config.StallTimeout, sp.fetchBlocks, func(height uint64, data []byte) error {
+		if err := sp.processBlock(data); err != nil {
+			return fmt.Errorf("failed to process block at height %d: %w", height, err)
 		}
 
-		// Request each block in the range
-		for height := currentHeight + 1; height <= endHeight; height++ {
-			blockReq := &net.BlockRequest{
-				Height: height,
-			}
+		// Update progress
+		sp.mu.Lock()
+		if state := sp.syncState[peerID]; state != nil {
+			state.BlocksSynced++
+		}
+		sp.mu.Unlock()
+		return nil
+	})
+	downloader.SetBatchSize(MaxBlocksPerRequest)
+	downloader.SetOnEvict(func(p peer.ID) {
+		sp.mu.Lock()
+		defer sp.mu.Unlock()
+		if state := sp.syncState[p]; state != nil {
+			state.LastError = fmt.Errorf("evicted from block download")
+		}
+	})
 
-			blockData, err := sp.requestBlock(peerID, blockReq)
-			if err != nil {
-				fmt.Printf("Failed to request block at height %d: %v\n", height, err)
-				continue
-			}
+	return downloader.Download(context.Background(), sp.downloadPeers(peerID, peerState.Height), currentHeight+1, peerState.Height)
+}
 
-			// Process block
-			if err := sp.processBlock(blockData); err != nil {
-				fmt.Printf("Failed to process block at height %d: %v\n", height, err)
-				continue
-			}
+// downloadPeers returns the peers to download blocks up to height from:
+// peerID followed by every other peer known to have reached height.
+func (sp *SyncProtocol) downloadPeers(peerID peer.ID, height uint64) []peer.ID {
+	sp.mu.RLock()
+	defer sp.mu.RUnlock()
 
-			// Update progress
-			sp.mu.Lock()
-			if state := sp.syncState[peerID]; state != nil {
-				state.BlocksSynced++
-			}
-			sp.mu.Unlock()
+	peers := []peer.ID{peerID}
+	for id, state := range sp.syncState {
+		if id != peerID && state.Height >= height {
+			peers = append(peers, id)
 		}
-
-		currentHeight = endHeight
 	}
+	return peers
+}
 
-	return nil
+// fetchBlocks requests the blocks at heights start to start+count-1 from a
+// peer, one at a time.
+func (sp *SyncProtocol) fetchBlocks(ctx context.Context, peerID peer.ID, start, count uint64) ([][]byte, error) {
+	blocks := make([][]byte, 0, count)
+	for height := start; height < start+count; height++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		blockData, err := sp.requestBlock(peerID, &net.BlockRequest{Height: height})
+		if err != nil {
+			return nil, fmt.Errorf("failed to request block at height %d: %w", height, err)
+		}
+		blocks = append(blocks, blockData)
+	}
+	return blocks, nil
 }
 
 // syncStateData synchronizes state with a peer
@@ -470,6 +501,9 @@ func (sp *SyncProtocol) processHeader(header *net.BlockHeader) error {
 	if err := blockHeader.IsValid(); err != nil {
 		return fmt.Errorf("invalid header: %w", err)
 	}
+	if err := sp.validateWithChain(blockHeader); err != nil {
+		return fmt.Errorf("invalid header: %w", err)
+	}
 
 	// Store header in cache for fast sync
 	sp.headerMutex.Lock()
@@ -479,6 +513,17 @@ func (sp *SyncProtocol) processHeader(header *net.BlockHeader) error {
 	return nil
 }
 
+// validateWithChain has the chain validate a header extending a stored
+// block, so that its block skips the header checks when it arrives. Other
+// headers are left to be validated with their blocks.
+func (sp *SyncProtocol) validateWithChain(header *block.Header) error {
+	validator, ok := sp.chainWriter.(HeaderValidator)
+	if !ok || sp.chain.GetBlock(header.PrevBlockHash) == nil {
+		return nil
+	}
+	return validator.ValidateHeader(header)
+}
+
 // processBlock processes a received block
 func (sp *SyncProtocol) processBlock(blockData []byte) error {
 	// Deserialize the block
@@ -555,6 +600,33 @@ func (sp *SyncProtocol) getPeerState(peerID peer.ID) *PeerSyncState {
 }
 
 // Protocol handlers
+// UploadThrottle caps the rate data is sent to each peer, such as the
+// network's per-peer upload limit.
+type UploadThrottle interface {
+	UploadWriter(ctx context.Context, p peer.ID, w io.Writer) io.Writer
+}
+
+// SetUploadThrottle sets the per-peer upload cap the headers, blocks and
+// state served to peers are sent within. Without it responses are sent as
+// fast as the stream allows.
+func (sp *SyncProtocol) SetUploadThrottle(uploads UploadThrottle) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.uploads = uploads
+}
+
+// responseWriter returns the writer a response is sent to the peer of a
+// request stream through, throttled to its upload cap.
+func (sp *SyncProtocol) responseWriter(stream network.Stream) io.Writer {
+	sp.mu.RLock()
+	uploads := sp.uploads
+	sp.mu.RUnlock()
+	if uploads == nil {
+		return stream
+	}
+	return uploads.UploadWriter(context.Background(), stream.Conn().RemotePeer(), stream)
+}
+
 func (sp *SyncProtocol) handleSyncRequest(stream network.Stream) {
 	defer stream.Close()
 
@@ -587,7 +659,7 @@ func (sp *SyncProtocol) handleSyncRequest(stream network.Stream) {
 		return
 	}
 
-	if _, err := stream.Write(response); err != nil {
+	if _, err := sp.responseWriter(stream).Write(response); err != nil {
 		fmt.Printf("Failed to write sync response: %v\n", err)
 		return
 	}
@@ -610,8 +682,12 @@ func (sp *SyncProtocol) handleHeaderRequest(stream network.Stream) {
 		return
 	}
 
-	// Get headers
-	headers := sp.getHeaders(headersReq.StartHeight, headersReq.Count)
+	// Get headers, no more than fit in one message
+	count := headersReq.Count
+	if limit := sp.maxHeadersPerMessage(); count > limit {
+		count = limit
+	}
+	headers := sp.getHeaders(headersReq.StartHeight, count)
 
 	// Create response
 	headersResp := &net.BlockHeadersResponse{
@@ -626,7 +702,7 @@ func (sp *SyncProtocol) handleHeaderRequest(stream network.Stream) {
 		return
 	}
 
-	if _, err := stream.Write(response); err != nil {
+	if _, err := sp.responseWriter(stream).Write(response); err != nil {
 		fmt.Printf("Failed to write headers response: %v\n", err)
 		return
 	}
@@ -672,7 +748,7 @@ func (sp *SyncProtocol) handleBlockRequest(stream network.Stream) {
 		return
 	}
 
-	if _, err := stream.Write(response); err != nil {
+	if _, err := sp.responseWriter(stream).Write(response); err != nil {
 		fmt.Printf("Failed to write block response: %v\n", err)
 		return
 	}
@@ -709,7 +785,7 @@ func (sp *SyncProtocol) handleStateRequest(stream network.Stream) {
 		return
 	}
 
-	if _, err := stream.Write(response); err != nil {
+	if _, err := sp.responseWriter(stream).Write(response); err != nil {
 		fmt.Printf("Failed to write state response: %v\n", err)
 		return
 	}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"math/big"
 	"os"
 	"sync"
@@ -16,6 +17,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/protocol"
 	"github.com/palaseus/adrenochain/pkg/block"
+	netpkg "github.com/palaseus/adrenochain/pkg/net"
 	netproto "github.com/palaseus/adrenochain/pkg/proto/net"
 	"github.com/palaseus/adrenochain/pkg/storage"
 	"github.com/stretchr/testify/assert"
@@ -1937,3 +1939,59 @@ func TestLowCoverageFunctions(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestDownloadPeersIncludesPeersAtHeight(t *testing.T) {
+	host := createTestHost(t)
+	defer host.Close()
+
+	sp := NewSyncProtocol(host, NewMockChain(), NewMockChain(), &MockStorage{}, DefaultSyncConfig())
+
+	target, ahead, behind := peer.ID("target"), peer.ID("ahead"), peer.ID("behind")
+	sp.mu.Lock()
+	sp.syncState[target] = &PeerSyncState{PeerID: target, Height: 200}
+	sp.syncState[ahead] = &PeerSyncState{PeerID: ahead, Height: 250}
+	sp.syncState[behind] = &PeerSyncState{PeerID: behind, Height: 150}
+	sp.mu.Unlock()
+
+	assert.Equal(t, []peer.ID{target, ahead}, sp.downloadPeers(target, 200))
+}
+
+// The network's per-peer upload cap can throttle sync responses
+var _ UploadThrottle = (*netpkg.Network)(nil)
+
+// recordingThrottle records the peers responses were sent to.
+type recordingThrottle struct {
+	mu    sync.Mutex
+	peers []peer.ID
+}
+
+func (rt *recordingThrottle) UploadWriter(ctx context.Context, p peer.ID, w io.Writer) io.Writer {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.peers = append(rt.peers, p)
+	return w
+}
+
+func TestResponsesUseUploadThrottle(t *testing.T) {
+	server := createTestHost(t)
+	defer server.Close()
+	client := createTestHost(t)
+	defer client.Close()
+	require.NoError(t, client.Connect(context.Background(), peer.AddrInfo{ID: server.ID(), Addrs: server.Addrs()}))
+
+	chain := NewMockChain()
+	serving := NewSyncProtocol(server, chain, chain, &MockStorage{}, DefaultSyncConfig())
+	throttle := &recordingThrottle{}
+	serving.SetUploadThrottle(throttle)
+	requesting := NewSyncProtocol(client, NewMockChain(), NewMockChain(), &MockStorage{}, DefaultSyncConfig())
+
+	// Blocks and headers are both sent through the requesting peer's cap
+	_, err := requesting.requestBlock(server.ID(), &netproto.BlockRequest{Height: 1})
+	require.NoError(t, err)
+	_, err = requesting.requestHeaders(server.ID(), &netproto.BlockHeadersRequest{StartHeight: 1, Count: 5})
+	require.NoError(t, err)
+
+	throttle.mu.Lock()
+	defer throttle.mu.Unlock()
+	assert.Equal(t, []peer.ID{client.ID(), client.ID()}, throttle.peers)
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/palaseus/adrenochain/pkg/block"
 	"github.com/palaseus/adrenochain/pkg/chain"
+	netpkg "github.com/palaseus/adrenochain/pkg/net"
 	"github.com/palaseus/adrenochain/pkg/storage"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -27,6 +28,12 @@ type ChainWriter interface {
 	AddBlock(block interface{}) error
 }
 
+// HeaderValidator is implemented by chain writers that validate headers
+// before their blocks are downloaded
+type HeaderValidator interface {
+	ValidateHeader(header *block.Header) error
+}
+
 // BlockInterface defines the interface that blocks must implement for sync operations
 type BlockInterface interface {
 	Serialize() ([]byte, error)
@@ -86,6 +93,12 @@ func (ca *ChainAdapter) AddBlock(blockData interface{}) error {
 	return fmt.Errorf("invalid block type")
 }
 
+// ValidateHeader validates a header ahead of its block, so that the chain
+// skips the header checks when the block arrives
+func (ca *ChainAdapter) ValidateHeader(header *block.Header) error {
+	return ca.chain.ValidateHeader(header)
+}
+
 // SyncManager manages blockchain synchronization between nodes.
 // It implements fast sync, light client sync, and state synchronization protocols.
 type SyncManager struct {
@@ -116,6 +129,15 @@ type SyncConfig struct {
 	BlockDownloadLimit uint64        // BlockDownloadLimit is the maximum blocks to download per request
 	StateSyncEnabled   bool          // StateSyncEnabled enables state synchronization
 	CheckpointInterval uint64        // CheckpointInterval is the height interval for checkpoints
+
+	// MaxHeadersPerMessage is the most headers requested or served in one
+	// message; larger batches from peers are rejected. Zero uses
+	// MaxHeadersPerRequest.
+	MaxHeadersPerMessage uint64
+	// StallTimeout is how long block download may go without applying a
+	// block before the slowest peer is evicted. Zero uses
+	// net.DefaultStallTimeout.
+	StallTimeout time.Duration
 }
 
 // DefaultSyncConfig returns the default synchronization configuration.
@@ -130,6 +152,9 @@ func DefaultSyncConfig() *SyncConfig {
 		BlockDownloadLimit: 1000,
 		StateSyncEnabled:   true,
 		CheckpointInterval: 10000,
+
+		MaxHeadersPerMessage: MaxHeadersPerRequest,
+		StallTimeout:         netpkg.DefaultStallTimeout,
 	}
 }
 
@@ -175,12 +175,19 @@ func NewLiveNode(config *LiveNodeConfig) (*LiveNode, error) {
 
 	// Initialize API server
 	apiConfig := &api.ServerConfig{
-		Port:    config.APIPort,
-		Chain:   nodeChain,
-		Wallet:  nodeWallet,
-		Network: nodeNetwork,
+		Port:            config.APIPort,
+		Chain:           nodeChain,
+		Wallet:          nodeWallet,
+		Network:         nodeNetwork,
+		UTXOSet:         utxoSet,
+		WalletEndpoints: true,
+	}
+	apiServer, err := api.NewServer(apiConfig)
+	if err != nil {
+		cancel()
+		nodeStorage.Close()
+		return nil, fmt.Errorf("failed to create API server: %w", err)
 	}
-	apiServer := api.NewServer(apiConfig)
 
 	node := &LiveNode{
 		ID:        config.NodeID,
@@ -0,0 +1,74 @@
+package utxo
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInconsistentUTXOSet is returned by Audit when the UTXO set violates one
+// of its internal invariants.
+var ErrInconsistentUTXOSet = errors.New("inconsistent UTXO set")
+
+// Audit verifies the invariants between the UTXOs and the per-address
+// balances kept alongside them: the balances must add up to the total value
+// of the UTXOs, there must be exactly one non-empty balance per address
+// holding value, and every balance must equal the value of the address's UTXOs.
+// Spilled UTXOs are read back from the store without loading them into
+// memory. It returns an error wrapping ErrInconsistentUTXOSet describing
+// every violation found.
+func (us *UTXOSet) Audit() error {
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+
+	var errs []error
+	held := make(map[string]uint64)
+	var utxoTotal uint64
+	for _, utxo := range us.utxos {
+		held[utxo.Address] += utxo.Value
+		utxoTotal += utxo.Value
+	}
+	for key := range us.spilled {
+		utxo := us.readSpilled(key)
+		if utxo == nil {
+			errs = append(errs, fmt.Errorf("spilled UTXO %s cannot be read", key))
+			continue
+		}
+		held[utxo.Address] += utxo.Value
+		utxoTotal += utxo.Value
+	}
+
+	var balanceTotal uint64
+	entries := 0
+	for _, balance := range us.balances {
+		balanceTotal += balance
+		if balance > 0 {
+			entries++
+		}
+	}
+	if balanceTotal != utxoTotal {
+		errs = append(errs, fmt.Errorf("balances total %d but UTXOs hold %d", balanceTotal, utxoTotal))
+	}
+
+	holders := 0
+	mismatched := 0
+	for address, value := range held {
+		if value == 0 {
+			continue
+		}
+		holders++
+		if us.balances[address] != value {
+			mismatched++
+		}
+	}
+	if holders != entries {
+		errs = append(errs, fmt.Errorf("%d addresses hold UTXOs but there are %d non-empty balance entries", holders, entries))
+	}
+	if mismatched > 0 {
+		errs = append(errs, fmt.Errorf("%d address balances differ from the value of their UTXOs", mismatched))
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrInconsistentUTXOSet, errors.Join(errs...))
+}
@@ -0,0 +1,59 @@
+package utxo
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUTXOSetAudit(t *testing.T) {
+	us := NewUTXOSet()
+	for i := 0; i < 4; i++ {
+		us.AddUTXOSafe(NewUTXO([]byte(fmt.Sprintf("tx_%d", i)), 0, 1000, []byte{byte(i % 2)}, fmt.Sprintf("addr_%d", i%2), false, 1))
+	}
+	us.RemoveUTXOSafe([]byte("tx_0"), 0)
+	require.NoError(t, us.Audit())
+
+	// A balance drifting from the UTXOs it is derived from is detected
+	us.mu.Lock()
+	us.balances["addr_1"] += 500
+	us.mu.Unlock()
+	err := us.Audit()
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrInconsistentUTXOSet))
+	assert.ErrorContains(t, err, "balances total 3500 but UTXOs hold 3000")
+	assert.ErrorContains(t, err, "1 address balances differ")
+
+	// So is a stale balance of an address without UTXOs
+	us.mu.Lock()
+	us.balances["addr_1"] -= 500
+	us.balances["addr_gone"] = 500
+	us.mu.Unlock()
+	err = us.Audit()
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "2 addresses hold UTXOs but there are 3 non-empty balance entries")
+}
+
+func TestCachedUTXOSetAudit(t *testing.T) {
+	store, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer store.Close()
+
+	us := NewCachedUTXOSet(store, 1)
+	for i := 0; i < 3; i++ {
+		us.AddUTXOSafe(NewUTXO([]byte(fmt.Sprintf("tx_%d", i)), 0, 1000, []byte{0xaa}, "aa", false, 1))
+	}
+	require.Equal(t, 2, us.SpilledCount())
+	require.NoError(t, us.Audit())
+
+	// Losing a spilled UTXO from the store is detected
+	require.NoError(t, store.Delete([]byte(spillKeyPrefix+us.makeKey([]byte("tx_0"), 0))))
+	err = us.Audit()
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "cannot be read")
+	assert.ErrorContains(t, err, "balances total 3000 but UTXOs hold 2000")
+}
@@ -0,0 +1,78 @@
+package utxo
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+)
+
+// BlockUTXOView is a UTXOView over a base set with the effects of the
+// transactions of a block applied so far, used to validate a block's
+// transactions in order: outputs created by earlier transactions are visible
+// and outputs they spent are hidden. The base set is never modified.
+type BlockUTXOView struct {
+	base    UTXOView
+	height  uint64
+	created map[string]*UTXO    // created holds unspent outputs of applied transactions.
+	spent   map[string]struct{} // spent holds outputs consumed by applied transactions.
+}
+
+// NewBlockUTXOView creates an empty view over base for a block at height.
+func NewBlockUTXOView(base UTXOView, height uint64) *BlockUTXOView {
+	return &BlockUTXOView{
+		base:    base,
+		height:  height,
+		created: make(map[string]*UTXO),
+		spent:   make(map[string]struct{}),
+	}
+}
+
+// GetUTXO returns the unspent output identified by txHash and txIndex, or nil
+// if it does not exist or was spent by an applied transaction.
+func (v *BlockUTXOView) GetUTXO(txHash []byte, txIndex uint32) *UTXO {
+	key := outpointKey(txHash, txIndex)
+	if _, spent := v.spent[key]; spent {
+		return nil
+	}
+	if u, exists := v.created[key]; exists {
+		return u
+	}
+	if v.base == nil {
+		return nil
+	}
+	return v.base.GetUTXO(txHash, txIndex)
+}
+
+// Apply records the inputs spent and the outputs created by tx, making its
+// outputs spendable by the transactions that follow it in the block.
+func (v *BlockUTXOView) Apply(tx *block.Transaction) {
+	for _, input := range tx.Inputs {
+		if len(input.PrevTxHash) == 0 {
+			continue
+		}
+		key := outpointKey(input.PrevTxHash, input.PrevTxIndex)
+		delete(v.created, key)
+		v.spent[key] = struct{}{}
+	}
+	for i, output := range tx.Outputs {
+		if output.IsData() {
+			continue
+		}
+		v.created[outpointKey(tx.Hash, uint32(i))] = &UTXO{
+			TxHash:       tx.Hash,
+			TxIndex:      uint32(i),
+			Value:        output.Value,
+			ScriptPubKey: output.ScriptPubKey,
+			Address:      hex.EncodeToString(output.ScriptPubKey),
+			IsCoinbase:   len(tx.Inputs) == 0,
+			Height:       v.height,
+		}
+	}
+}
+
+// outpointKey builds the map key for a transaction output reference, in the
+// same format as the UTXO set keys.
+func outpointKey(txHash []byte, txIndex uint32) string {
+	return fmt.Sprintf("%x:%d", txHash, txIndex)
+}
@@ -0,0 +1,58 @@
+package utxo
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/crypto_utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockUTXOView(t *testing.T) {
+	ctu := crypto_utils.NewCryptoTestUtils(t)
+	us := NewUTXOSet()
+
+	alice := ctu.GenerateTestKeyPair()
+	bob := ctu.GenerateTestKeyPair()
+	bobScript, err := hex.DecodeString(bob.Address)
+	require.NoError(t, err)
+
+	funding := createTestUTXO("block_view_funding", 0, 1000, alice, false, 1)
+	us.AddUTXOSafe(funding)
+
+	tx1 := ctu.CreateSignedTransaction(
+		[]*block.TxInput{{PrevTxHash: funding.TxHash, PrevTxIndex: 0, Sequence: 0xffffffff}},
+		[]*block.TxOutput{{Value: 900, ScriptPubKey: bobScript}},
+		map[string]*crypto_utils.TestKeyPair{alice.Address: alice}, 100)
+	tx2 := ctu.CreateSignedTransaction(
+		[]*block.TxInput{{PrevTxHash: tx1.Hash, PrevTxIndex: 0, Sequence: 0xffffffff}},
+		[]*block.TxOutput{{Value: 800, ScriptPubKey: []byte("carol")}},
+		map[string]*crypto_utils.TestKeyPair{bob.Address: bob}, 100)
+
+	view := NewBlockUTXOView(us, 2)
+	require.NoError(t, us.ValidateTransactionWithView(tx1, view))
+
+	// tx2 cannot be validated before tx1 is applied
+	err = us.ValidateTransactionWithView(tx2, view)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "input UTXO not found")
+
+	view.Apply(tx1)
+	assert.Nil(t, view.GetUTXO(funding.TxHash, 0))
+	created := view.GetUTXO(tx1.Hash, 0)
+	require.NotNil(t, created)
+	assert.Equal(t, uint64(2), created.Height)
+	require.NoError(t, us.ValidateTransactionWithView(tx2, view))
+
+	// A second spend of the same output within the block is rejected
+	view.Apply(tx2)
+	assert.Nil(t, view.GetUTXO(tx1.Hash, 0))
+	assert.NotNil(t, view.GetUTXO(tx2.Hash, 0))
+	assert.Error(t, us.ValidateTransactionWithView(tx2, view))
+
+	// The base set is left untouched
+	assert.NotNil(t, us.GetUTXO(funding.TxHash, 0))
+	assert.Nil(t, us.GetUTXO(tx1.Hash, 0))
+}
@@ -0,0 +1,152 @@
+package utxo
+
+import (
+	"container/list"
+	"encoding/json"
+)
+
+// spillKeyPrefix prefixes the store keys of UTXOs spilled out of memory.
+const spillKeyPrefix = "utxo_spill_"
+
+// SpillStore is the key-value store cold UTXOs are moved to once the
+// in-memory cache of a capped UTXO set is full. storage.StorageInterface
+// satisfies it.
+type SpillStore interface {
+	Write(key []byte, value []byte) error
+	Read(key []byte) ([]byte, error)
+	Delete(key []byte) error
+}
+
+// NewCachedUTXOSet creates a UTXO set that keeps at most maxEntries UTXOs in
+// memory. The least recently used UTXOs beyond that are written to store and
+// transparently loaded back when accessed; balances and an index of the
+// spilled outpoints stay in memory. A maxEntries of zero or less, or a nil
+// store, creates an uncapped set.
+func NewCachedUTXOSet(store SpillStore, maxEntries int) *UTXOSet {
+	us := NewUTXOSet()
+	if store == nil || maxEntries <= 0 {
+		return us
+	}
+	us.spill = store
+	us.maxEntries = maxEntries
+	us.lru = list.New()
+	us.lruElems = make(map[string]*list.Element)
+	us.spilled = make(map[string]string)
+	return us
+}
+
+// getLocked returns the UTXO stored under key, loading it back into memory
+// if it was spilled. The caller must hold the write lock when the set is
+// capped.
+func (us *UTXOSet) getLocked(key string) *UTXO {
+	if utxo, exists := us.utxos[key]; exists {
+		if us.lru != nil {
+			us.lru.MoveToFront(us.lruElems[key])
+		}
+		return utxo
+	}
+	if _, spilled := us.spilled[key]; !spilled {
+		return nil
+	}
+
+	utxo := us.readSpilled(key)
+	if utxo == nil {
+		return nil
+	}
+	us.spill.Delete([]byte(spillKeyPrefix + key))
+	delete(us.spilled, key)
+	us.putLocked(key, utxo)
+	return utxo
+}
+
+// putLocked stores a UTXO in memory as the most recently used entry and
+// spills the least recently used ones beyond the cap.
+func (us *UTXOSet) putLocked(key string, utxo *UTXO) {
+	us.utxos[key] = utxo
+	if us.lru == nil {
+		return
+	}
+	if _, spilled := us.spilled[key]; spilled {
+		us.spill.Delete([]byte(spillKeyPrefix + key))
+		delete(us.spilled, key)
+	}
+	if elem, exists := us.lruElems[key]; exists {
+		us.lru.MoveToFront(elem)
+	} else {
+		us.lruElems[key] = us.lru.PushFront(key)
+	}
+
+	for len(us.utxos) > us.maxEntries {
+		oldest := us.lru.Back()
+		coldKey := oldest.Value.(string)
+		cold := us.utxos[coldKey]
+		data, err := json.Marshal(cold)
+		if err != nil || us.spill.Write([]byte(spillKeyPrefix+coldKey), data) != nil {
+			// Keep the UTXO in memory rather than lose it; the cap is
+			// exceeded until the store accepts writes again
+			return
+		}
+		us.lru.Remove(oldest)
+		delete(us.lruElems, coldKey)
+		delete(us.utxos, coldKey)
+		us.spilled[coldKey] = cold.Address
+	}
+}
+
+// deleteLocked removes the in-memory or spilled UTXO stored under key.
+func (us *UTXOSet) deleteLocked(key string) {
+	delete(us.utxos, key)
+	if us.lru == nil {
+		return
+	}
+	if elem, exists := us.lruElems[key]; exists {
+		us.lru.Remove(elem)
+		delete(us.lruElems, key)
+	}
+	if _, spilled := us.spilled[key]; spilled {
+		us.spill.Delete([]byte(spillKeyPrefix + key))
+		delete(us.spilled, key)
+	}
+}
+
+// readSpilled reads a spilled UTXO from the store without loading it into
+// memory. It returns nil if the UTXO cannot be read.
+func (us *UTXOSet) readSpilled(key string) *UTXO {
+	data, err := us.spill.Read([]byte(spillKeyPrefix + key))
+	if err != nil {
+		return nil
+	}
+	var utxo UTXO
+	if err := json.Unmarshal(data, &utxo); err != nil {
+		return nil
+	}
+	return &utxo
+}
+
+// addressUTXOsLocked returns the in-memory and spilled UTXOs of an address
+// matching keep, without changing which UTXOs are held in memory.
+func (us *UTXOSet) addressUTXOsLocked(address string, keep func(*UTXO) bool) []*UTXO {
+	var utxos []*UTXO
+	for _, utxo := range us.utxos {
+		if utxo.Address == address && keep(utxo) {
+			utxos = append(utxos, utxo)
+		}
+	}
+	for key, spilledAddress := range us.spilled {
+		if spilledAddress != address {
+			continue
+		}
+		if utxo := us.readSpilled(key); utxo != nil && keep(utxo) {
+			utxos = append(utxos, utxo)
+		}
+	}
+	return utxos
+}
+
+// SpilledCount returns the number of UTXOs currently held in the spill
+// store rather than in memory.
+func (us *UTXOSet) SpilledCount() int {
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+	return len(us.spilled)
+}
@@ -0,0 +1,79 @@
+package utxo
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachedUTXOSetSpillover(t *testing.T) {
+	store, err := storage.NewStorage(&storage.StorageConfig{DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer store.Close()
+
+	us := NewCachedUTXOSet(store, 2)
+	hashes := make([][]byte, 5)
+	for i := range hashes {
+		hashes[i] = []byte(fmt.Sprintf("tx_%d", i))
+		us.AddUTXOSafe(NewUTXO(hashes[i], 0, uint64(1000*(i+1)), []byte{0xaa}, "aa", false, uint64(i)))
+	}
+
+	// Only the two most recently added UTXOs stay in memory
+	assert.Equal(t, 3, us.SpilledCount())
+	assert.Equal(t, 5, us.GetUTXOCount())
+	assert.Equal(t, uint64(15000), us.GetBalance("aa"))
+	assert.Equal(t, uint64(15000), us.GetStats()["total_value"])
+	assert.Len(t, us.GetAddressUTXOs("aa"), 5)
+	assert.Len(t, us.GetSpendableUTXOs("aa", 3000), 3)
+	assert.Equal(t, 3, us.SpilledCount(), "listing UTXOs must not load them into memory")
+
+	// An evicted UTXO is loaded back with all its fields
+	u := us.GetUTXO(hashes[0], 0)
+	require.NotNil(t, u)
+	assert.Equal(t, hashes[0], u.TxHash)
+	assert.Equal(t, uint64(1000), u.Value)
+	assert.Equal(t, []byte{0xaa}, u.ScriptPubKey)
+	assert.Equal(t, "aa", u.Address)
+	assert.Equal(t, 3, us.SpilledCount())
+	assert.Nil(t, us.GetUTXO([]byte("missing"), 0))
+
+	// Spending spilled UTXOs removes them from the store as well
+	spend := &block.Transaction{
+		Version: 1,
+		Inputs: []*block.TxInput{
+			{PrevTxHash: hashes[1], PrevTxIndex: 0},
+			{PrevTxHash: hashes[2], PrevTxIndex: 0},
+		},
+		Outputs: []*block.TxOutput{{Value: 4000, ScriptPubKey: []byte{0xbb}}},
+		Hash:    []byte("spend"),
+	}
+	view := NewBlockUTXOView(us, 5)
+	require.NotNil(t, view.GetUTXO(hashes[1], 0))
+	require.NotNil(t, view.GetUTXO(hashes[2], 0))
+
+	require.NoError(t, us.ProcessBlock(&block.Block{
+		Header:       &block.Header{Height: 5},
+		Transactions: []*block.Transaction{spend},
+	}))
+	assert.Nil(t, us.GetUTXO(hashes[1], 0))
+	assert.Nil(t, us.GetUTXO(hashes[2], 0))
+	assert.Equal(t, 4, us.GetUTXOCount())
+	assert.Equal(t, uint64(10000), us.GetBalance("aa"))
+	assert.Equal(t, uint64(4000), us.GetBalance("bb"))
+	assert.LessOrEqual(t, us.GetUTXOCount()-us.SpilledCount(), 2)
+
+	for _, hash := range [][]byte{hashes[1], hashes[2]} {
+		has, err := store.Has([]byte(spillKeyPrefix + us.makeKey(hash, 0)))
+		require.NoError(t, err)
+		assert.False(t, has)
+	}
+
+	us.Reset()
+	assert.Equal(t, 0, us.GetUTXOCount())
+	assert.Equal(t, 0, us.SpilledCount())
+	assert.Nil(t, us.GetUTXO(hashes[0], 0))
+}
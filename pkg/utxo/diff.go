@@ -0,0 +1,104 @@
+package utxo
+
+import (
+	"fmt"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+)
+
+// BlockDiff is the effect of a block on the UTXO set: the outputs it created
+// that are still unspent after the block, and the outputs of earlier blocks
+// it spent. Outputs created and spent within the block appear in neither.
+// Spent holds the full outputs, so the diff is also the undo data needed to
+// disconnect the block.
+type BlockDiff struct {
+	BlockHash []byte  `json:"block_hash"`
+	Height    uint64  `json:"height"`
+	Created   []*UTXO `json:"created"`
+	Spent     []*UTXO `json:"spent"`
+}
+
+// ApplyBlock processes a block like ProcessBlock and returns its diff.
+func (us *UTXOSet) ApplyBlock(block *block.Block) (*BlockDiff, error) {
+	if block == nil {
+		return nil, fmt.Errorf("block is nil")
+	}
+	if block.Header == nil {
+		return nil, fmt.Errorf("block header is nil")
+	}
+	if err := checkIntraBlockOrder(block.Transactions); err != nil {
+		return nil, err
+	}
+
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	// Process each transaction in the block
+	diff := &BlockDiff{}
+	for _, tx := range block.Transactions {
+		if err := us.applyTransaction(tx, block.Header.Height, diff); err != nil {
+			return nil, fmt.Errorf("failed to process transaction: %w", err)
+		}
+	}
+	diff.net()
+	diff.BlockHash = block.CalculateHash()
+	diff.Height = block.Header.Height
+
+	return diff, nil
+}
+
+// net drops the outputs both created and spent by the block.
+func (d *BlockDiff) net() {
+	created := make(map[string]struct{}, len(d.Created))
+	for _, u := range d.Created {
+		created[outpointKey(u.TxHash, u.TxIndex)] = struct{}{}
+	}
+	intraBlock := make(map[string]struct{})
+	spent := d.Spent[:0]
+	for _, u := range d.Spent {
+		key := outpointKey(u.TxHash, u.TxIndex)
+		if _, exists := created[key]; exists {
+			intraBlock[key] = struct{}{}
+			continue
+		}
+		spent = append(spent, u)
+	}
+	d.Spent = spent
+
+	if len(intraBlock) == 0 {
+		return
+	}
+	unspent := d.Created[:0]
+	for _, u := range d.Created {
+		if _, exists := intraBlock[outpointKey(u.TxHash, u.TxIndex)]; !exists {
+			unspent = append(unspent, u)
+		}
+	}
+	d.Created = unspent
+}
+
+// RevertBlock undoes the diff of the last block applied to the set, removing
+// the outputs it created and restoring the outputs it spent. The set is left
+// unchanged if a created output is missing, i.e. was spent by a later block
+// that has not been reverted first.
+func (us *UTXOSet) RevertBlock(diff *BlockDiff) error {
+	if diff == nil {
+		return fmt.Errorf("diff is nil")
+	}
+
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	for _, u := range diff.Created {
+		if us.getLocked(us.makeKey(u.TxHash, u.TxIndex)) == nil {
+			return fmt.Errorf("output %x:%d created by block %x is not unspent", u.TxHash, u.TxIndex, diff.BlockHash)
+		}
+	}
+	for _, u := range diff.Created {
+		us.RemoveUTXO(u.TxHash, u.TxIndex)
+	}
+	for _, u := range diff.Spent {
+		us.AddUTXO(u)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package utxo
+
+import (
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockDiffRevertsBlock(t *testing.T) {
+	us := NewUTXOSet()
+	us.AddUTXOSafe(NewUTXO([]byte("funding_a"), 0, 1000, []byte("alice"), "616c696365", false, 1))
+	us.AddUTXOSafe(NewUTXO([]byte("funding_b"), 1, 500, []byte("bob"), "626f62", false, 1))
+	before := us.Snapshot()
+	aliceBalance := us.GetBalance("616c696365")
+
+	newTx := func(inputs []*block.TxInput, outputs ...*block.TxOutput) *block.Transaction {
+		tx := &block.Transaction{Version: 1, Inputs: inputs, Outputs: outputs}
+		tx.Hash = tx.CalculateHash()
+		return tx
+	}
+	coinbase := newTx(nil, &block.TxOutput{Value: 5000, ScriptPubKey: []byte("miner")})
+	spend := newTx(
+		[]*block.TxInput{{PrevTxHash: []byte("funding_a"), PrevTxIndex: 0}},
+		&block.TxOutput{Value: 600, ScriptPubKey: []byte("carol")},
+		&block.TxOutput{Value: 300, ScriptPubKey: []byte("alice")},
+	)
+	// Spends an output created earlier in the same block
+	chained := newTx(
+		[]*block.TxInput{{PrevTxHash: spend.Hash, PrevTxIndex: 0}},
+		&block.TxOutput{Value: 550, ScriptPubKey: []byte("dave")},
+	)
+	b := &block.Block{
+		Header:       &block.Header{Version: 1, PrevBlockHash: make([]byte, 32), Height: 2},
+		Transactions: []*block.Transaction{coinbase, spend, chained},
+	}
+
+	diff, err := us.ApplyBlock(b)
+	require.NoError(t, err)
+	assert.Equal(t, b.CalculateHash(), diff.BlockHash)
+	assert.Equal(t, uint64(2), diff.Height)
+
+	// The output created and spent within the block is in neither list
+	require.Len(t, diff.Spent, 1)
+	assert.Equal(t, []byte("funding_a"), diff.Spent[0].TxHash)
+	assert.Equal(t, uint64(1000), diff.Spent[0].Value)
+	assert.Equal(t, []byte("alice"), diff.Spent[0].ScriptPubKey)
+	created := make(map[string]uint64)
+	for _, u := range diff.Created {
+		created[string(u.ScriptPubKey)] = u.Value
+	}
+	assert.Equal(t, map[string]uint64{"miner": 5000, "alice": 300, "dave": 550}, created)
+	assert.Equal(t, 4, us.GetUTXOCount())
+
+	// Reverting the diff restores exactly the set before the block
+	require.NoError(t, us.RevertBlock(diff))
+	assert.ElementsMatch(t, before, us.Snapshot())
+	assert.Equal(t, aliceBalance, us.GetBalance("616c696365"))
+	assert.Zero(t, us.GetBalance("6d696e6572"))
+
+	// A block whose outputs were spent since cannot be reverted
+	_, err = us.ApplyBlock(b)
+	require.NoError(t, err)
+	us.RemoveUTXOSafe(chained.Hash, 0)
+	assert.Error(t, us.RevertBlock(diff))
+	assert.Equal(t, 3, us.GetUTXOCount(), "a failed revert leaves the set unchanged")
+}
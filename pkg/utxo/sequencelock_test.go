@@ -0,0 +1,86 @@
+package utxo
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelativeLockTime(t *testing.T) {
+	blocks, duration, enabled := RelativeLockTime(10)
+	assert.True(t, enabled)
+	assert.Equal(t, uint64(10), blocks)
+	assert.Zero(t, duration)
+
+	blocks, duration, enabled = RelativeLockTime(SequenceLockTimeTypeFlag | 3)
+	assert.True(t, enabled)
+	assert.Zero(t, blocks)
+	assert.Equal(t, 3*SequenceLockTimeGranularity, duration)
+
+	_, _, enabled = RelativeLockTime(0xffffffff)
+	assert.False(t, enabled)
+	_, _, enabled = RelativeLockTime(SequenceLockTimeDisableFlag | 10)
+	assert.False(t, enabled)
+}
+
+func TestCheckSequenceLocks(t *testing.T) {
+	us := NewUTXOSet()
+	coin := NewUTXO([]byte("coin"), 0, 5000, []byte{0xaa}, "aa", false, 100)
+	us.AddUTXOSafe(coin)
+
+	// Block n is mined 600 seconds after block n-1
+	genesis := time.Unix(1700000000, 0)
+	blockTime := func(height uint64) (time.Time, bool) {
+		return genesis.Add(time.Duration(height) * 600 * time.Second), true
+	}
+	spend := func(version uint32, sequence uint32) *block.Transaction {
+		return &block.Transaction{
+			Version: version,
+			Inputs:  []*block.TxInput{{PrevTxHash: coin.TxHash, PrevTxIndex: 0, Sequence: sequence}},
+			Outputs: []*block.TxOutput{{Value: 4000, ScriptPubKey: []byte{0xbb}}},
+		}
+	}
+
+	// Height-based: the spending block must be 10 blocks above the coin's
+	tx := spend(2, 10)
+	err := CheckSequenceLocks(tx, us, 109, blockTime)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrSequenceLockNotMet))
+	assert.NoError(t, CheckSequenceLocks(tx, us, 110, blockTime))
+
+	// Time-based: 3 units of 512 seconds are 1536 seconds, so the parent of
+	// the spending block has to be at least three blocks after the parent of
+	// the coin's block
+	tx = spend(2, SequenceLockTimeTypeFlag|3)
+	err = CheckSequenceLocks(tx, us, 102, blockTime)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrSequenceLockNotMet))
+	assert.NoError(t, CheckSequenceLocks(tx, us, 103, blockTime))
+	assert.ErrorIs(t, CheckSequenceLocks(tx, us, 103, nil), ErrSequenceLockNotMet, "time locks need block times")
+
+	// Disabled locks, version 1 transactions and unlocked inputs are not
+	// subject to relative lock-times
+	assert.NoError(t, CheckSequenceLocks(spend(2, SequenceLockTimeDisableFlag|10), us, 101, blockTime))
+	assert.NoError(t, CheckSequenceLocks(spend(1, 10), us, 101, blockTime))
+	assert.NoError(t, CheckSequenceLocks(spend(2, 0), us, 100, blockTime))
+
+	// An output created earlier in the same block has no confirmations
+	parent := &block.Transaction{
+		Version: 2,
+		Inputs:  []*block.TxInput{{PrevTxHash: coin.TxHash, PrevTxIndex: 0, Sequence: 0xffffffff}},
+		Outputs: []*block.TxOutput{{Value: 4000, ScriptPubKey: []byte{0xbb}}},
+		Hash:    []byte("parent"),
+	}
+	view := NewBlockUTXOView(us, 120)
+	view.Apply(parent)
+	child := &block.Transaction{
+		Version: 2,
+		Inputs:  []*block.TxInput{{PrevTxHash: parent.Hash, PrevTxIndex: 0, Sequence: 1}},
+		Outputs: []*block.TxOutput{{Value: 3000, ScriptPubKey: []byte{0xcc}}},
+	}
+	assert.ErrorIs(t, CheckSequenceLocks(child, view, 120, blockTime), ErrSequenceLockNotMet)
+}
@@ -0,0 +1,136 @@
+package utxo
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+)
+
+// SigHashType selects the parts of a transaction an input signature covers.
+// A signature other than SigHashAll carries its type as one byte after the
+// signature in the scriptSig; signatures without it are SigHashAll.
+type SigHashType byte
+
+const (
+	// SigHashAll covers every input and output, and the fee. It is the
+	// type of signatures without a type byte.
+	SigHashAll SigHashType = 0x01
+	// SigHashNone covers the inputs but no output, letting anyone choose
+	// where the value goes.
+	SigHashNone SigHashType = 0x02
+	// SigHashSingle covers the inputs and only the output at the index of
+	// the signed input.
+	SigHashSingle SigHashType = 0x03
+	// SigHashAnyoneCanPay is combined with one of the types above to cover
+	// only the signed input, letting others add inputs.
+	SigHashAnyoneCanPay SigHashType = 0x80
+)
+
+// base returns the type without SigHashAnyoneCanPay.
+func (t SigHashType) base() SigHashType {
+	return t &^ SigHashAnyoneCanPay
+}
+
+// Valid reports whether t is one of the defined types.
+func (t SigHashType) Valid() bool {
+	switch t.base() {
+	case SigHashAll, SigHashNone, SigHashSingle:
+		return true
+	}
+	return false
+}
+
+// String returns the name of the type, e.g. "SINGLE|ANYONECANPAY".
+func (t SigHashType) String() string {
+	var name string
+	switch t.base() {
+	case SigHashAll:
+		name = "ALL"
+	case SigHashNone:
+		name = "NONE"
+	case SigHashSingle:
+		name = "SINGLE"
+	default:
+		return fmt.Sprintf("SigHashType(0x%02x)", byte(t))
+	}
+	if t&SigHashAnyoneCanPay != 0 {
+		name += "|ANYONECANPAY"
+	}
+	return name
+}
+
+// TxSignatureHash returns the hash an input's signature of the given type
+// signs. SigHashAll hashes the whole transaction as txSignatureData always
+// has. The other types hash the transaction with the uncovered inputs and
+// outputs left out, followed by the type; they leave out the fee as well,
+// since it changes when inputs or outputs are added.
+func TxSignatureHash(tx *block.Transaction, inputIndex int, hashType SigHashType) ([]byte, error) {
+	if !hashType.Valid() {
+		return nil, fmt.Errorf("invalid signature hash type 0x%02x", byte(hashType))
+	}
+	if inputIndex < 0 || inputIndex >= len(tx.Inputs) {
+		return nil, fmt.Errorf("input index %d out of range for %d inputs", inputIndex, len(tx.Inputs))
+	}
+	if hashType == SigHashAll {
+		return txSignatureData(tx), nil
+	}
+	if hashType.base() == SigHashSingle && inputIndex >= len(tx.Outputs) {
+		return nil, fmt.Errorf("SIGHASH_SINGLE input %d has no matching output", inputIndex)
+	}
+
+	data := binary.BigEndian.AppendUint32(nil, tx.Version)
+
+	inputs := tx.Inputs
+	if hashType&SigHashAnyoneCanPay != 0 {
+		inputs = tx.Inputs[inputIndex : inputIndex+1]
+	}
+	data = binary.BigEndian.AppendUint32(data, uint32(len(inputs)))
+	for _, input := range inputs {
+		data = append(data, input.PrevTxHash...)
+		data = binary.BigEndian.AppendUint32(data, input.PrevTxIndex)
+		// Other inputs may update their sequence unless all outputs are signed
+		if input == tx.Inputs[inputIndex] || hashType.base() == SigHashAll {
+			data = binary.BigEndian.AppendUint32(data, input.Sequence)
+		}
+	}
+
+	var outputs []*block.TxOutput
+	switch hashType.base() {
+	case SigHashAll:
+		outputs = tx.Outputs
+	case SigHashSingle:
+		outputs = tx.Outputs[inputIndex : inputIndex+1]
+	}
+	data = binary.BigEndian.AppendUint32(data, uint32(len(outputs)))
+	for _, output := range outputs {
+		data = binary.BigEndian.AppendUint64(data, output.Value)
+		data = binary.BigEndian.AppendUint32(data, uint32(len(output.ScriptPubKey)))
+		data = append(data, output.ScriptPubKey...)
+	}
+
+	data = binary.BigEndian.AppendUint64(data, tx.LockTime)
+	data = append(data, byte(hashType))
+
+	hash := sha256.Sum256(data)
+	return hash[:], nil
+}
+
+// SplitSigHashType splits the signature hash type byte off a signature of
+// sigSize bytes. A signature of exactly sigSize bytes is SigHashAll; one
+// byte more carries its type, which may not be SigHashAll so that every
+// signature has a single encoding.
+func SplitSigHashType(sig []byte, sigSize int) ([]byte, SigHashType, error) {
+	if len(sig) != sigSize+1 {
+		return sig, SigHashAll, nil
+	}
+	hashType := SigHashType(sig[sigSize])
+	if !hashType.Valid() {
+		return nil, 0, fmt.Errorf("invalid signature hash type 0x%02x", byte(hashType))
+	}
+	if hashType == SigHashAll {
+		return nil, 0, fmt.Errorf("SIGHASH_ALL signature must not carry a type byte")
+	}
+	return sig[:sigSize], hashType, nil
+}
@@ -0,0 +1,102 @@
+package utxo
+
+import (
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/crypto_utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigHashTypes(t *testing.T) {
+	ctu := crypto_utils.NewCryptoTestUtils(t)
+	alice, bob := ctu.GenerateTestKeyPair(), ctu.GenerateTestKeyPair()
+	prevs := []*UTXO{
+		createTestUTXO("sighash-0", 0, 1000, alice, false, 1),
+		createTestUTXO("sighash-1", 0, 1000, alice, false, 1),
+	}
+	bobScript := createTestUTXO("bob", 0, 0, bob, false, 0).ScriptPubKey
+	aliceScript := prevs[0].ScriptPubKey
+
+	newTx := func() *block.Transaction {
+		return &block.Transaction{
+			Version: 1,
+			Inputs: []*block.TxInput{
+				{PrevTxHash: prevs[0].TxHash, PrevTxIndex: 0, Sequence: 0xffffffff},
+				{PrevTxHash: prevs[1].TxHash, PrevTxIndex: 0, Sequence: 0xffffffff},
+			},
+			Outputs: []*block.TxOutput{
+				{Value: 1500, ScriptPubKey: bobScript},
+				{Value: 490, ScriptPubKey: aliceScript},
+			},
+			Fee: 10,
+		}
+	}
+	sign := func(tx *block.Transaction, i int, hashType SigHashType) {
+		hash, err := TxSignatureHash(tx, i, hashType)
+		require.NoError(t, err)
+		sig, err := ctu.SignData(hash, alice.PrivateKey)
+		require.NoError(t, err)
+		scriptSig := append(alice.PublicKey.SerializeUncompressed(), sig...)
+		if hashType != SigHashAll {
+			scriptSig = append(scriptSig, byte(hashType))
+		}
+		tx.Inputs[i].ScriptSig = scriptSig
+	}
+
+	// SIGHASH_ALL is the signature data signatures have always covered
+	tx := newTx()
+	us := NewUTXOSet()
+	all, err := TxSignatureHash(tx, 0, SigHashAll)
+	require.NoError(t, err)
+	assert.Equal(t, us.getTxSignatureData(tx), all)
+	allInput1, err := TxSignatureHash(tx, 1, SigHashAll)
+	require.NoError(t, err)
+	assert.Equal(t, all, allInput1, "SIGHASH_ALL covers the same data for every input")
+
+	// SIGHASH_SINGLE covers less, and differs per input
+	single, err := TxSignatureHash(tx, 0, SigHashSingle)
+	require.NoError(t, err)
+	singleInput1, err := TxSignatureHash(tx, 1, SigHashSingle)
+	require.NoError(t, err)
+	assert.NotEqual(t, all, single)
+	assert.NotEqual(t, single, singleInput1)
+
+	sign(tx, 0, SigHashSingle)
+	sign(tx, 1, SigHashAll)
+	require.NoError(t, AuthorizeP2PKH(tx, 0, prevs[0]))
+	require.NoError(t, AuthorizeP2PKH(tx, 1, prevs[1]))
+
+	// Changing an output only the SIGHASH_ALL signature covers, and the fee,
+	// leaves the SIGHASH_SINGLE signature valid
+	tx.Outputs[1].Value = 480
+	tx.Fee = 20
+	assert.NoError(t, AuthorizeP2PKH(tx, 0, prevs[0]))
+	assert.ErrorContains(t, AuthorizeP2PKH(tx, 1, prevs[1]), "invalid signature")
+
+	// Changing the output paired with the input breaks it
+	tx.Outputs[0].ScriptPubKey = aliceScript
+	assert.ErrorContains(t, AuthorizeP2PKH(tx, 0, prevs[0]), "invalid signature")
+
+	// SIGHASH_SINGLE|ANYONECANPAY lets others add inputs
+	tx = newTx()
+	sign(tx, 0, SigHashSingle|SigHashAnyoneCanPay)
+	tx.Inputs = append(tx.Inputs, &block.TxInput{PrevTxHash: makeHash("other"), Sequence: 0xffffffff})
+	assert.NoError(t, AuthorizeP2PKH(tx, 0, prevs[0]))
+
+	// Every signature has one encoding, with a defined type
+	tx = newTx()
+	sign(tx, 0, SigHashAll)
+	tx.Inputs[0].ScriptSig = append(tx.Inputs[0].ScriptSig, byte(SigHashAll))
+	assert.ErrorContains(t, AuthorizeP2PKH(tx, 0, prevs[0]), "must not carry a type byte")
+	tx.Inputs[0].ScriptSig[len(tx.Inputs[0].ScriptSig)-1] = 0x04
+	assert.ErrorContains(t, AuthorizeP2PKH(tx, 0, prevs[0]), "invalid signature hash type 0x04")
+
+	// SIGHASH_SINGLE needs an output for its input
+	tx.Outputs = tx.Outputs[:1]
+	_, err = TxSignatureHash(tx, 1, SigHashSingle)
+	assert.ErrorContains(t, err, "has no matching output")
+
+	assert.Equal(t, "SINGLE|ANYONECANPAY", (SigHashSingle | SigHashAnyoneCanPay).String())
+}
@@ -0,0 +1,33 @@
+package utxo
+
+// Snapshot returns a copy of every UTXO in the set, spilled ones included,
+// from which Restore rebuilds the set.
+func (us *UTXOSet) Snapshot() []*UTXO {
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+
+	utxos := make([]*UTXO, 0, len(us.utxos)+len(us.spilled))
+	for _, utxo := range us.utxos {
+		copied := *utxo
+		utxos = append(utxos, &copied)
+	}
+	for key := range us.spilled {
+		if utxo := us.readSpilled(key); utxo != nil {
+			utxos = append(utxos, utxo)
+		}
+	}
+	return utxos
+}
+
+// Restore replaces the contents of the set with the given UTXOs, typically
+// a snapshot taken earlier, before blocks are replayed on top of it.
+func (us *UTXOSet) Restore(utxos []*UTXO) {
+	us.Reset()
+
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	for _, utxo := range utxos {
+		copied := *utxo
+		us.AddUTXO(&copied)
+	}
+}
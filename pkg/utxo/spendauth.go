@@ -0,0 +1,282 @@
+package utxo
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/palaseus/adrenochain/pkg/block"
+)
+
+// ScriptTemplate identifies the kind of an output script and with it the
+// validator that authorizes spending the output.
+type ScriptTemplate string
+
+// ScriptTemplateP2PKH is the template of plain public key hash outputs, the
+// only template known to core validation.
+const ScriptTemplateP2PKH ScriptTemplate = "p2pkh"
+
+// templateScriptMarker starts a script that names its template explicitly:
+// marker, template identifier length, identifier, then the template payload.
+// Scripts without the marker, and scripts of the P2PKH length, are P2PKH.
+const templateScriptMarker = 0xc0
+
+// P2PKHScriptSize is the size of a P2PKH script, a 20-byte public key hash.
+const P2PKHScriptSize = 20
+
+const (
+	// P2PKHMinScriptSigSize is the smallest P2PKH scriptSig: an uncompressed
+	// public key followed by the 64-byte R and S of the signature.
+	P2PKHMinScriptSigSize = 65 + 64
+	// P2PKHMaxScriptSigSize is the largest P2PKH scriptSig: the smallest
+	// one followed by a signature hash type byte.
+	P2PKHMaxScriptSigSize = P2PKHMinScriptSigSize + 1
+	// DefaultMaxScriptSigSize bounds the scriptSig of inputs spending
+	// templates registered without limits.
+	DefaultMaxScriptSigSize = 1650
+)
+
+// ScriptSigLimits bounds the size of the scriptSig of inputs spending the
+// outputs of a script template.
+type ScriptSigLimits struct {
+	// MinSize is the smallest accepted scriptSig in bytes.
+	MinSize int
+	// MaxSize is the largest accepted scriptSig in bytes. Zero selects
+	// DefaultMaxScriptSigSize.
+	MaxSize int
+}
+
+// Validate checks that the limits are consistent.
+func (l ScriptSigLimits) Validate() error {
+	if l.MinSize < 0 {
+		return fmt.Errorf("minimum scriptSig size %d is negative", l.MinSize)
+	}
+	if l.MaxSize < 0 {
+		return fmt.Errorf("maximum scriptSig size %d is negative", l.MaxSize)
+	}
+	if l.MaxSize > 0 && l.MinSize > l.MaxSize {
+		return fmt.Errorf("minimum scriptSig size %d exceeds maximum %d", l.MinSize, l.MaxSize)
+	}
+	return nil
+}
+
+// check returns an error if a scriptSig of size bytes is outside the limits.
+func (l ScriptSigLimits) check(size int) error {
+	maxSize := l.MaxSize
+	if maxSize == 0 {
+		maxSize = DefaultMaxScriptSigSize
+	}
+	if size < l.MinSize {
+		return fmt.Errorf("invalid scriptSig length: %d (expected >= %d)", size, l.MinSize)
+	}
+	if size > maxSize {
+		return fmt.Errorf("invalid scriptSig length: %d (expected <= %d)", size, maxSize)
+	}
+	return nil
+}
+
+// SpendValidator authorizes the spend of prev by input inputIndex of tx,
+// returning an error if the spend is not allowed.
+type SpendValidator func(tx *block.Transaction, inputIndex int, prev *UTXO) error
+
+// SpendAuthRegistry maps script templates to the validators authorizing
+// spends of their outputs, so that new output types can be added without
+// changing core validation.
+type SpendAuthRegistry struct {
+	mu         sync.RWMutex
+	validators map[ScriptTemplate]SpendValidator
+	limits     map[ScriptTemplate]ScriptSigLimits
+}
+
+// defaultSpendAuth is used by UTXO sets created without NewUTXOSet.
+var defaultSpendAuth = NewSpendAuthRegistry()
+
+// NewSpendAuthRegistry creates a registry containing the P2PKH validator
+// and its scriptSig limits.
+func NewSpendAuthRegistry() *SpendAuthRegistry {
+	return &SpendAuthRegistry{
+		validators: map[ScriptTemplate]SpendValidator{
+			ScriptTemplateP2PKH: AuthorizeP2PKH,
+		},
+		limits: map[ScriptTemplate]ScriptSigLimits{
+			ScriptTemplateP2PKH: {MinSize: P2PKHMinScriptSigSize, MaxSize: P2PKHMaxScriptSigSize},
+		},
+	}
+}
+
+// Register adds the validator for a script template, replacing any validator
+// previously registered for it.
+func (r *SpendAuthRegistry) Register(template ScriptTemplate, validator SpendValidator) error {
+	if template == "" {
+		return fmt.Errorf("script template identifier is empty")
+	}
+	if len(template) > 255 {
+		return fmt.Errorf("script template identifier %q is longer than 255 bytes", template)
+	}
+	if validator == nil {
+		return fmt.Errorf("validator for script template %q is nil", template)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.validators[template] = validator
+	return nil
+}
+
+// SetScriptSigLimits sets the scriptSig size limits of inputs spending
+// outputs of a script template, replacing any limits previously set for it.
+func (r *SpendAuthRegistry) SetScriptSigLimits(template ScriptTemplate, limits ScriptSigLimits) error {
+	if err := limits.Validate(); err != nil {
+		return fmt.Errorf("script template %q: %w", template, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.limits[template] = limits
+	return nil
+}
+
+// ScriptSigLimits returns the scriptSig size limits of a script template.
+// Templates without limits only have DefaultMaxScriptSigSize.
+func (r *SpendAuthRegistry) ScriptSigLimits(template ScriptTemplate) ScriptSigLimits {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.limits[template]
+}
+
+// Validator returns the validator registered for a script template.
+func (r *SpendAuthRegistry) Validator(template ScriptTemplate) (SpendValidator, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	validator, exists := r.validators[template]
+	return validator, exists
+}
+
+// Authorize checks the spend of prev by input inputIndex of tx with the
+// validator of prev's script template, after checking the input's scriptSig
+// against the template's size limits. Outputs of unregistered templates
+// cannot be spent.
+func (r *SpendAuthRegistry) Authorize(tx *block.Transaction, inputIndex int, prev *UTXO) error {
+	template, _ := ParseScriptTemplate(prev.ScriptPubKey)
+	validator, exists := r.Validator(template)
+	if !exists {
+		return fmt.Errorf("input %d: no spend validator registered for script template %q", inputIndex, template)
+	}
+	if err := r.ScriptSigLimits(template).check(len(tx.Inputs[inputIndex].ScriptSig)); err != nil {
+		return fmt.Errorf("input %d: %w for script template %q", inputIndex, err, template)
+	}
+	return validator(tx, inputIndex, prev)
+}
+
+// SetSpendAuthRegistry replaces the registry used to authorize spends.
+func (us *UTXOSet) SetSpendAuthRegistry(registry *SpendAuthRegistry) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	us.spendAuth = registry
+}
+
+// SpendAuthRegistry returns the registry used to authorize spends.
+func (us *UTXOSet) SpendAuthRegistry() *SpendAuthRegistry {
+	us.mu.RLock()
+	defer us.mu.RUnlock()
+	if us.spendAuth == nil {
+		return defaultSpendAuth
+	}
+	return us.spendAuth
+}
+
+// NewTemplateScript builds the scriptPubKey of an output of a custom
+// template carrying the given payload.
+func NewTemplateScript(template ScriptTemplate, payload []byte) ([]byte, error) {
+	if template == "" || len(template) > 255 {
+		return nil, fmt.Errorf("invalid script template identifier %q", template)
+	}
+	script := make([]byte, 0, 2+len(template)+len(payload))
+	script = append(script, templateScriptMarker, byte(len(template)))
+	script = append(script, template...)
+	script = append(script, payload...)
+	if len(script) == P2PKHScriptSize {
+		return nil, fmt.Errorf("template script of %d bytes would be read as P2PKH", P2PKHScriptSize)
+	}
+	return script, nil
+}
+
+// ParseScriptTemplate returns the template of a scriptPubKey and its
+// template payload. Scripts that do not name a template are P2PKH, with the
+// whole script as payload.
+func ParseScriptTemplate(script []byte) (ScriptTemplate, []byte) {
+	if len(script) < 3 || len(script) == P2PKHScriptSize || script[0] != templateScriptMarker {
+		return ScriptTemplateP2PKH, script
+	}
+	idLen := int(script[1])
+	if idLen == 0 || 2+idLen > len(script) {
+		return ScriptTemplateP2PKH, script
+	}
+	return ScriptTemplate(script[2 : 2+idLen]), script[2+idLen:]
+}
+
+// AuthorizeP2PKH authorizes the spend of a public key hash output: the
+// input's scriptSig must hold the public key hashing to the output script
+// followed by a valid signature of the transaction, optionally followed by
+// its signature hash type.
+func AuthorizeP2PKH(tx *block.Transaction, inputIndex int, prev *UTXO) error {
+	i := inputIndex
+	input := tx.Inputs[i]
+
+	// The registry enforces the P2PKH size limits; this only guards the
+	// slicing below when the validator is called directly
+	if len(input.ScriptSig) < P2PKHMinScriptSigSize {
+		return fmt.Errorf("input %d: invalid scriptSig length: %d (expected >= %d)", i, len(input.ScriptSig), P2PKHMinScriptSigSize)
+	}
+
+	// Extract public key and signature from ScriptSig
+	pubBytes := input.ScriptSig[:65]
+	rsBytes := input.ScriptSig[65:]
+
+	// Validate public key format
+	pubKey, err := btcec.ParsePubKey(pubBytes)
+	if err != nil {
+		return fmt.Errorf("input %d: failed to unmarshal public key from scriptSig: %v", i, err)
+	}
+	pub := pubKey.ToECDSA()
+
+	// Verify public key hash matches the UTXO's ScriptPubKey
+	pubKeyHash := sha256.Sum256(pubBytes)
+	expectedAddress := hex.EncodeToString(pubKeyHash[len(pubKeyHash)-20:])
+	utxoAddress := hex.EncodeToString(prev.ScriptPubKey)
+
+	if expectedAddress != utxoAddress {
+		return fmt.Errorf("input %d: public key hash %s does not match UTXO scriptPubKey %s",
+			i, expectedAddress, utxoAddress)
+	}
+
+	// Extract R and S components from signature
+	if len(rsBytes) < 64 {
+		return fmt.Errorf("input %d: insufficient signature data", i)
+	}
+	rsBytes, hashType, err := SplitSigHashType(rsBytes, 64)
+	if err != nil {
+		return fmt.Errorf("input %d: %w", i, err)
+	}
+	r := new(big.Int).SetBytes(rsBytes[:32])
+	s := new(big.Int).SetBytes(rsBytes[32:64])
+
+	// Validate signature components
+	if r.Sign() <= 0 || s.Sign() <= 0 {
+		return fmt.Errorf("input %d: invalid signature components (R or S <= 0)", i)
+	}
+
+	// Verify signature
+	signatureData, err := TxSignatureHash(tx, i, hashType)
+	if err != nil {
+		return fmt.Errorf("input %d: %w", i, err)
+	}
+	if !ecdsa.Verify(pub, signatureData, r, s) {
+		return fmt.Errorf("input %d: invalid signature for UTXO %x:%d", i, input.PrevTxHash, input.PrevTxIndex)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package utxo
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/crypto_utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpendAuthRegistry(t *testing.T) {
+	us := NewUTXOSet()
+	registry := us.SpendAuthRegistry()
+
+	_, hasP2PKH := registry.Validator(ScriptTemplateP2PKH)
+	assert.True(t, hasP2PKH, "default registry must contain the P2PKH validator")
+
+	require.NoError(t, registry.Register("always-valid", func(tx *block.Transaction, inputIndex int, prev *UTXO) error {
+		return nil
+	}))
+	require.NoError(t, registry.Register("always-invalid", func(tx *block.Transaction, inputIndex int, prev *UTXO) error {
+		return errors.New("vault is locked")
+	}))
+	assert.Error(t, registry.Register("", func(*block.Transaction, int, *UTXO) error { return nil }))
+	assert.Error(t, registry.Register("no-validator", nil))
+
+	// spend builds a transaction spending a fresh output of the given template
+	spend := func(seed string, template ScriptTemplate) *block.Transaction {
+		script, err := NewTemplateScript(template, []byte("payload"))
+		require.NoError(t, err)
+		parsed, payload := ParseScriptTemplate(script)
+		require.Equal(t, template, parsed)
+		require.Equal(t, []byte("payload"), payload)
+
+		prev := &UTXO{TxHash: makeHash(seed), TxIndex: 0, Value: 1000, ScriptPubKey: script, Height: 1}
+		us.AddUTXOSafe(prev)
+		tx := &block.Transaction{
+			Version: 1,
+			Inputs:  []*block.TxInput{{PrevTxHash: prev.TxHash, PrevTxIndex: 0, ScriptSig: []byte("unlock")}},
+			Outputs: []*block.TxOutput{{Value: 900, ScriptPubKey: []byte("recipient")}},
+		}
+		tx.Hash = calculateTxHash(tx)
+		return tx
+	}
+
+	assert.NoError(t, us.ValidateTransaction(spend("valid", "always-valid")))
+
+	err := us.ValidateTransaction(spend("invalid", "always-invalid"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "vault is locked")
+
+	err = us.ValidateTransaction(spend("unknown", "unregistered"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `no spend validator registered for script template "unregistered"`)
+
+	// Plain public key hash outputs are still authorized by signature
+	ctu := crypto_utils.NewCryptoTestUtils(t)
+	alice := ctu.GenerateTestKeyPair()
+	funding := createTestUTXO("spend_auth_p2pkh", 0, 1000, alice, false, 1)
+	us.AddUTXOSafe(funding)
+	signed := ctu.CreateSignedTransaction(
+		[]*block.TxInput{{PrevTxHash: funding.TxHash, PrevTxIndex: 0, Sequence: 0xffffffff}},
+		[]*block.TxOutput{{Value: 900, ScriptPubKey: []byte("recipient")}},
+		map[string]*crypto_utils.TestKeyPair{alice.Address: alice}, 100)
+	assert.NoError(t, us.ValidateTransaction(signed))
+
+	signed.Inputs[0].ScriptSig = []byte("unsigned")
+	assert.Error(t, us.ValidateTransaction(signed))
+}
+
+func TestScriptSigLimits(t *testing.T) {
+	us := NewUTXOSet()
+	registry := us.SpendAuthRegistry()
+	accept := func(*block.Transaction, int, *UTXO) error { return nil }
+	require.NoError(t, registry.Register("multisig", accept))
+	require.NoError(t, registry.Register("unlimited", accept))
+
+	// A 2-of-3 multisig scriptSig holds two public keys and signatures
+	require.NoError(t, registry.SetScriptSigLimits("multisig", ScriptSigLimits{
+		MinSize: 2 * P2PKHMinScriptSigSize,
+		MaxSize: 3 * P2PKHMaxScriptSigSize,
+	}))
+	assert.Error(t, registry.SetScriptSigLimits("multisig", ScriptSigLimits{MinSize: 10, MaxSize: 5}))
+	assert.Error(t, registry.SetScriptSigLimits("multisig", ScriptSigLimits{MinSize: -1}))
+	assert.Equal(t, 2*P2PKHMinScriptSigSize, registry.ScriptSigLimits("multisig").MinSize)
+
+	// spend spends a fresh output of the given template with a scriptSig of
+	// the given size
+	spend := func(seed string, template ScriptTemplate, scriptSigSize int) *block.Transaction {
+		script, err := NewTemplateScript(template, []byte("payload"))
+		require.NoError(t, err)
+		prev := &UTXO{TxHash: makeHash(seed), TxIndex: 0, Value: 1000, ScriptPubKey: script, Height: 1}
+		us.AddUTXOSafe(prev)
+		tx := &block.Transaction{
+			Version: 1,
+			Inputs:  []*block.TxInput{{PrevTxHash: prev.TxHash, PrevTxIndex: 0, ScriptSig: make([]byte, scriptSigSize)}},
+			Outputs: []*block.TxOutput{{Value: 900, ScriptPubKey: []byte("recipient")}},
+		}
+		tx.Hash = calculateTxHash(tx)
+		return tx
+	}
+
+	// Longer than any P2PKH scriptSig but valid for multisig
+	assert.NoError(t, us.ValidateTransaction(spend("multisig_valid", "multisig", 2*P2PKHMinScriptSigSize)))
+
+	err := us.ValidateTransaction(spend("multisig_short", "multisig", P2PKHMinScriptSigSize))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid scriptSig length")
+	assert.Contains(t, err.Error(), `"multisig"`)
+
+	err = us.ValidateTransaction(spend("multisig_long", "multisig", 3*P2PKHMaxScriptSigSize+1))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid scriptSig length")
+
+	// Templates without limits are only bounded by the default maximum
+	assert.NoError(t, us.ValidateTransaction(spend("unlimited_valid", "unlimited", 1)))
+	assert.Error(t, us.ValidateTransaction(spend("unlimited_long", "unlimited", DefaultMaxScriptSigSize+1)))
+}
+
+func TestParseScriptTemplate(t *testing.T) {
+	pubKeyHash := make([]byte, 20)
+	pubKeyHash[0] = templateScriptMarker
+	template, payload := ParseScriptTemplate(pubKeyHash)
+	assert.Equal(t, ScriptTemplateP2PKH, template, "20-byte scripts are always P2PKH")
+	assert.Equal(t, pubKeyHash, payload)
+
+	template, _ = ParseScriptTemplate([]byte{templateScriptMarker, 9, 'x'})
+	assert.Equal(t, ScriptTemplateP2PKH, template, "truncated identifiers are not templates")
+
+	_, err := NewTemplateScript("vault", make([]byte, 13))
+	assert.Error(t, err, "template scripts of the P2PKH size are ambiguous")
+	_, err = NewTemplateScript("", nil)
+	assert.Error(t, err)
+}
@@ -1,22 +1,30 @@
 package utxo
 
 import (
-	"crypto/ecdsa"
+	"container/list"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"math/big"
 	"sync"
 
-	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/palaseus/adrenochain/pkg/block"
 )
 
 // UTXOSet represents the set of unspent transaction outputs
 type UTXOSet struct {
-	mu       sync.RWMutex
-	utxos    map[string]*UTXO  // key: "txHash:index"
-	balances map[string]uint64 // address -> balance
+	mu        sync.RWMutex
+	utxos     map[string]*UTXO   // key: "txHash:index"
+	balances  map[string]uint64  // address -> balance
+	spendAuth *SpendAuthRegistry // validators authorizing spends, by script template
+
+	// A capped set keeps at most maxEntries UTXOs in utxos, ordered by lru,
+	// and spills the rest to spill; spilled maps their keys to addresses.
+	spill      SpillStore
+	maxEntries int
+	lru        *list.List
+	lruElems   map[string]*list.Element
+	spilled    map[string]string
 }
 
 // UTXO represents an unspent transaction output
@@ -30,11 +38,26 @@ type UTXO struct {
 	Height       uint64 `json:"height"`
 }
 
+// DefaultCoinbaseMaturity is the number of blocks a coinbase output has to be
+// buried under before it can be spent.
+const DefaultCoinbaseMaturity = 100
+
+// IsMature reports whether the UTXO can be spent in a block built on top of
+// tipHeight. Non-coinbase outputs are always mature; coinbase outputs need
+// at least maturity confirmations.
+func (u *UTXO) IsMature(tipHeight, maturity uint64) bool {
+	if !u.IsCoinbase {
+		return true
+	}
+	return tipHeight+1 >= u.Height+maturity
+}
+
 // NewUTXOSet creates a new UTXO set
 func NewUTXOSet() *UTXOSet {
 	return &UTXOSet{
-		utxos:    make(map[string]*UTXO),
-		balances: make(map[string]uint64),
+		utxos:     make(map[string]*UTXO),
+		balances:  make(map[string]uint64),
+		spendAuth: NewSpendAuthRegistry(),
 	}
 }
 
@@ -57,7 +80,7 @@ func (us *UTXOSet) AddUTXO(utxo *UTXO) {
 		return
 	}
 	key := us.makeKey(utxo.TxHash, utxo.TxIndex)
-	us.utxos[key] = utxo
+	us.putLocked(key, utxo)
 
 	// Update balance
 	us.balances[utxo.Address] += utxo.Value
@@ -73,8 +96,8 @@ func (us *UTXOSet) AddUTXOSafe(utxo *UTXO) {
 // RemoveUTXO removes a UTXO from the set
 func (us *UTXOSet) RemoveUTXO(txHash []byte, txIndex uint32) *UTXO {
 	key := us.makeKey(txHash, txIndex)
-	utxo, exists := us.utxos[key]
-	if !exists {
+	utxo := us.getLocked(key)
+	if utxo == nil {
 		return nil
 	}
 
@@ -84,7 +107,7 @@ func (us *UTXOSet) RemoveUTXO(txHash []byte, txIndex uint32) *UTXO {
 		delete(us.balances, utxo.Address)
 	}
 
-	delete(us.utxos, key)
+	us.deleteLocked(key)
 	return utxo
 }
 
@@ -95,12 +118,35 @@ func (us *UTXOSet) RemoveUTXOSafe(txHash []byte, txIndex uint32) *UTXO {
 	return us.RemoveUTXO(txHash, txIndex)
 }
 
+// Reset removes every UTXO from the set, typically before it is rebuilt by
+// replaying blocks.
+func (us *UTXOSet) Reset() {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	for key := range us.spilled {
+		us.deleteLocked(key)
+	}
+	us.utxos = make(map[string]*UTXO)
+	us.balances = make(map[string]uint64)
+	if us.lru != nil {
+		us.lru.Init()
+		us.lruElems = make(map[string]*list.Element)
+	}
+}
+
 // GetUTXO retrieves a UTXO by transaction hash and index
 func (us *UTXOSet) GetUTXO(txHash []byte, txIndex uint32) *UTXO {
+	key := us.makeKey(txHash, txIndex)
+	if us.lru != nil {
+		// Accessing a capped set updates its recency order
+		us.mu.Lock()
+		defer us.mu.Unlock()
+		return us.getLocked(key)
+	}
+
 	us.mu.RLock()
 	defer us.mu.RUnlock()
-
-	key := us.makeKey(txHash, txIndex)
 	return us.utxos[key]
 }
 
@@ -117,14 +163,7 @@ func (us *UTXOSet) GetAddressUTXOs(address string) []*UTXO {
 	us.mu.RLock()
 	defer us.mu.RUnlock()
 
-	var addressUTXOs []*UTXO
-	for _, utxo := range us.utxos {
-		if utxo.Address == address {
-			addressUTXOs = append(addressUTXOs, utxo)
-		}
-	}
-
-	return addressUTXOs
+	return us.addressUTXOsLocked(address, func(*UTXO) bool { return true })
 }
 
 // makeKey creates a key for the UTXO map
@@ -137,30 +176,49 @@ func (us *UTXOSet) extractAddress(scriptPubKey []byte) string {
 	return hex.EncodeToString(scriptPubKey)
 }
 
-// ProcessBlock processes a block and updates the UTXO set
+// ProcessBlock processes a block and updates the UTXO set. Transactions are
+// applied in block order, so an output created by tx[i] is spendable by tx[j]
+// for j > i. A block in which a transaction spends an output of a later
+// transaction in the same block is rejected before the set is modified.
 func (us *UTXOSet) ProcessBlock(block *block.Block) error {
-	if block == nil {
-		return fmt.Errorf("block is nil")
-	}
-	if block.Header == nil {
-		return fmt.Errorf("block header is nil")
-	}
-
-	us.mu.Lock()
-	defer us.mu.Unlock()
+	_, err := us.ApplyBlock(block)
+	return err
+}
 
-	// Process each transaction in the block
-	for _, tx := range block.Transactions {
-		if err := us.processTransaction(tx, block.Header.Height); err != nil {
-			return fmt.Errorf("failed to process transaction: %w", err)
+// checkIntraBlockOrder returns an error if a transaction spends an output of
+// itself or of a transaction that appears later in the same block.
+func checkIntraBlockOrder(txs []*block.Transaction) error {
+	position := make(map[string]int, len(txs))
+	for i, tx := range txs {
+		if tx != nil && len(tx.Hash) > 0 {
+			position[string(tx.Hash)] = i
+		}
+	}
+	for i, tx := range txs {
+		if tx == nil {
+			continue
+		}
+		for _, input := range tx.Inputs {
+			if len(input.PrevTxHash) == 0 {
+				continue
+			}
+			if j, inBlock := position[string(input.PrevTxHash)]; inBlock && j >= i {
+				return fmt.Errorf("transaction %d spends output %x:%d created by transaction %d of the same block",
+					i, input.PrevTxHash, input.PrevTxIndex, j)
+			}
 		}
 	}
-
 	return nil
 }
 
 // processTransaction processes a single transaction
 func (us *UTXOSet) processTransaction(tx *block.Transaction, height uint64) error {
+	return us.applyTransaction(tx, height, &BlockDiff{})
+}
+
+// applyTransaction processes a single transaction, recording the outputs it
+// spends and creates in diff
+func (us *UTXOSet) applyTransaction(tx *block.Transaction, height uint64, diff *BlockDiff) error {
 	// Remove spent inputs
 	for _, input := range tx.Inputs {
 		// Skip coinbase transactions (they have no inputs)
@@ -169,11 +227,17 @@ func (us *UTXOSet) processTransaction(tx *block.Transaction, height uint64) erro
 		}
 
 		// Remove the spent UTXO
-		us.RemoveUTXO(input.PrevTxHash, input.PrevTxIndex)
+		if spent := us.RemoveUTXO(input.PrevTxHash, input.PrevTxIndex); spent != nil {
+			diff.Spent = append(diff.Spent, spent)
+		}
 	}
 
-	// Add new outputs
+	// Add new outputs; data outputs can never be spent and are left out
 	for i, output := range tx.Outputs {
+		if output.IsData() {
+			continue
+		}
+
 		// Determine if this is a coinbase transaction
 		isCoinbase := len(tx.Inputs) == 0
 
@@ -191,6 +255,7 @@ func (us *UTXOSet) processTransaction(tx *block.Transaction, height uint64) erro
 		}
 
 		us.AddUTXO(utxo)
+		diff.Created = append(diff.Created, utxo)
 	}
 
 	return nil
@@ -202,6 +267,19 @@ func (us *UTXOSet) processTransaction(tx *block.Transaction, height uint64) erro
 // Note: This method treats transactions with no inputs as potentially valid (coinbase-like),
 // but for strict validation in block context, use ValidateTransactionInBlock.
 func (us *UTXOSet) ValidateTransaction(tx *block.Transaction) error {
+	return us.ValidateTransactionWithView(tx, us)
+}
+
+// UTXOView resolves previous outputs during transaction validation. The
+// UTXOSet itself is a view; callers such as the mempool can supply views that
+// also expose outputs of unconfirmed transactions.
+type UTXOView interface {
+	GetUTXO(txHash []byte, txIndex uint32) *UTXO
+}
+
+// ValidateTransactionWithView performs the same checks as ValidateTransaction
+// but resolves inputs through the given view instead of the UTXO set.
+func (us *UTXOSet) ValidateTransactionWithView(tx *block.Transaction, view UTXOView) error {
 	if tx == nil {
 		return fmt.Errorf("transaction is nil")
 	}
@@ -213,7 +291,7 @@ func (us *UTXOSet) ValidateTransaction(tx *block.Transaction) error {
 		}
 		// Validate outputs
 		for i, output := range tx.Outputs {
-			if output.Value == 0 {
+			if output.Value == 0 && !output.IsData() {
 				return fmt.Errorf("output %d has zero value", i)
 			}
 			if len(output.ScriptPubKey) == 0 {
@@ -247,7 +325,7 @@ func (us *UTXOSet) ValidateTransaction(tx *block.Transaction) error {
 		}
 
 		// Check if UTXO exists and is not already spent
-		utxo := us.GetUTXO(input.PrevTxHash, input.PrevTxIndex)
+		utxo := view.GetUTXO(input.PrevTxHash, input.PrevTxIndex)
 		if utxo == nil {
 			return fmt.Errorf("input UTXO not found: %x:%d", input.PrevTxHash, input.PrevTxIndex)
 		}
@@ -258,49 +336,9 @@ func (us *UTXOSet) ValidateTransaction(tx *block.Transaction) error {
 			// In a real implementation, you might want to enforce maturity requirements
 		}
 
-		// Verify signature length and structure
-		if len(input.ScriptSig) < 65+64 {
-			return fmt.Errorf("input %d: invalid scriptSig length: %d (expected >= 129)", i, len(input.ScriptSig))
-		}
-
-		// Extract public key and signature from ScriptSig
-		pubBytes := input.ScriptSig[:65]
-		rsBytes := input.ScriptSig[65:]
-
-		// Validate public key format
-		pubKey, err := btcec.ParsePubKey(pubBytes)
-		if err != nil {
-			return fmt.Errorf("input %d: failed to unmarshal public key from scriptSig: %v", i, err)
-		}
-		pub := pubKey.ToECDSA()
-
-		// Verify public key hash matches the UTXO's ScriptPubKey
-		pubKeyHash := sha256.Sum256(pubBytes)
-		expectedAddress := hex.EncodeToString(pubKeyHash[len(pubKeyHash)-20:])
-		utxoAddress := hex.EncodeToString(utxo.ScriptPubKey)
-
-		if expectedAddress != utxoAddress {
-			return fmt.Errorf("input %d: public key hash %s does not match UTXO scriptPubKey %s",
-				i, expectedAddress, utxoAddress)
-		}
-
-		// Extract R and S components from signature
-		if len(rsBytes) < 64 {
-			return fmt.Errorf("input %d: insufficient signature data", i)
-		}
-		r := new(big.Int).SetBytes(rsBytes[:32])
-		s := new(big.Int).SetBytes(rsBytes[32:64])
-
-		// Validate signature components
-		if r.Sign() <= 0 || s.Sign() <= 0 {
-			return fmt.Errorf("input %d: invalid signature components (R or S <= 0)", i)
-		}
-
-		// Verify signature
-		signatureData := us.getTxSignatureData(tx)
-		verified := ecdsa.Verify(pub, signatureData, r, s)
-		if !verified {
-			return fmt.Errorf("input %d: invalid signature for UTXO %x:%d", i, input.PrevTxHash, input.PrevTxIndex)
+		// Authorize the spend with the validator of the output's script template
+		if err := us.SpendAuthRegistry().Authorize(tx, i, utxo); err != nil {
+			return err
 		}
 
 		totalInput += utxo.Value
@@ -334,7 +372,7 @@ func (us *UTXOSet) ValidateTransaction(tx *block.Transaction) error {
 	// Check for dust outputs (very small outputs that are uneconomical)
 	const dustThreshold = 546 // Satoshis, equivalent to Bitcoin's dust threshold
 	for i, output := range tx.Outputs {
-		if output.Value < dustThreshold {
+		if output.Value < dustThreshold && !output.IsData() {
 			return fmt.Errorf("output %d value %d is below dust threshold %d", i, output.Value, dustThreshold)
 		}
 	}
@@ -357,7 +395,7 @@ func (us *UTXOSet) ValidateTransactionBusinessLogic(tx *block.Transaction) error
 		}
 		// Validate outputs
 		for i, output := range tx.Outputs {
-			if output.Value == 0 {
+			if output.Value == 0 && !output.IsData() {
 				return fmt.Errorf("output %d has zero value", i)
 			}
 			if len(output.ScriptPubKey) == 0 {
@@ -430,7 +468,7 @@ func (us *UTXOSet) ValidateTransactionBusinessLogic(tx *block.Transaction) error
 	// Check for dust outputs (very small outputs that are uneconomical)
 	const dustThreshold = 546 // Satoshis, equivalent to Bitcoin's dust threshold
 	for i, output := range tx.Outputs {
-		if output.Value < dustThreshold {
+		if output.Value < dustThreshold && !output.IsData() {
 			return fmt.Errorf("output %d value %d is below dust threshold %d", i, output.Value, dustThreshold)
 		}
 	}
@@ -465,7 +503,7 @@ func (us *UTXOSet) ValidateTransactionInBlock(tx *block.Transaction, block *bloc
 		}
 		// Validate coinbase transaction outputs
 		for i, output := range tx.Outputs {
-			if output.Value == 0 {
+			if output.Value == 0 && !output.IsData() {
 				return fmt.Errorf("coinbase output %d has zero value", i)
 			}
 			if len(output.ScriptPubKey) == 0 {
@@ -513,49 +551,9 @@ func (us *UTXOSet) ValidateTransactionInBlock(tx *block.Transaction, block *bloc
 			// In a real implementation, you might want to enforce maturity requirements
 		}
 
-		// Verify signature length and structure
-		if len(input.ScriptSig) < 65+64 {
-			return fmt.Errorf("input %d: invalid scriptSig length: %d (expected >= 129)", i, len(input.ScriptSig))
-		}
-
-		// Extract public key and signature from ScriptSig
-		pubBytes := input.ScriptSig[:65]
-		rsBytes := input.ScriptSig[65:]
-
-		// Validate public key format
-		pubKey, err := btcec.ParsePubKey(pubBytes)
-		if err != nil {
-			return fmt.Errorf("input %d: failed to unmarshal public key from scriptSig: %v", i, err)
-		}
-		pub := pubKey.ToECDSA()
-
-		// Verify public key hash matches the UTXO's ScriptPubKey
-		pubKeyHash := sha256.Sum256(pubBytes)
-		expectedAddress := hex.EncodeToString(pubKeyHash[len(pubKeyHash)-20:])
-		utxoAddress := hex.EncodeToString(utxo.ScriptPubKey)
-
-		if expectedAddress != utxoAddress {
-			return fmt.Errorf("input %d: public key hash %s does not match UTXO scriptPubKey %s",
-				i, expectedAddress, utxoAddress)
-		}
-
-		// Extract R and S components from signature
-		if len(rsBytes) < 64 {
-			return fmt.Errorf("input %d: insufficient signature data", i)
-		}
-		r := new(big.Int).SetBytes(rsBytes[:32])
-		s := new(big.Int).SetBytes(rsBytes[32:64])
-
-		// Validate signature components
-		if r.Sign() <= 0 || s.Sign() <= 0 {
-			return fmt.Errorf("input %d: invalid signature components (R or S <= 0)", i)
-		}
-
-		// Verify signature
-		signatureData := us.getTxSignatureData(tx)
-		verified := ecdsa.Verify(pub, signatureData, r, s)
-		if !verified {
-			return fmt.Errorf("input %d: invalid signature for UTXO %x:%d", i, input.PrevTxHash, input.PrevTxIndex)
+		// Authorize the spend with the validator of the output's script template
+		if err := us.SpendAuthRegistry().Authorize(tx, i, utxo); err != nil {
+			return err
 		}
 
 		totalInput += utxo.Value
@@ -589,7 +587,7 @@ func (us *UTXOSet) ValidateTransactionInBlock(tx *block.Transaction, block *bloc
 	// Check for dust outputs (very small outputs that are uneconomical)
 	const dustThreshold = 546 // Satoshis, equivalent to Bitcoin's dust threshold
 	for i, output := range tx.Outputs {
-		if output.Value < dustThreshold {
+		if output.Value < dustThreshold && !output.IsData() {
 			return fmt.Errorf("output %d value %d is below dust threshold %d", i, output.Value, dustThreshold)
 		}
 	}
@@ -603,7 +601,7 @@ func (us *UTXOSet) GetStats() map[string]interface{} {
 	defer us.mu.RUnlock()
 
 	stats := make(map[string]interface{})
-	stats["total_utxos"] = len(us.utxos)
+	stats["total_utxos"] = len(us.utxos) + len(us.spilled)
 	stats["total_addresses"] = len(us.balances)
 
 	// Calculate total value
@@ -717,13 +715,7 @@ func (us *UTXOSet) GetSpendableUTXOs(address string, minValue uint64) []*UTXO {
 	us.mu.RLock()
 	defer us.mu.RUnlock()
 
-	var spendableUTXOs []*UTXO
-	for _, utxo := range us.utxos {
-		if utxo.Address == address && utxo.Value >= minValue {
-			spendableUTXOs = append(spendableUTXOs, utxo)
-		}
-	}
-	return spendableUTXOs
+	return us.addressUTXOsLocked(address, func(utxo *UTXO) bool { return utxo.Value >= minValue })
 }
 
 // GetUTXOCount returns the total number of UTXOs
@@ -731,7 +723,7 @@ func (us *UTXOSet) GetUTXOCount() int {
 	us.mu.RLock()
 	defer us.mu.RUnlock()
 
-	return len(us.utxos)
+	return len(us.utxos) + len(us.spilled)
 }
 
 // GetAddressCount returns the total number of addresses
@@ -751,6 +743,11 @@ func (us *UTXOSet) String() string {
 
 // getTxSignatureData creates the data to be signed for a transaction
 func (us *UTXOSet) getTxSignatureData(tx *block.Transaction) []byte {
+	return txSignatureData(tx)
+}
+
+// txSignatureData creates the data to be signed for a transaction
+func txSignatureData(tx *block.Transaction) []byte {
 	data := make([]byte, 0)
 
 	// Version
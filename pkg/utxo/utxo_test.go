@@ -172,6 +172,80 @@ func TestProcessBlock(t *testing.T) {
 	assert.Equal(t, uint64(0), us.GetBalance(minerAddrHex)) // Use hex-encoded address // Coinbase output should be spent
 }
 
+func TestProcessBlockKeepsPubKeyHashStartingWithOpReturn(t *testing.T) {
+	us := NewUTXOSet()
+
+	// One address in 256 starts with the data output opcode
+	pubKeyHash := append([]byte{block.OpReturn}, make([]byte, 19)...)
+	coinbaseTx := &block.Transaction{
+		Version: 1,
+		Outputs: []*block.TxOutput{{Value: 50, ScriptPubKey: pubKeyHash}},
+	}
+	coinbaseTx.Hash = calculateTxHash(coinbaseTx)
+
+	b := &block.Block{Header: &block.Header{Height: 1}, Transactions: []*block.Transaction{coinbaseTx}}
+	assert.NoError(t, us.ProcessBlock(b))
+	assert.NotNil(t, us.GetUTXO(coinbaseTx.Hash, 0))
+	assert.Equal(t, uint64(50), us.GetBalance(hex.EncodeToString(pubKeyHash)))
+}
+
+func TestProcessBlockIntraBlockSpends(t *testing.T) {
+	us := NewUTXOSet()
+
+	prior := &UTXO{TxHash: makeTestHash("prior"), TxIndex: 0, Value: 100, ScriptPubKey: []byte("alice"), Height: 1}
+	us.AddUTXOSafe(prior)
+
+	coinbaseTx := &block.Transaction{
+		Version: 1,
+		Outputs: []*block.TxOutput{{Value: 50, ScriptPubKey: []byte("miner")}},
+	}
+	coinbaseTx.Hash = calculateTxHash(coinbaseTx)
+
+	// tx1 spends a prior output, tx2 spends tx1's first output in the same block
+	tx1 := &block.Transaction{
+		Version: 1,
+		Inputs:  []*block.TxInput{{PrevTxHash: prior.TxHash, PrevTxIndex: 0, ScriptSig: []byte("sig1")}},
+		Outputs: []*block.TxOutput{
+			{Value: 60, ScriptPubKey: []byte("bob")},
+			{Value: 35, ScriptPubKey: []byte("alice")},
+		},
+	}
+	tx1.Hash = calculateTxHash(tx1)
+	tx2 := &block.Transaction{
+		Version: 1,
+		Inputs:  []*block.TxInput{{PrevTxHash: tx1.Hash, PrevTxIndex: 0, ScriptSig: []byte("sig2")}},
+		Outputs: []*block.TxOutput{{Value: 55, ScriptPubKey: []byte("carol")}},
+	}
+	tx2.Hash = calculateTxHash(tx2)
+
+	// A block listing the spender before the transaction it spends is rejected
+	// without modifying the set
+	reordered := &block.Block{
+		Header:       &block.Header{Height: 2},
+		Transactions: []*block.Transaction{coinbaseTx, tx2, tx1},
+	}
+	err := us.ProcessBlock(reordered)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "created by transaction 2 of the same block")
+	assert.Equal(t, 1, us.GetUTXOCount())
+	assert.NotNil(t, us.GetUTXO(prior.TxHash, 0))
+
+	b := &block.Block{
+		Header:       &block.Header{Height: 2},
+		Transactions: []*block.Transaction{coinbaseTx, tx1, tx2},
+	}
+	assert.NoError(t, us.ProcessBlock(b))
+
+	assert.Nil(t, us.GetUTXO(prior.TxHash, 0))
+	assert.Nil(t, us.GetUTXO(tx1.Hash, 0), "output spent within the block must not remain")
+	assert.NotNil(t, us.GetUTXO(tx1.Hash, 1))
+	assert.NotNil(t, us.GetUTXO(tx2.Hash, 0))
+	assert.NotNil(t, us.GetUTXO(coinbaseTx.Hash, 0))
+	assert.Equal(t, 3, us.GetUTXOCount())
+	assert.Equal(t, uint64(55), us.GetBalance(hex.EncodeToString([]byte("carol"))))
+	assert.Equal(t, uint64(0), us.GetBalance(hex.EncodeToString([]byte("bob"))))
+}
+
 func TestValidateTransaction_Enhanced(t *testing.T) {
 	us := NewUTXOSet()
 
@@ -3156,3 +3230,13 @@ func TestRemoveUTXOComprehensive(t *testing.T) {
 		assert.Equal(t, 1, us.GetAddressCount(), "Should have 1 address remaining")
 	})
 }
+
+func TestUTXOIsMature(t *testing.T) {
+	regular := &UTXO{Height: 50}
+	coinbase := &UTXO{Height: 50, IsCoinbase: true}
+
+	assert.True(t, regular.IsMature(50, DefaultCoinbaseMaturity))
+	assert.False(t, coinbase.IsMature(50, DefaultCoinbaseMaturity))
+	assert.False(t, coinbase.IsMature(148, DefaultCoinbaseMaturity))
+	assert.True(t, coinbase.IsMature(149, DefaultCoinbaseMaturity))
+}
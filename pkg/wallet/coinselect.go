@@ -0,0 +1,171 @@
+package wallet
+
+import (
+	"sort"
+
+	"github.com/palaseus/adrenochain/pkg/utxo"
+)
+
+// CoinSelector chooses the outputs funding a transaction.
+type CoinSelector interface {
+	// SelectCoins returns outputs from utxos worth at least target in
+	// total, and their total, or all of them if they are not worth enough.
+	SelectCoins(utxos []*utxo.UTXO, target uint64) ([]*utxo.UTXO, uint64)
+}
+
+// LargestFirst selects the largest outputs first, which keeps the number of
+// inputs low. It is the default strategy.
+type LargestFirst struct{}
+
+// SelectCoins implements CoinSelector.
+func (LargestFirst) SelectCoins(utxos []*utxo.UTXO, target uint64) ([]*utxo.UTXO, uint64) {
+	if len(utxos) == 0 {
+		return nil, 0
+	}
+
+	// Sort UTXOs by value (largest first) for better efficiency
+	sortedUTXOs := make([]*utxo.UTXO, len(utxos))
+	copy(sortedUTXOs, utxos)
+	sort.Slice(sortedUTXOs, func(i, j int) bool { return sortedUTXOs[i].Value > sortedUTXOs[j].Value })
+
+	var selectedUTXOs []*utxo.UTXO
+	var selectedAmount uint64
+
+	// Greedy selection with early termination
+	for _, utxo := range sortedUTXOs {
+		if selectedAmount >= target {
+			break
+		}
+		selectedUTXOs = append(selectedUTXOs, utxo)
+		selectedAmount += utxo.Value
+	}
+
+	return selectedUTXOs, selectedAmount
+}
+
+// PrivacyAware avoids spending outputs of different addresses together,
+// since doing so shows anyone reading the chain that the addresses belong
+// to the same owner. If the outputs of a single address can fund the
+// transaction, only those are spent; otherwise the outputs of as few
+// addresses as possible are combined, preferring addresses already linked
+// to each other.
+type PrivacyAware struct {
+	// Within selects among the outputs of the chosen addresses. Nil selects
+	// LargestFirst.
+	Within CoinSelector
+	// Linked reports whether two addresses have already been spent
+	// together, so combining them reveals nothing new. Nil treats every
+	// pair of addresses as unlinked.
+	Linked func(a, b string) bool
+}
+
+// SelectCoins implements CoinSelector.
+func (p PrivacyAware) SelectCoins(utxos []*utxo.UTXO, target uint64) ([]*utxo.UTXO, uint64) {
+	within := p.Within
+	if within == nil {
+		within = LargestFirst{}
+	}
+
+	// Group the outputs by address, keeping the order addresses appear in
+	var groups []*addressGroup
+	byAddress := make(map[string]*addressGroup)
+	for _, u := range utxos {
+		group, exists := byAddress[u.Address]
+		if !exists {
+			group = &addressGroup{address: u.Address}
+			byAddress[u.Address] = group
+			groups = append(groups, group)
+		}
+		group.utxos = append(group.utxos, u)
+		group.total += u.Value
+	}
+
+	// Prefer a single address, the one needing the fewest inputs and
+	// leaving the least change
+	var bestSelected []*utxo.UTXO
+	var bestAmount uint64
+	for _, group := range groups {
+		if group.total < target {
+			continue
+		}
+		selected, amount := within.SelectCoins(group.utxos, target)
+		if bestSelected == nil || len(selected) < len(bestSelected) ||
+			(len(selected) == len(bestSelected) && amount < bestAmount) {
+			bestSelected, bestAmount = selected, amount
+		}
+	}
+	if bestSelected != nil || len(groups) == 0 {
+		return bestSelected, bestAmount
+	}
+
+	// Otherwise combine addresses, starting from the one worth the most and
+	// adding those linked to the addresses chosen so far before the others,
+	// larger ones first
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].total > groups[j].total })
+	chosen := []*addressGroup{groups[0]}
+	remaining := groups[1:]
+	total := groups[0].total
+	for total < target && len(remaining) > 0 {
+		next := 0
+		for i, group := range remaining {
+			if p.linkedTo(group, chosen) {
+				next = i
+				break
+			}
+		}
+		chosen = append(chosen, remaining[next])
+		total += remaining[next].total
+		remaining = append(remaining[:next], remaining[next+1:]...)
+	}
+
+	var candidates []*utxo.UTXO
+	for _, group := range chosen {
+		candidates = append(candidates, group.utxos...)
+	}
+	return within.SelectCoins(candidates, target)
+}
+
+// linkedTo reports whether group's address is linked to any chosen address.
+func (p PrivacyAware) linkedTo(group *addressGroup, chosen []*addressGroup) bool {
+	if p.Linked == nil {
+		return false
+	}
+	for _, c := range chosen {
+		if p.Linked(group.address, c.address) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddressesLinked reports whether the wallet created a transaction spending
+// outputs of both addresses, which links them for anyone reading the chain.
+func (w *Wallet) AddressesLinked(a, b string) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.linked[a][b]
+}
+
+// linkAddresses records that the given addresses were spent together.
+func (w *Wallet) linkAddresses(addresses []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, a := range addresses {
+		for _, b := range addresses {
+			if a == b {
+				continue
+			}
+			if w.linked[a] == nil {
+				w.linked[a] = make(map[string]bool)
+			}
+			w.linked[a][b] = true
+		}
+	}
+}
+
+// addressGroup holds the outputs of one address.
+type addressGroup struct {
+	address string
+	utxos   []*utxo.UTXO
+	total   uint64
+}
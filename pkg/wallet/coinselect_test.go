@@ -0,0 +1,158 @@
+package wallet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/palaseus/adrenochain/pkg/utxo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLargestFirst(t *testing.T) {
+	utxos := []*utxo.UTXO{
+		{TxHash: []byte("a"), Value: 300},
+		{TxHash: []byte("b"), Value: 900},
+		{TxHash: []byte("c"), Value: 500},
+	}
+
+	// The largest outputs are spent until the target is covered
+	selected, amount := LargestFirst{}.SelectCoins(utxos, 1000)
+	assert.Equal(t, uint64(1400), amount)
+	assert.Equal(t, []uint64{900, 500}, valuesOf(selected))
+
+	selected, amount = LargestFirst{}.SelectCoins(utxos, 800)
+	assert.Equal(t, uint64(900), amount)
+	assert.Equal(t, []uint64{900}, valuesOf(selected))
+
+	// Without enough funds every output is returned
+	_, amount = LargestFirst{}.SelectCoins(utxos, 5000)
+	assert.Equal(t, uint64(1700), amount)
+	selected, amount = LargestFirst{}.SelectCoins(nil, 100)
+	assert.Empty(t, selected)
+	assert.Zero(t, amount)
+}
+
+func valuesOf(utxos []*utxo.UTXO) []uint64 {
+	values := make([]uint64, len(utxos))
+	for i, u := range utxos {
+		values[i] = u.Value
+	}
+	return values
+}
+
+func TestPrivacyAwarePrefersSingleAddress(t *testing.T) {
+	utxos := []*utxo.UTXO{
+		{TxHash: []byte("a1"), Value: 600, Address: "alice"},
+		{TxHash: []byte("b1"), Value: 900, Address: "bob"},
+		{TxHash: []byte("a2"), Value: 500, Address: "alice"},
+	}
+
+	// Largest first mixes the two addresses
+	selected, amount := LargestFirst{}.SelectCoins(utxos, 1000)
+	assert.Equal(t, uint64(1500), amount)
+	assert.ElementsMatch(t, []string{"bob", "alice"}, addressesOf(selected))
+
+	// The privacy strategy funds the transaction from one address
+	selected, amount = PrivacyAware{}.SelectCoins(utxos, 1000)
+	assert.Equal(t, uint64(1100), amount)
+	assert.Equal(t, []string{"alice", "alice"}, addressesOf(selected))
+
+	// Of the addresses able to pay, the one needing fewer inputs wins
+	selected, amount = PrivacyAware{}.SelectCoins(utxos, 800)
+	assert.Equal(t, uint64(900), amount)
+	assert.Equal(t, []string{"bob"}, addressesOf(selected))
+}
+
+func TestPrivacyAwarePrefersLinkedAddresses(t *testing.T) {
+	utxos := []*utxo.UTXO{
+		{TxHash: []byte("a"), Value: 500, Address: "alice"},
+		{TxHash: []byte("b"), Value: 400, Address: "bob"},
+		{TxHash: []byte("c"), Value: 300, Address: "carol"},
+	}
+
+	// No address can pay alone, so the fewest are combined
+	selected, amount := PrivacyAware{}.SelectCoins(utxos, 750)
+	assert.Equal(t, uint64(900), amount)
+	assert.ElementsMatch(t, []string{"alice", "bob"}, addressesOf(selected))
+
+	// An address already linked to the first one is combined before others
+	linked := func(a, b string) bool {
+		return (a == "alice" && b == "carol") || (a == "carol" && b == "alice")
+	}
+	selected, amount = PrivacyAware{Linked: linked}.SelectCoins(utxos, 750)
+	assert.Equal(t, uint64(800), amount)
+	assert.ElementsMatch(t, []string{"alice", "carol"}, addressesOf(selected))
+
+	// Without enough funds every output is returned
+	_, amount = PrivacyAware{}.SelectCoins(utxos, 5000)
+	assert.Equal(t, uint64(1200), amount)
+	selected, amount = PrivacyAware{}.SelectCoins(nil, 100)
+	assert.Empty(t, selected)
+	assert.Zero(t, amount)
+}
+
+func TestPrivacyAwareSpendsWalletAccounts(t *testing.T) {
+	config := DefaultWalletConfig()
+	config.CoinSelector = PrivacyAware{}
+	config.SpendFromAllAccounts = true
+	us := utxo.NewUTXOSet()
+	wallet, err := NewWallet(config, us, newTestStorage(t))
+	require.NoError(t, err)
+
+	sender := wallet.GetDefaultAccount()
+	other, err := wallet.CreateAccount()
+	require.NoError(t, err)
+	fund := func(account *Account, hash string, value uint64) {
+		us.AddUTXO(&utxo.UTXO{TxHash: []byte(hash), Value: value, ScriptPubKey: account.PublicKey, Address: account.Address, Height: 1})
+	}
+	fund(sender, "sender", 3000)
+	fund(other, "other1", 6000)
+	fund(other, "other2", 5000)
+
+	toPrivKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+	toAddress := wallet.generateChecksumAddress(toPrivKey.ToECDSA())
+	// Another account pays on its own rather than being combined with the
+	// sender, and the change still returns to the sender
+	tx, err := wallet.CreateTransaction(sender.Address, toAddress, 8000, 546)
+	require.NoError(t, err)
+	require.Len(t, tx.Inputs, 2)
+	for _, input := range tx.Inputs {
+		assert.True(t, bytes.HasPrefix(input.ScriptSig, other.PublicKey))
+	}
+	senderHash, err := addressToPubKeyHash(sender.Address)
+	require.NoError(t, err)
+	assert.Equal(t, senderHash, tx.Outputs[len(tx.Outputs)-1].ScriptPubKey)
+	valid, err := wallet.VerifyTransaction(tx)
+	require.NoError(t, err)
+	assert.True(t, valid)
+	assert.False(t, wallet.AddressesLinked(sender.Address, other.Address))
+
+	// When no account can pay alone the accounts are combined, each input
+	// signed by its own key, and the addresses are then linked
+	tx, err = wallet.CreateTransaction(sender.Address, toAddress, 12000, 1000)
+	require.NoError(t, err)
+	require.Len(t, tx.Inputs, 3)
+	for i, input := range tx.Inputs {
+		owner := other.PublicKey
+		if bytes.Equal(input.PrevTxHash, []byte("sender")) {
+			owner = sender.PublicKey
+		}
+		assert.True(t, bytes.HasPrefix(input.ScriptSig, owner), "input %d", i)
+	}
+	valid, err = wallet.VerifyTransaction(tx)
+	require.NoError(t, err)
+	assert.True(t, valid)
+	assert.True(t, wallet.AddressesLinked(sender.Address, other.Address))
+	assert.True(t, wallet.AddressesLinked(other.Address, sender.Address))
+}
+
+func addressesOf(utxos []*utxo.UTXO) []string {
+	addresses := make([]string, len(utxos))
+	for i, u := range utxos {
+		addresses[i] = u.Address
+	}
+	return addresses
+}
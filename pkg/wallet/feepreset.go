@@ -0,0 +1,165 @@
+package wallet
+
+import (
+	"fmt"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/mempool"
+)
+
+// FeeLevel names a fee-rate preset trading cost against confirmation time.
+type FeeLevel string
+
+const (
+	// FeeEconomy pays the least, for transactions that can wait.
+	FeeEconomy FeeLevel = "economy"
+	// FeeNormal aims for confirmation within about an hour.
+	FeeNormal FeeLevel = "normal"
+	// FeePriority aims for confirmation in the next block.
+	FeePriority FeeLevel = "priority"
+)
+
+// ParseFeeLevel returns the fee level named s.
+func ParseFeeLevel(s string) (FeeLevel, error) {
+	switch level := FeeLevel(s); level {
+	case FeeEconomy, FeeNormal, FeePriority:
+		return level, nil
+	default:
+		return "", fmt.Errorf("unknown fee level %q: must be %s, %s or %s", s, FeeEconomy, FeeNormal, FeePriority)
+	}
+}
+
+// FeeEstimator estimates the fee rate a transaction should pay to confirm
+// within a number of blocks; ok is false when the estimate is a fallback
+// for lack of data. *mempool.Mempool implements it.
+type FeeEstimator interface {
+	EstimateFeeRate(blocks uint64) (feeRate uint64, ok bool)
+}
+
+// FeePresets holds the confirmation target, in blocks, each fee level asks
+// the fee estimator for. Zero targets use DefaultFeePresets.
+type FeePresets struct {
+	Economy  uint64
+	Normal   uint64
+	Priority uint64
+}
+
+// DefaultFeePresets are the default confirmation targets of the fee levels.
+var DefaultFeePresets = FeePresets{
+	Economy:  144,
+	Normal:   6,
+	Priority: 1,
+}
+
+// target returns the confirmation target of level.
+func (p FeePresets) target(level FeeLevel) (uint64, error) {
+	var target, fallback uint64
+	switch level {
+	case FeeEconomy:
+		target, fallback = p.Economy, DefaultFeePresets.Economy
+	case FeeNormal:
+		target, fallback = p.Normal, DefaultFeePresets.Normal
+	case FeePriority:
+		target, fallback = p.Priority, DefaultFeePresets.Priority
+	default:
+		return 0, fmt.Errorf("unknown fee level %q", level)
+	}
+	if target == 0 {
+		target = fallback
+	}
+	return target, nil
+}
+
+// GetFeePreset returns the fee rate, per byte, of a fee level: the rate the
+// fee estimator expects to confirm within the level's target. A level never
+// pays less than the levels below it, nor less than the minimum relay fee
+// rate. It fails without an estimator, or when the estimator has no data for
+// the level's target, rather than pass a guess off as the level's rate.
+func (w *Wallet) GetFeePreset(level FeeLevel) (uint64, error) {
+	if w.feeEstimator == nil {
+		return 0, fmt.Errorf("no fee estimator to price fee level %q", level)
+	}
+
+	feeRate := w.relayPolicy().MinFeeRate
+	for _, l := range []FeeLevel{FeeEconomy, FeeNormal, FeePriority} {
+		target, err := w.feePresets.target(l)
+		if err != nil {
+			return 0, err
+		}
+		estimate, ok := w.feeEstimator.EstimateFeeRate(target)
+		if l == level {
+			if !ok {
+				return 0, fmt.Errorf("no fee estimate for fee level %q yet", level)
+			}
+			return max(feeRate, estimate), nil
+		}
+		// Lower levels only raise the floor once they are estimated
+		if ok {
+			feeRate = max(feeRate, estimate)
+		}
+	}
+	return 0, fmt.Errorf("unknown fee level %q", level)
+}
+
+// CreateTransactionWithFeeLevel creates a transaction like CreateTransaction,
+// paying the fee rate of a fee level for its estimated size instead of a
+// fixed fee.
+func (w *Wallet) CreateTransactionWithFeeLevel(fromAddress, toAddress string, amount uint64, level FeeLevel) (*block.Transaction, error) {
+	feeRate, err := w.GetFeePreset(level)
+	if err != nil {
+		return nil, err
+	}
+	fee, err := w.estimateFee(fromAddress, amount, feeRate)
+	if err != nil {
+		return nil, err
+	}
+	return w.CreateTransaction(fromAddress, toAddress, amount, fee)
+}
+
+// maxSignatureSize is the size of the longest DER signature of the wallet's
+// keys, used to estimate the size of a transaction before it is signed.
+const maxSignatureSize = 72
+
+// estimateFee returns the fee a payment of amount from fromAddress to one
+// recipient pays at feeRate, sizing the transaction with the inputs the
+// coin selector picks and a change output, and never less than the dust
+// threshold CreateTransaction requires.
+func (w *Wallet) estimateFee(fromAddress string, amount, feeRate uint64) (uint64, error) {
+	account := w.GetAccount(fromAddress)
+	if account == nil {
+		return 0, fmt.Errorf("account not found: %s", fromAddress)
+	}
+	scriptPubKey, err := addressToPubKeyHash(fromAddress)
+	if err != nil {
+		return 0, fmt.Errorf("invalid sender address: %w", err)
+	}
+	utxos := w.utxoSet.GetAddressUTXOs(fromAddress)
+
+	// The fee decides the inputs, whose number decides the fee, so size the
+	// transaction until the selection covers its own fee
+	fee := uint64(mempool.DustThreshold)
+	for i := 0; i <= len(utxos); i++ {
+		selected, _ := w.coinSelector.SelectCoins(utxos, amount+fee)
+
+		template := &block.Transaction{Version: 1}
+		for range max(len(selected), 1) {
+			template.Inputs = append(template.Inputs, &block.TxInput{
+				ScriptSig: make([]byte, len(account.PublicKey)+maxSignatureSize),
+			})
+		}
+		for range 2 {
+			template.Outputs = append(template.Outputs, &block.TxOutput{ScriptPubKey: scriptPubKey})
+		}
+
+		size := mempool.TransactionSize(template)
+		if w.relayPolicy().WeightAccounting {
+			size = mempool.TransactionVSize(template)
+		}
+		next := max(feeRate*size, mempool.DustThreshold)
+		if next <= fee {
+			return fee, nil
+		}
+		fee = next
+	}
+	return fee, nil
+}
@@ -0,0 +1,89 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/palaseus/adrenochain/pkg/mempool"
+	"github.com/palaseus/adrenochain/pkg/utxo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubFeeEstimator estimates fee rates that fall with the confirmation
+// target.
+type stubFeeEstimator struct{}
+
+func (stubFeeEstimator) EstimateFeeRate(blocks uint64) (uint64, bool) {
+	return 1000 / blocks, true
+}
+
+// fallbackFeeEstimator has no data to estimate from.
+type fallbackFeeEstimator struct{}
+
+func (fallbackFeeEstimator) EstimateFeeRate(blocks uint64) (uint64, bool) {
+	return 1, false
+}
+
+func TestGetFeePreset(t *testing.T) {
+	config := DefaultWalletConfig()
+	config.FeeEstimator = stubFeeEstimator{}
+	us := utxo.NewUTXOSet()
+	wallet, err := NewWallet(config, us, newTestStorage(t))
+	require.NoError(t, err)
+
+	// Each level maps to its own fee rate, rising with urgency
+	economy, err := wallet.GetFeePreset(FeeEconomy)
+	require.NoError(t, err)
+	normal, err := wallet.GetFeePreset(FeeNormal)
+	require.NoError(t, err)
+	priority, err := wallet.GetFeePreset(FeePriority)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1000/144), economy)
+	assert.Equal(t, uint64(1000/6), normal)
+	assert.Equal(t, uint64(1000), priority)
+	assert.Less(t, economy, normal)
+	assert.Less(t, normal, priority)
+
+	_, err = wallet.GetFeePreset("urgent")
+	assert.Error(t, err)
+	level, err := ParseFeeLevel("priority")
+	require.NoError(t, err)
+	assert.Equal(t, FeePriority, level)
+	_, err = ParseFeeLevel("urgent")
+	assert.Error(t, err)
+
+	// A transaction created at a level pays at least the level's fee rate
+	fromAccount := wallet.GetDefaultAccount()
+	us.AddUTXO(&utxo.UTXO{
+		TxHash:       []byte("test_tx_hash_fee_preset"),
+		Value:        2000000,
+		ScriptPubKey: fromAccount.PublicKey,
+		Address:      fromAccount.Address,
+		Height:       1,
+	})
+	toPrivKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+	toAddress := wallet.generateChecksumAddress(toPrivKey.ToECDSA())
+
+	tx, err := wallet.CreateTransactionWithFeeLevel(fromAccount.Address, toAddress, 1000000, FeeNormal)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, tx.Fee/mempool.TransactionSize(tx), normal)
+	assert.Less(t, tx.Fee/mempool.TransactionSize(tx), priority)
+
+	// Without an estimate no level has a fee rate
+	wallet, err = NewWallet(DefaultWalletConfig(), us, newTestStorage(t))
+	require.NoError(t, err)
+	_, err = wallet.GetFeePreset(FeeNormal)
+	assert.Error(t, err)
+	_, err = wallet.CreateTransactionWithFeeLevel(fromAccount.Address, toAddress, 1000000, FeeNormal)
+	assert.Error(t, err)
+
+	config.FeeEstimator = fallbackFeeEstimator{}
+	wallet, err = NewWallet(config, us, newTestStorage(t))
+	require.NoError(t, err)
+	for _, level := range []FeeLevel{FeeEconomy, FeeNormal, FeePriority} {
+		_, err := wallet.GetFeePreset(level)
+		assert.Error(t, err)
+	}
+}
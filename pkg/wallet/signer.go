@@ -0,0 +1,46 @@
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"fmt"
+)
+
+// Signer produces the signatures the wallet puts in scriptSigs. Keys do not
+// have to live in the wallet: a Signer may forward requests to an HSM or a
+// remote signing service. keyID identifies the key to sign with; the wallet
+// passes the address of the account whose outputs are spent.
+type Signer interface {
+	// Sign signs a transaction signature hash and returns the signature in
+	// canonical (low-S) DER encoding.
+	Sign(hash []byte, keyID string) ([]byte, error)
+}
+
+// localSigner signs with the private keys held in the wallet's accounts.
+type localSigner struct {
+	wallet *Wallet
+}
+
+// Sign signs hash with the private key of the account whose address is keyID.
+func (s *localSigner) Sign(hash []byte, keyID string) ([]byte, error) {
+	account := s.wallet.GetAccount(keyID)
+	if account == nil {
+		return nil, fmt.Errorf("account not found: %s", keyID)
+	}
+
+	privateKey, err := bytesToPrivateKey(account.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert private key: %w", err)
+	}
+
+	r, sig, err := ecdsa.Sign(rand.Reader, privateKey, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := encodeSignatureDER(r, sig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode signature: %w", err)
+	}
+	return signature, nil
+}
@@ -0,0 +1,200 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/utxo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockSigner stands in for an external signer such as an HSM.
+type mockSigner struct {
+	signature []byte
+	err       error
+	hashes    [][]byte
+	keyIDs    []string
+}
+
+func (m *mockSigner) Sign(hash []byte, keyID string) ([]byte, error) {
+	m.hashes = append(m.hashes, hash)
+	m.keyIDs = append(m.keyIDs, keyID)
+	return m.signature, m.err
+}
+
+func TestCreateTransactionWithSigner(t *testing.T) {
+	r, s := big.NewInt(1), big.NewInt(2)
+	der, err := encodeSignatureDER(r, s)
+	require.NoError(t, err)
+	signer := &mockSigner{signature: der}
+	config := DefaultWalletConfig()
+	config.Signer = signer
+	us := utxo.NewUTXOSet()
+	wallet, err := NewWallet(config, us, newTestStorage(t))
+	require.NoError(t, err)
+
+	fromAccount := wallet.GetDefaultAccount()
+	us.AddUTXO(&utxo.UTXO{
+		TxHash:       []byte("test_tx_hash_signer"),
+		TxIndex:      0,
+		Value:        50000,
+		ScriptPubKey: fromAccount.PublicKey,
+		Address:      fromAccount.Address,
+		Height:       1,
+	})
+
+	toPrivKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+	toAddress := wallet.generateChecksumAddress(toPrivKey.ToECDSA())
+
+	tx, err := wallet.CreateTransaction(fromAccount.Address, toAddress, 20000, 1000)
+	require.NoError(t, err)
+
+	// The signer was asked to sign the signature hash with the sender's key
+	require.Len(t, signer.hashes, 1)
+	assert.Equal(t, tx.Hash, signer.hashes[0])
+	assert.Equal(t, []string{fromAccount.Address}, signer.keyIDs)
+
+	// and its signature ends up as R and S in every scriptSig after the
+	// public key
+	expected := append(append([]byte{}, fromAccount.PublicKey...), concatRS(r, s)...)
+	for _, input := range tx.Inputs {
+		assert.Equal(t, expected, input.ScriptSig)
+	}
+
+	// A failing signer, or one returning something other than a DER
+	// signature, aborts transaction creation
+	nonce := fromAccount.Nonce
+	signer.signature = []byte("hsm-signature")
+	_, err = wallet.CreateTransaction(fromAccount.Address, toAddress, 20000, 1000)
+	assert.ErrorContains(t, err, "invalid signature")
+	assert.Equal(t, nonce, fromAccount.Nonce)
+
+	signer.err = errors.New("hsm unavailable")
+	_, err = wallet.CreateTransaction(fromAccount.Address, toAddress, 20000, 1000)
+	assert.ErrorIs(t, err, signer.err)
+	assert.Equal(t, nonce, fromAccount.Nonce)
+}
+
+func TestLocalSignerProducesVerifiableSignatures(t *testing.T) {
+	us := utxo.NewUTXOSet()
+	wallet, err := NewWallet(DefaultWalletConfig(), us, newTestStorage(t))
+	require.NoError(t, err)
+
+	fromAccount := wallet.GetDefaultAccount()
+	us.AddUTXO(&utxo.UTXO{
+		TxHash:       []byte("test_tx_hash_local_signer"),
+		TxIndex:      0,
+		Value:        50000,
+		ScriptPubKey: fromAccount.PublicKey,
+		Address:      fromAccount.Address,
+		Height:       1,
+	})
+
+	toPrivKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+	tx, err := wallet.CreateTransaction(fromAccount.Address, wallet.generateChecksumAddress(toPrivKey.ToECDSA()), 20000, 1000)
+	require.NoError(t, err)
+
+	valid, err := wallet.VerifyTransaction(tx)
+	require.NoError(t, err)
+	assert.True(t, valid)
+
+	_, err = wallet.signer.Sign(tx.Hash, "unknown")
+	assert.ErrorContains(t, err, "account not found")
+}
+
+func TestSignTransactionWithHashType(t *testing.T) {
+	wallet, err := NewWallet(DefaultWalletConfig(), utxo.NewUTXOSet(), newTestStorage(t))
+	require.NoError(t, err)
+	account := wallet.GetDefaultAccount()
+
+	newTx := func() *block.Transaction {
+		return &block.Transaction{
+			Version: 1,
+			Inputs: []*block.TxInput{
+				{PrevTxHash: []byte("sighash_input_0"), Sequence: 0xffffffff},
+				{PrevTxHash: []byte("sighash_input_1"), Sequence: 0xffffffff},
+			},
+			Outputs: []*block.TxOutput{
+				{Value: 1000, ScriptPubKey: []byte("payee")},
+				{Value: 500, ScriptPubKey: []byte("change")},
+			},
+			Fee: 10,
+		}
+	}
+
+	// SIGHASH_ALL signs the whole transaction once, without a type byte
+	all := newTx()
+	require.NoError(t, wallet.SignTransactionWithHashType(all, account.Address, utxo.SigHashAll))
+	single := newTx()
+	require.NoError(t, wallet.SignTransactionWithHashType(single, account.Address, utxo.SigHashSingle))
+	for i := range single.Inputs {
+		assert.Equal(t, byte(utxo.SigHashSingle), single.Inputs[i].ScriptSig[len(single.Inputs[i].ScriptSig)-1])
+		assert.NotEqual(t, all.Inputs[i].ScriptSig, single.Inputs[i].ScriptSig)
+	}
+
+	valid, err := wallet.VerifyTransaction(all)
+	require.NoError(t, err)
+	assert.True(t, valid)
+	valid, err = wallet.VerifyTransaction(single)
+	require.NoError(t, err)
+	assert.True(t, valid)
+
+	// Each SIGHASH_SINGLE signature only covers its own output
+	single.Outputs[1].ScriptPubKey = []byte("someone else")
+	_, err = wallet.VerifyTransaction(single)
+	assert.ErrorContains(t, err, "input 1: signature verification failed")
+
+	assert.Error(t, wallet.SignTransactionWithHashType(newTx(), account.Address, utxo.SigHashType(0x04)))
+}
+
+func TestHashTypeSignaturesPassConsensus(t *testing.T) {
+	us := utxo.NewUTXOSet()
+	wallet, err := NewWallet(DefaultWalletConfig(), us, newTestStorage(t))
+	require.NoError(t, err)
+	account := wallet.GetDefaultAccount()
+
+	// Outputs paying the account as consensus sees them: the last 20 bytes
+	// of the SHA-256 of its public key
+	pubKeyHash := sha256.Sum256(account.PublicKey)
+	for i := byte(0); i < 2; i++ {
+		us.AddUTXO(utxo.NewUTXO(bytes.Repeat([]byte{i + 1}, 32), 0, 10000, pubKeyHash[12:], account.Address, false, 1))
+	}
+
+	for _, hashType := range []utxo.SigHashType{
+		utxo.SigHashAll,
+		utxo.SigHashNone,
+		utxo.SigHashSingle,
+		utxo.SigHashAll | utxo.SigHashAnyoneCanPay,
+		utxo.SigHashNone | utxo.SigHashAnyoneCanPay,
+		utxo.SigHashSingle | utxo.SigHashAnyoneCanPay,
+	} {
+		t.Run(hashType.String(), func(t *testing.T) {
+			tx := &block.Transaction{
+				Version: 1,
+				Inputs: []*block.TxInput{
+					{PrevTxHash: bytes.Repeat([]byte{1}, 32), Sequence: 0xffffffff},
+					{PrevTxHash: bytes.Repeat([]byte{2}, 32), Sequence: 0xffffffff},
+				},
+				Outputs: []*block.TxOutput{
+					{Value: 12000, ScriptPubKey: bytes.Repeat([]byte{0xaa}, 20)},
+					{Value: 7000, ScriptPubKey: pubKeyHash[12:]},
+				},
+				Fee: 1000,
+			}
+			require.NoError(t, wallet.SignTransactionWithHashType(tx, account.Address, hashType))
+
+			assert.NoError(t, us.ValidateTransaction(tx))
+			valid, err := wallet.VerifyTransaction(tx)
+			require.NoError(t, err)
+			assert.True(t, valid)
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package wallet
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/palaseus/adrenochain/pkg/block"
+)
+
+// DefaultEventBufferSize is the default number of wallet events buffered
+// for a slow reader of Events.
+const DefaultEventBufferSize = 64
+
+// TxRetentionDepth is how many blocks deep a confirmed or conflicted
+// transaction is buried before the wallet stops tracking it. Reorganizations
+// are not expected to reach below it.
+const TxRetentionDepth = 100
+
+// TxStatus is the state of a transaction the wallet created.
+type TxStatus int
+
+const (
+	// TxStatusPending transactions are not in the chain yet.
+	TxStatusPending TxStatus = iota
+	// TxStatusConfirmed transactions are in a block of the chain.
+	TxStatusConfirmed
+	// TxStatusConflicted transactions can no longer confirm because a
+	// transaction in the chain spends one of their inputs.
+	TxStatusConflicted
+)
+
+// String returns the name of the status.
+func (s TxStatus) String() string {
+	switch s {
+	case TxStatusPending:
+		return "pending"
+	case TxStatusConfirmed:
+		return "confirmed"
+	case TxStatusConflicted:
+		return "conflicted"
+	default:
+		return fmt.Sprintf("TxStatus(%d)", int(s))
+	}
+}
+
+// WalletTransaction is a transaction created by the wallet and its status.
+type WalletTransaction struct {
+	Tx     *block.Transaction
+	Status TxStatus
+	// BlockHash is the block that confirmed the transaction or, for a
+	// conflicted one, the block that confirmed the conflicting transaction.
+	BlockHash []byte
+	// BlockHeight is the height of BlockHash.
+	BlockHeight uint64
+	// ConflictingTxHash is the transaction in the chain spending an input of
+	// a conflicted transaction.
+	ConflictingTxHash []byte
+}
+
+// Event notifies that a wallet transaction changed status.
+type Event struct {
+	TxHash            []byte
+	Status            TxStatus
+	BlockHash         []byte
+	ConflictingTxHash []byte
+}
+
+// Events returns the channel on which the wallet notifies status changes of
+// its transactions. Events are dropped while the channel's buffer is full.
+func (w *Wallet) Events() <-chan Event {
+	return w.events
+}
+
+// TrackTransaction starts following the status of a transaction, e.g. one
+// signed by the wallet but built elsewhere. Transactions made by
+// CreateTransaction are tracked already.
+func (w *Wallet) TrackTransaction(tx *block.Transaction) {
+	if tx == nil || len(tx.Hash) == 0 {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	key := fmt.Sprintf("%x", tx.Hash)
+	if _, exists := w.transactions[key]; !exists {
+		w.transactions[key] = &WalletTransaction{Tx: tx, Status: TxStatusPending}
+	}
+}
+
+// GetTransaction returns a copy of a tracked transaction, or nil if the
+// wallet does not track it.
+func (w *Wallet) GetTransaction(txHash []byte) *WalletTransaction {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	wtx, exists := w.transactions[fmt.Sprintf("%x", txHash)]
+	if !exists {
+		return nil
+	}
+	copied := *wtx
+	return &copied
+}
+
+// ConnectBlock updates the wallet transactions for a block added to the
+// chain. Transactions in the block become confirmed, and pending ones
+// spending an output the block spends, or an output of a transaction that
+// became conflicted, become conflicted. Confirmed and conflicted
+// transactions buried TxRetentionDepth blocks deep are no longer tracked.
+func (w *Wallet) ConnectBlock(b *block.Block) {
+	if b == nil || b.Header == nil {
+		return
+	}
+	blockHash, height := b.CalculateHash(), b.Header.Height
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for key, wtx := range w.transactions {
+		if wtx.Status != TxStatusPending && wtx.BlockHeight+TxRetentionDepth <= height {
+			delete(w.transactions, key)
+		}
+	}
+	if len(w.transactions) == 0 {
+		return
+	}
+
+	spentBy := make(map[string]*WalletTransaction)
+	for _, wtx := range w.transactions {
+		if wtx.Status != TxStatusPending {
+			continue
+		}
+		for _, input := range wtx.Tx.Inputs {
+			spentBy[fmt.Sprintf("%x:%d", input.PrevTxHash, input.PrevTxIndex)] = wtx
+		}
+	}
+
+	var conflicted []*WalletTransaction
+	for _, tx := range b.Transactions {
+		if wtx, exists := w.transactions[fmt.Sprintf("%x", tx.Hash)]; exists {
+			if wtx.Status != TxStatusConfirmed {
+				w.setStatus(wtx, TxStatusConfirmed, blockHash, height, nil)
+			}
+			continue
+		}
+		for _, input := range tx.Inputs {
+			wtx := spentBy[fmt.Sprintf("%x:%d", input.PrevTxHash, input.PrevTxIndex)]
+			if wtx != nil && wtx.Status == TxStatusPending {
+				w.setStatus(wtx, TxStatusConflicted, blockHash, height, tx.Hash)
+				conflicted = append(conflicted, wtx)
+			}
+		}
+	}
+
+	// Pending transactions spending the outputs of a conflicted one can never
+	// confirm either
+	for len(conflicted) > 0 {
+		parent := conflicted[0]
+		conflicted = conflicted[1:]
+		for _, wtx := range w.transactions {
+			if wtx.Status == TxStatusPending && spendsOutputOf(wtx.Tx, parent.Tx.Hash) {
+				w.setStatus(wtx, TxStatusConflicted, blockHash, height, parent.ConflictingTxHash)
+				conflicted = append(conflicted, wtx)
+			}
+		}
+	}
+}
+
+// spendsOutputOf reports whether tx spends an output of the transaction
+// with hash txHash.
+func spendsOutputOf(tx *block.Transaction, txHash []byte) bool {
+	for _, input := range tx.Inputs {
+		if bytes.Equal(input.PrevTxHash, txHash) {
+			return true
+		}
+	}
+	return false
+}
+
+// DisconnectBlock updates the wallet transactions for a block removed from
+// the chain by a reorganization. Transactions it confirmed, or that it made
+// conflicted, become pending again.
+func (w *Wallet) DisconnectBlock(b *block.Block) {
+	if b == nil {
+		return
+	}
+	blockHash := b.CalculateHash()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, wtx := range w.transactions {
+		if wtx.Status != TxStatusPending && bytes.Equal(wtx.BlockHash, blockHash) {
+			w.setStatus(wtx, TxStatusPending, nil, 0, nil)
+		}
+	}
+}
+
+// setStatus changes the status of a transaction and notifies it. The caller
+// must hold w.mu.
+func (w *Wallet) setStatus(wtx *WalletTransaction, status TxStatus, blockHash []byte, height uint64, conflictingTxHash []byte) {
+	wtx.Status = status
+	wtx.BlockHash = blockHash
+	wtx.BlockHeight = height
+	wtx.ConflictingTxHash = conflictingTxHash
+
+	select {
+	case w.events <- Event{
+		TxHash:            wtx.Tx.Hash,
+		Status:            status,
+		BlockHash:         blockHash,
+		ConflictingTxHash: conflictingTxHash,
+	}:
+	default:
+		// The reader is not keeping up; drop rather than block block processing
+	}
+}
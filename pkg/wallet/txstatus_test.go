@@ -0,0 +1,87 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/utxo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConflictingTransactionMarksWalletTransactionConflicted(t *testing.T) {
+	us := utxo.NewUTXOSet()
+	wallet, err := NewWallet(DefaultWalletConfig(), us, newTestStorage(t))
+	require.NoError(t, err)
+	account := wallet.GetDefaultAccount()
+
+	us.AddUTXO(&utxo.UTXO{
+		TxHash:       []byte("conflict_funding_tx"),
+		TxIndex:      0,
+		Value:        5000,
+		ScriptPubKey: account.PublicKey,
+		Address:      account.Address,
+		Height:       1,
+	})
+	toKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+	toAddress := wallet.generateChecksumAddress(toKey.ToECDSA())
+
+	original, err := wallet.CreateTransaction(account.Address, toAddress, 1000, 546)
+	require.NoError(t, err)
+	require.Equal(t, TxStatusPending, wallet.GetTransaction(original.Hash).Status)
+
+	// A transaction of the wallet spending the change of the original one
+	child := block.NewTransaction(
+		[]*block.TxInput{{PrevTxHash: original.Hash, PrevTxIndex: 1, Sequence: 0xffffffff}},
+		[]*block.TxOutput{{Value: 2000, ScriptPubKey: []byte("elsewhere")}},
+		546,
+	)
+	wallet.TrackTransaction(child)
+
+	// The same coin is spent again, e.g. by a fee bump broadcast elsewhere
+	conflicting := block.NewTransaction(
+		[]*block.TxInput{{PrevTxHash: original.Inputs[0].PrevTxHash, PrevTxIndex: original.Inputs[0].PrevTxIndex, Sequence: 0xffffffff}},
+		[]*block.TxOutput{{Value: 4000, ScriptPubKey: []byte("double spend")}},
+		1000,
+	)
+	b := block.NewBlock(make([]byte, 32), 2, 1)
+	b.AddTransaction(conflicting)
+	wallet.ConnectBlock(b)
+
+	conflicted := wallet.GetTransaction(original.Hash)
+	require.NotNil(t, conflicted)
+	assert.Equal(t, TxStatusConflicted, conflicted.Status)
+	assert.Equal(t, conflicting.Hash, conflicted.ConflictingTxHash)
+	assert.Equal(t, b.CalculateHash(), conflicted.BlockHash)
+	assert.Equal(t, TxStatusConflicted, wallet.GetTransaction(child.Hash).Status, "descendants conflict too")
+
+	events := map[string]Event{}
+	for len(wallet.Events()) > 0 {
+		event := <-wallet.Events()
+		events[string(event.TxHash)] = event
+	}
+	require.Len(t, events, 2)
+	assert.Equal(t, TxStatusConflicted, events[string(original.Hash)].Status)
+	assert.Equal(t, conflicting.Hash, events[string(original.Hash)].ConflictingTxHash)
+	assert.Equal(t, TxStatusConflicted, events[string(child.Hash)].Status)
+
+	// A reorganization removing the conflicting block makes them pending again
+	wallet.DisconnectBlock(b)
+	assert.Equal(t, TxStatusPending, wallet.GetTransaction(original.Hash).Status)
+	assert.Equal(t, TxStatusPending, wallet.GetTransaction(child.Hash).Status)
+
+	confirming := block.NewBlock(make([]byte, 32), 2, 1)
+	confirming.AddTransaction(original)
+	wallet.ConnectBlock(confirming)
+	assert.Equal(t, TxStatusConfirmed, wallet.GetTransaction(original.Hash).Status)
+	assert.Equal(t, TxStatusPending, wallet.GetTransaction(child.Hash).Status)
+
+	// Once buried TxRetentionDepth blocks deep it is no longer tracked
+	wallet.ConnectBlock(block.NewBlock(make([]byte, 32), 2+TxRetentionDepth-1, 1))
+	assert.NotNil(t, wallet.GetTransaction(original.Hash))
+	wallet.ConnectBlock(block.NewBlock(make([]byte, 32), 2+TxRetentionDepth, 1))
+	assert.Nil(t, wallet.GetTransaction(original.Hash))
+	assert.NotNil(t, wallet.GetTransaction(child.Hash), "pending transactions stay tracked")
+}
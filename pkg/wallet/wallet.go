@@ -1,7 +1,7 @@
 // Package wallet provides a secure cryptocurrency wallet implementation with the following security features:
 //
 // SECURITY FEATURES:
-// - Canonical DER signature encoding with low-S enforcement to prevent signature malleability
+// - Canonical low-S signatures to prevent signature malleability
 // - Secure key derivation using PBKDF2 with 100,000 iterations and per-wallet salt
 // - AES-GCM authenticated encryption for wallet storage
 // - Base58Check address encoding with checksums to prevent typos
@@ -9,10 +9,11 @@
 // - Proper change output handling to prevent fund loss
 //
 // SIGNATURE FORMAT:
-// - ECDSA signatures encoded in ASN.1 DER format
+// - Signers return ECDSA signatures encoded in ASN.1 DER format
 // - Low-S enforcement (s <= N/2) to prevent signature malleability
 // - Public key stored as uncompressed 65-byte format
-// - Wire format: [public_key(65)][der_signature(variable)]
+// - Wire format, as consensus verifies it:
+//   [public_key(65)][r(32)][s(32)][sighash_type(1), absent for SIGHASH_ALL]
 //
 // ADDRESS FORMAT:
 // - Base58Check encoding with double SHA256 checksum
@@ -48,6 +49,7 @@ import (
 
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/palaseus/adrenochain/pkg/block"
+	"github.com/palaseus/adrenochain/pkg/mempool"
 	"github.com/palaseus/adrenochain/pkg/storage"
 	"github.com/palaseus/adrenochain/pkg/utxo"
 	"github.com/mr-tron/base58"
@@ -65,6 +67,24 @@ type Wallet struct {
 	walletFilePath string           // Added walletFilePath field
 	passphrase     string           // Added passphrase field
 	salt           []byte           // Persistent salt for key derivation
+
+	coinbaseMaturity uint64                // Confirmations required before coinbase outputs are spendable
+	chainHeight      uint64                // Height of the best block known to the wallet
+	syncing          bool                  // Set while the node is in initial block download
+	maxFee           uint64                // Absolute fee ceiling enforced by CreateTransaction
+	maxFeePercent    uint64                // Fee ceiling as a percentage of the amount sent
+	allowHighFee     bool                  // Disables the fee ceilings
+	unconfirmed      map[string]*utxo.UTXO // Incoming outputs seen in the mempool but not yet mined
+	signer           Signer                // Produces the signatures of created transactions
+	coinSelector     CoinSelector          // Chooses the outputs funding created transactions
+	spendAllAccounts bool                  // Funds created transactions from the outputs of every account
+	feeEstimator     FeeEstimator          // Estimates the fee rates of the fee presets
+	feePresets       FeePresets            // Confirmation targets of the fee presets
+	policy           mempool.RelayPolicy   // Relay rules created transactions are checked against
+
+	transactions map[string]*WalletTransaction // Created transactions and their status, keyed by hash
+	events       chan Event                    // Status changes of the tracked transactions
+	linked       map[string]map[string]bool    // Addresses spent together in created transactions
 }
 
 // Account represents a wallet account
@@ -89,6 +109,70 @@ type WalletConfig struct {
 	KeyType    KeyType
 	Passphrase string
 	WalletFile string // Added WalletFile to config
+
+	// CoinbaseMaturity is the number of confirmations a coinbase output needs
+	// before it counts as spendable. Zero selects utxo.DefaultCoinbaseMaturity.
+	CoinbaseMaturity uint64
+
+	// MaxFee is the largest absolute fee CreateTransaction accepts. Zero
+	// selects DefaultMaxFee.
+	MaxFee uint64
+	// MaxFeePercent is the largest fee CreateTransaction accepts as a
+	// percentage of the amount sent. The minimum fee is always accepted.
+	// Zero selects DefaultMaxFeePercent.
+	MaxFeePercent uint64
+	// AllowHighFee disables the MaxFee and MaxFeePercent sanity checks.
+	AllowHighFee bool
+
+	// Signer signs the transactions the wallet creates, e.g. through an HSM.
+	// Nil signs with the private keys held in the wallet.
+	Signer Signer
+
+	// CoinSelector chooses the outputs funding the transactions the wallet
+	// creates, e.g. PrivacyAware to avoid linking addresses. Nil selects
+	// LargestFirst. A PrivacyAware selector without Linked treats the
+	// addresses the wallet already spent together as linked.
+	CoinSelector CoinSelector
+	// SpendFromAllAccounts funds transactions from the outputs of every
+	// account rather than only the sending one, whose address still
+	// receives the change. Each input is signed by the account it spends.
+	SpendFromAllAccounts bool
+
+	// FeeEstimator estimates the fee rates GetFeePreset returns, typically
+	// the node's mempool. Without one the fee levels cannot be used.
+	FeeEstimator FeeEstimator
+	// FeePresets sets the confirmation target of each fee level. Zero
+	// targets use DefaultFeePresets.
+	FeePresets FeePresets
+
+	// RelayPolicy is the mempool relay policy CreateTransaction checks the
+	// transactions it builds against. Nil selects the policy of the default
+	// mempool configuration.
+	RelayPolicy *mempool.RelayPolicy
+
+	// EventBufferSize is the number of events, such as a transaction
+	// becoming conflicted, buffered for the reader of Events. Zero selects
+	// DefaultEventBufferSize.
+	EventBufferSize int
+}
+
+// ErrNonStandardTransaction is returned by CreateTransaction for a
+// transaction the mempool would reject under its relay policy.
+var ErrNonStandardTransaction = errors.New("transaction would be rejected by the mempool")
+
+const (
+	// DefaultMaxFee is the default absolute fee ceiling.
+	DefaultMaxFee = 1000000
+	// DefaultMaxFeePercent is the default fee ceiling relative to the amount.
+	DefaultMaxFeePercent = 50
+)
+
+// BalanceDetails breaks an address balance down by how usable the funds are.
+type BalanceDetails struct {
+	Total     uint64 // Total includes confirmed and unconfirmed incoming funds
+	Confirmed uint64 // Confirmed includes every mined output, mature or not
+	Spendable uint64 // Spendable excludes immature coinbase and unconfirmed funds
+	Syncing   bool   // Syncing is set during initial block download, when mined funds are counted in Total only
 }
 
 // DefaultWalletConfig returns the default wallet configuration
@@ -97,6 +181,10 @@ func DefaultWalletConfig() *WalletConfig {
 		KeyType:    KeyTypeECDSA,
 		Passphrase: "",
 		WalletFile: "wallet.dat", // Default wallet file name
+
+		CoinbaseMaturity: utxo.DefaultCoinbaseMaturity,
+		MaxFee:           DefaultMaxFee,
+		MaxFeePercent:    DefaultMaxFeePercent,
 	}
 }
 
@@ -136,7 +224,50 @@ func NewWallet(config *WalletConfig, us *utxo.UTXOSet, s *storage.Storage) (*Wal
 		walletFilePath: config.WalletFile,
 		passphrase:     config.Passphrase,
 		salt:           nil, // Will be generated on first encryption
+
+		coinbaseMaturity: config.CoinbaseMaturity,
+		maxFee:           config.MaxFee,
+		maxFeePercent:    config.MaxFeePercent,
+		allowHighFee:     config.AllowHighFee,
+		unconfirmed:      make(map[string]*utxo.UTXO),
+		signer:           config.Signer,
+		coinSelector:     config.CoinSelector,
+		spendAllAccounts: config.SpendFromAllAccounts,
+		feeEstimator:     config.FeeEstimator,
+		feePresets:       config.FeePresets,
+		transactions:     make(map[string]*WalletTransaction),
+		linked:           make(map[string]map[string]bool),
+	}
+	if config.RelayPolicy != nil {
+		wallet.policy = *config.RelayPolicy
 	}
+	if wallet.coinbaseMaturity == 0 {
+		wallet.coinbaseMaturity = utxo.DefaultCoinbaseMaturity
+	}
+	if wallet.maxFee == 0 {
+		wallet.maxFee = DefaultMaxFee
+	}
+	if wallet.maxFeePercent == 0 {
+		wallet.maxFeePercent = DefaultMaxFeePercent
+	}
+	if wallet.signer == nil {
+		wallet.signer = &localSigner{wallet: wallet}
+	}
+	if wallet.coinSelector == nil {
+		wallet.coinSelector = LargestFirst{}
+	}
+	if privacy, ok := wallet.coinSelector.(PrivacyAware); ok && privacy.Linked == nil {
+		privacy.Linked = wallet.AddressesLinked
+		wallet.coinSelector = privacy
+	}
+	eventBufferSize := config.EventBufferSize
+	if eventBufferSize < 0 {
+		return nil, fmt.Errorf("event buffer size must not be negative: %d", eventBufferSize)
+	}
+	if eventBufferSize == 0 {
+		eventBufferSize = DefaultEventBufferSize
+	}
+	wallet.events = make(chan Event, eventBufferSize)
 
 	// Create default account
 	if err := wallet.createDefaultAccount(); err != nil {
@@ -475,21 +606,92 @@ func (w *Wallet) GetAllAccounts() []*Account {
 	return accounts
 }
 
+// Recipient is an address paid by a transaction and the amount it receives.
+type Recipient struct {
+	Address string
+	Amount  uint64
+}
+
 // CreateTransaction creates a new transaction
 func (w *Wallet) CreateTransaction(fromAddress, toAddress string, amount, fee uint64) (*block.Transaction, error) {
+	return w.CreateMultiOutputTransaction(fromAddress, []Recipient{{Address: toAddress, Amount: amount}}, fee)
+}
+
+// CreateMultiOutputTransaction creates one transaction paying every
+// recipient, in order, with the change returned to the sender in a last
+// output. Fee ceilings apply to the total amount paid.
+func (w *Wallet) CreateMultiOutputTransaction(fromAddress string, recipients []Recipient, fee uint64) (*block.Transaction, error) {
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("transaction has no recipients")
+	}
+	var amount uint64
+	outputs := make([]*block.TxOutput, 0, len(recipients)+1) // recipients + change
+	for _, recipient := range recipients {
+		if amount+recipient.Amount < amount {
+			return nil, fmt.Errorf("total amount overflows")
+		}
+		amount += recipient.Amount
+
+		recipPubKeyHash, err := addressToPubKeyHash(recipient.Address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient address: %w", err)
+		}
+		outputs = append(outputs, &block.TxOutput{
+			Value:        recipient.Amount,
+			ScriptPubKey: recipPubKeyHash,
+		})
+	}
+	return w.createTransaction(fromAddress, outputs, amount, fee)
+}
+
+// CreateDataTransaction creates a transaction from the default account
+// embedding data in a provably unspendable output, with the change returned
+// to the account. The data may not exceed MaxDataOutputSize.
+func (w *Wallet) CreateDataTransaction(data []byte, fee uint64) (*block.Transaction, error) {
+	account := w.GetDefaultAccount()
+	if account == nil {
+		return nil, fmt.Errorf("wallet has no default account")
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("data output is empty")
+	}
+	if len(data) > block.MaxDataOutputSize {
+		return nil, fmt.Errorf("%w: data of %d bytes exceeds the maximum %d", ErrNonStandardTransaction, len(data), block.MaxDataOutputSize)
+	}
+	output, err := block.NewDataOutput(data)
+	if err != nil {
+		return nil, err
+	}
+	return w.createTransaction(account.Address, []*block.TxOutput{output}, 0, fee)
+}
+
+// createTransaction funds outputs paying amount in total from fromAddress,
+// adds a change output and signs the transaction.
+func (w *Wallet) createTransaction(fromAddress string, outputs []*block.TxOutput, amount, fee uint64) (*block.Transaction, error) {
 	account := w.GetAccount(fromAddress)
 	if account == nil {
 		return nil, fmt.Errorf("account not found: %s", fromAddress)
 	}
 
 	// Validate minimum fee rate (dust threshold: 546 satoshis)
-	const dustThreshold = 546
+	const dustThreshold = mempool.DustThreshold
 	if fee < dustThreshold {
 		return nil, fmt.Errorf("fee too low: minimum fee is %d", dustThreshold)
 	}
+	if err := w.checkFeeCeiling(amount, fee, dustThreshold); err != nil {
+		return nil, err
+	}
 
-	// Get available UTXOs for the sender
+	// Get available UTXOs for the sender, followed by those of the other
+	// accounts if the wallet spends from all of them
 	utxos := w.utxoSet.GetAddressUTXOs(fromAddress)
+	if w.spendAllAccounts {
+		for _, other := range w.GetAllAccounts() {
+			if other.Address != fromAddress {
+				utxos = append(utxos, w.utxoSet.GetAddressUTXOs(other.Address)...)
+			}
+		}
+	}
 	if len(utxos) == 0 {
 		return nil, fmt.Errorf("no available UTXOs for address: %s", fromAddress)
 	}
@@ -506,15 +708,21 @@ func (w *Wallet) CreateTransaction(fromAddress, toAddress string, amount, fee ui
 		return nil, fmt.Errorf("insufficient funds: need %d, have %d", totalNeeded, totalAvailable)
 	}
 
-	// Select UTXOs to spend using improved coin selection algorithm
-	selectedUTXOs, selectedAmount := w.selectOptimalUTXOs(utxos, totalNeeded)
+	// Select UTXOs to spend using the configured coin selection strategy
+	selectedUTXOs, selectedAmount := w.coinSelector.SelectCoins(utxos, totalNeeded)
 	if selectedAmount < totalNeeded {
 		return nil, fmt.Errorf("insufficient funds after UTXO selection: need %d, have %d", totalNeeded, selectedAmount)
 	}
 
-	// Create transaction inputs
+	// Create transaction inputs, each signed by the account owning the
+	// output it spends
 	inputs := make([]*block.TxInput, 0, len(selectedUTXOs))
+	owners := make([]string, 0, len(selectedUTXOs))
 	for _, utxo := range selectedUTXOs {
+		owner := fromAddress
+		if w.spendAllAccounts && w.GetAccount(utxo.Address) != nil {
+			owner = utxo.Address
+		}
 		input := &block.TxInput{
 			PrevTxHash:  utxo.TxHash,
 			PrevTxIndex: utxo.TxIndex,
@@ -522,24 +730,12 @@ func (w *Wallet) CreateTransaction(fromAddress, toAddress string, amount, fee ui
 			Sequence:    0xffffffff,
 		}
 		inputs = append(inputs, input)
+		owners = append(owners, owner)
 	}
 
-	// Create transaction outputs
-	outputs := make([]*block.TxOutput, 0, 2) // recipient + change
-
-	// Output to recipient
-	recipPubKeyHash, err := addressToPubKeyHash(toAddress)
-	if err != nil {
-		return nil, fmt.Errorf("invalid recipient address: %w", err)
-	}
-	outputs = append(outputs, &block.TxOutput{
-		Value:        amount,
-		ScriptPubKey: recipPubKeyHash,
-	})
-
 	// Calculate change and create change output if needed (respecting dust threshold)
 	change := selectedAmount - totalNeeded
-	if change > dustThreshold {
+	if change >= dustThreshold {
 		// Create change output back to sender
 		senderPubKeyHash, err := addressToPubKeyHash(fromAddress)
 		if err != nil {
@@ -550,7 +746,12 @@ func (w *Wallet) CreateTransaction(fromAddress, toAddress string, amount, fee ui
 			ScriptPubKey: senderPubKeyHash,
 		})
 	} else if change > 0 {
-		// Add dust change to fee instead of creating dust output
+		// Add dust change to fee instead of creating dust output, as long as
+		// the higher fee is still acceptable
+		if err := w.checkFeeCeiling(amount, fee+change, dustThreshold); err != nil {
+			return nil, fmt.Errorf("%w: change %d is below the dust threshold %d and cannot be added to the fee: %v",
+				ErrNonStandardTransaction, change, dustThreshold, err)
+		}
 		fee += change
 	}
 
@@ -564,52 +765,96 @@ func (w *Wallet) CreateTransaction(fromAddress, toAddress string, amount, fee ui
 	}
 
 	// Sign transaction
-	if err := w.SignTransaction(tx, fromAddress); err != nil {
+	if err := w.signInputs(tx, owners, utxo.SigHashAll); err != nil {
 		return nil, fmt.Errorf("failed to sign transaction: %w", err)
 	}
 
+	// Check the signed transaction against the rules the mempool applies,
+	// so that it is not rejected after broadcast
+	if err := w.relayPolicy().Check(tx); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNonStandardTransaction, err)
+	}
+
 	// Update account nonce
 	account.Nonce++
 
+	w.linkAddresses(owners)
+	w.TrackTransaction(tx)
+
 	return tx, nil
 }
 
-// SignTransaction signs a transaction with the specified account's private key
+// SignTransaction signs a transaction for the specified account through the
+// wallet's signer
 func (w *Wallet) SignTransaction(tx *block.Transaction, fromAddress string) error {
-	account := w.GetAccount(fromAddress)
-	if account == nil {
-		return fmt.Errorf("account not found: %s", fromAddress)
-	}
+	return w.SignTransactionWithHashType(tx, fromAddress, utxo.SigHashAll)
+}
 
-	// Convert private key bytes back to ECDSA private key
-	privateKey, err := bytesToPrivateKey(account.PrivateKey)
-	if err != nil {
-		return fmt.Errorf("failed to convert private key: %w", err)
+// SignTransactionWithHashType signs every input of a transaction for the
+// specified account with signatures of the given hash type. Each scriptSig
+// holds the public key and the signature as 32-byte R and S values, the
+// encoding consensus verifies. Signatures other than SIGHASH_ALL cover
+// each input separately and end with their type byte.
+func (w *Wallet) SignTransactionWithHashType(tx *block.Transaction, fromAddress string, hashType utxo.SigHashType) error {
+	owners := make([]string, len(tx.Inputs))
+	for i := range owners {
+		owners[i] = fromAddress
+	}
+	return w.signInputs(tx, owners, hashType)
+}
+
+// signInputs signs every input of a transaction for the account owning it,
+// owners holding the address of each input's account, with signatures of
+// the given hash type
+func (w *Wallet) signInputs(tx *block.Transaction, owners []string, hashType utxo.SigHashType) error {
+	accounts := make([]*Account, len(owners))
+	for i, owner := range owners {
+		if accounts[i] = w.GetAccount(owner); accounts[i] == nil {
+			return fmt.Errorf("account not found: %s", owner)
+		}
+	}
+	if !hashType.Valid() {
+		return fmt.Errorf("invalid signature hash type 0x%02x", byte(hashType))
 	}
 
 	// Create signature data (this should be the hash that will be used for verification)
 	signatureData := w.createSignatureData(tx)
 
-	// Sign the data
-	r, s, err := ecdsa.Sign(rand.Reader, privateKey, signatureData)
-	if err != nil {
-		return fmt.Errorf("failed to sign transaction: %w", err)
-	}
-
-	// Encode signature in canonical DER format
-	signature, err := encodeSignatureDER(r, s)
-	if err != nil {
-		return fmt.Errorf("failed to encode signature: %w", err)
+	// Sign the data once per account and convert the signer's DER
+	// signature to R and S
+	signatures := make(map[string][]byte)
+	if hashType == utxo.SigHashAll {
+		for _, owner := range owners {
+			if _, signed := signatures[owner]; signed {
+				continue
+			}
+			signature, err := w.signScriptSig(signatureData, owner)
+			if err != nil {
+				return fmt.Errorf("failed to sign transaction: %w", err)
+			}
+			signatures[owner] = signature
+		}
 	}
 
-	pubBytes := publicKeyToBytes(&privateKey.PublicKey)
-
 	// Add signature to all inputs
 	for i := range tx.Inputs {
-		// Store public key followed by DER signature
-		combined := make([]byte, 0, len(pubBytes)+len(signature))
+		inputSignature := signatures[owners[i]]
+		if hashType != utxo.SigHashAll {
+			hash, err := utxo.TxSignatureHash(tx, i, hashType)
+			if err != nil {
+				return fmt.Errorf("failed to sign input %d: %w", i, err)
+			}
+			if inputSignature, err = w.signScriptSig(hash, owners[i]); err != nil {
+				return fmt.Errorf("failed to sign input %d: %w", i, err)
+			}
+			inputSignature = append(inputSignature, byte(hashType))
+		}
+
+		// Store public key followed by the signature
+		pubBytes := accounts[i].PublicKey
+		combined := make([]byte, 0, len(pubBytes)+len(inputSignature))
 		combined = append(combined, pubBytes...)
-		combined = append(combined, signature...)
+		combined = append(combined, inputSignature...)
 		tx.Inputs[i].ScriptSig = combined
 	}
 
@@ -619,6 +864,23 @@ func (w *Wallet) SignTransaction(tx *block.Transaction, fromAddress string) erro
 	return nil
 }
 
+// signScriptSig signs a hash through the wallet's signer and returns the
+// signature as the 64 bytes of R and S that go into a scriptSig
+func (w *Wallet) signScriptSig(hash []byte, fromAddress string) ([]byte, error) {
+	der, err := w.signer.Sign(hash, fromAddress)
+	if err != nil {
+		return nil, err
+	}
+	r, s, err := decodeSignatureDER(der)
+	if err != nil {
+		return nil, fmt.Errorf("signer returned an invalid signature: %w", err)
+	}
+	if err := verifyCanonicalSignature(r, s, btcec.S256()); err != nil {
+		return nil, fmt.Errorf("signer returned a non-canonical signature: %w", err)
+	}
+	return concatRS(r, s), nil
+}
+
 // VerifyTransaction verifies the cryptographic signatures of a transaction
 func (w *Wallet) VerifyTransaction(tx *block.Transaction) (bool, error) {
 	for i, input := range tx.Inputs {
@@ -628,7 +890,20 @@ func (w *Wallet) VerifyTransaction(tx *block.Transaction) (bool, error) {
 		}
 
 		pubBytes := input.ScriptSig[:65]
-		sigBytes := input.ScriptSig[65:]
+		sigBytes, hashType, err := utxo.SplitSigHashType(input.ScriptSig[65:], 64)
+		if err != nil {
+			return false, fmt.Errorf("input %d: %w", i, err)
+		}
+		if len(sigBytes) != 64 {
+			return false, fmt.Errorf("input %d: signature is %d bytes, expected 64", i, len(sigBytes))
+		}
+		signedHash := tx.Hash
+		if hashType != utxo.SigHashAll {
+			var err error
+			if signedHash, err = utxo.TxSignatureHash(tx, i, hashType); err != nil {
+				return false, fmt.Errorf("input %d: %w", i, err)
+			}
+		}
 
 		// Parse the public key
 		btcPubKey, err := btcec.ParsePubKey(pubBytes)
@@ -640,18 +915,17 @@ func (w *Wallet) VerifyTransaction(tx *block.Transaction) (bool, error) {
 		pub := btcPubKey.ToECDSA()
 
 		// Decode the signature
-		r, s, err := decodeSignatureDER(sigBytes)
-		if err != nil {
-			return false, fmt.Errorf("input %d: failed to decode signature: %w", i, err)
-		}
+		r := new(big.Int).SetBytes(sigBytes[:32])
+		s := new(big.Int).SetBytes(sigBytes[32:])
 
 		// Verify canonical form
 		if err := verifyCanonicalSignature(r, s, btcec.S256()); err != nil {
 			return false, fmt.Errorf("input %d: signature not in canonical form: %w", i, err)
 		}
 
-		// Verify signature against the transaction hash (which should be the signature data hash)
-		if !ecdsa.Verify(pub, tx.Hash, r, s) {
+		// Verify signature against the transaction hash (which should be the
+		// signature data hash) or the hash its type selects
+		if !ecdsa.Verify(pub, signedHash, r, s) {
 			return false, fmt.Errorf("input %d: signature verification failed", i)
 		}
 	}
@@ -746,6 +1020,123 @@ func (w *Wallet) GetBalance(address string) uint64 {
 	return 0
 }
 
+// SetChainHeight records the height of the current best block, which is
+// used to decide whether coinbase outputs have matured.
+func (w *Wallet) SetChainHeight(height uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.chainHeight = height
+}
+
+// SetAllowHighFee enables or disables the override of the fee sanity
+// ceilings checked by CreateTransaction.
+func (w *Wallet) SetAllowHighFee(allow bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.allowHighFee = allow
+}
+
+// checkFeeCeiling rejects a fee above the absolute maximum, or above the
+// maximum percentage of a non-zero amount when it exceeds the minimum fee,
+// unless high fees are explicitly allowed.
+func (w *Wallet) checkFeeCeiling(amount, fee, minFee uint64) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.allowHighFee {
+		return nil
+	}
+	if fee > w.maxFee {
+		return fmt.Errorf("fee too high: %d exceeds maximum fee %d", fee, w.maxFee)
+	}
+	if amount > 0 && fee > minFee && fee*100 > amount*w.maxFeePercent {
+		return fmt.Errorf("fee too high: %d is more than %d%% of amount %d", fee, w.maxFeePercent, amount)
+	}
+	return nil
+}
+
+// relayPolicy returns the relay policy transactions are checked against,
+// falling back to the policy of the default mempool configuration.
+func (w *Wallet) relayPolicy() mempool.RelayPolicy {
+	if w.policy == (mempool.RelayPolicy{}) {
+		return mempool.DefaultMempoolConfig().RelayPolicy()
+	}
+	return w.policy
+}
+
+// SetInitialBlockDownload records whether the node is still catching up with
+// the network. While it is, the wallet's view of the chain is incomplete and
+// mined outputs are not reported as confirmed.
+func (w *Wallet) SetInitialBlockDownload(ibd bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.syncing = ibd
+}
+
+// AddUnconfirmedUTXO records an incoming output that has been seen in the
+// mempool but not yet mined. It is counted in the total balance only.
+func (w *Wallet) AddUnconfirmedUTXO(u *utxo.UTXO) {
+	if u == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.unconfirmed[fmt.Sprintf("%x:%d", u.TxHash, u.TxIndex)] = u
+}
+
+// RemoveUnconfirmedUTXO forgets an unconfirmed incoming output, e.g. once it
+// has been mined or its transaction dropped from the mempool.
+func (w *Wallet) RemoveUnconfirmedUTXO(txHash []byte, txIndex uint32) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.unconfirmed, fmt.Sprintf("%x:%d", txHash, txIndex))
+}
+
+// GetBalanceDetailed returns the total, confirmed and spendable balance of an
+// address. Confirmed funds come from the UTXO set; immature coinbase outputs
+// are confirmed but not spendable, and unconfirmed incoming outputs only
+// contribute to the total. During initial block download no funds are
+// reported as confirmed or spendable.
+func (w *Wallet) GetBalanceDetailed(address string) *BalanceDetails {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	details := &BalanceDetails{}
+	if w.utxoSet != nil {
+		for _, u := range w.utxoSet.GetAddressUTXOs(address) {
+			details.Confirmed += u.Value
+			if u.IsMature(w.chainHeight, w.coinbaseMaturity) {
+				details.Spendable += u.Value
+			}
+		}
+	}
+
+	details.Total = details.Confirmed
+	for _, u := range w.unconfirmed {
+		if u.Address != address {
+			continue
+		}
+		// Outputs that already made it into the UTXO set are counted as confirmed
+		if w.utxoSet != nil && w.utxoSet.GetUTXO(u.TxHash, u.TxIndex) != nil {
+			continue
+		}
+		details.Total += u.Value
+	}
+
+	if w.syncing {
+		details.Syncing = true
+		details.Confirmed = 0
+		details.Spendable = 0
+	}
+	return details
+}
+
 // ImportPrivateKey imports a private key and creates an account
 func (w *Wallet) ImportPrivateKey(privateKeyHex string) (*Account, error) {
 	// Decode hex string
@@ -870,6 +1261,21 @@ func concatRS(r, s *big.Int) []byte {
 	return out
 }
 
+// AddressScript returns the output script paying to a base58-encoded
+// address: the 20-byte public key hash it encodes.
+func AddressScript(address string) ([]byte, error) {
+	return addressToPubKeyHash(address)
+}
+
+// ScriptAddress returns the base58-encoded address of a public key hash
+// script, the inverse of AddressScript.
+func ScriptAddress(script []byte) string {
+	versioned := append([]byte{0x00}, script...)
+	hash1 := sha256.Sum256(versioned)
+	hash2 := sha256.Sum256(hash1[:])
+	return base58.Encode(append(versioned, hash2[:4]...))
+}
+
 // addressToPubKeyHash converts a base58-encoded address string to its byte representation (public key hash)
 func addressToPubKeyHash(address string) ([]byte, error) {
 	// Since this is a package-level function, we need to create a temporary wallet instance
@@ -1000,39 +1406,3 @@ func generateSalt() ([]byte, error) {
 	_, err := rand.Read(salt)
 	return salt, err
 }
-
-// selectOptimalUTXOs implements an improved coin selection algorithm
-// that minimizes the number of inputs while avoiding dust outputs
-func (w *Wallet) selectOptimalUTXOs(utxos []*utxo.UTXO, targetAmount uint64) ([]*utxo.UTXO, uint64) {
-	if len(utxos) == 0 {
-		return nil, 0
-	}
-
-	// Sort UTXOs by value (largest first) for better efficiency
-	sortedUTXOs := make([]*utxo.UTXO, len(utxos))
-	copy(sortedUTXOs, utxos)
-
-	// Simple sort by value (descending)
-	for i := 0; i < len(sortedUTXOs)-1; i++ {
-		for j := i + 1; j < len(sortedUTXOs); j++ {
-			if sortedUTXOs[i].Value < sortedUTXOs[j].Value {
-				sortedUTXOs[i], sortedUTXOs[j] = sortedUTXOs[j], sortedUTXOs[i]
-			}
-		}
-	}
-
-	// Try to find exact match first
-	var selectedUTXOs []*utxo.UTXO
-	var selectedAmount uint64
-
-	// Greedy selection with early termination
-	for _, utxo := range sortedUTXOs {
-		if selectedAmount >= targetAmount {
-			break
-		}
-		selectedUTXOs = append(selectedUTXOs, utxo)
-		selectedAmount += utxo.Value
-	}
-
-	return selectedUTXOs, selectedAmount
-}
@@ -10,6 +10,7 @@ import (
 	"github.com/palaseus/adrenochain/pkg/storage" // Added import
 	"github.com/palaseus/adrenochain/pkg/utxo"
 	"github.com/stretchr/testify/assert" // Added import for assert
+	"github.com/stretchr/testify/require"
 )
 
 // Helper function to create a temporary storage for tests
@@ -185,6 +186,176 @@ func TestSignTransaction(t *testing.T) {
 	assert.True(t, valid, "Transaction signature verification failed")
 }
 
+func TestCreateTransactionFeeCeiling(t *testing.T) {
+	s := newTestStorage(t)
+	config := DefaultWalletConfig()
+	config.MaxFee = 5000
+	us := utxo.NewUTXOSet()
+	wallet, err := NewWallet(config, us, s)
+	assert.NoError(t, err)
+
+	fromAccount := wallet.GetDefaultAccount()
+	us.AddUTXO(&utxo.UTXO{
+		TxHash:       []byte("test_tx_hash_fee_ceiling"),
+		TxIndex:      0,
+		Value:        50000,
+		ScriptPubKey: fromAccount.PublicKey,
+		Address:      fromAccount.Address,
+		Height:       1,
+	})
+
+	toPrivKey, err := btcec.NewPrivateKey()
+	assert.NoError(t, err)
+	toAddress := wallet.generateChecksumAddress(toPrivKey.ToECDSA())
+
+	// Above the absolute maximum
+	_, err = wallet.CreateTransaction(fromAccount.Address, toAddress, 20000, 6000)
+	assert.ErrorContains(t, err, "exceeds maximum fee")
+
+	// Above half of the amount
+	_, err = wallet.CreateTransaction(fromAccount.Address, toAddress, 2000, 1500)
+	assert.ErrorContains(t, err, "more than 50% of amount")
+
+	// The minimum fee is accepted however small the amount
+	_, err = wallet.CreateTransaction(fromAccount.Address, toAddress, 600, 546)
+	assert.NoError(t, err)
+
+	// An amount below the dust threshold would not be relayed
+	_, err = wallet.CreateTransaction(fromAccount.Address, toAddress, 500, 546)
+	assert.ErrorIs(t, err, ErrNonStandardTransaction)
+	assert.ErrorContains(t, err, "below dust threshold")
+
+	// The override accepts excessive fees
+	wallet.SetAllowHighFee(true)
+	tx, err := wallet.CreateTransaction(fromAccount.Address, toAddress, 20000, 6000)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(6000), tx.Fee)
+	_, err = wallet.CreateTransaction(fromAccount.Address, toAddress, 2000, 1500)
+	assert.NoError(t, err)
+}
+
+func TestCreateTransactionDustChange(t *testing.T) {
+	newFundedWallet := func(value uint64) (*Wallet, string) {
+		wallet, err := NewWallet(DefaultWalletConfig(), utxo.NewUTXOSet(), newTestStorage(t))
+		require.NoError(t, err)
+		fromAccount := wallet.GetDefaultAccount()
+		wallet.utxoSet.AddUTXO(&utxo.UTXO{
+			TxHash:       []byte("test_tx_hash_dust_change"),
+			TxIndex:      0,
+			Value:        value,
+			ScriptPubKey: fromAccount.PublicKey,
+			Address:      fromAccount.Address,
+			Height:       1,
+		})
+		return wallet, fromAccount.Address
+	}
+
+	toPrivKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+
+	// 300 of change is dust; adding it to the fee would make the fee more
+	// than half of the amount, so the transaction cannot be built
+	wallet, from := newFundedWallet(1000 + 546 + 300)
+	toAddress := wallet.generateChecksumAddress(toPrivKey.ToECDSA())
+	_, err = wallet.CreateTransaction(from, toAddress, 1000, 546)
+	assert.ErrorIs(t, err, ErrNonStandardTransaction)
+	assert.ErrorContains(t, err, "change 300 is below the dust threshold 546 and cannot be added to the fee")
+	assert.Equal(t, uint64(0), wallet.GetDefaultAccount().Nonce)
+
+	// With a larger amount the dust change is absorbed into the fee
+	wallet, from = newFundedWallet(10000 + 546 + 300)
+	tx, err := wallet.CreateTransaction(from, toAddress, 10000, 546)
+	require.NoError(t, err)
+	assert.Len(t, tx.Outputs, 1)
+	assert.Equal(t, uint64(846), tx.Fee)
+}
+
+func TestCreateMultiOutputTransaction(t *testing.T) {
+	wallet, err := NewWallet(DefaultWalletConfig(), utxo.NewUTXOSet(), newTestStorage(t))
+	require.NoError(t, err)
+	fromAccount := wallet.GetDefaultAccount()
+	wallet.utxoSet.AddUTXO(&utxo.UTXO{
+		TxHash:       []byte("test_tx_hash_multi_output"),
+		TxIndex:      0,
+		Value:        20000,
+		ScriptPubKey: fromAccount.PublicKey,
+		Address:      fromAccount.Address,
+		Height:       1,
+	})
+
+	recipients := make([]Recipient, 0, 3)
+	for _, amount := range []uint64{1000, 2500, 4000} {
+		privKey, err := btcec.NewPrivateKey()
+		require.NoError(t, err)
+		recipients = append(recipients, Recipient{
+			Address: wallet.generateChecksumAddress(privKey.ToECDSA()),
+			Amount:  amount,
+		})
+	}
+
+	tx, err := wallet.CreateMultiOutputTransaction(fromAccount.Address, recipients, 600)
+	require.NoError(t, err)
+	require.Len(t, tx.Outputs, 4)
+	for i, recipient := range recipients {
+		pubKeyHash, err := addressToPubKeyHash(recipient.Address)
+		require.NoError(t, err)
+		assert.Equal(t, recipient.Amount, tx.Outputs[i].Value)
+		assert.Equal(t, pubKeyHash, tx.Outputs[i].ScriptPubKey)
+	}
+
+	senderPubKeyHash, err := addressToPubKeyHash(fromAccount.Address)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(20000-7500-600), tx.Outputs[3].Value)
+	assert.Equal(t, senderPubKeyHash, tx.Outputs[3].ScriptPubKey)
+	assert.Equal(t, uint64(600), tx.Fee)
+
+	valid, err := wallet.VerifyTransaction(tx)
+	require.NoError(t, err)
+	assert.True(t, valid)
+
+	_, err = wallet.CreateMultiOutputTransaction(fromAccount.Address, nil, 600)
+	assert.ErrorContains(t, err, "no recipients")
+}
+
+func TestCreateDataTransaction(t *testing.T) {
+	us := utxo.NewUTXOSet()
+	wallet, err := NewWallet(DefaultWalletConfig(), us, newTestStorage(t))
+	require.NoError(t, err)
+	fromAccount := wallet.GetDefaultAccount()
+	us.AddUTXO(&utxo.UTXO{
+		TxHash:       []byte("test_tx_hash_data_output"),
+		TxIndex:      0,
+		Value:        10000,
+		ScriptPubKey: fromAccount.PublicKey,
+		Address:      fromAccount.Address,
+		Height:       1,
+	})
+
+	data := []byte("document digest 7f3a")
+	tx, err := wallet.CreateDataTransaction(data, 600)
+	require.NoError(t, err)
+	require.Len(t, tx.Outputs, 2)
+	assert.True(t, tx.Outputs[0].IsData())
+	assert.Equal(t, data, tx.Outputs[0].Data())
+	assert.Zero(t, tx.Outputs[0].Value)
+	assert.Equal(t, uint64(10000-600), tx.Outputs[1].Value)
+
+	coinbase := &block.Transaction{
+		Version: 1,
+		Outputs: []*block.TxOutput{{Value: 1000, ScriptPubKey: []byte("miner")}},
+	}
+	coinbase.Hash = coinbase.CalculateHash()
+	b := block.NewBlock(make([]byte, 32), 2, 1)
+	b.AddTransaction(coinbase)
+	b.AddTransaction(tx)
+	require.NoError(t, us.ProcessBlock(b))
+	assert.Nil(t, us.GetUTXO(tx.Hash, 0), "data outputs are not spendable")
+	assert.NotNil(t, us.GetUTXO(tx.Hash, 1))
+
+	_, err = wallet.CreateDataTransaction(make([]byte, block.MaxDataOutputSize+1), 600)
+	assert.ErrorIs(t, err, ErrNonStandardTransaction)
+}
+
 func TestUpdateBalance(t *testing.T) {
 	s := newTestStorage(t)
 	config := DefaultWalletConfig()
@@ -468,3 +639,56 @@ func TestConcatRS(t *testing.T) {
 	// This is a basic verification of the concatenation logic
 	assert.Equal(t, 64, len(result3))
 }
+
+func TestGetBalanceDetailed(t *testing.T) {
+	s := newTestStorage(t)
+	config := DefaultWalletConfig()
+	config.CoinbaseMaturity = 10
+	us := utxo.NewUTXOSet()
+	wallet, err := NewWallet(config, us, s)
+	assert.NoError(t, err)
+
+	address := wallet.GetDefaultAccount().Address
+
+	// Mature regular output
+	us.AddUTXO(utxo.NewUTXO([]byte("regular_tx"), 0, 5000, []byte("script"), address, false, 1))
+	// Coinbase mined at height 5 matures once the tip reaches height 14
+	us.AddUTXO(utxo.NewUTXO([]byte("old_coinbase"), 0, 3000, []byte("script"), address, true, 5))
+	// Coinbase mined at height 12 is still immature
+	us.AddUTXO(utxo.NewUTXO([]byte("new_coinbase"), 0, 2000, []byte("script"), address, true, 12))
+	// Output belonging to a different address is ignored
+	us.AddUTXO(utxo.NewUTXO([]byte("other_tx"), 0, 9000, []byte("script"), "other", false, 1))
+
+	// Incoming payment still sitting in the mempool
+	wallet.AddUnconfirmedUTXO(utxo.NewUTXO([]byte("pending_tx"), 0, 700, []byte("script"), address, false, 0))
+
+	wallet.SetChainHeight(14)
+
+	details := wallet.GetBalanceDetailed(address)
+	assert.Equal(t, uint64(10700), details.Total)
+	assert.Equal(t, uint64(10000), details.Confirmed)
+	assert.Equal(t, uint64(8000), details.Spendable)
+
+	// Once the pending output is mined it stops being counted twice
+	us.AddUTXO(utxo.NewUTXO([]byte("pending_tx"), 0, 700, []byte("script"), address, false, 15))
+	wallet.SetChainHeight(21)
+
+	details = wallet.GetBalanceDetailed(address)
+	assert.Equal(t, uint64(10700), details.Total)
+	assert.Equal(t, uint64(10700), details.Confirmed)
+	assert.Equal(t, uint64(10700), details.Spendable)
+
+	wallet.RemoveUnconfirmedUTXO([]byte("pending_tx"), 0)
+	assert.Equal(t, uint64(10700), wallet.GetBalanceDetailed(address).Total)
+
+	// Confirmations are withheld while the node is in initial block download
+	wallet.SetInitialBlockDownload(true)
+	details = wallet.GetBalanceDetailed(address)
+	assert.True(t, details.Syncing)
+	assert.Equal(t, uint64(10700), details.Total)
+	assert.Equal(t, uint64(0), details.Confirmed)
+	assert.Equal(t, uint64(0), details.Spendable)
+
+	wallet.SetInitialBlockDownload(false)
+	assert.Equal(t, uint64(10700), wallet.GetBalanceDetailed(address).Confirmed)
+}
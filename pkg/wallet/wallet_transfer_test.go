@@ -217,8 +217,8 @@ func TestWalletTransfer(t *testing.T) {
 		require.NoError(t, err)
 		bobAddress := wallet.generateChecksumAddress(bobPrivKey.ToECDSA())
 
-		// Give Alice exactly 1000 coins
-		exactAmount := uint64(1000)
+		// Give Alice exactly 1600 coins
+		exactAmount := uint64(1600)
 		aliceUTXO := &utxo.UTXO{
 			TxHash:       make([]byte, 32),
 			TxIndex:      0,
@@ -232,7 +232,7 @@ func TestWalletTransfer(t *testing.T) {
 		us.AddUTXO(aliceUTXO)
 
 		// Try to send the exact amount (minus fee)
-		transferAmount := exactAmount - 546 // 1000 - 546 = 454
+		transferAmount := exactAmount - 546 // 1600 - 546 = 1054, above the dust threshold
 		fee := uint64(546)
 
 		tx, err := wallet.CreateTransaction(aliceAccount.Address, bobAddress, transferAmount, fee)